@@ -17,14 +17,24 @@ import (
 )
 
 var commands = []*util.Command{
+	nomsBench,
+	nomsCodegen,
 	nomsCommit,
 	nomsConfig,
+	nomsCp,
 	nomsDiff,
 	nomsDs,
+	nomsDu,
+	nomsGrep,
+	nomsGraph,
 	nomsLog,
 	nomsMerge,
+	nomsOversized,
+	nomsPrune,
+	nomsReflog,
 	nomsRoot,
 	nomsServe,
+	nomsServeUI,
 	nomsShow,
 	nomsSync,
 	nomsVersion,
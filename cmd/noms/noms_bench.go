@@ -0,0 +1,344 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	benchShape  string
+	benchCount  int
+	benchEdits  float64
+	benchSeed   int64
+	benchSyncTo string
+	benchKeep   bool
+)
+
+var nomsBench = &util.Command{
+	Run:       runBench,
+	UsageLine: "bench [--shape <shape>] [--count <n>] [--edits <fraction>] [--seed <n>] [--sync-to <database>] [--keep] <database>",
+	Short:     "Measures the performance of a database",
+	Long: `Generates a collection of synthetic rows and measures how quickly it can be inserted into the database, committed, read back, edited and committed again, and diffed against the edited version. If --sync-to is given, it also measures how quickly the result can be synced to another database. The results are printed as a table, so that runs against different databases, or with different options, can be compared.
+
+The shape of the collection is one of ` + strings.Join(benchShapeNames(), ", ") + `. Runs with the same --seed generate the same data. The collection is committed to a new dataset whose name begins with "bench-", which is deleted afterwards unless --keep is given.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database arguments.`,
+	Flags: setupBenchFlags,
+	Nargs: 1,
+}
+
+func setupBenchFlags() *flag.FlagSet {
+	benchFlagSet := flag.NewFlagSet("bench", flag.ExitOnError)
+	benchFlagSet.StringVar(&benchShape, "shape", "map", "the kind of collection to generate: "+strings.Join(benchShapeNames(), ", "))
+	benchFlagSet.IntVar(&benchCount, "count", 100000, "the number of rows to generate")
+	benchFlagSet.Float64Var(&benchEdits, "edits", 0.01, "the fraction of rows to change when editing")
+	benchFlagSet.Int64Var(&benchSeed, "seed", 0, "the seed for generating data")
+	benchFlagSet.StringVar(&benchSyncTo, "sync-to", "", "a database to measure syncing to")
+	benchFlagSet.BoolVar(&benchKeep, "keep", false, "don't delete the datasets that were written")
+	verbose.RegisterVerboseFlags(benchFlagSet)
+	return benchFlagSet
+}
+
+// benchCollection generates and works on collections of one shape.
+type benchCollection interface {
+	// build returns a collection of |n| rows, written to |vrw| as it's built.
+	build(vrw types.ValueReadWriter, rnd *rand.Rand, n int) types.Collection
+	// edit returns |col| with |n| of its rows changed.
+	edit(col types.Collection, rnd *rand.Rand, n int) types.Collection
+	// iter reads every row of |col|, returning how many there were.
+	iter(col types.Collection) uint64
+	// diff returns the number of changes between |col| and |last|.
+	diff(col, last types.Collection) uint64
+}
+
+var benchShapes = map[string]benchCollection{
+	"list": benchList{},
+	"map":  benchMap{},
+	"set":  benchSet{},
+}
+
+func benchShapeNames() []string {
+	names := make([]string, 0, len(benchShapes))
+	for name := range benchShapes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// benchResult is a row of the report printed by noms bench.
+type benchResult struct {
+	op      string
+	elapsed time.Duration
+	count   uint64
+	unit    string
+}
+
+func (r benchResult) String() string {
+	rate := float64(r.count) / r.elapsed.Seconds()
+	return fmt.Sprintf("%-8s %12s %12d %-6s %14.0f %s/s", r.op, r.elapsed, r.count, r.unit, rate, r.unit)
+}
+
+func runBench(args []string) int {
+	shape, ok := benchShapes[benchShape]
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Unknown --shape: %s", benchShape))
+	}
+	if benchCount <= 0 {
+		d.CheckErrorNoUsage(errors.New("--count must be positive"))
+	}
+	if benchEdits < 0 || benchEdits > 1 {
+		d.CheckErrorNoUsage(errors.New("--edits must be between 0 and 1"))
+	}
+
+	cfg := config.NewResolver()
+	dbSpec := cfg.ResolveDbSpec(args[0])
+	db, err := cfg.GetDatabase(dbSpec)
+	d.CheckError(err)
+	defer db.Close()
+
+	var sinkDB datas.Database
+	if benchSyncTo != "" {
+		sinkDB, err = cfg.GetDatabase(benchSyncTo)
+		d.CheckError(err)
+		defer sinkDB.Close()
+	}
+
+	id := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	ds := db.GetDataset(id)
+	defer func() {
+		if !benchKeep {
+			deleteBenchDataset(db, id)
+			if sinkDB != nil {
+				deleteBenchDataset(sinkDB, id)
+			}
+		}
+	}()
+
+	fmt.Printf("Benchmarking a %s of %d rows in %s, dataset %s\n", benchShape, benchCount, dbSpec, id)
+	fmt.Printf("%-8s %12s %12s %-6s %14s\n", "op", "time", "count", "", "rate")
+	report := func(op, unit string, f func() uint64) {
+		start := time.Now()
+		count := f()
+		fmt.Println(benchResult{op, time.Since(start), count, unit})
+	}
+
+	rnd := rand.New(rand.NewSource(benchSeed))
+	var col types.Collection
+	report("insert", "rows", func() uint64 {
+		col = shape.build(db, rnd, benchCount)
+		return uint64(benchCount)
+	})
+	report("commit", "commit", func() uint64 {
+		ds, err = db.CommitValue(ds, col)
+		d.CheckErrorNoUsage(err)
+		return 1
+	})
+
+	// Read back through a newly opened database, so that none of the values
+	// are cached. A mem database can't be reopened, so it's read as is.
+	readDB := db
+	if sp, err := spec.ForDatabase(dbSpec); err == nil && sp.Protocol != "mem" {
+		defer sp.Close()
+		readDB = sp.GetDatabase()
+	}
+	report("read", "rows", func() uint64 {
+		return shape.iter(readDB.GetDataset(id).HeadValue().(types.Collection))
+	})
+
+	edits := int(float64(benchCount) * benchEdits)
+	last := col
+	report("edit", "rows", func() uint64 {
+		col = shape.edit(col, rnd, edits)
+		ds, err = db.CommitValue(ds, col)
+		d.CheckErrorNoUsage(err)
+		return uint64(edits)
+	})
+	report("diff", "diffs", func() uint64 {
+		return shape.diff(col, last)
+	})
+
+	if sinkDB != nil {
+		report("sync", "chunks", func() (chunkCount uint64) {
+			progressCh := make(chan datas.PullProgress)
+			doneCh := make(chan struct{})
+			go func() {
+				for info := range progressCh {
+					chunkCount = info.DoneCount
+				}
+				close(doneCh)
+			}()
+			headRef := ds.HeadRef()
+			datas.PullWithFlush(db, sinkDB, headRef, types.Ref{}, 512, progressCh)
+			close(progressCh)
+			<-doneCh
+			_, err = sinkDB.FastForward(sinkDB.GetDataset(id), headRef)
+			d.CheckErrorNoUsage(err)
+			return
+		})
+	}
+	return 0
+}
+
+func deleteBenchDataset(db datas.Database, id string) {
+	if ds := db.GetDataset(id); ds.HasHead() {
+		_, err := db.Delete(ds)
+		d.CheckErrorNoUsage(err)
+	}
+}
+
+// benchRow generates the |i|th row. Rows have a few fields of different
+// kinds, with some randomness, so that they don't compress unrealistically
+// well.
+func benchRow(rnd *rand.Rand, i int) types.Struct {
+	return types.NewStruct("Row", types.StructData{
+		"id":    types.Number(i),
+		"name":  types.String(fmt.Sprintf("row %d %x", i, rnd.Int63())),
+		"score": types.Number(rnd.Float64()),
+		"valid": types.Bool(rnd.Intn(2) == 0),
+	})
+}
+
+type benchList struct{}
+
+func (benchList) build(vrw types.ValueReadWriter, rnd *rand.Rand, n int) types.Collection {
+	vals := make(chan types.Value, 1024)
+	listChan := types.NewStreamingList(vrw, vals)
+	for i := 0; i < n; i++ {
+		vals <- benchRow(rnd, i)
+	}
+	close(vals)
+	return <-listChan
+}
+
+func (benchList) edit(col types.Collection, rnd *rand.Rand, n int) types.Collection {
+	l := col.(types.List)
+	for i := 0; i < n; i++ {
+		idx := rnd.Intn(int(l.Len()))
+		l = l.Set(uint64(idx), benchRow(rnd, idx))
+	}
+	return l
+}
+
+func (benchList) iter(col types.Collection) (n uint64) {
+	col.(types.List).IterAll(func(v types.Value, idx uint64) {
+		n++
+	})
+	return
+}
+
+func (benchList) diff(col, last types.Collection) (n uint64) {
+	changes := make(chan types.Splice)
+	go func() {
+		col.(types.List).Diff(last.(types.List), changes, nil)
+		close(changes)
+	}()
+	for range changes {
+		n++
+	}
+	return
+}
+
+type benchMap struct{}
+
+func (benchMap) build(vrw types.ValueReadWriter, rnd *rand.Rand, n int) types.Collection {
+	kvs := make(chan types.Value, 1024)
+	mapChan := types.NewStreamingMap(vrw, kvs)
+	for i := 0; i < n; i++ {
+		kvs <- types.Number(i)
+		kvs <- benchRow(rnd, i)
+	}
+	close(kvs)
+	return <-mapChan
+}
+
+func (benchMap) edit(col types.Collection, rnd *rand.Rand, n int) types.Collection {
+	m := col.(types.Map)
+	for i := 0; i < n; i++ {
+		k := rnd.Intn(int(m.Len()))
+		m = m.Set(types.Number(k), benchRow(rnd, k))
+	}
+	return m
+}
+
+func (benchMap) iter(col types.Collection) (n uint64) {
+	col.(types.Map).IterAll(func(k, v types.Value) {
+		n++
+	})
+	return
+}
+
+func (benchMap) diff(col, last types.Collection) uint64 {
+	return countChanges(func(changes chan<- types.ValueChanged) {
+		col.(types.Map).Diff(last.(types.Map), changes, nil)
+	})
+}
+
+// benchSet holds the names of rows, which are generated in order so that the
+// set can be streamed.
+type benchSet struct{}
+
+func benchSetElem(rnd *rand.Rand, i int) types.String {
+	return types.String(fmt.Sprintf("%012d %x", i, rnd.Int63()))
+}
+
+func (benchSet) build(vrw types.ValueReadWriter, rnd *rand.Rand, n int) types.Collection {
+	vals := make(chan types.Value, 1024)
+	setChan := types.NewStreamingSet(vrw, vals)
+	for i := 0; i < n; i++ {
+		vals <- benchSetElem(rnd, i)
+	}
+	close(vals)
+	return <-setChan
+}
+
+func (benchSet) edit(col types.Collection, rnd *rand.Rand, n int) types.Collection {
+	s := col.(types.Set)
+	for i := 0; i < n; i++ {
+		idx := rnd.Intn(int(s.Len()))
+		s = s.Remove(s.IteratorAt(uint64(idx)).Next()).Insert(benchSetElem(rnd, idx))
+	}
+	return s
+}
+
+func (benchSet) iter(col types.Collection) (n uint64) {
+	col.(types.Set).IterAll(func(v types.Value) {
+		n++
+	})
+	return
+}
+
+func (benchSet) diff(col, last types.Collection) uint64 {
+	return countChanges(func(changes chan<- types.ValueChanged) {
+		col.(types.Set).Diff(last.(types.Set), changes, nil)
+	})
+}
+
+func countChanges(diff func(changes chan<- types.ValueChanged)) (n uint64) {
+	changes := make(chan types.ValueChanged)
+	go func() {
+		diff(changes)
+		close(changes)
+	}()
+	for range changes {
+		n++
+	}
+	return
+}
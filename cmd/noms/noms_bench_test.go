@@ -0,0 +1,57 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+type nomsBenchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func TestNomsBench(t *testing.T) {
+	suite.Run(t, &nomsBenchTestSuite{})
+}
+
+func (s *nomsBenchTestSuite) datasetCount(str string) int {
+	sp, err := spec.ForDatabase(str)
+	s.NoError(err)
+	defer sp.Close()
+	return int(sp.GetDatabase().Datasets().Len())
+}
+
+func (s *nomsBenchTestSuite) TestBench() {
+	dbStr := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	sinkStr := spec.CreateDatabaseSpecString("nbs", filepath.Join(s.TempDir, "sink"))
+	for _, shape := range []string{"list", "map", "set"} {
+		stdout, _ := s.MustRun(main, []string{"bench", "--shape", shape, "--count", "1000", "--edits", "0.1", "--sync-to", sinkStr, dbStr})
+		s.Contains(stdout, "Benchmarking a "+shape+" of 1000 rows")
+		for _, op := range []string{"insert", "commit", "read", "edit", "diff", "sync"} {
+			s.Contains(stdout, "\n"+op+" ", shape)
+		}
+		s.Contains(stdout, " 1000 rows ")
+		s.Contains(stdout, " 100 rows ")
+	}
+	s.Equal(0, s.datasetCount(dbStr))
+	s.Equal(0, s.datasetCount(sinkStr))
+
+	stdout, _ := s.MustRun(main, []string{"bench", "--count", "10", "--keep", "mem"})
+	s.Equal(8, len(strings.Split(stdout, "\n")))
+	s.NotContains(stdout, "sync")
+}
+
+func (s *nomsBenchTestSuite) TestBadFlags() {
+	for _, args := range [][]string{{"--shape", "blob"}, {"--count", "0"}, {"--edits", "2"}} {
+		_, _, err := s.Run(main, append(append([]string{"bench"}, args...), "mem"))
+		s.Equal(clienttest.ExitError{Code: 1}, err, "%v", args)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nomdl"
+	"github.com/attic-labs/noms/go/nomdl/codegen"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	codegenPackage string
+	codegenName    string
+	codegenType    string
+	codegenOut     string
+)
+
+var nomsCodegen = &util.Command{
+	Run:       runCodegen,
+	UsageLine: "codegen [options] <path> | codegen [options] --type <type>",
+	Short:     "Generates Go types for the values of a Noms type",
+	Long: `Prints Go declarations of types that package marshal converts to and from values of the type of the value at <path>, or of the type that --type gives in the syntax of package nomdl, e.g. "struct Person {name: String, age?: Number}". If <path> is a dataset, the type of the value of its head is used.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupCodegenFlags,
+	Nargs: 0,
+}
+
+func setupCodegenFlags() *flag.FlagSet {
+	codegenFlagSet := flag.NewFlagSet("codegen", flag.ExitOnError)
+	codegenFlagSet.StringVar(&codegenPackage, "package", "main", "the name of the package to generate code for")
+	codegenFlagSet.StringVar(&codegenName, "name", "Value", "the name of the Go type for the type itself, unless it's a named struct")
+	codegenFlagSet.StringVar(&codegenType, "type", "", "generate code for this type rather than the type of a value")
+	codegenFlagSet.StringVar(&codegenOut, "out", "", "write the code to this file rather than to stdout")
+	verbose.RegisterVerboseFlags(codegenFlagSet)
+	return codegenFlagSet
+}
+
+func runCodegen(args []string) int {
+	var t *types.Type
+	switch {
+	case codegenType != "" && len(args) > 0:
+		d.CheckError(errors.New("cannot specify both a path and --type"))
+	case codegenType != "":
+		var err error
+		t, err = nomdl.ParseType(codegenType)
+		d.CheckErrorNoUsage(err)
+	case len(args) > 0:
+		t = valueTypeAt(args[0])
+	default:
+		d.CheckError(errors.New("a path or --type is required"))
+	}
+
+	src, err := codegen.Generate(t, codegen.Options{Package: codegenPackage, Name: codegenName})
+	d.CheckErrorNoUsage(err)
+	if codegenOut == "" {
+		fmt.Print(string(src))
+		return 0
+	}
+	d.CheckErrorNoUsage(ioutil.WriteFile(codegenOut, src, 0644))
+	return 0
+}
+
+// valueTypeAt returns the type of the value at |path|, or of the value of the
+// Commit there, if it's the head of a dataset.
+func valueTypeAt(path string) *types.Type {
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(path)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", path))
+	}
+	if datas.IsCommit(value) {
+		value = value.(types.Struct).Get(datas.ValueField)
+	}
+	return types.TypeOf(value)
+}
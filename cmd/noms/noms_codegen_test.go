@@ -0,0 +1,55 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCodegen(t *testing.T) {
+	suite.Run(t, &nomsCodegenTestSuite{})
+}
+
+type nomsCodegenTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+const codegenPoint = `// Code generated by noms codegen. DO NOT EDIT.
+
+package geo
+
+// Point is the Go type of Noms structs named Point.
+type Point struct {
+	X float64 ` + "`noms:\"x\"`" + `
+	Y float64 ` + "`noms:\"y\"`" + `
+}
+`
+
+func (s *nomsCodegenTestSuite) TestCodegen() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "points"))
+	s.NoError(err)
+	defer sp.Close()
+	point := types.NewStruct("Point", types.StructData{"x": types.Number(1), "y": types.Number(2)})
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), point)
+	s.NoError(err)
+
+	// A dataset stands for the value of its head.
+	stdout, stderr, _ := s.Run(main, []string{"codegen", "--package", "geo", sp.String()})
+	s.Equal("", stderr)
+	s.Equal(codegenPoint, stdout)
+
+	stdout, stderr, _ = s.Run(main, []string{"codegen", "--package", "geo", "--type", "struct Point {x: Number, y: Number}"})
+	s.Equal("", stderr)
+	s.Equal(codegenPoint, stdout)
+
+	stdout, stderr, _ = s.Run(main, []string{"codegen", "--package", "geo", "--name", "Points", "--type", "List<struct Point {x: Number, y: Number}>"})
+	s.Equal("", stderr)
+	s.Contains(stdout, "type Points []Point\n")
+}
@@ -33,7 +33,7 @@ var nomsCommit = &util.Command{
 func setupCommitFlags() *flag.FlagSet {
 	commitFlagSet := flag.NewFlagSet("commit", flag.ExitOnError)
 	commitFlagSet.BoolVar(&allowDupe, "allow-dupe", false, "creates a new commit, even if it would be identical (modulo metadata and parents) to the existing HEAD.")
-	spec.RegisterCommitMetaFlags(commitFlagSet)
+	config.RegisterCommitMetaFlags(commitFlagSet)
 	verbose.RegisterVerboseFlags(commitFlagSet)
 	return commitFlagSet
 }
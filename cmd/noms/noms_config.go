@@ -5,35 +5,132 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/outputpager"
 	flag "github.com/juju/gnuflag"
 )
 
+var configUser bool
+
 var nomsConfig = &util.Command{
 	Run:       runConfig,
-	UsageLine: "config ",
-	Short:     "Display noms config info",
-	Long:      "Prints the active configuration if a .nomsconfig file is present",
-	Flags:     setupConfigFlags,
-	Nargs:     0,
+	UsageLine: "config [--user] [list | get <key> | set <key> <value>]",
+	Short:     "Display or change noms config settings",
+	Long: `With no arguments, prints the active configuration, if there is one. The active configuration is the .nomsconfig file in the current directory or the closest of its ancestors, layered over the user's .nomsconfig in their home directory.
+
+list prints each setting of the active configuration, get prints the value of a single setting, and set changes one. set writes to the .nomsconfig that's in effect, or creates one in the current directory. With --user, the commands read and write the user's .nomsconfig instead.
+
+The settings are:
+  db.<alias>.url  the URL of the database named by <alias>; "default" names the database used when none is given
+  user.name       the name recorded as the author of commits
+  user.email      the email address recorded as the author of commits
+  ui.color        whether to color output: auto, always or never
+  ui.pager        whether to page output: auto or never`,
+	Flags: setupConfigFlags,
+	Nargs: 0,
 }
 
 func setupConfigFlags() *flag.FlagSet {
-	return flag.NewFlagSet("config", flag.ExitOnError)
+	configFlagSet := flag.NewFlagSet("config", flag.ExitOnError)
+	configFlagSet.BoolVar(&configUser, "user", false, "use the user's config rather than the active one")
+	return configFlagSet
 }
 
 func runConfig(args []string) int {
-	c, err := config.FindNomsConfig()
-	if err == config.NoConfig {
-		fmt.Fprintf(os.Stdout, "no config active\n")
-	} else {
+	if len(args) == 0 {
+		c, err := readConfig()
+		if err == config.NoConfig {
+			fmt.Fprintf(os.Stdout, "no config active\n")
+		} else {
+			d.CheckError(err)
+			fmt.Fprintf(os.Stdout, "%s\n", c.String())
+		}
+		return 0
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		c, err := readConfig()
+		if err == config.NoConfig {
+			return 0
+		}
+		d.CheckError(err)
+		for _, k := range c.Keys() {
+			v, _ := c.Get(k)
+			fmt.Fprintf(os.Stdout, "%s = %s\n", k, v)
+		}
+	case args[0] == "get" && len(args) == 2:
+		c, err := readConfig()
+		if err == config.NoConfig {
+			return 1
+		}
+		d.CheckError(err)
+		v, ok := c.Get(args[1])
+		if !ok {
+			return 1
+		}
+		fmt.Fprintln(os.Stdout, v)
+	case args[0] == "set" && len(args) == 3:
+		file, err := configFileToEdit()
 		d.CheckError(err)
-		fmt.Fprintf(os.Stdout, "%s\n", c.String())
+		err = config.EditConfig(file, func(c *config.Config) error {
+			return c.Set(args[1], args[2])
+		})
+		d.CheckErrorNoUsage(err)
+	default:
+		d.CheckError(errors.New("Expected list, get <key> or set <key> <value>"))
 	}
 	return 0
 }
+
+// readConfig returns the config that the config command works with: the
+// active one or, with --user, the user's.
+func readConfig() (*config.Config, error) {
+	if !configUser {
+		return config.LoadConfig()
+	}
+	file := config.UserConfigFile()
+	if file == "" {
+		return nil, config.NoConfig
+	}
+	c, err := config.ReadConfig(file)
+	if os.IsNotExist(err) {
+		return nil, config.NoConfig
+	}
+	return c, err
+}
+
+func configFileToEdit() (string, error) {
+	if configUser {
+		if file := config.UserConfigFile(); file != "" {
+			return file, nil
+		}
+		return "", errors.New("Can't find the user's config without $HOME")
+	}
+	c, err := config.FindNomsConfig()
+	if err == config.NoConfig {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(cwd, config.NomsConfigFile), nil
+	} else if err != nil {
+		return "", err
+	}
+	return c.File, nil
+}
+
+// startPager starts paging output, unless the config turns paging off.
+func startPager(cfg *config.Resolver) *outputpager.Pager {
+	if cfg.Config().UI.Pager == config.UINever {
+		outputpager.Disable()
+	}
+	return outputpager.Start()
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+type nomsConfigTestSuite struct {
+	clienttest.ClientTestSuite
+	oldHome, oldWd string
+}
+
+func TestNomsConfig(t *testing.T) {
+	suite.Run(t, &nomsConfigTestSuite{})
+}
+
+func (s *nomsConfigTestSuite) SetupTest() {
+	s.oldHome = os.Getenv("HOME")
+	wd, err := os.Getwd()
+	s.NoError(err)
+	s.oldWd = wd
+
+	home := filepath.Join(s.TempDir, "home")
+	repo := filepath.Join(s.TempDir, "repo")
+	s.NoError(os.RemoveAll(home))
+	s.NoError(os.RemoveAll(repo))
+	s.NoError(os.MkdirAll(home, os.ModePerm))
+	s.NoError(os.MkdirAll(repo, os.ModePerm))
+	s.NoError(os.Setenv("HOME", home))
+	s.NoError(os.Chdir(repo))
+}
+
+func (s *nomsConfigTestSuite) TearDownTest() {
+	s.NoError(os.Setenv("HOME", s.oldHome))
+	s.NoError(os.Chdir(s.oldWd))
+}
+
+func (s *nomsConfigTestSuite) TestNomsConfigSetGetList() {
+	stdout, _ := s.MustRun(main, []string{"config"})
+	s.Equal("no config active\n", stdout)
+
+	s.MustRun(main, []string{"config", "--user", "set", "user.name", "Ada"})
+	s.MustRun(main, []string{"config", "--user", "set", "user.email", "ada@example.com"})
+	s.MustRun(main, []string{"config", "set", "user.email", "ada@work.example.com"})
+	s.MustRun(main, []string{"config", "set", "db.default.url", "nbs:db"})
+
+	stdout, _ = s.MustRun(main, []string{"config", "get", "user.name"})
+	s.Equal("Ada\n", stdout)
+	stdout, _ = s.MustRun(main, []string{"config", "get", "user.email"})
+	s.Equal("ada@work.example.com\n", stdout)
+	stdout, _ = s.MustRun(main, []string{"config", "--user", "get", "user.email"})
+	s.Equal("ada@example.com\n", stdout)
+
+	_, _, exitErr := s.Run(main, []string{"config", "get", "ui.color"})
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+	_, _, exitErr = s.Run(main, []string{"config", "set", "ui.color", "sometimes"})
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+
+	// The repo's config is written where the command was run, with the
+	// database path left relative.
+	c, err := config.ReadConfig(filepath.Join(s.TempDir, "repo", config.NomsConfigFile))
+	s.NoError(err)
+	s.Equal("nbs:"+filepath.Join(s.TempDir, "repo", "db"), c.Db[config.DefaultDbAlias].Url)
+
+	stdout, _ = s.MustRun(main, []string{"config", "list"})
+	s.Equal("db.default.url = nbs:"+filepath.Join(s.TempDir, "repo", "db")+"\nuser.name = Ada\nuser.email = ada@work.example.com\n", stdout)
+}
+
+func (s *nomsConfigTestSuite) TestNomsCommitAuthor() {
+	s.MustRun(main, []string{"config", "--user", "set", "user.name", "Ada"})
+	s.MustRun(main, []string{"config", "--user", "set", "user.email", "ada@example.com"})
+
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "commitAuthor"))
+	s.NoError(err)
+	defer sp.Close()
+	ref := sp.GetDatabase().WriteValue(types.String("author"))
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), ref)
+	s.NoError(err)
+
+	s.MustRun(main, []string{"commit", "--allow-dupe", "#" + ref.TargetHash().String(), sp.String()})
+
+	sp, err = spec.ForDataset(sp.String())
+	s.NoError(err)
+	defer sp.Close()
+	meta := sp.GetDataset().Head().Get(datas.MetaField).(types.Struct)
+	s.Equal(types.String("Ada <ada@example.com>"), meta.Get("author"))
+}
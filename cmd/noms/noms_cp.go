@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsCp = &util.Command{
+	Run:       runCp,
+	UsageLine: "cp [options] <source-path> <dest-dataset>",
+	Short:     "Copies a value, or part of one, into a dataset",
+	Long:      "Copies the value at source-path, which may be in another database, and everything it refers to into the database of dest-dataset, and commits it as the new head of dest-dataset. The commit's meta records the source-path and the hash of the value copied, in the fields copiedFrom and copiedHash. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path and dataset arguments.",
+	Flags:     setupCpFlags,
+	Nargs:     2,
+}
+
+func setupCpFlags() *flag.FlagSet {
+	cpFlagSet := flag.NewFlagSet("cp", flag.ExitOnError)
+	cpFlagSet.IntVar(&p, "p", 512, "parallelism")
+	config.RegisterCommitMetaFlags(cpFlagSet)
+	verbose.RegisterVerboseFlags(cpFlagSet)
+	return cpFlagSet
+}
+
+func runCp(args []string) int {
+	cfg := config.NewResolver()
+	sourceStore, value, err := cfg.GetPath(args[0])
+	d.CheckError(err)
+	defer sourceStore.Close()
+
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[0]))
+	}
+
+	sinkDB, sinkDataset, err := cfg.GetDataset(args[1])
+	d.CheckError(err)
+	defer sinkDB.Close()
+
+	// The value itself may be embedded in a chunk rather than be one, so the
+	// chunks it refers to are pulled, in one pass so that those they share
+	// are only walked once, and the value is written by Commit.
+	refs := types.RefSlice{}
+	value.WalkRefs(func(r types.Ref) {
+		refs = append(refs, r)
+	})
+	err = d.Try(func() {
+		datas.PullMany(sourceStore, sinkDB, refs, types.Ref{}, p, nil)
+	})
+	d.CheckErrorNoUsage(err)
+
+	meta, err := spec.CreateCommitMetaStruct(sinkDB, "", "", map[string]string{
+		"copiedFrom": cfg.ResolvePathSpec(args[0]),
+		"copiedHash": "#" + value.Hash().String(),
+	}, nil)
+	d.CheckErrorNoUsage(err)
+
+	oldCommitRef, oldCommitExists := sinkDataset.MaybeHeadRef()
+	sinkDataset, err = sinkDB.Commit(sinkDataset, value, datas.CommitOptions{Meta: meta})
+	d.CheckErrorNoUsage(err)
+
+	if oldCommitExists {
+		fmt.Fprintf(os.Stdout, "New head #%v (was #%v)\n", sinkDataset.HeadRef().TargetHash().String(), oldCommitRef.TargetHash().String())
+	} else {
+		fmt.Fprintf(os.Stdout, "New head #%v\n", sinkDataset.HeadRef().TargetHash().String())
+	}
+	return 0
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestCp(t *testing.T) {
+	suite.Run(t, &nomsCpTestSuite{})
+}
+
+type nomsCpTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsCpTestSuite) TestCp() {
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	nums := make([]types.Value, 10000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	doc := sourceDB.WriteValue(types.String("doc"))
+	items := types.NewStruct("Items", types.StructData{
+		"list": types.NewList(nums...),
+		"doc":  doc,
+	})
+	_, err := sourceDB.CommitValue(sourceDB.GetDataset("src"), types.NewStruct("Root", types.StructData{
+		"items": items,
+	}))
+	s.NoError(err)
+	sourceDB.Close()
+
+	sourcePath := spec.CreateValueSpecString("nbs", s.DBDir, "src.value.items")
+	sinkDatasetSpec := spec.CreateValueSpecString("nbs", s.DBDir2, "dest")
+	sout, _ := s.MustRun(main, []string{"cp", sourcePath, sinkDatasetSpec})
+	s.Regexp("New head #", sout)
+
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	dest := db.GetDataset("dest")
+	s.True(items.Equals(dest.HeadValue()))
+	s.Equal(uint64(len(nums)), dest.HeadValue().(types.Struct).Get("list").(types.List).Len())
+	s.True(types.String("doc").Equals(db.ReadValue(doc.TargetHash())))
+
+	meta := dest.Head().Get(datas.MetaField).(types.Struct)
+	s.Equal(types.String(sourcePath), meta.Get("copiedFrom"))
+	s.Equal(types.String("#"+items.Hash().String()), meta.Get("copiedHash"))
+	db.Close()
+
+	// Copying again commits on top of the existing head.
+	sout, _ = s.MustRun(main, []string{"cp", sourcePath, sinkDatasetSpec})
+	s.Regexp(`New head #\w+ \(was #\w+\)`, sout)
+}
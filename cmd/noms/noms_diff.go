@@ -57,7 +57,7 @@ func runDiff(args []string) int {
 		return 0
 	}
 
-	pgr := outputpager.Start()
+	pgr := startPager(cfg)
 	defer pgr.Stop()
 
 	diff.PrintDiff(pgr.Writer, value1, value2, false)
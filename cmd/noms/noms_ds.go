@@ -10,16 +10,18 @@ import (
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
-	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
-var toDelete string
+var (
+	toDelete string
+	dsPrefix string
+)
 
 var nomsDs = &util.Command{
 	Run:       runDs,
-	UsageLine: "ds [<database> | -d <dataset>]",
+	UsageLine: "ds [--prefix <prefix>] [<database> | -d <dataset>]",
 	Short:     "Noms dataset management",
 	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and dataset arguments.",
 	Flags:     setupDsFlags,
@@ -29,6 +31,7 @@ var nomsDs = &util.Command{
 func setupDsFlags() *flag.FlagSet {
 	dsFlagSet := flag.NewFlagSet("ds", flag.ExitOnError)
 	dsFlagSet.StringVar(&toDelete, "d", "", "dataset to delete")
+	dsFlagSet.StringVar(&dsPrefix, "prefix", "", "only list datasets whose names begin with this prefix, e.g. team/app/")
 	verbose.RegisterVerboseFlags(dsFlagSet)
 	return dsFlagSet
 }
@@ -58,9 +61,9 @@ func runDs(args []string) int {
 		d.CheckError(err)
 		defer store.Close()
 
-		store.Datasets().IterAll(func(k, v types.Value) {
-			fmt.Println(k)
-		})
+		for _, id := range store.ListDatasets(dsPrefix) {
+			fmt.Println(id)
+		}
 	}
 	return 0
 }
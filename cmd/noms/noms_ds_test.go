@@ -67,6 +67,12 @@ func (s *nomsDsTestSuite) TestNomsDs() {
 	rtnVal, _ = s.MustRun(main, []string{"ds", dbSpec})
 	s.Equal(id+"\n"+id2+"\n", rtnVal)
 
+	// only the datasets with a prefix
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--prefix", id + "2", dbSpec})
+	s.Equal(id2+"\n", rtnVal)
+	rtnVal, _ = s.MustRun(main, []string{"ds", "--prefix", "nope", dbSpec})
+	s.Equal("", rtnVal)
+
 	// delete one dataset, print message at delete
 	rtnVal, _ = s.MustRun(main, []string{"ds", "-d", datasetName})
 	s.Equal("Deleted "+datasetName+" (was #ko033p6voiin65necjgcc4kdi2iqbfa8)\n", rtnVal)
@@ -0,0 +1,74 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var duBytes bool
+
+var nomsDu = &util.Command{
+	Run:       runDu,
+	UsageLine: "du [--bytes] <path>",
+	Short:     "Shows how much storage the values under a path take up",
+	Long: `Prints, for each field of the struct, or entry of the map, or element of the list or set at <path>, the storage that it takes up: its own encoding, and the chunks that it reaches through refs or as a large collection. Chunks that only it reaches are counted as unique to it, and removing it would free them; chunks that others reach too are counted as shared by each. The last line is the total storage that the value at <path> reaches. If <path> is a dataset, the value of its head is measured.
+
+Every chunk that the value reaches is read, which can take a while for large values.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupDuFlags,
+	Nargs: 1,
+}
+
+func setupDuFlags() *flag.FlagSet {
+	duFlagSet := flag.NewFlagSet("du", flag.ExitOnError)
+	duFlagSet.BoolVar(&duBytes, "bytes", false, "print exact numbers of bytes, rather than rounded human readable sizes")
+	verbose.RegisterVerboseFlags(duFlagSet)
+	return duFlagSet
+}
+
+func runDu(args []string) int {
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(args[0])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		fmt.Fprintf(os.Stderr, "Object not found: %s\n", args[0])
+		return 1
+	}
+
+	root := args[0]
+	if datas.IsCommit(value) {
+		value = value.(types.Struct).Get(datas.ValueField)
+		root += types.NewFieldPath(datas.ValueField).String()
+	}
+
+	usage, total := datas.MeasureUsage(db, value)
+	fmt.Printf("%10s %10s  %s\n", "UNIQUE", "SHARED", "PATH")
+	for _, u := range usage {
+		fmt.Printf("%10s %10s  %s\n", duSize(u.Unique), duSize(u.Shared), root+u.Path.String())
+	}
+	fmt.Printf("%10s %10s  %s\n", duSize(total), "", "total")
+	return 0
+}
+
+func duSize(n uint64) string {
+	if duBytes {
+		return strconv.FormatUint(n, 10)
+	}
+	return humanize.Bytes(n)
+}
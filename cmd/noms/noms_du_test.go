@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsDu(t *testing.T) {
+	suite.Run(t, &nomsDuTestSuite{})
+}
+
+type nomsDuTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsDuTestSuite) TestDu() {
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+	sp, err := spec.ForDataset(str)
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+
+	size := func(v types.Value) uint64 {
+		return uint64(len(types.EncodeValue(v, db).Data()))
+	}
+	big := types.NewList(types.String("big"), types.String("list"))
+	bigRef := db.WriteValue(big)
+	name := types.String("du")
+	v := types.NewStruct("", types.StructData{"big": bigRef, "name": name})
+	_, err = db.CommitValue(sp.GetDataset(), v)
+	s.NoError(err)
+
+	stdout, _ := s.MustRun(main, []string{"du", "--bytes", str})
+	row := func(unique, shared, path string) string {
+		return fmt.Sprintf("%10s %10s  %s\n", unique, shared, path)
+	}
+	s.Equal(row("UNIQUE", "SHARED", "PATH")+
+		row(fmt.Sprint(size(bigRef)+size(big)), "0", str+".value.big")+
+		row(fmt.Sprint(size(name)), "0", str+".value.name")+
+		row(fmt.Sprint(size(v)+size(big)), "", "total"), stdout)
+
+	stdout, _ = s.MustRun(main, []string{"du", str + ".value.name"})
+	s.Equal(row("UNIQUE", "SHARED", "PATH")+row(fmt.Sprintf("%d B", size(name)), "", "total"), stdout)
+}
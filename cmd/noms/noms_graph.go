@@ -0,0 +1,54 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	flag "github.com/juju/gnuflag"
+)
+
+var graphFormat string
+
+var nomsGraph = &util.Command{
+	Run:       runGraph,
+	UsageLine: "graph [--format dot|json] <database>",
+	Short:     "Print the commit graph of all datasets in a database",
+	Long:      "Prints every commit reachable from the datasets of the database, with edges to their parents and markers for dataset heads, in the GraphViz DOT language (the default) or as JSON. For example: noms graph db | dot -Tsvg > graph.svg\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupGraphFlags,
+	Nargs:     1,
+}
+
+func setupGraphFlags() *flag.FlagSet {
+	graphFlagSet := flag.NewFlagSet("graph", flag.ExitOnError)
+	graphFlagSet.StringVar(&graphFormat, "format", "dot", "output format, either dot or json")
+	return graphFlagSet
+}
+
+func runGraph(args []string) int {
+	var write func(g datas.CommitGraph) error
+	switch graphFormat {
+	case "dot":
+		write = func(g datas.CommitGraph) error { return g.WriteDOT(os.Stdout) }
+	case "json":
+		write = func(g datas.CommitGraph) error { return g.WriteJSON(os.Stdout) }
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid format: %s\n", graphFormat)
+		return 1
+	}
+
+	cfg := config.NewResolver()
+	db, err := cfg.GetDatabase(args[0])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	d.CheckErrorNoUsage(write(datas.ExportCommitGraph(db)))
+	return 0
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsGraph(t *testing.T) {
+	suite.Run(t, &nomsGraphTestSuite{})
+}
+
+type nomsGraphTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsGraphTestSuite) TestGraph() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+
+	ds := sp.GetDataset()
+	ds, err = ds.Database().CommitValue(ds, types.String("one"))
+	s.NoError(err)
+	first := ds.HeadRef().TargetHash().String()
+	ds, err = ds.Database().CommitValue(ds, types.String("two"))
+	s.NoError(err)
+	second := ds.HeadRef().TargetHash().String()
+
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	dot, _ := s.MustRun(main, []string{"graph", dbSpec})
+	s.Contains(dot, `"ds:ds" -> "`+second+`" [style=dashed];`)
+	s.Contains(dot, `"`+second+`" -> "`+first+`";`)
+
+	out, _ := s.MustRun(main, []string{"graph", "--format", "json", dbSpec})
+	var g struct {
+		Heads   map[string]string
+		Commits []struct{ Hash string }
+	}
+	s.NoError(json.Unmarshal([]byte(out), &g))
+	s.Equal(second, g.Heads["ds"])
+	s.Len(g.Commits, 2)
+}
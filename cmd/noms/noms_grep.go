@@ -0,0 +1,306 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	grepRegex       bool
+	grepIgnoreCase  bool
+	grepKinds       string
+	grepParallelism int
+)
+
+// grepBatch is the number of elements of a collection that are searched
+// together. Larger collections are split into batches that are searched in
+// parallel, each loading the chunks it spans.
+const grepBatch = 1 << 12
+
+var nomsGrep = &util.Command{
+	Run:       runGrep,
+	UsageLine: "grep [options] <pattern> <path>",
+	Short:     "Prints the paths of values that match a pattern",
+	Long: `Searches the value at <path>, and every value it holds or references, for values that contain <pattern>, and prints the path of each of them, e.g. "/tmp/db::people.value[3].name". If <path> is a dataset, the value of its head is searched.
+
+Only the kinds of values that --kinds gives are searched: Strings by their text, and Numbers and Bools as they're written by noms show. Map keys are searched as well as their values. Refs are followed, and a value that's reachable by several Refs is searched once, and only reported at the first of their paths. Large collections are searched in parallel, so that their chunks are loaded in parallel too; the paths are printed in order nonetheless.
+
+Exits with status 1 if there are no matches.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.`,
+	Flags: setupGrepFlags,
+	Nargs: 2,
+}
+
+func setupGrepFlags() *flag.FlagSet {
+	grepFlagSet := flag.NewFlagSet("grep", flag.ExitOnError)
+	grepFlagSet.BoolVar(&grepRegex, "regex", false, "treat the pattern as a regular expression, in the syntax of Go's regexp package")
+	grepFlagSet.BoolVar(&grepIgnoreCase, "i", false, "ignore case when matching")
+	grepFlagSet.StringVar(&grepKinds, "kinds", "String", "comma-separated kinds of values to match: Bool, Number or String")
+	grepFlagSet.IntVar(&grepParallelism, "p", 16, "number of batches of values to search at once")
+	verbose.RegisterVerboseFlags(grepFlagSet)
+	return grepFlagSet
+}
+
+func runGrep(args []string) int {
+	pattern := args[0]
+	if !grepRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if grepIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	d.CheckError(err)
+	kinds, err := parseGrepKinds(grepKinds)
+	d.CheckError(err)
+	if grepParallelism < 1 {
+		d.CheckError(fmt.Errorf("-p must be at least 1"))
+	}
+
+	cfg := config.NewResolver()
+	db, value, err := cfg.GetPath(args[1])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if value == nil {
+		fmt.Fprintf(os.Stderr, "Object not found: %s\n", args[1])
+		return 1
+	}
+
+	root := args[1]
+	if datas.IsCommit(value) {
+		value = value.(types.Struct).Get(datas.ValueField)
+		root += types.NewFieldPath(datas.ValueField).String()
+	}
+
+	g := newGrepper(db, re, kinds, grepParallelism)
+	if g.grep(root, value, os.Stdout) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// parseGrepKinds parses the --kinds flag.
+func parseGrepKinds(s string) (map[types.NomsKind]bool, error) {
+	kinds := map[types.NomsKind]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, k := range []types.NomsKind{types.BoolKind, types.NumberKind, types.StringKind} {
+			if strings.EqualFold(name, k.String()) {
+				kinds[k], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Invalid kind: %s, must be Bool, Number or String", name)
+		}
+	}
+	return kinds, nil
+}
+
+// grepper searches values for ones that match a regexp. The values that
+// Refs target, and batches of the elements of large collections, are
+// searched in parallel, by at most as many goroutines as sem holds; each has
+// its own grepResults, which are printed in the order they'd have been found
+// in by searching one value at a time.
+type grepper struct {
+	vr    types.ValueReader
+	re    *regexp.Regexp
+	kinds map[types.NomsKind]bool
+	sem   chan struct{}
+
+	mu      sync.Mutex
+	targets map[hash.Hash]*grepResults
+}
+
+// grepResults are the matches of a part of a search, which are complete once
+// done is closed. The paths of the matches in the value that a Ref targets
+// are relative to the Ref's.
+type grepResults struct {
+	done  chan struct{}
+	items []grepItem
+}
+
+// grepItem is either the path of a match, or the results of a part of the
+// search that was started at that point. If that part is the value that a Ref
+// targets, target is its hash, and path is the path of the Ref's target.
+type grepItem struct {
+	path   string
+	more   *grepResults
+	target hash.Hash
+}
+
+func newGrepper(vr types.ValueReader, re *regexp.Regexp, kinds map[types.NomsKind]bool, parallelism int) *grepper {
+	return &grepper{vr: vr, re: re, kinds: kinds, sem: make(chan struct{}, parallelism), targets: map[hash.Hash]*grepResults{}}
+}
+
+// grep searches |v|, whose path is |root|, and writes the paths of the
+// matches to |w|, one per line. It returns the number of matches.
+func (g *grepper) grep(root string, v types.Value, w io.Writer) int {
+	res := &grepResults{done: make(chan struct{})}
+	go func() {
+		g.walk(root, v, res)
+		close(res.done)
+	}()
+	return printGrepResults(w, "", res, hash.HashSet{})
+}
+
+// printGrepResults writes the paths in |res|, prefixed with |prefix|. The
+// matches in a value that several Refs target are only written at the first
+// of them, whichever of them it was searched from, so the output doesn't
+// depend on the order in which the search ran.
+func printGrepResults(w io.Writer, prefix string, res *grepResults, printed hash.HashSet) (n int) {
+	<-res.done
+	for _, item := range res.items {
+		switch {
+		case item.more == nil:
+			fmt.Fprintln(w, prefix+item.path)
+			n++
+		case item.target.IsEmpty():
+			n += printGrepResults(w, prefix, item.more, printed)
+		case !printed.Has(item.target):
+			printed.Insert(item.target)
+			n += printGrepResults(w, prefix+item.path, item.more, printed)
+		}
+	}
+	return
+}
+
+// spawn searches with |f|, in parallel if a goroutine is free and otherwise
+// before returning, and returns its results. Waiting for a goroutine instead
+// could deadlock, since they all spawn more.
+func (g *grepper) spawn(f func(res *grepResults)) *grepResults {
+	res := &grepResults{done: make(chan struct{})}
+	select {
+	case g.sem <- struct{}{}:
+		go func() {
+			defer func() { <-g.sem }()
+			f(res)
+			close(res.done)
+		}()
+	default:
+		f(res)
+		close(res.done)
+	}
+	return res
+}
+
+func (g *grepper) walk(path string, v types.Value, res *grepResults) {
+	switch v := v.(type) {
+	case types.Bool, types.Number, types.String:
+		if g.matches(v) {
+			res.items = append(res.items, grepItem{path: path})
+		}
+	case types.Struct:
+		v.IterFields(func(name string, fv types.Value) {
+			g.walk(path+types.NewFieldPath(name).String(), fv, res)
+		})
+	case types.List:
+		g.walkBatches(v.Len(), res, func(start, end uint64, res *grepResults) {
+			it := v.IteratorAt(start)
+			for i := start; i < end; i++ {
+				g.walk(fmt.Sprintf("%s[%d]", path, i), it.Next(), res)
+			}
+		})
+	case types.Map:
+		g.walkBatches(v.Len(), res, func(start, end uint64, res *grepResults) {
+			it := v.IteratorAt(start)
+			for i := start; i < end; i++ {
+				k, mv := it.Next()
+				var index, key types.PathPart
+				if types.ValueCanBePathIndex(k) {
+					index, key = types.NewIndexPath(k), types.NewIndexIntoKeyPath(k)
+				} else {
+					index, key = types.NewHashIndexPath(k.Hash()), types.NewHashIndexIntoKeyPath(k.Hash())
+				}
+				g.walk(path+key.String(), k, res)
+				g.walk(path+index.String(), mv, res)
+			}
+		})
+	case types.Set:
+		g.walkBatches(v.Len(), res, func(start, end uint64, res *grepResults) {
+			it := v.IteratorAt(start)
+			for i := start; i < end; i++ {
+				sv := it.Next()
+				g.walk(path+types.NewHashIndexPath(sv.Hash()).String(), sv, res)
+			}
+		})
+	case types.Ref:
+		h := v.TargetHash()
+		res.items = append(res.items, grepItem{
+			path:   path + types.TargetAnnotation{}.String(),
+			more:   g.searchTarget(h),
+			target: h,
+		})
+	}
+	// Blobs and Types aren't searched.
+}
+
+// walkBatches calls |f| for the elements of a collection of |n| of them, in
+// batches of grepBatch, which are searched in parallel if there's more than
+// one.
+func (g *grepper) walkBatches(n uint64, res *grepResults, f func(start, end uint64, res *grepResults)) {
+	if n <= grepBatch {
+		f(0, n, res)
+		return
+	}
+	for start := uint64(0); start < n; start += grepBatch {
+		start, end := start, start+grepBatch
+		if end > n {
+			end = n
+		}
+		res.items = append(res.items, grepItem{more: g.spawn(func(res *grepResults) {
+			f(start, end, res)
+		})})
+	}
+}
+
+// searchTarget returns the results of searching the value that |h|
+// addresses, which is only searched the first time, with paths relative to
+// its own.
+func (g *grepper) searchTarget(h hash.Hash) *grepResults {
+	g.mu.Lock()
+	res, ok := g.targets[h]
+	if !ok {
+		// Reserve the entry, so that other Refs to |h| wait for this search.
+		res = &grepResults{done: make(chan struct{})}
+		g.targets[h] = res
+	}
+	g.mu.Unlock()
+	if ok {
+		return res
+	}
+	found := g.spawn(func(res *grepResults) {
+		g.walk("", g.vr.ReadValue(h), res)
+	})
+	res.items = []grepItem{{more: found}}
+	close(res.done)
+	return res
+}
+
+func (g *grepper) matches(v types.Value) bool {
+	if !g.kinds[v.Kind()] {
+		return false
+	}
+	if s, ok := v.(types.String); ok {
+		return g.re.MatchString(string(s))
+	}
+	return g.re.MatchString(types.EncodedValue(v))
+}
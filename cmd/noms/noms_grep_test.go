@@ -0,0 +1,127 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsGrep(t *testing.T) {
+	suite.Run(t, &nomsGrepTestSuite{})
+}
+
+type nomsGrepTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsGrepTestSuite) TestGrep() {
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "people")
+	sp, err := spec.ForDataset(str)
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+
+	person := func(name string, age float64) types.Struct {
+		return types.NewStruct("Person", types.StructData{"name": types.String(name), "age": types.Number(age)})
+	}
+	bob := db.WriteValue(person("Bob", 42))
+	value := types.NewStruct("", types.StructData{
+		"people": types.NewList(person("Alice", 30), person("Robert", 4)),
+		"nicknames": types.NewMap(
+			types.String("Bobby"), types.String("Bob"),
+			types.Number(7), types.String("Rob"),
+		),
+		"tags": types.NewSet(types.String("robot")),
+		"best": bob,
+	})
+	_, err = db.CommitValue(sp.GetDataset(), value)
+	s.NoError(err)
+
+	robot := types.NewHashIndexPath(types.String("robot").Hash()).String()
+	stdout, _ := s.MustRun(main, []string{"grep", "-i", "OB", str})
+	s.Equal(strings.Join([]string{
+		str + `.value.best@target.name`,
+		str + `.value.nicknames[7]`,
+		str + `.value.nicknames["Bobby"]@key`,
+		str + `.value.nicknames["Bobby"]`,
+		str + `.value.people[1].name`,
+		str + `.value.tags` + robot,
+		"",
+	}, "\n"), stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "--regex", "^R", str + ".value.people"})
+	s.Equal(str+".value.people[1].name\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"grep", "--kinds", "Number", "--regex", "^4", str})
+	s.Equal(str+".value.best@target.age\n"+str+".value.people[1].age\n", stdout)
+
+	// Numbers aren't searched unless --kinds says so.
+	_, _, exitErr := s.Run(main, []string{"grep", "30", str})
+	s.Equal(clienttest.ExitError{Code: 1}, exitErr)
+}
+
+func TestGrepBatches(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := datas.NewDatabase(storage.NewView())
+
+	n := 3*grepBatch + 1
+	values := make([]types.Value, n)
+	want := &bytes.Buffer{}
+	for i := range values {
+		values[i] = types.String(fmt.Sprintf("value %d", i))
+		if i > 0 && i%1000 == 0 {
+			fmt.Fprintf(want, "l[%d]\n", i)
+		}
+	}
+	_, err := db.CommitValue(db.GetDataset("l"), types.NewList(values...))
+	assert.NoError(err)
+	db.Close()
+
+	// The batches load the chunks of the List from a fresh Database.
+	db = datas.NewDatabase(storage.NewView())
+	defer db.Close()
+	l := db.GetDataset("l").HeadValue()
+
+	out := &bytes.Buffer{}
+	g := newGrepper(db, regexp.MustCompile(`0{3}$`), map[types.NomsKind]bool{types.StringKind: true}, 2)
+	assert.Equal(n/1000, g.grep("l", l, out))
+	assert.Equal(want.String(), out.String())
+}
+
+func TestGrepSharedRefs(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := datas.NewDatabase(storage.NewView())
+	defer db.Close()
+
+	// Every batch of the List reaches the same value, which is reported at the
+	// first path to it however the batches are scheduled.
+	shared := db.WriteValue(types.NewStruct("", types.StructData{"name": types.String("shared")}))
+	values := make([]types.Value, 3*grepBatch+1)
+	for i := range values {
+		values[i] = shared
+	}
+	l := types.NewList(values...)
+
+	for i := 0; i < 10; i++ {
+		out := &bytes.Buffer{}
+		g := newGrepper(db, regexp.MustCompile("shared"), map[types.NomsKind]bool{types.StringKind: true}, 2)
+		assert.Equal(1, g.grep("l", l, out))
+		assert.Equal("l[0]@target.name\n", out.String())
+	}
+}
@@ -51,7 +51,7 @@ var nomsLog = &util.Command{
 
 func setupLogFlags() *flag.FlagSet {
 	logFlagSet := flag.NewFlagSet("log", flag.ExitOnError)
-	logFlagSet.IntVar(&color, "color", -1, "value of 1 forces color on, 0 forces color off")
+	logFlagSet.IntVar(&color, "color", -1, "value of 1 forces color on, 0 forces color off (defaults to ui.color in the config)")
 	logFlagSet.IntVar(&maxLines, "max-lines", 9, "max number of lines to show per commit (-1 for all lines)")
 	logFlagSet.IntVar(&maxCommits, "n", 0, "max number of commits to display (0 for all commits)")
 	logFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
@@ -63,8 +63,8 @@ func setupLogFlags() *flag.FlagSet {
 }
 
 func runLog(args []string) int {
-	useColor = shouldUseColor()
 	cfg := config.NewResolver()
+	useColor = shouldUseColor(cfg.Config())
 
 	resolved := cfg.ResolvePathSpec(args[0])
 	sp, err := spec.ForPath(resolved)
@@ -116,7 +116,7 @@ func runLog(args []string) int {
 		close(bytesChan)
 	}()
 
-	pgr := outputpager.Start()
+	pgr := startPager(cfg)
 	defer pgr.Stop()
 
 	for ch := range bytesChan {
@@ -354,8 +354,14 @@ func writeDiffLines(node LogNode, path types.Path, db datas.Database, maxLines,
 	return int(pw.NumLines), err
 }
 
-func shouldUseColor() bool {
+func shouldUseColor(c *config.Config) bool {
 	if color != 1 && color != 0 {
+		switch c.UI.Color {
+		case config.UIAlways:
+			return true
+		case config.UINever:
+			return false
+		}
 		return outputpager.IsStdoutTty()
 	}
 	return color == 1
@@ -15,6 +15,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/status"
 	"github.com/attic-labs/noms/go/util/verbose"
@@ -38,6 +39,7 @@ var (
 func setupMergeFlags() *flag.FlagSet {
 	commitFlagSet := flag.NewFlagSet("merge", flag.ExitOnError)
 	commitFlagSet.StringVar(&resolver, "policy", "n", "conflict resolution policy for merging. Defaults to 'n', which means no resolution strategy will be applied. Supported values are 'l' (left), 'r' (right) and 'p' (prompt). 'prompt' will bring up a simple command-line prompt allowing you to resolve conflicts by choosing between 'l' or 'r' on a case-by-case basis.")
+	config.RegisterCommitMetaFlags(commitFlagSet)
 	verbose.RegisterVerboseFlags(commitFlagSet)
 	return commitFlagSet
 }
@@ -66,7 +68,10 @@ func runMerge(args []string) int {
 	d.CheckErrorNoUsage(err)
 	close(pc)
 
-	_, err = db.SetHead(outDS, db.WriteValue(datas.NewCommit(merged, types.NewSet(leftDS.HeadRef(), rightDS.HeadRef()), types.EmptyStruct)))
+	meta, err := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
+	d.CheckErrorNoUsage(err)
+
+	_, err = db.SetHead(outDS, db.WriteValue(datas.NewCommit(merged, types.NewSet(leftDS.HeadRef(), rightDS.HeadRef()), meta)))
 	d.PanicIfError(err)
 	if !verbose.Quiet() {
 		status.Printf("Done")
@@ -74,10 +74,17 @@ func (s *nomsMergeTestSuite) TestNomsMerge_Success() {
 	})
 
 	output := "output"
-	stdout, stderr, err := s.Run(main, []string{"merge", s.DBDir, left, right, output})
+	stdout, stderr, err := s.Run(main, []string{"merge", "--message", "merge right", "--author", "Ada <ada@example.com>", s.DBDir, left, right, output})
 	if err == nil {
 		s.Equal("", stderr)
 		s.validateDataset(output, expected, l, r)
+
+		sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, output))
+		s.NoError(err)
+		defer sp.Close()
+		meta := sp.GetDataset().Head().Get(datas.MetaField).(types.Struct)
+		s.Equal(types.String("merge right"), meta.Get("message"))
+		s.Equal(types.String("Ada <ada@example.com>"), meta.Get("author"))
 	} else {
 		s.Fail("Run failed", "err: %v\nstdout: %s\nstderr: %s\n", err, stdout, stderr)
 	}
@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsOversized = &util.Command{
+	Run:       runOversized,
+	UsageLine: "oversized <db-spec> <max-size>",
+	Short:     "Lists the chunks in a database that are larger than a maximum size",
+	Long: `Prints the hash, size and kind of each chunk reachable from the root of the database whose data is more than <max-size> (e.g. "400KB") long, e.g. to find the chunks that keep the database from being copied to a backend that can't store them. Oversized chunks are usually Blobs or Strings that were written without being chunked. The maxchunksize parameter of aws database specs keeps them from being written in the first place.
+
+Every reachable chunk is read, which can take a while for large databases.
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.`,
+	Flags: setupOversizedFlags,
+	Nargs: 2,
+}
+
+func setupOversizedFlags() *flag.FlagSet {
+	return flag.NewFlagSet("oversized", flag.ExitOnError)
+}
+
+func runOversized(args []string) int {
+	max, err := humanize.ParseBytes(args[1])
+	if err != nil || max == 0 {
+		fmt.Fprintf(os.Stderr, "Invalid size: %s\n", args[1])
+		return 1
+	}
+
+	cfg := config.NewResolver()
+	db, err := cfg.GetDatabase(args[0])
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	datas.FindOversizedChunks(db, max, func(oc datas.OversizedChunk) {
+		fmt.Printf("%s %10s  %s\n", oc.Hash, humanize.Bytes(oc.Size), types.KindToString[oc.Kind])
+	})
+	return 0
+}
@@ -0,0 +1,50 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+	humanize "github.com/dustin/go-humanize"
+)
+
+func TestNomsOversized(t *testing.T) {
+	suite.Run(t, &nomsOversizedTestSuite{})
+}
+
+type nomsOversizedTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsOversizedTestSuite) TestOversized() {
+	str := spec.CreateValueSpecString("nbs", s.DBDir, "ds")
+	sp, err := spec.ForDataset(str)
+	s.NoError(err)
+	defer sp.Close()
+	db := sp.GetDatabase()
+
+	big := types.String(strings.Repeat("x", 2000))
+	bigRef := db.WriteValue(big)
+	_, err = db.CommitValue(sp.GetDataset(), types.NewStruct("", types.StructData{"big": bigRef}))
+	s.NoError(err)
+
+	dbStr := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	stdout, _ := s.MustRun(main, []string{"oversized", dbStr, "1KB"})
+	size := uint64(len(types.EncodeValue(big, db).Data()))
+	s.Equal(fmt.Sprintf("%s %10s  String\n", bigRef.TargetHash(), humanize.Bytes(size)), stdout)
+
+	stdout, _ = s.MustRun(main, []string{"oversized", dbStr, "1MB"})
+	s.Equal("", stdout)
+
+	_, stderr, recovered := s.Run(main, []string{"oversized", dbStr, "lots"})
+	s.NotNil(recovered)
+	s.Contains(stderr, "Invalid size: lots")
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	keepCommits   int
+	keepFor       string
+	pruneNoReflog bool
+	pruneDryRun   bool
+	pruneYes      bool
+)
+
+var nomsPrune = &util.Command{
+	Run:       runPrune,
+	UsageLine: "prune [--keep-commits <n>] [--keep-for <duration>] [--no-reflog] [--dry-run] [--yes] <dataset>",
+	Short:     "Drops old history from a dataset",
+	Long: `Rewrites the history of a dataset so that it only contains the commits allowed by the retention policy, which is given by --keep-commits and --keep-for. A commit is kept if either flag allows it, and the head is always kept. The dropped commits, and data that only they refer to, can then be reclaimed by garbage collection.
+
+The old head is added to the dataset's reflog, so that the prune can be undone with noms reflog, but that keeps the dropped commits reachable too. --no-reflog drops the dataset's reflog instead, so that they can be reclaimed, and the prune can't be undone.
+
+The commits that would be dropped are listed, along with an estimate of the space that could be reclaimed, and confirmation is asked for before anything is changed. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupPruneFlags,
+	Nargs: 1,
+}
+
+func setupPruneFlags() *flag.FlagSet {
+	pruneFlagSet := flag.NewFlagSet("prune", flag.ExitOnError)
+	pruneFlagSet.IntVar(&keepCommits, "keep-commits", 0, "keep this many of the most recent commits, counting the head")
+	pruneFlagSet.StringVar(&keepFor, "keep-for", "", "keep commits whose meta date is at most this old, e.g. 36h or 30d")
+	pruneFlagSet.BoolVar(&pruneNoReflog, "no-reflog", false, "drop the dataset's reflog, so that the dropped commits can be reclaimed")
+	pruneFlagSet.BoolVar(&pruneDryRun, "dry-run", false, "only list what would be dropped")
+	pruneFlagSet.BoolVar(&pruneYes, "yes", false, "don't ask for confirmation")
+	verbose.RegisterVerboseFlags(pruneFlagSet)
+	return pruneFlagSet
+}
+
+func runPrune(args []string) int {
+	policy, err := parseRetentionPolicy(keepCommits, keepFor)
+	d.CheckErrorNoUsage(err)
+	policy.DropReflog = pruneNoReflog
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	if !ds.HasHead() {
+		d.CheckErrorNoUsage(fmt.Errorf("Dataset %s not found", ds.ID()))
+	}
+
+	now := time.Now()
+	plan := datas.PlanPrune(db, ds, policy, now)
+	if len(plan.Drop) == 0 {
+		fmt.Printf("Nothing to prune, keeping all %d commits\n", len(plan.Keep))
+		return 0
+	}
+
+	fmt.Printf("Keeping %d commits, dropping %d:\n", len(plan.Keep), len(plan.Drop))
+	for i := len(plan.Drop) - 1; i >= 0; i-- {
+		fmt.Println("  " + describeCommit(db, plan.Drop[i]))
+	}
+	if plan.DropReflog {
+		chunkCount, chunkBytes := plan.Reclaimable(db)
+		fmt.Printf("Up to %s in %d chunks can be reclaimed by garbage collection afterwards\n", humanize.Bytes(chunkBytes), chunkCount)
+	} else {
+		fmt.Printf("The old head will be kept in the reflog of %s, so nothing can be reclaimed unless --no-reflog is given\n", ds.ID())
+	}
+
+	if pruneDryRun {
+		return 0
+	}
+	if !pruneYes {
+		fmt.Printf("Drop %d commits from %s? [y/N] ", len(plan.Drop), ds.ID())
+		answer, _, err := bufio.NewReader(os.Stdin).ReadLine()
+		if err != nil || !strings.EqualFold(strings.TrimSpace(string(answer)), "y") {
+			fmt.Println("Aborted")
+			return 1
+		}
+	}
+
+	oldHeadRef := ds.HeadRef()
+	ds, _, err = datas.Prune(db, ds, policy, now)
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("New head #%s (was #%s)\n", ds.HeadRef().TargetHash().String(), oldHeadRef.TargetHash().String())
+	return 0
+}
+
+func parseRetentionPolicy(keepCommits int, keepFor string) (policy datas.RetentionPolicy, err error) {
+	if keepCommits < 0 {
+		return policy, errors.New("--keep-commits must not be negative")
+	}
+	policy.KeepCommits = keepCommits
+
+	if keepFor != "" {
+		// time.ParseDuration doesn't know about days, which is what retention
+		// is usually expressed in.
+		if days := strings.TrimSuffix(keepFor, "d"); days != keepFor {
+			n, err := strconv.ParseUint(days, 10, 32)
+			if err != nil {
+				return policy, fmt.Errorf("Invalid --keep-for: %s", keepFor)
+			}
+			policy.KeepFor = time.Duration(n) * 24 * time.Hour
+		} else if policy.KeepFor, err = time.ParseDuration(keepFor); err != nil || policy.KeepFor < 0 {
+			return policy, fmt.Errorf("Invalid --keep-for: %s", keepFor)
+		}
+	}
+
+	if policy.IsZero() {
+		return policy, errors.New("Either --keep-commits or --keep-for is required")
+	}
+	return policy, nil
+}
+
+func describeCommit(db datas.Database, r types.Ref) string {
+	s := "#" + r.TargetHash().String()
+	meta := r.TargetValue(db).(types.Struct).Get(datas.MetaField).(types.Struct)
+	for _, field := range []string{"date", "message"} {
+		if v, ok := meta.MaybeGet(field); ok {
+			if str, ok := v.(types.String); ok {
+				s += " " + string(str)
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+type nomsPruneTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func TestNomsPrune(t *testing.T) {
+	suite.Run(t, &nomsPruneTestSuite{})
+}
+
+// setupDatedDataset commits each of |values| to a new dataset, dated a day
+// apart and ending today.
+func setupDatedDataset(s *clienttest.ClientTestSuite, name string, values ...types.Value) string {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, name))
+	s.NoError(err)
+	defer sp.Close()
+
+	db, ds := sp.GetDatabase(), sp.GetDataset()
+	for i, v := range values {
+		date := time.Now().AddDate(0, 0, i+1-len(values)).Format(datas.CommitMetaDateFormat)
+		meta := types.NewStruct("Meta", types.StructData{"date": types.String(date), "message": types.String("commit")})
+		ds, err = db.Commit(ds, v, datas.CommitOptions{Meta: meta})
+		s.NoError(err)
+	}
+	return sp.String()
+}
+
+// headAndLength returns the head of the dataset |str| and the length of its
+// history.
+func headAndLength(s *clienttest.ClientTestSuite, str string) (types.Ref, int) {
+	sp, err := spec.ForDataset(str)
+	s.NoError(err)
+	defer sp.Close()
+
+	ds := sp.GetDataset()
+	n := 0
+	for it := NewCommitIterator(sp.GetDatabase(), ds.Head()); ; n++ {
+		if _, ok := it.Next(); !ok {
+			break
+		}
+	}
+	return ds.HeadRef(), n
+}
+
+func (s *nomsPruneTestSuite) TestDryRun() {
+	str := setupDatedDataset(&s.ClientTestSuite, "dryRun", types.Number(1), types.Number(2), types.Number(3), types.Number(4))
+	head, n := headAndLength(&s.ClientTestSuite, str)
+	s.Equal(4, n)
+
+	stdout, _ := s.MustRun(main, []string{"prune", "--keep-commits", "2", "--dry-run", str})
+	s.Contains(stdout, "Keeping 2 commits, dropping 2:")
+	s.Contains(stdout, " commit\n")
+	s.Contains(stdout, "nothing can be reclaimed unless --no-reflog is given")
+
+	stdout, _ = s.MustRun(main, []string{"prune", "--keep-commits", "2", "--no-reflog", "--dry-run", str})
+	s.Contains(stdout, "can be reclaimed by garbage collection")
+
+	head2, n := headAndLength(&s.ClientTestSuite, str)
+	s.True(head.Equals(head2))
+	s.Equal(4, n)
+
+	stdout, _ = s.MustRun(main, []string{"prune", "--keep-commits", "10", str})
+	s.Equal("Nothing to prune, keeping all 4 commits\n", stdout)
+}
+
+func (s *nomsPruneTestSuite) TestPrune() {
+	str := setupDatedDataset(&s.ClientTestSuite, "prune", types.Number(1), types.Number(2), types.Number(3), types.Number(4))
+
+	stdout, _ := s.MustRun(main, []string{"prune", "--keep-for", "36h", "--yes", str})
+	s.Contains(stdout, "New head #")
+	_, n := headAndLength(&s.ClientTestSuite, str)
+	s.Equal(2, n)
+
+	stdout, _ = s.MustRun(main, []string{"reflog", str})
+	s.Contains(stdout, " prune #")
+	s.MustRun(main, []string{"prune", "--keep-commits", "1", "--no-reflog", "--yes", str})
+	stdout, _ = s.MustRun(main, []string{"reflog", str})
+	s.Equal("No previous heads of prune\n", stdout)
+}
+
+func (s *nomsPruneTestSuite) TestConfirmation() {
+	str := setupDatedDataset(&s.ClientTestSuite, "confirm", types.Number(1), types.Number(2), types.Number(3))
+
+	withStdin := func(input string, f func()) {
+		oldStdin := os.Stdin
+		newStdin, stdinWriter, err := os.Pipe()
+		s.NoError(err)
+		os.Stdin = newStdin
+		defer func() {
+			os.Stdin = oldStdin
+		}()
+		go func() {
+			stdinWriter.Write([]byte(input))
+			stdinWriter.Close()
+		}()
+		f()
+	}
+
+	withStdin("n\n", func() {
+		stdout, _, err := s.Run(main, []string{"prune", "--keep-commits", "1", str})
+		s.Equal(clienttest.ExitError{Code: 1}, err)
+		s.Contains(stdout, "Drop 2 commits from confirm? [y/N] Aborted\n")
+	})
+	_, n := headAndLength(&s.ClientTestSuite, str)
+	s.Equal(3, n)
+
+	withStdin("y\n", func() {
+		s.MustRun(main, []string{"prune", "--keep-commits", "1", str})
+	})
+	_, n = headAndLength(&s.ClientTestSuite, str)
+	s.Equal(1, n)
+}
+
+func (s *nomsPruneTestSuite) TestBadFlags() {
+	str := setupDatedDataset(&s.ClientTestSuite, "badFlags", types.Number(1))
+	for _, args := range [][]string{{}, {"--keep-for", "soon"}, {"--keep-for", "-1h"}, {"--keep-commits", "-1"}} {
+		_, _, err := s.Run(main, append(append([]string{"prune"}, args...), str))
+		s.Equal(clienttest.ExitError{Code: 1}, err, "%v", args)
+	}
+}
+
+func TestParseRetentionPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := parseRetentionPolicy(3, "")
+	assert.NoError(err)
+	assert.Equal(datas.RetentionPolicy{KeepCommits: 3}, p)
+
+	p, err = parseRetentionPolicy(0, "30d")
+	assert.NoError(err)
+	assert.Equal(datas.RetentionPolicy{KeepFor: 30 * 24 * time.Hour}, p)
+
+	p, err = parseRetentionPolicy(1, "90m")
+	assert.NoError(err)
+	assert.Equal(datas.RetentionPolicy{KeepCommits: 1, KeepFor: 90 * time.Minute}, p)
+
+	for _, s := range []string{"d", "-3d", "1.5d", "x"} {
+		_, err = parseRetentionPolicy(0, s)
+		assert.Error(err, s)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var reflogRestore int
+
+var nomsReflog = &util.Command{
+	Run:       runReflog,
+	UsageLine: "reflog [--restore <n>] <dataset>",
+	Short:     "Lists or restores the previous heads of a dataset",
+	Long: `Lists the heads that a dataset had before they were replaced by an update that can drop history - noms prune, deleting or renaming the dataset, or setting its head directly - newest first. Each is numbered, and --restore <n> makes the head numbered n the head of the dataset again. Restoring is itself logged, so it can be undone.
+
+Deleted datasets keep their reflog, so they can be restored too. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.`,
+	Flags: setupReflogFlags,
+	Nargs: 1,
+}
+
+func setupReflogFlags() *flag.FlagSet {
+	reflogFlagSet := flag.NewFlagSet("reflog", flag.ExitOnError)
+	reflogFlagSet.IntVar(&reflogRestore, "restore", -1, "make the head numbered n the head of the dataset again")
+	verbose.RegisterVerboseFlags(reflogFlagSet)
+	return reflogFlagSet
+}
+
+func runReflog(args []string) int {
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	entries := datas.Reflog(db, ds.ID())
+	if reflogRestore < 0 {
+		if len(entries) == 0 {
+			fmt.Printf("No previous heads of %s\n", ds.ID())
+		}
+		for i, e := range entries {
+			fmt.Printf("%d: %s %s %s\n", i, e.Date.Local().Format(time.RFC3339), e.Op, describeCommit(db, e.Head))
+		}
+		return 0
+	}
+
+	if reflogRestore >= len(entries) {
+		d.CheckErrorNoUsage(fmt.Errorf("%s has no previous head numbered %d", ds.ID(), reflogRestore))
+	}
+	oldHeadRef, hadHead := ds.MaybeHeadRef()
+	ds, err = datas.RestoreFromReflog(db, ds, entries[reflogRestore])
+	d.CheckErrorNoUsage(err)
+	if hadHead {
+		fmt.Printf("New head #%s (was #%s)\n", ds.HeadRef().TargetHash().String(), oldHeadRef.TargetHash().String())
+	} else {
+		fmt.Printf("Restored %s to #%s\n", ds.ID(), ds.HeadRef().TargetHash().String())
+	}
+	return 0
+}
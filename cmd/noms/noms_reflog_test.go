@@ -0,0 +1,52 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+type nomsReflogTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func TestNomsReflog(t *testing.T) {
+	suite.Run(t, &nomsReflogTestSuite{})
+}
+
+// TestRestorePrune undoes a prune with noms reflog.
+func (s *nomsReflogTestSuite) TestRestorePrune() {
+	str := setupDatedDataset(&s.ClientTestSuite, "reflog", types.Number(1), types.Number(2), types.Number(3))
+	head, _ := headAndLength(&s.ClientTestSuite, str)
+
+	stdout, _ := s.MustRun(main, []string{"reflog", str})
+	s.Equal("No previous heads of reflog\n", stdout)
+
+	s.MustRun(main, []string{"prune", "--keep-commits", "1", "--yes", str})
+	_, n := headAndLength(&s.ClientTestSuite, str)
+	s.Equal(1, n)
+
+	stdout, _ = s.MustRun(main, []string{"reflog", str})
+	s.Contains(stdout, "0: ")
+	s.Contains(stdout, " prune #"+head.TargetHash().String()+" ")
+
+	_, _, err := s.Run(main, []string{"reflog", "--restore", "1", str})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+
+	stdout, _ = s.MustRun(main, []string{"reflog", "--restore", "0", str})
+	s.Contains(stdout, "New head #"+head.TargetHash().String())
+	head2, n := headAndLength(&s.ClientTestSuite, str)
+	s.True(head.Equals(head2))
+	s.Equal(3, n)
+
+	stdout, _ = s.MustRun(main, []string{"reflog", str})
+	s.Contains(stdout, "0: ")
+	s.Contains(stdout, " set-head #")
+	s.Contains(stdout, "1: ")
+}
@@ -5,28 +5,52 @@
 package main
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/metrics"
+	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/util/profile"
 	"github.com/attic-labs/noms/go/util/verbose"
+	humanize "github.com/dustin/go-humanize"
 	flag "github.com/juju/gnuflag"
 )
 
 var (
-	port int
+	port          int
+	metricsPort   int
+	statsInterval int
+	serveDir      string
+	serveStdio    bool
+
+	maxOpenFiles   int
+	indexCacheSize string
+	queueCommits   bool
+)
+
+const (
+	// Resources shared by all the databases served with --dir.
+	serveDirIndexCacheSize = 1 << 26
+	serveDirMaxTables      = 1 << 10
 )
 
 var nomsServe = &util.Command{
 	Run:       runServe,
-	UsageLine: "serve [options] <database>",
+	UsageLine: "serve [options] <database> | serve [options] --dir <directory> | serve --stdio <database>",
 	Short:     "Serves a Noms database over HTTP",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Long:      "With --dir, every database in a subdirectory of <directory> is served, with the first component of the URL path selecting the database. For example, with --dir /data the database in /data/foo is available at http://localhost:8000/foo. Databases are opened when first requested.\n\nWith --stdio, a single client is served over standard input and output, rather than on a port. ssh:// database specs run noms serve --stdio on the remote host over ssh, so hosts that can't open an HTTP port can still be pulled from and pushed to.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
 	Flags:     setupServeFlags,
 	Nargs:     0,
 }
@@ -34,20 +58,78 @@ var nomsServe = &util.Command{
 func setupServeFlags() *flag.FlagSet {
 	serveFlagSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveFlagSet.IntVar(&port, "port", 8000, "port to listen on for HTTP requests")
+	serveFlagSet.IntVar(&metricsPort, "metrics-port", 0, "if non-zero, port on which to serve collected metrics")
+	serveFlagSet.IntVar(&statsInterval, "stats-interval", 0, "if non-zero, log a summary of request stats every this many seconds")
+	serveFlagSet.StringVar(&serveDir, "dir", "", "serve all the databases in subdirectories of this directory")
+	serveFlagSet.BoolVar(&serveStdio, "stdio", false, "serve a single client over standard input and output, as ssh:// database specs do")
+	serveFlagSet.IntVar(&maxOpenFiles, "max-open-files", 0, "if non-zero, the number of table files to keep open")
+	serveFlagSet.StringVar(&indexCacheSize, "index-cache-size", "", "if set, the amount of memory used to cache table indices, e.g. 64MB")
+	serveFlagSet.BoolVar(&queueCommits, "queue-commits", false, "apply clients' commits on the server, one at a time, so that concurrent writers needn't retry")
 	verbose.RegisterVerboseFlags(serveFlagSet)
 	profile.RegisterProfileFlags(serveFlagSet)
 	return serveFlagSet
 }
 
 func runServe(args []string) int {
-	cfg := config.NewResolver()
-	db := ""
-	if len(args) > 0 {
-		db = args[0]
-	}
-	cs, err := cfg.GetChunkStore(db)
+	cacheOpts, err := parseTableCacheOptions(maxOpenFiles, indexCacheSize)
 	d.CheckError(err)
-	server := datas.NewRemoteDatabaseServer(cs, port)
+
+	var server *datas.RemoteDatabaseServer
+	if serveDir != "" {
+		if len(args) > 0 {
+			d.CheckError(errors.New("cannot specify both a database and --dir"))
+		}
+		if serveStdio {
+			d.CheckError(errors.New("cannot specify both --dir and --stdio"))
+		}
+		d.CheckErrorNoUsage(nbs.CheckDir(serveDir))
+		if cacheOpts.IndexCacheSize == 0 {
+			cacheOpts.IndexCacheSize = serveDirIndexCacheSize
+		}
+		if cacheOpts.MaxOpenFiles == 0 {
+			cacheOpts.MaxOpenFiles = serveDirMaxTables
+		}
+		f := nbs.NewLocalStoreFactory(serveDir, cacheOpts.IndexCacheSize, cacheOpts.MaxOpenFiles)
+		defer f.Shutter()
+		server = datas.NewMultiRemoteDatabaseServer(openDirDatabase(f, serveDir), port)
+	} else {
+		if cacheOpts != (nbs.TableCacheOptions{}) {
+			d.CheckError(nbs.ConfigureTableCache(cacheOpts))
+		}
+		cfg := config.NewResolver()
+		db := ""
+		if len(args) > 0 {
+			db = args[0]
+		}
+		cs, err := cfg.GetChunkStore(db)
+		d.CheckError(err)
+		server = datas.NewRemoteDatabaseServer(cs, port)
+	}
+	server.QueueCommits = queueCommits
+
+	if serveStdio {
+		// Anything else written to stdout would corrupt the responses, so
+		// metrics and stats aren't served.
+		d.Try(func() {
+			defer server.Stop()
+			server.ServeStdio(os.Stdin, os.Stdout)
+		})
+		return 0
+	}
+
+	stopStats := make(chan struct{})
+	if metricsPort != 0 || statsInterval > 0 {
+		metrics.SetEnabled(true)
+	}
+	if metricsPort != 0 {
+		go func() {
+			fmt.Printf("Serving metrics on port %d...\n", metricsPort)
+			d.CheckError(http.ListenAndServe(fmt.Sprintf(":%d", metricsPort), metrics.Handler()))
+		}()
+	}
+	if statsInterval > 0 {
+		go logServeStats(time.Duration(statsInterval)*time.Second, stopStats)
+	}
 
 	// Shutdown server gracefully so that profile may be written
 	c := make(chan os.Signal, 1)
@@ -55,6 +137,7 @@ func runServe(args []string) int {
 	signal.Notify(c, syscall.SIGTERM)
 	go func() {
 		<-c
+		close(stopStats)
 		server.Stop()
 	}()
 
@@ -64,3 +147,60 @@ func runServe(args []string) int {
 	})
 	return 0
 }
+
+// parseTableCacheOptions builds the table cache limits given by the
+// --max-open-files and --index-cache-size flags. Zero values leave the
+// defaults in place.
+func parseTableCacheOptions(maxOpenFiles int, indexCacheSize string) (opts nbs.TableCacheOptions, err error) {
+	if maxOpenFiles < 0 {
+		return opts, fmt.Errorf("Invalid --max-open-files: %d", maxOpenFiles)
+	}
+	opts.MaxOpenFiles = maxOpenFiles
+	if indexCacheSize != "" {
+		if opts.IndexCacheSize, err = humanize.ParseBytes(indexCacheSize); err != nil {
+			return opts, fmt.Errorf("Invalid --index-cache-size: %s", indexCacheSize)
+		}
+	}
+	return opts, nil
+}
+
+// openDirDatabase returns a function that opens the database in the
+// subdirectory |name| of |dir| using |f|. Unlike f.CreateStore, it fails
+// rather than creating databases that don't exist yet.
+func openDirDatabase(f chunks.Factory, dir string) func(name string) (chunks.ChunkStore, error) {
+	return func(name string) (chunks.ChunkStore, error) {
+		if name == "" || name[0] == '.' {
+			return nil, fmt.Errorf("Invalid database name: %s", name)
+		}
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("Database not found: %s", name)
+		}
+		return f.CreateStore(name), nil
+	}
+}
+
+// logServeStats logs a one-line summary of the requests served during each
+// |interval| until |stop| is closed.
+func logServeStats(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := metrics.Snapshot()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		curr := metrics.Snapshot()
+		log.Println(serveStatsLine(curr, last, interval))
+		last = curr
+	}
+}
+
+func serveStatsLine(curr, last map[string]metrics.Histogram, interval time.Duration) string {
+	latency := curr[datas.ServerRequestLatencyMetric].Delta(last[datas.ServerRequestLatencyMetric])
+	bytes := curr[datas.ServerBytesPerResponseMetric].Delta(last[datas.ServerBytesPerResponseMetric])
+	qps := float64(latency.Samples()) / interval.Seconds()
+	return fmt.Sprintf("QPS: %.1f, Bytes: %s, P99: %s", qps, humanize.Bytes(bytes.Sum()), time.Duration(latency.Percentile(.99)))
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/metrics"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestServeStatsLine(t *testing.T) {
+	assert := assert.New(t)
+
+	latency, bytes := metrics.NewTimeHistogram(), metrics.NewByteHistogram()
+	last := map[string]metrics.Histogram{
+		datas.ServerRequestLatencyMetric:   latency,
+		datas.ServerBytesPerResponseMetric: bytes,
+	}
+
+	for i := 0; i < 20; i++ {
+		latency.SampleTime(time.Millisecond)
+		bytes.Sample(1000)
+	}
+	curr := map[string]metrics.Histogram{
+		datas.ServerRequestLatencyMetric:   latency,
+		datas.ServerBytesPerResponseMetric: bytes,
+	}
+
+	assert.Equal("QPS: 2.0, Bytes: 15 kB, P99: 1.048575ms", serveStatsLine(curr, last, 10*time.Second))
+	assert.Equal("QPS: 0.0, Bytes: 0 B, P99: 0s", serveStatsLine(curr, curr, 10*time.Second))
+}
+
+func TestOpenDirDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	assert.NoError(os.Mkdir(filepath.Join(dir, "db"), 0777))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0666))
+
+	f := nbs.NewLocalStoreFactory(dir, 0, 8)
+	defer f.Shutter()
+	open := openDirDatabase(f, dir)
+
+	cs, err := open("db")
+	assert.NoError(err)
+	assert.NotNil(cs)
+	cs.Close()
+
+	for _, name := range []string{"missing", "file", ".", "..", ""} {
+		_, err := open(name)
+		assert.Error(err, name)
+	}
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestParseTableCacheOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	opts, err := parseTableCacheOptions(0, "")
+	assert.NoError(err)
+	assert.Equal(nbs.TableCacheOptions{}, opts)
+
+	opts, err = parseTableCacheOptions(256, "64MB")
+	assert.NoError(err)
+	assert.Equal(nbs.TableCacheOptions{MaxOpenFiles: 256, IndexCacheSize: 64 * 1000 * 1000}, opts)
+
+	_, err = parseTableCacheOptions(-1, "")
+	assert.Error(err)
+	_, err = parseTableCacheOptions(0, "lots")
+	assert.Error(err)
+}
@@ -0,0 +1,402 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/attic-labs/noms/go/util/writers"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	uiPort     int
+	uiPageSize int
+)
+
+const (
+	// uiValueLines is the number of lines each entry of a value is shown in,
+	// and uiDiffLines the number of lines of a diff that are shown.
+	uiValueLines = 10
+	uiDiffLines  = 2000
+	// uiBlobLineBytes is the number of bytes of a Blob in each line of its
+	// hex dump.
+	uiBlobLineBytes = 16
+)
+
+var nomsServeUI = &util.Command{
+	Run:       runServeUI,
+	UsageLine: "serve-ui [--port <port>] <database>",
+	Short:     "Serves a read-only web UI for browsing a Noms database",
+	Long:      "Serves web pages that list the datasets in <database>, show values a page at a time, and show the history of each dataset along with the diff that each commit made, so that data can be inspected with just a web browser. Nothing can be changed through the UI.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupServeUIFlags,
+	Nargs:     1,
+}
+
+func setupServeUIFlags() *flag.FlagSet {
+	serveUIFlagSet := flag.NewFlagSet("serve-ui", flag.ExitOnError)
+	serveUIFlagSet.IntVar(&uiPort, "port", 8080, "port to listen on for HTTP requests")
+	serveUIFlagSet.IntVar(&uiPageSize, "page-size", 100, "number of entries, commits or lines of a blob to show per page")
+	verbose.RegisterVerboseFlags(serveUIFlagSet)
+	return serveUIFlagSet
+}
+
+func runServeUI(args []string) int {
+	if uiPageSize <= 0 {
+		d.CheckError(fmt.Errorf("Invalid --page-size: %d", uiPageSize))
+	}
+	cfg := config.NewResolver()
+	db, err := cfg.GetDatabase(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	fmt.Printf("Serving %s at http://localhost:%d/\n", args[0], uiPort)
+	d.CheckErrorNoUsage(http.ListenAndServe(fmt.Sprintf(":%d", uiPort), newUIHandler(db, uiPageSize)))
+	return 0
+}
+
+// uiHandler serves the pages of noms serve-ui:
+//   /                           lists the datasets
+//   /value?hash=<h>&path=<p>    shows the value at path p of the value h
+//   /log?ds=<id>                shows the history of a dataset
+//   /diff?from=<h1>&to=<h2>     shows the diff between two values, or the
+//                               values of two commits
+// Each takes a page parameter too, counting from 0.
+type uiHandler struct {
+	db       datas.Database
+	pageSize int
+	mux      *http.ServeMux
+}
+
+func newUIHandler(db datas.Database, pageSize int) http.Handler {
+	h := &uiHandler{db: db, pageSize: pageSize, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/", h.serveDatasets)
+	h.mux.HandleFunc("/value", h.serveValue)
+	h.mux.HandleFunc("/log", h.serveLog)
+	h.mux.HandleFunc("/diff", h.serveDiff)
+	return h
+}
+
+func (h *uiHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(w, "The UI is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	err := d.Try(func() { h.mux.ServeHTTP(w, req) })
+	if err != nil {
+		http.Error(w, d.Unwrap(err).Error(), http.StatusInternalServerError)
+	}
+}
+
+// uiPager says which page of a sequence of entries is shown.
+type uiPager struct {
+	Page, Start, End, Total uint64
+	Prev, Next              string
+}
+
+// pager returns the uiPager for the page that |req| asks for, of |total|
+// entries of which h.pageSize are shown at a time.
+func (h *uiHandler) pager(req *http.Request, total uint64) uiPager {
+	page, _ := strconv.ParseUint(req.URL.Query().Get("page"), 10, 64)
+	size := uint64(h.pageSize)
+	if page*size >= total && total > 0 {
+		page = (total - 1) / size
+	}
+	p := uiPager{Page: page, Start: page * size, End: page*size + size, Total: total}
+	if p.End > total {
+		p.End = total
+	}
+	link := func(page uint64) string {
+		u := *req.URL
+		q := u.Query()
+		q.Set("page", strconv.FormatUint(page, 10))
+		u.RawQuery = q.Encode()
+		return u.RequestURI()
+	}
+	if page > 0 {
+		p.Prev = link(page - 1)
+	}
+	if p.End < total {
+		p.Next = link(page + 1)
+	}
+	return p
+}
+
+func (h *uiHandler) render(w http.ResponseWriter, name string, data interface{}) {
+	buf := &bytes.Buffer{}
+	d.PanicIfError(uiTemplates.ExecuteTemplate(buf, name, data))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+type uiDataset struct {
+	ID, Head, Description string
+}
+
+func (h *uiHandler) serveDatasets(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	h.db.Rebase()
+	datasets := []uiDataset{}
+	h.db.Datasets().IterAll(func(k, v types.Value) {
+		r := v.(types.Ref)
+		datasets = append(datasets, uiDataset{string(k.(types.String)), r.TargetHash().String(), describeCommit(h.db, r)})
+	})
+	h.render(w, "datasets", datasets)
+}
+
+// uiEntry is an element of a List or Set, an entry of a Map, or a field of a
+// Struct. Href, if set, links to the page of its value.
+type uiEntry struct {
+	Key, Value, Href string
+}
+
+type uiValue struct {
+	Hash, Path, Kind, Value string
+	Href                    string
+	Entries                 []uiEntry
+	Pager                   uiPager
+}
+
+func (h *uiHandler) serveValue(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	root, ok := hash.MaybeParse(q.Get("hash"))
+	if !ok {
+		http.Error(w, "Invalid hash: "+q.Get("hash"), http.StatusBadRequest)
+		return
+	}
+	p := types.Path{}
+	if ps := q.Get("path"); ps != "" {
+		var err error
+		if p, err = types.ParsePath(ps); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	v := h.db.ReadValue(root)
+	if v != nil {
+		v = p.Resolve(v, h.db)
+	}
+	if v == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	page := uiValue{Hash: root.String(), Path: p.String(), Kind: v.Kind().String()}
+	entry := func(key string, part types.PathPart, ev types.Value) {
+		page.Entries = append(page.Entries, uiEntry{key, types.EncodedValueMaxLines(ev, uiValueLines), uiValueHref(root, p.Append(part), ev)})
+	}
+	switch v := v.(type) {
+	case types.List:
+		page.Pager = h.pager(req, v.Len())
+		it := v.IteratorAt(page.Pager.Start)
+		for i := page.Pager.Start; i < page.Pager.End; i++ {
+			entry(strconv.FormatUint(i, 10), types.NewIndexPath(types.Number(i)), it.Next())
+		}
+	case types.Map:
+		page.Pager = h.pager(req, v.Len())
+		it := v.IteratorAt(page.Pager.Start)
+		for i := page.Pager.Start; i < page.Pager.End; i++ {
+			k, mv := it.Next()
+			entry(types.EncodedValueMaxLines(k, 1), uiIndexPathPart(k), mv)
+		}
+	case types.Set:
+		page.Pager = h.pager(req, v.Len())
+		it := v.IteratorAt(page.Pager.Start)
+		for i := page.Pager.Start; i < page.Pager.End; i++ {
+			ev := it.Next()
+			entry("", uiIndexPathPart(ev), ev)
+		}
+	case types.Struct:
+		page.Pager = h.pager(req, uint64(v.Len()))
+		i := uint64(0)
+		v.IterFields(func(name string, fv types.Value) {
+			if i >= page.Pager.Start && i < page.Pager.End {
+				entry(name, types.NewFieldPath(name), fv)
+			}
+			i++
+		})
+	case types.Blob:
+		// Blobs are shown as a hex dump, a page of lines at a time.
+		page.Pager = h.pager(req, (v.Len()+uiBlobLineBytes-1)/uiBlobLineBytes)
+		r := v.Reader()
+		_, err := r.Seek(int64(page.Pager.Start*uiBlobLineBytes), io.SeekStart)
+		d.PanicIfError(err)
+		data := make([]byte, (page.Pager.End-page.Pager.Start)*uiBlobLineBytes)
+		n, _ := io.ReadFull(r, data)
+		page.Value = hex.Dump(data[:n])
+	case types.Ref:
+		page.Value = types.EncodedValue(v)
+		page.Href = uiValueHref(root, p, v)
+	default:
+		page.Value = types.EncodedValue(v)
+	}
+	h.render(w, "value", page)
+}
+
+// uiValueHref returns the link to the page of |v|, which is at |p| within the
+// value |root|, or "" if it's shown in full where it's listed.
+func uiValueHref(root hash.Hash, p types.Path, v types.Value) string {
+	switch v := v.(type) {
+	case types.Ref:
+		return "/value?" + url.Values{"hash": {v.TargetHash().String()}}.Encode()
+	case types.List, types.Map, types.Set, types.Struct, types.Blob:
+		return "/value?" + url.Values{"hash": {root.String()}, "path": {p.String()}}.Encode()
+	}
+	return ""
+}
+
+func uiIndexPathPart(k types.Value) types.PathPart {
+	if types.ValueCanBePathIndex(k) {
+		return types.NewIndexPath(k)
+	}
+	return types.NewHashIndexPath(k.Hash())
+}
+
+type uiCommit struct {
+	Hash, Description, DiffHref string
+}
+
+type uiLog struct {
+	ID      string
+	Commits []uiCommit
+	Pager   uiPager
+}
+
+func (h *uiHandler) serveLog(w http.ResponseWriter, req *http.Request) {
+	id := req.URL.Query().Get("ds")
+	if !datas.DatasetFullRe.MatchString(id) {
+		http.Error(w, "Invalid dataset: "+id, http.StatusBadRequest)
+		return
+	}
+	h.db.Rebase()
+	head, ok := h.db.GetDataset(id).MaybeHead()
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	// The length of the history isn't known without walking all of it, so
+	// there's a next page if there's a commit after this one.
+	page := uiLog{ID: id}
+	pager := h.pager(req, ^uint64(0))
+	iter := NewCommitIterator(h.db, head)
+	for i := uint64(0); i <= pager.End; i++ {
+		node, ok := iter.Next()
+		if !ok {
+			pager.Next = ""
+			break
+		}
+		if i < pager.Start || i == pager.End {
+			continue
+		}
+		c := uiCommit{Hash: node.cr.TargetHash().String(), Description: describeCommit(h.db, node.cr)}
+		if parents := commitRefsFromSet(node.commit.Get(datas.ParentsField).(types.Set)); len(parents) > 0 {
+			c.DiffHref = "/diff?" + url.Values{"from": {parents[0].TargetHash().String()}, "to": {c.Hash}}.Encode()
+		}
+		page.Commits = append(page.Commits, c)
+	}
+	page.Pager = pager
+	h.render(w, "log", page)
+}
+
+type uiDiff struct {
+	From, To, Diff string
+	Truncated      bool
+}
+
+func (h *uiHandler) serveDiff(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	values := make([]types.Value, 2)
+	for i, param := range []string{"from", "to"} {
+		hv, ok := hash.MaybeParse(q.Get(param))
+		if !ok {
+			http.Error(w, "Invalid hash: "+q.Get(param), http.StatusBadRequest)
+			return
+		}
+		if values[i] = h.db.ReadValue(hv); values[i] == nil {
+			http.NotFound(w, req)
+			return
+		}
+		// Commits are diffed by their values.
+		if datas.IsCommit(values[i]) {
+			values[i] = values[i].(types.Struct).Get(datas.ValueField)
+		}
+	}
+
+	page := uiDiff{From: q.Get("from"), To: q.Get("to")}
+	buf := &bytes.Buffer{}
+	err := diff.PrintDiff(&writers.MaxLineWriter{Dest: buf, MaxLines: uiDiffLines}, values[0], values[1], false)
+	page.Truncated = err == writers.MaxLinesErr
+	page.Diff = buf.String()
+	h.render(w, "diff", page)
+}
+
+var uiTemplates = template.Must(template.New("").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Noms</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+pre { margin: 0; }
+table { border-collapse: collapse; }
+td, th { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+</style></head><body>
+<p><a href="/">Datasets</a></p>
+{{end}}
+
+{{define "footer"}}</body></html>{{end}}
+
+{{define "pager"}}{{if .Total}}<p>{{if .Prev}}<a href="{{.Prev}}">&larr; Previous</a> {{end}}{{if .Next}}<a href="{{.Next}}">Next &rarr;</a>{{end}}</p>{{end}}{{end}}
+
+{{define "datasets"}}{{template "header"}}
+<h1>Datasets</h1>
+{{if .}}<table>
+<tr><th>Dataset</th><th>Head</th><th></th></tr>
+{{range .}}<tr><td><a href="/value?hash={{.Head}}&amp;path=.value">{{.ID}}</a></td><td>{{.Description}}</td><td><a href="/log?ds={{.ID}}">History</a></td></tr>
+{{end}}</table>{{else}}<p>No datasets</p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "value"}}{{template "header"}}
+<h1>#{{.Hash}}{{.Path}}</h1>
+<p>{{.Kind}}{{if .Pager.Total}}, {{.Pager.Start}}-{{.Pager.End}} of {{.Pager.Total}}{{end}}</p>
+{{if .Href}}<p><a href="{{.Href}}">Target</a></p>{{end}}
+{{if .Value}}<pre>{{.Value}}</pre>{{end}}
+{{if .Entries}}<table>
+{{range .Entries}}<tr>{{if .Key}}<td><pre>{{.Key}}</pre></td>{{end}}<td><pre>{{.Value}}</pre></td><td>{{if .Href}}<a href="{{.Href}}">Open</a>{{end}}</td></tr>
+{{end}}</table>{{end}}
+{{template "pager" .Pager}}
+{{template "footer"}}{{end}}
+
+{{define "log"}}{{template "header"}}
+<h1>History of {{.ID}}</h1>
+<table>
+{{range .Commits}}<tr><td><a href="/value?hash={{.Hash}}">{{.Description}}</a></td><td><a href="/value?hash={{.Hash}}&amp;path=.value">Value</a></td><td>{{if .DiffHref}}<a href="{{.DiffHref}}">Diff</a>{{end}}</td></tr>
+{{end}}</table>
+{{template "pager" .Pager}}
+{{template "footer"}}{{end}}
+
+{{define "diff"}}{{template "header"}}
+<h1>Diff from #{{.From}} to #{{.To}}</h1>
+<pre>{{.Diff}}</pre>
+{{if .Truncated}}<p>The diff is truncated.</p>{{end}}
+{{template "footer"}}{{end}}
+`))
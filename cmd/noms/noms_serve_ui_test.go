@@ -0,0 +1,95 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestServeUI(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("team/people"), types.NewMap(types.String("alice"), types.Number(1)))
+	assert.NoError(err)
+	first := ds.HeadRef()
+	vs := []types.Value{}
+	for i := 0; i < 25; i++ {
+		vs = append(vs, types.String("elem"), types.Number(i))
+	}
+	v := types.NewStruct("Person", types.StructData{
+		"list": types.NewList(vs...),
+		"ref":  db.WriteValue(types.String("referenced")),
+	})
+	ds, err = db.CommitValue(ds, types.NewMap(types.String("alice"), v))
+	assert.NoError(err)
+	head := ds.HeadRef().TargetHash().String()
+
+	h := newUIHandler(db, 20)
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code, w.Body.String()
+	}
+	value := func(path, page string) string {
+		return "/value?" + url.Values{"hash": {head}, "path": {path}, "page": {page}}.Encode()
+	}
+
+	code, body := get("/")
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, "team/people")
+	assert.Contains(body, "/log?ds=team%2fpeople")
+
+	// Collections are shown a page at a time, with links into their entries.
+	code, body = get(value(`.value["alice"].list`, "0"))
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, "List, 0-20 of 50")
+	assert.Contains(body, `&#34;elem&#34;`)
+	assert.NotContains(body, "Previous")
+	assert.Contains(body, "Next")
+	_, body = get(value(`.value["alice"].list`, "2"))
+	assert.Contains(body, "List, 40-50 of 50")
+	assert.Contains(body, "Previous")
+	assert.NotContains(body, "Next")
+
+	_, body = get(value(`.value["alice"]`, ""))
+	assert.Contains(body, "Struct, 0-2 of 2")
+	assert.Contains(body, "path="+url.QueryEscape(`.value["alice"].list`))
+
+	code, body = get("/log?ds=team/people")
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, head)
+	assert.Contains(body, first.TargetHash().String())
+	assert.Contains(body, "/diff?from="+first.TargetHash().String())
+
+	code, body = get("/diff?" + url.Values{"from": {first.TargetHash().String()}, "to": {head}}.Encode())
+	assert.Equal(http.StatusOK, code)
+	assert.Contains(body, "-   &#34;alice&#34;: 1")
+
+	for path, status := range map[string]int{
+		"/value?hash=bad":     http.StatusBadRequest,
+		value(".missing", ""): http.StatusNotFound,
+		"/log?ds=missing":     http.StatusNotFound,
+		"/diff?from=" + head:  http.StatusBadRequest,
+		"/elsewhere":          http.StatusNotFound,
+		value(".value[", ""):  http.StatusBadRequest,
+	} {
+		code, _ := get(path)
+		assert.Equal(status, code, path)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Code)
+}
@@ -57,7 +57,7 @@ func runShow(args []string) int {
 		return 0
 	}
 
-	pgr := outputpager.Start()
+	pgr := startPager(cfg)
 	defer pgr.Stop()
 
 	types.WriteEncodedValue(pgr.Writer, value)
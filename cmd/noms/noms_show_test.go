@@ -58,6 +58,11 @@ func (s *nomsShowTestSuite) TestNomsShow() {
 	res, _ = s.MustRun(main, []string{"show", str1})
 	s.Equal(res2, res)
 
+	// Hashes may be abbreviated.
+	str1 = spec.CreateValueSpecString("nbs", s.DBDir, "#"+r.TargetHash().String()[:10])
+	res, _ = s.MustRun(main, []string{"show", str1})
+	s.Equal(res2, res)
+
 	list := types.NewList(types.String("elem1"), types.Number(2), types.String("elem3"))
 	r = s.writeTestData(str, list)
 	res, _ = s.MustRun(main, []string{"show", str})
@@ -0,0 +1,75 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// AbsentCache remembers which chunks a ChunkStore has said it doesn't have,
+// so that asking again about them can be skipped. Chunks can only appear in
+// a store when its root changes, so each answer is recorded along with the
+// root it was given at, and is forgotten as soon as the cache is used with a
+// different root. The cache is also cleared whenever it grows beyond a
+// maximum size, so that it holds only fairly recent answers.
+type AbsentCache struct {
+	mu      sync.Mutex
+	root    hash.Hash
+	absent  hash.HashSet
+	maxSize int
+}
+
+// NewAbsentCache returns an AbsentCache that holds at most |maxSize| hashes.
+func NewAbsentCache(maxSize int) *AbsentCache {
+	return &AbsentCache{absent: hash.HashSet{}, maxSize: maxSize}
+}
+
+// Absent returns those of |hashes| that are known to be absent from the store
+// as of |root|.
+func (ac *AbsentCache) Absent(root hash.Hash, hashes hash.HashSet) hash.HashSet {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.setRoot(root)
+	absent := hash.HashSet{}
+	for h := range hashes {
+		if ac.absent.Has(h) {
+			absent.Insert(h)
+		}
+	}
+	return absent
+}
+
+// Insert records that |hashes| were absent from the store as of |root|.
+func (ac *AbsentCache) Insert(root hash.Hash, hashes hash.HashSet) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.setRoot(root)
+	if len(ac.absent)+len(hashes) > ac.maxSize {
+		ac.absent = hash.HashSet{}
+		if len(hashes) > ac.maxSize {
+			return
+		}
+	}
+	for h := range hashes {
+		ac.absent.Insert(h)
+	}
+}
+
+// Remove forgets that |h| is absent, e.g. because it's about to be written to
+// the store.
+func (ac *AbsentCache) Remove(h hash.Hash) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.absent.Remove(h)
+}
+
+func (ac *AbsentCache) setRoot(root hash.Hash) {
+	if root != ac.root {
+		ac.root = root
+		ac.absent = hash.HashSet{}
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestAbsentCache(t *testing.T) {
+	assert := assert.New(t)
+	a, b, c := hash.Of([]byte("a")), hash.Of([]byte("b")), hash.Of([]byte("c"))
+	root1, root2 := hash.Of([]byte("root1")), hash.Of([]byte("root2"))
+	all := hash.NewHashSet(a, b, c)
+
+	ac := NewAbsentCache(3)
+	assert.Empty(ac.Absent(root1, all))
+
+	ac.Insert(root1, hash.NewHashSet(a, b))
+	assert.Equal(hash.NewHashSet(a, b), ac.Absent(root1, all))
+	assert.Equal(hash.NewHashSet(b), ac.Absent(root1, hash.NewHashSet(b, c)))
+
+	ac.Remove(a)
+	assert.Equal(hash.NewHashSet(b), ac.Absent(root1, all))
+
+	// A new root invalidates everything.
+	assert.Empty(ac.Absent(root2, all))
+	assert.Empty(ac.Absent(root1, all))
+
+	// Growing beyond the maximum size starts afresh.
+	ac.Insert(root1, hash.NewHashSet(a, b))
+	ac.Insert(root1, hash.NewHashSet(c))
+	assert.Equal(all, ac.Absent(root1, all))
+	ac.Insert(root1, hash.NewHashSet(hash.Of([]byte("d"))))
+	assert.Empty(ac.Absent(root1, all))
+}
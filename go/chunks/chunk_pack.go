@@ -0,0 +1,205 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+/*
+  Chunk Pack:
+    Chunk Data 0
+    Chunk Data 1
+     ..
+    Chunk Data N-1
+    Terminator  // 1-byte 0, since no chunk is empty
+    Index
+    Footer
+
+  Chunk Data:
+    Len   // uvarint
+    Data  // len(Data) == Len
+
+  Index:
+    Hash 0
+     ..
+    Hash N-1  // 20-byte hashes, in the same order as the Chunk Data
+
+  Footer:
+    Count  // 4-byte int, N
+    Magic  // packMagic
+
+  Unlike the format written by Serialize, chunk data is framed by a varint
+  length alone, and the hashes are gathered into a trailing index, which costs
+  little for the small chunks that dominate most datasets and keeps the
+  compressible chunk data together. Packs are compressed, if at all, by
+  whatever carries them, e.g. the Content-Encoding of an HTTP body.
+*/
+
+const packMagic = "NPK1"
+
+// ErrMalformedPack is returned when reading a chunk pack that is truncated
+// or otherwise doesn't match the format written by PackWriter.
+var ErrMalformedPack = errors.New("Malformed chunk pack")
+
+// PackWriter writes Chunks to an io.Writer in the chunk pack format. Chunk
+// data is written as it's added, and the index once the writer is closed.
+type PackWriter struct {
+	w      io.Writer
+	hashes []hash.Hash
+	buf    [binary.MaxVarintLen64]byte
+}
+
+// NewPackWriter returns a PackWriter that writes a pack to |w|.
+func NewPackWriter(w io.Writer) *PackWriter {
+	return &PackWriter{w: w}
+}
+
+// Write adds |chunk| to the pack.
+func (pw *PackWriter) Write(chunk Chunk) {
+	d.PanicIfTrue(chunk.IsEmpty())
+	// Because of chunking at higher levels, no chunk should ever be more than 4GB
+	d.PanicIfTrue(uint64(len(chunk.Data())) > math.MaxUint32)
+
+	n := binary.PutUvarint(pw.buf[:], uint64(len(chunk.Data())))
+	_, err := pw.w.Write(pw.buf[:n])
+	d.Chk.NoError(err)
+	_, err = pw.w.Write(chunk.Data())
+	d.Chk.NoError(err)
+	pw.hashes = append(pw.hashes, chunk.Hash())
+}
+
+// Close writes the index and footer of the pack. It doesn't close the
+// underlying io.Writer.
+func (pw *PackWriter) Close() error {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(0)
+	for _, h := range pw.hashes {
+		buf.Write(h[:])
+	}
+	binary.Write(buf, binary.BigEndian, uint32(len(pw.hashes)))
+	buf.WriteString(packMagic)
+	_, err := io.Copy(pw.w, buf)
+	return err
+}
+
+// DeserializePack reads a chunk pack from |reader|, sending its chunks to
+// chunkChan in the order they were written. Since the address of each chunk
+// is computed from its data, chunks are sent as they are read; once the
+// index has been read, a *HashMismatchError is returned for the first chunk
+// whose address differs from the one in the index.
+func DeserializePack(reader io.Reader, chunkChan chan<- *Chunk) error {
+	var hashes []hash.Hash
+	br := byteReader(reader)
+	err := readPackData(br, func(data []byte) {
+		c := NewChunk(data)
+		hashes = append(hashes, c.Hash())
+		chunkChan <- &c
+	})
+	if err != nil {
+		return err
+	}
+	index, err := readPackIndex(br, len(hashes))
+	if err != nil {
+		return err
+	}
+	for i, h := range index {
+		if h != hashes[i] {
+			return &HashMismatchError{h, hashes[i]}
+		}
+	}
+	return nil
+}
+
+// DeserializePackUnverified is like DeserializePack, but trusts the addresses
+// in the index instead of hashing chunk data. Since the index comes last, no
+// chunk is sent until the whole pack has been read. Only use it to read from
+// trusted sources.
+func DeserializePackUnverified(reader io.Reader, chunkChan chan<- *Chunk) error {
+	var datas [][]byte
+	br := byteReader(reader)
+	err := readPackData(br, func(data []byte) {
+		datas = append(datas, data)
+	})
+	if err != nil {
+		return err
+	}
+	index, err := readPackIndex(br, len(datas))
+	if err != nil {
+		return err
+	}
+	for i, h := range index {
+		c := NewChunkWithHash(h, datas[i])
+		chunkChan <- &c
+	}
+	return nil
+}
+
+type packReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func byteReader(reader io.Reader) packReader {
+	if br, ok := reader.(packReader); ok {
+		return br
+	}
+	return bufio.NewReader(reader)
+}
+
+func readPackData(reader packReader, cb func(data []byte)) error {
+	for {
+		l, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return packError(err)
+		}
+		if l == 0 {
+			return nil
+		}
+		if l > math.MaxUint32 {
+			return ErrMalformedPack
+		}
+		data := make([]byte, int(l))
+		if _, err = io.ReadFull(reader, data); err != nil {
+			return packError(err)
+		}
+		cb(data)
+	}
+}
+
+func readPackIndex(reader io.Reader, count int) ([]hash.Hash, error) {
+	index := make([]hash.Hash, count)
+	for i := range index {
+		if _, err := io.ReadFull(reader, index[i][:]); err != nil {
+			return nil, packError(err)
+		}
+	}
+
+	footer := make([]byte, 4+len(packMagic))
+	if _, err := io.ReadFull(reader, footer); err != nil {
+		return nil, packError(err)
+	}
+	if binary.BigEndian.Uint32(footer) != uint32(count) || string(footer[4:]) != packMagic {
+		return nil, ErrMalformedPack
+	}
+	return index, nil
+}
+
+// packError reports a pack that ends early as malformed, rather than as EOF,
+// since a pack always ends with its footer.
+func packError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrMalformedPack
+	}
+	return err
+}
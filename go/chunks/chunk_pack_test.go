@@ -0,0 +1,90 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func writePack(chnx ...Chunk) []byte {
+	buf := &bytes.Buffer{}
+	pw := NewPackWriter(buf)
+	for _, c := range chnx {
+		pw.Write(c)
+	}
+	pw.Close()
+	return buf.Bytes()
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	chnx := []Chunk{NewChunk(bytes.Repeat([]byte("abc"), 100))}
+	for i := 0; i < 10; i++ {
+		chnx = append(chnx, NewChunk([]byte{byte(i)}))
+	}
+	pack := writePack(chnx...)
+
+	// Small chunks take less space in a pack than when serialized one by one.
+	buf := &bytes.Buffer{}
+	for _, c := range chnx {
+		Serialize(c, buf)
+	}
+	assert.True(len(pack) < buf.Len())
+
+	for _, deserialize := range []func([]byte, chan<- *Chunk) error{
+		func(b []byte, ch chan<- *Chunk) error { return DeserializePack(bytes.NewReader(b), ch) },
+		func(b []byte, ch chan<- *Chunk) error { return DeserializePackUnverified(bytes.NewReader(b), ch) },
+	} {
+		ch := make(chan *Chunk, len(chnx))
+		assert.NoError(deserialize(pack, ch))
+		close(ch)
+		i := 0
+		for c := range ch {
+			assert.Equal(chnx[i].Hash(), c.Hash())
+			assert.Equal(chnx[i].Data(), c.Data())
+			i++
+		}
+		assert.Equal(len(chnx), i)
+	}
+
+	ch := make(chan *Chunk)
+	assert.NoError(DeserializePack(bytes.NewReader(writePack()), ch))
+}
+
+func TestBadPack(t *testing.T) {
+	assert := assert.New(t)
+	pack := writePack(NewChunk([]byte("abc")), NewChunk([]byte("def")))
+
+	for _, bad := range [][]byte{
+		{},
+		pack[:len(pack)-1],
+		pack[:5],
+		append(append([]byte{}, pack[:len(pack)-1]...), 'X'),
+	} {
+		ch := make(chan *Chunk, 2)
+		assert.Equal(ErrMalformedPack, DeserializePack(bytes.NewReader(bad), ch))
+		assert.Equal(ErrMalformedPack, DeserializePackUnverified(bytes.NewReader(bad), ch))
+	}
+}
+
+func TestDeserializePackHashMismatch(t *testing.T) {
+	assert := assert.New(t)
+	c := NewChunk([]byte("abc"))
+	tampered := writePack(c)
+	tampered[3] = 'd'
+
+	ch := make(chan *Chunk, 1)
+	err := DeserializePack(bytes.NewReader(tampered), ch)
+	assert.Equal(&HashMismatchError{c.Hash(), NewChunk([]byte("abd")).Hash()}, err)
+	assert.Equal(NewChunk([]byte("abd")).Hash(), (<-ch).Hash())
+
+	assert.NoError(DeserializePackUnverified(bytes.NewReader(tampered), ch))
+	unverified := <-ch
+	assert.Equal(c.Hash(), unverified.Hash())
+	assert.Equal("abd", string(unverified.Data()))
+}
@@ -7,6 +7,7 @@ package chunks
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 
 	"github.com/attic-labs/noms/go/d"
@@ -45,13 +46,35 @@ func Serialize(chunk Chunk, writer io.Writer) {
 	d.PanicIfFalse(uint32(n) == chunkSize)
 }
 
+// HashMismatchError is returned by Deserialize when the data of a serialized
+// chunk doesn't hash to the address it was serialized with.
+type HashMismatchError struct {
+	Hash, Actual hash.Hash
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("Chunk data hashes to %s, not %s", e.Actual, e.Hash)
+}
+
 // Deserialize reads off of |reader| until EOF, sending chunks to
 // chunkChan in the order they are read. Objects sent over chunkChan are
-// *Chunk.
+// *Chunk. It stops with a *HashMismatchError at the first chunk whose data
+// doesn't hash to the address it was serialized with.
 func Deserialize(reader io.Reader, chunkChan chan<- *Chunk) (err error) {
+	return deserialize(reader, chunkChan, true)
+}
+
+// DeserializeUnverified is like Deserialize, but trusts the addresses chunks
+// were serialized with instead of hashing their data. Only use it to read
+// from trusted sources.
+func DeserializeUnverified(reader io.Reader, chunkChan chan<- *Chunk) (err error) {
+	return deserialize(reader, chunkChan, false)
+}
+
+func deserialize(reader io.Reader, chunkChan chan<- *Chunk, verify bool) (err error) {
 	for {
 		var c Chunk
-		c, err = deserializeChunk(reader)
+		c, err = deserializeChunk(reader, verify)
 		if err != nil {
 			break
 		}
@@ -64,7 +87,7 @@ func Deserialize(reader io.Reader, chunkChan chan<- *Chunk) (err error) {
 	return
 }
 
-func deserializeChunk(reader io.Reader) (Chunk, error) {
+func deserializeChunk(reader io.Reader, verify bool) (Chunk, error) {
 	h := hash.Hash{}
 	n, err := io.ReadFull(reader, h[:])
 	if err != nil {
@@ -82,9 +105,12 @@ func deserializeChunk(reader io.Reader) (Chunk, error) {
 		return EmptyChunk, err
 	}
 	d.PanicIfFalse(int(chunkSize) == n)
+	if !verify {
+		return NewChunkWithHash(h, data), nil
+	}
 	c := NewChunk(data)
 	if h != c.Hash() {
-		d.Panic("%s != %s", h, c.Hash().String())
+		return EmptyChunk, &HashMismatchError{h, c.Hash()}
 	}
 	return c, nil
 }
@@ -43,3 +43,22 @@ func TestBadSerialization(t *testing.T) {
 	defer close(ch)
 	assert.Error(t, Deserialize(bytes.NewReader(bad), ch))
 }
+
+func TestDeserializeHashMismatch(t *testing.T) {
+	assert := assert.New(t)
+	c := NewChunk([]byte("abc"))
+	buf := &bytes.Buffer{}
+	Serialize(c, buf)
+	tampered := buf.Bytes()
+	tampered[len(tampered)-1] = 'd'
+
+	ch := make(chan *Chunk, 1)
+	err := Deserialize(bytes.NewReader(tampered), ch)
+	assert.Equal(&HashMismatchError{c.Hash(), NewChunk([]byte("abd")).Hash()}, err)
+	assert.Len(ch, 0)
+
+	assert.NoError(DeserializeUnverified(bytes.NewReader(tampered), ch))
+	unverified := <-ch
+	assert.Equal(c.Hash(), unverified.Hash())
+	assert.Equal("abd", string(unverified.Data()))
+}
@@ -57,6 +57,30 @@ type ChunkStore interface {
 	io.Closer
 }
 
+// HashPrefixLister is implemented by ChunkStores which can enumerate the
+// chunks whose hashes begin with a given prefix. It is used to resolve
+// abbreviated hashes typed by users, so implementations need not be fast
+// for very short prefixes.
+type HashPrefixLister interface {
+	// HashesWithPrefix returns the hashes of all chunks visible to this
+	// ChunkStore whose String() begins with prefix. prefix must be
+	// well formed according to hash.MaybeParsePrefix.
+	HashesWithPrefix(prefix string) hash.HashSet
+}
+
+// RootContentionNotifier is implemented by ChunkStores which can report
+// Commits that fail because another writer moved the root first. Higher
+// layers use it to observe contention, e.g. for telemetry, or to decide how
+// to retry or merge.
+type RootContentionNotifier interface {
+	// SubscribeRootContention arranges for f to be called with a
+	// RootContention each time Commit fails because the persisted root isn't
+	// the one it expected, until the returned function is called. f is called
+	// by the goroutine calling Commit, before Commit returns, so it can be
+	// called concurrently and mustn't call Commit itself.
+	SubscribeRootContention(f func(RootContention)) (unsubscribe func())
+}
+
 // Factory allows the creation of namespaced ChunkStore instances. The details
 // of how namespaces are separated is left up to the particular implementation
 // of Factory and ChunkStore.
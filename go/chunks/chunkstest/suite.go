@@ -0,0 +1,340 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package chunkstest provides a test suite that checks a chunks.ChunkStore
+// implementation for conformance with the semantics Noms relies upon. To use
+// it, embed Suite in a testify suite and set Factory before each test:
+//
+//   type MyStoreSuite struct {
+//   	chunkstest.Suite
+//   }
+//
+//   func (s *MyStoreSuite) SetupTest() {
+//   	s.Factory = newMyStoreFactory()
+//   }
+//
+//   func (s *MyStoreSuite) TearDownTest() {
+//   	s.Factory.Shutter()
+//   }
+//
+//   func TestMyStore(t *testing.T) {
+//   	suite.Run(t, &MyStoreSuite{})
+//   }
+//
+// Stores created by Factory for the same namespace must share persistent
+// storage, and stores created for different namespaces must not.
+package chunkstest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+// Suite is a testify suite of ChunkStore conformance tests.
+type Suite struct {
+	suite.Suite
+	Factory chunks.Factory
+}
+
+// AssertInputInStore asserts that the chunk with hash h can be read from s and
+// contains input.
+func AssertInputInStore(input string, h hash.Hash, s chunks.ChunkStore, assert *assert.Assertions) {
+	chunk := s.Get(h)
+	assert.False(chunk.IsEmpty(), "Shouldn't get empty chunk for %s", h.String())
+	assert.Equal(input, string(chunk.Data()))
+}
+
+// AssertInputNotInStore asserts that the chunk with hash h cannot be read from
+// s.
+func AssertInputNotInStore(input string, h hash.Hash, s chunks.ChunkStore, assert *assert.Assertions) {
+	chunk := s.Get(h)
+	assert.True(chunk.IsEmpty(), "Shouldn't get non-empty chunk for %s: %v", h.String(), chunk)
+}
+
+func (suite *Suite) TestChunkStorePut() {
+	store := suite.Factory.CreateStore("ns")
+	input := "abc"
+	c := chunks.NewChunk([]byte(input))
+	store.Put(c)
+	h := c.Hash()
+
+	// Reading it via the API should work.
+	AssertInputInStore(input, h, store, suite.Assert())
+	suite.True(store.Has(h))
+}
+
+func (suite *Suite) TestChunkStoreRoot() {
+	store := suite.Factory.CreateStore("ns")
+	oldRoot := store.Root()
+	suite.True(oldRoot.IsEmpty())
+
+	bogusRoot := hash.Parse("8habda5skfek1265pc5d5l1orptn5dr0")
+	newRoot := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+
+	// Try to update root with bogus oldRoot
+	result := store.Commit(newRoot, bogusRoot)
+	suite.False(result)
+
+	// Now do a valid root update
+	result = store.Commit(newRoot, oldRoot)
+	suite.True(result)
+	suite.Equal(newRoot, store.Root())
+}
+
+func (suite *Suite) TestChunkStoreCommitPut() {
+	name := "ns"
+	store := suite.Factory.CreateStore(name)
+	input := "abc"
+	c := chunks.NewChunk([]byte(input))
+	store.Put(c)
+	h := c.Hash()
+
+	// Reading it via the API should work...
+	AssertInputInStore(input, h, store, suite.Assert())
+	// ...but it shouldn't be persisted yet
+	AssertInputNotInStore(input, h, suite.Factory.CreateStore(name), suite.Assert())
+
+	store.Commit(h, store.Root()) // Commit persists Chunks
+	AssertInputInStore(input, h, store, suite.Assert())
+	AssertInputInStore(input, h, suite.Factory.CreateStore(name), suite.Assert())
+}
+
+func (suite *Suite) TestChunkStoreGetNonExisting() {
+	store := suite.Factory.CreateStore("ns")
+	h := hash.Parse("11111111111111111111111111111111")
+	c := store.Get(h)
+	suite.True(c.IsEmpty())
+	suite.False(store.Has(h))
+}
+
+func (suite *Suite) TestChunkStoreGetManyHasMany() {
+	store := suite.Factory.CreateStore("ns")
+	inputs := []string{"abc", "def", "ghi"}
+	present := hash.HashSet{}
+	for _, input := range inputs {
+		c := chunks.NewChunk([]byte(input))
+		store.Put(c)
+		present.Insert(c.Hash())
+	}
+	absent := hash.Parse("11111111111111111111111111111111")
+	query := hash.HashSet{absent: struct{}{}}
+	for h := range present {
+		query.Insert(h)
+	}
+
+	suite.Equal(present, store.HasMany(query))
+
+	found := make(chan *chunks.Chunk, len(query))
+	store.GetMany(query, found)
+	close(found)
+	got := map[hash.Hash]string{}
+	for c := range found {
+		got[c.Hash()] = string(c.Data())
+	}
+	suite.Len(got, len(inputs))
+	for _, input := range inputs {
+		suite.Equal(input, got[chunks.NewChunk([]byte(input)).Hash()])
+	}
+}
+
+func (suite *Suite) TestChunkStoreVersion() {
+	store := suite.Factory.CreateStore("ns")
+	oldRoot := store.Root()
+	suite.True(oldRoot.IsEmpty())
+	newRoot := hash.Parse("11111222223333344444555556666677")
+	suite.True(store.Commit(newRoot, oldRoot))
+
+	suite.Equal(constants.NomsVersion, store.Version())
+}
+
+func (suite *Suite) TestChunkStoreCommitUnchangedRoot() {
+	store1, store2 := suite.Factory.CreateStore("ns"), suite.Factory.CreateStore("ns")
+	input := "abc"
+	c := chunks.NewChunk([]byte(input))
+	store1.Put(c)
+	h := c.Hash()
+
+	// Reading c from store1 via the API should work...
+	AssertInputInStore(input, h, store1, suite.Assert())
+	// ...but not store2.
+	AssertInputNotInStore(input, h, store2, suite.Assert())
+
+	store1.Commit(store1.Root(), store1.Root())
+	store2.Rebase()
+	// Now, reading c from store2 via the API should work...
+	AssertInputInStore(input, h, store2, suite.Assert())
+}
+
+func (suite *Suite) TestChunkStoreRootIsStableUntilRebase() {
+	store1, store2 := suite.Factory.CreateStore("ns"), suite.Factory.CreateStore("ns")
+	oldRoot := store2.Root()
+	newRoot := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+	suite.True(store1.Commit(newRoot, store1.Root()))
+
+	suite.Equal(oldRoot, store2.Root())
+	store2.Rebase()
+	suite.Equal(newRoot, store2.Root())
+}
+
+func (suite *Suite) TestChunkStoreCommitFencing() {
+	store1, store2 := suite.Factory.CreateStore("ns"), suite.Factory.CreateStore("ns")
+	root1 := hash.Parse("8habda5skfek1265pc5d5l1orptn5dr0")
+	root2 := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+
+	c := chunks.NewChunk([]byte("abc"))
+	store2.Put(c)
+
+	suite.True(store1.Commit(root1, store1.Root()))
+
+	// store2 tries to move the root from the value it last saw, which is no
+	// longer current.
+	suite.False(store2.Commit(root2, hash.Hash{}))
+	store2.Rebase()
+	suite.Equal(root1, store2.Root())
+
+	// Chunks Put before a failed Commit are still pending and are persisted
+	// by the next successful one.
+	suite.True(store2.Commit(root2, root1))
+	store3 := suite.Factory.CreateStore("ns")
+	suite.Equal(root2, store3.Root())
+	AssertInputInStore("abc", c.Hash(), store3, suite.Assert())
+}
+
+func (suite *Suite) TestChunkStoreConcurrentCommits() {
+	const n = 8
+	stores := make([]chunks.ChunkStore, n)
+	for i := range stores {
+		stores[i] = suite.Factory.CreateStore("ns")
+	}
+	start := stores[0].Root()
+
+	wins := make([]bool, n)
+	wg := sync.WaitGroup{}
+	for i, store := range stores {
+		wg.Add(1)
+		go func(i int, store chunks.ChunkStore) {
+			defer wg.Done()
+			c := chunks.NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+			store.Put(c)
+			wins[i] = store.Commit(c.Hash(), start)
+		}(i, store)
+	}
+	wg.Wait()
+
+	// Exactly one of the competing Commits from the same root wins.
+	winner := -1
+	for i, won := range wins {
+		if won {
+			suite.Equal(-1, winner, "stores %d and %d both committed", winner, i)
+			winner = i
+		}
+	}
+	suite.NotEqual(-1, winner)
+
+	check := suite.Factory.CreateStore("ns")
+	winnerChunk := chunks.NewChunk([]byte(fmt.Sprintf("chunk %d", winner)))
+	suite.Equal(winnerChunk.Hash(), check.Root())
+	AssertInputInStore(string(winnerChunk.Data()), winnerChunk.Hash(), check, suite.Assert())
+}
+
+func (suite *Suite) TestChunkStoreConcurrentPutAndGet() {
+	store := suite.Factory.CreateStore("ns")
+	const n = 64
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprintf("chunk %d", i)
+			c := chunks.NewChunk([]byte(input))
+			store.Put(c)
+			AssertInputInStore(input, c.Hash(), store, suite.Assert())
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (suite *Suite) TestChunkStoreNamespacesAreIsolated() {
+	store1, store2 := suite.Factory.CreateStore("ns1"), suite.Factory.CreateStore("ns2")
+	c := chunks.NewChunk([]byte("abc"))
+	store1.Put(c)
+	root := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+	suite.True(store1.Commit(root, store1.Root()))
+
+	store2.Rebase()
+	suite.True(store2.Root().IsEmpty())
+	AssertInputNotInStore("abc", c.Hash(), store2, suite.Assert())
+}
+
+func (suite *Suite) TestChunkStoreHashesWithPrefix() {
+	store := suite.Factory.CreateStore("ns")
+	lister, ok := store.(chunks.HashPrefixLister)
+	if !ok {
+		suite.T().Skip("ChunkStore does not implement HashPrefixLister")
+	}
+
+	all := hash.HashSet{}
+	put := func(from, to int) {
+		for i := from; i < to; i++ {
+			c := chunks.NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+			store.Put(c)
+			all.Insert(c.Hash())
+		}
+	}
+	// Some of the chunks are persisted, the rest are only pending.
+	put(0, 100)
+	suite.True(store.Commit(store.Root(), store.Root()))
+	put(100, 150)
+
+	for h := range all {
+		for _, n := range []int{1, 2, 13, hash.StringLen} {
+			prefix := h.String()[:n]
+			expected := hash.HashSet{}
+			for other := range all {
+				if other.HasPrefix(prefix) {
+					expected.Insert(other)
+				}
+			}
+			suite.Equal(expected, lister.HashesWithPrefix(prefix), "prefix %s", prefix)
+		}
+	}
+	suite.Empty(lister.HashesWithPrefix("vvvvvvvvvvvv"))
+}
+
+func (suite *Suite) TestChunkStoreRootContention() {
+	store1, store2 := suite.Factory.CreateStore("ns"), suite.Factory.CreateStore("ns")
+	notifier, ok := store2.(chunks.RootContentionNotifier)
+	if !ok {
+		suite.T().Skip("ChunkStore does not implement RootContentionNotifier")
+	}
+	root1 := hash.Parse("8habda5skfek1265pc5d5l1orptn5dr0")
+	root2 := hash.Parse("8la6qjbh81v85r6q67lqbfrkmpds14lg")
+
+	var mu sync.Mutex
+	contentions := []chunks.RootContention{}
+	unsubscribe := notifier.SubscribeRootContention(func(rc chunks.RootContention) {
+		mu.Lock()
+		defer mu.Unlock()
+		contentions = append(contentions, rc)
+	})
+
+	store2.Put(chunks.NewChunk([]byte("abc")))
+	suite.True(store1.Commit(root1, store1.Root()))
+	suite.False(store2.Commit(root2, hash.Hash{}))
+	suite.Equal([]chunks.RootContention{{Last: hash.Hash{}, Attempted: root2, Winner: root1}}, contentions)
+
+	// Successful Commits aren't reported.
+	suite.True(store2.Commit(root2, root1))
+	suite.Len(contentions, 1)
+
+	unsubscribe()
+	suite.False(store2.Commit(root1, hash.Hash{}))
+	suite.Len(contentions, 1)
+}
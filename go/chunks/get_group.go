@@ -0,0 +1,73 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
+)
+
+// coalescedGets counts the Gets, across all GetGroups, which waited for a Get
+// already in flight rather than making a request of their own. It's only
+// counted when metrics.Enabled().
+var coalescedGets = metrics.RegisterCounter("chunks.CoalescedGets")
+
+// GetGroup coalesces concurrent Gets of the same chunk, so that while a Get
+// of a hash is in flight, further Gets of it wait for its result rather than
+// making requests of their own. This keeps many goroutines that walk the same
+// part of a graph from each fetching the same chunks from a remote store. The
+// zero value is ready to use.
+type GetGroup struct {
+	mu    sync.Mutex
+	calls map[hash.Hash]*getCall
+}
+
+type getCall struct {
+	wg  sync.WaitGroup
+	c   Chunk
+	err interface{}
+}
+
+// Get returns get(h), unless a Get of h is already in flight, in which case
+// it waits for that Get and returns its result. If get panics, every caller
+// waiting for it panics with the same value.
+func (g *GetGroup) Get(h hash.Hash, get func(h hash.Hash) Chunk) Chunk {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[hash.Hash]*getCall{}
+	}
+	if call, ok := g.calls[h]; ok {
+		g.mu.Unlock()
+		if metrics.Enabled() {
+			coalescedGets.Inc()
+		}
+		call.wg.Wait()
+		if call.err != nil {
+			panic(call.err)
+		}
+		return call.c
+	}
+	call := &getCall{}
+	call.wg.Add(1)
+	g.calls[h] = call
+	g.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			call.err = r
+		}
+		g.mu.Lock()
+		delete(g.calls, h)
+		g.mu.Unlock()
+		call.wg.Done()
+		if call.err != nil {
+			panic(call.err)
+		}
+	}()
+	call.c = get(h)
+	return call.c
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGetGroupCoalesces(t *testing.T) {
+	assert := assert.New(t)
+	metrics.SetEnabled(true)
+	defer metrics.SetEnabled(false)
+	c := NewChunk([]byte("abc"))
+
+	g := &GetGroup{}
+	var calls int32
+	release := make(chan struct{})
+	get := func(h hash.Hash) Chunk {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return c
+	}
+
+	const n = 10
+	coalesced := coalescedGets.Value()
+	results := make(chan Chunk, n)
+	wg := &sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			results <- g.Get(c.Hash(), get)
+		}()
+	}
+	// Hold the first Get until all the others are waiting for it.
+	for coalescedGets.Value()-coalesced < n-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	for r := range results {
+		assert.Equal(c.Hash(), r.Hash())
+	}
+
+	// Once it's done, a Get of the same hash goes to the store again.
+	assert.Equal(c.Hash(), g.Get(c.Hash(), get).Hash())
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestGetGroupPanic(t *testing.T) {
+	assert := assert.New(t)
+	g := &GetGroup{}
+	h := hash.Of([]byte("abc"))
+	assert.Panics(func() {
+		g.Get(h, func(h hash.Hash) Chunk { panic("boom") })
+	})
+	assert.True(g.Get(h, func(h hash.Hash) Chunk { return EmptyChunk }).IsEmpty())
+}
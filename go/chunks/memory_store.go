@@ -49,6 +49,23 @@ func (ms *MemoryStorage) Has(r hash.Hash) bool {
 	return ok
 }
 
+// HashesWithPrefix returns the hashes in ms.data whose String() begins with
+// prefix.
+func (ms *MemoryStorage) HashesWithPrefix(prefix string) hash.HashSet {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return hashesWithPrefix(ms.data, prefix, hash.HashSet{})
+}
+
+func hashesWithPrefix(data map[hash.Hash]Chunk, prefix string, found hash.HashSet) hash.HashSet {
+	for h := range data {
+		if h.HasPrefix(prefix) {
+			found.Insert(h)
+		}
+	}
+	return found
+}
+
 // Len returns the number of Chunks in ms.data.
 func (ms *MemoryStorage) Len() int {
 	ms.mu.RLock()
@@ -92,7 +109,8 @@ type MemoryStoreView struct {
 	rootHash hash.Hash
 	mu       sync.RWMutex
 
-	storage *MemoryStorage
+	storage    *MemoryStorage
+	contention RootContentionSubscribers
 }
 
 func (ms *MemoryStoreView) Get(h hash.Hash) Chunk {
@@ -133,6 +151,12 @@ func (ms *MemoryStoreView) HasMany(hashes hash.HashSet) hash.HashSet {
 	return present
 }
 
+func (ms *MemoryStoreView) HashesWithPrefix(prefix string) hash.HashSet {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return hashesWithPrefix(ms.pending, prefix, ms.storage.HashesWithPrefix(prefix))
+}
+
 func (ms *MemoryStoreView) Version() string {
 	return constants.NomsVersion
 }
@@ -165,20 +189,30 @@ func (ms *MemoryStoreView) Root() hash.Hash {
 }
 
 func (ms *MemoryStoreView) Commit(current, last hash.Hash) bool {
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
-	if last != ms.rootHash {
-		return false
-	}
+	success, winner := func() (bool, hash.Hash) {
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		if last != ms.rootHash {
+			return false, ms.rootHash
+		}
 
-	success := ms.storage.Update(current, last, ms.pending)
-	if success {
-		ms.pending = nil
+		success := ms.storage.Update(current, last, ms.pending)
+		if success {
+			ms.pending = nil
+		}
+		ms.rootHash = ms.storage.Root()
+		return success, ms.rootHash
+	}()
+	if !success {
+		ms.contention.Notify(RootContention{last, current, winner})
 	}
-	ms.rootHash = ms.storage.Root()
 	return success
 }
 
+func (ms *MemoryStoreView) SubscribeRootContention(f func(RootContention)) (unsubscribe func()) {
+	return ms.contention.Subscribe(f)
+}
+
 func (ms *MemoryStoreView) Close() error {
 	return nil
 }
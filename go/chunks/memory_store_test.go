@@ -2,11 +2,13 @@
 // Licensed under the Apache License, version 2.0:
 // http://www.apache.org/licenses/LICENSE-2.0
 
-package chunks
+package chunks_test
 
 import (
 	"testing"
 
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/chunks/chunkstest"
 	"github.com/attic-labs/testify/suite"
 )
 
@@ -15,11 +17,11 @@ func TestMemoryStoreTestSuite(t *testing.T) {
 }
 
 type MemoryStoreTestSuite struct {
-	ChunkStoreTestSuite
+	chunkstest.Suite
 }
 
 func (suite *MemoryStoreTestSuite) SetupTest() {
-	suite.Factory = NewMemoryStoreFactory()
+	suite.Factory = chunks.NewMemoryStoreFactory()
 }
 
 func (suite *MemoryStoreTestSuite) TearDownTest() {
@@ -0,0 +1,64 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// RootContention describes a Commit that lost a race to update the root.
+type RootContention struct {
+	// Last is the root the Commit expected to replace.
+	Last hash.Hash
+	// Attempted is the root the Commit tried to set.
+	Attempted hash.Hash
+	// Winner is the root the store holds instead, as set by the writer that
+	// got there first.
+	Winner hash.Hash
+}
+
+// RootContentionSubscribers keeps the subscribers of a RootContentionNotifier,
+// for ChunkStore implementations to use. The zero value has no subscribers,
+// and it's safe for concurrent use.
+type RootContentionSubscribers struct {
+	mu   sync.Mutex
+	next int
+	subs map[int]func(RootContention)
+}
+
+// Subscribe adds f to the subscribers, until the returned function is called.
+func (rs *RootContentionSubscribers) Subscribe(f func(RootContention)) (unsubscribe func()) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.subs == nil {
+		rs.subs = map[int]func(RootContention){}
+	}
+	id := rs.next
+	rs.next++
+	rs.subs[id] = f
+	return func() {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		delete(rs.subs, id)
+	}
+}
+
+// Notify calls each subscriber with rc. Subscribers may subscribe and
+// unsubscribe while being notified; the ones subscribed when Notify is called
+// are called.
+func (rs *RootContentionSubscribers) Notify(rc RootContention) {
+	rs.mu.Lock()
+	subs := make([]func(RootContention), 0, len(rs.subs))
+	for _, f := range rs.subs {
+		subs = append(subs, f)
+	}
+	rs.mu.Unlock()
+
+	for _, f := range subs {
+		f(rc)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// ChunkTooLargeError is the cause of the panic raised by Put on a ChunkStore
+// returned by NewSizeLimitedChunkStore when the chunk is larger than the
+// limit. It can be recovered using d.Try(f, &ChunkTooLargeError{}).
+type ChunkTooLargeError struct {
+	Hash hash.Hash
+	// Size is the length of the chunk's data, and Max the limit, in bytes.
+	Size, Max uint64
+}
+
+func (e *ChunkTooLargeError) Error() string {
+	return fmt.Sprintf("Chunk %s is %d bytes, more than the maximum of %d", e.Hash, e.Size, e.Max)
+}
+
+// NewSizeLimitedChunkStore returns a ChunkStore that forwards to |cs|, except
+// that Put panics with a *ChunkTooLargeError, and doesn't put the chunk, if
+// its data is more than |max| bytes long. Use it in front of backends that
+// can't store large items, e.g. DynamoDB, so that writes that would
+// eventually fail there fail early instead. Chunks that are too large are
+// usually Blobs or Strings that were written without being chunked.
+func NewSizeLimitedChunkStore(cs ChunkStore, max uint64) ChunkStore {
+	d.PanicIfTrue(max == 0)
+	return &sizeLimitedChunkStore{cs, max}
+}
+
+type sizeLimitedChunkStore struct {
+	ChunkStore
+	max uint64
+}
+
+func (scs *sizeLimitedChunkStore) Put(c Chunk) {
+	if size := uint64(len(c.Data())); size > scs.max {
+		panic(d.Wrap(&ChunkTooLargeError{c.Hash(), size, scs.max}))
+	}
+	scs.ChunkStore.Put(c)
+}
+
+// HashesWithPrefix forwards to the underlying ChunkStore if it is a
+// HashPrefixLister, and otherwise finds nothing.
+func (scs *sizeLimitedChunkStore) HashesWithPrefix(prefix string) hash.HashSet {
+	if l, ok := scs.ChunkStore.(HashPrefixLister); ok {
+		return l.HashesWithPrefix(prefix)
+	}
+	return hash.HashSet{}
+}
+
+// SubscribeRootContention forwards to the underlying ChunkStore if it is a
+// RootContentionNotifier, and otherwise never notifies.
+func (scs *sizeLimitedChunkStore) SubscribeRootContention(f func(RootContention)) (unsubscribe func()) {
+	if n, ok := scs.ChunkStore.(RootContentionNotifier); ok {
+		return n.SubscribeRootContention(f)
+	}
+	return func() {}
+}
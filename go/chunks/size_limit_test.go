@@ -0,0 +1,29 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestSizeLimitedChunkStore(t *testing.T) {
+	assert := assert.New(t)
+	storage := &MemoryStorage{}
+	cs := NewSizeLimitedChunkStore(storage.NewView(), 4)
+
+	small := NewChunk([]byte("abcd"))
+	cs.Put(small)
+	assert.True(cs.Has(small.Hash()))
+
+	large := NewChunk([]byte("abcde"))
+	err := d.Try(func() { cs.Put(large) }, &ChunkTooLargeError{})
+	assert.Equal(&ChunkTooLargeError{large.Hash(), 5, 4}, err)
+	assert.False(cs.Has(large.Hash()))
+
+	assert.Equal(1, len(cs.(HashPrefixLister).HashesWithPrefix(small.Hash().String()[:4])))
+}
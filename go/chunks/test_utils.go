@@ -7,20 +7,8 @@ package chunks
 import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
-	"github.com/attic-labs/testify/assert"
 )
 
-func assertInputInStore(input string, h hash.Hash, s ChunkStore, assert *assert.Assertions) {
-	chunk := s.Get(h)
-	assert.False(chunk.IsEmpty(), "Shouldn't get empty chunk for %s", h.String())
-	assert.Equal(input, string(chunk.Data()))
-}
-
-func assertInputNotInStore(input string, h hash.Hash, s ChunkStore, assert *assert.Assertions) {
-	chunk := s.Get(h)
-	assert.True(chunk.IsEmpty(), "Shouldn't get non-empty chunk for %s: %v", h.String(), chunk)
-}
-
 type TestStorage struct {
 	MemoryStorage
 }
@@ -56,6 +44,20 @@ func (s *TestStoreView) HasMany(hashes hash.HashSet) hash.HashSet {
 	return s.ChunkStore.HasMany(hashes)
 }
 
+func (s *TestStoreView) HashesWithPrefix(prefix string) hash.HashSet {
+	if l, ok := s.ChunkStore.(HashPrefixLister); ok {
+		return l.HashesWithPrefix(prefix)
+	}
+	return hash.HashSet{}
+}
+
+func (s *TestStoreView) SubscribeRootContention(f func(RootContention)) (unsubscribe func()) {
+	if n, ok := s.ChunkStore.(RootContentionNotifier); ok {
+		return n.SubscribeRootContention(f)
+	}
+	return func() {}
+}
+
 func (s *TestStoreView) Put(c Chunk) {
 	s.Writes++
 	s.ChunkStore.Put(c)
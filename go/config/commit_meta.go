@@ -0,0 +1,28 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package config
+
+import (
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	flag "github.com/juju/gnuflag"
+)
+
+// RegisterCommitMetaFlags is like spec.RegisterCommitMetaFlags, but --author
+// defaults to the Author of the config in effect, so that commits made by
+// commands that use these flags are attributed to the user.
+func RegisterCommitMetaFlags(flags *flag.FlagSet) {
+	spec.RegisterCommitMetaFlags(flags)
+	c, err := LoadConfig()
+	if err != nil {
+		// A config that can't be read is reported when it's resolved.
+		return
+	}
+	if author := c.Author(); author != "" {
+		f := flags.Lookup("author")
+		d.PanicIfError(f.Value.Set(author))
+		f.DefValue = author
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/attic-labs/noms/go/spec"
@@ -19,15 +21,37 @@ import (
 type Config struct {
 	File string
 	Db   map[string]DbConfig
+	User UserConfig
+	UI   UIConfig
 }
 
 type DbConfig struct {
 	Url string
 }
 
+// UserConfig is the identity recorded as the author of commits.
+type UserConfig struct {
+	Name  string
+	Email string
+}
+
+// UIConfig holds preferences for how commands display their output.
+type UIConfig struct {
+	// Color is UIAlways, UINever or, if empty, UIAuto, which uses color when
+	// stdout is a terminal.
+	Color string
+	// Pager is UINever or, if empty, UIAuto, which pages output when stdout
+	// is a terminal.
+	Pager string
+}
+
 const (
 	NomsConfigFile = ".nomsconfig"
 	DefaultDbAlias = "default"
+
+	UIAuto   = "auto"
+	UIAlways = "always"
+	UINever  = "never"
 )
 
 var NoConfig = errors.New(fmt.Sprintf("no %s found", NomsConfigFile))
@@ -59,7 +83,53 @@ func FindNomsConfig() (*Config, error) {
 	}
 }
 
+// UserConfigFile returns the name of the user's config file, which holds
+// settings for every directory. It's the .nomsconfig in the home directory.
+func UserConfigFile() string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, NomsConfigFile)
+}
+
+// LoadConfig returns the config in effect in the current directory: the one
+// found by FindNomsConfig, layered over the user's config. Settings in the
+// former take precedence. LoadConfig returns NoConfig if neither exists.
+func LoadConfig() (*Config, error) {
+	var user *Config
+	if file := UserConfigFile(); file != "" {
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			if user, err = ReadConfig(file); err != nil {
+				return nil, err
+			}
+		} else if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	c, err := FindNomsConfig()
+	if err == NoConfig && user != nil {
+		return user, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if user == nil || user.File == c.File {
+		return c, nil
+	}
+	return user.merge(c), nil
+}
+
 func ReadConfig(name string) (*Config, error) {
+	c, err := readRawConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return qualifyPaths(name, c)
+}
+
+// readRawConfig is like ReadConfig, but leaves relative paths in db specs as
+// they are, so that the config can be written back unchanged.
+func readRawConfig(name string) (*Config, error) {
 	data, err := ioutil.ReadFile(name)
 	if err != nil {
 		return nil, err
@@ -69,7 +139,23 @@ func ReadConfig(name string) (*Config, error) {
 		return nil, err
 	}
 	c.File = name
-	return qualifyPaths(name, c)
+	return c, nil
+}
+
+// EditConfig reads the config file |name|, calls |edit| to change it, and
+// writes it back. If the file doesn't exist, |edit| is given an empty config.
+func EditConfig(name string, edit func(c *Config) error) error {
+	c, err := readRawConfig(name)
+	if os.IsNotExist(err) {
+		c, err = &Config{File: name}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := edit(c); err != nil {
+		return err
+	}
+	return c.writeFile(name)
 }
 
 func NewConfig(data string) (*Config, error) {
@@ -82,15 +168,144 @@ func NewConfig(data string) (*Config, error) {
 
 func (c *Config) WriteTo(configHome string) (string, error) {
 	file := filepath.Join(configHome, NomsConfigFile)
-	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
-		return "", err
-	}
-	if err := ioutil.WriteFile(file, []byte(c.writeableString()), os.ModePerm); err != nil {
+	if err := c.writeFile(file); err != nil {
 		return "", err
 	}
 	return file, nil
 }
 
+func (c *Config) writeFile(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte(c.writeableString()), os.ModePerm)
+}
+
+// merge returns a copy of |c| with the settings in |over| taking precedence.
+func (c *Config) merge(over *Config) *Config {
+	mc := *over
+	mc.Db = map[string]DbConfig{}
+	for k, r := range c.Db {
+		mc.Db[k] = r
+	}
+	for k, r := range over.Db {
+		mc.Db[k] = r
+	}
+	if mc.User.Name == "" {
+		mc.User.Name = c.User.Name
+	}
+	if mc.User.Email == "" {
+		mc.User.Email = c.User.Email
+	}
+	if mc.UI.Color == "" {
+		mc.UI.Color = c.UI.Color
+	}
+	if mc.UI.Pager == "" {
+		mc.UI.Pager = c.UI.Pager
+	}
+	return &mc
+}
+
+// Author returns the user's identity, formatted as "Name <email>", or "" if
+// neither is configured.
+func (c *Config) Author() string {
+	switch {
+	case c.User.Email == "":
+		return c.User.Name
+	case c.User.Name == "":
+		return "<" + c.User.Email + ">"
+	}
+	return fmt.Sprintf("%s <%s>", c.User.Name, c.User.Email)
+}
+
+// Keys returns the names of the settings in |c|, in order. The names are those
+// used by Get and Set: "db.<alias>.url", "user.name", "user.email", "ui.color"
+// and "ui.pager".
+func (c *Config) Keys() []string {
+	keys := []string{}
+	for k := range c.Db {
+		keys = append(keys, "db."+k+".url")
+	}
+	sort.Strings(keys)
+	for _, k := range []string{"user.name", "user.email", "ui.color", "ui.pager"} {
+		if v, _ := c.Get(k); v != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Get returns the value of the setting named |key|, and whether it's set.
+func (c *Config) Get(key string) (string, bool) {
+	var v string
+	switch key {
+	case "user.name":
+		v = c.User.Name
+	case "user.email":
+		v = c.User.Email
+	case "ui.color":
+		v = c.UI.Color
+	case "ui.pager":
+		v = c.UI.Pager
+	default:
+		alias, ok := dbAlias(key)
+		if !ok {
+			return "", false
+		}
+		r, ok := c.Db[alias]
+		return r.Url, ok
+	}
+	return v, v != ""
+}
+
+// Set sets the setting named |key| to |value|. It returns an error if there's
+// no such setting, or if |value| isn't valid for it.
+func (c *Config) Set(key, value string) error {
+	checkUI := func(values ...string) error {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("Invalid value for %s: %s, must be one of %s", key, value, strings.Join(values, ", "))
+	}
+	switch key {
+	case "user.name":
+		c.User.Name = value
+	case "user.email":
+		c.User.Email = value
+	case "ui.color":
+		if err := checkUI(UIAuto, UIAlways, UINever); err != nil {
+			return err
+		}
+		c.UI.Color = value
+	case "ui.pager":
+		if err := checkUI(UIAuto, UINever); err != nil {
+			return err
+		}
+		c.UI.Pager = value
+	default:
+		alias, ok := dbAlias(key)
+		if !ok {
+			return fmt.Errorf("Unknown config key: %s", key)
+		}
+		if c.Db == nil {
+			c.Db = map[string]DbConfig{}
+		}
+		c.Db[alias] = DbConfig{value}
+	}
+	return nil
+}
+
+// dbAlias returns the alias named by a key of the form "db.<alias>.url".
+func dbAlias(key string) (string, bool) {
+	const prefix, suffix = "db.", ".url"
+	if len(key) <= len(prefix)+len(suffix) || !strings.HasPrefix(key, prefix) || !strings.HasSuffix(key, suffix) {
+		return "", false
+	}
+	return key[len(prefix) : len(key)-len(suffix)], true
+}
+
 // Replace relative directory in path part of spec with an absolute
 // directory. Assumes the path is relative to the location of the config file
 func absDbSpec(configHome string, url string) string {
@@ -134,8 +349,68 @@ func (c *Config) String() string {
 func (c *Config) writeableString() string {
 	var buffer bytes.Buffer
 	for k, r := range c.Db {
-		buffer.WriteString(fmt.Sprintf("[db.%s]\n", k))
-		buffer.WriteString(fmt.Sprintf("\t"+`url = "%s"`+"\n", r.Url))
+		buffer.WriteString(fmt.Sprintf("[db.%s]\n", tomlKey(k)))
+		buffer.WriteString(fmt.Sprintf("\turl = %s\n", tomlString(r.Url)))
+	}
+	writeSection := func(name string, values ...string) {
+		header := false
+		for i := 0; i < len(values); i += 2 {
+			if values[i+1] == "" {
+				continue
+			}
+			if !header {
+				buffer.WriteString(fmt.Sprintf("[%s]\n", name))
+				header = true
+			}
+			buffer.WriteString(fmt.Sprintf("\t%s = %s\n", values[i], tomlString(values[i+1])))
+		}
+	}
+	writeSection("user", "name", c.User.Name, "email", c.User.Email)
+	writeSection("ui", "color", c.UI.Color, "pager", c.UI.Pager)
+	return buffer.String()
+}
+
+// tomlKey returns |k| as a TOML key: bare if it can be, and otherwise quoted.
+func tomlKey(k string) string {
+	if k == "" {
+		return tomlString(k)
+	}
+	for _, r := range k {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+// tomlString returns |s| as a TOML basic string. strconv.Quote isn't used
+// because TOML doesn't support all of Go's escapes, e.g. \x and \a.
+func tomlString(s string) string {
+	var buffer bytes.Buffer
+	buffer.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buffer.WriteByte('\\')
+			buffer.WriteRune(r)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				buffer.WriteString(fmt.Sprintf(`\u%04X`, r))
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
 	}
+	buffer.WriteByte('"')
 	return buffer.String()
 }
@@ -27,32 +27,28 @@ var (
 	ctestRoot = os.TempDir()
 
 	ldbConfig = &Config{
-		"",
-		map[string]DbConfig{
+		Db: map[string]DbConfig{
 			DefaultDbAlias: {nbsSpec},
 			remoteAlias:    {httpSpec},
 		},
 	}
 
 	httpConfig = &Config{
-		"",
-		map[string]DbConfig{
+		Db: map[string]DbConfig{
 			DefaultDbAlias: {httpSpec},
 			remoteAlias:    {nbsSpec},
 		},
 	}
 
 	memConfig = &Config{
-		"",
-		map[string]DbConfig{
+		Db: map[string]DbConfig{
 			DefaultDbAlias: {memSpec},
 			remoteAlias:    {httpSpec},
 		},
 	}
 
 	ldbAbsConfig = &Config{
-		"",
-		map[string]DbConfig{
+		Db: map[string]DbConfig{
 			DefaultDbAlias: {nbsAbsSpec},
 			remoteAlias:    {httpSpec},
 		},
@@ -199,3 +195,118 @@ func TestCwd(t *testing.T) {
 
 	assert.Equal(cwd, abs)
 }
+
+func TestConfigGetSet(t *testing.T) {
+	assert := assert.New(t)
+	c := &Config{}
+	assert.NoError(c.Set("db.default.url", nbsSpec))
+	assert.NoError(c.Set("user.name", "Ada"))
+	assert.NoError(c.Set("user.email", "ada@example.com"))
+	assert.NoError(c.Set("ui.color", UINever))
+	assert.Error(c.Set("ui.color", "sometimes"))
+	assert.Error(c.Set("ui.pager", UIAlways))
+	assert.Error(c.Set("db..url", nbsSpec))
+	assert.Error(c.Set("user.phone", "555"))
+
+	v, ok := c.Get("db.default.url")
+	assert.True(ok)
+	assert.Equal(nbsSpec, v)
+	v, ok = c.Get("ui.color")
+	assert.True(ok)
+	assert.Equal(UINever, v)
+	_, ok = c.Get("ui.pager")
+	assert.False(ok)
+	_, ok = c.Get("db.origin.url")
+	assert.False(ok)
+
+	assert.Equal([]string{"db.default.url", "user.name", "user.email", "ui.color"}, c.Keys())
+	assert.Equal("Ada <ada@example.com>", c.Author())
+	assert.Equal("<ada@example.com>", (&Config{User: UserConfig{Email: "ada@example.com"}}).Author())
+	assert.Equal("", (&Config{}).Author())
+}
+
+func TestEditConfig(t *testing.T) {
+	assert := assert.New(t)
+	path := getPaths(assert, "home.edit")
+	assert.NoError(os.RemoveAll(path.home))
+
+	set := func(key, value string) error {
+		return EditConfig(path.config, func(c *Config) error { return c.Set(key, value) })
+	}
+	assert.NoError(set("db.default.url", nbsSpec))
+	assert.NoError(set("user.name", "Ada"))
+	assert.Error(set("ui.pager", "sometimes"))
+
+	// Relative paths are written back as they were.
+	c, err := readRawConfig(path.config)
+	assert.NoError(err)
+	assert.Equal(nbsSpec, c.Db[DefaultDbAlias].Url)
+	assert.Equal("Ada", c.User.Name)
+
+	c, err = ReadConfig(path.config)
+	assert.NoError(err)
+	assertDbSpecsEquiv(assert, nbsSpec, c.Db[DefaultDbAlias].Url)
+}
+
+func TestLoadConfig(t *testing.T) {
+	assert := assert.New(t)
+	user := getPaths(assert, "home.user")
+	repo := getPaths(assert, "home.repo")
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	assert.NoError(os.Setenv("HOME", user.home))
+
+	userConfig := &Config{
+		Db: map[string]DbConfig{
+			DefaultDbAlias: {httpSpec},
+			remoteAlias:    {httpSpec},
+		},
+		User: UserConfig{"Ada", "ada@example.com"},
+		UI:   UIConfig{Color: UINever},
+	}
+	writeConfig(assert, userConfig, user.home)
+	writeConfig(assert, &Config{
+		Db:   map[string]DbConfig{DefaultDbAlias: {memSpec}},
+		User: UserConfig{Email: "ada@work.example.com"},
+	}, repo.home)
+
+	// Only the user's config applies outside of the repo.
+	assert.NoError(os.MkdirAll(filepath.Join(ctestRoot, "home.other"), os.ModePerm))
+	assert.NoError(os.Chdir(filepath.Join(ctestRoot, "home.other")))
+	c, err := LoadConfig()
+	assert.NoError(err)
+	validateConfig(assert, user.config, userConfig, c)
+	assert.Equal("Ada <ada@example.com>", c.Author())
+
+	// In the repo, its settings take precedence.
+	assert.NoError(os.Chdir(repo.home))
+	c, err = LoadConfig()
+	assert.NoError(err)
+	assert.Equal(repo.config, c.File)
+	assert.Equal(memSpec, c.Db[DefaultDbAlias].Url)
+	assert.Equal(httpSpec, c.Db[remoteAlias].Url)
+	assert.Equal("Ada <ada@work.example.com>", c.Author())
+	assert.Equal(UINever, c.UI.Color)
+
+	assert.NoError(os.Setenv("HOME", filepath.Join(ctestRoot, "home.none")))
+	c, err = LoadConfig()
+	assert.NoError(err)
+	assert.Equal("<ada@work.example.com>", c.Author())
+}
+
+func TestWriteableStringRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	c := &Config{
+		Db: map[string]DbConfig{
+			DefaultDbAlias:   {`http://example.com/"quoted"\path`},
+			`my "db".name\x`: {"mem\ttab"},
+		},
+		User: UserConfig{`Ada "The Countess" \ Lovelace`, "ada@example.com"},
+		UI:   UIConfig{Pager: "less -R\n"},
+	}
+	rc, err := NewConfig(c.writeableString())
+	assert.NoError(err)
+	assert.Equal(c.Db, rc.Db)
+	assert.Equal(c.User, rc.User)
+	assert.Equal(c.UI, rc.UI)
+}
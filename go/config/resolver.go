@@ -24,8 +24,9 @@ type Resolver struct {
 // line arguments when a .nomsconfig file is present. To use it, create a config resolver
 // before command line processing and use it to resolve each dataspec argument in
 // succession.
+// The config is the one returned by LoadConfig, so the user's .nomsconfig applies too.
 func NewResolver() *Resolver {
-	c, err := FindNomsConfig()
+	c, err := LoadConfig()
 	if err != nil {
 		if err != NoConfig {
 			panic(fmt.Errorf("Failed to read .nomsconfig due to: %v", err))
@@ -35,6 +36,14 @@ func NewResolver() *Resolver {
 	return &Resolver{c, ""}
 }
 
+// Config returns the config in effect, which is empty if there's none.
+func (r *Resolver) Config() *Config {
+	if r.config == nil {
+		return &Config{}
+	}
+	return r.config
+}
+
 // Print replacement if one occurred
 func (r *Resolver) verbose(orig string, replacement string) string {
 	if verbose.Verbose() && orig != replacement {
@@ -52,7 +61,10 @@ func (r *Resolver) verbose(orig string, replacement string) string {
 func (r *Resolver) ResolveDbSpec(str string) string {
 	if r.config != nil {
 		if str == "" {
-			return r.config.Db[DefaultDbAlias].Url
+			if val, ok := r.config.Db[DefaultDbAlias]; ok {
+				return val.Url
+			}
+			return str
 		}
 		if val, ok := r.config.Db[str]; ok {
 			return val.Url
@@ -70,6 +82,10 @@ func (r *Resolver) ResolveDbSpec(str string) string {
 func (r *Resolver) ResolvePathSpec(str string) string {
 	if r.config != nil {
 		split := strings.SplitN(str, spec.Separator, 2)
+		if _, ok := r.config.Db[DefaultDbAlias]; !ok && len(split) == 1 {
+			// The config may only hold user settings.
+			return str
+		}
 		db, rest := "", split[0]
 		if len(split) > 1 {
 			db, rest = split[0], split[1]
@@ -123,5 +139,10 @@ func (r *Resolver) GetPath(str string) (datas.Database, types.Value, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return sp.GetDatabase(), sp.GetValue(), nil
+	db := sp.GetDatabase()
+	if sp.Path, err = sp.Path.ResolveHashPrefix(db); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return db, sp.GetValue(), nil
 }
@@ -10,6 +10,7 @@ const (
 	GetBlobPath    = "/getBlob/"
 	HasRefsPath    = "/hasRefs/"
 	WriteValuePath = "/writeValue/"
+	CommitPath     = "/commit/"
 	BasePath       = "/"
 
 	GraphQLPath = "/graphql/"
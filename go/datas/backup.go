@@ -0,0 +1,155 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+/*
+  Backup:
+    Magic  // backupMagic
+    Since  // 20-byte hash of the root the backup was taken since, zero if none
+    Root   // 20-byte hash of the root that was backed up
+    Pack   // a chunk pack, as written by chunks.PackWriter
+*/
+
+const backupMagic = "NBK1"
+
+var (
+	// ErrMalformedBackup is returned by ApplyBackup when what it reads isn't a
+	// backup written by WriteBackup.
+	ErrMalformedBackup = errors.New("Malformed backup")
+	// ErrWrongBackupBase is returned by ApplyBackup when the root of the
+	// Database isn't the one the backup was taken since.
+	ErrWrongBackupBase = errors.New("Database root is not the one the backup was taken since")
+)
+
+// WriteBackup writes a backup of the current root of |db| to |w|, and returns
+// that root. If |since| isn't empty, it must be a root of db that was backed
+// up before, and the backup is incremental: it only holds the chunks that are
+// reachable from the current root but not from |since|, and can only be
+// applied to a Database whose root is |since|, e.g. one that earlier backups
+// were applied to. Otherwise it holds every chunk that's reachable from the
+// root, and can be applied to an empty Database.
+//
+// Like Pull, it walks the chunks reachable from the two roots in height
+// order, so that only the chunks of |since| that are taller than the
+// shortest new chunk are read.
+func WriteBackup(db Database, since hash.Hash, w io.Writer) (root hash.Hash, err error) {
+	db.Rebase()
+	cs := db.chunkStore()
+	root = cs.Root()
+
+	srcQ, sinkQ := types.RefByHeight{}, types.RefByHeight{}
+	if root != since && !root.IsEmpty() {
+		srcQ = append(srcQ, types.NewRef(db.ReadValue(root)))
+	}
+	if !since.IsEmpty() {
+		v := db.ReadValue(since)
+		if v == nil {
+			return hash.Hash{}, fmt.Errorf("Root %s not found", since)
+		}
+		sinkQ = append(sinkQ, types.NewRef(v))
+	}
+
+	ew := &errWriter{w: w}
+	ew.Write([]byte(backupMagic))
+	ew.Write(since[:])
+	ew.Write(root[:])
+	pw := chunks.NewPackWriter(ew)
+
+	seen := hash.HashSet{}
+	for !srcQ.Empty() && ew.err == nil {
+		srcRefs, sinkRefs, _ := planWork(&srcQ, &sinkQ)
+		for _, r := range srcRefs {
+			if seen.Has(r.TargetHash()) {
+				continue
+			}
+			seen.Insert(r.TargetHash())
+			c := cs.Get(r.TargetHash())
+			pw.Write(c)
+			srcQ = append(srcQ, getChunks(types.DecodeValue(c, db))...)
+		}
+		// As in Pull, common chunks, and what they reach, were backed up
+		// already.
+		for _, r := range sinkRefs {
+			if r.Height() > 1 {
+				sinkQ = append(sinkQ, getChunks(r.TargetValue(db))...)
+			}
+		}
+		sort.Sort(srcQ)
+		sort.Sort(sinkQ)
+	}
+	if ew.err == nil {
+		pw.Close()
+	}
+	return root, ew.err
+}
+
+// ApplyBackup reads a backup that WriteBackup wrote from |r|, writes its
+// chunks to |db|, and moves the root of db to the root that was backed up,
+// which it returns. db's root must be the one the backup was taken since, or
+// empty for a backup that isn't incremental; otherwise ErrWrongBackupBase is
+// returned and nothing is written. Applying a backup whose root db already
+// has does nothing.
+func ApplyBackup(db Database, r io.Reader) (root hash.Hash, err error) {
+	header := make([]byte, len(backupMagic)+2*hash.ByteLen)
+	if _, err = io.ReadFull(r, header); err != nil || string(header[:len(backupMagic)]) != backupMagic {
+		return hash.Hash{}, ErrMalformedBackup
+	}
+	var since hash.Hash
+	copy(since[:], header[len(backupMagic):])
+	copy(root[:], header[len(backupMagic)+hash.ByteLen:])
+
+	db.Rebase()
+	cs := db.chunkStore()
+	switch cs.Root() {
+	case root:
+		return root, nil
+	case since:
+	default:
+		return hash.Hash{}, ErrWrongBackupBase
+	}
+
+	chunkChan := make(chan *chunks.Chunk, 64)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- chunks.DeserializePack(r, chunkChan)
+		close(chunkChan)
+	}()
+	for c := range chunkChan {
+		cs.Put(*c)
+	}
+	if err = <-errChan; err != nil {
+		return hash.Hash{}, err
+	}
+
+	if !cs.Commit(root, since) {
+		return hash.Hash{}, ErrOptimisticLockFailed
+	}
+	return root, nil
+}
+
+// errWriter keeps the first error writing to w, and drops what's written
+// after it, so that it can be checked once at the end.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err == nil {
+		_, ew.err = ew.w.Write(p)
+	}
+	return len(p), nil
+}
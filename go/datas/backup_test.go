@@ -0,0 +1,84 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	assert := assert.New(t)
+	src := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer src.Close()
+
+	list := func(n int) types.List {
+		vs := []types.Value{}
+		for i := 0; i < n; i++ {
+			vs = append(vs, types.String("backup"), types.Number(i))
+		}
+		return types.NewList(vs...)
+	}
+	a := list(5000)
+	ds, err := src.CommitValue(src.GetDataset("ds"), a)
+	assert.NoError(err)
+
+	full := &bytes.Buffer{}
+	root1, err := WriteBackup(src, hash.Hash{}, full)
+	assert.NoError(err)
+
+	b := a.Append(types.String("more"))
+	ds, err = src.CommitValue(ds, b)
+	assert.NoError(err)
+
+	incremental := &bytes.Buffer{}
+	since, err := WriteBackup(src, root1, incremental)
+	assert.NoError(err)
+	assert.NotEqual(root1, since)
+	full2 := &bytes.Buffer{}
+	_, err = WriteBackup(src, hash.Hash{}, full2)
+	assert.NoError(err)
+	assert.True(incremental.Len() < full2.Len())
+
+	// An incremental backup can't be applied to a Database that doesn't have
+	// the root it was taken since.
+	empty := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer empty.Close()
+	_, err = ApplyBackup(empty, bytes.NewReader(incremental.Bytes()))
+	assert.Equal(ErrWrongBackupBase, err)
+
+	sink := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer sink.Close()
+
+	// A truncated backup doesn't move the root.
+	_, err = ApplyBackup(sink, bytes.NewReader(full.Bytes()[:full.Len()-1]))
+	assert.Error(err)
+	sink.Rebase()
+	_, ok := sink.GetDataset("ds").MaybeHeadRef()
+	assert.False(ok)
+
+	root, err := ApplyBackup(sink, bytes.NewReader(full.Bytes()))
+	assert.NoError(err)
+	assert.Equal(root1, root)
+	assert.True(a.Equals(sink.GetDataset("ds").HeadValue()))
+
+	root, err = ApplyBackup(sink, bytes.NewReader(incremental.Bytes()))
+	assert.NoError(err)
+	assert.Equal(since, root)
+	assert.True(b.Equals(sink.GetDataset("ds").HeadValue()))
+
+	// Applying a backup again does nothing.
+	root, err = ApplyBackup(sink, bytes.NewReader(incremental.Bytes()))
+	assert.NoError(err)
+	assert.Equal(since, root)
+
+	_, err = ApplyBackup(sink, bytes.NewReader([]byte("not a backup")))
+	assert.Equal(ErrMalformedBackup, err)
+}
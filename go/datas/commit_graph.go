@@ -0,0 +1,173 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// CommitGraph is the DAG formed by every Commit reachable from the heads of
+// the Datasets in a Database.
+type CommitGraph struct {
+	// Nodes holds one entry per Commit, ordered by descending height and then
+	// by hash, so that every Commit appears before its parents.
+	Nodes []CommitNode
+	// Heads maps each datasetID to the hash of its head Commit.
+	Heads map[string]hash.Hash
+}
+
+// CommitNode describes a single Commit in a CommitGraph.
+type CommitNode struct {
+	Hash    hash.Hash
+	Height  uint64
+	Parents []hash.Hash
+	Meta    types.Struct
+}
+
+// ExportCommitGraph walks the history of every Dataset in db and returns the
+// resulting CommitGraph. Commits shared between Datasets appear only once.
+func ExportCommitGraph(db Database) CommitGraph {
+	g := CommitGraph{Heads: map[string]hash.Hash{}}
+	pending := types.RefSlice{}
+	db.Datasets().IterAll(func(k, v types.Value) {
+		r := v.(types.Ref)
+		g.Heads[string(k.(types.String))] = r.TargetHash()
+		pending = append(pending, r)
+	})
+
+	visited := hash.HashSet{}
+	for len(pending) > 0 {
+		r := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited.Has(r.TargetHash()) {
+			continue
+		}
+		visited.Insert(r.TargetHash())
+
+		commit := db.ReadValue(r.TargetHash()).(types.Struct)
+		node := CommitNode{Hash: r.TargetHash(), Height: r.Height()}
+		if meta, ok := commit.MaybeGet(MetaField); ok {
+			node.Meta, _ = meta.(types.Struct)
+		}
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			p := v.(types.Ref)
+			node.Parents = append(node.Parents, p.TargetHash())
+			pending = append(pending, p)
+		})
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	sort.Sort(commitNodesByHeight(g.Nodes))
+	return g
+}
+
+type commitNodesByHeight []CommitNode
+
+func (ns commitNodesByHeight) Len() int      { return len(ns) }
+func (ns commitNodesByHeight) Swap(i, j int) { ns[i], ns[j] = ns[j], ns[i] }
+func (ns commitNodesByHeight) Less(i, j int) bool {
+	if ns[i].Height != ns[j].Height {
+		return ns[i].Height > ns[j].Height
+	}
+	return ns[i].Hash.Less(ns[j].Hash)
+}
+
+// datasetIDs returns the keys of g.Heads in sorted order.
+func (g CommitGraph) datasetIDs() []string {
+	ids := make([]string, 0, len(g.Heads))
+	for id := range g.Heads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// metaFields returns the fields of meta as human readable strings.
+func metaFields(meta types.Struct) map[string]string {
+	fields := map[string]string{}
+	if meta.IsZeroValue() {
+		return fields
+	}
+	meta.IterFields(func(name string, v types.Value) {
+		if s, ok := v.(types.String); ok {
+			fields[name] = string(s)
+		} else {
+			fields[name] = types.EncodedValue(v)
+		}
+	})
+	return fields
+}
+
+// WriteDOT writes g to w in the GraphViz DOT language. Each Commit is a node
+// labeled with its hash and meta fields, with edges pointing to its parents.
+// Each Dataset is a box shaped node with an edge to its head.
+func (g CommitGraph) WriteDOT(w io.Writer) error {
+	lines := []string{"digraph commits {", "  rankdir=BT;"}
+	for _, id := range g.datasetIDs() {
+		lines = append(lines,
+			fmt.Sprintf("  %s [shape=box, style=filled, fillcolor=lightgrey];", strconv.Quote("ds:"+id)),
+			fmt.Sprintf("  %s -> %s [style=dashed];", strconv.Quote("ds:"+id), strconv.Quote(g.Heads[id].String())))
+	}
+	for _, n := range g.Nodes {
+		label := []string{n.Hash.String()[:8]}
+		meta := metaFields(n.Meta)
+		names := make([]string, 0, len(meta))
+		for name := range meta {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			label = append(label, name+": "+meta[name])
+		}
+		lines = append(lines, fmt.Sprintf("  %s [label=%s];", strconv.Quote(n.Hash.String()), strconv.Quote(strings.Join(label, "\n"))))
+		for _, p := range n.Parents {
+			lines = append(lines, fmt.Sprintf("  %s -> %s;", strconv.Quote(n.Hash.String()), strconv.Quote(p.String())))
+		}
+	}
+	lines = append(lines, "}", "")
+	_, err := io.WriteString(w, strings.Join(lines, "\n"))
+	return err
+}
+
+type jsonCommitNode struct {
+	Hash    string            `json:"hash"`
+	Height  uint64            `json:"height"`
+	Parents []string          `json:"parents"`
+	Meta    map[string]string `json:"meta"`
+}
+
+type jsonCommitGraph struct {
+	Heads   map[string]string `json:"heads"`
+	Commits []jsonCommitNode  `json:"commits"`
+}
+
+// WriteJSON writes g to w as a JSON object with a "heads" object mapping
+// datasetIDs to commit hashes and a "commits" array in the order of g.Nodes.
+// Meta fields that are not Strings are written in their human readable
+// encoding.
+func (g CommitGraph) WriteJSON(w io.Writer) error {
+	jg := jsonCommitGraph{Heads: map[string]string{}, Commits: make([]jsonCommitNode, len(g.Nodes))}
+	for id, h := range g.Heads {
+		jg.Heads[id] = h.String()
+	}
+	for i, n := range g.Nodes {
+		parents := make([]string, len(n.Parents))
+		for j, p := range n.Parents {
+			parents[j] = p.String()
+		}
+		jg.Commits[i] = jsonCommitNode{n.Hash.String(), n.Height, parents, metaFields(n.Meta)}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jg)
+}
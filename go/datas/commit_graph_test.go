@@ -0,0 +1,86 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestExportCommitGraph(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.TestStorage{}).NewView())
+	defer db.Close()
+
+	meta := func(msg string) types.Struct {
+		return types.NewStruct("", types.StructData{"message": types.String(msg)})
+	}
+
+	// a <- b <- d (ds1, merges c)
+	//  \
+	//   c (ds2)
+	ds1, err := db.Commit(db.GetDataset("ds1"), types.Number(1), CommitOptions{Meta: meta("a")})
+	assert.NoError(err)
+	a := ds1.HeadRef()
+	ds1, err = db.Commit(ds1, types.Number(2), CommitOptions{Meta: meta("b")})
+	assert.NoError(err)
+	b := ds1.HeadRef()
+	ds2, err := db.Commit(db.GetDataset("ds2"), types.Number(3), CommitOptions{Parents: types.NewSet(a), Meta: meta("c")})
+	assert.NoError(err)
+	c := ds2.HeadRef()
+	ds1, err = db.Commit(ds1, types.Number(4), CommitOptions{Parents: types.NewSet(b, c), Meta: meta("d")})
+	assert.NoError(err)
+	dh := ds1.HeadRef()
+
+	g := ExportCommitGraph(db)
+	assert.Equal(map[string]hash.Hash{"ds1": dh.TargetHash(), "ds2": c.TargetHash()}, g.Heads)
+	assert.Len(g.Nodes, 4)
+
+	nodes := map[hash.Hash]CommitNode{}
+	for i, n := range g.Nodes {
+		nodes[n.Hash] = n
+		if i > 0 {
+			assert.True(g.Nodes[i-1].Height >= n.Height)
+		}
+	}
+	assert.Equal(dh.TargetHash(), g.Nodes[0].Hash)
+	assert.Equal(a.TargetHash(), g.Nodes[3].Hash)
+	assert.Empty(nodes[a.TargetHash()].Parents)
+	assert.Equal([]hash.Hash{a.TargetHash()}, nodes[c.TargetHash()].Parents)
+	assert.Len(nodes[dh.TargetHash()].Parents, 2)
+	assert.True(meta("b").Equals(nodes[b.TargetHash()].Meta))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(g.WriteDOT(buf))
+	dot := buf.String()
+	assert.True(strings.HasPrefix(dot, "digraph commits {"))
+	assert.Contains(dot, `"ds:ds2" -> "`+c.TargetHash().String()+`" [style=dashed];`)
+	assert.Contains(dot, `"`+c.TargetHash().String()+`" -> "`+a.TargetHash().String()+`";`)
+	assert.Contains(dot, `message: d`)
+
+	buf.Reset()
+	assert.NoError(g.WriteJSON(buf))
+	var out struct {
+		Heads   map[string]string
+		Commits []struct {
+			Hash    string
+			Height  uint64
+			Parents []string
+			Meta    map[string]string
+		}
+	}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(c.TargetHash().String(), out.Heads["ds2"])
+	assert.Len(out.Commits, 4)
+	assert.Equal("d", out.Commits[0].Meta["message"])
+	assert.Equal(uint64(1), out.Commits[3].Height)
+}
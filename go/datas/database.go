@@ -7,8 +7,10 @@ package datas
 
 import (
 	"io"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -33,9 +35,18 @@ type Database interface {
 	io.Closer
 
 	// Datasets returns the root of the database which is a
-	// Map<String, Ref<Commit>> where string is a datasetID.
+	// Map<String, Ref<Commit>> where string is a datasetID. The reflogs and
+	// leases that the root also holds, which Reflog and Leases read, are left
+	// out.
 	Datasets() types.Map
 
+	// ListDatasets returns the IDs of the Datasets in this Database whose IDs
+	// begin with prefix, in order. Datasets can be organized hierarchically
+	// by using '/' as a separator, e.g. "team/app/table", and then listed a
+	// level at a time, e.g. with the prefix "team/". Only the part of the
+	// root map that matches prefix is read.
+	ListDatasets(prefix string) []string
+
 	// GetDataset returns a Dataset struct containing the current mapping of
 	// datasetID in the above Datasets Map.
 	GetDataset(datasetID string) Dataset
@@ -77,6 +88,17 @@ type Database interface {
 	// of a conflict, Delete returns an 'ErrMergeNeeded' error.
 	Delete(ds Dataset) (Dataset, error)
 
+	// RenameDataset atomically moves the head of the Dataset named ds.ID() to
+	// newDatasetID and removes ds.ID(), in a single update of the root of the
+	// Database. The history of the Dataset is preserved; no new Commit is
+	// made. It returns ErrDatasetNotFound if ds.ID() has no head and
+	// ErrDatasetExists if newDatasetID already has one. If the head of ds.ID()
+	// is moved concurrently, RenameDataset returns 'ErrMergeNeeded'.
+	// The returned Dataset is always the newest snapshot of newDatasetID,
+	// regardless of success or failure, and Datasets() is updated to match
+	// backing storage upon return as well.
+	RenameDataset(ds Dataset, newDatasetID string) (Dataset, error)
+
 	// SetHead ignores any lineage constraints (e.g. the current Head being in
 	// commit’s Parent set) and force-sets a mapping from datasetID: commit in
 	// this database.
@@ -99,11 +121,26 @@ type Database interface {
 	// Regardless, Datasets() is updated to match backing storage upon return.
 	FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error)
 
+	// ResolveHashPrefix returns the hash of the single chunk in this
+	// database whose hash begins with prefix, so that users can abbreviate
+	// hashes. See types.ValueStore.ResolveHashPrefix for the errors it
+	// returns.
+	ResolveHashPrefix(prefix string) (hash.Hash, error)
+
+	// replaceHead is like SetHead, but fails with 'ErrMergeNeeded' unless the
+	// head of ds.ID() is still the head of ds. If dropReflog is true, the
+	// reflog of ds.ID() is dropped rather than added to.
+	replaceHead(ds Dataset, newHeadRef types.Ref, dropReflog bool) (Dataset, error)
+
 	// chunkStore returns the ChunkStore used to read and write
 	// groups of values to the database efficiently. This interface is a low-
 	// level detail of the database that should infrequently be needed by
 	// clients.
 	chunkStore() chunks.ChunkStore
+
+	// updateLeases replaces the leases kept in the root with those |update|
+	// returns, as AcquireLease, RenewLease and ReleaseLease do.
+	updateLeases(update func(leases types.Map, now time.Time) (types.Map, error)) error
 }
 
 func NewDatabase(cs chunks.ChunkStore) Database {
@@ -6,6 +6,8 @@ package datas
 
 import (
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
@@ -22,6 +24,8 @@ type database struct {
 var (
 	ErrOptimisticLockFailed = errors.New("Optimistic lock failed on database Root update")
 	ErrMergeNeeded          = errors.New("Dataset head is not ancestor of commit")
+	ErrDatasetNotFound      = errors.New("Dataset does not exist")
+	ErrDatasetExists        = errors.New("Dataset already exists")
 )
 
 // rootTracker is a narrowing of the ChunkStore interface, to keep Database disciplined about working directly with Chunks
@@ -43,6 +47,18 @@ func (db *database) chunkStore() chunks.ChunkStore {
 }
 
 func (db *database) Datasets() types.Map {
+	root := db.root()
+	for _, k := range []string{reflogKey, leasesKey} {
+		if root.Has(types.String(k)) {
+			root = root.Remove(types.String(k))
+		}
+	}
+	return root
+}
+
+// root returns the Map at the root of the database, which unlike Datasets()
+// includes the reflogs and leases.
+func (db *database) root() types.Map {
 	rootHash := db.rt.Root()
 	if rootHash.IsEmpty() {
 		return types.NewMap()
@@ -51,11 +67,26 @@ func (db *database) Datasets() types.Map {
 	return db.ReadValue(rootHash).(types.Map)
 }
 
+func (db *database) ListDatasets(prefix string) []string {
+	ids := []string{}
+	db.root().IterFrom(types.String(prefix), func(k, v types.Value) (stop bool) {
+		id := string(k.(types.String))
+		if !strings.HasPrefix(id, prefix) {
+			return true
+		}
+		if id != reflogKey && id != leasesKey {
+			ids = append(ids, id)
+		}
+		return false
+	})
+	return ids
+}
+
 func (db *database) GetDataset(datasetID string) Dataset {
 	if !DatasetFullRe.MatchString(datasetID) {
 		d.Panic("Invalid dataset ID: %s", datasetID)
 	}
-	if r, ok := db.Datasets().MaybeGet(types.String(datasetID)); ok {
+	if r, ok := db.root().MaybeGet(types.String(datasetID)); ok {
 		head := r.(types.Ref).TargetValue(db)
 		d.PanicIfFalse(IsCommit(head))
 		return Dataset{db, datasetID, types.NewRef(head)}
@@ -81,13 +112,45 @@ func (db *database) doSetHead(ds Dataset, newHeadRef types.Ref) error {
 	}
 	commit := db.validateRefAsCommit(newHeadRef)
 
-	currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
+	currentRootHash, currentDatasets := db.rt.Root(), db.root()
 	commitRef := db.WriteValue(commit) // will be orphaned if the tryCommitChunks() below fails
 
+	if r, hasHead := currentDatasets.MaybeGet(types.String(ds.ID())); hasHead {
+		currentDatasets = db.logHead(currentDatasets, ds.ID(), r.(types.Ref), "set-head")
+	}
 	currentDatasets = currentDatasets.Set(types.String(ds.ID()), types.ToRefOfValue(commitRef))
 	return db.tryCommitChunks(currentDatasets, currentRootHash)
 }
 
+func (db *database) replaceHead(ds Dataset, newHeadRef types.Ref, dropReflog bool) (Dataset, error) {
+	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doReplaceHead(ds, newHeadRef, dropReflog) })
+}
+
+// doReplaceHead is optimistic in the same way as doDelete. If the optimistic lock fails because someone changed the Head of ds, then the update fails. If it failed because someone changed a different Dataset, we try again.
+func (db *database) doReplaceHead(ds Dataset, newHeadRef types.Ref, dropReflog bool) error {
+	datasetID := types.String(ds.ID())
+	expectedHeadRef, _ := ds.MaybeHeadRef()
+	commit := db.validateRefAsCommit(newHeadRef)
+
+	for {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		r, hasHead := currentDatasets.MaybeGet(datasetID)
+		if !hasHead || r.(types.Ref).TargetHash() != expectedHeadRef.TargetHash() {
+			return ErrMergeNeeded
+		}
+		commitRef := db.WriteValue(commit) // will be orphaned if the tryCommitChunks() below fails
+		if dropReflog {
+			currentDatasets = db.dropReflog(currentDatasets, ds.ID())
+		} else {
+			currentDatasets = db.logHead(currentDatasets, ds.ID(), r.(types.Ref), "prune")
+		}
+		currentDatasets = currentDatasets.Set(datasetID, types.ToRefOfValue(commitRef))
+		if err := db.tryCommitChunks(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
+
 func (db *database) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
 	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doFastForward(ds, newHeadRef) })
 }
@@ -119,11 +182,14 @@ func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy
 	if !IsCommit(commit) {
 		d.Panic("Can't commit a non-Commit struct to dataset %s", datasetID)
 	}
+	if hcs, ok := db.chunkStore().(*httpChunkStore); ok && hcs.queueCommits {
+		return db.doQueuedCommit(hcs, datasetID, commit, mergePolicy)
+	}
 
 	// This could loop forever, given enough simultaneous committers. BUG 2565
 	var err error
 	for err = ErrOptimisticLockFailed; err == ErrOptimisticLockFailed; {
-		currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
 		commitRef := db.WriteValue(commit) // will be orphaned if the tryCommitChunks() below fails
 
 		// If there's nothing in the DB yet, skip all this logic.
@@ -147,12 +213,11 @@ func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy
 						return ErrMergeNeeded
 					}
 
-					ancestor, currentHead := db.validateRefAsCommit(ancestorRef), db.validateRefAsCommit(currentHeadRef)
-					merged, err := mergePolicy(commit.Get(ValueField), currentHead.Get(ValueField), ancestor.Get(ValueField), db, nil)
+					merged, err := db.mergeWithHead(commit, commitRef, currentHeadRef, ancestorRef, mergePolicy)
 					if err != nil {
 						return err
 					}
-					commitRef = db.WriteValue(NewCommit(merged, types.NewSet(commitRef, currentHeadRef), types.EmptyStruct))
+					commitRef = db.WriteValue(merged)
 				}
 			}
 		}
@@ -162,6 +227,44 @@ func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy
 	return err
 }
 
+// doQueuedCommit has the server behind |hcs| commit |commit| to |datasetID|.
+// The server applies commits one at a time, so unlike doCommit this needn't
+// retry when another writer updates the Root first. If |commit| isn't a
+// fast-forward and there's a |mergePolicy|, the merge is done here and sent
+// to the server in turn.
+func (db *database) doQueuedCommit(hcs *httpChunkStore, datasetID string, commit types.Struct, mergePolicy merge.Policy) error {
+	for {
+		commitRef := db.WriteValue(commit)
+		db.Flush()
+		err := hcs.commitOnServer(datasetID, commitRef.TargetHash())
+		if err != ErrMergeNeeded || mergePolicy == nil {
+			return err
+		}
+
+		r, hasHead := db.Datasets().MaybeGet(types.String(datasetID))
+		d.PanicIfFalse(hasHead)
+		currentHeadRef := types.NewRef(r.(types.Ref).TargetValue(db))
+		ancestorRef, found := FindCommonAncestor(commitRef, currentHeadRef, db)
+		if !found || currentHeadRef.TargetHash() == commitRef.TargetHash() {
+			return ErrMergeNeeded
+		}
+		if commit, err = db.mergeWithHead(commit, commitRef, currentHeadRef, ancestorRef, mergePolicy); err != nil {
+			return err
+		}
+	}
+}
+
+// mergeWithHead uses |mergePolicy| to merge |commit| with the dataset head
+// |currentHeadRef|, returning a Commit whose parents are both.
+func (db *database) mergeWithHead(commit types.Struct, commitRef, currentHeadRef, ancestorRef types.Ref, mergePolicy merge.Policy) (types.Struct, error) {
+	ancestor, currentHead := db.validateRefAsCommit(ancestorRef), db.validateRefAsCommit(currentHeadRef)
+	merged, err := mergePolicy(commit.Get(ValueField), currentHead.Get(ValueField), ancestor.Get(ValueField), db, nil)
+	if err != nil {
+		return types.Struct{}, err
+	}
+	return NewCommit(merged, types.NewSet(commitRef, currentHeadRef), types.EmptyStruct), nil
+}
+
 func (db *database) Delete(ds Dataset) (Dataset, error) {
 	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doDelete(ds.ID()) })
 }
@@ -169,7 +272,7 @@ func (db *database) Delete(ds Dataset) (Dataset, error) {
 // doDelete manages concurrent access the single logical piece of mutable state: the current Root. doDelete is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to Commit below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again.
 func (db *database) doDelete(datasetIDstr string) error {
 	datasetID := types.String(datasetIDstr)
-	currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
+	currentRootHash, currentDatasets := db.rt.Root(), db.root()
 	var initialHead types.Ref
 	if r, hasHead := currentDatasets.MaybeGet(datasetID); !hasHead {
 		return nil
@@ -179,13 +282,13 @@ func (db *database) doDelete(datasetIDstr string) error {
 
 	var err error
 	for {
-		currentDatasets = currentDatasets.Remove(datasetID)
+		currentDatasets = db.logHead(currentDatasets.Remove(datasetID), datasetIDstr, initialHead, "delete")
 		err = db.tryCommitChunks(currentDatasets, currentRootHash)
 		if err != ErrOptimisticLockFailed {
 			break
 		}
 		// If the optimistic lock failed because someone changed the Head of datasetID, then return ErrMergeNeeded. If it failed because someone changed a different Dataset, we should try again.
-		currentRootHash, currentDatasets = db.rt.Root(), db.Datasets()
+		currentRootHash, currentDatasets = db.rt.Root(), db.root()
 		if r, hasHead := currentDatasets.MaybeGet(datasetID); !hasHead || (hasHead && !initialHead.Equals(r)) {
 			err = ErrMergeNeeded
 			break
@@ -194,7 +297,50 @@ func (db *database) doDelete(datasetIDstr string) error {
 	return err
 }
 
+func (db *database) RenameDataset(ds Dataset, newDatasetID string) (Dataset, error) {
+	if !DatasetFullRe.MatchString(newDatasetID) {
+		d.Panic("Invalid dataset ID: %s", newDatasetID)
+	}
+	err := db.doRename(ds.ID(), newDatasetID)
+	return db.GetDataset(newDatasetID), err
+}
+
+// doRename is optimistic in the same way as doDelete. If the optimistic lock fails because someone changed the Head of either datasetID, then the rename fails. If it failed because someone changed a different Dataset, we try again.
+func (db *database) doRename(oldIDstr, newIDstr string) error {
+	oldID, newID := types.String(oldIDstr), types.String(newIDstr)
+	if oldID == newID {
+		return nil
+	}
+
+	currentRootHash, currentDatasets := db.rt.Root(), db.root()
+	r, hasHead := currentDatasets.MaybeGet(oldID)
+	if !hasHead {
+		return ErrDatasetNotFound
+	}
+	initialHead := r.(types.Ref)
+
+	var err error
+	for {
+		if currentDatasets.Has(newID) {
+			return ErrDatasetExists
+		}
+		currentDatasets = db.logHead(currentDatasets.Remove(oldID), oldIDstr, initialHead, "rename")
+		currentDatasets = currentDatasets.Set(newID, initialHead)
+		err = db.tryCommitChunks(currentDatasets, currentRootHash)
+		if err != ErrOptimisticLockFailed {
+			break
+		}
+		currentRootHash, currentDatasets = db.rt.Root(), db.root()
+		if r, hasHead := currentDatasets.MaybeGet(oldID); !hasHead || !initialHead.Equals(r) {
+			err = ErrMergeNeeded
+			break
+		}
+	}
+	return err
+}
+
 func (db *database) tryCommitChunks(currentDatasets types.Map, currentRootHash hash.Hash) (err error) {
+	currentDatasets = db.dropExpiredLeases(currentDatasets, time.Now())
 	newRootHash := db.WriteValue(currentDatasets).TargetHash()
 
 	db.Flush()
@@ -211,7 +357,10 @@ func (db *database) validateRefAsCommit(r types.Ref) types.Struct {
 	if v == nil {
 		panic(r.TargetHash().String() + " not found")
 	}
-	if !IsCommit(v) {
+	// The ValueStore keeps the types of the values it writes and types, so
+	// that validating the same Commit again, as FastForward and doCommit do,
+	// is cheap.
+	if !IsCommitType(db.TypeOf(v)) {
 		panic("Not a commit: " + types.EncodedValueMaxLines(v, 10) + "  ...\n")
 	}
 	return v.(types.Struct)
@@ -6,16 +6,36 @@ package datas
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// ServerRequestLatencyMetric names the metrics Histogram in which
+	// RemoteDatabaseServer records the latency of every request it handles.
+	ServerRequestLatencyMetric = "datas.RemoteDatabaseServer.RequestLatency"
+
+	// ServerBytesPerResponseMetric names the metrics Histogram in which
+	// RemoteDatabaseServer records the size of every response body it writes.
+	ServerBytesPerResponseMetric = "datas.RemoteDatabaseServer.BytesPerResponse"
+)
+
+// These are only sampled when metrics.Enabled().
+var (
+	serverRequestLatency   = metrics.RegisterTimeHistogram(ServerRequestLatencyMetric)
+	serverBytesPerResponse = metrics.RegisterByteHistogram(ServerBytesPerResponseMetric)
+)
+
 type connectionState struct {
 	c  net.Conn
 	cs http.ConnState
@@ -23,12 +43,18 @@ type connectionState struct {
 
 type RemoteDatabaseServer struct {
 	cs      chunks.ChunkStore
+	stores  *chunkStoreCache
 	port    int
 	l       *net.Listener
 	csChan  chan *connectionState
 	closing bool
 	// Called just before the server is started.
 	Ready func()
+	// QueueCommits, if set before Run is called, lets clients ask the server
+	// to commit to a dataset for them. The server applies such commits one at
+	// a time, so clients that commit concurrently don't have to retry when
+	// another one updates the root first.
+	QueueCommits bool
 }
 
 func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *RemoteDatabaseServer {
@@ -37,7 +63,26 @@ func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *RemoteDatabaseServ
 		d.Panic("SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
 	}
 	return &RemoteDatabaseServer{
-		cs, port, nil, make(chan *connectionState, 16), false, func() {},
+		cs:     cs,
+		port:   port,
+		csChan: make(chan *connectionState, 16),
+		Ready:  func() {},
+	}
+}
+
+// NewMultiRemoteDatabaseServer returns a RemoteDatabaseServer that hosts many
+// databases. The first component of a request's path names the database it
+// is for, so a client reaches database "foo" at http://<host>:<port>/foo.
+// Each database is opened by calling open the first time it is requested and
+// is kept open, separately from all others, until Stop is called. open should
+// return an error if there is no database with the given name, in which case
+// the request fails with 404 Not Found.
+func NewMultiRemoteDatabaseServer(open func(name string) (chunks.ChunkStore, error), port int) *RemoteDatabaseServer {
+	return &RemoteDatabaseServer{
+		stores: &chunkStoreCache{open: open, stores: map[string]chunks.ChunkStore{}},
+		port:   port,
+		csChan: make(chan *connectionState, 16),
+		Ready:  func() {},
 	}
 }
 
@@ -58,28 +103,8 @@ func (s *RemoteDatabaseServer) Run() {
 	d.Chk.NoError(err)
 	fmt.Printf("Listening on port %d...\n", s.port)
 
-	router := httprouter.New()
-
-	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
-	router.GET(constants.GetBlobPath, s.corsHandle(s.makeHandle(HandleGetBlob)))
-	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
-	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
-	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
-	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootGet)))
-	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
-	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
-	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
-	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
-	router.GET(constants.BasePath, s.corsHandle(s.makeHandle(HandleBaseGet)))
-
-	router.GET(constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
-	router.POST(constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
-	router.OPTIONS(constants.GraphQLPath, s.corsHandle(noopHandle))
-
 	srv := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			router.ServeHTTP(w, req)
-		}),
+		Handler:   s.handler(),
 		ConnState: s.connState,
 	}
 
@@ -102,15 +127,148 @@ func (s *RemoteDatabaseServer) Run() {
 	srv.Serve(l)
 }
 
+// ServeStdio serves a single client over |r| and |w|, rather than listening
+// on the port, and blocks until |r| reaches EOF. Like git's ssh transport, noms
+// serve --stdio uses it to serve a client that runs it over ssh, with |r| and
+// |w| the standard input and output of the process, so nothing else may write
+// to |w|. Stop should still be called afterwards, to close the database.
+func (s *RemoteDatabaseServer) ServeStdio(r io.Reader, w io.Writer) {
+	done := make(chan struct{})
+	var once sync.Once
+	conn := &streamConn{r, w, func() error {
+		once.Do(func() { close(done) })
+		return nil
+	}}
+	srv := &http.Server{Handler: s.handler()}
+	go s.Ready()
+	srv.Serve(&singleConnListener{conn, done})
+}
+
+func (s *RemoteDatabaseServer) handler() http.Handler {
+	router := httprouter.New()
+	if s.stores != nil {
+		s.route(router, "/:"+dbNameParam)
+	} else {
+		s.route(router, "")
+	}
+	return router
+}
+
+// route registers the handlers of the remote database protocol with router,
+// under prefix.
+func (s *RemoteDatabaseServer) route(router *httprouter.Router, prefix string) {
+	router.POST(prefix+constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
+	router.GET(prefix+constants.GetBlobPath, s.corsHandle(s.makeHandle(HandleGetBlob)))
+	router.OPTIONS(prefix+constants.GetRefsPath, s.corsHandle(noopHandle))
+	router.POST(prefix+constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
+	router.OPTIONS(prefix+constants.HasRefsPath, s.corsHandle(noopHandle))
+	rootGet := s.makeHandle(HandleRootGet)
+	if s.QueueCommits {
+		rootGet = advertiseCommitQueue(rootGet)
+		router.POST(prefix+constants.CommitPath, s.corsHandle(s.makeHandle(HandleCommit)))
+		router.OPTIONS(prefix+constants.CommitPath, s.corsHandle(noopHandle))
+	}
+	router.GET(prefix+constants.RootPath, s.corsHandle(rootGet))
+	router.POST(prefix+constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
+	router.OPTIONS(prefix+constants.RootPath, s.corsHandle(noopHandle))
+	router.POST(prefix+constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
+	router.OPTIONS(prefix+constants.WriteValuePath, s.corsHandle(noopHandle))
+	router.GET(prefix+constants.BasePath, s.corsHandle(s.makeHandle(HandleBaseGet)))
+
+	router.GET(prefix+constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
+	router.POST(prefix+constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
+	router.OPTIONS(prefix+constants.GraphQLPath, s.corsHandle(noopHandle))
+}
+
 func (s *RemoteDatabaseServer) makeHandle(hndlr Handler) httprouter.Handle {
 	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
-		hndlr(w, req, ps, s.cs)
+		if metrics.Enabled() {
+			t1 := time.Now()
+			cw := &countingResponseWriter{ResponseWriter: w}
+			defer func() {
+				serverRequestLatency.SampleTimeSince(t1)
+				if cw.written > 0 {
+					serverBytesPerResponse.Sample(cw.written)
+				}
+			}()
+			w = cw
+		}
+		cs := s.cs
+		if s.stores != nil {
+			var err error
+			if cs, err = s.stores.get(ps.ByName(dbNameParam)); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+// dbNameParam is the name of the path parameter that selects the database in
+// a server created by NewMultiRemoteDatabaseServer.
+const dbNameParam = "db"
+
+// chunkStoreCache opens the databases of a multi-database server on demand
+// and keeps them open until closeAll is called.
+type chunkStoreCache struct {
+	open   func(name string) (chunks.ChunkStore, error)
+	mu     sync.Mutex
+	stores map[string]chunks.ChunkStore
+}
+
+func (c *chunkStoreCache) get(name string) (chunks.ChunkStore, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cs, ok := c.stores[name]; ok {
+		return cs, nil
+	}
+	cs, err := c.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dataVersion := cs.Version(); constants.NomsVersion != dataVersion {
+		cs.Close()
+		return nil, fmt.Errorf("SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
+	}
+	c.stores[name] = cs
+	return cs, nil
+}
+
+func (c *chunkStoreCache) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, cs := range c.stores {
+		cs.Close()
+		closeCommitQueue(cs)
+		delete(c.stores, name)
 	}
 }
 
+// countingResponseWriter tallies the number of body bytes written through it.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written uint64
+}
+
+func (cw *countingResponseWriter) Write(p []byte) (int, error) {
+	n, err := cw.ResponseWriter.Write(p)
+	cw.written += uint64(n)
+	return n, err
+}
+
 func noopHandle(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 }
 
+// advertiseCommitQueue tells clients, in the response to root/ requests, that
+// they can send commits to commit/.
+func advertiseCommitQueue(f httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.Header().Set(commitQueueHeader, "1")
+		f(w, r, ps)
+	}
+}
+
 func (s *RemoteDatabaseServer) corsHandle(f httprouter.Handle) httprouter.Handle {
 	// TODO: Implement full pre-flighting?
 	// See: http://www.html5rocks.com/static/images/cors_server_flowchart.png
@@ -136,7 +294,14 @@ func (s *RemoteDatabaseServer) connState(c net.Conn, cs http.ConnState) {
 // Will cause the RemoteDatabaseServer to stop listening and an existing call to Run() to continue.
 func (s *RemoteDatabaseServer) Stop() {
 	s.closing = true
-	(*s.l).Close()
-	(s.cs).Close()
+	if s.l != nil {
+		(*s.l).Close()
+	}
+	if s.stores != nil {
+		s.stores.closeAll()
+	} else {
+		(s.cs).Close()
+		closeCommitQueue(s.cs)
+	}
 	close(s.csChan)
 }
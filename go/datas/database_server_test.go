@@ -0,0 +1,156 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMultiRemoteDatabaseServer(t *testing.T) {
+	assert := assert.New(t)
+
+	storages := map[string]*chunks.MemoryStorage{"a": {}, "b": {}}
+	opened := map[string]int{}
+	server := NewMultiRemoteDatabaseServer(func(name string) (chunks.ChunkStore, error) {
+		storage, ok := storages[name]
+		if !ok {
+			return nil, fmt.Errorf("Database not found: %s", name)
+		}
+		opened[name]++
+		return storage.NewView(), nil
+	}, 0)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+	defer server.Stop()
+
+	url := func(name string) string {
+		return fmt.Sprintf("http://localhost:%d/%s", server.Port(), name)
+	}
+
+	dbA := NewDatabase(NewHTTPChunkStore(url("a"), ""))
+	ds, err := dbA.CommitValue(dbA.GetDataset("ds"), types.String("in a"))
+	assert.NoError(err)
+	assert.True(types.String("in a").Equals(ds.HeadValue()))
+	dbA.Close()
+
+	// Each database is isolated from the others...
+	dbB := NewDatabase(NewHTTPChunkStore(url("b"), ""))
+	_, ok := dbB.GetDataset("ds").MaybeHead()
+	assert.False(ok)
+	dbB.Close()
+	assert.NotEqual(storages["a"].Root(), storages["b"].Root())
+
+	// ...and is opened only once, on demand.
+	dbA = NewDatabase(NewHTTPChunkStore(url("a"), ""))
+	assert.True(types.String("in a").Equals(dbA.GetDataset("ds").HeadValue()))
+	dbA.Close()
+	assert.Equal(map[string]int{"a": 1, "b": 1}, opened)
+
+	resp, err := http.Get(url("c") + "/root/")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRemoteDatabaseServerQueueCommits(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := &chunks.MemoryStorage{}
+	server := NewRemoteDatabaseServer(storage.NewView(), 0)
+	server.QueueCommits = true
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+	defer server.Stop()
+
+	url := fmt.Sprintf("http://localhost:%d", server.Port())
+	newDB := func() Database {
+		cs := NewHTTPChunkStore(url, "")
+		assert.True(cs.(*httpChunkStore).queueCommits)
+		return NewDatabase(cs)
+	}
+
+	// Concurrent commits to different datasets all go through.
+	const writers = 8
+	wg := sync.WaitGroup{}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db := newDB()
+			defer db.Close()
+			_, err := db.CommitValue(db.GetDataset(fmt.Sprintf("ds%d", i)), types.Number(i))
+			assert.NoError(err)
+		}(i)
+	}
+	wg.Wait()
+
+	db1, db2 := newDB(), newDB()
+	defer db1.Close()
+	defer db2.Close()
+	assert.Equal(writers, len(db1.ListDatasets("ds")))
+
+	// A commit that isn't a fast-forward is refused, unless it can be merged.
+	ds1 := db1.GetDataset("m")
+	ds1, err := db1.CommitValue(ds1, types.NewMap(types.String("a"), types.Number(1)))
+	assert.NoError(err)
+	db2.Rebase()
+	ds2 := db2.GetDataset("m")
+	ds1, err = db1.CommitValue(ds1, ds1.HeadValue().(types.Map).Set(types.String("b"), types.Number(2)))
+	assert.NoError(err)
+
+	edited := ds2.HeadValue().(types.Map).Set(types.String("c"), types.Number(3))
+	_, err = db2.CommitValue(ds2, edited)
+	assert.Equal(ErrMergeNeeded, err)
+	ds2, err = db2.Commit(ds2, edited, CommitOptions{Policy: merge.NewThreeWay(merge.None)})
+	assert.NoError(err)
+	assert.True(types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2), types.String("c"), types.Number(3)).Equals(ds2.HeadValue()))
+	assert.Equal(uint64(2), ds2.Head().Get(ParentsField).(types.Set).Len())
+	assert.Equal(ds2.HeadRef().TargetHash(), storageHead(storage, "m"))
+}
+
+func TestRemoteDatabaseServerStopDropsCommitQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	cs := (&chunks.MemoryStorage{}).NewView()
+	server := NewRemoteDatabaseServer(cs, 0)
+	server.QueueCommits = true
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+
+	db := NewDatabase(NewHTTPChunkStore(fmt.Sprintf("http://localhost:%d", server.Port()), ""))
+	_, err := db.CommitValue(db.GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	db.Close()
+
+	hasQueue := func() bool {
+		commitQueues.mu.Lock()
+		defer commitQueues.mu.Unlock()
+		_, ok := commitQueues.m[cs]
+		return ok
+	}
+	assert.True(hasQueue())
+	server.Stop()
+	assert.False(hasQueue())
+}
+
+func storageHead(storage *chunks.MemoryStorage, datasetID string) hash.Hash {
+	db := NewDatabase(storage.NewView())
+	return db.GetDataset(datasetID).HeadRef().TargetHash()
+}
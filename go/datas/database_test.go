@@ -298,6 +298,24 @@ func newOptsWithMerge(policy merge.ResolveFunc, parents ...types.Value) CommitOp
 	return CommitOptions{Parents: types.NewSet(parents...), Policy: merge.NewThreeWay(policy)}
 }
 
+func (suite *DatabaseSuite) TestListDatasets() {
+	suite.Empty(suite.db.ListDatasets(""))
+
+	ids := []string{"team", "team-b/app", "team/app/a", "team/app/b", "team/other", "zzz"}
+	for _, id := range ids {
+		_, err := suite.db.CommitValue(suite.db.GetDataset(id), types.String(id))
+		suite.NoError(err)
+	}
+
+	suite.Equal(ids, suite.db.ListDatasets(""))
+	suite.Equal([]string{"team", "team-b/app", "team/app/a", "team/app/b", "team/other"}, suite.db.ListDatasets("team"))
+	suite.Equal([]string{"team/app/a", "team/app/b", "team/other"}, suite.db.ListDatasets("team/"))
+	suite.Equal([]string{"team/app/a", "team/app/b"}, suite.db.ListDatasets("team/app/"))
+	suite.Equal([]string{"zzz"}, suite.db.ListDatasets("z"))
+	suite.Empty(suite.db.ListDatasets("team/nope"))
+	suite.Empty(suite.db.ListDatasets("a"))
+}
+
 func (suite *DatabaseSuite) TestDatabaseDelete() {
 	datasetID1, datasetID2 := "ds1", "ds2"
 	ds1, ds2 := suite.db.GetDataset(datasetID1), suite.db.GetDataset(datasetID2)
@@ -332,6 +350,70 @@ func (suite *DatabaseSuite) TestDatabaseDelete() {
 	suite.True(present, "Dataset %s should be present", datasetID2)
 }
 
+func (suite *DatabaseSuite) TestDatabaseRename() {
+	ds1, ds2 := suite.db.GetDataset("ds1"), suite.db.GetDataset("ds2")
+
+	// ds1: |a| <- |b|, ds2: |c|
+	var err error
+	ds1, err = suite.db.CommitValue(ds1, types.String("a"))
+	suite.NoError(err)
+	ds1, err = suite.db.CommitValue(ds1, types.String("b"))
+	suite.NoError(err)
+	ds2, err = suite.db.CommitValue(ds2, types.String("c"))
+	suite.NoError(err)
+	headRef := ds1.HeadRef()
+
+	renamed, err := suite.db.RenameDataset(ds1, "ds3")
+	suite.NoError(err)
+	suite.Equal("ds3", renamed.ID())
+	suite.Equal(headRef, renamed.HeadRef())
+	suite.Equal(uint64(2), renamed.HeadRef().Height())
+	_, present := suite.db.GetDataset("ds1").MaybeHead()
+	suite.False(present, "Dataset ds1 should not be present")
+
+	_, err = suite.db.RenameDataset(suite.db.GetDataset("ds1"), "ds4")
+	suite.Equal(ErrDatasetNotFound, err)
+
+	// Renaming onto an existing dataset leaves both alone.
+	ds2, err = suite.db.RenameDataset(renamed, "ds2")
+	suite.Equal(ErrDatasetExists, err)
+	suite.True(ds2.HeadValue().Equals(types.String("c")))
+	suite.Equal(headRef, suite.db.GetDataset("ds3").HeadRef())
+
+	// Get a fresh database, and verify the rename was persisted
+	newDB := suite.makeDb(suite.storage.NewView())
+	defer newDB.Close()
+	suite.Equal(uint64(2), newDB.Datasets().Len())
+	suite.Equal(headRef, newDB.GetDataset("ds3").HeadRef())
+
+	suite.Panics(func() { suite.db.RenameDataset(renamed, "inv@lid") })
+}
+
+func (suite *DatabaseSuite) TestRenameWithConcurrentChunkStoreUse() {
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+
+	// Craft DB that will allow me to move the backing ChunkStore while suite.db isn't looking
+	interloper := suite.makeDb(suite.storage.NewView())
+	defer interloper.Close()
+
+	// A concurrent change to another dataset doesn't block the rename.
+	_, concErr := interloper.CommitValue(interloper.GetDataset("ds2"), types.String("b"))
+	suite.NoError(concErr)
+	ds3, err := suite.db.RenameDataset(ds1, "ds3")
+	suite.NoError(err)
+	suite.True(ds3.HeadValue().Equals(types.String("a")))
+	suite.True(suite.db.GetDataset("ds2").HeadValue().Equals(types.String("b")))
+
+	// A concurrent change to the renamed dataset does.
+	interloper.Rebase()
+	_, concErr = interloper.CommitValue(interloper.GetDataset("ds3"), types.String("c"))
+	suite.NoError(concErr)
+	_, err = suite.db.RenameDataset(ds3, "ds4")
+	suite.Equal(ErrMergeNeeded, err)
+	suite.True(suite.db.GetDataset("ds3").HeadValue().Equals(types.String("c")))
+}
+
 type waitDuringUpdateRootChunkStore struct {
 	chunks.ChunkStore
 	preUpdateRootHook func()
@@ -22,6 +22,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/util/throttle"
 	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/golang/snappy"
 	"github.com/julienschmidt/httprouter"
@@ -31,6 +32,7 @@ const (
 	httpChunkSinkConcurrency = 6
 	writeBufferSize          = 1 << 12 // 4K
 	readBufferSize           = 1 << 12 // 4K
+	absentCacheSize          = 1 << 18 // 256K hashes
 )
 
 var customHTTPTransport = http.Transport{
@@ -45,6 +47,7 @@ type httpChunkStore struct {
 	httpClient   httpDoer
 	auth         string
 	getQueue     chan chunks.ReadRequest
+	getGroup     *chunks.GetGroup
 	hasQueue     chan chunks.ReadRequest
 	finishedChan chan struct{}
 	rateLimit    chan struct{}
@@ -54,17 +57,111 @@ type httpChunkStore struct {
 	cacheMu       *sync.RWMutex
 	unwrittenPuts *nbs.NomsBlockCache
 
+	// absent holds chunks that the server recently said it didn't have, so
+	// that repeated pushes to it needn't ask about them again.
+	absent *chunks.AbsentCache
+
 	rootMu  *sync.RWMutex
 	root    hash.Hash
 	version string
+
+	// packWrites is true if the server accepts writeValue/ bodies in the
+	// chunk pack format.
+	packWrites bool
+
+	// queueCommits is true if the server accepts commits at commit/.
+	queueCommits bool
+
+	// retries and retryBackoff configure doRead.
+	retries      int
+	retryBackoff time.Duration
+
+	verifyChunks bool
+	errMu        *sync.Mutex
+	err          error
+
+	contention *chunks.RootContentionSubscribers
+}
+
+// HTTPChunkStoreOptions configure an HTTP ChunkStore. The zero value gives
+// the defaults.
+type HTTPChunkStoreOptions struct {
+	// BytesPerSecond caps the combined rate at which request and response
+	// bodies are sent and received, e.g. so that background syncs don't
+	// saturate a production link. Zero means no limit.
+	BytesPerSecond uint64
+	// MaxConcurrentRequests caps the number of chunk requests in flight at
+	// once. The default is 6.
+	MaxConcurrentRequests int
+	// SkipChunkVerification trusts that the chunks the server sends hash to
+	// the addresses it sends them with, rather than checking. Only set it
+	// for trusted links; it saves hashing every chunk that is read.
+	SkipChunkVerification bool
+	// Retries is the number of times reads, which are idempotent, are
+	// retried when they fail to get a response or get a 502, 503 or 504,
+	// e.g. from a load balancer. Writes and commits are never retried. Zero
+	// means no retries, and it must not be negative.
+	Retries int
+	// RetryBackoff is how long to wait before the first retry. The wait
+	// doubles with each retry after it, up to a minute. The default is 1s.
+	RetryBackoff time.Duration
+	// RequestTimeout caps the time each request may take, including reading
+	// its response. Zero means no limit.
+	RequestTimeout time.Duration
+}
+
+const (
+	defaultRetryBackoff = time.Second
+	maxRetryBackoff     = time.Minute
+)
+
+// ChunkIntegrityError is the cause of the panic raised by reads from an HTTP
+// ChunkStore once the server has sent a chunk whose data doesn't hash to the
+// address it was sent with. Such a store fails all subsequent reads with the
+// same error. It can be recovered using d.Try(f, &ChunkIntegrityError{}).
+type ChunkIntegrityError struct {
+	// Server is the URL of the offending database, without credentials.
+	Server string
+	// Hash is the address the server sent the chunk with, and Actual the hash
+	// of its data.
+	Hash, Actual hash.Hash
+}
+
+func (e *ChunkIntegrityError) Error() string {
+	return fmt.Sprintf("Chunk %s received from %s is corrupt, its data hashes to %s", e.Hash, e.Server, e.Actual)
 }
 
 func NewHTTPChunkStore(baseURL, auth string) chunks.ChunkStore {
+	return NewHTTPChunkStoreWithOptions(baseURL, auth, HTTPChunkStoreOptions{})
+}
+
+// NewHTTPChunkStoreWithOptions returns an HTTP ChunkStore configured by opts.
+// It panics if opts.Retries is negative.
+func NewHTTPChunkStoreWithOptions(baseURL, auth string, opts HTTPChunkStoreOptions) chunks.ChunkStore {
 	// Custom http.Client to give control of idle connections and timeouts
-	return newHTTPChunkStoreWithClient(baseURL, auth, &http.Client{Transport: &customHTTPTransport})
+	client := &http.Client{Transport: &customHTTPTransport, Timeout: opts.RequestTimeout}
+	return newHTTPChunkStoreWithClientAndOptions(baseURL, auth, client, opts)
 }
 
 func newHTTPChunkStoreWithClient(baseURL, auth string, client httpDoer) *httpChunkStore {
+	return newHTTPChunkStoreWithClientAndOptions(baseURL, auth, client, HTTPChunkStoreOptions{})
+}
+
+func newHTTPChunkStoreWithClientAndOptions(baseURL, auth string, client httpDoer, opts HTTPChunkStoreOptions) *httpChunkStore {
+	if opts.Retries < 0 {
+		d.Panic("Retries must not be negative: %d", opts.Retries)
+	}
+	if opts.BytesPerSecond > 0 {
+		client = throttledDoer{client, throttle.NewLimiter(opts.BytesPerSecond)}
+	}
+	concurrency := httpChunkSinkConcurrency
+	if opts.MaxConcurrentRequests > 0 {
+		concurrency = opts.MaxConcurrentRequests
+	}
+	backoff := defaultRetryBackoff
+	if opts.RetryBackoff > 0 {
+		backoff = opts.RetryBackoff
+	}
 	u, err := url.Parse(baseURL)
 	d.PanicIfError(err)
 	if u.Scheme != "http" && u.Scheme != "https" {
@@ -75,16 +172,23 @@ func newHTTPChunkStoreWithClient(baseURL, auth string, client httpDoer) *httpChu
 		httpClient:    client,
 		auth:          auth,
 		getQueue:      make(chan chunks.ReadRequest, readBufferSize),
+		getGroup:      &chunks.GetGroup{},
 		hasQueue:      make(chan chunks.ReadRequest, readBufferSize),
 		finishedChan:  make(chan struct{}),
-		rateLimit:     make(chan struct{}, httpChunkSinkConcurrency),
+		rateLimit:     make(chan struct{}, concurrency),
 		requestWg:     &sync.WaitGroup{},
 		workerWg:      &sync.WaitGroup{},
 		cacheMu:       &sync.RWMutex{},
 		unwrittenPuts: nbs.NewCache(),
+		absent:        chunks.NewAbsentCache(absentCacheSize),
 		rootMu:        &sync.RWMutex{},
+		retries:       opts.Retries,
+		retryBackoff:  backoff,
+		verifyChunks:  !opts.SkipChunkVerification,
+		errMu:         &sync.Mutex{},
+		contention:    &chunks.RootContentionSubscribers{},
 	}
-	hcs.root, hcs.version = hcs.getRoot(false)
+	hcs.root, hcs.version, hcs.packWrites, hcs.queueCommits = hcs.getRoot(false, hash.Hash{})
 	hcs.batchGetRequests()
 	hcs.batchHasRequests()
 	return hcs
@@ -94,6 +198,75 @@ type httpDoer interface {
 	Do(req *http.Request) (resp *http.Response, err error)
 }
 
+// throttledDoer passes the bodies of requests and responses through a
+// throttle.Limiter.
+type throttledDoer struct {
+	httpDoer
+	l *throttle.Limiter
+}
+
+func (td throttledDoer) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body = td.l.ReadCloser(req.Body)
+	}
+	res, err := td.httpDoer.Do(req)
+	if err == nil {
+		res.Body = td.l.ReadCloser(res.Body)
+	}
+	return res, err
+}
+
+// doRead sends |req|, which must be idempotent, retrying it as configured by
+// HTTPChunkStoreOptions.Retries if it fails to get a response or gets one
+// that says the server, or a proxy in front of it, is briefly unavailable.
+func (hcs *httpChunkStore) doRead(req *http.Request) (*http.Response, error) {
+	backoff := hcs.retryBackoff
+	for i := 0; ; i++ {
+		res, err := hcs.httpClient.Do(req)
+		if i >= hcs.retries || !shouldRetry(res, err) {
+			return res, err
+		}
+		if err == nil {
+			closeResponse(res.Body)
+		}
+		verbose.Log("Retrying %s %s in %s: %s", req.Method, req.URL.Path, backoff, retryReason(res, err))
+		time.Sleep(backoff)
+		backoff = nextRetryBackoff(backoff)
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// nextRetryBackoff returns the wait before the retry after one that waited
+// |backoff|: twice as long, up to maxRetryBackoff.
+func nextRetryBackoff(backoff time.Duration) time.Duration {
+	if backoff >= maxRetryBackoff/2 {
+		return maxRetryBackoff
+	}
+	return backoff * 2
+}
+
+func shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func retryReason(res *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return res.Status
+}
+
 func (hcs *httpChunkStore) Version() string {
 	return hcs.version
 }
@@ -129,10 +302,16 @@ func (hcs *httpChunkStore) Get(h hash.Hash) chunks.Chunk {
 		return pending
 	}
 
-	ch := make(chan *chunks.Chunk)
-	hcs.requestWg.Add(1)
-	hcs.getQueue <- chunks.NewGetRequest(h, ch)
-	return *(<-ch)
+	// Concurrent Gets of the same chunk share a single request, since those
+	// that miss the batch it's sent in would otherwise each send another.
+	return hcs.getGroup.Get(h, func(h hash.Hash) chunks.Chunk {
+		ch := make(chan *chunks.Chunk)
+		hcs.requestWg.Add(1)
+		hcs.getQueue <- chunks.NewGetRequest(h, ch)
+		c := <-ch
+		hcs.panicIfReadFailed()
+		return *c
+	})
 }
 
 func (hcs *httpChunkStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
@@ -160,6 +339,25 @@ func (hcs *httpChunkStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks
 	hcs.requestWg.Add(1)
 	hcs.getQueue <- chunks.NewGetManyRequest(remaining, wg, foundChunks)
 	wg.Wait()
+	hcs.panicIfReadFailed()
+}
+
+// setReadFailed records err, unless an earlier error was already recorded, so
+// that reads fail with it on the calling goroutine.
+func (hcs *httpChunkStore) setReadFailed(err error) {
+	hcs.errMu.Lock()
+	defer hcs.errMu.Unlock()
+	if hcs.err == nil {
+		hcs.err = err
+	}
+}
+
+func (hcs *httpChunkStore) panicIfReadFailed() {
+	hcs.errMu.Lock()
+	defer hcs.errMu.Unlock()
+	if hcs.err != nil {
+		panic(d.Wrap(hcs.err))
+	}
 }
 
 func (hcs *httpChunkStore) batchGetRequests() {
@@ -175,11 +373,19 @@ func (hcs *httpChunkStore) Has(h hash.Hash) bool {
 	if checkCache(h) {
 		return true
 	}
+	root := hcs.Root()
+	if hcs.absent.Absent(root, hash.NewHashSet(h)).Has(h) {
+		return false
+	}
 
 	ch := make(chan bool)
 	hcs.requestWg.Add(1)
 	hcs.hasQueue <- chunks.NewHasRequest(h, ch)
-	return <-ch
+	has := <-ch
+	if !has {
+		hcs.absent.Insert(root, hash.NewHashSet(h))
+	}
+	return has
 }
 
 func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (present hash.HashSet) {
@@ -188,9 +394,11 @@ func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (present hash.HashSet) {
 		defer hcs.cacheMu.RUnlock()
 		present = hcs.unwrittenPuts.HasMany(hashes)
 	}()
+	root := hcs.Root()
+	absent := hcs.absent.Absent(root, hashes)
 	remaining := hash.HashSet{}
 	for h := range hashes {
-		if !present.Has(h) {
+		if !present.Has(h) && !absent.Has(h) {
 			remaining.Insert(h)
 		}
 	}
@@ -207,7 +415,9 @@ func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (present hash.HashSet) {
 
 	for found := range foundChunks {
 		present.Insert(found)
+		remaining.Remove(found)
 	}
+	hcs.absent.Insert(root, remaining)
 	return present
 }
 
@@ -281,11 +491,12 @@ func (hcs *httpChunkStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 	u.Path = httprouter.CleanPath(hcs.host.Path + constants.GetRefsPath)
 
 	req := newRequest("POST", hcs.auth, u.String(), buildHashesRequest(hashes), http.Header{
+		"Accept":          {chunkPackContentType + ", application/octet-stream"},
 		"Accept-Encoding": {"x-snappy-framed"},
 		"Content-Type":    {"application/x-www-form-urlencoded"},
 	})
 
-	res, err := hcs.httpClient.Do(req)
+	res, err := hcs.doRead(req)
 	d.Chk.NoError(err)
 	expectVersion(hcs.version, res)
 	reader := resBodyReader(res)
@@ -295,8 +506,19 @@ func (hcs *httpChunkStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
 	}
 
+	// Servers that predate chunk packs ignore the Accept header.
+	deserialize := chunks.Deserialize
+	if res.Header.Get("Content-Type") == chunkPackContentType {
+		deserialize = chunks.DeserializePack
+		if !hcs.verifyChunks {
+			deserialize = chunks.DeserializePackUnverified
+		}
+	} else if !hcs.verifyChunks {
+		deserialize = chunks.DeserializeUnverified
+	}
 	chunkChan := make(chan *chunks.Chunk, 16)
-	go func() { defer close(chunkChan); chunks.Deserialize(reader, chunkChan) }()
+	errChan := make(chan error, 1)
+	go func() { defer close(chunkChan); errChan <- deserialize(reader, chunkChan) }()
 
 	for c := range chunkChan {
 		h := c.Hash()
@@ -305,6 +527,12 @@ func (hcs *httpChunkStore) getRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 		}
 		delete(batch, c.Hash())
 	}
+
+	if hme, ok := (<-errChan).(*chunks.HashMismatchError); ok {
+		server := *hcs.host
+		server.User, server.RawQuery = nil, ""
+		hcs.setReadFailed(&ChunkIntegrityError{server.String(), hme.Hash, hme.Actual})
+	}
 }
 
 func (hcs *httpChunkStore) hasRefs(hashes hash.HashSet, batch chunks.ReadBatch) {
@@ -317,7 +545,7 @@ func (hcs *httpChunkStore) hasRefs(hashes hash.HashSet, batch chunks.ReadBatch)
 		"Content-Type":    {"application/x-www-form-urlencoded"},
 	})
 
-	res, err := hcs.httpClient.Do(req)
+	res, err := hcs.doRead(req)
 	d.Chk.NoError(err)
 	expectVersion(hcs.version, res)
 	reader := resBodyReader(res)
@@ -359,6 +587,7 @@ func resBodyReader(res *http.Response) (reader io.ReadCloser) {
 }
 
 func (hcs *httpChunkStore) Put(c chunks.Chunk) {
+	hcs.absent.Remove(c.Hash())
 	hcs.cacheMu.RLock()
 	defer hcs.cacheMu.RUnlock()
 	hcs.unwrittenPuts.Insert(c)
@@ -389,14 +618,18 @@ func (hcs *httpChunkStore) sendWriteRequests() {
 		close(chunkChan)
 	}()
 
-	body := buildWriteValueRequest(chunkChan)
+	body := buildWriteValueRequest(chunkChan, hcs.packWrites)
+	contentType := "application/octet-stream"
+	if hcs.packWrites {
+		contentType = chunkPackContentType
+	}
 	url := *hcs.host
 	url.Path = httprouter.CleanPath(hcs.host.Path + constants.WriteValuePath)
 	// TODO: Make this accept snappy encoding
 	req := newRequest("POST", hcs.auth, url.String(), body, http.Header{
 		"Accept-Encoding":  {"gzip"},
 		"Content-Encoding": {"x-snappy-framed"},
-		"Content-Type":     {"application/octet-stream"},
+		"Content-Type":     {contentType},
 	})
 
 	res, err := hcs.httpClient.Do(req)
@@ -416,49 +649,78 @@ func (hcs *httpChunkStore) Root() hash.Hash {
 	return hcs.root
 }
 
+// Rebase asks the server for its root, and is cheap when it hasn't changed:
+// the request is conditional on the root being different from the one this
+// store already has, so the server needn't send it back.
 func (hcs *httpChunkStore) Rebase() {
-	root, _ := hcs.getRoot(true)
+	root, _, _, _ := hcs.getRoot(true, hcs.Root())
 	hcs.rootMu.Lock()
 	defer hcs.rootMu.Unlock()
 	hcs.root = root
 }
 
-func (hcs *httpChunkStore) getRoot(checkVers bool) (root hash.Hash, vers string) {
-	// GET http://<host>/root. Response will be ref of root.
-	res := hcs.requestRoot("GET", hash.Hash{}, hash.Hash{})
+// getRoot asks the server for its root. If |known| isn't empty, the request
+// is conditional on the root having changed from |known|, which is returned
+// if it hasn't.
+func (hcs *httpChunkStore) getRoot(checkVers bool, known hash.Hash) (root hash.Hash, vers string, packWrites, queueCommits bool) {
+	// GET http://<host>/root. Response will be ref of root, or 304 if it's |known|.
+	header := http.Header{}
+	if !known.IsEmpty() {
+		header.Set("If-None-Match", rootETag(known))
+	}
+	res := hcs.requestRoot("GET", hash.Hash{}, hash.Hash{}, header)
 	if checkVers {
 		expectVersion(hcs.version, res)
 	}
 	defer closeResponse(res.Body)
 
-	if http.StatusOK != res.StatusCode {
+	vers, packWrites, queueCommits = res.Header.Get(NomsVersionHeader), acceptsChunkPack(res.Header.Get("Accept-Post")), res.Header.Get(commitQueueHeader) != ""
+	switch res.StatusCode {
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(res.Body)
+		d.PanicIfError(err)
+		root = hash.Parse(string(data))
+	case http.StatusNotModified:
+		root = known
+	default:
 		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
 	}
-	data, err := ioutil.ReadAll(res.Body)
-	d.PanicIfError(err)
-
-	return hash.Parse(string(data)), res.Header.Get(NomsVersionHeader)
+	return
 }
 
 func (hcs *httpChunkStore) Commit(current, last hash.Hash) bool {
+	success, winner := hcs.commit(current, last)
+	if !success {
+		hcs.contention.Notify(chunks.RootContention{Last: last, Attempted: current, Winner: winner})
+	}
+	return success
+}
+
+func (hcs *httpChunkStore) SubscribeRootContention(f func(chunks.RootContention)) (unsubscribe func()) {
+	return hcs.contention.Subscribe(f)
+}
+
+// commit does the work of Commit, returning whether it succeeded and the root
+// afterwards.
+func (hcs *httpChunkStore) commit(current, last hash.Hash) (bool, hash.Hash) {
 	hcs.rootMu.Lock()
 	defer hcs.rootMu.Unlock()
 	hcs.Flush()
 
 	// POST http://<host>/root?current=<ref>&last=<ref>. Response will be 200 on success, 409 if current is outdated.
-	res := hcs.requestRoot("POST", current, last)
+	res := hcs.requestRoot("POST", current, last, nil)
 	expectVersion(hcs.version, res)
 	defer closeResponse(res.Body)
 
 	switch res.StatusCode {
 	case http.StatusOK:
 		hcs.root = current
-		return true
+		return true, hcs.root
 	case http.StatusConflict:
 		data, err := ioutil.ReadAll(res.Body)
 		d.PanicIfError(err)
 		hcs.root = hash.Parse(string(data))
-		return false
+		return false, hcs.root
 	default:
 		buf := bytes.Buffer{}
 		buf.ReadFrom(res.Body)
@@ -467,11 +729,48 @@ func (hcs *httpChunkStore) Commit(current, last hash.Hash) bool {
 			fmt.Sprintf("Unexpected response: %s: %s",
 				http.StatusText(res.StatusCode),
 				body))
-		return false
+		return false, hcs.root
 	}
 }
 
-func (hcs *httpChunkStore) requestRoot(method string, current, last hash.Hash) *http.Response {
+// commitOnServer asks the server to commit the Commit |commit|, which must
+// already have been written, to the dataset |datasetID|. It returns
+// ErrMergeNeeded if the commit isn't a fast-forward. Either way, the root
+// is updated to the server's.
+func (hcs *httpChunkStore) commitOnServer(datasetID string, commit hash.Hash) error {
+	hcs.rootMu.Lock()
+	defer hcs.rootMu.Unlock()
+	hcs.Flush()
+
+	// POST http://<host>/commit?ds=<id>&commit=<ref>. Response will be the new root, with 409 if the commit isn't a fast-forward.
+	u := *hcs.host
+	u.Path = httprouter.CleanPath(hcs.host.Path + constants.CommitPath)
+	params := u.Query()
+	params.Add("ds", datasetID)
+	params.Add("commit", commit.String())
+	u.RawQuery = params.Encode()
+
+	res, err := hcs.httpClient.Do(newRequest("POST", hcs.auth, u.String(), nil, nil))
+	d.PanicIfError(err)
+	expectVersion(hcs.version, res)
+	defer closeResponse(res.Body)
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusConflict:
+		data, err := ioutil.ReadAll(res.Body)
+		d.PanicIfError(err)
+		hcs.root = hash.Parse(string(data))
+		if res.StatusCode == http.StatusConflict {
+			return ErrMergeNeeded
+		}
+		return nil
+	default:
+		d.Panic("Unexpected response: %s", formatErrorResponse(res))
+		return nil
+	}
+}
+
+func (hcs *httpChunkStore) requestRoot(method string, current, last hash.Hash, header http.Header) *http.Response {
 	u := *hcs.host
 	u.Path = httprouter.CleanPath(hcs.host.Path + constants.RootPath)
 	if method == "POST" {
@@ -481,9 +780,13 @@ func (hcs *httpChunkStore) requestRoot(method string, current, last hash.Hash) *
 		u.RawQuery = params.Encode()
 	}
 
-	req := newRequest(method, hcs.auth, u.String(), nil, nil)
+	req := newRequest(method, hcs.auth, u.String(), nil, header)
 
-	res, err := hcs.httpClient.Do(req)
+	do := hcs.httpClient.Do
+	if method == "GET" {
+		do = hcs.doRead
+	}
+	res, err := do(req)
 	d.PanicIfError(err)
 
 	return res
@@ -5,11 +5,15 @@
 package datas
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
@@ -152,6 +156,32 @@ func (suite *HTTPChunkStoreSuite) TestPutChunk() {
 	suite.Equal(1, suite.serverCS.Writes)
 }
 
+func (suite *HTTPChunkStoreSuite) TestThrottled() {
+	const rate = 32 << 10
+	unthrottled := suite.http
+	defer unthrottled.Close()
+	suite.http = newHTTPChunkStoreWithClientAndOptions("http://localhost:9000", "", unthrottled.httpClient, HTTPChunkStoreOptions{BytesPerSecond: rate, MaxConcurrentRequests: 1})
+	suite.Equal(1, cap(suite.http.rateLimit))
+
+	// The first second's worth of bytes is free, the rest has to wait. Random
+	// data doesn't compress much, even base64 encoded.
+	data := make([]byte, rate*3/2)
+	rand.Read(data)
+	c := types.EncodeValue(types.String(base64.StdEncoding.EncodeToString(data)), nil)
+	start := time.Now()
+	suite.http.Put(c)
+	suite.http.Flush()
+	suite.True(time.Since(start) >= time.Second/4, "took %s", time.Since(start))
+	suite.Equal(1, suite.serverCS.Writes)
+
+	// Responses are throttled too.
+	store := newHTTPChunkStoreWithClientAndOptions("http://localhost:9000", "", unthrottled.httpClient, HTTPChunkStoreOptions{BytesPerSecond: rate})
+	defer store.Close()
+	start = time.Now()
+	suite.Equal(c.Data(), store.Get(c.Hash()).Data())
+	suite.True(time.Since(start) >= time.Second/4, "took %s", time.Since(start))
+}
+
 func (suite *HTTPChunkStoreSuite) TestPutChunksInOrder() {
 	vals := []types.Value{
 		types.String("abc"),
@@ -168,6 +198,20 @@ func (suite *HTTPChunkStoreSuite) TestPutChunksInOrder() {
 	suite.Equal(3, suite.serverCS.Writes)
 }
 
+func (suite *HTTPChunkStoreSuite) TestPutChunksUnpacked() {
+	// As if the server predated chunk packs.
+	suite.True(suite.http.packWrites)
+	suite.http.packWrites = false
+
+	l := types.NewList(types.NewRef(types.String("abc")))
+	suite.http.Put(types.EncodeValue(types.String("abc"), nil))
+	suite.http.Put(types.EncodeValue(l, nil))
+	suite.http.Flush()
+
+	suite.Equal(2, suite.serverCS.Writes)
+	suite.True(suite.serverCS.Has(l.Hash()))
+}
+
 func (suite *HTTPChunkStoreSuite) TestRebase() {
 	suite.Equal(hash.Hash{}, suite.http.Root())
 	c := types.EncodeValue(types.NewMap(), nil)
@@ -179,6 +223,111 @@ func (suite *HTTPChunkStoreSuite) TestRebase() {
 	suite.Equal(c.Hash(), suite.serverCS.Root())
 }
 
+// statusRecorder records the status codes of the responses to requests.
+type statusRecorder struct {
+	httpDoer
+	codes []int
+}
+
+func (sr *statusRecorder) Do(req *http.Request) (*http.Response, error) {
+	res, err := sr.httpDoer.Do(req)
+	if err == nil {
+		sr.codes = append(sr.codes, res.StatusCode)
+	}
+	return res, err
+}
+
+func (suite *HTTPChunkStoreSuite) TestRebaseNotModified() {
+	c := types.EncodeValue(types.NewMap(), nil)
+	suite.serverCS.Put(c)
+	suite.True(suite.serverCS.Commit(c.Hash(), hash.Hash{}))
+	suite.http.Rebase()
+	suite.Equal(c.Hash(), suite.http.Root())
+
+	sr := &statusRecorder{httpDoer: suite.http.httpClient}
+	suite.http.httpClient = sr
+	suite.http.Rebase()
+	suite.Equal(c.Hash(), suite.http.Root())
+	suite.Equal([]int{http.StatusNotModified}, sr.codes)
+
+	c2 := types.EncodeValue(types.NewMap(types.Bool(true), types.Bool(true)), nil)
+	suite.serverCS.Put(c2)
+	suite.True(suite.serverCS.Commit(c2.Hash(), c.Hash()))
+	suite.http.Rebase()
+	suite.Equal(c2.Hash(), suite.http.Root())
+	suite.Equal([]int{http.StatusNotModified, http.StatusOK}, sr.codes)
+}
+
+// flakyDoer fails the next |failures| requests with a 502, as a load
+// balancer might.
+type flakyDoer struct {
+	httpDoer
+	failures int
+	requests int
+}
+
+func (fd *flakyDoer) Do(req *http.Request) (*http.Response, error) {
+	fd.requests++
+	if fd.failures == 0 {
+		return fd.httpDoer.Do(req)
+	}
+	fd.failures--
+	if req.Body != nil {
+		ioutil.ReadAll(req.Body)
+	}
+	return &http.Response{
+		StatusCode: http.StatusBadGateway,
+		Status:     http.StatusText(http.StatusBadGateway),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(&bytes.Buffer{}),
+	}, nil
+}
+
+func (suite *HTTPChunkStoreSuite) TestRetries() {
+	c := types.EncodeValue(types.String("abc"), nil)
+	suite.serverCS.Put(c)
+	suite.True(suite.serverCS.Commit(c.Hash(), hash.Hash{}))
+
+	flaky := &flakyDoer{httpDoer: suite.http.httpClient}
+	store := newHTTPChunkStoreWithClientAndOptions("http://localhost:9000", "", flaky, HTTPChunkStoreOptions{Retries: 2, RetryBackoff: time.Millisecond})
+	defer store.Close()
+
+	// Reads are retried, with their bodies.
+	flaky.failures, flaky.requests = 2, 0
+	suite.Equal(c.Data(), store.Get(c.Hash()).Data())
+	suite.Equal(3, flaky.requests)
+	flaky.failures, flaky.requests = 2, 0
+	suite.True(store.Has(c.Hash()))
+	suite.Equal(3, flaky.requests)
+	flaky.failures, flaky.requests = 2, 0
+	store.Rebase()
+	suite.Equal(c.Hash(), store.Root())
+	suite.Equal(3, flaky.requests)
+
+	// But only as many times as configured.
+	flaky.failures, flaky.requests = 3, 0
+	suite.Panics(store.Rebase)
+	suite.Equal(3, flaky.requests)
+
+	// Writes aren't retried.
+	flaky.failures, flaky.requests = 1, 0
+	store.Put(types.EncodeValue(types.String("def"), nil))
+	suite.Panics(store.Flush)
+	suite.Equal(1, flaky.requests)
+
+	suite.Panics(func() {
+		newHTTPChunkStoreWithClientAndOptions("http://localhost:9000", "", flaky, HTTPChunkStoreOptions{Retries: -1})
+	})
+}
+
+func TestNextRetryBackoff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(2*time.Second, nextRetryBackoff(time.Second))
+	assert.Equal(40*time.Second, nextRetryBackoff(20*time.Second))
+	assert.Equal(maxRetryBackoff, nextRetryBackoff(40*time.Second))
+	assert.Equal(maxRetryBackoff, nextRetryBackoff(maxRetryBackoff))
+}
+
 func (suite *HTTPChunkStoreSuite) TestRoot() {
 	c := types.EncodeValue(types.NewMap(), nil)
 	suite.serverCS.Put(c)
@@ -340,6 +489,29 @@ func (suite *HTTPChunkStoreSuite) TestHasMany() {
 	suite.False(present.Has(notPresent))
 }
 
+func (suite *HTTPChunkStoreSuite) TestHasManyRemembersAbsent() {
+	c := types.EncodeValue(types.NewMap(), nil)
+	hashes := hash.NewHashSet(c.Hash())
+	suite.Empty(suite.http.HasMany(hashes))
+
+	// Until the root changes, the server isn't asked again.
+	suite.serverCS.Put(c)
+	persistChunks(suite.serverCS)
+	suite.Empty(suite.http.HasMany(hashes))
+	suite.False(suite.http.Has(c.Hash()))
+
+	suite.True(suite.serverCS.Commit(c.Hash(), suite.serverCS.Root()))
+	suite.http.Rebase()
+	suite.Equal(hashes, suite.http.HasMany(hashes))
+
+	// Chunks that are written are forgotten about too.
+	d := types.EncodeValue(types.String("abc"), nil)
+	suite.False(suite.http.Has(d.Hash()))
+	suite.http.Put(d)
+	suite.http.Flush()
+	suite.True(suite.http.Has(d.Hash()))
+}
+
 func (suite *HTTPChunkStoreSuite) TestHasManyAllCached() {
 	chnx := []chunks.Chunk{
 		chunks.NewChunk([]byte("abc")),
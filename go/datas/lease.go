@@ -0,0 +1,193 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/random"
+)
+
+// leasesKey is the key of the root Map under which the leases are kept, as a
+// Ref to a Commit whose value is a Map<String, Lease> keyed by lease ID. Like
+// reflogKey, it isn't a valid Dataset ID.
+const leasesKey = "$leases"
+
+const (
+	leaseTargetField  = "target"
+	leaseExpiresField = "expires"
+)
+
+var leaseTemplate = types.MakeStructTemplate("Lease", []string{leaseExpiresField, leaseTargetField})
+
+// ErrLeaseExpired is returned by RenewLease when the lease has expired, or
+// was released, so that what it protected may have been collected.
+var ErrLeaseExpired = errors.New("Lease has expired")
+
+// Lease protects the chunks reachable from Target, e.g. a root or a Commit
+// that a long-running reader is traversing, from garbage collection until
+// Expires, even once no Dataset reaches them. Leases are kept in the root of
+// the Database, so they keep their targets reachable, and expired leases are
+// dropped from it whenever the root is next updated, e.g. by Commit, SetHead
+// or Delete. Readers should renew their leases well before they expire, and
+// release them when they're done.
+type Lease struct {
+	// ID identifies the lease to RenewLease and ReleaseLease.
+	ID string
+	// Target is a Ref to the value whose chunks are protected.
+	Target types.Ref
+	// Expires is when the lease stops protecting Target.
+	Expires time.Time
+}
+
+func (l Lease) value() types.Value {
+	return leaseTemplate.NewStruct([]types.Value{
+		types.String(l.Expires.Format(time.RFC3339Nano)),
+		l.Target,
+	})
+}
+
+func readLease(id string, v types.Value) Lease {
+	s := v.(types.Struct)
+	expires, err := time.Parse(time.RFC3339Nano, string(s.Get(leaseExpiresField).(types.String)))
+	d.PanicIfError(err)
+	return Lease{id, s.Get(leaseTargetField).(types.Ref), expires}
+}
+
+// AcquireLease adds a lease on |target|, which must be in |db|, that expires
+// after |ttl|, which must be positive.
+func AcquireLease(db Database, target types.Ref, ttl time.Duration) (lease Lease, err error) {
+	if ttl <= 0 {
+		return Lease{}, fmt.Errorf("Lease TTL must be positive, not %s", ttl)
+	}
+	if db.ReadValue(target.TargetHash()) == nil {
+		return Lease{}, fmt.Errorf("Lease target %s not found", target.TargetHash())
+	}
+	err = db.updateLeases(func(leases types.Map, now time.Time) (types.Map, error) {
+		lease = Lease{random.Id(), target, now.Add(ttl)}
+		return leases.Set(types.String(lease.ID), lease.value()), nil
+	})
+	return
+}
+
+// RenewLease extends |lease| so that it expires after |ttl| from now, and
+// returns the renewed lease. If it has expired or been released already,
+// ErrLeaseExpired is returned, and the reader should start again from a
+// new lease.
+func RenewLease(db Database, lease Lease, ttl time.Duration) (renewed Lease, err error) {
+	if ttl <= 0 {
+		return Lease{}, fmt.Errorf("Lease TTL must be positive, not %s", ttl)
+	}
+	err = db.updateLeases(func(leases types.Map, now time.Time) (types.Map, error) {
+		if !leases.Has(types.String(lease.ID)) {
+			return leases, ErrLeaseExpired
+		}
+		renewed = Lease{lease.ID, lease.Target, now.Add(ttl)}
+		return leases.Set(types.String(lease.ID), renewed.value()), nil
+	})
+	return
+}
+
+// ReleaseLease removes |lease|, so that it no longer protects its target.
+// Releasing a lease that has expired does nothing.
+func ReleaseLease(db Database, lease Lease) error {
+	return db.updateLeases(func(leases types.Map, now time.Time) (types.Map, error) {
+		return leases.Remove(types.String(lease.ID)), nil
+	})
+}
+
+// Leases returns the leases in |db| that haven't expired by |now|, in order
+// of expiry. A garbage collector must keep the chunks reachable from their
+// targets, which it does anyway if it keeps those reachable from the root.
+// Until the root is next updated, that also keeps the targets of leases that
+// have expired since.
+func Leases(db Database, now time.Time) []Lease {
+	root := types.NewMap()
+	if h := db.chunkStore().Root(); !h.IsEmpty() {
+		root = db.ReadValue(h).(types.Map)
+	}
+	leases := []Lease{}
+	readLeases(db, root).IterAll(func(k, v types.Value) {
+		if l := readLease(string(k.(types.String)), v); l.Expires.After(now) {
+			leases = append(leases, l)
+		}
+	})
+	sort.Sort(leasesByExpiry(leases))
+	return leases
+}
+
+type leasesByExpiry []Lease
+
+func (ls leasesByExpiry) Len() int           { return len(ls) }
+func (ls leasesByExpiry) Less(i, j int) bool { return ls[i].Expires.Before(ls[j].Expires) }
+func (ls leasesByExpiry) Swap(i, j int)      { ls[i], ls[j] = ls[j], ls[i] }
+
+// readLeases returns the leases kept in |root|, keyed by lease ID.
+func readLeases(vr types.ValueReader, root types.Map) types.Map {
+	r, ok := root.MaybeGet(types.String(leasesKey))
+	if !ok {
+		return types.NewMap()
+	}
+	return r.(types.Ref).TargetValue(vr).(types.Struct).Get(ValueField).(types.Map)
+}
+
+// unexpiredLeases returns |leases| without those that have expired by |now|.
+func unexpiredLeases(leases types.Map, now time.Time) types.Map {
+	unexpired := leases
+	leases.IterAll(func(k, v types.Value) {
+		if !readLease(string(k.(types.String)), v).Expires.After(now) {
+			unexpired = unexpired.Remove(k)
+		}
+	})
+	return unexpired
+}
+
+// setLeases returns |root| with its leases replaced by |leases|.
+func (db *database) setLeases(root types.Map, leases types.Map) types.Map {
+	if leases.Empty() {
+		return root.Remove(types.String(leasesKey))
+	}
+	commit := NewCommit(leases, types.NewSet(), types.EmptyStruct)
+	return root.Set(types.String(leasesKey), types.ToRefOfValue(db.WriteValue(commit)))
+}
+
+// dropExpiredLeases returns |root| without the leases that have expired by
+// |now|, so that they stop keeping their targets reachable. tryCommitChunks
+// applies it to every root it commits.
+func (db *database) dropExpiredLeases(root types.Map, now time.Time) types.Map {
+	current := readLeases(db, root)
+	if leases := unexpiredLeases(current, now); !leases.Equals(current) {
+		return db.setLeases(root, leases)
+	}
+	return root
+}
+
+// updateLeases replaces the leases in the root with those that |update|
+// returns, given the current ones without those that have expired by |now|.
+// It's optimistic in the same way as doDelete, and retries until the root is
+// committed, or update returns an error.
+func (db *database) updateLeases(update func(leases types.Map, now time.Time) (types.Map, error)) error {
+	for {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		now := time.Now()
+		current := readLeases(db, currentDatasets)
+		leases, err := update(unexpiredLeases(current, now), now)
+		if err != nil {
+			return err
+		}
+		if leases.Equals(current) {
+			return nil
+		}
+		currentDatasets = db.setLeases(currentDatasets, leases)
+		if err := db.tryCommitChunks(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestLeases(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	a := ds.HeadRef()
+	assert.Empty(Leases(db, time.Now()))
+
+	lease, err := AcquireLease(db, a, time.Hour)
+	assert.NoError(err)
+	assert.NotEmpty(lease.ID)
+	assert.Equal(a, lease.Target)
+	leases := Leases(db, time.Now())
+	assert.Len(leases, 1)
+	assert.Equal(lease.ID, leases[0].ID)
+	assert.True(lease.Expires.Equal(leases[0].Expires))
+	assert.Empty(Leases(db, lease.Expires))
+
+	// The lease keeps |a| reachable from the root once the Dataset drops it,
+	// but isn't a Dataset itself.
+	_, err = db.Delete(ds)
+	assert.NoError(err)
+	assert.Equal(uint64(0), db.Datasets().Len())
+	assert.Empty(db.ListDatasets(""))
+	assert.True(db.(*database).root().Has(types.String(leasesKey)))
+
+	renewed, err := RenewLease(db, lease, 2*time.Hour)
+	assert.NoError(err)
+	assert.Equal(lease.ID, renewed.ID)
+	assert.True(renewed.Expires.After(lease.Expires))
+	assert.Len(Leases(db, lease.Expires), 1)
+
+	// Expired leases can't be renewed, and are dropped when the root is next
+	// updated.
+	short, err := AcquireLease(db, a, 50*time.Millisecond)
+	assert.NoError(err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = RenewLease(db, short, time.Hour)
+	assert.Equal(ErrLeaseExpired, err)
+	assert.Len(Leases(db, time.Now()), 1)
+	assert.Equal(uint64(2), readLeases(db, db.(*database).root()).Len())
+	_, err = db.CommitValue(db.GetDataset("other"), types.String("b"))
+	assert.NoError(err)
+	assert.Equal(uint64(1), readLeases(db, db.(*database).root()).Len())
+
+	assert.NoError(ReleaseLease(db, renewed))
+	assert.NoError(ReleaseLease(db, renewed))
+	assert.Empty(Leases(db, time.Now()))
+	assert.False(db.(*database).root().Has(types.String(leasesKey)))
+	_, err = RenewLease(db, renewed, time.Hour)
+	assert.Equal(ErrLeaseExpired, err)
+
+	_, err = AcquireLease(db, types.NewRef(types.String("missing")), time.Hour)
+	assert.Error(err)
+	_, err = AcquireLease(db, a, 0)
+	assert.Error(err)
+	_, err = RenewLease(db, renewed, -time.Second)
+	assert.Error(err)
+}
@@ -0,0 +1,73 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// OversizedChunk is a chunk that FindOversizedChunks found to be larger than
+// the maximum it was given.
+type OversizedChunk struct {
+	Hash hash.Hash
+	// Size is the length of the chunk's data in bytes.
+	Size uint64
+	// Kind is the kind of the value the chunk encodes. Oversized chunks are
+	// usually Blobs or Strings that were written without being chunked.
+	Kind types.NomsKind
+}
+
+// oversizedScanBatchSize caps the number of chunks FindOversizedChunks asks
+// for at once.
+const oversizedScanBatchSize = 1 << 12
+
+// FindOversizedChunks calls |cb| for each chunk that's reachable from the
+// root of |db| and whose data is more than |max| bytes long, e.g. to find
+// the chunks that keep a database from being copied to a backend that can't
+// store them. See chunks.NewSizeLimitedChunkStore to keep them from being
+// written in the first place.
+//
+// Each reachable chunk is read once, breadth first. The hashes of those that
+// have been read are kept in memory until FindOversizedChunks returns.
+func FindOversizedChunks(db Database, max uint64, cb func(oc OversizedChunk)) {
+	cs := db.chunkStore()
+	root := cs.Root()
+	if root.IsEmpty() {
+		return
+	}
+
+	visited := hash.HashSet{root: struct{}{}}
+	next := []hash.Hash{root}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for len(current) > 0 {
+			n := len(current)
+			if n > oversizedScanBatchSize {
+				n = oversizedScanBatchSize
+			}
+			batch := hash.NewHashSet(current[:n]...)
+			current = current[n:]
+
+			found := make(chan *chunks.Chunk, len(batch))
+			cs.GetMany(batch, found)
+			close(found)
+			for c := range found {
+				v := types.DecodeValue(*c, db)
+				if size := uint64(len(c.Data())); size > max {
+					cb(OversizedChunk{c.Hash(), size, v.Kind()})
+				}
+				for _, h := range refHashes(v) {
+					if !visited.Has(h) {
+						visited.Insert(h)
+						next = append(next, h)
+					}
+				}
+			}
+		}
+	}
+}
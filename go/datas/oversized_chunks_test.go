@@ -0,0 +1,47 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestFindOversizedChunks(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	find := func(max uint64) (found []OversizedChunk) {
+		FindOversizedChunks(db, max, func(oc OversizedChunk) {
+			found = append(found, oc)
+		})
+		return
+	}
+	assert.Empty(find(1))
+
+	// Strings aren't chunked, but Blobs are.
+	big := types.String(strings.Repeat("big", 1<<12))
+	data := make([]byte, 1<<16)
+	rand.New(rand.NewSource(0)).Read(data)
+	blob := types.NewBlob(bytes.NewReader(data))
+	v := types.NewStruct("", types.StructData{
+		"big":  db.WriteValue(big),
+		"blob": db.WriteValue(blob),
+	})
+	_, err := db.CommitValue(db.GetDataset("ds"), v)
+	assert.NoError(err)
+
+	bigSize := uint64(len(types.EncodeValue(big, db).Data()))
+	assert.Equal([]OversizedChunk{{big.Hash(), bigSize, types.StringKind}}, find(bigSize-1))
+	assert.Empty(find(bigSize))
+	assert.True(len(find(1)) > 5)
+}
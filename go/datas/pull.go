@@ -9,9 +9,11 @@ import (
 	"math/rand"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/golang/snappy"
 )
@@ -22,6 +24,13 @@ type PullProgress struct {
 
 const bytesWrittenSampleRate = .10
 
+// These are only sampled when metrics.Enabled().
+var (
+	pullLatency   = metrics.RegisterTimeHistogram("datas.Pull.Latency")
+	chunksPerPull = metrics.RegisterHistogram("datas.Pull.ChunksPerPull")
+	bytesPerPull  = metrics.RegisterByteHistogram("datas.Pull.BytesPerPull")
+)
+
 // PullWithFlush calls Pull and then manually flushes data to sinkDB. This is
 // an unfortunate current necessity. The Flush() can't happen at the end of
 // regular Pull() because that breaks tests that try to ensure we're not
@@ -37,18 +46,63 @@ func PullWithFlush(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, con
 // Pull objects that descend from sourceRef from srcDB to sinkDB. sinkHeadRef
 // should point to a Commit (in sinkDB) that's an ancestor of sourceRef. This
 // allows the algorithm to figure out which portions of data are already
-// present in sinkDB and skip copying them.
+// present in sinkDB and skip copying them. If sourceRef is a Commit, the
+// Commits in its history that sinkDB already has are found first, a
+// generation at a time, and used the same way, so that little needs to be
+// asked of sinkDB when it's only a few Commits behind, whatever sinkHeadRef.
 func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) {
-	srcQ, sinkQ := &types.RefByHeight{sourceRef}, &types.RefByHeight{sinkHeadRef}
+	PullMany(srcDB, sinkDB, types.RefSlice{sourceRef}, sinkHeadRef, concurrency, progressCh)
+}
 
-	// If the sourceRef points to an object already in sinkDB, there's nothing to do.
-	if sinkDB.chunkStore().Has(sourceRef.TargetHash()) {
+// PullMany pulls objects that descend from any of sourceRefs from srcDB to
+// sinkDB, as Pull does for one. The chunks that several of them share are
+// walked, and copied, only once, and sinkDB is asked which of sourceRefs it
+// has already with a single HasMany.
+func PullMany(srcDB, sinkDB Database, sourceRefs types.RefSlice, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) {
+	srcQ, sinkQ := &types.RefByHeight{}, &types.RefByHeight{}
+
+	// The sourceRefs that point to objects already in sinkDB need nothing done.
+	hashes := hash.HashSet{}
+	for _, r := range sourceRefs {
+		hashes.Insert(r.TargetHash())
+	}
+	present := sinkDB.chunkStore().HasMany(hashes)
+	for _, r := range sourceRefs {
+		if !present.Has(r.TargetHash()) {
+			srcQ.PushBack(r)
+		}
+	}
+	if srcQ.Empty() {
 		return
 	}
+	sort.Sort(srcQ)
+	srcQ.Unique()
 
-	// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just clear out sinkQ right now and don't bother.
-	if !srcDB.chunkStore().Has(sinkHeadRef.TargetHash()) {
-		sinkQ.PopBack()
+	// We generally expect that sourceRefs descend from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then don't bother.
+	if srcDB.chunkStore().Has(sinkHeadRef.TargetHash()) {
+		sinkQ.PushBack(sinkHeadRef)
+	}
+	for _, r := range commitFrontiers(srcDB, sinkDB, types.RefSlice(*srcQ)) {
+		sinkQ.PushBack(r)
+	}
+	sort.Sort(sinkQ)
+	sinkQ.Unique()
+	// sinkDB has all of each of these Commits, so there's no need to look at their parents.
+	sinkHeads := hash.HashSet{}
+	for _, r := range *sinkQ {
+		sinkHeads.Insert(r.TargetHash())
+	}
+
+	var chunksPulled, bytesPulled uint64
+	if metrics.Enabled() {
+		t1 := time.Now()
+		defer func() {
+			pullLatency.SampleTimeSince(t1)
+			if chunksPulled > 0 {
+				chunksPerPull.Sample(chunksPulled)
+				bytesPerPull.Sample(bytesPulled)
+			}
+		}()
 	}
 
 	// traverseWorker below takes refs off of {src,sink,com}Chan, processes them to figure out what reachable refs should be traversed, and then sends the results to {srcRes,sinkRes,comRes}Chan.
@@ -60,15 +114,15 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 	sinkResChan := make(chan traverseResult)
 	comResChan := make(chan traverseResult)
 	done := make(chan struct{})
+	// A panic in a traverseWorker, e.g. because srcDB sent a corrupt chunk, is sent here and re-raised on the calling goroutine.
+	panicChan := make(chan interface{}, concurrency)
 
 	workerWg := &sync.WaitGroup{}
 	defer func() {
 		close(done)
 		workerWg.Wait()
 
-		close(srcChan)
-		close(sinkChan)
-		close(comChan)
+		// sendWork goroutines may still be blocked on {src,sink,com}Chan if a traverseWorker panicked; they exit on 'done', so those channels aren't closed.
 		close(srcResChan)
 		close(sinkResChan)
 		close(comResChan)
@@ -76,6 +130,12 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 	traverseWorker := func() {
 		workerWg.Add(1)
 		go func() {
+			defer workerWg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicChan <- r
+				}
+			}()
 			for {
 				select {
 				case srcRef := <-srcChan:
@@ -85,13 +145,27 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 					// There's no immediately observable performance benefit to sampling here, but there's
 					// also no appreciable loss in accuracy, so we'll keep it around.
 					takeSample := rand.Float64() < bytesWrittenSampleRate
-					srcResChan <- traverseSource(srcRef, srcDB, sinkDB, takeSample)
+					res := traverseSource(srcRef, srcDB, sinkDB, takeSample)
+					select {
+					case srcResChan <- res:
+					case <-done:
+						return
+					}
 				case sinkRef := <-sinkChan:
-					sinkResChan <- traverseSink(sinkRef, srcDB)
+					res := traverseSink(sinkRef, srcDB)
+					select {
+					case sinkResChan <- res:
+					case <-done:
+						return
+					}
 				case comRef := <-comChan:
-					comResChan <- traverseCommon(comRef, sinkHeadRef, srcDB)
+					res := traverseCommon(comRef, sinkHeads, srcDB)
+					select {
+					case comResChan <- res:
+					case <-done:
+						return
+					}
 				case <-done:
-					workerWg.Done()
 					return
 				}
 			}
@@ -120,9 +194,9 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 		}
 
 		// These goroutines send work to traverseWorkers, blocking when all are busy. They self-terminate when they've sent all they have.
-		go sendWork(srcChan, srcRefs)
-		go sendWork(sinkChan, sinkRefs)
-		go sendWork(comChan, comRefs)
+		go sendWork(srcChan, srcRefs, done)
+		go sendWork(sinkChan, sinkRefs, done)
+		go sendWork(comChan, comRefs, done)
 		//  Don't use srcRefs, sinkRefs, or comRefs after this point. The goroutines above own them.
 
 		for srcWork+sinkWork+comWork > 0 {
@@ -135,6 +209,10 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 					sampleSize += uint64(res.writeBytes)
 					sampleCount += 1
 				}
+				if res.readBytes > 0 {
+					chunksPulled++
+					bytesPulled += uint64(res.readBytes)
+				}
 				srcWork--
 
 				updateProgress(1, 0, uint64(res.readBytes), sampleSize/uint64(math.Max(1, float64(sampleCount))))
@@ -144,7 +222,7 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				}
 				sinkWork--
 			case res := <-comResChan:
-				isHeadOfSink := res.readHash == sinkHeadRef.TargetHash()
+				isHeadOfSink := sinkHeads.Has(res.readHash)
 				for _, reachable := range res.reachables {
 					sinkQ.PushBack(reachable)
 					if !isHeadOfSink {
@@ -153,6 +231,8 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				}
 				comWork--
 				updateProgress(1, 0, uint64(res.readBytes), 0)
+			case r := <-panicChan:
+				panic(r)
 			}
 		}
 		sort.Sort(sinkQ)
@@ -219,14 +299,80 @@ func findCommon(taller, shorter *types.RefByHeight, height uint64) (tallRefs, co
 	return
 }
 
-func sendWork(ch chan<- types.Ref, refs types.RefSlice) {
+func sendWork(ch chan<- types.Ref, refs types.RefSlice, done <-chan struct{}) {
 	for _, r := range refs {
-		ch <- r
+		select {
+		case ch <- r:
+		case <-done:
+			return
+		}
 	}
 }
 
 type hintCache map[hash.Hash]hash.Hash
 
+// maxFrontierGenerations caps the number of generations of history that
+// commitFrontier asks about, and so the number of round trips it makes to a
+// remote sinkDB. Beyond it, Pull falls back to asking about chunks.
+const maxFrontierGenerations = 64
+
+// commitFrontiers returns the commitFrontier of each of |sourceRefs|. If
+// sinkDB's root is empty, it's taken to have no Commits, and isn't asked about
+// any, so that a first-time pull doesn't make a round trip for each generation
+// of history.
+func commitFrontiers(srcDB, sinkDB Database, sourceRefs types.RefSlice) (frontier types.RefSlice) {
+	if sinkDB.chunkStore().Root().IsEmpty() {
+		return nil
+	}
+	for _, sourceRef := range sourceRefs {
+		frontier = append(frontier, commitFrontier(srcDB, sinkDB, sourceRef)...)
+	}
+	return
+}
+
+// commitFrontier finds the Commits in the history of |sourceRef| that sinkDB
+// already has, like the have/want exchange of git. It walks the history in
+// srcDB down from sourceRef, which sinkDB must lack, a generation at a time,
+// and asks sinkDB about all the parents of each generation with a single
+// HasMany. Since sinkDB has everything that a Commit it has refers to, the
+// history below a Commit it has is skipped. If sourceRef isn't a Commit, the
+// frontier is empty.
+func commitFrontier(srcDB, sinkDB Database, sourceRef types.Ref) (frontier types.RefSlice) {
+	if !IsRefOfCommitType(types.TypeOf(sourceRef)) {
+		return nil
+	}
+
+	seen := hash.NewHashSet(sourceRef.TargetHash())
+	wants := types.RefSlice{sourceRef}
+	for gen := 0; len(wants) > 0 && gen < maxFrontierGenerations; gen++ {
+		parents, hashes := types.RefSlice{}, hash.HashSet{}
+		for _, r := range wants {
+			r.TargetValue(srcDB).(types.Struct).Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+				p := v.(types.Ref)
+				if !seen.Has(p.TargetHash()) {
+					seen.Insert(p.TargetHash())
+					hashes.Insert(p.TargetHash())
+					parents = append(parents, p)
+				}
+			})
+		}
+		if len(parents) == 0 {
+			break
+		}
+
+		present := sinkDB.chunkStore().HasMany(hashes)
+		wants = types.RefSlice{}
+		for _, p := range parents {
+			if present.Has(p.TargetHash()) {
+				frontier = append(frontier, p)
+			} else {
+				wants = append(wants, p)
+			}
+		}
+	}
+	return
+}
+
 func getChunks(v types.Value) (chunks []types.Ref) {
 	v.WalkRefs(func(ref types.Ref) {
 		chunks = append(chunks, ref)
@@ -262,13 +408,13 @@ func traverseSink(sinkRef types.Ref, db Database) traverseResult {
 	return traverseResult{}
 }
 
-func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
+func traverseCommon(comRef types.Ref, sinkHeads hash.HashSet, db Database) traverseResult {
 	// TODO: Add IsRefOfCommit?
 	if comRef.Height() > 1 && IsRefOfCommitType(types.TypeOf(comRef)) {
 		commit := comRef.TargetValue(db).(types.Struct)
-		// We don't want to traverse the parents of sinkHead, but we still want to traverse its Value on the sinkDB side. We also still want to traverse all children, in both the srcDB and sinkDB, of any common Commit that is not at the Head of sinkDB.
+		// We don't want to traverse the parents of a sink head, but we still want to traverse its Value on the sinkDB side. We also still want to traverse all children, in both the srcDB and sinkDB, of any common Commit that is not a head of sinkDB.
 		exclusionSet := types.NewSet()
-		if comRef.Equals(sinkHead) {
+		if sinkHeads.Has(comRef.TargetHash()) {
 			exclusionSet = commit.Get(ParentsField).(types.Set)
 		}
 		chunks := types.RefSlice(getChunks(commit))
@@ -9,6 +9,9 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
@@ -159,6 +162,41 @@ func (suite *PullSuite) TestPullEverything() {
 	suite.True(l.Equals(v.Get(ValueField)))
 }
 
+func (suite *PullSuite) TestPullMany() {
+	l := buildListOfHeight(2, suite.source)
+	lr := suite.source.WriteValue(l)
+	a := suite.source.WriteValue(types.NewList(lr, types.Number(1)))
+	b := suite.source.WriteValue(types.NewList(lr, types.Number(2)))
+	suite.commitToSource(types.NewList(a, b), types.NewSet())
+
+	PullMany(suite.source, suite.sink, types.RefSlice{a, b, a}, types.Ref{}, 2, nil)
+	suite.Equal(0, suite.sinkCS.Reads)
+	persistChunks(suite.sink.chunkStore())
+	for _, r := range []types.Ref{a, b, lr} {
+		suite.True(suite.sink.chunkStore().Has(r.TargetHash()))
+	}
+	suite.True(l.Equals(suite.sink.ReadValue(lr.TargetHash())))
+
+	// There's nothing to do once sinkDB has them all.
+	reads := suite.sourceCS.Reads
+	PullMany(suite.source, suite.sink, types.RefSlice{a, b}, types.Ref{}, 2, nil)
+	suite.Equal(reads, suite.sourceCS.Reads)
+}
+
+func (suite *PullSuite) TestPullSamplesMetrics() {
+	metrics.SetEnabled(true)
+	defer metrics.SetEnabled(false)
+	before := metrics.Snapshot()
+
+	l := buildListOfHeight(2, suite.source)
+	sourceRef := suite.commitToSource(l, types.NewSet())
+	Pull(suite.source, suite.sink, sourceRef, types.Ref{}, 2, nil)
+
+	after := metrics.Snapshot()
+	suite.Equal(uint64(1), after["datas.Pull.Latency"].Samples()-before["datas.Pull.Latency"].Samples())
+	suite.True(after["datas.Pull.ChunksPerPull"].Sum() > before["datas.Pull.ChunksPerPull"].Sum())
+}
+
 // Source: -6-> C3(L5) -1-> N
 //               .  \  -5-> L4 -1-> N
 //                .          \ -4-> L3 -1-> N
@@ -298,6 +336,58 @@ func (suite *PullSuite) SkipTestPullUpdates() {
 	suite.True(srcL.Equals(v.Get(ValueField)))
 }
 
+// Source: C3(L5) -> C2(L4) -> C1(L2)
+//
+// Sink: C1(L2), but the sink head isn't given, so the Commits that the sink
+// already has are found by asking it about them.
+func (suite *PullSuite) TestPullFindsFrontier() {
+	sinkL := buildListOfHeight(2, suite.sink)
+	sinkRef := suite.commitToSink(sinkL, types.NewSet())
+	expectedReads := suite.sinkCS.Reads
+
+	srcL := buildListOfHeight(2, suite.source)
+	c1 := suite.commitToSource(srcL, types.NewSet())
+	suite.True(c1.Equals(sinkRef))
+	srcL = buildListOfHeight(4, suite.source)
+	sourceRef := suite.commitToSource(srcL, types.NewSet(c1))
+	srcL = buildListOfHeight(5, suite.source)
+	sourceRef = suite.commitToSource(srcL, types.NewSet(sourceRef))
+
+	// One HasMany per generation, until C1 is found.
+	preHases := suite.sinkCS.Hases
+	frontier := commitFrontier(suite.source, suite.sink, sourceRef)
+	suite.Len(frontier, 1)
+	suite.True(c1.Equals(frontier[0]))
+	suite.Equal(2, suite.sinkCS.Hases-preHases)
+
+	Pull(suite.source, suite.sink, sourceRef, types.Ref{}, 2, nil)
+	suite.Equal(expectedReads, suite.sinkCS.Reads)
+
+	persistChunks(suite.sink.chunkStore())
+	v := suite.sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	suite.NotNil(v)
+	suite.True(srcL.Equals(v.Get(ValueField)))
+}
+
+// Source: C2(L4) -> C1(L2), Sink: empty. Nothing is asked of the sink about
+// Commits, since it has none.
+func (suite *PullSuite) TestPullToEmptySinkSkipsFrontier() {
+	srcL := buildListOfHeight(2, suite.source)
+	sourceRef := suite.commitToSource(srcL, types.NewSet())
+	srcL = buildListOfHeight(4, suite.source)
+	sourceRef = suite.commitToSource(srcL, types.NewSet(sourceRef))
+
+	preHases := suite.sinkCS.Hases
+	suite.Empty(commitFrontiers(suite.source, suite.sink, types.RefSlice{sourceRef}))
+	suite.Equal(preHases, suite.sinkCS.Hases)
+
+	Pull(suite.source, suite.sink, sourceRef, types.Ref{}, 2, nil)
+	persistChunks(suite.sink.chunkStore())
+	v := suite.sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	suite.NotNil(v)
+	suite.True(srcL.Equals(v.Get(ValueField)))
+}
+
 func (suite *PullSuite) commitToSource(v types.Value, p types.Set) types.Ref {
 	ds := suite.source.GetDataset(datasetID)
 	ds, err := suite.source.Commit(ds, v, CommitOptions{Parents: p})
@@ -352,3 +442,36 @@ func TestFindCommon(t *testing.T) {
 	assert.Equal(t, 0, len(*taller))
 	assert.Equal(t, 50, len(*shorter))
 }
+
+func TestPullCorruptChunk(t *testing.T) {
+	assert := assert.New(t)
+	sourceStorage := &chunks.TestStorage{}
+	source := NewDatabase(sourceStorage.NewView())
+	corrupt := source.WriteValue(types.String("corrupt me"))
+	l := types.NewList(corrupt, source.WriteValue(types.String("fine")))
+	ds, err := source.CommitValue(source.GetDataset("ds"), l)
+	assert.NoError(err)
+	sourceRef := ds.HeadRef()
+	source.Close()
+
+	// Replace the data of |corrupt| behind the store's back.
+	root := sourceStorage.Root()
+	bad := types.EncodeValue(types.String("corrupted"), nil)
+	sourceStorage.Update(root, root, map[hash.Hash]chunks.Chunk{
+		corrupt.TargetHash(): chunks.NewChunkWithHash(corrupt.TargetHash(), bad.Data()),
+	})
+
+	remote := newHTTPChunkStoreForTest(sourceStorage.NewView())
+	remoteDB := newDatabase(remote)
+	defer remoteDB.Close()
+	sinkDB := NewDatabase((&chunks.TestStorage{}).NewView())
+	defer sinkDB.Close()
+
+	err = d.Try(func() {
+		Pull(remoteDB, sinkDB, sourceRef, types.Ref{}, 2, nil)
+	}, &ChunkIntegrityError{})
+	assert.Equal(&ChunkIntegrityError{"http://localhost:9000", corrupt.TargetHash(), bad.Hash()}, err)
+
+	// Once a server has sent a corrupt chunk, all reads fail.
+	assert.Panics(func() { remote.Get(sourceRef.TargetHash()) })
+}
@@ -0,0 +1,131 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// MaxReflogEntries is the number of previous heads that the reflog of a
+// Dataset keeps. Older entries are dropped.
+const MaxReflogEntries = 100
+
+// reflogKey is the key in the root of a Database under which the reflogs of
+// all its Datasets are kept, in the value of a Commit, as a
+// Map<String, List<ReflogEntry>>. It isn't a valid Dataset ID, so it can't
+// clash with a Dataset, and Datasets and ListDatasets leave it out.
+const reflogKey = "$reflog"
+
+const (
+	reflogDateField = "date"
+	reflogHeadField = "head"
+	reflogOpField   = "op"
+)
+
+var reflogEntryTemplate = types.MakeStructTemplate("ReflogEntry", []string{reflogDateField, reflogHeadField, reflogOpField})
+
+// ReflogEntry records a head that a Dataset had before an update replaced or
+// removed it. The reflog keeps these heads reachable, so that they survive
+// garbage collection and the Dataset can be restored to any of them. Only the
+// updates that can drop history are logged: SetHead, Prune, unless its
+// RetentionPolicy drops the reflog, Delete and RenameDataset. Commit and
+// FastForward keep the previous head in the history of the new one, so they
+// aren't.
+type ReflogEntry struct {
+	// Head is a Ref to the Commit that was the head.
+	Head types.Ref
+	// Date is when the head was replaced.
+	Date time.Time
+	// Op is the kind of update that replaced the head: "set-head", "prune",
+	// "delete" or "rename".
+	Op string
+}
+
+func (e ReflogEntry) value() types.Value {
+	return reflogEntryTemplate.NewStruct([]types.Value{
+		types.String(e.Date.Format(time.RFC3339Nano)),
+		e.Head,
+		types.String(e.Op),
+	})
+}
+
+func readReflogEntry(v types.Value) ReflogEntry {
+	s := v.(types.Struct)
+	date, err := time.Parse(time.RFC3339Nano, string(s.Get(reflogDateField).(types.String)))
+	d.PanicIfError(err)
+	return ReflogEntry{s.Get(reflogHeadField).(types.Ref), date, string(s.Get(reflogOpField).(types.String))}
+}
+
+// Reflog returns the reflog of the Dataset |datasetID| in |db|, newest first:
+// the heads it had before its most recent updates, up to MaxReflogEntries of
+// them. The reflog outlives the Dataset, so a deleted Dataset can be restored
+// with RestoreFromReflog.
+func Reflog(db Database, datasetID string) []ReflogEntry {
+	root := types.NewMap()
+	if h := db.chunkStore().Root(); !h.IsEmpty() {
+		root = db.ReadValue(h).(types.Map)
+	}
+	entries := []ReflogEntry{}
+	if l, ok := readReflogs(db, root).MaybeGet(types.String(datasetID)); ok {
+		l.(types.List).IterAll(func(v types.Value, idx uint64) {
+			entries = append(entries, readReflogEntry(v))
+		})
+	}
+	return entries
+}
+
+// RestoreFromReflog makes the head that |entry| records the head of |ds|
+// again, as SetHead does. The head it replaces is added to the reflog in turn,
+// so that restoring can be undone too.
+func RestoreFromReflog(db Database, ds Dataset, entry ReflogEntry) (Dataset, error) {
+	return db.SetHead(ds, entry.Head)
+}
+
+// readReflogs returns the reflogs kept in |root|, keyed by Dataset ID.
+func readReflogs(vr types.ValueReader, root types.Map) types.Map {
+	r, ok := root.MaybeGet(types.String(reflogKey))
+	if !ok {
+		return types.NewMap()
+	}
+	return r.(types.Ref).TargetValue(vr).(types.Struct).Get(ValueField).(types.Map)
+}
+
+// logHead returns |root| with |prevHead|, the head that Dataset |datasetID|
+// had before an update of kind |op|, added to the front of its reflog. The
+// reflog is kept in the root it describes, so that it's updated atomically
+// with the head.
+func (db *database) logHead(root types.Map, datasetID string, prevHead types.Ref, op string) types.Map {
+	reflogs := readReflogs(db, root)
+	id := types.String(datasetID)
+	entries := types.NewList()
+	if l, ok := reflogs.MaybeGet(id); ok {
+		entries = l.(types.List)
+	}
+	entries = entries.Insert(0, ReflogEntry{prevHead, time.Now(), op}.value())
+	if entries.Len() > MaxReflogEntries {
+		entries = entries.Remove(MaxReflogEntries, entries.Len())
+	}
+	commit := NewCommit(reflogs.Set(id, entries), types.NewSet(), types.EmptyStruct)
+	return root.Set(types.String(reflogKey), types.ToRefOfValue(db.WriteValue(commit)))
+}
+
+// dropReflog returns |root| without the reflog of Dataset |datasetID|, so that
+// the heads it kept can be garbage collected.
+func (db *database) dropReflog(root types.Map, datasetID string) types.Map {
+	reflogs := readReflogs(db, root)
+	id := types.String(datasetID)
+	if !reflogs.Has(id) {
+		return root
+	}
+	reflogs = reflogs.Remove(id)
+	if reflogs.Empty() {
+		return root.Remove(types.String(reflogKey))
+	}
+	commit := NewCommit(reflogs, types.NewSet(), types.EmptyStruct)
+	return root.Set(types.String(reflogKey), types.ToRefOfValue(db.WriteValue(commit)))
+}
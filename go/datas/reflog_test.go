@@ -0,0 +1,79 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestReflog(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	ds := db.GetDataset("ds")
+	assert.Empty(Reflog(db, "ds"))
+
+	// Commits don't lose history, so they aren't logged.
+	ds, err := db.CommitValue(ds, types.String("a"))
+	assert.NoError(err)
+	a := ds.HeadRef()
+	ds, err = db.CommitValue(ds, types.String("b"))
+	assert.NoError(err)
+	b := ds.HeadRef()
+	assert.Empty(Reflog(db, "ds"))
+
+	// Force the head back to |a|, which drops |b|.
+	ds, err = db.SetHead(ds, a)
+	assert.NoError(err)
+	entries := Reflog(db, "ds")
+	assert.Len(entries, 1)
+	assert.Equal(b.TargetHash(), entries[0].Head.TargetHash())
+	assert.Equal("set-head", entries[0].Op)
+	assert.False(entries[0].Date.IsZero())
+
+	ds, err = RestoreFromReflog(db, ds, entries[0])
+	assert.NoError(err)
+	assert.True(types.String("b").Equals(ds.HeadValue()))
+
+	// The restore is logged too, newest first.
+	entries = Reflog(db, "ds")
+	assert.Len(entries, 2)
+	assert.Equal(a.TargetHash(), entries[0].Head.TargetHash())
+	assert.False(entries[0].Date.Before(entries[1].Date))
+
+	// A deleted dataset can be restored, and the reflog isn't a dataset.
+	_, err = db.Delete(ds)
+	assert.NoError(err)
+	assert.Equal(uint64(0), db.Datasets().Len())
+	assert.Empty(db.ListDatasets(""))
+	entries = Reflog(db, "ds")
+	assert.Equal("delete", entries[0].Op)
+
+	db = NewDatabase(storage.NewView())
+	ds, err = RestoreFromReflog(db, db.GetDataset("ds"), Reflog(db, "ds")[0])
+	assert.NoError(err)
+	assert.True(types.String("b").Equals(ds.HeadValue()))
+	assert.Equal([]string{"ds"}, db.ListDatasets(""))
+
+	renamed, err := db.RenameDataset(ds, "other")
+	assert.NoError(err)
+	assert.Equal("rename", Reflog(db, "ds")[0].Op)
+	assert.Empty(Reflog(db, "other"))
+
+	// The reflog is bounded.
+	for i := 0; i < MaxReflogEntries; i++ {
+		renamed, err = db.SetHead(renamed, a)
+		assert.NoError(err)
+		renamed, err = db.SetHead(renamed, b)
+		assert.NoError(err)
+	}
+	assert.Len(Reflog(db, "other"), MaxReflogEntries)
+}
@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -39,19 +40,32 @@ const (
 	NomsVersionHeader = "x-noms-vers"
 	nomsBaseHTML      = "<html><head></head><body><p>Hi. This is a Noms HTTP server.</p><p>To learn more, visit <a href=\"https://github.com/attic-labs/noms\">our GitHub project</a>.</p></body></html>"
 	maxGetBatchSize   = 1 << 11 // Limit GetMany() to ~8MB of data
+
+	// chunkPackContentType is the Content-Type of request and response bodies
+	// that hold chunks in the pack format written by chunks.PackWriter, rather
+	// than as written by chunks.Serialize, whose Content-Type is
+	// application/octet-stream. Servers that accept packs say so in the
+	// Accept-Post header of root/ responses, and send them in response to
+	// getRefs/ requests that Accept them.
+	chunkPackContentType = "application/x-noms-chunk-pack"
+
+	// commitQueueHeader is set in root/ responses by servers that accept
+	// commits at commit/.
+	commitQueueHeader = "x-noms-commit-queue"
 )
 
 var (
 	// HandleWriteValue is meant to handle HTTP POST requests to the
 	// writeValue/ server endpoint. The payload should be an appropriately-
-	// ordered sequence of Chunks to be validated and stored on the server.
+	// ordered sequence of Chunks to be validated and stored on the server,
+	// serialized as a chunk pack if the Content-Type says so.
 	// TODO: Nice comment about what headers it expects/honors, payload
 	// format, and error responses.
 	HandleWriteValue = createHandler(handleWriteValue, true)
 
 	// HandleGetRefs is meant to handle HTTP POST requests to the getRefs/
 	// server endpoint. Given a sequence of Chunk hashes, the server will
-	// fetch and return them.
+	// fetch and return them, as a chunk pack if the request Accepts one.
 	// TODO: Nice comment about what headers it
 	// expects/honors, payload format, and responses.
 	HandleGetRefs = createHandler(handleGetRefs, true)
@@ -70,7 +84,10 @@ var (
 	HandleHasRefs = createHandler(handleHasRefs, true)
 
 	// HandleRootGet is meant to handle HTTP GET requests to the root/ server
-	// endpoint. The server returns the hash of the Root as a string.
+	// endpoint. The server returns the hash of the Root as a string, and
+	// the same hash, quoted, as the ETag. If the request's If-None-Match
+	// header holds that ETag, the response is 304 Not Modified, without a
+	// body.
 	// TODO: Nice comment about what headers it expects/honors, payload
 	// format, and responses.
 	HandleRootGet = createHandler(handleRootGet, true)
@@ -82,6 +99,14 @@ var (
 	// format, and error responses.
 	HandleRootPost = createHandler(handleRootPost, true)
 
+	// HandleCommit is meant to handle HTTP POST requests to the commit/
+	// server endpoint, which commits the Commit whose hash is given by the
+	// "commit" query param to the dataset given by "ds", unless that isn't a
+	// fast-forward. Commits to a ChunkStore are applied one at a time. The
+	// response is the hash of the Root afterwards, with 409 Conflict if the
+	// commit wasn't a fast-forward.
+	HandleCommit = createHandler(handleCommit, true)
+
 	// HandleBaseGet is meant to handle HTTP GET requests to the / server
 	// endpoint. This is used to give a friendly message to users.
 	// TODO: Nice comment about what headers it expects/honors, payload
@@ -143,11 +168,15 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 	errChan := make(chan error)
 	chunkChan := make(chan *chunks.Chunk, writeValueConcurrency)
 
+	deserialize := chunks.Deserialize
+	if req.Header.Get("Content-Type") == chunkPackContentType {
+		deserialize = chunks.DeserializePack
+	}
 	go func() {
 		var err error
 		defer func() { errChan <- err; close(errChan) }()
 		defer close(chunkChan)
-		err = chunks.Deserialize(reader, chunkChan)
+		err = deserialize(reader, chunkChan)
 	}()
 
 	decoded := make(chan chan types.DecodedChunk, writeValueConcurrency)
@@ -192,14 +221,23 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 	w.WriteHeader(http.StatusCreated)
 }
 
-// Contents of the returned io.Reader are snappy-compressed.
-func buildWriteValueRequest(chunkChan chan *chunks.Chunk) io.Reader {
+// Contents of the returned io.Reader are snappy-compressed. If |pack| is
+// true, the chunks are written as a chunk pack.
+func buildWriteValueRequest(chunkChan chan *chunks.Chunk, pack bool) io.Reader {
 	body, pw := io.Pipe()
 
 	go func() {
 		gw := snappy.NewBufferedWriter(pw)
-		for c := range chunkChan {
-			chunks.Serialize(*c, gw)
+		if pack {
+			cw := chunks.NewPackWriter(gw)
+			for c := range chunkChan {
+				cw.Write(*c)
+			}
+			d.Chk.NoError(cw.Close())
+		} else {
+			for c := range chunkChan {
+				chunks.Serialize(*c, gw)
+			}
 		}
 		d.Chk.NoError(gw.Close())
 		d.Chk.NoError(pw.Close())
@@ -208,6 +246,17 @@ func buildWriteValueRequest(chunkChan chan *chunks.Chunk) io.Reader {
 	return body
 }
 
+// acceptsChunkPack returns true if |header|, an Accept or Accept-Post header,
+// lists chunkPackContentType.
+func acceptsChunkPack(header string) bool {
+	for _, t := range strings.Split(header, ",") {
+		if mt := strings.TrimSpace(strings.SplitN(t, ";", 2)[0]); mt == chunkPackContentType {
+			return true
+		}
+	}
+	return false
+}
+
 func bodyReader(req *http.Request) (reader io.ReadCloser) {
 	reader = req.Body
 	if strings.Contains(req.Header.Get("Content-Encoding"), "gzip") {
@@ -262,10 +311,22 @@ func handleGetRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 
 	hashes := extractHashes(req)
 
-	w.Header().Add("Content-Type", "application/octet-stream")
+	pack := acceptsChunkPack(req.Header.Get("Accept"))
+	if pack {
+		w.Header().Add("Content-Type", chunkPackContentType)
+	} else {
+		w.Header().Add("Content-Type", "application/octet-stream")
+	}
 	writer := respWriter(req, w)
 	defer writer.Close()
 
+	serialize := func(c chunks.Chunk) { chunks.Serialize(c, writer) }
+	var pw *chunks.PackWriter
+	if pack {
+		pw = chunks.NewPackWriter(writer)
+		serialize = pw.Write
+	}
+
 	for len(hashes) > 0 {
 		batch := hashes
 
@@ -281,11 +342,17 @@ func handleGetRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 		}()
 
 		for c := range chunkChan {
-			chunks.Serialize(*c, writer)
+			serialize(*c)
 		}
 
 		hashes = hashes[len(batch):]
 	}
+
+	// The index is only written once all the chunks have been, so that clients
+	// can tell an incomplete pack from a complete one.
+	if pw != nil {
+		d.Chk.NoError(pw.Close())
+	}
 }
 
 func handleGetBlob(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
@@ -356,8 +423,31 @@ func handleRootGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt ch
 	if req.Method != "GET" {
 		d.Panic("Expected get method.")
 	}
-	fmt.Fprintf(w, "%v", rt.Root().String())
+	root := rt.Root()
+	w.Header().Add("Accept-Post", chunkPackContentType+", application/octet-stream")
+	w.Header().Set("ETag", rootETag(root))
+	if etagMatches(req.Header.Get("If-None-Match"), rootETag(root)) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	w.Header().Add("content-type", "text/plain")
+	fmt.Fprintf(w, "%v", root.String())
+}
+
+func rootETag(root hash.Hash) string {
+	return `"` + root.String() + `"`
+}
+
+// etagMatches returns true if |etag| is one of the entity tags in the
+// If-None-Match header |header|.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
 }
 
 func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
@@ -413,6 +503,69 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 	}
 }
 
+// commitQueues serializes the commits that handleCommit makes to each
+// ChunkStore.
+var commitQueues = struct {
+	mu sync.Mutex
+	m  map[chunks.ChunkStore]*sync.Mutex
+}{m: map[chunks.ChunkStore]*sync.Mutex{}}
+
+func commitQueue(cs chunks.ChunkStore) *sync.Mutex {
+	commitQueues.mu.Lock()
+	defer commitQueues.mu.Unlock()
+	q, ok := commitQueues.m[cs]
+	if !ok {
+		q = &sync.Mutex{}
+		commitQueues.m[cs] = q
+	}
+	return q
+}
+
+// closeCommitQueue drops the commit queue of |cs|, which the server is
+// closing, so that commitQueues doesn't keep it, and its ChunkStore, forever.
+func closeCommitQueue(cs chunks.ChunkStore) {
+	commitQueues.mu.Lock()
+	defer commitQueues.mu.Unlock()
+	delete(commitQueues.m, cs)
+}
+
+func handleCommit(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	if req.Method != "POST" {
+		d.Panic("Expected post method.")
+	}
+
+	params := req.URL.Query()
+	datasetID := params.Get("ds")
+	if !DatasetFullRe.MatchString(datasetID) {
+		d.Panic("Invalid dataset ID: %s", datasetID)
+	}
+	h, ok := hash.MaybeParse(params.Get("commit"))
+	if !ok {
+		d.Panic(`Expected "commit" query param value`)
+	}
+
+	q := commitQueue(cs)
+	q.Lock()
+	defer q.Unlock()
+
+	// Other clients may still update the Root directly, which doCommit
+	// retries around.
+	db := newDatabase(cs)
+	db.Rebase()
+	commit := db.ReadValue(h)
+	if commit == nil || !IsCommit(commit) {
+		d.Panic("Not a commit: #%s", h)
+	}
+	err := db.doCommit(datasetID, commit.(types.Struct), nil)
+	w.Header().Add("content-type", "text/plain")
+	if err == ErrMergeNeeded {
+		w.WriteHeader(http.StatusConflict)
+	} else {
+		d.PanicIfError(err)
+	}
+	fmt.Fprintf(w, "%v", cs.Root().String())
+}
+
 func handleGraphQL(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
 	if req.Method != http.MethodGet && req.Method != http.MethodPost {
 		d.Panic("Unexpected method")
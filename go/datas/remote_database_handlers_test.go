@@ -57,6 +57,40 @@ func TestHandleWriteValue(t *testing.T) {
 	}
 }
 
+func TestHandleWriteValuePack(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+
+	l := types.NewList(types.Bool(true), types.NewRef(types.NewEmptyBlob()))
+	body := &bytes.Buffer{}
+	pw := chunks.NewPackWriter(body)
+	pw.Write(types.EncodeValue(types.NewEmptyBlob(), nil))
+	pw.Write(types.EncodeValue(l, nil))
+	assert.NoError(pw.Close())
+
+	w := httptest.NewRecorder()
+	HandleWriteValue(w, newRequest("POST", "", "", body, http.Header{
+		"Content-Type": {chunkPackContentType},
+	}), params{}, storage.NewView())
+
+	if assert.Equal(http.StatusCreated, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		v := NewDatabase(storage.NewView()).ReadValue(l.Hash())
+		if assert.NotNil(v) {
+			assert.True(v.Equals(l))
+		}
+	}
+
+	// A truncated pack is rejected.
+	body = &bytes.Buffer{}
+	pw = chunks.NewPackWriter(body)
+	pw.Write(types.EncodeValue(types.NewEmptyBlob(), nil))
+	w = httptest.NewRecorder()
+	HandleWriteValue(w, newRequest("POST", "", "", body, http.Header{
+		"Content-Type": {chunkPackContentType},
+	}), params{}, storage.NewView())
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
 func TestHandleWriteValuePanic(t *testing.T) {
 	assert := assert.New(t)
 	storage := &chunks.MemoryStorage{}
@@ -108,7 +142,7 @@ func TestBuildWriteValueRequest(t *testing.T) {
 	inChunkChan <- &chnx[1]
 	close(inChunkChan)
 
-	compressed := buildWriteValueRequest(inChunkChan)
+	compressed := buildWriteValueRequest(inChunkChan, false)
 	gr := snappy.NewReader(compressed)
 
 	outChunkChan := make(chan *chunks.Chunk, len(chnx))
@@ -122,6 +156,30 @@ func TestBuildWriteValueRequest(t *testing.T) {
 	assert.Empty(chnx)
 }
 
+func TestBuildWriteValueRequestPack(t *testing.T) {
+	assert := assert.New(t)
+	chnx := []chunks.Chunk{
+		chunks.NewChunk([]byte("abc")),
+		chunks.NewChunk([]byte("def")),
+	}
+
+	inChunkChan := make(chan *chunks.Chunk, 2)
+	inChunkChan <- &chnx[0]
+	inChunkChan <- &chnx[1]
+	close(inChunkChan)
+
+	gr := snappy.NewReader(buildWriteValueRequest(inChunkChan, true))
+	outChunkChan := make(chan *chunks.Chunk, len(chnx))
+	assert.NoError(chunks.DeserializePack(gr, outChunkChan))
+	close(outChunkChan)
+
+	for c := range outChunkChan {
+		assert.Equal(chnx[0].Hash(), c.Hash())
+		chnx = chnx[1:]
+	}
+	assert.Empty(chnx)
+}
+
 func serializeChunks(chnx []chunks.Chunk, assert *assert.Assertions) io.Reader {
 	body := &bytes.Buffer{}
 	sw := snappy.NewBufferedWriter(body)
@@ -198,6 +256,46 @@ func TestHandleGetRefs(t *testing.T) {
 	}
 }
 
+func TestHandleGetRefsPack(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	cs := storage.NewView()
+	chnx := []chunks.Chunk{
+		chunks.NewChunk([]byte("abc")),
+		chunks.NewChunk([]byte("def")),
+	}
+	for _, c := range chnx {
+		cs.Put(c)
+	}
+	persistChunks(cs)
+
+	body := strings.NewReader(fmt.Sprintf("ref=%s&ref=%s", chnx[0].Hash(), chnx[1].Hash()))
+
+	w := httptest.NewRecorder()
+	HandleGetRefs(
+		w,
+		newRequest("POST", "", "", body, http.Header{
+			"Accept":       {"application/octet-stream, " + chunkPackContentType + ";q=0.9"},
+			"Content-Type": {"application/x-www-form-urlencoded"},
+		}),
+		params{},
+		storage.NewView(),
+	)
+
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		assert.Equal(chunkPackContentType, w.Header().Get("Content-Type"))
+		chunkChan := make(chan *chunks.Chunk, len(chnx))
+		assert.NoError(chunks.DeserializePack(w.Body, chunkChan))
+		close(chunkChan)
+
+		foundHashes := hash.HashSet{}
+		for c := range chunkChan {
+			foundHashes.Insert(c.Hash())
+		}
+		assert.Equal(hash.NewHashSet(chnx[0].Hash(), chnx[1].Hash()), foundHashes)
+	}
+}
+
 func TestHandleGetBlob(t *testing.T) {
 	assert := assert.New(t)
 
@@ -319,7 +417,34 @@ func TestHandleGetRoot(t *testing.T) {
 	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
 		root := hash.Parse(string(w.Body.Bytes()))
 		assert.Equal(c.Hash(), root)
+		assert.True(acceptsChunkPack(w.Header().Get("Accept-Post")))
+	}
+}
+
+func TestHandleGetRootNotModified(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	cs := storage.NewView()
+	c := chunks.NewChunk([]byte("abc"))
+	cs.Put(c)
+	assert.True(cs.Commit(c.Hash(), hash.Hash{}))
+
+	w := httptest.NewRecorder()
+	HandleRootGet(w, newRequest("GET", "", "", nil, nil), params{}, storage.NewView())
+	etag := w.Header().Get("ETag")
+	assert.Equal(`"`+c.Hash().String()+`"`, etag)
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		w = httptest.NewRecorder()
+		HandleRootGet(w, newRequest("GET", "", "", nil, http.Header{"If-None-Match": {inm}}), params{}, storage.NewView())
+		assert.Equal(http.StatusNotModified, w.Code, inm)
+		assert.Empty(w.Body.Bytes())
 	}
+
+	w = httptest.NewRecorder()
+	HandleRootGet(w, newRequest("GET", "", "", nil, http.Header{"If-None-Match": {`"` + hash.Hash{}.String() + `"`}}), params{}, storage.NewView())
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(c.Hash(), hash.Parse(w.Body.String()))
 }
 
 func TestHandleGetBase(t *testing.T) {
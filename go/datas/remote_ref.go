@@ -0,0 +1,83 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomdl"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	RemoteRefDatabaseField = "database"
+	RemoteRefTargetField   = "target"
+	remoteRefName          = "RemoteRef"
+)
+
+var remoteRefTemplate = types.MakeStructTemplate(remoteRefName, []string{RemoteRefDatabaseField, RemoteRefTargetField})
+
+// RemoteRefType is the type of the Noms structs that hold RemoteRefs:
+//
+// ```
+// struct RemoteRef {
+//   database: String,
+//   target: String,
+// }
+// ```
+var RemoteRefType = nomdl.MustParseType(`struct RemoteRef {
+        database: String,
+        target: String,
+}`)
+
+// RemoteRef refers to a value that's stored in another database, so that a
+// dataset can mention values of other databases without copying them. Unlike a
+// types.Ref, it doesn't keep its target alive or make it reachable: pulling or
+// garbage collecting the database holding the RemoteRef doesn't involve the
+// other database.
+type RemoteRef struct {
+	// Database is the spec of the database holding the target, e.g.
+	// "https://demo.noms.io/cli-tour", as understood by package spec.
+	Database string
+	// Target is the hash of the value referred to.
+	Target hash.Hash
+}
+
+// NewRemoteRef returns a RemoteRef to the value with hash |target| in the
+// database named by the spec |database|.
+func NewRemoteRef(database string, target hash.Hash) RemoteRef {
+	return RemoteRef{database, target}
+}
+
+// Struct returns the Noms struct that holds |r|, of type RemoteRefType, for
+// storing in a Noms value. The target hash is stored as a String.
+func (r RemoteRef) Struct() types.Struct {
+	return remoteRefTemplate.NewStruct([]types.Value{types.String(r.Database), types.String(r.Target.String())})
+}
+
+// ReadRemoteRef returns the RemoteRef held by |v|, and true, or false if |v|
+// isn't a RemoteRef struct.
+func ReadRemoteRef(v types.Value) (RemoteRef, bool) {
+	if !IsRemoteRef(v) {
+		return RemoteRef{}, false
+	}
+	s := v.(types.Struct)
+	target, ok := hash.MaybeParse(string(s.Get(RemoteRefTargetField).(types.String)))
+	if !ok {
+		return RemoteRef{}, false
+	}
+	return RemoteRef{string(s.Get(RemoteRefDatabaseField).(types.String)), target}, true
+}
+
+// IsRemoteRef returns true if |v| is a struct of type RemoteRefType.
+func IsRemoteRef(v types.Value) bool {
+	return types.IsValueSubtypeOf(v, RemoteRefType)
+}
+
+// Resolve reads the target of |r| from |vr|, which should be the database that
+// |r| names. It returns nil if the target isn't there. Package spec's
+// ForRemoteRef opens the database for a RemoteRef.
+func (r RemoteRef) Resolve(vr types.ValueReader) types.Value {
+	return vr.ReadValue(r.Target)
+}
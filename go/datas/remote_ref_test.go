@@ -0,0 +1,54 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestRemoteRef(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+	v := types.String("hello")
+	db.WriteValue(v)
+
+	r := NewRemoteRef("https://example.com/db", v.Hash())
+	s := r.Struct()
+	assert.True(IsRemoteRef(s))
+	assert.True(types.IsSubtype(RemoteRefType, types.TypeOf(s)))
+	assert.Equal("https://example.com/db", string(s.Get(RemoteRefDatabaseField).(types.String)))
+
+	r2, ok := ReadRemoteRef(s)
+	assert.True(ok)
+	assert.Equal(r, r2)
+	assert.True(v.Equals(r2.Resolve(db)))
+	assert.Nil(NewRemoteRef("", hash.Of([]byte("missing"))).Resolve(db))
+
+	// RemoteRefs can be stored in, and read back from, other values.
+	l := types.NewList(s)
+	r2, ok = ReadRemoteRef(l.Get(0))
+	assert.True(ok)
+	assert.Equal(r, r2)
+
+	for _, v := range []types.Value{
+		types.String("RemoteRef"),
+		types.NewStruct("RemoteRef", types.StructData{"database": types.String("mem")}),
+		types.NewStruct("RemoteRef", types.StructData{
+			"database": types.String("mem"),
+			"target":   types.String("not a hash"),
+		}),
+	} {
+		_, ok := ReadRemoteRef(v)
+		assert.False(ok)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"sort"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// CommitMetaDateFormat is the format of the "date" field in the meta of
+// Commits made by the noms tools. RetentionPolicy.KeepFor relies on it.
+const CommitMetaDateFormat = "2006-01-02T15:04:05-0700"
+
+// RetentionPolicy says how much of a Dataset's history to keep. A Commit is
+// kept if it's among the KeepCommits most recent Commits, or if the "date"
+// field of its meta is within KeepFor of the time of pruning. A zero field
+// places no limit, so the zero RetentionPolicy keeps everything. The head of
+// a Dataset is always kept, as is every Commit between the head and a kept
+// Commit.
+type RetentionPolicy struct {
+	// KeepCommits is the number of generations of history to keep, counting
+	// the head as the first.
+	KeepCommits int
+	// KeepFor is how long to keep Commits for. Commits whose meta lacks a
+	// date in CommitMetaDateFormat or RFC 3339 format count as old.
+	KeepFor time.Duration
+	// DropReflog has Prune drop the reflog of the Dataset, rather than add the
+	// old head to it. The reflog keeps the old head, and all of the dropped
+	// history, reachable, so nothing can be reclaimed unless it's dropped, but
+	// then the prune can't be undone.
+	DropReflog bool
+}
+
+// IsZero returns true if p keeps everything.
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepCommits == 0 && p.KeepFor == 0
+}
+
+func (p RetentionPolicy) keeps(depth int, commit types.Struct, cutoff time.Time) bool {
+	if p.IsZero() || depth == 0 || depth < p.KeepCommits {
+		return true
+	}
+	if p.KeepFor == 0 {
+		return false
+	}
+	meta, ok := commitMeta(commit)
+	if !ok {
+		return false
+	}
+	date, ok := meta.MaybeGet("date")
+	if !ok {
+		return false
+	}
+	s, ok := date.(types.String)
+	if !ok {
+		return false
+	}
+	t, err := time.Parse(CommitMetaDateFormat, string(s))
+	if err != nil {
+		if t, err = time.Parse(time.RFC3339, string(s)); err != nil {
+			return false
+		}
+	}
+	return !t.Before(cutoff)
+}
+
+// commitMeta returns the meta of |commit|, and false if it has none, or its
+// meta isn't a Struct.
+func commitMeta(commit types.Struct) (types.Struct, bool) {
+	if meta, ok := commit.MaybeGet(MetaField); ok {
+		meta, ok := meta.(types.Struct)
+		return meta, ok
+	}
+	return types.Struct{}, false
+}
+
+// PrunePlan describes the effect of applying a RetentionPolicy to a Dataset.
+type PrunePlan struct {
+	// Head is the head of the Dataset that the plan was made for.
+	Head types.Ref
+	// Keep holds the Commits that are kept, in order of increasing height.
+	// Those with parents in Drop will be rewritten without them, and so will
+	// their descendants, all the way up to Head.
+	Keep []types.Ref
+	// Drop holds the Commits that will no longer be reachable from the head.
+	Drop []types.Ref
+	// DropReflog is true if the reflog of the Dataset will be dropped, as
+	// RetentionPolicy.DropReflog says.
+	DropReflog bool
+}
+
+// PlanPrune works out which Commits in the history of |ds| should be dropped
+// in order to apply |policy| at time |now|. |ds| must have a head.
+func PlanPrune(db Database, ds Dataset, policy RetentionPolicy, now time.Time) PrunePlan {
+	d.PanicIfTrue(policy.KeepCommits < 0 || policy.KeepFor < 0)
+	plan := PrunePlan{Head: ds.HeadRef(), DropReflog: policy.DropReflog}
+	cutoff := now.Add(-policy.KeepFor)
+
+	// Walk breadth first, so that each Commit is seen at its shortest distance
+	// from the head. History beyond a dropped Commit is dropped too, unless
+	// it's also reachable through a kept one.
+	depths := map[hash.Hash]int{plan.Head.TargetHash(): 0}
+	kept := map[hash.Hash]bool{}
+	dropped := types.RefSlice{}
+	for queue := []types.Ref{plan.Head}; len(queue) > 0; queue = queue[1:] {
+		r := queue[0]
+		depth := depths[r.TargetHash()]
+		commit := r.TargetValue(db).(types.Struct)
+		if !policy.keeps(depth, commit, cutoff) {
+			dropped = append(dropped, r)
+			continue
+		}
+		kept[r.TargetHash()] = true
+		plan.Keep = append(plan.Keep, r)
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			p := v.(types.Ref)
+			if _, seen := depths[p.TargetHash()]; !seen {
+				depths[p.TargetHash()] = depth + 1
+				queue = append(queue, p)
+			}
+		})
+	}
+	sort.Sort(types.RefByHeight(plan.Keep))
+
+	// Everything reachable from the dropped Commits, but not from a kept one,
+	// becomes unreachable.
+	seen := map[hash.Hash]bool{}
+	for len(dropped) > 0 {
+		r := dropped[len(dropped)-1]
+		dropped = dropped[:len(dropped)-1]
+		if kept[r.TargetHash()] || seen[r.TargetHash()] {
+			continue
+		}
+		seen[r.TargetHash()] = true
+		plan.Drop = append(plan.Drop, r)
+		r.TargetValue(db).(types.Struct).Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			dropped = append(dropped, v.(types.Ref))
+		})
+	}
+	sort.Sort(types.RefByHeight(plan.Drop))
+	return plan
+}
+
+// Prune applies |policy| to |ds| at time |now|, as planned by PlanPrune. The
+// kept Commits whose parents are dropped are rewritten without those parents,
+// which changes their hashes, so every kept Commit above them is rewritten
+// too and the head of |ds| is replaced with the rewritten head. Values and
+// metas are unchanged. The old head is added to the reflog of |ds|, so the
+// prune can be undone, unless |policy| drops the reflog, in which case the
+// dropped Commits, and any values only they refer to, become unreachable and
+// can be reclaimed by garbage collection.
+//
+// If the head of |ds| has moved since |ds| was read, Prune returns
+// ErrMergeNeeded rather than lose the newer Commits. The plan is returned
+// whether or not pruning succeeded, along with the newest snapshot of |ds|.
+func Prune(db Database, ds Dataset, policy RetentionPolicy, now time.Time) (Dataset, PrunePlan, error) {
+	plan := PlanPrune(db, ds, policy, now)
+	if len(plan.Drop) == 0 {
+		return ds, plan, nil
+	}
+
+	kept := map[hash.Hash]bool{}
+	for _, r := range plan.Keep {
+		kept[r.TargetHash()] = true
+	}
+	rewritten := map[hash.Hash]types.Ref{}
+	for _, r := range plan.Keep {
+		commit := r.TargetValue(db).(types.Struct)
+		parents := types.NewSet()
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			p := v.(types.Ref)
+			if kept[p.TargetHash()] {
+				parents = parents.Insert(rewritten[p.TargetHash()])
+			}
+		})
+		meta, ok := commitMeta(commit)
+		if !ok {
+			meta = types.EmptyStruct
+		}
+		rewritten[r.TargetHash()] = db.WriteValue(NewCommit(commit.Get(ValueField), parents, meta))
+	}
+
+	ds, err := db.replaceHead(ds, rewritten[plan.Head.TargetHash()], policy.DropReflog)
+	return ds, plan, err
+}
+
+// PruneAll applies each of |policies|, keyed by Dataset ID, at time |now|.
+// Datasets that have no head are skipped. It stops at the first error, and
+// returns the plans of the Datasets it pruned, keyed by Dataset ID.
+func PruneAll(db Database, policies map[string]RetentionPolicy, now time.Time) (map[string]PrunePlan, error) {
+	ids := make([]string, 0, len(policies))
+	for id := range policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	plans := map[string]PrunePlan{}
+	for _, id := range ids {
+		ds := db.GetDataset(id)
+		if !ds.HasHead() {
+			continue
+		}
+		_, plan, err := Prune(db, ds, policies[id], now)
+		if err != nil {
+			return plans, err
+		}
+		plans[id] = plan
+	}
+	return plans, nil
+}
+
+// Reclaimable estimates how many chunks, and how many bytes of chunk data,
+// will become unreachable from the head of the Dataset once |plan| is carried
+// out: those reachable from plan.Head but not from the values and metas of the
+// kept Commits. It walks the two graphs in height order, as Pull does, so
+// that of the kept chunks only those taller than the shortest dropped one are
+// read. Chunks that are also reachable from other Datasets are counted,
+// so garbage collection may reclaim less.
+//
+// Unless the plan drops the reflog, the old head stays reachable from it, and
+// nothing can be reclaimed.
+func (plan PrunePlan) Reclaimable(db Database) (chunkCount int, chunkBytes uint64) {
+	if len(plan.Drop) == 0 || !plan.DropReflog {
+		return
+	}
+
+	srcQ, sinkQ := types.RefByHeight{plan.Head}, types.RefByHeight{}
+	for _, r := range plan.Keep {
+		commit := r.TargetValue(db).(types.Struct)
+		sinkQ = append(sinkQ, getChunks(commit.Get(ValueField))...)
+		sinkQ = append(sinkQ, getChunks(commit.Get(MetaField))...)
+	}
+	sort.Sort(sinkQ)
+	sinkQ.Unique()
+
+	seen := hash.HashSet{}
+	for !srcQ.Empty() {
+		srcRefs, sinkRefs, comRefs := planWork(&srcQ, &sinkQ)
+		for _, r := range srcRefs {
+			if seen.Has(r.TargetHash()) {
+				continue
+			}
+			seen.Insert(r.TargetHash())
+			c := db.chunkStore().Get(r.TargetHash())
+			chunkCount++
+			chunkBytes += uint64(len(c.Data()))
+			srcQ = append(srcQ, getChunks(types.DecodeValue(c, db))...)
+		}
+		// Unlike Pull, descend into common chunks, on the kept side only, since
+		// the dropped side may reach their children by another path.
+		for _, r := range append(sinkRefs, comRefs...) {
+			if r.Height() > 1 {
+				sinkQ = append(sinkQ, getChunks(r.TargetValue(db))...)
+			}
+		}
+		sort.Sort(srcQ)
+		sort.Sort(sinkQ)
+	}
+	return
+}
@@ -0,0 +1,262 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+var pruneNow = time.Date(2017, 6, 30, 12, 0, 0, 0, time.UTC)
+
+// commitDaily commits each of |values| to |ds|, dated a day apart and ending
+// the day before pruneNow.
+func commitDaily(assert *assert.Assertions, db Database, ds Dataset, values ...types.Value) Dataset {
+	for i, v := range values {
+		date := pruneNow.AddDate(0, 0, i-len(values)).Format(CommitMetaDateFormat)
+		meta := types.NewStruct("Meta", types.StructData{"date": types.String(date)})
+		var err error
+		ds, err = db.Commit(ds, v, CommitOptions{Meta: meta})
+		assert.NoError(err)
+	}
+	return ds
+}
+
+// history returns the values of the commits reachable from the head of ds,
+// following first parents only.
+func history(ds Dataset) (values []types.Value) {
+	commit, ok := ds.MaybeHead()
+	for ok {
+		values = append(values, commit.Get(ValueField))
+		parents := commit.Get(ParentsField).(types.Set)
+		if ok = !parents.Empty(); ok {
+			commit = parents.First().(types.Ref).TargetValue(ds.Database()).(types.Struct)
+		}
+	}
+	return
+}
+
+func TestPruneKeepCommits(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	ds := commitDaily(assert, db, db.GetDataset("ds"), types.Number(1), types.Number(2), types.Number(3), types.Number(4), types.Number(5))
+	head := ds.Head()
+
+	plan := PlanPrune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.Len(plan.Keep, 2)
+	assert.Len(plan.Drop, 3)
+	assert.True(plan.Keep[1].Equals(ds.HeadRef()))
+
+	ds, plan2, err := Prune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.NoError(err)
+	assert.True(plan.Head.Equals(plan2.Head))
+	assert.Len(plan2.Drop, 3)
+	assert.Equal([]types.Value{types.Number(5), types.Number(4)}, history(ds))
+	assert.True(head.Get(MetaField).Equals(ds.Head().Get(MetaField)))
+	assert.False(plan.Head.Equals(ds.HeadRef()))
+
+	// Pruning again is a no-op.
+	ds2, plan, err := Prune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.NoError(err)
+	assert.Empty(plan.Drop)
+	assert.True(ds.HeadRef().Equals(ds2.HeadRef()))
+
+	// The zero policy keeps everything, and so does keeping more than there is.
+	assert.Empty(PlanPrune(db, ds, RetentionPolicy{}, pruneNow).Drop)
+	assert.Empty(PlanPrune(db, ds, RetentionPolicy{KeepCommits: 10}, pruneNow).Drop)
+}
+
+func TestPruneKeepFor(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	ds := commitDaily(assert, db, db.GetDataset("ds"), types.Number(1), types.Number(2), types.Number(3), types.Number(4))
+
+	ds, _, err := Prune(db, ds, RetentionPolicy{KeepFor: 50 * time.Hour}, pruneNow)
+	assert.NoError(err)
+	assert.Equal([]types.Value{types.Number(4), types.Number(3)}, history(ds))
+
+	// The head is kept no matter how old it is, and undated commits are old.
+	ds, err = db.CommitValue(ds, types.Number(5))
+	assert.NoError(err)
+	ds, _, err = Prune(db, ds, RetentionPolicy{KeepFor: time.Hour}, pruneNow)
+	assert.NoError(err)
+	assert.Equal([]types.Value{types.Number(5)}, history(ds))
+
+	// Either limit is enough to keep a commit.
+	ds = commitDaily(assert, db, ds, types.Number(6), types.Number(7))
+	plan := PlanPrune(db, ds, RetentionPolicy{KeepCommits: 2, KeepFor: 50 * time.Hour}, pruneNow)
+	assert.Len(plan.Keep, 2)
+	plan = PlanPrune(db, ds, RetentionPolicy{KeepCommits: 3, KeepFor: time.Hour}, pruneNow)
+	assert.Len(plan.Keep, 3)
+}
+
+func TestPruneMerge(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	// a <- b <- d
+	//  \- c <-/
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("a"))
+	assert.NoError(err)
+	a := ds.HeadRef()
+	ds, err = db.CommitValue(ds, types.String("b"))
+	assert.NoError(err)
+	b := ds.HeadRef()
+	c := db.WriteValue(NewCommit(types.String("c"), types.NewSet(a), types.EmptyStruct))
+	ds, err = db.Commit(ds, types.String("d"), CommitOptions{Parents: types.NewSet(b, c)})
+	assert.NoError(err)
+
+	plan := PlanPrune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.Len(plan.Keep, 3)
+	assert.Len(plan.Drop, 1)
+	assert.True(a.Equals(plan.Drop[0]))
+
+	ds, _, err = Prune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.NoError(err)
+	parents := ds.Head().Get(ParentsField).(types.Set)
+	assert.Equal(uint64(2), parents.Len())
+	parents.IterAll(func(v types.Value) {
+		p := v.(types.Ref).TargetValue(db).(types.Struct)
+		assert.True(p.Get(ParentsField).(types.Set).Empty())
+	})
+}
+
+func TestPruneHeadMoved(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	stale := commitDaily(assert, db, db.GetDataset("ds"), types.Number(1), types.Number(2))
+	ds, err := db.CommitValue(stale, types.Number(3))
+	assert.NoError(err)
+
+	ds2, _, err := Prune(db, stale, RetentionPolicy{KeepCommits: 1}, pruneNow)
+	assert.Equal(ErrMergeNeeded, err)
+	assert.True(ds.HeadRef().Equals(ds2.HeadRef()))
+}
+
+func TestPruneReflog(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	ds := commitDaily(assert, db, db.GetDataset("ds"), types.Number(1), types.Number(2), types.Number(3))
+	other := commitDaily(assert, db, db.GetDataset("other"), types.Number(1))
+	first := other.HeadRef()
+	other, err := db.CommitValue(other, types.Number(2))
+	assert.NoError(err)
+	_, err = db.SetHead(other, first)
+	assert.NoError(err)
+	head := ds.HeadRef()
+
+	// By default the old head is logged, so the prune can be undone.
+	ds, _, err = Prune(db, ds, RetentionPolicy{KeepCommits: 2}, pruneNow)
+	assert.NoError(err)
+	entries := Reflog(db, "ds")
+	assert.Len(entries, 1)
+	assert.Equal("prune", entries[0].Op)
+	assert.Equal(head.TargetHash(), entries[0].Head.TargetHash())
+
+	// Dropping the reflog leaves nothing to keep the old heads reachable.
+	ds, _, err = Prune(db, ds, RetentionPolicy{KeepCommits: 1, DropReflog: true}, pruneNow)
+	assert.NoError(err)
+	assert.Len(history(ds), 1)
+	assert.Empty(Reflog(db, "ds"))
+	// Other Datasets keep theirs.
+	assert.Len(Reflog(db, "other"), 1)
+}
+
+func TestPruneAll(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	commitDaily(assert, db, db.GetDataset("a"), types.Number(1), types.Number(2), types.Number(3))
+	commitDaily(assert, db, db.GetDataset("b"), types.Number(1), types.Number(2), types.Number(3))
+	commitDaily(assert, db, db.GetDataset("c"), types.Number(1), types.Number(2), types.Number(3))
+
+	plans, err := PruneAll(db, map[string]RetentionPolicy{
+		"a":       {KeepCommits: 1},
+		"b":       {KeepCommits: 2},
+		"missing": {KeepCommits: 1},
+	}, pruneNow)
+	assert.NoError(err)
+	assert.Len(plans, 2)
+	assert.Len(plans["a"].Drop, 2)
+	assert.Len(plans["b"].Drop, 1)
+	assert.Len(history(db.GetDataset("a")), 1)
+	assert.Len(history(db.GetDataset("b")), 2)
+	assert.Len(history(db.GetDataset("c")), 3)
+}
+
+func TestPruneReclaimable(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	// Only the first value is unique to the history that will be dropped; the
+	// others are shared with the value at the head.
+	big := func(n int) types.Value {
+		items := make([]types.Value, n)
+		for i := range items {
+			items[i] = types.String(fmt.Sprintf("item %d", i))
+		}
+		return types.NewList(items...)
+	}
+	ds, err := db.CommitValue(db.GetDataset("ds"), db.WriteValue(big(10)))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, db.WriteValue(big(5000)))
+	assert.NoError(err)
+	ds, err = db.CommitValue(ds, db.WriteValue(big(5001)))
+	assert.NoError(err)
+
+	// The reflog would keep the old head reachable.
+	n, _ := PlanPrune(db, ds, RetentionPolicy{KeepCommits: 1}, pruneNow).Reclaimable(db)
+	assert.Equal(0, n)
+
+	plan := PlanPrune(db, ds, RetentionPolicy{KeepCommits: 1, DropReflog: true}, pruneNow)
+	chunkCount, chunkBytes := plan.Reclaimable(db)
+
+	// Everything reachable from the dropped commits, but not from the head.
+	kept := hash.HashSet{}
+	var walk func(r types.Ref, cb func(types.Ref))
+	walk = func(r types.Ref, cb func(types.Ref)) {
+		cb(r)
+		r.TargetValue(db).WalkRefs(func(r types.Ref) { walk(r, cb) })
+	}
+	head := ds.Head()
+	head.Get(ValueField).WalkRefs(func(r types.Ref) {
+		walk(r, func(r types.Ref) { kept.Insert(r.TargetHash()) })
+	})
+	dropped := hash.HashSet{}
+	walk(plan.Head, func(r types.Ref) {
+		if !kept.Has(r.TargetHash()) {
+			dropped.Insert(r.TargetHash())
+		}
+	})
+	expectedBytes := uint64(0)
+	for h := range dropped {
+		expectedBytes += uint64(len(types.EncodeValue(db.ReadValue(h), nil).Data()))
+	}
+	assert.Equal(len(dropped), chunkCount)
+	assert.Equal(expectedBytes, chunkBytes)
+	assert.True(len(dropped) < 10, "only the parts that differ should be reclaimable, got %d chunks", len(dropped))
+
+	assert.Equal(0, func() int {
+		n, _ := PlanPrune(db, ds, RetentionPolicy{}, pruneNow).Reclaimable(db)
+		return n
+	}())
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+)
+
+// SSHEnvVar names the environment variable that, like GIT_SSH_COMMAND, gives
+// the ssh client used to reach ssh:// databases when SSHOptions.Command is
+// empty.
+const SSHEnvVar = "NOMS_SSH"
+
+// SSHOptions configure ChunkStores that reach a database over ssh.
+type SSHOptions struct {
+	// Command is the ssh client to run, with any arguments it needs before
+	// the host, e.g. "ssh -i /path/to/key". It's split on spaces. If empty,
+	// the value of the NOMS_SSH environment variable is used, or else "ssh".
+	Command string
+	// RemoteNoms is the noms binary to run on the remote host. If empty, it's
+	// "noms", which has to be on the PATH of the remote user's shell.
+	RemoteNoms string
+	// HTTP configures the chunk protocol that's spoken over the connection,
+	// as for NewHTTPChunkStoreWithOptions.
+	HTTP HTTPChunkStoreOptions
+}
+
+// NewSSHChunkStore returns a ChunkStore for the database that |sshURL|, of the
+// form ssh://[user@]host[:port]/path/to/db, locates. Like git's ssh transport,
+// it runs `noms serve --stdio /path/to/db` on the host over ssh and speaks
+// the protocol of NewHTTPChunkStore over the standard input and output of
+// that process, so the host needn't open any port but ssh's. A path that
+// starts with /~/ is relative to the remote user's home directory.
+//
+// Each concurrent request opens its own connection, and so runs its own ssh
+// process, so ssh shouldn't prompt for a password. Use an ssh agent, or ssh's
+// ControlMaster option to share one connection between them.
+func NewSSHChunkStore(sshURL string, opts SSHOptions) chunks.ChunkStore {
+	u, err := url.Parse(sshURL)
+	d.PanicIfError(err)
+	args, err := sshArgs(u, opts)
+	d.PanicIfError(err)
+	return newSSHChunkStore(u, opts, func() (net.Conn, error) {
+		return startSSH(args)
+	})
+}
+
+// sshChunkStore is an httpChunkStore whose connections run ssh processes,
+// which are ended when it's closed.
+type sshChunkStore struct {
+	*httpChunkStore
+	transport *http.Transport
+}
+
+func (s sshChunkStore) Close() error {
+	err := s.httpChunkStore.Close()
+	s.transport.CloseIdleConnections()
+	return err
+}
+
+func newSSHChunkStore(u *url.URL, opts SSHOptions, dial func() (net.Conn, error)) sshChunkStore {
+	if u.Scheme != "ssh" {
+		d.Panic("Unrecognized scheme: %s", u.Scheme)
+	}
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return dial()
+		},
+		MaxIdleConnsPerHost:   customHTTPTransport.MaxIdleConnsPerHost,
+		ResponseHeaderTimeout: customHTTPTransport.ResponseHeaderTimeout,
+	}
+	// The host of the URL only shows up in errors; every request goes over a
+	// connection that dial opens.
+	hcs := newHTTPChunkStoreWithClientAndOptions("http://"+u.Hostname(), "", &http.Client{Transport: transport}, opts.HTTP)
+	return sshChunkStore{hcs, transport}
+}
+
+// CheckSSHURL returns an error if the user or host of |u| could be taken for
+// an option by ssh, as in ssh://-oProxyCommand=cmd/db, which would run cmd
+// locally.
+func CheckSSHURL(u *url.URL) error {
+	if strings.HasPrefix(u.Hostname(), "-") {
+		return fmt.Errorf("Invalid ssh host %q", u.Hostname())
+	}
+	if u.User != nil && strings.HasPrefix(u.User.Username(), "-") {
+		return fmt.Errorf("Invalid ssh user %q", u.User.Username())
+	}
+	return nil
+}
+
+// sshArgs returns the command line that runs noms serve --stdio on the host
+// that |u| names, to serve the database at its path. The destination follows
+// "--", so that ssh doesn't read it as an option, and CheckSSHURL rejects
+// those that would be one anyway.
+func sshArgs(u *url.URL, opts SSHOptions) ([]string, error) {
+	if err := CheckSSHURL(u); err != nil {
+		return nil, err
+	}
+	command := opts.Command
+	if command == "" {
+		command = os.Getenv(SSHEnvVar)
+	}
+	if command == "" {
+		command = "ssh"
+	}
+	remoteNoms := opts.RemoteNoms
+	if remoteNoms == "" {
+		remoteNoms = "noms"
+	}
+
+	args := strings.Fields(command)
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	path := shellQuote(u.Path)
+	if strings.HasPrefix(u.Path, "/~/") {
+		path = "~/" + shellQuote(u.Path[len("/~/"):])
+	}
+	return append(args, "--", host, shellQuote(remoteNoms)+" serve --stdio "+path), nil
+}
+
+// shellQuote quotes |s| so that the remote shell passes it to the command
+// as a single argument, as it is.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// startSSH runs the ssh command |args| and returns a connection over its
+// standard input and output. Its standard error is passed through, so that
+// ssh's errors are reported.
+func startSSH(args []string) (net.Conn, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &streamConn{r, w, func() error {
+		// Closing the remote server's standard input makes it exit, and ssh
+		// with it.
+		err := w.Close()
+		go cmd.Wait()
+		return err
+	}}, nil
+}
+
+// streamConn is a net.Conn over a pair of streams, such as the standard
+// input and output of a process. Deadlines aren't supported.
+type streamConn struct {
+	io.Reader
+	io.Writer
+	close func() error
+}
+
+func (c *streamConn) Close() error                       { return c.close() }
+func (c *streamConn) LocalAddr() net.Addr                { return streamAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr               { return streamAddr{} }
+func (c *streamConn) SetDeadline(t time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type streamAddr struct{}
+
+func (streamAddr) Network() string { return "stream" }
+func (streamAddr) String() string  { return "stream" }
+
+// singleConnListener is a net.Listener that accepts |conn| once, and then
+// fails once |done| is closed, which is when conn is.
+type singleConnListener struct {
+	conn net.Conn
+	done chan struct{}
+}
+
+var errListenerDone = errors.New("connection closed")
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	if c := l.conn; c != nil {
+		l.conn = nil
+		return c, nil
+	}
+	<-l.done
+	return nil, errListenerDone
+}
+
+func (l *singleConnListener) Close() error   { return nil }
+func (l *singleConnListener) Addr() net.Addr { return streamAddr{} }
@@ -0,0 +1,89 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestSSHArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	args := func(sshURL string, opts SSHOptions) []string {
+		u, err := url.Parse(sshURL)
+		assert.NoError(err)
+		args, err := sshArgs(u, opts)
+		assert.NoError(err)
+		return args
+	}
+
+	assert.Equal([]string{"ssh", "--", "example.com", "'noms' serve --stdio '/data/db'"},
+		args("ssh://example.com/data/db", SSHOptions{}))
+	assert.Equal([]string{"ssh", "-i", "key", "-p", "2222", "--", "me@example.com", "'/opt/noms' serve --stdio ~/'my db'"},
+		args("ssh://me@example.com:2222/~/my%20db", SSHOptions{Command: "ssh -i key", RemoteNoms: "/opt/noms"}))
+	assert.Equal([]string{"ssh", "--", "example.com", `'noms' serve --stdio '/it'\''s'`},
+		args("ssh://example.com/it's", SSHOptions{}))
+}
+
+func TestSSHArgsRejectsOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, hostile := range []string{
+		"ssh://-oProxyCommand=touch$IFS/tmp/pwned/db",
+		"ssh://-oProxyCommand=id@h/db",
+		"ssh://-oProxyCommand=id@example.com/db",
+		"ssh://-p2222/db",
+	} {
+		u, err := url.Parse(hostile)
+		assert.NoError(err)
+		args, err := sshArgs(u, SSHOptions{})
+		assert.Error(err, hostile)
+		assert.Nil(args, hostile)
+		assert.Panics(func() { NewSSHChunkStore(hostile, SSHOptions{}) }, hostile)
+	}
+}
+
+func TestSSHChunkStore(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := &chunks.MemoryStorage{}
+	server := NewRemoteDatabaseServer(storage.NewView(), 0)
+	defer server.Stop()
+
+	// Each connection is served by a separate call to ServeStdio, as each would
+	// be by a separate noms serve --stdio.
+	wg := &sync.WaitGroup{}
+	dial := func() (net.Conn, error) {
+		client, conn := net.Pipe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.ServeStdio(conn, conn)
+		}()
+		return client, nil
+	}
+	u, err := url.Parse("ssh://example.com/db")
+	assert.NoError(err)
+
+	db := NewDatabase(newSSHChunkStore(u, SSHOptions{}, dial))
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.String("over ssh"))
+	assert.NoError(err)
+	assert.True(types.String("over ssh").Equals(ds.HeadValue()))
+	db.Close()
+
+	view := NewDatabase(storage.NewView())
+	assert.True(types.String("over ssh").Equals(view.GetDataset("ds").HeadValue()))
+	view.Close()
+
+	// Each ServeStdio returns once its client hangs up.
+	wg.Wait()
+}
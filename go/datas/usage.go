@@ -0,0 +1,174 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Usage is the storage taken up by one of the values that a value holds.
+type Usage struct {
+	// Path is the path of the value within the one that holds it, e.g.
+	// .name or ["key"].
+	Path types.Path
+	// Unique is the number of bytes that only this value takes up: its own
+	// encoding within the one that holds it, and the chunks that no other
+	// value measured with it reaches. Removing the value frees them.
+	Unique uint64
+	// Shared is the number of bytes of the chunks that this value reaches and
+	// other values measured with it reach too.
+	Shared uint64
+}
+
+// sharedChunk is the owner of a chunk that's reachable from several values.
+const sharedChunk = -1
+
+type usageChunk struct {
+	size  uint64
+	refs  []hash.Hash
+	owner int
+}
+
+// MeasureUsage breaks the storage that |v| takes up down by the values that
+// it holds: the fields of a Struct, the entries of a Map, or the elements of
+// a List or Set. Other values hold none. A chunk that's reachable from one of
+// them is counted as unique to it if no other reaches it, and as shared by
+// each that does otherwise. |total| is the number of bytes of v's own
+// encoding and of every chunk that's reachable from v, which includes the
+// chunks of v's prolly tree, if it's a large collection, that no Usage
+// accounts for.
+//
+// Each reachable chunk is read once, but the Refs between them are kept in
+// memory until MeasureUsage returns.
+func MeasureUsage(db Database, v types.Value) (usage []Usage, total uint64) {
+	values := [][]types.Value{}
+	add := func(p types.PathPart, vs ...types.Value) {
+		usage = append(usage, Usage{Path: types.Path{p}})
+		values = append(values, vs)
+	}
+	switch v := v.(type) {
+	case types.Struct:
+		v.IterFields(func(name string, fv types.Value) {
+			add(types.NewFieldPath(name), fv)
+		})
+	case types.Map:
+		v.IterAll(func(k, mv types.Value) {
+			if types.ValueCanBePathIndex(k) {
+				add(types.NewIndexPath(k), k, mv)
+			} else {
+				add(types.NewHashIndexPath(k.Hash()), k, mv)
+			}
+		})
+	case types.List:
+		v.IterAll(func(ev types.Value, i uint64) {
+			add(types.NewIndexPath(types.Number(i)), ev)
+		})
+	case types.Set:
+		v.IterAll(func(ev types.Value) {
+			add(types.NewHashIndexPath(ev.Hash()), ev)
+		})
+	}
+
+	cs := db.chunkStore()
+	graph := map[hash.Hash]*usageChunk{}
+	roots := make([][]hash.Hash, len(values))
+	for i, vs := range values {
+		for _, cv := range vs {
+			usage[i].Unique += uint64(len(types.EncodeValue(cv, db).Data()))
+			roots[i] = append(roots[i], refHashes(cv)...)
+		}
+		claimChunks(cs, db, graph, roots[i], i)
+	}
+
+	for i := range usage {
+		walkChunks(graph, roots[i], func(c *usageChunk) {
+			if c.owner == i {
+				usage[i].Unique += c.size
+			} else {
+				usage[i].Shared += c.size
+			}
+		})
+	}
+
+	// Claiming v's chunks for no value in particular reads the ones that only
+	// its own prolly tree reaches.
+	total = uint64(len(types.EncodeValue(v, db).Data()))
+	all := refHashes(v)
+	claimChunks(cs, db, graph, all, sharedChunk)
+	walkChunks(graph, all, func(c *usageChunk) {
+		total += c.size
+	})
+	return
+}
+
+func refHashes(v types.Value) (hs []hash.Hash) {
+	for _, r := range getChunks(v) {
+		hs = append(hs, r.TargetHash())
+	}
+	return
+}
+
+// claimChunks marks the chunks reachable from |roots| as owned by |owner|,
+// or shared if another owner has claimed them already, reading those that
+// aren't in |graph| yet. It doesn't descend into chunks that are already
+// shared, since what they reach is too.
+func claimChunks(cs chunks.ChunkStore, vr types.ValueReader, graph map[hash.Hash]*usageChunk, roots []hash.Hash, owner int) {
+	visited := hash.HashSet{}
+	next := roots
+	for len(next) > 0 {
+		toRead := hash.HashSet{}
+		for _, h := range next {
+			if _, ok := graph[h]; !ok {
+				toRead.Insert(h)
+			}
+		}
+		if len(toRead) > 0 {
+			found := make(chan *chunks.Chunk, len(toRead))
+			cs.GetMany(toRead, found)
+			close(found)
+			for c := range found {
+				graph[c.Hash()] = &usageChunk{uint64(len(c.Data())), refHashes(types.DecodeValue(*c, vr)), owner}
+			}
+		}
+
+		current := next
+		next = nil
+		for _, h := range current {
+			c, ok := graph[h]
+			if !ok || visited.Has(h) {
+				continue
+			}
+			visited.Insert(h)
+			// Chunks that were just read are claimed already.
+			if !toRead.Has(h) {
+				if c.owner == sharedChunk || c.owner == owner {
+					continue
+				}
+				c.owner = sharedChunk
+			}
+			next = append(next, c.refs...)
+		}
+	}
+}
+
+// walkChunks calls |cb| once for each chunk in |graph| that's reachable from
+// |roots|.
+func walkChunks(graph map[hash.Hash]*usageChunk, roots []hash.Hash, cb func(c *usageChunk)) {
+	visited := hash.HashSet{}
+	next := roots
+	for len(next) > 0 {
+		h := next[len(next)-1]
+		next = next[:len(next)-1]
+		c, ok := graph[h]
+		if !ok || visited.Has(h) {
+			continue
+		}
+		visited.Insert(h)
+		cb(c)
+		next = append(next, c.refs...)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMeasureUsage(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	size := func(v types.Value) uint64 {
+		return uint64(len(types.EncodeValue(v, db).Data()))
+	}
+
+	// |shared| is reachable from two fields, and |own| from one, by way of
+	// |inner|.
+	shared := types.NewList(types.String("shared"), types.Number(1))
+	own := types.NewSet(types.String("own"))
+	inner := types.NewStruct("Inner", types.StructData{"own": db.WriteValue(own)})
+	sharedRef := db.WriteValue(shared)
+	innerRef := db.WriteValue(inner)
+	v := types.NewStruct("", types.StructData{
+		"a":    sharedRef,
+		"b":    sharedRef,
+		"c":    innerRef,
+		"name": types.String("usage"),
+	})
+	ds, err := db.CommitValue(db.GetDataset("ds"), v)
+	assert.NoError(err)
+
+	usage, total := MeasureUsage(db, ds.HeadValue())
+	assert.Equal([]Usage{
+		{types.MustParsePath(".a"), size(sharedRef), size(shared)},
+		{types.MustParsePath(".b"), size(sharedRef), size(shared)},
+		{types.MustParsePath(".c"), size(innerRef) + size(inner) + size(own), 0},
+		{types.MustParsePath(".name"), size(types.String("usage")), 0},
+	}, usage)
+	assert.Equal(size(v)+size(shared)+size(inner)+size(own), total)
+
+	// Map entries count their keys too.
+	m := types.NewMap(types.String("k"), sharedRef, types.Number(2), types.Bool(true))
+	usage, total = MeasureUsage(db, m)
+	assert.Equal([]Usage{
+		{types.MustParsePath("[2]"), size(types.Number(2)) + size(types.Bool(true)), 0},
+		{types.MustParsePath(`["k"]`), size(types.String("k")) + size(sharedRef) + size(shared), 0},
+	}, usage)
+	assert.Equal(size(m)+size(shared), total)
+
+	// Primitives hold nothing.
+	usage, total = MeasureUsage(db, types.String("usage"))
+	assert.Empty(usage)
+	assert.Equal(size(types.String("usage")), total)
+}
@@ -44,3 +44,21 @@ func (vcs *validatingChunkStore) validate() {
 	defer vcs.mu.Unlock()
 	vcs.cc.PanicIfDangling(vcs.ChunkStore)
 }
+
+// HashesWithPrefix forwards to the underlying ChunkStore if it is a
+// chunks.HashPrefixLister, and otherwise finds nothing.
+func (vcs *validatingChunkStore) HashesWithPrefix(prefix string) hash.HashSet {
+	if l, ok := vcs.ChunkStore.(chunks.HashPrefixLister); ok {
+		return l.HashesWithPrefix(prefix)
+	}
+	return hash.HashSet{}
+}
+
+// SubscribeRootContention forwards to the underlying ChunkStore if it is a
+// chunks.RootContentionNotifier, and otherwise never notifies.
+func (vcs *validatingChunkStore) SubscribeRootContention(f func(chunks.RootContention)) (unsubscribe func()) {
+	if n, ok := vcs.ChunkStore.(chunks.RootContentionNotifier); ok {
+		return n.SubscribeRootContention(f)
+	}
+	return func() {}
+}
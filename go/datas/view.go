@@ -0,0 +1,252 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	viewMetaName     = "ViewMeta"
+	viewSourcesField = "sources"
+)
+
+// ViewSource describes how one source Dataset of a View has changed since the
+// View was last derived.
+type ViewSource struct {
+	ID string
+	// Old is the value of the source's head when the View was last derived,
+	// or nil if the source had no head then or the View has never been
+	// derived.
+	Old types.Value
+	// New is the value of the source's current head, or nil if it has none.
+	New types.Value
+}
+
+// Changed returns true if the head of the source has moved since the View was
+// last derived. Unchanged sources are included in the ViewSources passed to a
+// ViewFunc so that it can read their values.
+func (s ViewSource) Changed() bool {
+	if s.Old == nil || s.New == nil {
+		return s.Old != s.New
+	}
+	return !s.Old.Equals(s.New)
+}
+
+// ViewFunc computes the new value of a View. |prev| is the value it returned
+// last time, or nil if the View has never been derived. |sources| has an entry
+// for each of the View's sources, in order. A ViewFunc can work incrementally
+// by diffing the Old and New values of the changed sources and applying the
+// result to |prev|. Any values it writes should be written to |vrw|.
+type ViewFunc func(vrw types.ValueReadWriter, prev types.Value, sources []ViewSource) (types.Value, error)
+
+// View is a Dataset whose value is derived from the heads of other Datasets.
+type View struct {
+	// ID is the ID of the derived Dataset.
+	ID string
+	// Sources are the IDs of the Datasets that the View is derived from.
+	Sources []string
+	Derive  ViewFunc
+}
+
+// UpdateView brings the Dataset view.ID up to date with the heads of
+// view.Sources. If any source has moved since the View was last derived,
+// view.Derive is called and its result committed. The meta of each derived
+// Commit is a ViewMeta struct whose "sources" field maps the ID of each source
+// to the Ref of the head Commit it was derived from; this is also how
+// UpdateView finds the Old values of the sources next time. If nothing has
+// changed no Commit is made. The latest snapshot of view.ID is returned. If a
+// recorded source head isn't a Ref to a Commit, a *ViewUpdateError is
+// returned.
+func UpdateView(db Database, view View) (Dataset, error) {
+	ds := db.GetDataset(view.ID)
+
+	var prev types.Value
+	recorded := types.NewMap()
+	if head, ok := ds.MaybeHead(); ok {
+		if m, ok := recordedSources(head); ok {
+			prev, recorded = head.Get(ValueField), m
+		}
+	}
+
+	heads := types.NewMap()
+	sources := make([]ViewSource, len(view.Sources))
+	for i, id := range view.Sources {
+		sources[i].ID = id
+		if r, ok := recorded.MaybeGet(types.String(id)); ok {
+			old, ok := recordedValue(db, r)
+			if !ok {
+				return ds, &ViewUpdateError{view.ID, fmt.Errorf("Recorded head of source %s isn't a Ref to a Commit", id)}
+			}
+			sources[i].Old = old
+		}
+		if src, ok := db.GetDataset(id).MaybeHead(); ok {
+			heads = heads.Set(types.String(id), types.NewRef(src))
+			sources[i].New = src.Get(ValueField)
+		}
+	}
+	if ds.HasHead() && heads.Equals(recorded) {
+		return ds, nil
+	}
+
+	v, err := view.Derive(db, prev, sources)
+	if err != nil {
+		return ds, err
+	}
+	d.PanicIfTrue(v == nil)
+	meta := types.NewStruct(viewMetaName, types.StructData{viewSourcesField: heads})
+	return db.Commit(ds, v, CommitOptions{Meta: meta})
+}
+
+// recordedSources returns the Map of source heads that UpdateView recorded in
+// the meta of |head|, and false if |head| wasn't committed by UpdateView, so
+// its meta has no such Map. The View is then derived afresh, as if it had no
+// head.
+func recordedSources(head types.Struct) (types.Map, bool) {
+	if meta, ok := head.MaybeGet(MetaField); ok {
+		if meta, ok := meta.(types.Struct); ok {
+			if m, ok := meta.MaybeGet(viewSourcesField); ok {
+				if m, ok := m.(types.Map); ok {
+					return m, true
+				}
+			}
+		}
+	}
+	return types.Map{}, false
+}
+
+// recordedValue returns the value of the Commit that |r|, a recorded source
+// head, refers to, and false if it doesn't refer to a Commit.
+func recordedValue(vr types.ValueReader, r types.Value) (types.Value, bool) {
+	if r, ok := r.(types.Ref); ok {
+		if c := r.TargetValue(vr); c != nil && IsCommit(c) {
+			return c.(types.Struct).Get(ValueField), true
+		}
+	}
+	return nil, false
+}
+
+// ViewUpdateError is returned by the Database returned from NewViewDatabase
+// when a commit succeeded but a View derived from the committed Dataset could
+// not be updated.
+type ViewUpdateError struct {
+	ViewID string
+	Err    error
+}
+
+func (e *ViewUpdateError) Error() string {
+	return fmt.Sprintf("Updating view %s: %s", e.ViewID, e.Err)
+}
+
+type viewDatabase struct {
+	Database
+	views []View
+}
+
+// NewViewDatabase returns a Database that keeps |views| up to date: after each
+// successful Commit, SetHead or FastForward of a Dataset, every View with
+// that Dataset among its Sources is updated with UpdateView, which in turn
+// updates Views derived from that View. The Views must not depend on each
+// other cyclically.
+//
+// The source update is not undone if a View can't be updated. Instead, the
+// error is returned as a *ViewUpdateError, and the View will catch up on its
+// next update.
+func NewViewDatabase(db Database, views ...View) Database {
+	vdb := &viewDatabase{db, views}
+	for _, v := range views {
+		vdb.checkAcyclic(v.ID, map[string]bool{})
+	}
+	return vdb
+}
+
+func (vdb *viewDatabase) checkAcyclic(id string, visiting map[string]bool) {
+	if visiting[id] {
+		d.Panic("Views derived from dataset %s form a cycle", id)
+	}
+	visiting[id] = true
+	for _, v := range vdb.views {
+		for _, src := range v.Sources {
+			if src == id {
+				vdb.checkAcyclic(v.ID, visiting)
+			}
+		}
+	}
+	delete(visiting, id)
+}
+
+// own makes |ds| refer to vdb, so that commits made through ds.Database() also
+// update the views.
+func (vdb *viewDatabase) own(ds Dataset) Dataset {
+	ds.db = vdb
+	return ds
+}
+
+func (vdb *viewDatabase) afterUpdate(ds Dataset, err error) (Dataset, error) {
+	if err != nil {
+		return vdb.own(ds), err
+	}
+	for _, v := range vdb.views {
+		for _, src := range v.Sources {
+			if src != ds.ID() {
+				continue
+			}
+			if _, err := vdb.updateView(v); err != nil {
+				if _, ok := err.(*ViewUpdateError); !ok {
+					err = &ViewUpdateError{v.ID, err}
+				}
+				return vdb.own(ds), err
+			}
+			break
+		}
+	}
+	return vdb.own(ds), nil
+}
+
+func (vdb *viewDatabase) updateView(v View) (Dataset, error) {
+	ds, err := UpdateView(vdb.Database, v)
+	if err != nil {
+		return ds, err
+	}
+	return vdb.afterUpdate(ds, nil)
+}
+
+func (vdb *viewDatabase) GetDataset(datasetID string) Dataset {
+	return vdb.own(vdb.Database.GetDataset(datasetID))
+}
+
+func (vdb *viewDatabase) Commit(ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
+	return vdb.afterUpdate(vdb.Database.Commit(ds, v, opts))
+}
+
+func (vdb *viewDatabase) CommitValue(ds Dataset, v types.Value) (Dataset, error) {
+	return vdb.Commit(ds, v, CommitOptions{})
+}
+
+func (vdb *viewDatabase) SetHead(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	return vdb.afterUpdate(vdb.Database.SetHead(ds, newHeadRef))
+}
+
+func (vdb *viewDatabase) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	return vdb.afterUpdate(vdb.Database.FastForward(ds, newHeadRef))
+}
+
+func (vdb *viewDatabase) replaceHead(ds Dataset, newHeadRef types.Ref, dropReflog bool) (Dataset, error) {
+	ds, err := vdb.Database.replaceHead(ds, newHeadRef, dropReflog)
+	return vdb.own(ds), err
+}
+
+func (vdb *viewDatabase) Delete(ds Dataset) (Dataset, error) {
+	ds, err := vdb.Database.Delete(ds)
+	return vdb.own(ds), err
+}
+
+func (vdb *viewDatabase) RenameDataset(ds Dataset, newDatasetID string) (Dataset, error) {
+	ds, err := vdb.Database.RenameDataset(ds, newDatasetID)
+	return vdb.own(ds), err
+}
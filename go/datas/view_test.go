@@ -0,0 +1,202 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// sumView derives the total of the values of the Map in dataset "counts",
+// applying the diff since the last derivation rather than starting over.
+func sumView(calls *int) View {
+	return View{
+		ID:      "total",
+		Sources: []string{"counts"},
+		Derive: func(vrw types.ValueReadWriter, prev types.Value, sources []ViewSource) (types.Value, error) {
+			*calls++
+			total := types.Number(0)
+			if prev != nil {
+				total = prev.(types.Number)
+			}
+			last := types.NewMap()
+			if sources[0].Old != nil {
+				last = sources[0].Old.(types.Map)
+			}
+			current := types.NewMap()
+			if sources[0].New != nil {
+				current = sources[0].New.(types.Map)
+			}
+
+			changes := make(chan types.ValueChanged)
+			go func() {
+				current.Diff(last, changes, nil)
+				close(changes)
+			}()
+			for c := range changes {
+				if c.OldValue != nil {
+					total -= c.OldValue.(types.Number)
+				}
+				if c.NewValue != nil {
+					total += c.NewValue.(types.Number)
+				}
+			}
+			return total, nil
+		},
+	}
+}
+
+func TestUpdateView(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	calls := 0
+	view := sumView(&calls)
+
+	// With no source heads, the view is derived from nothing.
+	ds, err := UpdateView(db, view)
+	assert.NoError(err)
+	assert.True(types.Number(0).Equals(ds.HeadValue()))
+	assert.Equal(1, calls)
+
+	src, err := db.CommitValue(db.GetDataset("counts"), types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2)))
+	assert.NoError(err)
+	ds, err = UpdateView(db, view)
+	assert.NoError(err)
+	assert.True(types.Number(3).Equals(ds.HeadValue()))
+	assert.Equal(2, calls)
+
+	// The derived commit records the source head it was derived from.
+	meta := ds.Head().Get(MetaField).(types.Struct)
+	assert.Equal(viewMetaName, meta.Name())
+	assert.True(src.HeadRef().Equals(meta.Get(viewSourcesField).(types.Map).Get(types.String("counts"))))
+
+	// Nothing changed, so there's no new commit.
+	head := ds.HeadRef()
+	ds, err = UpdateView(db, view)
+	assert.NoError(err)
+	assert.True(head.Equals(ds.HeadRef()))
+	assert.Equal(2, calls)
+
+	m := src.HeadValue().(types.Map)
+	src, err = db.CommitValue(src, m.Set(types.String("a"), types.Number(10)).Remove(types.String("b")).Set(types.String("c"), types.Number(5)))
+	assert.NoError(err)
+	ds, err = UpdateView(db, view)
+	assert.NoError(err)
+	assert.True(types.Number(15).Equals(ds.HeadValue()))
+	assert.True(ds.Head().Get(ParentsField).(types.Set).Has(head))
+
+	boom := errors.New("boom")
+	_, err = db.CommitValue(src, types.NewMap())
+	assert.NoError(err)
+	ds, err = UpdateView(db, View{ID: view.ID, Sources: view.Sources, Derive: func(types.ValueReadWriter, types.Value, []ViewSource) (types.Value, error) {
+		return nil, boom
+	}})
+	assert.Equal(boom, err)
+	assert.True(types.Number(15).Equals(ds.HeadValue()))
+}
+
+func TestUpdateViewForeignHead(t *testing.T) {
+	assert := assert.New(t)
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	_, err := db.CommitValue(db.GetDataset("counts"), types.NewMap(types.String("a"), types.Number(1)))
+	assert.NoError(err)
+
+	// Heads of the view's dataset that UpdateView didn't commit, so that their
+	// meta doesn't say what they were derived from, are derived afresh.
+	calls := 0
+	view := sumView(&calls)
+	for _, meta := range []types.Struct{
+		types.NewStruct("", types.StructData{}),
+		types.NewStruct(viewMetaName, types.StructData{viewSourcesField: types.Number(1)}),
+	} {
+		_, err = db.Commit(db.GetDataset(view.ID), types.Number(42), CommitOptions{Meta: meta})
+		assert.NoError(err)
+		ds, err := UpdateView(db, view)
+		assert.NoError(err)
+		assert.True(types.Number(1).Equals(ds.HeadValue()))
+	}
+	assert.Equal(2, calls)
+
+	// Recorded sources that aren't Refs to Commits are an error.
+	for _, recorded := range []types.Value{
+		types.Number(1),
+		db.WriteValue(types.Number(1)),
+	} {
+		meta := types.NewStruct(viewMetaName, types.StructData{
+			viewSourcesField: types.NewMap(types.String("counts"), recorded),
+		})
+		_, err = db.Commit(db.GetDataset(view.ID), types.Number(42), CommitOptions{Meta: meta})
+		assert.NoError(err)
+		_, err := UpdateView(db, view)
+		assert.IsType(&ViewUpdateError{}, err)
+	}
+	assert.Equal(2, calls)
+}
+
+func TestViewDatabase(t *testing.T) {
+	assert := assert.New(t)
+	inner := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer inner.Close()
+
+	calls := 0
+	double := View{
+		ID:      "double",
+		Sources: []string{"total"},
+		Derive: func(vrw types.ValueReadWriter, prev types.Value, sources []ViewSource) (types.Value, error) {
+			return sources[0].New.(types.Number) * 2, nil
+		},
+	}
+	db := NewViewDatabase(inner, sumView(&calls), double)
+
+	src, err := db.CommitValue(db.GetDataset("counts"), types.NewMap(types.String("a"), types.Number(1)))
+	assert.NoError(err)
+	assert.True(types.Number(1).Equals(db.GetDataset("total").HeadValue()))
+	assert.True(types.Number(2).Equals(db.GetDataset("double").HeadValue()))
+
+	// Commits through the returned Dataset's Database are seen too.
+	m := src.HeadValue().(types.Map)
+	src, err = src.Database().CommitValue(src, m.Set(types.String("b"), types.Number(3)))
+	assert.NoError(err)
+	assert.True(types.Number(4).Equals(db.GetDataset("total").HeadValue()))
+	assert.True(types.Number(8).Equals(db.GetDataset("double").HeadValue()))
+	assert.Equal(2, calls)
+
+	// Commits to unrelated datasets don't cause derivations.
+	_, err = db.CommitValue(db.GetDataset("other"), types.Bool(true))
+	assert.NoError(err)
+	assert.Equal(2, calls)
+
+	// A failed view update doesn't undo the source commit.
+	boom := errors.New("boom")
+	failing := NewViewDatabase(inner, View{"fails", []string{"counts"}, func(types.ValueReadWriter, types.Value, []ViewSource) (types.Value, error) {
+		return nil, boom
+	}})
+	src, err = failing.CommitValue(failing.GetDataset("counts"), types.NewMap())
+	assert.Equal(&ViewUpdateError{"fails", boom}, err)
+	assert.True(types.NewMap().Equals(src.HeadValue()))
+}
+
+func TestViewDatabaseCycle(t *testing.T) {
+	db := NewDatabase((&chunks.MemoryStorage{}).NewView())
+	defer db.Close()
+
+	derive := func(types.ValueReadWriter, types.Value, []ViewSource) (types.Value, error) {
+		return types.Bool(true), nil
+	}
+	assert.Panics(t, func() {
+		NewViewDatabase(db, View{"a", []string{"b"}, derive}, View{"b", []string{"c", "a"}, derive})
+	})
+	assert.NotPanics(t, func() {
+		NewViewDatabase(db, View{"a", []string{"b"}, derive}, View{"b", []string{"c"}, derive})
+	})
+}
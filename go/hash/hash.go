@@ -34,6 +34,7 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/attic-labs/noms/go/d"
 )
@@ -47,8 +48,9 @@ const (
 )
 
 var (
-	pattern   = regexp.MustCompile("^([0-9a-v]{" + strconv.Itoa(StringLen) + "})$")
-	emptyHash = Hash{}
+	pattern       = regexp.MustCompile("^([0-9a-v]{" + strconv.Itoa(StringLen) + "})$")
+	prefixPattern = regexp.MustCompile("^([0-9a-v]{1," + strconv.Itoa(StringLen) + "})$")
+	emptyHash     = Hash{}
 )
 
 // Hash is used to represent the hash of a Noms Value.
@@ -100,6 +102,25 @@ func Parse(s string) Hash {
 	return r
 }
 
+// MaybeParsePrefix parses an abbreviated hash, i.e. the first few characters
+// of the String() of a Hash. It returns the smallest and largest Hashes whose
+// String() begins with s. If s is not well formed then this returns
+// (emptyHash, emptyHash, false).
+func MaybeParsePrefix(s string) (lo, hi Hash, ok bool) {
+	if !prefixPattern.MatchString(s) {
+		return emptyHash, emptyHash, false
+	}
+	pad := StringLen - len(s)
+	lo = New(decode(s + strings.Repeat("0", pad)))
+	hi = New(decode(s + strings.Repeat("v", pad)))
+	return lo, hi, true
+}
+
+// HasPrefix returns true if the String() of this Hash begins with prefix.
+func (h Hash) HasPrefix(prefix string) bool {
+	return strings.HasPrefix(h.String(), prefix)
+}
+
 // Less compares two hashes returning whether this Hash is less than other.
 func (h Hash) Less(other Hash) bool {
 	return bytes.Compare(h[:], other[:]) < 0
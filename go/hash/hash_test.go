@@ -60,6 +60,31 @@ func TestMaybeParse(t *testing.T) {
 	parse("0000000000000000000000000000000w", false)
 }
 
+func TestMaybeParsePrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	h := Of([]byte("abc"))
+	for _, n := range []int{1, 5, 12, 13, StringLen} {
+		prefix := h.String()[:n]
+		lo, hi, ok := MaybeParsePrefix(prefix)
+		assert.True(ok)
+		assert.True(lo.HasPrefix(prefix))
+		assert.True(hi.HasPrefix(prefix))
+		assert.False(h.Less(lo))
+		assert.False(h.Greater(hi))
+	}
+
+	lo, hi, ok := MaybeParsePrefix("a")
+	assert.True(ok)
+	assert.Equal("a0000000000000000000000000000000", lo.String())
+	assert.Equal("avvvvvvvvvvvvvvvvvvvvvvvvvvvvvvv", hi.String())
+
+	for _, s := range []string{"", "w", "A1", "sha1-00", "000000000000000000000000000000000"} {
+		_, _, ok := MaybeParsePrefix(s)
+		assert.False(ok, "Expected failure for %s", s)
+	}
+}
+
 func TestEquals(t *testing.T) {
 	assert := assert.New(t)
 
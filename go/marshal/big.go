@@ -0,0 +1,99 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+var bigIntType = reflect.TypeOf(big.Int{})
+var bigFloatType = reflect.TypeOf(big.Float{})
+
+var bigIntNomsType = types.MakeStructTypeFromFields("BigInt", types.FieldMap{
+	"abs":  types.BlobType,
+	"sign": types.NumberType,
+})
+
+var bigIntTemplate = types.MakeStructTemplate("BigInt", []string{"abs", "sign"})
+
+var bigFloatNomsType = types.MakeStructTypeFromFields("BigFloat", types.FieldMap{
+	"prec":  types.NumberType,
+	"value": types.StringType,
+})
+
+var bigFloatTemplate = types.MakeStructTemplate("BigFloat", []string{"prec", "value"})
+
+// bigIntEncoder encodes a big.Int as its sign, -1, 0 or 1, and the big-endian
+// bytes of its absolute value.
+func bigIntEncoder(v reflect.Value, es *encodeState) types.Value {
+	i := v.Interface().(big.Int)
+	abs := types.NewBlob(bytes.NewReader(i.Bytes()))
+	return bigIntTemplate.NewStruct([]types.Value{abs, types.Number(i.Sign())})
+}
+
+// bigFloatEncoder encodes a big.Float as its precision and its exact value,
+// formatted with a hexadecimal mantissa and binary exponent.
+func bigFloatEncoder(v reflect.Value, es *encodeState) types.Value {
+	f := v.Interface().(big.Float)
+	return bigFloatTemplate.NewStruct([]types.Value{types.Number(f.Prec()), types.String(f.Text('p', 0))})
+}
+
+func bigIntDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	i := new(big.Int)
+	switch v := v.(type) {
+	case types.Number:
+		if _, acc := big.NewFloat(float64(v)).Int(i); acc != big.Exact {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", it isn't an integer", ""})
+		}
+	case types.Struct:
+		abs, ok := v.MaybeGet("abs")
+		sign, ok2 := v.MaybeGet("sign")
+		b, ok3 := abs.(types.Blob)
+		n, ok4 := sign.(types.Number)
+		if !ok || !ok2 || !ok3 || !ok4 {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct BigInt", ""})
+		}
+		data, err := ioutil.ReadAll(b.Reader())
+		if err != nil {
+			panic(&unmarshalNomsError{err})
+		}
+		i.SetBytes(data)
+		if n < 0 {
+			i.Neg(i)
+		}
+	default:
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
+	}
+	rv.Set(reflect.ValueOf(i).Elem())
+}
+
+func bigFloatDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	f := new(big.Float)
+	switch v := v.(type) {
+	case types.Number:
+		f.SetFloat64(float64(v))
+	case types.Struct:
+		prec, ok := v.MaybeGet("prec")
+		value, ok2 := v.MaybeGet("value")
+		p, ok3 := prec.(types.Number)
+		s, ok4 := value.(types.String)
+		if !ok || !ok2 || !ok3 || !ok4 || p < 0 || p > big.MaxPrec {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct BigFloat", ""})
+		}
+		if _, ok := f.SetPrec(uint(p)).SetString(string(s)); !ok {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", invalid value " + string(s), ""})
+		}
+		// Parsing raises a precision of 0, which only ±0 and ±Inf have, to 64.
+		f.SetPrec(uint(p))
+	default:
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
+	}
+	rv.Set(reflect.ValueOf(f).Elem())
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMarshalBigInt(t *testing.T) {
+	assert := assert.New(t)
+
+	huge, ok := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	assert.True(ok)
+	v, err := Marshal(*huge)
+	assert.NoError(err)
+	assert.True(types.NewStruct("BigInt", types.StructData{
+		"abs":  types.NewBlob(bytes.NewReader(new(big.Int).Abs(huge).Bytes())),
+		"sign": types.Number(-1),
+	}).Equals(v))
+
+	for _, i := range []*big.Int{huge, big.NewInt(0), big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), 1000)} {
+		var out big.Int
+		assert.NoError(Unmarshal(MustMarshal(*i), &out))
+		assert.Equal(0, i.Cmp(&out), "%s != %s", i, &out)
+	}
+
+	type S struct {
+		N *big.Int
+		Z big.Int `noms:",omitempty"`
+	}
+	v, err = Marshal(S{N: huge})
+	assert.NoError(err)
+	_, ok = v.(types.Struct).MaybeGet("z")
+	assert.False(ok)
+	var s S
+	assert.NoError(Unmarshal(v, &s))
+	assert.Equal(0, huge.Cmp(s.N))
+
+	var out big.Int
+	assert.NoError(Unmarshal(types.Number(1e20), &out))
+	assert.Equal("100000000000000000000", out.String())
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.Number(1.5), &out))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.String("1"), &out))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.NewStruct("BigInt", types.StructData{"sign": types.Number(1)}), &out))
+}
+
+func TestMarshalBigFloat(t *testing.T) {
+	assert := assert.New(t)
+
+	pi, _, err := big.ParseFloat("3.14159265358979323846264338327950288419716939937510", 10, 200, big.ToNearestEven)
+	assert.NoError(err)
+	v, err := Marshal(*pi)
+	assert.NoError(err)
+	assert.True(types.NewStruct("BigFloat", types.StructData{
+		"prec":  types.Number(200),
+		"value": types.String(pi.Text('p', 0)),
+	}).Equals(v))
+
+	for _, f := range []*big.Float{
+		pi,
+		new(big.Float).Neg(pi),
+		new(big.Float).SetPrec(500).SetInf(true),
+		new(big.Float).SetPrec(10).Neg(new(big.Float)),
+		new(big.Float),
+		big.NewFloat(math.MaxFloat64).SetPrec(1000).Mul(big.NewFloat(math.MaxFloat64), big.NewFloat(math.MaxFloat64)),
+	} {
+		var out big.Float
+		assert.NoError(Unmarshal(MustMarshal(*f), &out))
+		assert.Equal(0, f.Cmp(&out), "%s != %s", f, &out)
+		assert.Equal(f.Prec(), out.Prec())
+		assert.Equal(f.Signbit(), out.Signbit())
+	}
+
+	var out big.Float
+	assert.NoError(Unmarshal(types.Number(1.5), &out))
+	assert.Equal(0, big.NewFloat(1.5).Cmp(&out))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.NewStruct("BigFloat", types.StructData{
+		"prec":  types.Number(53),
+		"value": types.String("pi"),
+	}), &out))
+}
+
+func TestMarshalTypeBig(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		I big.Int
+		F *big.Float
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("S",
+		types.StructField{Name: "f", Type: bigFloatNomsType, Optional: true},
+		types.StructField{Name: "i", Type: bigIntNomsType},
+	).Equals(typ))
+
+	v, err := Marshal(S{F: big.NewFloat(1)})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ))
+}
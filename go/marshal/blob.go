@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// isByteSequence returns true if |t| is a slice or array of bytes.
+func isByteSequence(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
+// shouldEncodeAsBlob returns true if values of |t| are encoded as a Blob:
+// byte slices, unless tagged with "list", and byte arrays tagged with "blob".
+func shouldEncodeAsBlob(t reflect.Type, tags nomsTags) bool {
+	if !isByteSequence(t) {
+		return false
+	}
+	if t.Kind() == reflect.Array {
+		return tags.blob
+	}
+	return !tags.list
+}
+
+func blobEncoder(v reflect.Value, es *encodeState) types.Value {
+	var b []byte
+	if v.Kind() == reflect.Slice {
+		b = v.Bytes()
+	} else {
+		b = make([]byte, v.Len())
+		for i := range b {
+			b[i] = byte(v.Index(i).Uint())
+		}
+	}
+	return types.NewBlob(bytes.NewReader(b))
+}
+
+// bytesDecoder decodes a Blob onto a byte slice or array. Other values, such
+// as the Lists of Numbers that byte slices tagged with "list" are encoded as,
+// are decoded by |decoder|.
+func bytesDecoder(t reflect.Type, decoder decoderFunc) decoderFunc {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
+		blob, ok := v.(types.Blob)
+		if !ok {
+			decoder(v, rv, ds)
+			return
+		}
+		b, err := ioutil.ReadAll(blob.Reader())
+		if err != nil {
+			panic(&unmarshalNomsError{err})
+		}
+		if t.Kind() == reflect.Slice {
+			rv.SetBytes(b)
+			return
+		}
+		if len(b) != t.Len() {
+			panic(&UnmarshalTypeMismatchError{v, t, ", length does not match", ""})
+		}
+		for i, c := range b {
+			rv.Index(i).SetUint(uint64(c))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func blobOf(b ...byte) types.Blob {
+	return types.NewBlob(bytes.NewReader(b))
+}
+
+func TestMarshalBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Data  []byte
+		Hash  [3]byte `noms:",blob"`
+		Small [2]byte
+		Old   []byte `noms:",list"`
+		Empty []byte `noms:",omitempty"`
+	}
+	in := S{[]byte("hello"), [3]byte{1, 2, 3}, [2]byte{4, 5}, []byte{6}, nil}
+	v, err := Marshal(in)
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"data":  blobOf([]byte("hello")...),
+		"hash":  blobOf(1, 2, 3),
+		"small": types.NewList(types.Number(4), types.Number(5)),
+		"old":   types.NewList(types.Number(6)),
+	}).Equals(v))
+
+	var out S
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(in, out)
+
+	typ, err := MarshalType(in)
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ))
+
+	// Blobs and Lists decode onto either.
+	var b []byte
+	assert.NoError(Unmarshal(types.NewList(types.Number(7)), &b))
+	assert.Equal([]byte{7}, b)
+	var a [2]byte
+	assert.NoError(Unmarshal(blobOf(8, 9), &a))
+	assert.Equal([2]byte{8, 9}, a)
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(blobOf(8), &a))
+
+	var i interface{}
+	assert.NoError(Unmarshal(MustMarshal([]byte{10}), &i))
+	assert.Equal([]byte{10}, i)
+
+	type Bad struct {
+		Name string `noms:",blob"`
+	}
+	_, err = Marshal(Bad{})
+	assert.IsType(&InvalidTagError{}, err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"encoding/hex"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// EncoderFunc encodes |v|, a Go value of the type it's registered for, as a
+// Noms value. |vrw| is the ValueReadWriter given to MarshalVRW, or nil. See
+// RegisterEncoder.
+type EncoderFunc func(v reflect.Value, vrw types.ValueReadWriter) (types.Value, error)
+
+// DecoderFunc decodes the Noms value |v| onto |out|, a settable Go value of the
+// type it's registered for. See RegisterDecoder.
+type DecoderFunc func(v types.Value, out reflect.Value) error
+
+type customEncoder struct {
+	fn EncoderFunc
+	nt *types.Type
+}
+
+var customCodecs = struct {
+	sync.RWMutex
+	encoders map[reflect.Type]customEncoder
+	decoders map[reflect.Type]DecoderFunc
+}{encoders: map[reflect.Type]customEncoder{}, decoders: map[reflect.Type]DecoderFunc{}}
+
+// RegisterEncoder registers |fn| to encode all Go values of type |t|, so that
+// programs can choose how types they don't own, like time.Duration or types of
+// third-party packages, are encoded without wrapping them in types of their
+// own. A registered encoder takes precedence over the encoding Marshal would
+// otherwise use, including a Marshaler implementation. |nt| is the Noms type
+// of the values |fn| returns, for MarshalType; it may be nil if that isn't
+// known, in which case MarshalType fails for types holding a |t|.
+//
+// Encoders are looked up when Marshal first encodes a Go type, so they should
+// be registered before marshaling, e.g. in an init function. Registering a
+// second encoder for the same type replaces the first.
+func RegisterEncoder(t reflect.Type, nt *types.Type, fn EncoderFunc) {
+	customCodecs.Lock()
+	defer customCodecs.Unlock()
+	customCodecs.encoders[t] = customEncoder{fn, nt}
+}
+
+// RegisterDecoder registers |fn| to decode Noms values onto all Go values of
+// type |t|. Like RegisterEncoder, it takes precedence over the decoding
+// Unmarshal would otherwise use, and should be called before unmarshaling.
+// Registering a second decoder for the same type replaces the first.
+func RegisterDecoder(t reflect.Type, fn DecoderFunc) {
+	customCodecs.Lock()
+	defer customCodecs.Unlock()
+	customCodecs.decoders[t] = fn
+}
+
+func getCustomEncoder(t reflect.Type) (customEncoder, bool) {
+	customCodecs.RLock()
+	defer customCodecs.RUnlock()
+	ce, ok := customCodecs.encoders[t]
+	return ce, ok
+}
+
+func getCustomDecoder(t reflect.Type) DecoderFunc {
+	customCodecs.RLock()
+	defer customCodecs.RUnlock()
+	return customCodecs.decoders[t]
+}
+
+// hasCustomCodec returns true if an encoder or a decoder is registered for
+// |t|, in which case it isn't treated as an embedded struct.
+func hasCustomCodec(t reflect.Type) bool {
+	_, ok := getCustomEncoder(t)
+	return ok || getCustomDecoder(t) != nil
+}
+
+func customEncoderFunc(fn EncoderFunc) encoderFunc {
+	return func(v reflect.Value, es *encodeState) types.Value {
+		nv, err := fn(v, es.valueReadWriter())
+		if err != nil {
+			panic(&marshalNomsError{err})
+		}
+		return nv
+	}
+}
+
+func customDecoderFunc(fn DecoderFunc) decoderFunc {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
+		if err := fn(v, rv); err != nil {
+			panic(&unmarshalNomsError{err})
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// RegisterDurationAsString registers an encoder and a decoder that encode
+// time.Durations as Strings, such as "1h30m", rather than as Numbers of
+// nanoseconds. The decoder also accepts Numbers of nanoseconds, so that
+// values encoded before registering can still be decoded.
+func RegisterDurationAsString() {
+	RegisterEncoder(durationType, types.StringType, func(v reflect.Value, vrw types.ValueReadWriter) (types.Value, error) {
+		return types.String(time.Duration(v.Int()).String()), nil
+	})
+	RegisterDecoder(durationType, func(v types.Value, out reflect.Value) error {
+		switch v := v.(type) {
+		case types.String:
+			d, err := time.ParseDuration(string(v))
+			if err != nil {
+				return &UnmarshalTypeMismatchError{v, durationType, " (" + err.Error() + ")", ""}
+			}
+			out.SetInt(int64(d))
+		case types.Number:
+			out.SetInt(int64(v))
+		default:
+			return &UnmarshalTypeMismatchError{v, durationType, "", ""}
+		}
+		return nil
+	})
+}
+
+// RegisterUUID registers an encoder and a decoder that encode values of |t|,
+// which must be a [16]byte array type, as Strings in the canonical UUID form,
+// e.g. "6ba7b810-9dad-11d1-80b4-00c04fd430c8". Types like net.IP that
+// implement encoding.TextMarshaler are encoded as Strings without registering.
+func RegisterUUID(t reflect.Type) {
+	if t.Kind() != reflect.Array || t.Len() != 16 || t.Elem().Kind() != reflect.Uint8 {
+		panic(&UnsupportedTypeError{t, "UUIDs must be [16]byte arrays"})
+	}
+	RegisterEncoder(t, types.StringType, func(v reflect.Value, vrw types.ValueReadWriter) (types.Value, error) {
+		var b [16]byte
+		reflect.Copy(reflect.ValueOf(b[:]), v)
+		h := hex.EncodeToString(b[:])
+		return types.String(h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]), nil
+	})
+	RegisterDecoder(t, func(v types.Value, out reflect.Value) error {
+		s, ok := v.(types.String)
+		if !ok || len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return &UnmarshalTypeMismatchError{v, t, ", expected a UUID", ""}
+		}
+		b, err := hex.DecodeString(string(s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]))
+		if err != nil {
+			return &UnmarshalTypeMismatchError{v, t, ", expected a UUID", ""}
+		}
+		reflect.Copy(out, reflect.ValueOf(b))
+		return nil
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// celsius implements Marshaler, which the registered encoder overrides.
+type celsius float64
+
+func (c celsius) MarshalNoms(vrw types.ValueReadWriter) (types.Value, error) {
+	return types.Number(c), nil
+}
+
+type customID [16]byte
+
+func init() {
+	t := reflect.TypeOf(celsius(0))
+	RegisterEncoder(t, types.StringType, func(v reflect.Value, vrw types.ValueReadWriter) (types.Value, error) {
+		if v.Float() < -273.15 {
+			return nil, errors.New("below absolute zero")
+		}
+		return types.String(strconv.FormatFloat(v.Float(), 'g', -1, 64) + "C"), nil
+	})
+	RegisterDecoder(t, func(v types.Value, out reflect.Value) error {
+		s, ok := v.(types.String)
+		if !ok {
+			return errors.New("expected a String")
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(string(s), "C"), 64)
+		out.SetFloat(f)
+		return err
+	})
+	RegisterUUID(reflect.TypeOf(customID{}))
+	RegisterDurationAsString()
+}
+
+func TestRegisteredCodecs(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Temp    celsius
+		Key     customID
+		Timeout time.Duration
+		Backoff *time.Duration
+	}
+	id := customID{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	backoff := 2 * time.Second
+	v := MustMarshal(S{21.5, id, 90 * time.Minute, &backoff})
+	assert.True(types.NewStruct("S", types.StructData{
+		"temp":    types.String("21.5C"),
+		"key":     types.String("6ba7b810-9dad-11d1-80b4-00c04fd430c8"),
+		"timeout": types.String("1h30m0s"),
+		"backoff": types.String("2s"),
+	}).Equals(v), types.EncodedValue(v))
+
+	var out S
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(S{21.5, id, 90 * time.Minute, &backoff}, out)
+
+	// Durations encoded as Numbers still decode.
+	var d time.Duration
+	assert.NoError(Unmarshal(types.Number(5), &d))
+	assert.Equal(time.Duration(5), d)
+
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ), typ.Describe())
+
+	// Errors from registered functions are returned.
+	_, err = Marshal(celsius(-300))
+	assert.EqualError(err, "below absolute zero")
+	var c celsius
+	assert.EqualError(Unmarshal(types.Number(1), &c), "expected a String")
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.String("not-a-uuid"), &out.Key))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.Bool(true), &d))
+
+	assert.Panics(func() {
+		RegisterUUID(reflect.TypeOf([8]byte{}))
+	})
+}
@@ -6,9 +6,14 @@ package marshal
 
 import (
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -23,24 +28,71 @@ import (
 // its tag).  Unmarshal will only set exported fields of the struct.  The name
 // of the Go struct must match (ignoring case) the name of the Noms struct. All
 // exported fields on the Go struct must be present in the Noms struct, unless
-// the field on the Go struct is marked with the "omitempty" tag. Go struct
-// fields also support the "original" tag which causes the Go field to receive
-// the entire original unmarshaled Noms struct.
+// the field on the Go struct is marked with the "omitempty" or "optional"
+// tag. Go struct fields also support the "original" tag which causes the Go
+// field to receive the entire original unmarshaled Noms struct. If the Go
+// struct has a field with the "version=N" tag and the Noms struct holds an
+// older version, the migrations registered with RegisterMigration are applied
+// before decoding.
+//
+// Types that implement Unmarshaler or UnmarshalerFrom decode themselves, and
+// types with a decoder registered by RegisterDecoder are decoded by it.
+//
+// To unmarshal onto a Go pointer, Unmarshal decodes onto the value it points
+// to, allocating a new one if the pointer is nil. Like fields tagged with
+// "omitempty", pointer fields may be missing from the Noms struct, in which
+// case they are left unchanged.
+//
+// Fields tagged with "omitzero" may be missing from the Noms struct too. A
+// slice or map field tagged with it that isn't missing is never left nil,
+// even if the Noms collection is empty, so that empty and nil slices and
+// maps survive the round trip through Marshal and Unmarshal.
+//
+// A field tagged with "default=<literal>" may also be missing from the Noms
+// struct, in which case it's set to that default. Defaults can be given for
+// bool, number and string fields, and are parsed as by package strconv; string
+// defaults can't contain commas.
+//
+// A field tagged with `noms:",ref"` may hold a types.Ref, in which case the
+// value it refers to is decoded onto the field. Following the Ref requires a
+// ValueReader, so such values can only be decoded by UnmarshalVR.
+//
+// A numeric field tagged with `noms:",string"` is decoded from a
+// types.String of its decimal digits, as written by Marshal, and integers are
+// parsed exactly.
+//
+// Values of types that are encoded with encoding.TextMarshaler, as described
+// for Marshal, are decoded from a types.String with encoding.TextUnmarshaler.
+//
+// To unmarshal onto a big.Int or big.Float, the Noms value must be a struct
+// as written by Marshal, or a Number, which must be an integer for a big.Int.
 //
 // To unmarshal a Noms list or set into a slice, Unmarshal resets the slice
-// length to zero and then appends each element to the slice. If the Go slice
-// was nil a new slice is created when an element is added.
+// length to zero and then appends each element to the slice, in Noms order.
+// If the Go slice was nil a new slice is created when an element is added.
 //
 // To unmarshal a Noms list into a Go array, Unmarshal decodes Noms list
 // elements into corresponding Go array elements.
 //
+// To unmarshal a Noms blob into a Go byte slice or array, Unmarshal copies
+// its bytes. The array must be of the blob's length.
+//
 // To unmarshal a Noms map into a Go map, Unmarshal decodes Noms key and values
 // into corresponding Go array elements. If the Go map was nil a new map is
-// created if any value is set.
+// created if any value is set. Keys are decoded as values are, so a map can
+// be keyed by any comparable type, such as a struct, an array, a bool or a
+// Noms primitive. A key decoded into an interface must not be a collection,
+// which Go can't compare.
+//
+// To unmarshal a Noms set into a Go map, it must have a type of
+// map[<value-type>]struct{}. Unmarshal decodes into Go map keys corresponding
+// to the set values and assigns each key a value of struct{}{}. Fields tagged
+// with `noms:",set"` must hold a set; other such maps can hold a set or a map.
 //
-// To unmarshal a Noms set into a Go map, the field must be tagged with `noms:",set"`,
-// and it must have a type of map[<value-type>]struct{}. Unmarshal decodes into
-// Go map keys corresponding to the set values and assigns each key a value of struct{}{}.
+// To unmarshal onto a time.Time, the Noms value must be a struct with a Number
+// field secSinceEpoch, as written by Marshal, or a Number of seconds since the
+// epoch, as written by Marshal for fields tagged with `noms:",unixtime"`. The
+// time.Time is in the local time zone.
 //
 // When unmarshalling onto interface{} the following rules are used:
 //  - types.Bool -> bool
@@ -52,16 +104,86 @@ import (
 //    same rules.
 //  - types.Number -> float64
 //  - types.String -> string
+//  - types.Blob -> []byte
 //  - *types.Type -> *types.Type
 //  - types.Union -> interface
+//  - types.Struct -> the type registered for its name, when unmarshaling
+//    with a TypeRegistry
 //  - Everything else an error
 //
 // Unmarshal returns an UnmarshalTypeMismatchError if:
 //  - a Noms value is not appropriate for a given target type
 //  - a Noms number overflows the target type
 //  - a Noms list is decoded into a Go array of a different length
+// Its Path says where the value is, and prefixes its message, e.g.
+// `.orders[3].zip: Cannot unmarshal String into Go value of type int`. Go types
+// that can't be decoded at all cause an UnsupportedTypeError, and invalid tags
+// an InvalidTagError, whatever the value, so that callers can tell values that
+// don't match the schema of their Go types from Go types that are wrong.
 //
+// Fields of the Noms struct that no Go field corresponds to are ignored. See
+// UnmarshalOpt for failing on them instead, and UnmarshalLenient for decoding
+// values of the wrong kind.
 func Unmarshal(v types.Value, out interface{}) (err error) {
+	return unmarshal(v, out, &decodeState{})
+}
+
+// UnmarshalOpt is like Unmarshal, but decodes according to |opt|. Fields are
+// named according to its FieldNaming, as they are by MarshalOpt. With Strict
+// set, a field that would be dropped, or a missing one, is reported with an
+// UnmarshalTypeMismatchError, which helps catch schema drift between the
+// writers of a value and its readers. Without it, decoding is lenient: unlike
+// Unmarshal, Go fields that are missing from the Noms struct are left as they
+// are, at their zero value if |out| is new, rather than failing.
+func UnmarshalOpt(v types.Value, out interface{}, opt Opt) error {
+	return unmarshal(v, out, &decodeState{strict: opt.Strict, skipMissing: !opt.Strict, naming: opt.FieldNaming, foldCase: opt.CaseInsensitive})
+}
+
+// UnmarshalVR is like Unmarshal but reads the values referred to by the Refs
+// in fields tagged with `noms:",ref"` from |vr|.
+func UnmarshalVR(vr types.ValueReader, v types.Value, out interface{}) error {
+	return unmarshal(v, out, &decodeState{vr: vr})
+}
+
+// Coercion describes a value that UnmarshalLenient converted to a different
+// kind in order to decode it.
+type Coercion struct {
+	// Path is where the value was found, relative to the value being
+	// unmarshaled, e.g. `.tags[2]` or `.counts["a"]`.
+	Path string
+	// Value is the Noms value that was coerced.
+	Value types.Value
+	// Type is the type of the Go value it was decoded into.
+	Type reflect.Type
+}
+
+func (c Coercion) String() string {
+	return fmt.Sprintf("%s: %s coerced to %s", c.Path, types.EncodedValue(c.Value), c.Type)
+}
+
+// UnmarshalLenient is like Unmarshal, but rather than failing when a Noms value
+// is of the wrong kind for the Go value it's decoded into, it performs these
+// coercions where they lose nothing:
+//  - types.Number -> string, formatted as by strconv.FormatFloat(n, 'g', -1, 64)
+//  - types.String -> numeric types, if it holds a finite number, allowing
+//    surrounding whitespace
+//  - types.Bool -> numeric types, as 0 or 1
+//  - types.Number -> bool, if it's 0 or 1
+//
+// This helps with ingesting data written by loosely-typed clients. The
+// coercions that were applied are returned, in the order they were applied.
+// Values that can't be coerced fail as they do with Unmarshal.
+func UnmarshalLenient(v types.Value, out interface{}) ([]Coercion, error) {
+	ds := &decodeState{lenient: true}
+	err := unmarshal(v, out, ds)
+	return ds.coercions, err
+}
+
+func unmarshal(v types.Value, out interface{}, ds *decodeState) (err error) {
+	if ds == nil {
+		ds = &decodeState{}
+	}
+	depth := len(ds.path)
 	defer func() {
 		if r := recover(); r != nil {
 			switch r := r.(type) {
@@ -72,6 +194,17 @@ func Unmarshal(v types.Value, out interface{}) (err error) {
 			default:
 				panic(r)
 			}
+			// The decoders that panicked didn't pop their parts of the path,
+			// so it's the path of the value that didn't match, or, for an
+			// error from an Unmarshaler that unmarshaled on its own, the path
+			// of that Unmarshaler, which the error's path is relative to.
+			// Errors from unmarshaling with this state, e.g. by a
+			// FieldDecoder, have their full path already.
+			if e, ok := err.(*UnmarshalTypeMismatchError); ok && e != ds.pathed {
+				e.Path = ds.pathString() + e.Path
+				ds.pathed = e
+			}
+			ds.path = ds.path[:depth]
 		}
 	}()
 
@@ -81,20 +214,16 @@ func Unmarshal(v types.Value, out interface{}) (err error) {
 	}
 	rv = rv.Elem()
 	d := typeDecoder(rv.Type(), nomsTags{})
-	d(v, rv)
+	d(v, rv, ds)
 	return
 }
 
 // Unmarshals a Noms value into a Go value using the same rules as Unmarshal().
 // Panics on failure.
 func MustUnmarshal(v types.Value, out interface{}) {
-	rv := reflect.ValueOf(out)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		panic(&InvalidUnmarshalError{reflect.TypeOf(out)})
+	if err := Unmarshal(v, out); err != nil {
+		panic(err)
 	}
-	rv = rv.Elem()
-	d := typeDecoder(rv.Type(), nomsTags{})
-	d(v, rv)
 }
 
 // Unmarshaler is an interface types can implement to provide their own
@@ -112,6 +241,51 @@ type Unmarshaler interface {
 
 var unmarshalerInterface = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 
+// UnmarshalerFrom is an interface that types decoded from Noms structs can
+// implement to decode themselves field by field, using the same rules as
+// Unmarshal. Unlike Unmarshal, it lets types keep their state in unexported
+// fields. As with Unmarshaler, implement it on a pointer to the type:
+//
+//  func (t *MyType) UnmarshalNomsFrom(dec *FieldDecoder) error {
+//    return dec.Decode("count", &t.count)
+//  }
+type UnmarshalerFrom interface {
+	// UnmarshalNomsFrom decodes the fields given by dec, or returns an error.
+	UnmarshalNomsFrom(dec *FieldDecoder) error
+}
+
+var unmarshalerFromInterface = reflect.TypeOf((*UnmarshalerFrom)(nil)).Elem()
+
+// FieldDecoder decodes the fields of a Noms struct for an UnmarshalerFrom.
+type FieldDecoder struct {
+	s  types.Struct
+	ds *decodeState
+}
+
+// Name returns the name of the Noms struct.
+func (dec *FieldDecoder) Name() string {
+	return dec.s.Name()
+}
+
+// Has returns true if the Noms struct has a field named |name|.
+func (dec *FieldDecoder) Has(name string) bool {
+	_, ok := dec.s.MaybeGet(name)
+	return ok
+}
+
+// Decode decodes the field named |name| into |out|, following the same rules
+// as the Unmarshal call that's decoding the struct. It returns an
+// UnmarshalTypeMismatchError if there's no such field.
+func (dec *FieldDecoder) Decode(name string, out interface{}) error {
+	v, ok := dec.s.MaybeGet(name)
+	if !ok {
+		return &UnmarshalTypeMismatchError{dec.s, reflect.TypeOf(out), ", missing field \"" + name + "\"", ""}
+	}
+	dec.ds.push(pathPart{kind: '.', field: name})
+	defer dec.ds.pop()
+	return unmarshal(v, out, dec.ds)
+}
+
 // InvalidUnmarshalError describes an invalid argument passed to Unmarshal. (The
 // argument to Unmarshal must be a non-nil pointer.)
 type InvalidUnmarshalError struct {
@@ -135,6 +309,9 @@ type UnmarshalTypeMismatchError struct {
 	Value   types.Value
 	Type    reflect.Type // type of Go value it could not be assigned to
 	details string
+	// Path is where Value was found, relative to the value being
+	// unmarshaled, e.g. `.tags[2]`, or empty if it's that value.
+	Path string
 }
 
 func (e *UnmarshalTypeMismatchError) Error() string {
@@ -144,11 +321,15 @@ func (e *UnmarshalTypeMismatchError) Error() string {
 	} else {
 		ts = e.Type.String()
 	}
-	return fmt.Sprintf("Cannot unmarshal %s into Go value of type %s%s", types.TypeOf(e.Value).Describe(), ts, e.details)
+	msg := fmt.Sprintf("Cannot unmarshal %s into Go value of type %s%s", types.TypeOf(e.Value).Describe(), ts, e.details)
+	if e.Path != "" {
+		return e.Path + ": " + msg
+	}
+	return msg
 }
 
 func overflowError(v types.Number, t reflect.Type) *UnmarshalTypeMismatchError {
-	return &UnmarshalTypeMismatchError{v, t, fmt.Sprintf(" (%g does not fit in %s)", v, t)}
+	return &UnmarshalTypeMismatchError{v, t, fmt.Sprintf(" (%g does not fit in %s)", v, t), ""}
 }
 
 // unmarshalNomsError wraps errors from Marshaler.UnmarshalNoms. These should
@@ -161,12 +342,152 @@ func (e *unmarshalNomsError) Error() string {
 	return e.err.Error()
 }
 
-type decoderFunc func(v types.Value, rv reflect.Value)
+// decodeState is the state of a decode: its options, and the path of the
+// value being decoded. Decoders may be passed a nil *decodeState, which
+// decodes as Unmarshal does but doesn't track the path.
+type decodeState struct {
+	lenient     bool
+	strict      bool
+	skipMissing bool
+	naming      FieldNaming
+	foldCase    bool
+	path        []pathPart
+	coercions   []Coercion
+	registry    *TypeRegistry
+	vr          types.ValueReader
+
+	// pathed is the last error given its path, so that it isn't given it
+	// again by an enclosing unmarshal with this state.
+	pathed *UnmarshalTypeMismatchError
+
+	// pointers holds the pointers decoded from Refs, so that those to the
+	// same value are shared.
+	pointers map[sharedPointer]reflect.Value
+}
+
+type sharedPointer struct {
+	h hash.Hash
+	t reflect.Type
+}
+
+func (ds *decodeState) isLenient() bool {
+	return ds != nil && ds.lenient
+}
+
+func (ds *decodeState) isStrict() bool {
+	return ds != nil && ds.strict
+}
+
+// skipsMissing returns true if Go fields that are missing from the Noms struct
+// are left as they are, rather than failing, as UnmarshalOpt does without
+// Strict.
+func (ds *decodeState) skipsMissing() bool {
+	return ds != nil && ds.skipMissing
+}
+
+func (ds *decodeState) isCaseInsensitive() bool {
+	return ds != nil && ds.foldCase
+}
+
+func (ds *decodeState) fieldNaming() FieldNaming {
+	if ds == nil {
+		return LowerCamelCase
+	}
+	return ds.naming
+}
+
+// registeredType returns the Go type registered for Noms structs named
+// |name|, or nil if there isn't one.
+func (ds *decodeState) registeredType(name string) reflect.Type {
+	if ds == nil || ds.registry == nil {
+		return nil
+	}
+	return ds.registry.lookup(name)
+}
+
+func (ds *decodeState) valueReader() types.ValueReader {
+	if ds == nil {
+		return nil
+	}
+	return ds.vr
+}
+
+// pathPart is an element of the path of the value being decoded. It's only
+// formatted when the path is needed, which is rarely, so that tracking it is
+// cheap.
+type pathPart struct {
+	kind  byte // '.' for a struct field, '[' for an index, '@' for a map key
+	field string
+	index uint64
+	key   types.Value // the key or set element, for '[' with a non-nil key and '@'
+}
+
+func (p pathPart) String() string {
+	switch {
+	case p.kind == '.':
+		return "." + p.field
+	case p.kind == '@':
+		return "[" + types.EncodedValue(p.key) + "]@key"
+	case p.key != nil:
+		return "[" + types.EncodedValue(p.key) + "]"
+	}
+	return fmt.Sprintf("[%d]", p.index)
+}
+
+func (ds *decodeState) push(p pathPart) {
+	if ds != nil {
+		ds.path = append(ds.path, p)
+	}
+}
+
+func (ds *decodeState) pop() {
+	if ds != nil {
+		ds.path = ds.path[:len(ds.path)-1]
+	}
+}
+
+func (ds *decodeState) pathString() string {
+	if ds == nil {
+		return ""
+	}
+	parts := make([]string, len(ds.path))
+	for i, p := range ds.path {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, "")
+}
+
+func (ds *decodeState) coerced(v types.Value, t reflect.Type) {
+	ds.coercions = append(ds.coercions, Coercion{ds.pathString(), v, t})
+}
+
+type decoderFunc func(v types.Value, rv reflect.Value, ds *decodeState)
 
 func typeDecoder(t reflect.Type, tags nomsTags) decoderFunc {
+	if fn := getCustomDecoder(t); fn != nil {
+		return customDecoderFunc(fn)
+	}
 	if reflect.PtrTo(t).Implements(unmarshalerInterface) {
 		return marshalerDecoder(t)
 	}
+	if reflect.PtrTo(t).Implements(unmarshalerFromInterface) {
+		return unmarshalerFromDecoder(t)
+	}
+	if t == timeType {
+		return timeDecoder
+	}
+	switch t {
+	case bigIntType:
+		return bigIntDecoder
+	case bigFloatType:
+		return bigFloatDecoder
+	}
+	if isTextUnmarshaler(t, tags) {
+		return textDecoder
+	}
+	if tags.str {
+		return numberStringDecoder
+	}
 
 	switch t.Kind() {
 	case reflect.Bool:
@@ -184,8 +505,14 @@ func typeDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 	case reflect.Interface:
 		return interfaceDecoder(t)
 	case reflect.Slice:
+		if isByteSequence(t) {
+			return bytesDecoder(t, sliceDecoder(t))
+		}
 		return sliceDecoder(t)
 	case reflect.Array:
+		if isByteSequence(t) {
+			return bytesDecoder(t, arrayDecoder(t))
+		}
 		return arrayDecoder(t)
 	case reflect.Map:
 		if shouldMapDecodeFromSet(t, tags) {
@@ -197,63 +524,217 @@ func typeDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 		if t.Implements(nomsValueInterface) {
 			return nomsValueDecoder
 		}
-		fallthrough
+		return pointerDecoder(t, tags)
 	default:
 		panic(&UnsupportedTypeError{Type: t})
 	}
 }
 
-func boolDecoder(v types.Value, rv reflect.Value) {
+func pointerDecoder(t reflect.Type, tags nomsTags) decoderFunc {
+	d := decoderCache.get(t)
+	if d != nil {
+		return d
+	}
+
+	var decoder decoderFunc
+	// d waits until the decoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	followRefs := !decodesRefs(t.Elem())
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
+		ready.Wait()
+		if r, ok := v.(types.Ref); ok && followRefs {
+			ds.decodeSharedPointer(r, rv, decoder)
+			return
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		decoder(v, rv.Elem(), ds)
+	}
+
+	decoderCache.set(t, d)
+	decoder = typeDecoder(t.Elem(), tags)
+	return d
+}
+
+// decodesRefs returns true if values of |t| can be decoded from a types.Ref
+// themselves, so that pointers to them don't follow Refs, which pointers
+// marshaled with SharePointers are encoded as.
+func decodesRefs(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface || t.Implements(nomsValueInterface) ||
+		reflect.PtrTo(t).Implements(unmarshalerInterface) || reflect.PtrTo(t).Implements(unmarshalerFromInterface) ||
+		hasCustomCodec(t)
+}
+
+// decodeSharedPointer sets the pointer |rv| to the value that |r| refers to,
+// decoded by |decoder|. Pointers decoded from Refs to the same value share
+// it, as they did when they were marshaled with SharePointers.
+func (ds *decodeState) decodeSharedPointer(r types.Ref, rv reflect.Value, decoder decoderFunc) {
+	vr := ds.valueReader()
+	if vr == nil {
+		panic(&UnmarshalTypeMismatchError{r, rv.Type(), ", pointers encoded as Refs can only be unmarshaled by UnmarshalVR", ""})
+	}
+	k := sharedPointer{r.TargetHash(), rv.Type()}
+	if p, ok := ds.pointers[k]; ok {
+		rv.Set(p)
+		return
+	}
+	v := r.TargetValue(vr)
+	if v == nil {
+		panic(&UnmarshalTypeMismatchError{r, rv.Type(), ", the value it refers to is missing", ""})
+	}
+	p := reflect.New(rv.Type().Elem())
+	decoder(v, p.Elem(), ds)
+	if ds.pointers == nil {
+		ds.pointers = map[sharedPointer]reflect.Value{}
+	}
+	ds.pointers[k] = p
+	rv.Set(p)
+}
+
+func boolDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
 	if b, ok := v.(types.Bool); ok {
 		rv.SetBool(bool(b))
+	} else if n, ok := v.(types.Number); ok && ds.isLenient() && (n == 0 || n == 1) {
+		ds.coerced(v, rv.Type())
+		rv.SetBool(n == 1)
 	} else {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 }
 
-func stringDecoder(v types.Value, rv reflect.Value) {
+func stringDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
 	if s, ok := v.(types.String); ok {
 		rv.SetString(string(s))
+	} else if n, ok := v.(types.Number); ok && ds.isLenient() {
+		ds.coerced(v, rv.Type())
+		rv.SetString(strconv.FormatFloat(float64(n), 'g', -1, 64))
 	} else {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 }
 
-func floatDecoder(v types.Value, rv reflect.Value) {
+func timeDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	if s, ok := v.(types.Struct); ok {
+		if n, ok := s.MaybeGet("secSinceEpoch"); ok {
+			v = n
+		}
+	}
 	if n, ok := v.(types.Number); ok {
+		rv.Set(reflect.ValueOf(timeFromSecSinceEpoch(n)))
+	} else {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
+	}
+}
+
+// asNumber returns v if it's a Number. When decoding leniently, it also
+// coerces Bools, and Strings holding finite numbers, to Numbers. A String is
+// only coerced to an integer type if it holds an integer of the right sign.
+func asNumber(v types.Value, t reflect.Type, ds *decodeState) (types.Number, bool) {
+	if n, ok := v.(types.Number); ok || !ds.isLenient() {
+		return n, ok
+	}
+	switch v := v.(type) {
+	case types.String:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, false
+		}
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if f != math.Trunc(f) {
+				return 0, false
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if f != math.Trunc(f) || f < 0 {
+				return 0, false
+			}
+		}
+		ds.coerced(v, t)
+		return types.Number(f), true
+	case types.Bool:
+		ds.coerced(v, t)
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func floatDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	if n, ok := asNumber(v, rv.Type(), ds); ok {
 		rv.SetFloat(float64(n))
 	} else {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 }
 
-func intDecoder(v types.Value, rv reflect.Value) {
-	if n, ok := v.(types.Number); ok {
+func intDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	if n, ok := asNumber(v, rv.Type(), ds); ok {
 		i := int64(n)
 		if rv.OverflowInt(i) {
 			panic(overflowError(n, rv.Type()))
 		}
 		rv.SetInt(i)
 	} else {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 }
 
-func uintDecoder(v types.Value, rv reflect.Value) {
-	if n, ok := v.(types.Number); ok {
+func uintDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	if n, ok := asNumber(v, rv.Type(), ds); ok {
 		u := uint64(n)
 		if rv.OverflowUint(u) {
 			panic(overflowError(n, rv.Type()))
 		}
 		rv.SetUint(u)
 	} else {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 }
 
+// numberStringDecoder decodes a String of decimal digits, as written by Marshal
+// for fields tagged with `noms:",string"`, into a number. It parses integers
+// exactly, rather than by way of a float64. When decoding leniently, it also
+// decodes Numbers.
+func numberStringDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	s, ok := v.(types.String)
+	if !ok {
+		if _, ok := v.(types.Number); ok && ds.isLenient() {
+			ds.coerced(v, rv.Type())
+			typeDecoder(rv.Type(), nomsTags{})(v, rv, ds)
+			return
+		}
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
+	}
+	var err error
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(string(s), 10, rv.Type().Bits())
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(string(s), 10, rv.Type().Bits())
+		rv.SetUint(u)
+	default:
+		var f float64
+		f, err = strconv.ParseFloat(string(s), rv.Type().Bits())
+		rv.SetFloat(f)
+	}
+	if err != nil {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), fmt.Sprintf(" (%q is not a %s)", string(s), rv.Type()), ""})
+	}
+}
+
+// decoderCacheT maps Go types to their decoders. Like encoderCacheT, it's
+// read without locking.
 type decoderCacheT struct {
-	sync.RWMutex
-	m map[reflect.Type]decoderFunc
+	mu sync.Mutex   // serializes set
+	m  atomic.Value // map[reflect.Type]decoderFunc
 }
 
 var decoderCache = &decoderCacheT{}
@@ -263,26 +744,107 @@ var decoderCache = &decoderCacheT{}
 var setDecoderCache = &decoderCacheT{}
 
 func (c *decoderCacheT) get(t reflect.Type) decoderFunc {
-	c.RLock()
-	defer c.RUnlock()
-	return c.m[t]
+	m, _ := c.m.Load().(map[reflect.Type]decoderFunc)
+	return m[t]
 }
 
 func (c *decoderCacheT) set(t reflect.Type, d decoderFunc) {
-	c.Lock()
-	defer c.Unlock()
-	if c.m == nil {
-		c.m = map[reflect.Type]decoderFunc{}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, _ := c.m.Load().(map[reflect.Type]decoderFunc)
+	m := make(map[reflect.Type]decoderFunc, len(old)+1)
+	for k, v := range old {
+		m[k] = v
 	}
-	c.m[t] = d
+	m[t] = d
+	c.m.Store(m)
 }
 
 type decField struct {
 	name      string
 	decoder   decoderFunc
-	index     int
+	index     []int
 	omitEmpty bool
+	def       reflect.Value
 	original  bool
+	version   int
+}
+
+// decFields are the fields of a Go struct that are decoded, named according
+// to a FieldNaming.
+type decFields struct {
+	fields []decField
+	// known holds the names of the Noms fields that are decoded, for strict
+	// decoding. It's nil if the whole struct is kept in an "original" field.
+	known map[string]bool
+}
+
+func newDecFields(t reflect.Type, naming FieldNaming) *decFields {
+	sfs := structFields(t, naming)
+	fields := make([]decField, 0, len(sfs))
+	for _, sf := range sfs {
+		f, tags := sf.StructField, sf.tags
+		if tags.version > 0 {
+			versionEncoder(f, t, tags.version) // validates the tag
+		}
+
+		decoder := typeDecoder(f.Type, tags)
+		if tags.ref {
+			decoder = refDecoder(decoder)
+		}
+		if tags.omitZero && (f.Type.Kind() == reflect.Slice || f.Type.Kind() == reflect.Map) {
+			decoder = nonNilDecoder(decoder)
+		}
+
+		fields = append(fields, decField{
+			name:      tags.name,
+			decoder:   decoder,
+			index:     f.Index,
+			omitEmpty: tags.omitEmpty || tags.omitZero || tags.optional || isPointerField(f.Type),
+			def:       tags.def,
+			original:  tags.original,
+			version:   tags.version,
+		})
+	}
+
+	known := map[string]bool{}
+	for _, f := range fields {
+		if f.original {
+			known = nil
+			break
+		}
+		known[f.name] = true
+	}
+	return &decFields{fields, known}
+}
+
+// foldsTo returns true if the field |name| of |s| is decoded into a Go field
+// whose name matches it but for case, because |s| has no field of that name.
+func (df *decFields) foldsTo(s types.Struct, name string) bool {
+	for _, f := range df.fields {
+		if !strings.EqualFold(f.name, name) {
+			continue
+		}
+		if _, ok := s.MaybeGet(f.name); !ok {
+			if n, _, _ := getFold(s, f.name); n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getFold returns the first field of |s| whose name matches |name| but for
+// case.
+func getFold(s types.Struct, name string) (string, types.Value, bool) {
+	var fn string
+	var fv types.Value
+	s.IterFields(func(n string, v types.Value) {
+		if fv == nil && strings.EqualFold(n, name) {
+			fn, fv = n, v
+		}
+	})
+	return fn, fv, fv != nil
 }
 
 func structDecoder(t reflect.Type) decoderFunc {
@@ -295,45 +857,72 @@ func structDecoder(t reflect.Type) decoderFunc {
 		return d
 	}
 
-	fields := make([]decField, 0, t.NumField())
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		tags := getTags(f)
-		if tags.skip {
-			continue
+	// The fields for the default FieldNaming are found now, so that invalid
+	// tags are reported straight away. Other namings are only used by
+	// UnmarshalOpt, so their fields are found when they're first needed.
+	var byNaming [fieldNamings]*decFields
+	var once [fieldNamings]sync.Once
+	once[LowerCamelCase].Do(func() { byNaming[LowerCamelCase] = newDecFields(t, LowerCamelCase) })
+	fieldsFor := func(naming FieldNaming) *decFields {
+		once[naming].Do(func() { byNaming[naming] = newDecFields(t, naming) })
+		if byNaming[naming] == nil {
+			// Finding the fields panicked the first time, so it will again.
+			return newDecFields(t, naming)
 		}
-
-		validateField(f, t)
-
-		fields = append(fields, decField{
-			name:      tags.name,
-			decoder:   typeDecoder(f.Type, tags),
-			index:     i,
-			omitEmpty: tags.omitEmpty,
-			original:  tags.original,
-		})
+		return byNaming[naming]
 	}
 
-	d = func(v types.Value, rv reflect.Value) {
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
 		s, ok := v.(types.Struct)
 		if !ok {
-			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct"})
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct", ""})
 		}
 
+		df := fieldsFor(ds.fieldNaming())
+		fields, known := df.fields, df.known
+
 		for _, f := range fields {
-			sf := rv.Field(f.index)
+			if f.version > 0 {
+				s = migrate(t, s, f.name, f.version)
+				break
+			}
+		}
+
+		foldCase := ds.isCaseInsensitive()
+		if ds.isStrict() && known != nil {
+			s.IterFields(func(name string, _ types.Value) {
+				if !known[name] && !(foldCase && df.foldsTo(s, name)) {
+					panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", unknown field \"" + name + "\"", ""})
+				}
+			})
+		}
+
+		for _, f := range fields {
+			sf := rv.FieldByIndex(f.index)
+			if f.version > 0 {
+				f.decoder(types.Number(f.version), sf, nil)
+				continue
+			}
 			if f.original {
 				if sf.Type() != reflect.TypeOf(s) {
-					panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", field with tag \"original\" must have type Struct"})
+					panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", field with tag \"original\" must have type Struct", ""})
 				}
 				sf.Set(reflect.ValueOf(s))
 				continue
 			}
-			fv, ok := s.MaybeGet(f.name)
+			name := f.name
+			fv, ok := s.MaybeGet(name)
+			if !ok && foldCase {
+				name, fv, ok = getFold(s, name)
+			}
 			if ok {
-				f.decoder(fv, sf)
-			} else if !f.omitEmpty {
-				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", missing field \"" + f.name + "\""})
+				ds.push(pathPart{kind: '.', field: name})
+				f.decoder(fv, sf, ds)
+				ds.pop()
+			} else if f.def.IsValid() {
+				sf.Set(f.def)
+			} else if !f.omitEmpty && !ds.skipsMissing() {
+				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", missing field \"" + f.name + "\"", ""})
 			}
 		}
 	}
@@ -342,15 +931,50 @@ func structDecoder(t reflect.Type) decoderFunc {
 	return d
 }
 
-func nomsValueDecoder(v types.Value, rv reflect.Value) {
+// refDecoder decodes the value a Ref refers to with |decoder|. Values that
+// aren't Refs are decoded as they are.
+func refDecoder(decoder decoderFunc) decoderFunc {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
+		if r, ok := v.(types.Ref); ok {
+			vr := ds.valueReader()
+			if vr == nil {
+				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", fields tagged ref can only be unmarshaled by UnmarshalVR", ""})
+			}
+			target := r.TargetValue(vr)
+			if target == nil {
+				panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", the value it refers to is missing", ""})
+			}
+			v = target
+		}
+		decoder(v, rv, ds)
+	}
+}
+
+// nonNilDecoder decodes with |decoder| onto a slice or map, and then makes it
+// empty if it's nil.
+func nonNilDecoder(decoder decoderFunc) decoderFunc {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
+		decoder(v, rv, ds)
+		if !rv.IsNil() {
+			return
+		}
+		if rv.Kind() == reflect.Slice {
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, 0))
+		} else {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+	}
+}
+
+func nomsValueDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
 	if !reflect.TypeOf(v).AssignableTo(rv.Type()) {
-		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ""})
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), "", ""})
 	}
 	rv.Set(reflect.ValueOf(v))
 }
 
 func marshalerDecoder(t reflect.Type) decoderFunc {
-	return func(v types.Value, rv reflect.Value) {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
 		ptr := reflect.New(t)
 		err := ptr.Interface().(Unmarshaler).UnmarshalNoms(v)
 		if err != nil {
@@ -360,6 +984,21 @@ func marshalerDecoder(t reflect.Type) decoderFunc {
 	}
 }
 
+func unmarshalerFromDecoder(t reflect.Type) decoderFunc {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
+		s, ok := v.(types.Struct)
+		if !ok {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected struct", ""})
+		}
+		ptr := reflect.New(t)
+		err := ptr.Interface().(UnmarshalerFrom).UnmarshalNomsFrom(&FieldDecoder{s, ds})
+		if err != nil {
+			panic(&unmarshalNomsError{err})
+		}
+		rv.Set(ptr.Elem())
+	}
+}
+
 func iterListOrSlice(v types.Value, t reflect.Type, f func(c types.Value, i uint64)) {
 	switch v := v.(type) {
 	case types.List:
@@ -371,7 +1010,7 @@ func iterListOrSlice(v types.Value, t reflect.Type, f func(c types.Value, i uint
 			i++
 		})
 	default:
-		panic(&UnmarshalTypeMismatchError{v, t, ""})
+		panic(&UnmarshalTypeMismatchError{v, t, "", ""})
 	}
 }
 
@@ -382,21 +1021,23 @@ func sliceDecoder(t reflect.Type) decoderFunc {
 	}
 
 	var decoder decoderFunc
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	d = func(v types.Value, rv reflect.Value) {
+	// d waits until the decoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
 		var slice reflect.Value
 		if rv.IsNil() {
 			slice = rv
 		} else {
 			slice = rv.Slice(0, 0)
 		}
-		init.RLock()
-		defer init.RUnlock()
-		iterListOrSlice(v, t, func(v types.Value, _ uint64) {
+		ready.Wait()
+		iterListOrSlice(v, t, func(v types.Value, i uint64) {
 			elemRv := reflect.New(t.Elem()).Elem()
-			decoder(v, elemRv)
+			ds.push(pathPart{kind: '[', index: i})
+			decoder(v, elemRv, ds)
+			ds.pop()
 			slice = reflect.Append(slice, elemRv)
 		})
 		rv.Set(slice)
@@ -414,24 +1055,26 @@ func arrayDecoder(t reflect.Type) decoderFunc {
 	}
 
 	var decoder decoderFunc
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	d = func(v types.Value, rv reflect.Value) {
+	// d waits until the decoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
 		size := t.Len()
 		list, ok := v.(types.Collection)
 		if !ok {
-			panic(&UnmarshalTypeMismatchError{v, t, ""})
+			panic(&UnmarshalTypeMismatchError{v, t, "", ""})
 		}
 
 		l := int(list.Len())
 		if l != size {
-			panic(&UnmarshalTypeMismatchError{v, t, ", length does not match"})
+			panic(&UnmarshalTypeMismatchError{v, t, ", length does not match", ""})
 		}
-		init.RLock()
-		defer init.RUnlock()
+		ready.Wait()
 		iterListOrSlice(list, t, func(v types.Value, i uint64) {
-			decoder(v, rv.Index(int(i)))
+			ds.push(pathPart{kind: '[', index: i})
+			decoder(v, rv.Index(int(i)), ds)
+			ds.pop()
 		})
 	}
 
@@ -447,22 +1090,25 @@ func mapFromSetDecoder(t reflect.Type) decoderFunc {
 	}
 
 	var decoder decoderFunc
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	d = func(v types.Value, rv reflect.Value) {
+	// d waits until the decoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
 		m := rv
 
 		nomsSet, ok := v.(types.Set)
 		if !ok {
-			panic(&UnmarshalTypeMismatchError{v, t, `, field has "set" tag`})
+			panic(&UnmarshalTypeMismatchError{v, t, `, field has "set" tag`, ""})
 		}
 
-		init.RLock()
-		defer init.RUnlock()
+		ready.Wait()
 		nomsSet.IterAll(func(v types.Value) {
 			keyRv := reflect.New(t.Key()).Elem()
-			decoder(v, keyRv)
+			ds.push(pathPart{kind: '[', key: v})
+			decoder(v, keyRv, ds)
+			checkMapKey(v, keyRv)
+			ds.pop()
 			if m.IsNil() {
 				m = reflect.MakeMap(t)
 			}
@@ -476,6 +1122,40 @@ func mapFromSetDecoder(t reflect.Type) decoderFunc {
 	return d
 }
 
+// checkMapKey panics if |rv|, decoded from the key |v|, can't be a Go map
+// key. Keys of any comparable type, such as structs, arrays and Noms
+// primitives, can be decoded, but an interface may hold a value decoded from
+// a collection, which isn't comparable.
+func checkMapKey(v types.Value, rv reflect.Value) {
+	if !isHashable(rv) {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", map keys must be comparable", ""})
+	}
+}
+
+// isHashable returns true if |rv| can be a Go map key. Values of comparable
+// types can be, unless they hold an interface whose value isn't comparable.
+func isHashable(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Interface:
+		return rv.IsNil() || isHashable(rv.Elem())
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if !isHashable(rv.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if !isHashable(rv.Index(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return rv.Type().Comparable()
+}
+
 func mapDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 	d := decoderCache.get(t)
 	if d != nil {
@@ -484,30 +1164,37 @@ func mapDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 
 	var keyDecoder decoderFunc
 	var valueDecoder decoderFunc
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	d = func(v types.Value, rv reflect.Value) {
+	var setDecoder decoderFunc
+	// d waits until the decoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	d = func(v types.Value, rv reflect.Value, ds *decodeState) {
 		m := rv
 
-		// Special case decoding failure if it looks like the "set" tag is missing,
-		// because it's helpful.
-		if _, ok := v.(types.Set); ok && !tags.set {
-			panic(&UnmarshalTypeMismatchError{v, t, `, field missing "set" tag`})
+		ready.Wait()
+
+		// A map[T]struct{} can hold a Set without the "set" tag.
+		if _, ok := v.(types.Set); ok && setDecoder != nil {
+			setDecoder(v, rv, ds)
+			return
 		}
 
 		nomsMap, ok := v.(types.Map)
 		if !ok {
-			panic(&UnmarshalTypeMismatchError{v, t, ""})
+			panic(&UnmarshalTypeMismatchError{v, t, "", ""})
 		}
 
-		init.RLock()
-		defer init.RUnlock()
 		nomsMap.IterAll(func(k, v types.Value) {
 			keyRv := reflect.New(t.Key()).Elem()
-			keyDecoder(k, keyRv)
+			ds.push(pathPart{kind: '@', key: k})
+			keyDecoder(k, keyRv, ds)
+			checkMapKey(k, keyRv)
+			ds.pop()
 			valueRv := reflect.New(t.Elem()).Elem()
-			valueDecoder(v, valueRv)
+			ds.push(pathPart{kind: '[', key: k})
+			valueDecoder(v, valueRv, ds)
+			ds.pop()
 			if m.IsNil() {
 				m = reflect.MakeMap(t)
 			}
@@ -519,6 +1206,9 @@ func mapDecoder(t reflect.Type, tags nomsTags) decoderFunc {
 	decoderCache.set(t, d)
 	keyDecoder = typeDecoder(t.Key(), nomsTags{})
 	valueDecoder = typeDecoder(t.Elem(), nomsTags{})
+	if isEmptyStruct(t.Elem()) {
+		setDecoder = mapFromSetDecoder(t)
+	}
 	return d
 }
 
@@ -528,19 +1218,33 @@ func interfaceDecoder(t reflect.Type) decoderFunc {
 	}
 
 	if t != emptyInterface {
-		panic(&UnsupportedTypeError{Type: t})
+		// Only Noms structs of a type registered with a TypeRegistry can be
+		// decoded onto other interfaces.
+		return func(v types.Value, rv reflect.Value, ds *decodeState) {
+			s, ok := v.(types.Struct)
+			if !ok || ds.registeredType(s.Name()) == nil {
+				panic(&UnsupportedTypeError{Type: t})
+			}
+			rt := ds.registeredType(s.Name())
+			if !rt.Implements(t) {
+				panic(&UnmarshalTypeMismatchError{v, t, ", registered type " + rt.String() + " does not implement it", ""})
+			}
+			i := reflect.New(rt).Elem()
+			typeDecoder(rt, nomsTags{})(v, i, ds)
+			rv.Set(i)
+		}
 	}
 
-	return func(v types.Value, rv reflect.Value) {
+	return func(v types.Value, rv reflect.Value, ds *decodeState) {
 		// TODO: Go directly from value to go type
-		t := getGoTypeForNomsType(types.TypeOf(v), rv.Type(), v)
+		t := getGoTypeForNomsType(types.TypeOf(v), rv.Type(), v, ds)
 		i := reflect.New(t).Elem()
-		typeDecoder(t, nomsTags{})(v, i)
+		typeDecoder(t, nomsTags{})(v, i, ds)
 		rv.Set(i)
 	}
 }
 
-func getGoTypeForNomsType(nt *types.Type, rt reflect.Type, v types.Value) reflect.Type {
+func getGoTypeForNomsType(nt *types.Type, rt reflect.Type, v types.Value, ds *decodeState) reflect.Type {
 	switch nt.TargetKind() {
 	case types.BoolKind:
 		return reflect.TypeOf(false)
@@ -548,21 +1252,28 @@ func getGoTypeForNomsType(nt *types.Type, rt reflect.Type, v types.Value) reflec
 		return reflect.TypeOf(float64(0))
 	case types.StringKind:
 		return reflect.TypeOf("")
+	case types.BlobKind:
+		return bytesType
 	case types.ListKind, types.SetKind:
-		et := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[0], rt, v)
+		et := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[0], rt, v, ds)
 		return reflect.SliceOf(et)
 	case types.MapKind:
-		kt := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[0], rt, v)
-		vt := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[1], rt, v)
+		kt := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[0], rt, v, ds)
+		vt := getGoTypeForNomsType(nt.Desc.(types.CompoundDesc).ElemTypes[1], rt, v, ds)
 		return reflect.MapOf(kt, vt)
 	case types.UnionKind:
 		// Visit union types to raise potential errors
 		for _, ut := range nt.Desc.(types.CompoundDesc).ElemTypes {
-			getGoTypeForNomsType(ut, rt, v)
+			getGoTypeForNomsType(ut, rt, v, ds)
 		}
 		return emptyInterface
-	// case types.StructKind:
-	// 	reflect.StructOf was not added until Go 1.7
+	case types.StructKind:
+		// Structs of the same name are always simplified into one type, so this
+		// covers every struct a collection might hold with that name.
+		if t := ds.registeredType(nt.Desc.(types.StructDesc).Name); t != nil {
+			return t
+		}
+		panic(&UnmarshalTypeMismatchError{Value: v, Type: rt})
 	default:
 		panic(&UnmarshalTypeMismatchError{Value: v, Type: rt})
 	}
@@ -570,7 +1281,9 @@ func getGoTypeForNomsType(nt *types.Type, rt reflect.Type, v types.Value) reflec
 
 func shouldMapDecodeFromSet(rt reflect.Type, tags nomsTags) bool {
 	// map[T]struct{} `noms:,"set"`
-	return tags.set &&
-		rt.Elem().Kind() == reflect.Struct &&
-		rt.Elem().NumField() == 0
+	return tags.set && isEmptyStruct(rt.Elem())
+}
+
+func isEmptyStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
 }
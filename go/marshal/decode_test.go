@@ -13,7 +13,9 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
@@ -249,7 +251,7 @@ func TestDecodeTypeMismatch(t *testing.T) {
 	assertDecodeErrorMessage(t, types.String("hi!"), &s, "Cannot unmarshal String into Go value of type marshal.S, expected struct")
 	assertDecodeErrorMessage(t, types.NewStruct("S", types.StructData{
 		"x": types.String("hi"),
-	}), &s, "Cannot unmarshal String into Go value of type int")
+	}), &s, ".x: Cannot unmarshal String into Go value of type int")
 }
 
 func assertDecodeErrorMessage(t *testing.T, v types.Value, ptr interface{}, msg string) {
@@ -264,17 +266,14 @@ func TestDecodeInvalidTypes(tt *testing.T) {
 		assertDecodeErrorMessage(tt, types.Number(42), p, "Type is not supported, type: "+ts)
 	}
 
-	var ptr *bool
-	t(&ptr, "*bool")
-
 	var c chan bool
 	t(&c, "chan bool")
 
 	type Nested struct {
-		X *bool
+		X chan bool
 	}
 	var n Nested
-	t(&n, "*bool")
+	t(&n, "chan bool")
 }
 
 func TestDecodeOverflows(tt *testing.T) {
@@ -319,12 +318,26 @@ func TestDecodeMissingField(t *testing.T) {
 }
 
 func TestDecodeEmbeddedStruct(tt *testing.T) {
-	type EmbeddedStruct struct{}
+	assert := assert.New(tt)
+
+	type EmbeddedStruct struct {
+		X int
+		Y int `noms:",omitempty"`
+	}
 	type TestStruct struct {
 		EmbeddedStruct
+		Y string
 	}
 	var ts TestStruct
-	assertDecodeErrorMessage(tt, types.String("hi"), &ts, "Embedded structs are not supported, type: marshal.TestStruct")
+	assert.NoError(Unmarshal(types.NewStruct("TestStruct", types.StructData{
+		"x": types.Number(1),
+		"y": types.String("hi"),
+	}), &ts))
+	assert.Equal(TestStruct{EmbeddedStruct{1, 0}, "hi"}, ts)
+
+	assertDecodeErrorMessage(tt, types.NewStruct("TestStruct", types.StructData{
+		"y": types.String("hi"),
+	}), &ts, "Cannot unmarshal struct TestStruct {\n  y: String,\n} into Go value of type marshal.TestStruct, missing field \"x\"")
 }
 
 func TestDecodeNonExportedField(tt *testing.T) {
@@ -400,7 +413,7 @@ func TestDecodeInvalidNomsType(t *testing.T) {
 	var s S
 	assertDecodeErrorMessage(t, types.NewStruct("S", types.StructData{
 		"a": types.NewMap(types.String("A"), types.Number(1)),
-	}), &s, "Cannot unmarshal Map<String, Number> into Go value of type types.List")
+	}), &s, ".a: Cannot unmarshal Map<String, Number> into Go value of type types.List")
 }
 
 func TestDecodeNomsTypePtr(t *testing.T) {
@@ -566,12 +579,12 @@ func TestDecodeWrongArrayLength(t *testing.T) {
 
 func TestDecodeWrongArrayType(t *testing.T) {
 	var l [1]string
-	assertDecodeErrorMessage(t, types.NewList(types.Number(1)), &l, "Cannot unmarshal Number into Go value of type string")
+	assertDecodeErrorMessage(t, types.NewList(types.Number(1)), &l, "[0]: Cannot unmarshal Number into Go value of type string")
 }
 
 func TestDecodeWrongSliceType(t *testing.T) {
 	var l []string
-	assertDecodeErrorMessage(t, types.NewList(types.Number(1)), &l, "Cannot unmarshal Number into Go value of type string")
+	assertDecodeErrorMessage(t, types.NewList(types.Number(1)), &l, "[0]: Cannot unmarshal Number into Go value of type string")
 }
 
 func TestDecodeSliceWrongNomsType(t *testing.T) {
@@ -664,6 +677,50 @@ func TestDecodeMapEmpty(t *testing.T) {
 	assert.Equal(map[string]int{}, m2)
 }
 
+func TestDecodeMapKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	type Point struct {
+		X, Y int
+	}
+	type Key struct {
+		Name  string
+		At    Point
+		Flags [2]bool
+	}
+
+	roundTrip := func(in, out interface{}) {
+		v, err := Marshal(in)
+		assert.NoError(err)
+		assert.NoError(Unmarshal(v, out))
+		assert.Equal(in, reflect.ValueOf(out).Elem().Interface())
+	}
+	roundTrip(map[Key]string{
+		{"a", Point{1, 2}, [2]bool{true, false}}: "a",
+		{"b", Point{3, 4}, [2]bool{false, true}}: "b",
+	}, &map[Key]string{})
+	roundTrip(map[bool]int{true: 1, false: 0}, &map[bool]int{})
+	roundTrip(map[[2]string]int{{"a", "b"}: 1}, &map[[2]string]int{})
+	roundTrip(map[Point]struct{}{{1, 2}: {}}, &map[Point]struct{}{})
+
+	var values map[types.Value]string
+	assert.NoError(Unmarshal(types.NewMap(
+		types.Bool(true), types.String("bool"),
+		types.Number(1), types.String("number"),
+		types.String("s"), types.String("string")), &values))
+	assert.Equal(map[types.Value]string{types.Bool(true): "bool", types.Number(1): "number", types.String("s"): "string"}, values)
+
+	// Collections can't be Go map keys, however they're decoded.
+	var ifaces map[interface{}]int
+	err := Unmarshal(types.NewMap(types.NewList(types.Number(1)), types.Number(1)), &ifaces)
+	assert.Contains(err.Error(), "map keys must be comparable")
+	err = Unmarshal(types.NewMap(types.NewList(types.Number(1)), types.Number(1)), &values)
+	assert.Contains(err.Error(), "map keys must be comparable")
+	var set map[types.Value]struct{}
+	err = Unmarshal(types.NewSet(types.NewSet()), &set)
+	assert.Contains(err.Error(), "map keys must be comparable")
+}
+
 func TestDecodeMapWrongNomsType(t *testing.T) {
 	var m map[string]int
 	assertDecodeErrorMessage(t, types.NewList(types.String("a"), types.Number(1)), &m, "Cannot unmarshal List<Number | String> into Go value of type map[string]int")
@@ -800,17 +857,17 @@ func TestDecodeSetWrongMapType(t *testing.T) {
 		"a": types.NewSet(types.Number(0)),
 	}), &T1{})
 	assert.Error(err)
-	assert.Equal("Cannot unmarshal Set<Number> into Go value of type map[int]int", err.Error())
+	assert.Equal(".a: Cannot unmarshal Set<Number> into Go value of type map[int]int", err.Error())
 
 	type T2 struct {
-		A map[int]struct{}
+		A map[int]int
 	}
 
 	err = Unmarshal(types.NewStruct("T2", types.StructData{
 		"a": types.NewSet(types.Number(0)),
 	}), &T2{})
 	assert.Error(err)
-	assert.Equal(`Cannot unmarshal Set<Number> into Go value of type map[int]struct {}, field missing "set" tag`, err.Error())
+	assert.Equal(".a: Cannot unmarshal Set<Number> into Go value of type map[int]int", err.Error())
 
 	type T3 struct {
 		A map[int]struct{} `noms:",set"`
@@ -820,7 +877,40 @@ func TestDecodeSetWrongMapType(t *testing.T) {
 		"a": types.NewMap(types.Number(0), types.EmptyStruct),
 	}), &T3{})
 	assert.Error(err)
-	assert.Equal(`Cannot unmarshal Map<Number, struct {}> into Go value of type map[int]struct {}, field has "set" tag`, err.Error())
+	assert.Equal(`.a: Cannot unmarshal Map<Number, struct {}> into Go value of type map[int]struct {}, field has "set" tag`, err.Error())
+}
+
+func TestDecodeSetWithoutTag(t *testing.T) {
+	assert := assert.New(t)
+
+	type T struct {
+		M map[string]struct{}
+		S []string
+		A [3]string
+	}
+	set := types.NewSet(types.String("c"), types.String("a"), types.String("b"))
+
+	var out T
+	err := Unmarshal(types.NewStruct("T", types.StructData{
+		"m": set,
+		"s": set,
+		"a": set,
+	}), &out)
+	assert.NoError(err)
+	assert.Equal(T{
+		M: map[string]struct{}{"a": {}, "b": {}, "c": {}},
+		S: []string{"a", "b", "c"},
+		A: [3]string{"a", "b", "c"},
+	}, out)
+
+	// Untagged maps still decode from Maps.
+	var m map[string]struct{}
+	assert.NoError(Unmarshal(types.NewMap(types.String("x"), types.EmptyStruct), &m))
+	assert.Equal(map[string]struct{}{"x": {}}, m)
+
+	m = nil
+	assert.NoError(Unmarshal(set, &m))
+	assert.Equal(map[string]struct{}{"a": {}, "b": {}, "c": {}}, m)
 }
 
 func TestDecodeOmitEmpty(t *testing.T) {
@@ -851,6 +941,59 @@ func TestDecodeOmitEmpty(t *testing.T) {
 	assert.Equal(expected, actual)
 }
 
+func TestDecodeDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Name    string  `noms:",default=anon"`
+		Retries int     `noms:",omitempty,default=3"`
+		Ratio   float32 `noms:",default=0.5"`
+		Enabled bool    `noms:",default=true"`
+		Count   uint8
+	}
+
+	var s S
+	assert.NoError(Unmarshal(types.NewStruct("S", types.StructData{
+		"count": types.Number(1),
+	}), &s))
+	assert.Equal(S{"anon", 3, 0.5, true, 1}, s)
+
+	// Fields present in the Noms struct override the defaults.
+	s = S{}
+	assert.NoError(Unmarshal(types.NewStruct("S", types.StructData{
+		"name":    types.String("x"),
+		"retries": types.Number(0),
+		"ratio":   types.Number(2),
+		"enabled": types.Bool(false),
+		"count":   types.Number(1),
+	}), &s))
+	assert.Equal(S{"x", 0, 2, false, 1}, s)
+
+	// Fields tagged with "omitempty" are left out when they hold their
+	// default, so that they round trip.
+	v := MustMarshal(S{"anon", 3, 0.5, true, 1})
+	assert.True(types.NewStruct("S", types.StructData{
+		"name":    types.String("anon"),
+		"ratio":   types.Number(0.5),
+		"enabled": types.Bool(true),
+		"count":   types.Number(1),
+	}).Equals(v), types.EncodedValue(v))
+	v = MustMarshal(S{Retries: 0})
+	assert.True(types.Number(0).Equals(v.(types.Struct).Get("retries")))
+
+	_, err := Marshal(struct {
+		F int `noms:",default=x"`
+	}{})
+	assert.IsType(&InvalidTagError{}, err)
+	assert.Equal("Invalid default tag for field F: x", err.Error())
+
+	_, err = Marshal(struct {
+		F []int `noms:",default=1"`
+	}{})
+	assert.IsType(&InvalidTagError{}, err)
+	assert.Equal("The default tag is only valid on bool, number and string fields: F", err.Error())
+}
+
 func TestDecodeOriginal(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1090,3 +1233,436 @@ func TestUnmarshalMustUnmarshal(t *testing.T) {
 	v = MustMarshal(TestStruct{2})
 	a.NotPanics(func() { MustUnmarshal(v, &out) })
 }
+
+func TestUnmarshalTypeMismatchPath(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		On bool
+	}
+	type S struct {
+		Tags   []string
+		Counts map[string]int
+		Inner  []Inner
+	}
+	test := func(v types.Value, path string) {
+		var s S
+		err := Unmarshal(v, &s)
+		assert.IsType(&UnmarshalTypeMismatchError{}, err)
+		assert.Equal(path, err.(*UnmarshalTypeMismatchError).Path)
+	}
+	s := func(tags, counts, inner types.Value) types.Value {
+		return types.NewStruct("S", types.StructData{"tags": tags, "counts": counts, "inner": inner})
+	}
+	tags := types.NewList(types.String("a"))
+	counts := types.NewMap(types.String("a"), types.Number(1))
+	inner := types.NewList(types.NewStruct("Inner", types.StructData{"on": types.Bool(true)}))
+
+	test(s(types.NewList(types.String("a"), types.Number(2)), counts, inner), ".tags[1]")
+	test(s(tags, types.NewMap(types.String("a"), types.Bool(true)), inner), `.counts["a"]`)
+	test(s(tags, types.NewMap(types.Number(1), types.Number(1)), inner), ".counts[1]@key")
+	test(s(tags, counts, types.NewList(types.NewStruct("Inner", types.StructData{"on": types.Number(1)}))), ".inner[0].on")
+	var out S
+	err := Unmarshal(s(tags, counts, types.NewList(types.NewStruct("Inner", types.StructData{"on": types.Number(1)}))), &out)
+	assert.EqualError(err, ".inner[0].on: Cannot unmarshal Number into Go value of type bool")
+	test(types.Number(1), "")
+
+	// The path is relative to the value being unmarshaled, and the same
+	// decodeState can be used again after an error.
+	ds := &decodeState{}
+	var ints []int
+	err = unmarshal(types.NewList(types.Number(1), types.String("x")), &ints, ds)
+	assert.Equal("[1]", err.(*UnmarshalTypeMismatchError).Path)
+	assert.Empty(ds.path)
+}
+
+type unmarshalsOnItsOwn struct {
+	X bool
+}
+
+func (u *unmarshalsOnItsOwn) UnmarshalNoms(v types.Value) error {
+	var inner struct {
+		X bool
+	}
+	err := Unmarshal(v, &inner)
+	u.X = inner.X
+	return err
+}
+
+type decodesFields struct {
+	X bool
+}
+
+func (u *decodesFields) UnmarshalNomsFrom(dec *FieldDecoder) error {
+	return dec.Decode("x", &u.X)
+}
+
+func TestUnmarshalTypeMismatchPathNested(t *testing.T) {
+	assert := assert.New(t)
+
+	// The path of an error from an Unmarshaler that unmarshals on its own is
+	// relative to the Unmarshaler, so it's given the path to it.
+	var s struct {
+		A unmarshalsOnItsOwn
+	}
+	bad := types.NewStruct("", types.StructData{"x": types.Number(1)})
+	err := Unmarshal(types.NewStruct("S", types.StructData{"a": bad}), &s)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Equal(".a.x", err.(*UnmarshalTypeMismatchError).Path)
+
+	// Errors from a FieldDecoder have their full path already.
+	var s2 struct {
+		A decodesFields
+	}
+	err = Unmarshal(types.NewStruct("S", types.StructData{"a": bad}), &s2)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Equal(".a.x", err.(*UnmarshalTypeMismatchError).Path)
+}
+
+func TestUnmarshalLenient(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		On bool
+	}
+	type S struct {
+		Name   string
+		Count  int
+		Ratio  float32
+		Size   uint8
+		Tags   []string
+		Counts map[string]int
+		Inner  Inner
+	}
+
+	v := types.NewStruct("S", types.StructData{
+		"name":   types.Number(42),
+		"count":  types.String(" 7 "),
+		"ratio":  types.Bool(true),
+		"size":   types.Number(3),
+		"tags":   types.NewList(types.String("a"), types.Number(1.5)),
+		"counts": types.NewMap(types.String("x"), types.String("-2")),
+		"inner":  types.NewStruct("Inner", types.StructData{"on": types.Number(1)}),
+	})
+
+	var s S
+	err := Unmarshal(v, &s)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+
+	s = S{}
+	coercions, err := UnmarshalLenient(v, &s)
+	assert.NoError(err)
+	assert.Equal(S{"42", 7, 1, 3, []string{"a", "1.5"}, map[string]int{"x": -2}, Inner{true}}, s)
+
+	paths := []string{}
+	for _, c := range coercions {
+		paths = append(paths, c.Path)
+	}
+	assert.Equal([]string{".name", ".count", ".ratio", ".tags[1]", ".counts[\"x\"]", ".inner.on"}, paths)
+	assert.True(types.Number(42).Equals(coercions[0].Value))
+	assert.Equal(reflect.TypeOf(""), coercions[0].Type)
+	assert.Equal(".name: 42 coerced to string", coercions[0].String())
+
+	// Values that don't already match report no coercions.
+	coercions, err = UnmarshalLenient(types.NewList(types.Number(1), types.Number(2)), &[]int{})
+	assert.NoError(err)
+	assert.Empty(coercions)
+
+	// Coercions that would lose information still fail.
+	for _, c := range []struct {
+		v   types.Value
+		out interface{}
+	}{
+		{types.String("abc"), new(int)},
+		{types.String("NaN"), new(float64)},
+		{types.String("1e400"), new(float64)},
+		{types.String("-1"), new(uint)},
+		{types.String("300"), new(uint8)},
+		{types.String("1.5"), new(int)},
+		{types.Number(2), new(bool)},
+		{types.String("true"), new(bool)},
+		{types.Bool(true), new(string)},
+	} {
+		_, err := UnmarshalLenient(c.v, c.out)
+		assert.IsType(&UnmarshalTypeMismatchError{}, err, "%s", types.EncodedValue(c.v))
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		On bool
+	}
+	type S struct {
+		Name   string
+		Inners []Inner
+		Skip   int `noms:"-"`
+		Opt    int `noms:",omitempty"`
+	}
+
+	v := types.NewStruct("S", types.StructData{
+		"name":   types.String("a"),
+		"inners": types.NewList(types.NewStruct("Inner", types.StructData{"on": types.Bool(true)})),
+	})
+	var s S
+	assert.NoError(UnmarshalOpt(v, &s, Opt{Strict: true}))
+	assert.Equal(S{"a", []Inner{{true}}, 0, 0}, s)
+
+	// Unknown fields are dropped unless decoding strictly, at any depth.
+	for _, v := range []types.Value{
+		v.Set("skip", types.Number(1)),
+		v.Set("inners", types.NewList(types.NewStruct("Inner", types.StructData{
+			"on":  types.Bool(true),
+			"off": types.Bool(false),
+		}))),
+	} {
+		s = S{}
+		assert.NoError(Unmarshal(v, &s))
+		assert.NoError(UnmarshalOpt(v, &s, Opt{}))
+		err := UnmarshalOpt(v, &s, Opt{Strict: true})
+		assert.IsType(&UnmarshalTypeMismatchError{}, err)
+		assert.Contains(err.Error(), "unknown field")
+	}
+
+	// Missing fields fail when decoding strictly, and are left at their zero
+	// value otherwise, at any depth. Unmarshal fails on them too.
+	for _, tc := range []struct {
+		v        types.Value
+		expected S
+	}{
+		{types.NewStruct("S", types.StructData{"inners": v.Get("inners")}), S{Inners: []Inner{{true}}}},
+		{v.Set("inners", types.NewList(types.NewStruct("Inner", types.StructData{}))), S{Name: "a", Inners: []Inner{{false}}}},
+	} {
+		s = S{}
+		v := tc.v
+		assert.NoError(UnmarshalOpt(v, &s, Opt{}))
+		assert.Equal(tc.expected, s)
+		err := UnmarshalOpt(v, &s, Opt{Strict: true})
+		assert.IsType(&UnmarshalTypeMismatchError{}, err)
+		assert.Contains(err.Error(), "missing field")
+		assert.Error(Unmarshal(v, &s))
+	}
+
+	// A struct with an "original" field keeps every field.
+	type O struct {
+		Name string
+		Orig types.Struct `noms:",original"`
+	}
+	var o O
+	assert.NoError(UnmarshalOpt(v.Set("extra", types.Number(1)), &o, Opt{Strict: true}))
+	assert.Equal("a", o.Name)
+}
+
+func TestUnmarshalCaseInsensitive(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		On bool
+	}
+	type S struct {
+		UserID string
+		Inner  Inner
+		Count  int `noms:",omitempty"`
+	}
+
+	v := types.NewStruct("S", types.StructData{
+		"UserId": types.String("a"),
+		"INNER":  types.NewStruct("Inner", types.StructData{"ON": types.Bool(true)}),
+	})
+	var s S
+	err := Unmarshal(v, &s)
+	assert.Contains(err.Error(), `missing field "userID"`)
+
+	opt := Opt{CaseInsensitive: true}
+	assert.NoError(UnmarshalOpt(v, &s, opt))
+	assert.Equal(S{"a", Inner{true}, 0}, s)
+
+	// Exact matches are preferred.
+	s = S{}
+	assert.NoError(UnmarshalOpt(v.Set("userID", types.String("b")), &s, opt))
+	assert.Equal("b", s.UserID)
+
+	// Fields matched but for case are known to strict decoding, but those
+	// that lose out to an exact match aren't.
+	opt.Strict = true
+	assert.NoError(UnmarshalOpt(v, &s, opt))
+	err = UnmarshalOpt(v.Set("userID", types.String("b")), &s, opt)
+	assert.Contains(err.Error(), `unknown field "UserId"`)
+	err = UnmarshalOpt(v.Set("Count", types.Number(1)).Set("count", types.Number(2)), &s, opt)
+	assert.Contains(err.Error(), `unknown field "Count"`)
+}
+
+func TestDecodeTime(t *testing.T) {
+	assert := assert.New(t)
+
+	// Either representation can be decoded, whatever the tag.
+	var tm time.Time
+	assert.NoError(Unmarshal(types.NewStruct("DateTime", types.StructData{"secSinceEpoch": types.Number(-1.5)}), &tm))
+	assert.True(time.Unix(-2, 500000000).Equal(tm))
+	assert.NoError(Unmarshal(types.Number(1500000000), &tm))
+	assert.True(time.Unix(1500000000, 0).Equal(tm))
+
+	for _, v := range []types.Value{types.String("2017-07-14"), types.NewStruct("DateTime", types.StructData{})} {
+		err := Unmarshal(v, &tm)
+		assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	}
+}
+
+func TestDecodeRefTag(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+	defer vs.Close()
+
+	type Doc struct {
+		Title string
+	}
+	type S struct {
+		Doc  Doc  `noms:",ref"`
+		Prev *Doc `noms:",ref"`
+	}
+	in := S{Doc{"Intro"}, &Doc{"Preface"}}
+	v, err := MarshalVRW(vs, in)
+	assert.NoError(err)
+
+	var out S
+	assert.NoError(UnmarshalVR(vs, v, &out))
+	assert.Equal(in, out)
+
+	// Values that were written inline are decoded as they are.
+	out = S{}
+	assert.NoError(UnmarshalVR(vs, types.NewStruct("S", types.StructData{
+		"doc": MustMarshal(Doc{"Inline"}),
+	}), &out))
+	assert.Equal(S{Doc: Doc{"Inline"}}, out)
+
+	err = Unmarshal(v, &out)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Contains(err.Error(), "fields tagged ref can only be unmarshaled by UnmarshalVR")
+
+	missing := types.NewStruct("S", types.StructData{"doc": types.NewRef(types.String("missing"))})
+	err = UnmarshalVR(vs, missing, &out)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Contains(err.Error(), "the value it refers to is missing")
+}
+
+func TestDecodePointer(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		B bool
+	}
+	type S struct {
+		N *int
+		S *string
+		I *Inner
+	}
+	var s S
+	assert.NoError(Unmarshal(types.NewStruct("S", types.StructData{
+		"n": types.Number(42),
+		"s": types.String("hi"),
+		"i": types.NewStruct("Inner", types.StructData{"b": types.Bool(true)}),
+	}), &s))
+	assert.Equal(42, *s.N)
+	assert.Equal("hi", *s.S)
+	assert.Equal(Inner{true}, *s.I)
+
+	// Pointers that are already set are decoded onto, and missing fields are
+	// left alone.
+	n := s.N
+	assert.NoError(Unmarshal(types.NewStruct("S", types.StructData{"n": types.Number(43)}), &s))
+	assert.True(n == s.N)
+	assert.Equal(43, *s.N)
+	assert.Equal("hi", *s.S)
+
+	var ptrs []*int
+	assert.NoError(Unmarshal(types.NewList(types.Number(1), types.Number(2)), &ptrs))
+	assert.Equal(2, len(ptrs))
+	assert.Equal(1, *ptrs[0])
+	assert.Equal(2, *ptrs[1])
+
+	var ptr *bool
+	assertDecodeErrorMessage(t, types.Number(42), &ptr, "Cannot unmarshal Number into Go value of type bool")
+}
+
+type counter struct {
+	name  string
+	count int
+	tags  []string
+}
+
+func (c *counter) UnmarshalNomsFrom(dec *FieldDecoder) error {
+	if dec.Name() != "Counter" {
+		return errors.New("expected struct Counter")
+	}
+	if err := dec.Decode("name", &c.name); err != nil {
+		return err
+	}
+	if err := dec.Decode("count", &c.count); err != nil {
+		return err
+	}
+	if dec.Has("tags") {
+		return dec.Decode("tags", &c.tags)
+	}
+	return nil
+}
+
+func TestUnmarshalerFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	var c counter
+	err := Unmarshal(types.NewStruct("Counter", types.StructData{
+		"name":  types.String("hits"),
+		"count": types.Number(42),
+		"tags":  types.NewList(types.String("a"), types.String("b")),
+	}), &c)
+	assert.NoError(err)
+	assert.Equal(counter{"hits", 42, []string{"a", "b"}}, c)
+
+	// Works as a field, and for optional fields.
+	var s struct {
+		C counter
+	}
+	err = Unmarshal(types.NewStruct("S", types.StructData{
+		"c": types.NewStruct("Counter", types.StructData{
+			"name":  types.String("misses"),
+			"count": types.Number(1),
+		}),
+	}), &s)
+	assert.NoError(err)
+	assert.Equal(counter{"misses", 1, nil}, s.C)
+
+	assertDecodeErrorMessage(t, types.Number(42), &c, "Cannot unmarshal Number into Go value of type marshal.counter, expected struct")
+	assertDecodeErrorMessage(t, types.NewStruct("Counter", types.StructData{
+		"name": types.String("hits"),
+	}), &c, "Cannot unmarshal struct Counter {\n  name: String,\n} into Go value of type *int, missing field \"count\"")
+	assertDecodeErrorMessage(t, types.NewStruct("Counter", types.StructData{
+		"name":  types.String("hits"),
+		"count": types.String("many"),
+	}), &c, ".count: Cannot unmarshal String into Go value of type int")
+	assertDecodeErrorMessage(t, types.NewStruct("Gauge", types.StructData{}), &c, "expected struct Counter")
+}
+
+func BenchmarkUnmarshalParallel(b *testing.B) {
+	v := MustMarshal(newBenchItem())
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var out benchItem
+			MustUnmarshal(v, &out)
+		}
+	})
+}
+
+func BenchmarkUnmarshalOptParallel(b *testing.B) {
+	opt := Opt{FieldNaming: SnakeCase}
+	v := MustMarshalOpt(nil, newBenchItem(), opt)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			var out benchItem
+			if err := UnmarshalOpt(v, &out, opt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
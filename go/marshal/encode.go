@@ -8,11 +8,18 @@
 package marshal
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 
 	"github.com/attic-labs/noms/go/types"
@@ -35,20 +42,77 @@ import (
 // field is tagged with `noms:"set", it will be encoded as Noms types.Set
 // instead.
 //
+// Byte slices are encoded as Noms types.Blob, unless the field is tagged with
+// `noms:",list"`, in which case they're encoded as a List of Numbers. Byte
+// arrays are encoded as a List, unless the field is tagged with
+// `noms:",blob"`.
+//
 // Maps are encoded as Noms types.Map, or a types.Set if the value type is
 // struct{} and the field is tagged with `noms:"set"`.
 //
+// time.Time values are encoded as Noms structs of type
+// Struct DateTime {secSinceEpoch: Number}, the same as the DateTime type of
+// package datetime, where secSinceEpoch may hold fractions of a second. If
+// the field is tagged with `noms:",unixtime"`, they're encoded as a Noms
+// types.Number of seconds since the epoch instead. Either way, precision is
+// limited to what a float64 can hold, which is around a microsecond for
+// current times.
+//
+// big.Int values are encoded losslessly as Noms structs of type
+// Struct BigInt {abs: Blob, sign: Number}, where abs holds the big-endian bytes
+// of the absolute value and sign is -1, 0 or 1. big.Float values are encoded as
+// Struct BigFloat {prec: Number, value: String}, where value is the exact value
+// formatted as by big.Float's Text('p', 0). Their rounding modes are not kept.
+//
+// Raw values are encoded as the Ref they hold, without reading the value it
+// refers to.
+//
+// Values of other types that implement encoding.TextMarshaler, such as
+// net.IP, are encoded as a types.String holding their text, unless they're
+// byte slices or arrays tagged with "blob" or "list". This includes types
+// whose MarshalText has a pointer receiver.
+//
+// Values of types with an encoder registered by RegisterEncoder are encoded
+// by it, whatever their type. RegisterDurationAsString and RegisterUUID
+// register encoders for some common types.
+//
+// Pointers are encoded as the value they point to. A struct field holding a
+// nil pointer is left out of the Noms struct, so pointer fields are optional
+// fields of the struct's Noms type. Nil pointers elsewhere, e.g. in a slice,
+// can't be encoded. Pointers, maps and slices that hold themselves, directly
+// or not, can't be encoded either: Marshal returns an UnsupportedTypeError for
+// such cycles rather than recursing forever. MarshalOpt with SharePointers
+// encodes each pointer as a Ref instead, so that values that several pointers
+// share are written once.
+//
 // Struct values are encoded as Noms structs (types.Struct). Each exported Go
 // struct field becomes a member of the Noms struct unless
 //   - The field's tag is "-"
-//   - The field is empty and its tag specifies the "omitempty" option.
+//   - The field is empty and its tag specifies the "omitempty" option. If the
+//     tag also specifies a default with "default=<literal>", the field is
+//     left out when it holds that default instead.
+//   - The field is the zero value of its Go type and its tag specifies the
+//     "omitzero" option. Unlike "omitempty", this keeps empty slices and
+//     maps that aren't nil, so that they can be told apart from nil ones.
+//   - The field is a nil pointer.
 //   - The field has the "original" tag, in which case the field is used as an
 //     initial value onto which the fields of the Go type are added. When
 //     combined with the corresponding support for "original" in Unmarshal(),
 //     this allows one to find and modify any values of a known subtype.
 //
+// If a struct field is tagged with `noms:",ref"`, its encoded value is written
+// to the ValueReadWriter and the Noms struct holds a types.Ref to it, so that
+// large nested values are stored, and shared between structs, as separate
+// chunks. Such fields can only be encoded by MarshalVRW.
+//
 // Additionally, user-defined types can implement the Marshaler interface to
-// provide a custom encoding.
+// provide a custom encoding. Types that need to write values of their own, for
+// example to build a Blob or a Ref, can implement MarshalerVRW instead and use
+// MarshalVRW to supply the ValueReadWriter the result is destined for.
+//
+// Slices and arrays of many elements are encoded by several goroutines at
+// once, unless SharePointers is set, so the Marshaler methods of their
+// elements may be called concurrently.
 //
 // The empty values are false, 0, any nil pointer or interface value, and any
 // array, slice, map, or string of length zero.
@@ -56,7 +120,8 @@ import (
 // The Noms struct default field name is the Go struct field name where the
 // first character is lower cased, but can be specified in the Go struct field's
 // tag value. The "noms" key in the Go struct field's tag value is the field
-// name. Examples:
+// name. If two fields of a Go struct end up with the same name, Marshal returns
+// an InvalidTagError naming both. Examples:
 //
 //   // Field is ignored.
 //   Field int `noms:"-"`
@@ -75,23 +140,100 @@ import (
 //   //  omitted from the object if its value is empty, as defined above.
 //   Field int `noms:",omitempty"
 //
+//   // Field appears in a Noms struct as key "field", even if it's an empty
+//   //  slice, but is omitted from the object if it's nil.
+//   Field []string `noms:",omitzero"`
+//
+//   // Field, a time.Time, appears in a Noms struct as a Number of seconds
+//   //  since the epoch.
+//   Field time.Time `noms:",unixtime"`
+//
+//   // Field, a number, appears in a Noms struct as a String of its decimal
+//   //  digits, as with encoding/json. Unlike a Number, which is a float64,
+//   //  the String holds every int64 and uint64 exactly.
+//   ID uint64 `noms:",string"`
+//
+//   // Field is written to the ValueReadWriter given to MarshalVRW, and
+//   //  appears in a Noms struct as a Ref to it.
+//   Field Document `noms:",ref"`
+//
+//   // Field always appears in a Noms struct as key "field", but is an
+//   //  optional field of its type, so Unmarshal and MarshalType allow for
+//   //  Noms structs without it.
+//   Field int `noms:",optional"`
+//
+//   // Field appears in a Noms struct as key "retries" and the field is
+//   //  omitted from the object if it holds 3. Unmarshal sets it to 3 if
+//   //  the Noms struct lacks it.
+//   Retries int `noms:",omitempty,default=3"`
+//
+//   // The Noms struct has no name, rather than that of the Go struct.
+//   _ struct{} `noms:",noname"`
+//
+//   // The Noms struct is named "Person_v2", rather than after the Go struct.
+//   _ struct{} `noms:",name=Person_v2"`
+//
+//   // Field appears in a Noms struct as key "version" and always holds 3,
+//   //  the current version of the Go struct's schema. See RegisterMigration.
+//   Version int `noms:",version=3"`
+//
 // The name of the Noms struct is the name of the Go struct where the first
-// character is changed to upper case.
+// character is changed to upper case. A Go struct with a blank field tagged
+// `noms:",name=<name>"` marshals to a Noms struct of that name instead, so
+// that renaming the Go type doesn't change the type of the data it writes. One
+// tagged `noms:",noname"` marshals to a Noms struct without a name, as an
+// anonymous Go struct does, for use with data whose types are structural.
+// MarshalType can't give the type of such a struct if it refers to itself,
+// since a Cycle has no name to refer to.
 //
-// Anonymous struct fields are currently not supported.
+// MarshalOpt can override the name of the outermost Noms struct, and choose
+// how fields without a name in their tag are named.
 //
-// Embedded structs are currently not supported (which is the same as anonymous
-// struct fields).
+// The exported fields of an embedded struct are flattened into the Noms struct,
+// following the rules of encoding/json: a field hides any more deeply nested
+// fields of the same name, and giving the embedded struct a name in its tag
+// makes it a field like any other. Unlike encoding/json, two fields of the
+// same name that are nested equally deeply cause an InvalidTagError. Other
+// embedded types must be named by their tag.
 //
 // Noms values (values implementing types.Value) are copied over without any
-// change.
+// change, including those held by interfaces within slices, maps and structs.
+// MarshalType gives fields of interface types such as types.Value the type
+// Value.
+//
+// When marshalling interface{} the dynamic type is used. A nil interface can
+// only be encoded as a struct field tagged "omitempty", which leaves it out.
 //
-// When marshalling interface{} the dynamic type is used.
+// Marshal is deterministic: equal inputs produce equal Noms values regardless of
+// the order in which Go iterates over maps. If several keys of a Go map encode
+// to the same Noms value the entry with the greatest encoded value is kept.
 //
-// Go pointers, complex, function are not supported. Attempting to encode such a
-// value causes Marshal to return an UnsupportedTypeError.
+// Go complex, function and channel values are not supported. Attempting to
+// encode such a value causes Marshal to return an UnsupportedTypeError.
 //
 func Marshal(v interface{}) (nomsValue types.Value, err error) {
+	return MarshalVRW(nil, v)
+}
+
+// MarshalVRW is like Marshal but passes vrw on to every MarshalerVRW
+// encountered while traversing v, so that custom encodings can create values
+// tied to vrw. vrw may be nil, in which case MarshalerVRW implementations are
+// called with a nil ValueReadWriter.
+func MarshalVRW(vrw types.ValueReadWriter, v interface{}) (nomsValue types.Value, err error) {
+	return MarshalOpt(vrw, v, Opt{})
+}
+
+// MarshalOpt is like MarshalVRW but marshals according to |opt|, e.g. to
+// produce structs that are compatible with ones written by other tools:
+//
+//   MarshalOpt(nil, v, Opt{StructName: "Event", FieldNaming: SnakeCase})
+func MarshalOpt(vrw types.ValueReadWriter, v interface{}, opt Opt) (nomsValue types.Value, err error) {
+	return marshal(vrw, v, opt, nil)
+}
+
+// marshal is MarshalOpt, registering with |registry|, if it isn't nil, the
+// structs that are encoded from interfaces.
+func marshal(vrw types.ValueReadWriter, v interface{}, opt Opt, registry *TypeRegistry) (nomsValue types.Value, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			switch r := r.(type) {
@@ -104,16 +246,148 @@ func Marshal(v interface{}) (nomsValue types.Value, err error) {
 			}
 		}
 	}()
-	nomsValue = MustMarshal(v)
+	nomsValue = mustMarshal(vrw, v, opt, registry)
 	return
 }
 
 // MustMarshal marshals a Go value to a Noms value using the same rules as
 // Marshal(). Panics on failure.
 func MustMarshal(v interface{}) types.Value {
+	return MustMarshalVRW(nil, v)
+}
+
+// MustMarshalVRW marshals a Go value to a Noms value using the same rules as
+// MarshalVRW(). Panics on failure.
+func MustMarshalVRW(vrw types.ValueReadWriter, v interface{}) types.Value {
+	return MustMarshalOpt(vrw, v, Opt{})
+}
+
+// MustMarshalOpt marshals a Go value to a Noms value using the same rules as
+// MarshalOpt(). Panics on failure.
+func MustMarshalOpt(vrw types.ValueReadWriter, v interface{}, opt Opt) types.Value {
+	return mustMarshal(vrw, v, opt, nil)
+}
+
+func mustMarshal(vrw types.ValueReadWriter, v interface{}, opt Opt, registry *TypeRegistry) types.Value {
 	rv := reflect.ValueOf(v)
-	encoder := typeEncoder(rv.Type(), map[string]reflect.Type{}, nomsTags{})
-	return encoder(rv)
+	// The encoders are cached without StructName, so that they're shared by
+	// every name they're used with, and the name is applied to their result.
+	encoder := typeEncoder(rv.Type(), map[string]reflect.Type{}, nomsTags{}, opt.nested())
+	nv := encoder(rv, &encodeState{vrw: vrw, sharePointers: opt.SharePointers, registry: registry})
+	if opt.StructName != "" && structNameApplies(rv, opt) {
+		data := types.StructData{}
+		nv.(types.Struct).IterFields(func(name string, value types.Value) {
+			data[name] = value
+		})
+		nv = types.NewStruct(opt.StructName, data)
+	}
+	return nv
+}
+
+// structNameApplies returns whether |v|, or what it points to, is a Go struct
+// that structEncoder encodes, and so is named by opt.StructName. A struct
+// extending a non-empty original keeps the original's name.
+func structNameApplies(v reflect.Value, opt Opt) bool {
+	for {
+		t := v.Type()
+		if specialEncoder(t, nomsTags{}) != nil || t.Implements(nomsValueInterface) {
+			return false
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			for _, sf := range structFields(t, opt.FieldNaming) {
+				if sf.tags.original {
+					return v.FieldByIndex(sf.Index).Interface().(types.Struct).IsZeroValue()
+				}
+			}
+			return true
+		case reflect.Ptr, reflect.Interface:
+			if v.IsNil() {
+				return false
+			}
+			v = v.Elem()
+		default:
+			return false
+		}
+	}
+}
+
+// Apply returns |orig| with each field that v marshals to set to its encoded
+// value. v must be a Go struct or a pointer to one, and is marshaled following
+// the same rules as Marshal(). Fields of |orig| that v doesn't mention - because
+// the Go struct lacks them, or they are skipped with "-" or "omitempty" - are
+// left untouched, as is the name of |orig|. This has the effect of the
+// "original" tag without requiring the Go struct to carry the original value.
+// If |orig| is the zero Struct, the result is simply the marshaled v.
+func Apply(orig types.Struct, v interface{}) (types.Struct, error) {
+	_, updates, err := marshalStruct(v, "Apply")
+	if err != nil || orig.IsZeroValue() {
+		return updates, err
+	}
+
+	updates.IterFields(func(name string, value types.Value) {
+		orig = orig.Set(name, value)
+	})
+	return orig, nil
+}
+
+// Update is like Apply, but only sets the fields of |orig| whose encoded values
+// differ from those it holds, so that when v was unmarshaled from orig and
+// then modified, the result differs from orig only where v was changed, and is
+// orig itself if v wasn't. Unlike Apply, fields that the Go struct has but
+// that v leaves out, because they're empty and tagged "omitempty" or are nil
+// pointers, are removed from orig, so that the result unmarshals back to v.
+// Fields of orig that the Go struct lacks or skips with "-" are left
+// untouched.
+func Update(orig types.Struct, v interface{}) (types.Struct, error) {
+	t, updates, err := marshalStruct(v, "Update")
+	if err != nil || orig.IsZeroValue() {
+		return updates, err
+	}
+
+	for _, sf := range structFields(t, LowerCamelCase) {
+		name := sf.tags.name
+		if sf.tags.original {
+			continue
+		}
+		value, ok := updates.MaybeGet(name)
+		if !ok {
+			if _, ok := orig.MaybeGet(name); ok {
+				orig = orig.Delete(name)
+			}
+			continue
+		}
+		if old, ok := orig.MaybeGet(name); !ok || !old.Equals(value) {
+			orig = orig.Set(name, value)
+		}
+	}
+	return orig, nil
+}
+
+// marshalStruct marshals |v|, which |fn| requires to be a Go struct or a
+// pointer to one that marshals to a Noms struct, and returns its type.
+func marshalStruct(v interface{}, fn string) (reflect.Type, types.Struct, error) {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, types.Struct{}, &UnsupportedTypeError{nil, fn + " requires a Go struct, not nil"}
+	}
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type().Implements(nomsValueInterface) {
+		return nil, types.Struct{}, &UnsupportedTypeError{rv.Type(), fn + " requires a Go struct"}
+	}
+
+	nomsValue, err := Marshal(rv.Interface())
+	if err != nil {
+		return nil, types.Struct{}, err
+	}
+	s, ok := nomsValue.(types.Struct)
+	if !ok {
+		// A Marshaler can encode the struct as something else.
+		return nil, types.Struct{}, &UnsupportedTypeError{rv.Type(), fn + " requires a Go struct that marshals to a Noms struct"}
+	}
+	return rv.Type(), s, nil
 }
 
 // Marshaler is an interface types can implement to provide their own encoding.
@@ -124,6 +398,18 @@ type Marshaler interface {
 	MarshalNoms() (val types.Value, err error)
 }
 
+// MarshalerVRW is like Marshaler but MarshalNoms is given the ValueReadWriter
+// passed to MarshalVRW, so that implementations can write chunked collections
+// and Refs to the destination database rather than producing values that only
+// live in memory.
+type MarshalerVRW interface {
+	// MarshalNoms returns the Noms Value encoding of a type, or an error. vrw
+	// is the ValueReadWriter passed to MarshalVRW and is nil if the value is
+	// being encoded by Marshal. nil is not a valid return val - if both val
+	// and err are nil, Marshal will panic.
+	MarshalNoms(vrw types.ValueReadWriter) (val types.Value, err error)
+}
+
 // UnsupportedTypeError is returned by encode when attempting to encode a type
 // that isn't supported.
 type UnsupportedTypeError struct {
@@ -136,6 +422,9 @@ func (e *UnsupportedTypeError) Error() string {
 	if msg == "" {
 		msg = "Type is not supported"
 	}
+	if e.Type == nil {
+		return msg
+	}
 	return msg + ", type: " + e.Type.String()
 }
 
@@ -161,44 +450,180 @@ func (e *marshalNomsError) Error() string {
 
 type nomsTags struct {
 	name      string
+	def       reflect.Value
+	blob      bool
+	list      bool
 	omitEmpty bool
+	omitZero  bool
+	optional  bool
 	original  bool
+	ref       bool
 	set       bool
 	skip      bool
+	str       bool
+	unixtime  bool
+	version   int
 }
 
 var nomsValueInterface = reflect.TypeOf((*types.Value)(nil)).Elem()
 var emptyInterface = reflect.TypeOf((*interface{})(nil)).Elem()
 var marshalerInterface = reflect.TypeOf((*Marshaler)(nil)).Elem()
+var marshalerVRWInterface = reflect.TypeOf((*MarshalerVRW)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+
+var dateTimeType = types.MakeStructTypeFromFields("DateTime", types.FieldMap{
+	"secSinceEpoch": types.NumberType,
+})
+
+var dateTimeTemplate = types.MakeStructTemplate("DateTime", []string{"secSinceEpoch"})
+
+type encoderFunc func(v reflect.Value, es *encodeState) types.Value
+
+// startDetectingCyclesAfter is the number of nested pointers, maps and slices
+// that are encoded before encoding starts checking for cycles among them. As in
+// encoding/json, this keeps the check off the path of ordinary values.
+const startDetectingCyclesAfter = 1000
+
+// encodeState is the state of a single Marshal call.
+type encodeState struct {
+	vrw           types.ValueReadWriter
+	sharePointers bool
 
-type encoderFunc func(v reflect.Value) types.Value
+	// depth is the number of pointers, maps and slices being encoded, and
+	// active holds them once depth passes startDetectingCyclesAfter.
+	depth  int
+	active map[addr]bool
 
-func boolEncoder(v reflect.Value) types.Value {
+	// refs holds the Refs that pointers have been encoded as, when
+	// sharePointers is set.
+	refs map[addr]types.Ref
+
+	// registry, if not nil, registers the structs encoded from interfaces.
+	registry *TypeRegistry
+}
+
+// addr identifies what a pointer, map or slice refers to. Slices of different
+// lengths over the same array are different values.
+type addr struct {
+	ptr uintptr
+	t   reflect.Type
+	len int
+}
+
+func addrOf(v reflect.Value) addr {
+	if v.Kind() == reflect.Slice {
+		return addr{v.Pointer(), v.Type(), v.Len()}
+	}
+	return addr{v.Pointer(), v.Type(), 0}
+}
+
+func (es *encodeState) valueReadWriter() types.ValueReadWriter {
+	if es == nil {
+		return nil
+	}
+	return es.vrw
+}
+
+// enter records that the pointer, map or slice |v| is being encoded, and
+// panics if it already is, which means that it holds itself. Each enter is
+// paired with a leave once |v| is encoded.
+func (es *encodeState) enter(v reflect.Value) {
+	es.depth++
+	if es.depth <= startDetectingCyclesAfter {
+		return
+	}
+	a := addrOf(v)
+	if es.active[a] {
+		panic(&UnsupportedTypeError{v.Type(), "Encountered a cycle"})
+	}
+	if es.active == nil {
+		es.active = map[addr]bool{}
+	}
+	es.active[a] = true
+}
+
+func (es *encodeState) leave(v reflect.Value) {
+	if es.depth > startDetectingCyclesAfter {
+		delete(es.active, addrOf(v))
+	}
+	es.depth--
+}
+
+// fork returns an encodeState for encoding values nested in the one that es
+// is encoding from another goroutine.
+func (es *encodeState) fork() *encodeState {
+	f := &encodeState{vrw: es.vrw, depth: es.depth, registry: es.registry}
+	if es.active != nil {
+		f.active = make(map[addr]bool, len(es.active))
+		for a := range es.active {
+			f.active[a] = true
+		}
+	}
+	return f
+}
+
+// sharedRef returns a Ref to the value that the pointer |v| points to, which
+// |e| encodes. The value is written to the ValueReadWriter the first time a
+// pointer to it is encoded, and later pointers to it share the Ref.
+func (es *encodeState) sharedRef(v reflect.Value, e encoderFunc) types.Ref {
+	a := addrOf(v)
+	if r, ok := es.refs[a]; ok {
+		return r
+	}
+	vrw := es.valueReadWriter()
+	if vrw == nil {
+		panic(&marshalNomsError{errors.New("SharePointers requires a ValueReadWriter")})
+	}
+	es.enter(v)
+	nv := e(v.Elem(), es)
+	es.leave(v)
+	r := vrw.WriteValue(nv)
+	if es.refs == nil {
+		es.refs = map[addr]types.Ref{}
+	}
+	es.refs[a] = r
+	return r
+}
+
+func boolEncoder(v reflect.Value, es *encodeState) types.Value {
 	return types.Bool(v.Bool())
 }
 
-func float64Encoder(v reflect.Value) types.Value {
+func float64Encoder(v reflect.Value, es *encodeState) types.Value {
 	return types.Number(v.Float())
 }
 
-func intEncoder(v reflect.Value) types.Value {
+func intEncoder(v reflect.Value, es *encodeState) types.Value {
 	return types.Number(float64(v.Int()))
 }
 
-func uintEncoder(v reflect.Value) types.Value {
+func uintEncoder(v reflect.Value, es *encodeState) types.Value {
 	return types.Number(float64(v.Uint()))
 }
 
-func stringEncoder(v reflect.Value) types.Value {
+// numberStringEncoder encodes a number as a String of its decimal digits, for
+// fields tagged with `noms:",string"`. Unlike a Number, the String holds 64-bit
+// integers exactly.
+func numberStringEncoder(v reflect.Value, es *encodeState) types.Value {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.String(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return types.String(strconv.FormatUint(v.Uint(), 10))
+	}
+	return types.String(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+}
+
+func stringEncoder(v reflect.Value, es *encodeState) types.Value {
 	return types.String(v.String())
 }
 
-func nomsValueEncoder(v reflect.Value) types.Value {
+func nomsValueEncoder(v reflect.Value, es *encodeState) types.Value {
 	return v.Interface().(types.Value)
 }
 
 func marshalerEncoder(t reflect.Type) encoderFunc {
-	return func(v reflect.Value) types.Value {
+	return func(v reflect.Value, es *encodeState) types.Value {
 		val, err := v.Interface().(Marshaler).MarshalNoms()
 		if err != nil {
 			panic(&marshalNomsError{err})
@@ -210,9 +635,22 @@ func marshalerEncoder(t reflect.Type) encoderFunc {
 	}
 }
 
-func typeEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTags) encoderFunc {
-	if t.Implements(marshalerInterface) {
-		return marshalerEncoder(t)
+func marshalerVRWEncoder(t reflect.Type) encoderFunc {
+	return func(v reflect.Value, es *encodeState) types.Value {
+		val, err := v.Interface().(MarshalerVRW).MarshalNoms(es.valueReadWriter())
+		if err != nil {
+			panic(&marshalNomsError{err})
+		}
+		if val == nil {
+			panic(fmt.Errorf("nil result from %s.MarshalNoms", t.String()))
+		}
+		return val
+	}
+}
+
+func typeEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTags, opt Opt) encoderFunc {
+	if e := specialEncoder(t, tags); e != nil {
+		return e
 	}
 
 	switch t.Kind() {
@@ -227,100 +665,234 @@ func typeEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsT
 	case reflect.String:
 		return stringEncoder
 	case reflect.Struct:
-		return structEncoder(t, seenStructs)
+		return structEncoder(t, seenStructs, opt)
 	case reflect.Slice, reflect.Array:
 		if shouldEncodeAsSet(t, tags) {
-			return setFromListEncoder(t, seenStructs)
+			return setFromListEncoder(t, seenStructs, opt)
 		}
-		return listEncoder(t, seenStructs)
+		if shouldEncodeAsBlob(t, tags) {
+			return blobEncoder
+		}
+		return listEncoder(t, seenStructs, opt)
 	case reflect.Map:
 		if shouldEncodeAsSet(t, tags) {
-			return setEncoder(t, seenStructs)
+			return setEncoder(t, seenStructs, opt)
 		}
-		return mapEncoder(t, seenStructs)
+		return mapEncoder(t, seenStructs, opt)
 	case reflect.Interface:
-		return func(v reflect.Value) types.Value {
+		return func(v reflect.Value, es *encodeState) types.Value {
+			if v.IsNil() {
+				panic(&UnsupportedTypeError{t, "Nil interfaces can't be encoded"})
+			}
+			// Noms values are passed through as they are, wherever the
+			// interface is, rather than encoded according to their Go type.
+			if nv, ok := v.Interface().(types.Value); ok {
+				return nv
+			}
 			// Get the dynamic type.
 			v2 := reflect.ValueOf(v.Interface())
-			return typeEncoder(v2.Type(), seenStructs, tags)(v2)
+			nv := typeEncoder(v2.Type(), seenStructs, tags, opt)(v2, es)
+			if es != nil && es.registry != nil {
+				es.registry.observe(v2.Type(), nv)
+			}
+			return nv
 		}
 	case reflect.Ptr:
 		// Allow implementations of types.Value (like *types.Type)
 		if t.Implements(nomsValueInterface) {
 			return nomsValueEncoder
 		}
-		fallthrough
+		return pointerEncoder(t, seenStructs, tags, opt)
 	default:
 		panic(&UnsupportedTypeError{Type: t})
 	}
 }
 
-func structEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
+// specialEncoder returns the encoder for |t| if it isn't encoded according to
+// its kind, e.g. because it's a Marshaler, and nil otherwise.
+func specialEncoder(t reflect.Type, tags nomsTags) encoderFunc {
+	if ce, ok := getCustomEncoder(t); ok {
+		return customEncoderFunc(ce.fn)
+	}
+	if t.Implements(marshalerInterface) {
+		return marshalerEncoder(t)
+	}
+	if t.Implements(marshalerVRWInterface) {
+		return marshalerVRWEncoder(t)
+	}
+	if t == timeType {
+		if tags.unixtime {
+			return unixTimeEncoder
+		}
+		return timeEncoder
+	}
+	switch t {
+	case bigIntType:
+		return bigIntEncoder
+	case bigFloatType:
+		return bigFloatEncoder
+	}
+	if isTextMarshaler(t, tags) {
+		return textEncoder
+	}
+	if tags.str {
+		return numberStringEncoder
+	}
+	return nil
+}
+
+// isPointerField returns true if a struct field of type |t| is encoded as the
+// value it points to, and so left out of the Noms struct when it's nil.
+// Pointers that implement types.Value or Marshaler are encoded as themselves.
+func isPointerField(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && !t.Implements(nomsValueInterface) && !t.Implements(marshalerInterface) && !t.Implements(marshalerVRWInterface)
+}
+
+func pointerEncoder(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTags, opt Opt) encoderFunc {
+	e := encoderCache.get(t, opt)
+	if e != nil {
+		return e
+	}
+
+	var elemEncoder encoderFunc
+	// e waits until the encoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	e = func(v reflect.Value, es *encodeState) types.Value {
+		if v.IsNil() {
+			panic(&UnsupportedTypeError{t, "Nil pointers are only supported as struct fields"})
+		}
+		ready.Wait()
+		if es.sharePointers {
+			return es.sharedRef(v, elemEncoder)
+		}
+		es.enter(v)
+		defer es.leave(v)
+		return elemEncoder(v.Elem(), es)
+	}
+
+	encoderCache.set(t, opt, e)
+	elemEncoder = typeEncoder(t.Elem(), seenStructs, tags, opt)
+	return e
+}
+
+// refEncoder writes the value that |e| encodes to the ValueReadWriter, and
+// encodes a Ref to it instead.
+func refEncoder(e encoderFunc) encoderFunc {
+	return func(v reflect.Value, es *encodeState) types.Value {
+		vrw := es.valueReadWriter()
+		if vrw == nil {
+			panic(&marshalNomsError{errors.New("Fields tagged ref can only be marshaled by MarshalVRW")})
+		}
+		return vrw.WriteValue(e(v, es))
+	}
+}
+
+func secSinceEpoch(t time.Time) types.Number {
+	return types.Number(float64(t.Unix()) + float64(t.Nanosecond())*1e-9)
+}
+
+func timeEncoder(v reflect.Value, es *encodeState) types.Value {
+	return dateTimeTemplate.NewStruct([]types.Value{secSinceEpoch(v.Interface().(time.Time))})
+}
+
+func unixTimeEncoder(v reflect.Value, es *encodeState) types.Value {
+	return secSinceEpoch(v.Interface().(time.Time))
+}
+
+// timeFromSecSinceEpoch is the inverse of secSinceEpoch.
+func timeFromSecSinceEpoch(n types.Number) time.Time {
+	s, frac := math.Modf(float64(n))
+	return time.Unix(int64(s), int64(frac*1e9))
+}
+
+func structEncoder(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) encoderFunc {
 	if t.Implements(nomsValueInterface) {
 		return nomsValueEncoder
 	}
 
-	e := encoderCache.get(t)
+	e := encoderCache.get(t, opt)
 	if e != nil {
 		return e
 	}
 
-	seenStructs[t.Name()] = t
-	fields, _, knownShape, originalFieldIndex := typeFields(t, seenStructs, false)
+	// The struct is only seen while its own fields are, so that siblings of
+	// the same type aren't taken for cycles. An entry made by an enclosing
+	// struct is left for it to delete.
+	if _, ok := seenStructs[t.Name()]; !ok {
+		seenStructs[t.Name()] = t
+		defer delete(seenStructs, t.Name())
+	}
+	fields, _, knownShape, originalFieldIndex := typeFields(t, seenStructs, nil, false, opt)
 	if knownShape {
 		fieldNames := make([]string, len(fields))
 		for i, f := range fields {
 			fieldNames[i] = f.name
 		}
 
-		structTemplate := types.MakeStructTemplate(strings.Title(t.Name()), fieldNames)
-		e = func(v reflect.Value) types.Value {
+		structTemplate := types.MakeStructTemplate(opt.structName(t), fieldNames)
+		e = func(v reflect.Value, es *encodeState) types.Value {
 			values := make(types.ValueSlice, len(fields))
 			for i, f := range fields {
-				values[i] = f.encoder(v.Field(f.index))
+				values[i] = f.encoder(v.FieldByIndex(f.index), es)
 			}
 			return structTemplate.NewStruct(values)
 		}
 	} else if originalFieldIndex == nil {
 		// Slower path: cannot precompute the Noms type since there are Noms collections,
 		// but at least there are a set number of fields.
-		name := strings.Title(t.Name())
-		e = func(v reflect.Value) types.Value {
+		name := opt.structName(t)
+		e = func(v reflect.Value, es *encodeState) types.Value {
 			data := make(types.StructData, len(fields))
 			for _, f := range fields {
-				fv := v.Field(f.index)
-				if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+				fv := v.FieldByIndex(f.index)
+				if f.omit(fv) {
 					continue
 				}
-				data[f.name] = f.encoder(fv)
+				data[f.name] = f.encoder(fv, es)
 			}
 			return types.NewStruct(name, data)
 		}
 	} else {
 		// Slowest path - we are extending some other struct. We need to start with the
 		// type of that struct and extend.
-		e = func(v reflect.Value) types.Value {
+		name := t.Name()
+		if tagged, ok := taggedStructName(t); ok {
+			name = tagged
+		}
+		e = func(v reflect.Value, es *encodeState) types.Value {
 			fv := v.FieldByIndex(originalFieldIndex)
 			ret := fv.Interface().(types.Struct)
 			if ret.IsZeroValue() {
-				ret = types.NewStruct(t.Name(), nil)
+				ret = types.NewStruct(name, nil)
 			}
 			for _, f := range fields {
-				fv := v.Field(f.index)
-				if !fv.IsValid() || f.omitEmpty && isEmptyValue(fv) {
+				fv := v.FieldByIndex(f.index)
+				if f.omit(fv) {
 					continue
 				}
-				ret = ret.Set(f.name, f.encoder(fv))
+				ret = ret.Set(f.name, f.encoder(fv, es))
 			}
 			return ret
 		}
 	}
 
-	encoderCache.set(t, e)
+	encoderCache.set(t, opt, e)
 	return e
 }
 
 func isEmptyValue(v reflect.Value) bool {
+	switch v.Type() {
+	case bigIntType:
+		i := v.Interface().(big.Int)
+		return i.Sign() == 0
+	case bigFloatType:
+		f := v.Interface().(big.Float)
+		return f.Sign() == 0
+	case rawType:
+		return v.Interface().(Raw).IsEmpty()
+	}
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
 		return v.Len() == 0
@@ -335,18 +907,66 @@ func isEmptyValue(v reflect.Value) bool {
 	case reflect.Struct:
 		z := reflect.Zero(v.Type())
 		return z.Interface() == v.Interface()
-	case reflect.Interface:
+	case reflect.Interface, reflect.Ptr:
 		return v.IsNil()
 	}
 	return false
 }
 
+// isZeroValue returns true if |v| is the zero value of its type. Unlike
+// isEmptyValue, it's false for slices and maps that are empty but not nil.
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.IsNil()
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isZeroValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isZeroValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Bool, reflect.String, reflect.Interface, reflect.Ptr,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return isEmptyValue(v)
+	}
+	return false
+}
+
 type field struct {
 	name      string
 	encoder   encoderFunc
-	index     int
+	index     []int
 	nomsType  *types.Type
 	omitEmpty bool
+	omitZero  bool
+	optional  bool
+	def       reflect.Value
+}
+
+// omit returns true if the value |fv| of |f| is left out of the Noms struct.
+// Fields tagged with "omitempty" or "omitzero", and "default=", are left out
+// when they hold their default, rather than when they're empty or zero.
+func (f field) omit(fv reflect.Value) bool {
+	if !fv.IsValid() {
+		return true
+	}
+	if !f.omitEmpty && !f.omitZero {
+		return false
+	}
+	if f.def.IsValid() {
+		return fv.Interface() == f.def.Interface()
+	}
+	return f.omitEmpty && isEmptyValue(fv) || f.omitZero && isZeroValue(fv)
 }
 
 type fieldSlice []field
@@ -355,9 +975,21 @@ func (fs fieldSlice) Len() int           { return len(fs) }
 func (fs fieldSlice) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
 func (fs fieldSlice) Less(i, j int) bool { return fs[i].name < fs[j].name }
 
+// encoderCacheT maps Go types to their encoders. Marshal calls from many
+// goroutines read it all the time, while encoders are only added when a type
+// is first marshaled, so reads don't lock: the map is never modified once
+// it's stored, and set stores a modified copy instead.
 type encoderCacheT struct {
-	sync.RWMutex
-	m map[reflect.Type]encoderFunc
+	mu sync.Mutex   // serializes set
+	m  atomic.Value // map[encoderKey]encoderFunc
+}
+
+// encoderKey identifies an encoder by the type it encodes and the options
+// that affect it. StructName isn't one: the encoders are built without it,
+// and mustMarshal applies it to their result.
+type encoderKey struct {
+	t      reflect.Type
+	naming FieldNaming
 }
 
 var encoderCache = &encoderCacheT{}
@@ -366,24 +998,27 @@ var encoderCache = &encoderCacheT{}
 // `noms:",set"` tag encode differently (Set vs Map).
 var setEncoderCache = &encoderCacheT{}
 
-func (c *encoderCacheT) get(t reflect.Type) encoderFunc {
-	c.RLock()
-	defer c.RUnlock()
-	return c.m[t]
+// get returns the encoder for |t| with the FieldNaming of |opt|.
+func (c *encoderCacheT) get(t reflect.Type, opt Opt) encoderFunc {
+	m, _ := c.m.Load().(map[encoderKey]encoderFunc)
+	return m[encoderKey{t, opt.FieldNaming}]
 }
 
-func (c *encoderCacheT) set(t reflect.Type, e encoderFunc) {
-	c.Lock()
-	defer c.Unlock()
-	if c.m == nil {
-		c.m = map[reflect.Type]encoderFunc{}
+func (c *encoderCacheT) set(t reflect.Type, opt Opt, e encoderFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, _ := c.m.Load().(map[encoderKey]encoderFunc)
+	m := make(map[encoderKey]encoderFunc, len(old)+1)
+	for k, v := range old {
+		m[k] = v
 	}
-	c.m[t] = e
+	m[encoderKey{t, opt.FieldNaming}] = e
+	c.m.Store(m)
 }
 
-func getTags(f reflect.StructField) (tags nomsTags) {
+func getTags(f reflect.StructField, naming FieldNaming) (tags nomsTags) {
 	reflectTags := f.Tag.Get("noms")
-	if reflectTags == "-" {
+	if _, ok := structNameTag(f); ok || reflectTags == "-" {
 		tags.skip = true
 		return
 	}
@@ -392,7 +1027,7 @@ func getTags(f reflect.StructField) (tags nomsTags) {
 
 	// The first tag is always the name, or empty to use the field as the name.
 	if len(tagsSlice) == 0 || tagsSlice[0] == "" {
-		tags.name = strings.ToLower(f.Name[:1]) + f.Name[1:]
+		tags.name = naming.fieldName(f.Name)
 	} else {
 		tags.name = tagsSlice[0]
 	}
@@ -405,59 +1040,305 @@ func getTags(f reflect.StructField) (tags nomsTags) {
 		switch tag := tagsSlice[i]; tag {
 		case "omitempty":
 			tags.omitEmpty = true
+		case "omitzero":
+			tags.omitZero = true
+		case "optional":
+			tags.optional = true
 		case "original":
 			tags.original = true
+		case "ref":
+			tags.ref = true
+		case "blob", "list":
+			if !isByteSequence(f.Type) {
+				panic(&InvalidTagError{"The " + tag + " tag is only valid on byte slices and arrays: " + f.Name})
+			}
+			tags.blob = tag == "blob"
+			tags.list = tag == "list"
 		case "set":
 			tags.set = true
+		case "string":
+			if !isNumeric(f.Type) {
+				panic(&InvalidTagError{"The string tag is only valid on numeric fields: " + f.Name})
+			}
+			tags.str = true
+		case "noname":
+			panic(&InvalidTagError{"The noname tag is only valid on a blank field: " + f.Name})
+		case "unixtime":
+			if f.Type != timeType {
+				panic(&InvalidTagError{"The unixtime tag is only valid on time.Time fields: " + f.Name})
+			}
+			tags.unixtime = true
 		default:
-			panic(&InvalidTagError{"Unrecognized tag: " + tag})
+			if strings.HasPrefix(tag, "name=") {
+				panic(&InvalidTagError{"The name tag is only valid on a blank field: " + f.Name})
+			}
+			if strings.HasPrefix(tag, "default=") {
+				tags.def = parseDefault(f, strings.TrimPrefix(tag, "default="))
+				continue
+			}
+			if !strings.HasPrefix(tag, "version=") {
+				panic(&InvalidTagError{"Unrecognized tag: " + tag})
+			}
+			v, err := strconv.Atoi(strings.TrimPrefix(tag, "version="))
+			if err != nil || v < 1 {
+				panic(&InvalidTagError{"Invalid version tag: " + tag})
+			}
+			tags.version = v
 		}
 	}
 	return
 }
 
+// structNameTag returns the name of the Noms struct that |f| gives the Go
+// struct it's in, if it's a blank field tagged with `noms:",name=<name>"`, or
+// with `noms:",noname"` for no name.
+func structNameTag(f reflect.StructField) (name string, ok bool) {
+	if f.Name != "_" {
+		return "", false
+	}
+	tag := f.Tag.Get("noms")
+	if tag == ",noname" {
+		return "", true
+	}
+	if !strings.HasPrefix(tag, ",name=") {
+		return "", false
+	}
+	name = strings.TrimPrefix(tag, ",name=")
+	if !types.IsValidStructFieldName(name) {
+		panic(&InvalidTagError{"Invalid struct name: " + name})
+	}
+	return name, true
+}
+
+// taggedStructName returns the name of the Noms struct that a blank field of
+// the Go struct |t| gives it, if one does.
+func taggedStructName(t reflect.Type) (string, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := structNameTag(t.Field(i)); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// isNumeric returns true if |t| is a Go integer or floating point type, which
+// the "string" tag is valid on. types.Number is copied over like any other
+// Noms value, so it isn't.
+func isNumeric(t reflect.Type) bool {
+	if t.Implements(nomsValueInterface) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseDefault returns the value of the field |f| that |literal|, from its
+// "default=" tag, describes.
+func parseDefault(f reflect.StructField, literal string) reflect.Value {
+	def := reflect.New(f.Type).Elem()
+	var err error
+	switch f.Type.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(literal)
+		def.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(literal, 10, f.Type.Bits())
+		def.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(literal, 10, f.Type.Bits())
+		def.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var fl float64
+		fl, err = strconv.ParseFloat(literal, f.Type.Bits())
+		def.SetFloat(fl)
+	case reflect.String:
+		def.SetString(literal)
+	default:
+		panic(&InvalidTagError{"The default tag is only valid on bool, number and string fields: " + f.Name})
+	}
+	if err != nil {
+		panic(&InvalidTagError{"Invalid default tag for field " + f.Name + ": " + literal})
+	}
+	return def
+}
+
 func validateField(f reflect.StructField, t reflect.Type) {
-	if f.Anonymous {
-		panic(&UnsupportedTypeError{t, "Embedded structs are not supported"})
+	if f.Anonymous && !hasTagName(f) {
+		panic(&UnsupportedTypeError{t, "Embedded fields must be structs or be named by a tag"})
 	}
 	if unicode.IsLower(rune(f.Name[0])) { // we only allow ascii so this is fine
 		panic(&UnsupportedTypeError{t, "Non exported fields are not supported"})
 	}
 }
 
-func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, computeType bool) (fields fieldSlice, structType *types.Type, knownShape bool, originalFieldIndex []int) {
-	knownShape = true
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		tags := getTags(f)
-		if tags.skip {
-			continue
+func hasTagName(f reflect.StructField) bool {
+	return strings.Split(f.Tag.Get("noms"), ",")[0] != ""
+}
+
+// isEmbeddedStruct returns true if |f| is an embedded struct whose fields are
+// flattened into the struct it's embedded in.
+func isEmbeddedStruct(f reflect.StructField) bool {
+	t := f.Type
+	return f.Anonymous && !hasTagName(f) && t.Kind() == reflect.Struct && t != timeType && t != bigIntType && t != bigFloatType && !t.Implements(nomsValueInterface) &&
+		!t.Implements(marshalerInterface) && !t.Implements(marshalerVRWInterface) && !reflect.PtrTo(t).Implements(unmarshalerInterface) && !reflect.PtrTo(t).Implements(unmarshalerFromInterface) &&
+		!isTextMarshaler(t, nomsTags{}) && !hasCustomCodec(t)
+}
+
+// structField is a Go struct field that becomes a field of the Noms struct.
+// Its Index is relative to the outermost struct, for use with FieldByIndex.
+type structField struct {
+	reflect.StructField
+	tags nomsTags
+}
+
+// structFields returns the fields of |t| that become fields of the Noms
+// struct. As in encoding/json, the exported fields of embedded structs are
+// promoted, unless the embedded struct is named by its tag, and a field hides
+// any more deeply nested fields of the same name. Unlike encoding/json, two
+// fields of the same name at the same depth are an error. Fields are named
+// according to |naming| unless their tags name them.
+func structFields(t reflect.Type, naming FieldNaming) []structField {
+	if fields, ok := fieldCache.get(t, naming); ok {
+		return fields
+	}
+	fields := findStructFields(t, naming)
+	fieldCache.set(t, naming, fields)
+	return fields
+}
+
+// fieldCacheT maps Go struct types to their fields, for each FieldNaming.
+// Like encoderCacheT, it's read without locking.
+type fieldCacheT struct {
+	mu sync.Mutex   // serializes set
+	m  atomic.Value // map[fieldKey][]structField
+}
+
+type fieldKey struct {
+	t      reflect.Type
+	naming FieldNaming
+}
+
+var fieldCache = &fieldCacheT{}
+
+func (c *fieldCacheT) get(t reflect.Type, naming FieldNaming) ([]structField, bool) {
+	m, _ := c.m.Load().(map[fieldKey][]structField)
+	fields, ok := m[fieldKey{t, naming}]
+	return fields, ok
+}
+
+func (c *fieldCacheT) set(t reflect.Type, naming FieldNaming, fields []structField) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, _ := c.m.Load().(map[fieldKey][]structField)
+	m := make(map[fieldKey][]structField, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	m[fieldKey{t, naming}] = fields
+	c.m.Store(m)
+}
+
+func findStructFields(t reflect.Type, naming FieldNaming) []structField {
+	type candidate struct {
+		structField
+		path  string
+		depth int
+	}
+	var candidates []candidate
+	var walk func(st reflect.Type, index []int, prefix string, depth int)
+	walk = func(st reflect.Type, index []int, prefix string, depth int) {
+		for i := 0; i < st.NumField(); i++ {
+			f := st.Field(i)
+			tags := getTags(f, naming)
+			if tags.skip {
+				continue
+			}
+			f.Index = append(append([]int{}, index...), i)
+			if isEmbeddedStruct(f) {
+				walk(f.Type, f.Index, prefix+f.Name+".", depth+1)
+				continue
+			}
+			validateField(f, t)
+			candidates = append(candidates, candidate{structField{f, tags}, prefix + f.Name, depth})
 		}
+	}
+	walk(t, nil, "", 0)
 
+	minDepth := map[string]int{}
+	for _, c := range candidates {
+		if md, ok := minDepth[c.tags.name]; !c.tags.original && (!ok || c.depth < md) {
+			minDepth[c.tags.name] = c.depth
+		}
+	}
+	fields := make([]structField, 0, len(candidates))
+	seen := map[string]string{}
+	for _, c := range candidates {
+		if !c.tags.original {
+			if c.depth > minDepth[c.tags.name] {
+				continue
+			}
+			if other, ok := seen[c.tags.name]; ok {
+				panic(&InvalidTagError{"Fields " + other + " and " + c.path + " of " + t.String() + " both have the name " + c.tags.name})
+			}
+			seen[c.tags.name] = c.path
+		}
+		fields = append(fields, c.structField)
+	}
+	return fields
+}
+
+func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, r *TypeRegistry, computeType bool, opt Opt) (fields fieldSlice, structType *types.Type, knownShape bool, originalFieldIndex []int) {
+	knownShape = true
+	fieldOpt := opt.nested()
+	for _, sf := range structFields(t, opt.FieldNaming) {
+		f, tags := sf.StructField, sf.tags
 		if tags.original {
 			originalFieldIndex = f.Index
 			continue
 		}
 
 		var nt *types.Type
-		validateField(f, t)
 		if computeType {
-			nt = encodeType(f.Type, seenStructs, tags)
+			nt = encodeType(f.Type, seenStructs, r, tags, fieldOpt)
 			if nt == nil {
 				knownShape = false
 			}
 		}
 
-		if tags.omitEmpty && !computeType {
+		encoder := typeEncoder(f.Type, seenStructs, tags, fieldOpt)
+		if tags.version > 0 {
+			encoder = versionEncoder(f, t, tags.version)
+		}
+		if tags.ref {
+			encoder = refEncoder(encoder)
+			if nt != nil {
+				nt = types.MakeRefType(nt)
+			}
+		}
+
+		// Nil pointers are always left out.
+		omitEmpty := tags.omitEmpty || isPointerField(f.Type)
+		if (omitEmpty || tags.omitZero) && !computeType {
 			knownShape = false
 		}
 
 		fields = append(fields, field{
 			name:      tags.name,
-			encoder:   typeEncoder(f.Type, seenStructs, tags),
-			index:     i,
+			encoder:   encoder,
+			index:     f.Index,
 			nomsType:  nt,
-			omitEmpty: tags.omitEmpty,
+			omitEmpty: omitEmpty,
+			omitZero:  tags.omitZero,
+			optional:  omitEmpty || tags.omitZero || tags.optional || tags.def.IsValid(),
+			def:       tags.def,
 		})
 
 	}
@@ -468,123 +1349,258 @@ func typeFields(t reflect.Type, seenStructs map[string]reflect.Type, computeType
 			structTypeFields[i] = types.StructField{
 				Name:     fs.name,
 				Type:     fs.nomsType,
-				Optional: fs.omitEmpty,
+				Optional: fs.optional,
 			}
 		}
-		structType = types.MakeStructType(strings.Title(t.Name()), structTypeFields...)
+		structType = types.MakeStructType(opt.structName(t), structTypeFields...)
 	}
 	return
 }
 
-func listEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
-	e := encoderCache.get(t)
+// versionEncoder returns an encoder for the field |f| of |t| tagged with
+// `noms:",version=N"`, which always encodes N, the version of |t|'s schema.
+func versionEncoder(f reflect.StructField, t reflect.Type, version int) encoderFunc {
+	switch f.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic(&InvalidTagError{"The version tag requires an integer field, but " + t.String() + "." + f.Name + " is " + f.Type.String()})
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if other := t.Field(i); other.Name != f.Name && getTags(other, LowerCamelCase).version > 0 {
+			panic(&InvalidTagError{"Only one field of " + t.String() + " may have the version tag"})
+		}
+	}
+	return func(v reflect.Value, es *encodeState) types.Value {
+		return types.Number(version)
+	}
+}
+
+func listEncoder(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) encoderFunc {
+	e := encoderCache.get(t, opt)
 	if e != nil {
 		return e
 	}
 
 	var elemEncoder encoderFunc
-	// lock e until encoder(s) are initialized
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	e = func(v reflect.Value) types.Value {
-		init.RLock()
-		defer init.RUnlock()
+	// e waits until the encoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	e = func(v reflect.Value, es *encodeState) types.Value {
+		ready.Wait()
+		if v.Kind() == reflect.Slice {
+			es.enter(v)
+			defer es.leave(v)
+		}
+		if v.Len() >= parallelListLen && !es.sharePointers {
+			return encodeListParallel(v, elemEncoder, es)
+		}
 		values := make([]types.Value, v.Len())
 		for i := 0; i < v.Len(); i++ {
-			values[i] = elemEncoder(v.Index(i))
+			values[i] = elemEncoder(v.Index(i), es)
 		}
 		return types.NewList(values...)
 	}
 
-	encoderCache.set(t, e)
-	elemEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{})
+	encoderCache.set(t, opt, e)
+	elemEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{}, opt.nested())
 	return e
 }
 
+const (
+	// parallelListLen is the length from which slices and arrays are encoded
+	// by several goroutines.
+	parallelListLen = 1 << 14
+	// parallelListBatch is the number of elements each goroutine encodes at a
+	// time.
+	parallelListBatch = 1 << 10
+)
+
+// encodeListParallel encodes the slice or array |v| as a List, encoding
+// batches of its elements with |elemEncoder| in parallel, while they're
+// appended to the List in order as they're done. Panics in the goroutines
+// that encode them are passed on to the caller's, so that marshal recovers
+// them as usual. Since the encodeState of each goroutine is its own, it can't
+// be used with SharePointers, which shares Refs between all the elements.
+func encodeListParallel(v reflect.Value, elemEncoder encoderFunc, es *encodeState) types.List {
+	type batch struct {
+		values   []types.Value
+		panicked interface{}
+	}
+	n := v.Len()
+	batches := make([]chan batch, (n+parallelListBatch-1)/parallelListBatch)
+	for i := range batches {
+		batches[i] = make(chan batch, 1)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range batches {
+			select {
+			case next <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wes := es.fork()
+		go func() {
+			for i := range next {
+				func() {
+					b := batch{}
+					defer func() {
+						b.panicked = recover()
+						batches[i] <- b
+					}()
+					start, end := i*parallelListBatch, (i+1)*parallelListBatch
+					if end > n {
+						end = n
+					}
+					b.values = make([]types.Value, end-start)
+					for j := start; j < end; j++ {
+						b.values[j-start] = elemEncoder(v.Index(j), wes)
+					}
+				}()
+			}
+		}()
+	}
+
+	values := make(chan types.Value, parallelListBatch)
+	list := types.NewStreamingList(nil, values)
+	defer func() {
+		if values != nil {
+			close(values)
+		}
+	}()
+	for _, c := range batches {
+		b := <-c
+		if b.panicked != nil {
+			panic(b.panicked)
+		}
+		for _, ev := range b.values {
+			values <- ev
+		}
+	}
+	close(values)
+	values = nil
+	return <-list
+}
+
 // Encode set from array or slice
-func setFromListEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
-	e := setEncoderCache.get(t)
+func setFromListEncoder(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) encoderFunc {
+	e := setEncoderCache.get(t, opt)
 	if e != nil {
 		return e
 	}
 
 	var elemEncoder encoderFunc
-	// lock e until encoder(s) are initialized
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	e = func(v reflect.Value) types.Value {
-		init.RLock()
-		defer init.RUnlock()
+	// e waits until the encoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	e = func(v reflect.Value, es *encodeState) types.Value {
+		ready.Wait()
 		values := make([]types.Value, v.Len())
 		for i := 0; i < v.Len(); i++ {
-			values[i] = elemEncoder(v.Index(i))
+			values[i] = elemEncoder(v.Index(i), es)
 		}
 		return types.NewSet(values...)
 	}
 
-	setEncoderCache.set(t, e)
-	elemEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{})
+	setEncoderCache.set(t, opt, e)
+	elemEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{}, opt.nested())
 	return e
 }
 
-func setEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
-	e := setEncoderCache.get(t)
+func setEncoder(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) encoderFunc {
+	e := setEncoderCache.get(t, opt)
 	if e != nil {
 		return e
 	}
 
 	var encoder encoderFunc
-	// lock e until encoder(s) are initialized
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	e = func(v reflect.Value) types.Value {
-		init.RLock()
-		defer init.RUnlock()
+	// e waits until the encoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	e = func(v reflect.Value, es *encodeState) types.Value {
+		ready.Wait()
 		values := make([]types.Value, v.Len(), v.Len())
 		for i, k := range v.MapKeys() {
-			values[i] = encoder(k)
+			values[i] = encoder(k, es)
 		}
 		return types.NewSet(values...)
 	}
 
-	setEncoderCache.set(t, e)
-	encoder = typeEncoder(t.Key(), seenStructs, nomsTags{})
+	setEncoderCache.set(t, opt, e)
+	encoder = typeEncoder(t.Key(), seenStructs, nomsTags{}, opt.nested())
 	return e
 }
 
-func mapEncoder(t reflect.Type, seenStructs map[string]reflect.Type) encoderFunc {
-	e := encoderCache.get(t)
+func mapEncoder(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) encoderFunc {
+	e := encoderCache.get(t, opt)
 	if e != nil {
 		return e
 	}
 
 	var keyEncoder encoderFunc
 	var valueEncoder encoderFunc
-	// lock e until encoder(s) are initialized
-	var init sync.RWMutex
-	init.Lock()
-	defer init.Unlock()
-	e = func(v reflect.Value) types.Value {
-		init.RLock()
-		defer init.RUnlock()
+	// e waits until the encoder(s) it uses are initialized
+	var ready sync.WaitGroup
+	ready.Add(1)
+	defer ready.Done()
+	e = func(v reflect.Value, es *encodeState) types.Value {
+		ready.Wait()
+		es.enter(v)
+		defer es.leave(v)
 		keys := v.MapKeys()
-		kvs := make([]types.Value, 2*len(keys))
+		entries := make(mapEntrySlice, len(keys))
 		for i, k := range keys {
-			kvs[2*i] = keyEncoder(k)
-			kvs[2*i+1] = valueEncoder(v.MapIndex(k))
+			entries[i] = mapEntry{keyEncoder(k, es), valueEncoder(v.MapIndex(k), es)}
 		}
-		return types.NewMap(kvs...)
+		return types.NewMap(entries.sortedKVs()...)
 	}
 
-	encoderCache.set(t, e)
-	keyEncoder = typeEncoder(t.Key(), seenStructs, nomsTags{})
-	valueEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{})
+	encoderCache.set(t, opt, e)
+	keyEncoder = typeEncoder(t.Key(), seenStructs, nomsTags{}, opt.nested())
+	valueEncoder = typeEncoder(t.Elem(), seenStructs, nomsTags{}, opt.nested())
 	return e
 }
 
+type mapEntry struct {
+	key, value types.Value
+}
+
+// mapEntrySlice orders entries by key and then by value. Distinct Go map keys
+// can encode to the same Noms value (e.g. int(1) and float64(1) in a
+// map[interface{}]T) and types.NewMap keeps the last of any duplicate keys it
+// is given, so sorting by value too makes the winner independent of Go's
+// randomized map iteration order.
+type mapEntrySlice []mapEntry
+
+func (es mapEntrySlice) Len() int      { return len(es) }
+func (es mapEntrySlice) Swap(i, j int) { es[i], es[j] = es[j], es[i] }
+func (es mapEntrySlice) Less(i, j int) bool {
+	if !es[i].key.Equals(es[j].key) {
+		return es[i].key.Less(es[j].key)
+	}
+	return es[i].value.Less(es[j].value)
+}
+
+func (es mapEntrySlice) sortedKVs() []types.Value {
+	sort.Sort(es)
+	kvs := make([]types.Value, 2*len(es))
+	for i, e := range es {
+		kvs[2*i] = e.key
+		kvs[2*i+1] = e.value
+	}
+	return kvs
+}
+
 func shouldEncodeAsSet(t reflect.Type, tags nomsTags) bool {
 	switch t.Kind() {
 	case reflect.Slice, reflect.Array:
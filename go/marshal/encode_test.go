@@ -12,7 +12,10 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"testing/quick"
+	"time"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
 )
@@ -160,16 +163,67 @@ func assertEncodeErrorMessage(t *testing.T, v interface{}, expectedMessage strin
 
 func TestInvalidTypes(t *testing.T) {
 	assertEncodeErrorMessage(t, make(chan int), "Type is not supported, type: chan int")
-	x := 42
-	assertEncodeErrorMessage(t, &x, "Type is not supported, type: *int")
+	assertEncodeErrorMessage(t, complex(1, 2), "Type is not supported, type: complex128")
 }
 
 func TestEncodeEmbeddedStruct(t *testing.T) {
-	type EmbeddedStruct struct{}
+	assert := assert.New(t)
+
+	type Base struct {
+		ID   int
+		Name string
+	}
+	type inner struct {
+		Flag bool
+	}
 	type TestStruct struct {
-		EmbeddedStruct
+		Base
+		inner
+		Name   string // hides Base.Name
+		Tagged Base   `noms:"base"`
+	}
+	v, err := Marshal(TestStruct{Base{1, "base"}, inner{true}, "test", Base{2, "tagged"}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("TestStruct", types.StructData{
+		"iD":   types.Number(1),
+		"flag": types.Bool(true),
+		"name": types.String("test"),
+		"base": types.NewStruct("Base", types.StructData{
+			"iD":   types.Number(2),
+			"name": types.String("tagged"),
+		}),
+	}).Equals(v))
+
+	var ts TestStruct
+	assert.NoError(Unmarshal(v, &ts))
+	assert.Equal(TestStruct{Base{1, ""}, inner{true}, "test", Base{2, "tagged"}}, ts)
+
+	type Named struct {
+		Base `noms:"b"`
 	}
-	assertEncodeErrorMessage(t, TestStruct{EmbeddedStruct{}}, "Embedded structs are not supported, type: marshal.TestStruct")
+	v, err = Marshal(Named{Base{3, "named"}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Named", types.StructData{
+		"b": types.NewStruct("Base", types.StructData{
+			"iD":   types.Number(3),
+			"name": types.String("named"),
+		}),
+	}).Equals(v))
+
+	type Other struct {
+		Name string
+	}
+	type Ambiguous struct {
+		Base
+		Other
+	}
+	assertEncodeErrorMessage(t, Ambiguous{}, "Fields Base.Name and Other.Name of marshal.Ambiguous both have the name name")
+
+	type Number int
+	type EmbeddedInt struct {
+		Number
+	}
+	assertEncodeErrorMessage(t, EmbeddedInt{}, "Embedded fields must be structs or be named by a tag, type: marshal.EmbeddedInt")
 }
 
 func TestEncodeNonExportedField(t *testing.T) {
@@ -219,6 +273,22 @@ func TestEncodeInvalidNamedFields(t *testing.T) {
 	assertEncodeErrorMessage(t, S{42}, "Invalid struct field name: 1a")
 }
 
+func TestEncodeDuplicateFieldNames(t *testing.T) {
+	type S struct {
+		Abc int
+		Xyz int `noms:"abc"`
+	}
+	assertEncodeErrorMessage(t, S{1, 2}, "Fields Abc and Xyz of marshal.S both have the name abc")
+
+	type T struct {
+		Xyz int `noms:"abc"`
+		Abc int
+		Ok  bool
+	}
+	assertEncodeErrorMessage(t, T{1, 2, true}, "Fields Xyz and Abc of marshal.T both have the name abc")
+	assertMarshalTypeErrorMessage(t, T{}, "Fields Xyz and Abc of marshal.T both have the name abc")
+}
+
 func TestEncodeOmitEmpty(t *testing.T) {
 	assert := assert.New(t)
 
@@ -369,6 +439,56 @@ func TestEncodeOmitEmpty(t *testing.T) {
 	assert.True(types.NewStruct("S4", types.StructData{}).Equals(v9))
 }
 
+func TestEncodeOmitZero(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		A int
+	}
+	type S struct {
+		List  []string          `noms:",omitzero"`
+		Map   map[string]string `noms:",omitzero"`
+		Int   int               `noms:",omitzero"`
+		Inner Inner             `noms:",omitzero"`
+		Both  []string          `noms:",omitempty,omitzero"`
+	}
+
+	v, err := Marshal(S{})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{}).Equals(v))
+	var s S
+	assert.NoError(Unmarshal(v, &s))
+	assert.Nil(s.List)
+	assert.Nil(s.Map)
+
+	// Empty collections that aren't nil are kept, unlike with omitempty, and
+	// Unmarshal doesn't make them nil.
+	v, err = Marshal(S{List: []string{}, Map: map[string]string{}, Inner: Inner{1}, Both: []string{}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"list":  types.NewList(),
+		"map":   types.NewMap(),
+		"inner": types.NewStruct("Inner", types.StructData{"a": types.Number(1)}),
+	}).Equals(v))
+	s = S{}
+	assert.NoError(Unmarshal(v, &s))
+	assert.NotNil(s.List)
+	assert.Empty(s.List)
+	assert.NotNil(s.Map)
+	assert.Empty(s.Map)
+	assert.Equal(Inner{1}, s.Inner)
+
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("S",
+		types.StructField{"both", types.MakeListType(types.StringType), true},
+		types.StructField{"inner", types.MakeStructType("Inner", types.StructField{"a", types.NumberType, false}), true},
+		types.StructField{"int", types.NumberType, true},
+		types.StructField{"list", types.MakeListType(types.StringType), true},
+		types.StructField{"map", types.MakeMapType(types.StringType, types.StringType), true},
+	).Equals(typ))
+}
+
 func ExampleMarshal() {
 	type Person struct {
 		Given string
@@ -522,6 +642,81 @@ func TestEncodeMap(t *testing.T) {
 	assert.True(types.NewMap().Equals(v))
 }
 
+func TestEncodeMapDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		X bool
+	}
+	type Key struct {
+		A string
+		B int
+		C Inner
+	}
+	type Value struct {
+		M map[string]float64
+		S map[int]struct{} `noms:",set"`
+	}
+
+	f := func(keys []string, nums []float64) bool {
+		// Keep the generated values small, the nesting below multiplies them.
+		if len(keys) > 8 {
+			keys = keys[:8]
+		}
+		if len(nums) > 8 {
+			nums = nums[:8]
+		}
+		for i, k := range keys {
+			if r := []rune(k); len(r) > 8 {
+				keys[i] = string(r[:8])
+			}
+		}
+		m := map[string]Value{}
+		for i, k := range keys {
+			v := Value{M: map[string]float64{}, S: map[int]struct{}{}}
+			for j, n := range nums {
+				v.M[fmt.Sprintf("%s%d", k, j)] = n
+				v.S[i+j] = struct{}{}
+			}
+			m[k] = v
+		}
+		nested := map[Key]map[string]Value{}
+		for i, k := range keys {
+			nested[Key{k, i, Inner{i%2 == 0}}] = m
+		}
+
+		expected := MustMarshal(nested)
+		for i := 0; i < 5; i++ {
+			// Rebuild the maps so that Go assigns them a fresh iteration order.
+			copied := map[Key]map[string]Value{}
+			for k, v := range nested {
+				c := map[string]Value{}
+				for k2, v2 := range v {
+					c[k2] = v2
+				}
+				copied[k] = c
+			}
+			if !expected.Equals(MustMarshal(copied)) {
+				return false
+			}
+		}
+		return true
+	}
+	assert.NoError(quick.Check(f, &quick.Config{MaxCount: 20}))
+}
+
+func TestEncodeMapDuplicateKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	// int(1) and float64(1) are distinct Go keys that both encode to Number(1).
+	expected := types.NewMap(types.Number(1), types.String("z"))
+	for i := 0; i < 20; i++ {
+		v, err := Marshal(map[interface{}]string{1: "a", float64(1): "z", uint8(1): "m"})
+		assert.NoError(err)
+		assert.True(expected.Equals(v))
+	}
+}
+
 func TestEncodeInterface(t *testing.T) {
 	assert := assert.New(t)
 
@@ -923,3 +1118,669 @@ func TestMarshalerErrors(t *testing.T) {
 	m3 := panicsMarshaler{}
 	assert.Panics(func() { Marshal(m3) })
 }
+
+type blobRefMarshaler string
+
+func (u blobRefMarshaler) MarshalNoms(vrw types.ValueReadWriter) (types.Value, error) {
+	if vrw == nil {
+		return nil, errors.New("no ValueReadWriter")
+	}
+	b := types.NewStreamingBlob(vrw, strings.NewReader(string(u)))
+	return vrw.WriteValue(b), nil
+}
+
+func TestMarshalerVRW(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+	defer vs.Close()
+
+	type S struct {
+		B  blobRefMarshaler
+		Bs []blobRefMarshaler
+	}
+	v, err := MarshalVRW(vs, S{"abc", []blobRefMarshaler{"d", "e"}})
+	assert.NoError(err)
+
+	st := v.(types.Struct)
+	r := st.Get("b").(types.Ref)
+	assert.True(types.NewBlob(strings.NewReader("abc")).Equals(vs.ReadValue(r.TargetHash())))
+	st.Get("bs").(types.List).IterAll(func(v types.Value, i uint64) {
+		assert.True(vs.ReadValue(v.(types.Ref).TargetHash()).Equals(types.NewBlob(strings.NewReader([]string{"d", "e"}[i]))))
+	})
+
+	// Without a ValueReadWriter the marshaler is handed nil.
+	_, err = Marshal(S{})
+	assert.EqualError(err, "no ValueReadWriter")
+}
+
+func TestEncodeRefTag(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+	defer vs.Close()
+
+	type Doc struct {
+		Title string
+		Body  []string
+	}
+	type S struct {
+		Doc  Doc   `noms:",ref"`
+		Prev *Doc  `noms:",ref"`
+		Docs []Doc `noms:",ref"`
+	}
+	doc := Doc{"Intro", []string{"a", "b"}}
+	v, err := MarshalVRW(vs, S{Doc: doc, Docs: []Doc{doc}})
+	assert.NoError(err)
+
+	st := v.(types.Struct)
+	r := st.Get("doc").(types.Ref)
+	assert.True(MustMarshal(doc).Equals(vs.ReadValue(r.TargetHash())))
+	_, ok := st.MaybeGet("prev")
+	assert.False(ok)
+	// Equal values are written once and shared.
+	r2 := vs.ReadValue(st.Get("docs").(types.Ref).TargetHash()).(types.List).Get(0)
+	assert.True(MustMarshal(doc).Equals(r2))
+
+	_, err = Marshal(S{})
+	assert.EqualError(err, "Fields tagged ref can only be marshaled by MarshalVRW")
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+
+	orig := types.NewStruct("Photo", types.StructData{
+		"title": types.String("Sunset"),
+		"tags":  types.NewSet(types.String("sky")),
+		"width": types.Number(640),
+	})
+
+	type Edit struct {
+		Title string
+		Width int    `noms:",omitempty"`
+		Note  string `noms:"-"`
+	}
+
+	s, err := Apply(orig, Edit{Title: "Dusk", Note: "ignored"})
+	assert.NoError(err)
+	assert.True(orig.Set("title", types.String("Dusk")).Equals(s))
+	assert.Equal("Photo", s.Name())
+
+	s, err = Apply(orig, &Edit{Title: "Dusk", Width: 800})
+	assert.NoError(err)
+	assert.True(orig.Set("title", types.String("Dusk")).Set("width", types.Number(800)).Equals(s))
+
+	// Fields that aren't in orig yet are added.
+	type Rating struct {
+		Stars int
+	}
+	s, err = Apply(orig, Rating{5})
+	assert.NoError(err)
+	assert.True(types.Number(5).Equals(s.Get("stars")))
+	assert.True(orig.Get("tags").Equals(s.Get("tags")))
+
+	// With no original, Apply is just Marshal.
+	s, err = Apply(types.Struct{}, Rating{5})
+	assert.NoError(err)
+	assert.True(MustMarshal(Rating{5}).Equals(s))
+}
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	orig := types.NewStruct("Photo", types.StructData{
+		"title":   types.String("Sunset"),
+		"caption": types.String("Over the bay"),
+		"tags":    types.NewSet(types.String("sky")),
+		"width":   types.Number(640),
+	})
+
+	type Photo struct {
+		Title   string
+		Caption string `noms:",omitempty"`
+		Width   int
+		Height  *int
+	}
+	var p Photo
+	assert.NoError(Unmarshal(orig, &p))
+
+	// Nothing changed, so orig comes back as it was.
+	s, err := Update(orig, p)
+	assert.NoError(err)
+	assert.True(orig.Equals(s))
+
+	// Unlike Apply, fields that v leaves out are removed.
+	p.Width = 800
+	p.Caption = ""
+	s, err = Update(orig, &p)
+	assert.NoError(err)
+	assert.True(orig.Set("width", types.Number(800)).Delete("caption").Equals(s))
+	s, err = Apply(orig, &p)
+	assert.NoError(err)
+	assert.True(orig.Set("width", types.Number(800)).Equals(s))
+
+	var p2 Photo
+	s, err = Update(orig, &p)
+	assert.NoError(err)
+	assert.NoError(Unmarshal(s, &p2))
+	assert.Equal(p, p2)
+	assert.True(orig.Get("tags").Equals(s.Get("tags")))
+
+	height := 480
+	p.Height = &height
+	s, err = Update(orig, p)
+	assert.NoError(err)
+	assert.True(types.Number(480).Equals(s.Get("height")))
+	assert.Equal("Photo", s.Name())
+
+	// With no original, Update is just Marshal.
+	s, err = Update(types.Struct{}, p)
+	assert.NoError(err)
+	assert.True(MustMarshal(p).Equals(s))
+
+	_, err = Update(orig, 42)
+	assert.IsType(&UnsupportedTypeError{}, err)
+}
+
+func TestApplyErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	orig := types.NewStruct("S", nil)
+	_, err := Apply(orig, 42)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	_, err = Apply(orig, []string{"a"})
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	_, err = Apply(orig, orig)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	type Bad struct {
+		F int `noms:",nope"`
+	}
+	_, err = Apply(orig, Bad{})
+	assert.IsType(&InvalidTagError{}, err)
+
+	_, err = Apply(orig, primitiveStructType{1, 2})
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	_, err = Apply(orig, nil)
+	assert.EqualError(err, "Apply requires a Go struct, not nil")
+	_, err = Update(orig, nil)
+	assert.EqualError(err, "Update requires a Go struct, not nil")
+	var nilPtr *Bad
+	_, err = Update(orig, nilPtr)
+	assert.IsType(&UnsupportedTypeError{}, err)
+}
+
+func TestEncodeTime(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Created  time.Time
+		Modified time.Time `noms:",unixtime"`
+	}
+	created := time.Unix(1500000000, 250000000)
+	modified := time.Unix(-100, 0)
+	v, err := Marshal(S{created, modified})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"created":  types.NewStruct("DateTime", types.StructData{"secSinceEpoch": types.Number(1500000000.25)}),
+		"modified": types.Number(-100),
+	}).Equals(v))
+
+	var s S
+	assert.NoError(Unmarshal(v, &s))
+	assert.True(created.Equal(s.Created))
+	assert.True(modified.Equal(s.Modified))
+
+	v, err = Marshal([]time.Time{created})
+	assert.NoError(err)
+	assert.True(types.NewList(dateTimeTemplate.NewStruct([]types.Value{types.Number(1500000000.25)})).Equals(v))
+
+	type Bad struct {
+		Modified int `noms:",unixtime"`
+	}
+	_, err = Marshal(Bad{})
+	assert.IsType(&InvalidTagError{}, err)
+}
+
+func TestEncodeString(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		ID    uint64  `noms:"id,string"`
+		Delta int8    `noms:",string"`
+		Ratio float32 `noms:",string"`
+	}
+	// 1<<53 + 1 can't be held by a float64.
+	in := S{1<<53 + 1, -3, 0.1}
+	v, err := Marshal(in)
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"id":    types.String("9007199254740993"),
+		"delta": types.String("-3"),
+		"ratio": types.String("0.1"),
+	}).Equals(v))
+
+	var out S
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(in, out)
+
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructTypeFromFields("S", types.FieldMap{
+		"id":    types.StringType,
+		"delta": types.StringType,
+		"ratio": types.StringType,
+	}).Equals(typ))
+
+	err = Unmarshal(types.NewStruct("S", types.StructData{
+		"id":    types.String("9007199254740993"),
+		"delta": types.String("300"),
+		"ratio": types.String("0.1"),
+	}), &out)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+
+	// Numbers are only decoded leniently.
+	numbers := types.NewStruct("S", types.StructData{
+		"id":    types.Number(7),
+		"delta": types.Number(-3),
+		"ratio": types.Number(0.5),
+	})
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(numbers, &out))
+	coercions, err := UnmarshalLenient(numbers, &out)
+	assert.NoError(err)
+	assert.Len(coercions, 3)
+	assert.Equal(S{7, -3, 0.5}, out)
+
+	type Bad struct {
+		Name string `noms:",string"`
+	}
+	_, err = Marshal(Bad{})
+	assert.IsType(&InvalidTagError{}, err)
+}
+
+func TestEncodeNoName(t *testing.T) {
+	assert := assert.New(t)
+
+	type Point struct {
+		_ struct{} `noms:",noname"`
+		X int
+		Y int
+	}
+	type Shape struct {
+		Center Point
+		Label  string
+	}
+	v, err := Marshal(Shape{Point{X: 1, Y: 2}, "dot"})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Shape", types.StructData{
+		"center": types.NewStruct("", types.StructData{"x": types.Number(1), "y": types.Number(2)}),
+		"label":  types.String("dot"),
+	}).Equals(v))
+
+	var s Shape
+	assert.NoError(Unmarshal(v, &s))
+	assert.Equal(Shape{Point{X: 1, Y: 2}, "dot"}, s)
+
+	// StructName still names the outermost struct.
+	v, err = MarshalOpt(nil, Point{X: 1}, Opt{StructName: "Point"})
+	assert.NoError(err)
+	assert.Equal("Point", v.(types.Struct).Name())
+
+	type Bad struct {
+		X int `noms:",noname"`
+	}
+	_, err = Marshal(Bad{})
+	assert.IsType(&InvalidTagError{}, err)
+}
+
+func TestEncodeTaggedName(t *testing.T) {
+	assert := assert.New(t)
+
+	type Person struct {
+		_    struct{} `noms:",name=Person_v2"`
+		Name string
+	}
+	type Team struct {
+		Lead Person
+	}
+	v, err := Marshal(Team{Person{Name: "Ann"}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Team", types.StructData{
+		"lead": types.NewStruct("Person_v2", types.StructData{"name": types.String("Ann")}),
+	}).Equals(v))
+
+	typ, err := MarshalType(Person{})
+	assert.NoError(err)
+	assert.Equal("Person_v2", typ.Desc.(types.StructDesc).Name)
+
+	var team Team
+	assert.NoError(Unmarshal(v, &team))
+	assert.Equal("Ann", team.Lead.Name)
+
+	v, err = MarshalOpt(nil, Person{Name: "Ann"}, Opt{StructName: "Person"})
+	assert.NoError(err)
+	assert.Equal("Person", v.(types.Struct).Name())
+
+	type BadName struct {
+		_ struct{} `noms:",name=not-a-name"`
+	}
+	_, err = Marshal(BadName{})
+	assert.IsType(&InvalidTagError{}, err)
+
+	type NotBlank struct {
+		X int `noms:",name=X"`
+	}
+	_, err = Marshal(NotBlank{})
+	assert.IsType(&InvalidTagError{}, err)
+}
+
+// failingMarshaler fails to marshal when it's true.
+type failingMarshaler bool
+
+func (f failingMarshaler) MarshalNoms() (types.Value, error) {
+	if f {
+		return nil, errors.New("failed")
+	}
+	return types.Bool(false), nil
+}
+
+func TestEncodeLargeSlice(t *testing.T) {
+	assert := assert.New(t)
+
+	n := parallelListLen*2 + 3
+	in := make([]int, n)
+	values := make([]types.Value, n)
+	for i := range in {
+		in[i] = i
+		values[i] = types.Number(i)
+	}
+	v, err := Marshal(in)
+	assert.NoError(err)
+	assert.True(types.NewList(values...).Equals(v))
+
+	var out []int
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(in, out)
+
+	// Errors from any element are returned.
+	fs := make([]failingMarshaler, n)
+	fs[n-1] = true
+	_, err = Marshal(fs)
+	assert.EqualError(err, "failed")
+}
+
+func TestEncodePointer(t *testing.T) {
+	assert := assert.New(t)
+
+	type Inner struct {
+		B bool
+	}
+	type S struct {
+		N *int
+		S *string
+		I *Inner
+	}
+	n, s := 42, "hi"
+	v, err := Marshal(S{&n, &s, &Inner{true}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"n": types.Number(42),
+		"s": types.String("hi"),
+		"i": types.NewStruct("Inner", types.StructData{"b": types.Bool(true)}),
+	}).Equals(v))
+
+	// Nil pointer fields are left out.
+	v, err = Marshal(S{N: &n})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{"n": types.Number(42)}).Equals(v))
+
+	v, err = Marshal(&n)
+	assert.NoError(err)
+	assert.True(types.Number(42).Equals(v))
+
+	v, err = Marshal([]*int{&n, &n})
+	assert.NoError(err)
+	assert.True(types.NewList(types.Number(42), types.Number(42)).Equals(v))
+
+	assertEncodeErrorMessage(t, []*int{&n, nil}, "Nil pointers are only supported as struct fields, type: *int")
+}
+
+func TestEncodeRecursivePointer(t *testing.T) {
+	assert := assert.New(t)
+
+	type Node struct {
+		Value int
+		Next  *Node
+	}
+	v, err := Marshal(Node{1, &Node{2, nil}})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Node", types.StructData{
+		"value": types.Number(1),
+		"next":  types.NewStruct("Node", types.StructData{"value": types.Number(2)}),
+	}).Equals(v))
+
+	var n Node
+	assert.NoError(Unmarshal(v, &n))
+	assert.Equal(Node{1, &Node{2, nil}}, n)
+}
+
+type cycleNode struct {
+	Name string
+	Next *cycleNode
+}
+
+func TestEncodeCycle(t *testing.T) {
+	assert := assert.New(t)
+
+	n := &cycleNode{Name: "a"}
+	n.Next = &cycleNode{"b", n}
+	_, err := Marshal(n)
+	assert.Error(err)
+	assert.IsType(&UnsupportedTypeError{}, err)
+	assert.Contains(err.Error(), "cycle")
+
+	l := []interface{}{1}
+	l[0] = l
+	_, err = Marshal(l)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err = Marshal(m)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	// Long chains without cycles are fine.
+	var long *cycleNode
+	for i := 0; i < 2*startDetectingCyclesAfter; i++ {
+		long = &cycleNode{"x", long}
+	}
+	_, err = Marshal(long)
+	assert.NoError(err)
+}
+
+func TestEncodeSharePointers(t *testing.T) {
+	assert := assert.New(t)
+
+	type Leaf struct {
+		Name string
+	}
+	type Pair struct {
+		A, B *Leaf
+	}
+	shared := &Leaf{"shared"}
+	p := Pair{shared, shared}
+	opt := Opt{SharePointers: true}
+
+	_, err := MarshalOpt(nil, p, opt)
+	assert.Error(err)
+
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+	v, err := MarshalOpt(vs, p, opt)
+	assert.NoError(err)
+	s := v.(types.Struct)
+	assert.True(s.Get("a").Equals(s.Get("b")))
+	r := s.Get("a").(types.Ref)
+	assert.True(types.NewStruct("Leaf", types.StructData{"name": types.String("shared")}).Equals(r.TargetValue(vs)))
+
+	typ, err := MarshalTypeOpt(p, opt)
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ), typ.Describe())
+
+	// Without SharePointers, the value is encoded once for each pointer.
+	v, err = Marshal(p)
+	assert.NoError(err)
+	assert.IsType(types.Struct{}, v.(types.Struct).Get("a"))
+
+	var out Pair
+	assert.Error(Unmarshal(s, &out))
+	assert.NoError(UnmarshalVR(vs, s, &out))
+	assert.Equal("shared", out.A.Name)
+	assert.True(out.A == out.B)
+}
+
+func TestEncodeNomsValuePassThrough(t *testing.T) {
+	assert := assert.New(t)
+
+	list := types.NewList(types.Number(1), types.String("a"))
+	st := types.NewStruct("Inner", types.StructData{"x": types.Bool(true)})
+	type Nested struct {
+		Any interface{}
+	}
+	type S struct {
+		Any    interface{}
+		Slice  []interface{}
+		Map    map[string]interface{}
+		Nested Nested
+	}
+	v, err := Marshal(S{
+		Any:    list,
+		Slice:  []interface{}{st, types.Number(2), 3, []interface{}{types.NumberType}},
+		Map:    map[string]interface{}{"set": types.NewSet(types.String("x")), "n": 4},
+		Nested: Nested{types.NewBlob(bytes.NewBufferString("b"))},
+	})
+	assert.NoError(err)
+	assert.True(types.NewStruct("S", types.StructData{
+		"any":   list,
+		"slice": types.NewList(st, types.Number(2), types.Number(3), types.NewList(types.NumberType)),
+		"map": types.NewMap(
+			types.String("set"), types.NewSet(types.String("x")),
+			types.String("n"), types.Number(4),
+		),
+		"nested": types.NewStruct("Nested", types.StructData{"any": types.NewBlob(bytes.NewBufferString("b"))}),
+	}).Equals(v), types.EncodedValue(v))
+
+	// The type of the result is that of the values passed through.
+	typ := types.TypeOf(v).Desc.(types.StructDesc)
+	any, _ := typ.Field("any")
+	assert.True(types.TypeOf(list).Equals(any))
+
+	assertEncodeErrorMessage(t, []interface{}{nil}, "Nil interfaces can't be encoded, type: interface {}")
+}
+
+type benchItem struct {
+	Name  string
+	Count int
+	Tags  []string
+	Attrs map[string]float64
+	Next  *benchItem
+}
+
+func newBenchItem() benchItem {
+	return benchItem{"item", 3, []string{"a", "b"}, map[string]float64{"x": 1, "y": 2}, &benchItem{Name: "next"}}
+}
+
+func TestMarshalConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	// Types that are first seen by many goroutines at once, with each option,
+	// are marshaled and unmarshaled consistently.
+	type Fresh struct {
+		UserName string
+		Items    []benchItem
+	}
+	in := Fresh{"a", []benchItem{newBenchItem()}}
+	opts := []Opt{{}, {FieldNaming: SnakeCase}, {StructName: "Renamed"}}
+	expected := make([]types.Value, len(opts))
+	done := make(chan bool)
+	for g := 0; g < 30; g++ {
+		go func(g int) {
+			defer func() { done <- true }()
+			opt := opts[g%len(opts)]
+			v := MustMarshalOpt(nil, in, opt)
+			var out Fresh
+			assert.NoError(UnmarshalOpt(v, &out, opt))
+			assert.Equal(in, out)
+		}(g)
+	}
+	for g := 0; g < 30; g++ {
+		<-done
+	}
+	for i, opt := range opts {
+		expected[i] = MustMarshalOpt(nil, in, opt)
+		assert.True(expected[i].Equals(MustMarshalOpt(nil, in, opt)))
+	}
+	assert.Equal("Renamed", types.TypeOf(expected[2]).Desc.(types.StructDesc).Name)
+	assert.Equal("Fresh", types.TypeOf(expected[0]).Desc.(types.StructDesc).Name)
+}
+
+func TestMarshalStructNameNotCached(t *testing.T) {
+	assert := assert.New(t)
+
+	type Named struct {
+		X int
+	}
+	type Extends struct {
+		Orig types.Struct `noms:",original"`
+		X    int          `noms:",omitempty"`
+	}
+	cached := func() int {
+		m, _ := encoderCache.m.Load().(map[encoderKey]encoderFunc)
+		return len(m)
+	}
+	MustMarshal(&Named{})
+	n := cached()
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("Name%d", i)
+		v := MustMarshalOpt(nil, &Named{i}, Opt{StructName: name})
+		assert.True(types.NewStruct(name, types.StructData{"x": types.Number(i)}).Equals(v))
+	}
+	assert.Equal(n, cached())
+
+	// The name isn't applied to values other than structs, or to structs that
+	// extend an original, which keep its name.
+	assert.True(types.Number(1).Equals(MustMarshalOpt(nil, 1, Opt{StructName: "N"})))
+	orig := types.NewStruct("Orig", types.StructData{"y": types.Bool(true)})
+	v := MustMarshalOpt(nil, Extends{orig, 1}, Opt{StructName: "N"})
+	assert.True(orig.Set("x", types.Number(1)).Equals(v))
+	v = MustMarshalOpt(nil, Extends{X: 1}, Opt{StructName: "N"})
+	assert.True(types.NewStruct("N", types.StructData{"x": types.Number(1)}).Equals(v))
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := newBenchItem()
+	for i := 0; i < b.N; i++ {
+		MustMarshal(v)
+	}
+}
+
+func BenchmarkMarshalParallel(b *testing.B) {
+	v := newBenchItem()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			MustMarshal(v)
+		}
+	})
+}
+
+func BenchmarkMarshalOptParallel(b *testing.B) {
+	v := newBenchItem()
+	opt := Opt{StructName: "Item", FieldNaming: SnakeCase}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			MustMarshalOpt(nil, v, opt)
+		}
+	})
+}
@@ -16,12 +16,28 @@ import (
 
 // MarshalType computes a Noms type from a Go type
 //
-// The rules for MarshalType is the same as for Marshal, except for omitempty
-// is ignored since that cannot be determined statically.
+// The rules for MarshalType is the same as for Marshal. Fields that may be
+// missing from the Noms struct - pointers, and fields tagged with "omitempty",
+// "omitzero", "optional" or "default=" - are optional fields of the struct
+// type, so the type matches values both with and without them.
 //
 // If a Go struct contains a noms tag with original the field is skipped since
 // the Noms type depends on the original Noms value which is not available.
+//
+// The type of an interface, other than one that Noms values implement,
+// depends on the values it holds, so MarshalType can't compute it; a
+// TypeRegistry's MarshalType can.
 func MarshalType(v interface{}) (nt *types.Type, err error) {
+	return MarshalTypeOpt(v, Opt{})
+}
+
+// MarshalTypeOpt is like MarshalType, but computes the type of the value that
+// MarshalOpt would produce with |opt|.
+func MarshalTypeOpt(v interface{}, opt Opt) (nt *types.Type, err error) {
+	return marshalType(v, opt, nil)
+}
+
+func marshalType(v interface{}, opt Opt, registry *TypeRegistry) (nt *types.Type, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			switch r := r.(type) {
@@ -34,15 +50,27 @@ func MarshalType(v interface{}) (nt *types.Type, err error) {
 			}
 		}
 	}()
-	nt = MustMarshalType(v)
+	nt = mustMarshalType(v, opt, registry)
 	return
 }
 
 // MustMarshalType computes a Noms type from a Go type or panics if there is an
 // error.
 func MustMarshalType(v interface{}) (nt *types.Type) {
+	return MustMarshalTypeOpt(v, Opt{})
+}
+
+// MustMarshalTypeOpt computes a Noms type from a Go type, as MarshalTypeOpt
+// does, or panics if there is an error.
+func MustMarshalTypeOpt(v interface{}, opt Opt) (nt *types.Type) {
+	return mustMarshalType(v, opt, nil)
+}
+
+// mustMarshalType computes the type of |v|, in which interfaces hold the
+// types registered with |r|, if it isn't nil.
+func mustMarshalType(v interface{}, opt Opt, r *TypeRegistry) (nt *types.Type) {
 	rv := reflect.ValueOf(v)
-	nt = encodeType(rv.Type(), map[string]reflect.Type{}, nomsTags{})
+	nt = encodeType(rv.Type(), map[string]reflect.Type{}, r, nomsTags{}, opt)
 
 	if nt == nil {
 		panic(&UnsupportedTypeError{Type: rv.Type()})
@@ -63,7 +91,10 @@ type TypeMarshaler interface {
 var typeOfTypesType = reflect.TypeOf((*types.Type)(nil))
 var typeMarshalerInterface = reflect.TypeOf((*TypeMarshaler)(nil)).Elem()
 
-func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTags) *types.Type {
+func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, r *TypeRegistry, tags nomsTags, opt Opt) *types.Type {
+	if ce, ok := getCustomEncoder(t); ok {
+		return ce.nt
+	}
 	if t.Implements(typeMarshalerInterface) {
 		v := reflect.Zero(t)
 		typ, err := v.Interface().(TypeMarshaler).MarshalNomsType()
@@ -76,18 +107,41 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 		return typ
 	}
 
-	if t.Implements(marshalerInterface) {
+	if t.Implements(marshalerInterface) || t.Implements(marshalerVRWInterface) {
 		// There is no way to determine the noms type now. For Marshal it can be
 		// different each time MarshalNoms is called and is handled further up the
 		// stack.
-		err := fmt.Errorf("Cannot marshal type which implements %s, perhaps implement %s for %s", marshalerInterface, typeMarshalerInterface, t)
-		panic(&marshalNomsError{err})
+		iface := marshalerInterface
+		if !t.Implements(iface) {
+			iface = marshalerVRWInterface
+		}
+		panic(&UnsupportedTypeError{t, fmt.Sprintf("Cannot marshal the type of a %s, perhaps implement %s", iface, typeMarshalerInterface)})
+	}
+
+	if t == timeType {
+		if tags.unixtime {
+			return types.NumberType
+		}
+		return dateTimeType
+	}
+	switch t {
+	case bigIntType:
+		return bigIntNomsType
+	case bigFloatType:
+		return bigFloatNomsType
+	}
+	if isTextMarshaler(t, tags) || tags.str {
+		return types.StringType
 	}
 
 	if t.Implements(nomsValueInterface) {
 		if t == typeOfTypesType {
 			return types.TypeType
 		}
+		// An interface such as types.Value can hold any Noms value.
+		if t.Kind() == reflect.Interface {
+			return types.ValueType
+		}
 
 		// Use Name because List and Blob are convertible to each other on Go.
 		switch t.Name() {
@@ -101,8 +155,7 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 			return types.StringType
 		}
 
-		err := fmt.Errorf("Cannot marshal type %s, it requires type parameters", t)
-		panic(&marshalNomsError{err})
+		panic(&UnsupportedTypeError{t, "Cannot marshal the type of a Noms value that requires type parameters"})
 	}
 
 	switch t.Kind() {
@@ -113,9 +166,21 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 	case reflect.String:
 		return types.StringType
 	case reflect.Struct:
-		return structEncodeType(t, seenStructs)
+		return structEncodeType(t, seenStructs, r, opt)
+	case reflect.Ptr:
+		// Pointers are encoded as what they point to, or as a Ref to it with
+		// SharePointers. Pointer fields are optional, which is handled by
+		// typeFields.
+		elemType := encodeType(t.Elem(), seenStructs, r, tags, opt)
+		if elemType == nil || !opt.SharePointers {
+			return elemType
+		}
+		return types.MakeRefType(elemType)
 	case reflect.Array, reflect.Slice:
-		elemType := encodeType(t.Elem(), seenStructs, nomsTags{})
+		if !tags.set && shouldEncodeAsBlob(t, tags) {
+			return types.BlobType
+		}
+		elemType := encodeType(t.Elem(), seenStructs, r, nomsTags{}, opt.nested())
 		if elemType == nil {
 			break
 		}
@@ -124,7 +189,7 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 		}
 		return types.MakeListType(elemType)
 	case reflect.Map:
-		keyType := encodeType(t.Key(), seenStructs, nomsTags{})
+		keyType := encodeType(t.Key(), seenStructs, r, nomsTags{}, opt.nested())
 		if keyType == nil {
 			break
 		}
@@ -133,10 +198,12 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 			return types.MakeSetType(keyType)
 		}
 
-		valueType := encodeType(t.Elem(), seenStructs, nomsTags{})
+		valueType := encodeType(t.Elem(), seenStructs, r, nomsTags{}, opt.nested())
 		if valueType != nil {
 			return types.MakeMapType(keyType, valueType)
 		}
+	case reflect.Interface:
+		return r.unionType(t, seenStructs, opt)
 	}
 
 	// This will be reported as an error at a different layer.
@@ -148,15 +215,29 @@ func encodeType(t reflect.Type, seenStructs map[string]reflect.Type, tags nomsTa
 // the type but we also need to look at the value. In these cases this returns
 // nil and we have to wait until we have a value to be able to determine the
 // type.
-func structEncodeType(t reflect.Type, seenStructs map[string]reflect.Type) *types.Type {
-	name := t.Name()
-	if name != "" {
-		if _, ok := seenStructs[name]; ok {
+func structEncodeType(t reflect.Type, seenStructs map[string]reflect.Type, r *TypeRegistry, opt Opt) *types.Type {
+	// A renamed struct isn't a cycle target, since nested values of its Go
+	// type keep their own name. The others are cycle targets by their Noms
+	// name while their fields are computed, so that only the structs that
+	// enclose a struct, and not its siblings, make it a cycle.
+	if t.Name() != "" && opt.StructName == "" {
+		name := opt.structName(t)
+		key := name
+		if name == "" {
+			// A struct without a name can't be a cycle target, but is still
+			// tracked by its Go type, to catch it referring to itself.
+			key = "noname " + t.PkgPath() + "." + t.Name()
+		}
+		if _, ok := seenStructs[key]; ok {
+			if name == "" {
+				panic(&UnsupportedTypeError{t, "A struct without a name can't refer to itself"})
+			}
 			return types.MakeCycleType(name)
 		}
-		seenStructs[name] = t
+		seenStructs[key] = t
+		defer delete(seenStructs, key)
 	}
 
-	_, structType, _, _ := typeFields(t, seenStructs, true)
+	_, structType, _, _ := typeFields(t, seenStructs, r, true, opt)
 	return structType
 }
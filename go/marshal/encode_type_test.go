@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
@@ -107,15 +108,95 @@ func assertMarshalTypeErrorMessage(t *testing.T, v interface{}, expectedMessage
 func TestMarshalTypeInvalidTypes(t *testing.T) {
 	assertMarshalTypeErrorMessage(t, make(chan int), "Type is not supported, type: chan int")
 	l := types.NewList()
-	assertMarshalTypeErrorMessage(t, l, "Cannot marshal type types.List, it requires type parameters")
+	assertMarshalTypeErrorMessage(t, l, "Cannot marshal the type of a Noms value that requires type parameters, type: types.List")
 }
 
 func TestMarshalTypeEmbeddedStruct(t *testing.T) {
-	type EmbeddedStruct struct{}
+	assert := assert.New(t)
+
+	type EmbeddedStruct struct {
+		X int
+		Y string `noms:",omitempty"`
+	}
 	type TestStruct struct {
 		EmbeddedStruct
+		Z bool
+	}
+	typ, err := MarshalType(TestStruct{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("TestStruct",
+		types.StructField{Name: "x", Type: types.NumberType},
+		types.StructField{Name: "y", Type: types.StringType, Optional: true},
+		types.StructField{Name: "z", Type: types.BoolType},
+	).Equals(typ))
+}
+
+func TestMarshalTypeSiblingStructs(t *testing.T) {
+	assert := assert.New(t)
+
+	// Circle is the Noms struct Disc, and Ring is the Noms struct Circle.
+	type Circle struct {
+		_      struct{} `noms:",name=Disc"`
+		Radius float64
+	}
+	type Ring struct {
+		_     struct{} `noms:",name=Circle"`
+		Inner float64
 	}
-	assertMarshalTypeErrorMessage(t, TestStruct{EmbeddedStruct{}}, "Embedded structs are not supported, type: marshal.TestStruct")
+	type Pair struct {
+		A, B Circle
+		C    Ring
+	}
+	discType := types.MakeStructType("Disc", types.StructField{Name: "radius", Type: types.NumberType})
+	pairType := types.MakeStructType("Pair",
+		types.StructField{Name: "a", Type: discType},
+		types.StructField{Name: "b", Type: discType},
+		types.StructField{Name: "c", Type: types.MakeStructType("Circle", types.StructField{Name: "inner", Type: types.NumberType})},
+	)
+
+	// Neither sibling of the same type, nor a later struct whose Noms name is
+	// Circle's Go name, is a cycle.
+	typ, err := MarshalType(Pair{})
+	assert.NoError(err)
+	assert.True(pairType.Equals(typ), typ.Describe())
+	v, err := Marshal(Pair{C: Ring{Inner: 1}})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ))
+}
+
+func TestMarshalTypeOptionalFields(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		A int
+		B string `noms:",omitempty"`
+		C []int  `noms:",optional"`
+		D *int
+		E float64 `noms:",default=1"`
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("S",
+		types.StructField{Name: "a", Type: types.NumberType},
+		types.StructField{Name: "b", Type: types.StringType, Optional: true},
+		types.StructField{Name: "c", Type: types.MakeListType(types.NumberType), Optional: true},
+		types.StructField{Name: "d", Type: types.NumberType, Optional: true},
+		types.StructField{Name: "e", Type: types.NumberType, Optional: true},
+	).Equals(typ), typ.Describe())
+
+	// Values with and without the optional fields match the type.
+	one := 1
+	v := MustMarshal([]S{{A: 1}, {A: 2, B: "b", C: []int{3}, D: &one}})
+	assert.True(types.IsValueSubtypeOf(v, types.MakeListType(typ)))
+	v = types.NewStruct("S", types.StructData{"a": types.Number(1)})
+	assert.True(types.IsValueSubtypeOf(v, typ))
+
+	// Fields tagged "optional" are always encoded, but may be missing when
+	// decoding.
+	assert.True(MustMarshal(S{}).(types.Struct).Get("c").Equals(types.NewList()))
+	var s S
+	assert.NoError(Unmarshal(v, &s))
+	assert.Equal(S{A: 1, E: 1}, s)
 }
 
 func TestMarshalTypeEncodeNonExportedField(t *testing.T) {
@@ -127,10 +208,10 @@ func TestMarshalTypeEncodeNonExportedField(t *testing.T) {
 
 func TestMarshalTypeEncodeNomsTypeWithTypeParameters(t *testing.T) {
 
-	assertMarshalTypeErrorMessage(t, types.NewList(), "Cannot marshal type types.List, it requires type parameters")
-	assertMarshalTypeErrorMessage(t, types.NewSet(), "Cannot marshal type types.Set, it requires type parameters")
-	assertMarshalTypeErrorMessage(t, types.NewMap(), "Cannot marshal type types.Map, it requires type parameters")
-	assertMarshalTypeErrorMessage(t, types.NewRef(types.NewSet()), "Cannot marshal type types.Ref, it requires type parameters")
+	assertMarshalTypeErrorMessage(t, types.NewList(), "Cannot marshal the type of a Noms value that requires type parameters, type: types.List")
+	assertMarshalTypeErrorMessage(t, types.NewSet(), "Cannot marshal the type of a Noms value that requires type parameters, type: types.Set")
+	assertMarshalTypeErrorMessage(t, types.NewMap(), "Cannot marshal the type of a Noms value that requires type parameters, type: types.Map")
+	assertMarshalTypeErrorMessage(t, types.NewRef(types.NewSet()), "Cannot marshal the type of a Noms value that requires type parameters, type: types.Ref")
 }
 
 func TestMarshalTypeEncodeTaggingSkip(t *testing.T) {
@@ -259,6 +340,29 @@ func TestMarshalTypeRecursive(t *testing.T) {
 	assert.True(typ2.Equals(typ))
 }
 
+func TestMarshalTypeSiblings(t *testing.T) {
+	assert := assert.New(t)
+
+	// Structs of the same type side by side aren't cycles, and cycles are
+	// named after the Noms struct, not the Go one.
+	type point struct {
+		X int
+	}
+	type node struct {
+		From, To point
+		Next     *node
+	}
+	typ, err := MarshalType(node{})
+	assert.NoError(err)
+
+	pointType := types.MakeStructType("Point", types.StructField{Name: "x", Type: types.NumberType})
+	assert.True(types.MakeStructType("Node",
+		types.StructField{Name: "from", Type: pointType},
+		types.StructField{Name: "next", Type: types.MakeCycleType("Node"), Optional: true},
+		types.StructField{Name: "to", Type: pointType},
+	).Equals(typ))
+}
+
 func TestMarshalTypeMap(t *testing.T) {
 	assert := assert.New(t)
 
@@ -436,7 +540,7 @@ func TestTypeMarshalerPrimitiveStructTypeNoMarshalNomsType(t *testing.T) {
 	var u primitiveStructType
 	_, err := MarshalType(u)
 	assert.Error(err)
-	assert.Equal("Cannot marshal type which implements marshal.Marshaler, perhaps implement marshal.TypeMarshaler for marshal.primitiveStructType", err.Error())
+	assert.Equal("Cannot marshal the type of a marshal.Marshaler, perhaps implement marshal.TypeMarshaler, type: marshal.primitiveStructType", err.Error())
 }
 
 func (u builtinType) MarshalNomsType() (*types.Type, error) {
@@ -489,3 +593,120 @@ func TestTypeMarshalerErrors(t *testing.T) {
 	var m3 panicsMarshaler
 	assert.Panics(func() { MarshalType(m3) })
 }
+
+func TestMarshalTypeTime(t *testing.T) {
+	assert := assert.New(t)
+	type S struct {
+		Created  time.Time
+		Modified time.Time `noms:",unixtime"`
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructTypeFromFields("S", types.FieldMap{
+		"created":  types.MakeStructTypeFromFields("DateTime", types.FieldMap{"secSinceEpoch": types.NumberType}),
+		"modified": types.NumberType,
+	}).Equals(typ))
+
+	v, err := Marshal(S{time.Now(), time.Now()})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ))
+}
+
+type noNameNode struct {
+	_        struct{} `noms:",noname"`
+	Children []noNameNode
+}
+
+func TestMarshalTypeNoName(t *testing.T) {
+	assert := assert.New(t)
+	type Point struct {
+		_ struct{} `noms:",noname"`
+		X int
+	}
+	type Shape struct {
+		A Point
+		B Point
+	}
+	typ, err := MarshalType(Shape{})
+	assert.NoError(err)
+	point := types.MakeStructTypeFromFields("", types.FieldMap{"x": types.NumberType})
+	assert.True(types.MakeStructTypeFromFields("Shape", types.FieldMap{
+		"a": point,
+		"b": point,
+	}).Equals(typ))
+
+	_, err = MarshalType(noNameNode{})
+	assert.IsType(&UnsupportedTypeError{}, err)
+	// Values of such a type can still be marshaled.
+	v, err := Marshal(noNameNode{Children: []noNameNode{{}}})
+	assert.NoError(err)
+	assert.Equal("", v.(types.Struct).Name())
+}
+
+func TestMarshalTypePointer(t *testing.T) {
+	assert := assert.New(t)
+
+	type Node struct {
+		Value int
+		Next  *Node
+		Name  *string
+	}
+	var n Node
+	typ, err := MarshalType(n)
+	assert.NoError(err)
+	assert.True(types.MakeStructType("Node",
+		types.StructField{Name: "name", Type: types.StringType, Optional: true},
+		types.StructField{Name: "next", Type: types.MakeCycleType("Node"), Optional: true},
+		types.StructField{Name: "value", Type: types.NumberType},
+	).Equals(typ))
+
+	v, err := Marshal(Node{Value: 1, Next: &Node{Value: 2}})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ))
+}
+
+func TestMarshalTypeRef(t *testing.T) {
+	assert := assert.New(t)
+
+	type Doc struct {
+		Title string
+	}
+	type S struct {
+		Doc  Doc  `noms:",ref"`
+		Prev *Doc `noms:",ref"`
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	docType := types.MakeStructType("Doc", types.StructField{Name: "title", Type: types.StringType})
+	assert.True(types.MakeStructType("S",
+		types.StructField{Name: "doc", Type: types.MakeRefType(docType)},
+		types.StructField{Name: "prev", Type: types.MakeRefType(docType), Optional: true},
+	).Equals(typ))
+}
+
+func TestMarshalTypeNomsValueInterface(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		V    types.Value
+		L    []types.Value
+		M    map[string]types.Value
+		Coll types.Collection
+	}
+	typ, err := MarshalType(S{})
+	assert.NoError(err)
+	assert.True(types.MakeStructType("S",
+		types.StructField{Name: "coll", Type: types.ValueType},
+		types.StructField{Name: "l", Type: types.MakeListType(types.ValueType)},
+		types.StructField{Name: "m", Type: types.MakeMapType(types.StringType, types.ValueType)},
+		types.StructField{Name: "v", Type: types.ValueType},
+	).Equals(typ), typ.Describe())
+
+	v := MustMarshal(S{
+		types.Number(1),
+		[]types.Value{types.String("a"), types.NewList(types.Bool(true))},
+		map[string]types.Value{"s": types.NewStruct("Inner", nil)},
+		types.NewSet(types.Number(2)),
+	})
+	assert.True(types.IsValueSubtypeOf(v, typ))
+}
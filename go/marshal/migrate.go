@@ -0,0 +1,87 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// MigrationFunc upgrades a Noms struct from one version of a Go type's schema
+// to the next. See RegisterMigration.
+type MigrationFunc func(s types.Struct) (types.Struct, error)
+
+type migrationKey struct {
+	t    reflect.Type
+	from int
+}
+
+var migrations = struct {
+	sync.RWMutex
+	m map[migrationKey]MigrationFunc
+}{m: map[migrationKey]MigrationFunc{}}
+
+// RegisterMigration registers |fn| to upgrade Noms structs encoded at version
+// |from| of the Go struct type of |v| to version |from|+1.
+//
+// A Go struct opts into versioning by tagging one integer field with
+// `noms:",version=N"`, where N is the current version of its schema. Marshal
+// always writes N into that field. When Unmarshal finds a lower version it
+// applies the registered migrations one version at a time, starting with the
+// stored version, before decoding the result. Noms structs written before the
+// type was versioned lack the field and are treated as version 0.
+//
+// Registering a second migration for the same type and version replaces the
+// first.
+func RegisterMigration(v interface{}, from int, fn MigrationFunc) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(&UnsupportedTypeError{t, "Migrations can only be registered for structs"})
+	}
+
+	migrations.Lock()
+	defer migrations.Unlock()
+	migrations.m[migrationKey{t, from}] = fn
+}
+
+func getMigration(t reflect.Type, from int) MigrationFunc {
+	migrations.RLock()
+	defer migrations.RUnlock()
+	return migrations.m[migrationKey{t, from}]
+}
+
+// migrate upgrades |s|, which holds a Go value of type |t| whose version is
+// stored in field |name|, to |version|.
+func migrate(t reflect.Type, s types.Struct, name string, version int) types.Struct {
+	stored := 0
+	if v, ok := s.MaybeGet(name); ok {
+		n, ok := v.(types.Number)
+		if !ok || n < 0 || types.Number(int(n)) != n {
+			panic(&UnmarshalTypeMismatchError{s, t, ", field \"" + name + "\" must hold a version number", ""})
+		}
+		stored = int(n)
+	}
+	if stored > version {
+		panic(&UnmarshalTypeMismatchError{s, t, fmt.Sprintf(", version %d is newer than %d", stored, version), ""})
+	}
+
+	for ; stored < version; stored++ {
+		fn := getMigration(t, stored)
+		if fn == nil {
+			panic(&UnmarshalTypeMismatchError{s, t, fmt.Sprintf(", no migration registered from version %d", stored), ""})
+		}
+		var err error
+		if s, err = fn(s); err != nil {
+			panic(&unmarshalNomsError{err})
+		}
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type Person struct {
+	Version  int `noms:",version=2"`
+	FullName string
+	Email    string
+}
+
+func init() {
+	// Version 0 split the name in two.
+	RegisterMigration(Person{}, 0, func(s types.Struct) (types.Struct, error) {
+		first, last := s.Get("first").(types.String), s.Get("last").(types.String)
+		return types.NewStruct("Person", types.StructData{"fullName": first + " " + last}), nil
+	})
+	// Version 1 had no email.
+	RegisterMigration(&Person{}, 1, func(s types.Struct) (types.Struct, error) {
+		return s.Set("email", types.String("")), nil
+	})
+}
+
+func TestMarshalVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := Marshal(Person{FullName: "Ada Lovelace"})
+	assert.NoError(err)
+	assert.True(types.NewStruct("Person", types.StructData{
+		"version":  types.Number(2),
+		"fullName": types.String("Ada Lovelace"),
+		"email":    types.String(""),
+	}).Equals(v))
+
+	// The Go value of the field doesn't matter.
+	v2, err := Marshal(Person{Version: 1, FullName: "Ada Lovelace"})
+	assert.NoError(err)
+	assert.True(v.Equals(v2))
+
+	typ, err := MarshalType(Person{})
+	assert.NoError(err)
+	assert.True(types.TypeOf(v).Equals(typ))
+}
+
+func TestUnmarshalMigrates(t *testing.T) {
+	assert := assert.New(t)
+
+	expected := Person{Version: 2, FullName: "Ada Lovelace"}
+
+	var p Person
+	v0 := types.NewStruct("Person", types.StructData{
+		"first": types.String("Ada"),
+		"last":  types.String("Lovelace"),
+	})
+	assert.NoError(Unmarshal(v0, &p))
+	assert.Equal(expected, p)
+
+	p = Person{}
+	v1 := types.NewStruct("Person", types.StructData{
+		"version":  types.Number(1),
+		"fullName": types.String("Ada Lovelace"),
+	})
+	assert.NoError(Unmarshal(v1, &p))
+	assert.Equal(expected, p)
+
+	p = Person{}
+	assert.NoError(Unmarshal(MustMarshal(expected), &p))
+	assert.Equal(expected, p)
+}
+
+func TestUnmarshalMigrationErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var p Person
+	newer := types.NewStruct("Person", types.StructData{
+		"version":  types.Number(3),
+		"fullName": types.String("Ada Lovelace"),
+		"email":    types.String(""),
+	})
+	err := Unmarshal(newer, &p)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Contains(err.Error(), "version 3 is newer than 2")
+
+	notANumber := newer.Set("version", types.String("2"))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(notANumber, &p))
+
+	type Pet struct {
+		V    uint8 `noms:"v,version=1"`
+		Name string
+	}
+	var pet Pet
+	err = Unmarshal(types.NewStruct("Pet", types.StructData{"name": types.String("Rex")}), &pet)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Contains(err.Error(), "no migration registered from version 0")
+
+	boom := errors.New("boom")
+	RegisterMigration(Pet{}, 0, func(s types.Struct) (types.Struct, error) {
+		return types.Struct{}, boom
+	})
+	assert.Equal(boom, Unmarshal(types.NewStruct("Pet", types.StructData{"name": types.String("Rex")}), &pet))
+}
+
+func TestVersionTagErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	type ZeroVersion struct {
+		V int `noms:",version=0"`
+	}
+	_, err := Marshal(ZeroVersion{})
+	assert.IsType(&InvalidTagError{}, err)
+
+	type StringVersion struct {
+		V string `noms:",version=1"`
+	}
+	_, err = Marshal(StringVersion{})
+	assert.IsType(&InvalidTagError{}, err)
+	assert.IsType(&InvalidTagError{}, Unmarshal(types.NewStruct("StringVersion", nil), &StringVersion{}))
+
+	type TwoVersions struct {
+		V int `noms:",version=1"`
+		W int `noms:",version=2"`
+	}
+	_, err = Marshal(TwoVersions{})
+	assert.IsType(&InvalidTagError{}, err)
+}
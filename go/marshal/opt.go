@@ -0,0 +1,120 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Opt holds options for MarshalOpt, MarshalTypeOpt and UnmarshalOpt. The zero
+// Opt gives the behavior of Marshal, MarshalType and Unmarshal, except that
+// UnmarshalOpt doesn't fail on missing fields unless Strict is set.
+type Opt struct {
+	// StructName, if not empty, is the name of the Noms struct that a Go
+	// struct is marshaled to when it's the value being marshaled, or what that
+	// value points to, rather than the name of the Go struct or the one its
+	// tags give it. Structs nested within it keep their own names. It's
+	// ignored when unmarshaling, which doesn't check struct names.
+	StructName string
+
+	// FieldNaming is how the names of Noms struct fields are derived from the
+	// names of Go struct fields that don't give one in their tag, when
+	// marshaling and unmarshaling.
+	FieldNaming FieldNaming
+
+	// Strict makes it an error for a Noms struct to have a field that no
+	// field of the Go struct it's decoded into corresponds to, rather than
+	// dropping the field, or to lack a field that the Go struct requires, one
+	// not tagged "omitempty", "omitzero" or "default", or a pointer, rather
+	// than leaving the Go field at its zero value. Go structs with a field
+	// tagged "original", and types that implement Unmarshaler or
+	// UnmarshalerFrom, keep the whole Noms struct, so they aren't checked for
+	// unknown fields. It's ignored when marshaling.
+	Strict bool
+
+	// SharePointers makes each pointer encode as a types.Ref to the value it
+	// points to, which is written to the ValueReadWriter once however many
+	// pointers to it there are, so that shared Go values stay shared in Noms.
+	// It requires MarshalOpt to be given a ValueReadWriter. Without it, a
+	// value reachable through several pointers is encoded once for each.
+	// Unmarshaling follows such Refs into pointers whether it's set or not,
+	// given a ValueReader, as UnmarshalVR has.
+	SharePointers bool
+
+	// CaseInsensitive makes a Go struct field that no field of the Noms
+	// struct is named after take the value of one whose name matches it but
+	// for case, as encoding/json does, so that data written with other
+	// casing conventions, e.g. by the SDKs of other languages, still
+	// decodes. An exact match is preferred. It's ignored when marshaling.
+	CaseInsensitive bool
+}
+
+// nested returns the options for values nested in the one being marshaled.
+func (opt Opt) nested() Opt {
+	opt.StructName = ""
+	return opt
+}
+
+// structName returns the name of the Noms struct that values of the Go struct
+// |t| are marshaled to.
+func (opt Opt) structName(t reflect.Type) string {
+	if opt.StructName != "" {
+		return opt.StructName
+	}
+	if name, ok := taggedStructName(t); ok {
+		return name
+	}
+	return strings.Title(t.Name())
+}
+
+// FieldNaming is a policy for naming Noms struct fields after Go struct
+// fields.
+type FieldNaming int
+
+const (
+	// LowerCamelCase lower cases the first character of the Go name, so
+	// that MyURL becomes myURL. It's the default.
+	LowerCamelCase FieldNaming = iota
+	// SnakeCase lower cases the Go name and separates its words with
+	// underscores, so that MyURL becomes my_url.
+	SnakeCase
+	// Verbatim uses the Go name as it is, so that MyURL stays MyURL.
+	Verbatim
+
+	fieldNamings = iota
+)
+
+// fieldName returns the Noms name of the Go struct field |name|.
+func (n FieldNaming) fieldName(name string) string {
+	switch n {
+	case SnakeCase:
+		return toSnakeCase(name)
+	case Verbatim:
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// toSnakeCase splits |name| into words before each upper case character that
+// follows a lower case character or digit, and before the last upper case
+// character of a run that's followed by a lower case one, so that
+// HTTPServer2Addr becomes http_server2_addr. Existing underscores are kept.
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	words := make([]rune, 0, len(runes)+4)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && runes[i-1] != '_' {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextIsLower {
+				words = append(words, '_')
+			}
+		}
+		words = append(words, unicode.ToLower(r))
+	}
+	return string(words)
+}
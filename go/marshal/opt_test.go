@@ -0,0 +1,119 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	assert := assert.New(t)
+	for in, out := range map[string]string{
+		"A":               "a",
+		"Name":            "name",
+		"MyName":          "my_name",
+		"URL":             "url",
+		"MyURL":           "my_url",
+		"HTTPServer2Addr": "http_server2_addr",
+		"ID2":             "id2",
+		"Already_Snake":   "already_snake",
+	} {
+		assert.Equal(out, toSnakeCase(in), in)
+	}
+}
+
+type optInner struct {
+	PartID int
+}
+
+type optOuter struct {
+	UserName string
+	Tagged   bool `noms:"KeepMe"`
+	Inner    optInner
+	Next     *optOuter
+}
+
+func TestMarshalOpt(t *testing.T) {
+	assert := assert.New(t)
+
+	v := optOuter{"a", true, optInner{1}, &optOuter{UserName: "b"}}
+	inner := types.NewStruct("OptInner", types.StructData{"part_id": types.Number(1)})
+	next := types.NewStruct("OptOuter", types.StructData{
+		"user_name": types.String("b"),
+		"KeepMe":    types.Bool(false),
+		"inner":     types.NewStruct("OptInner", types.StructData{"part_id": types.Number(0)}),
+	})
+	expected := types.NewStruct("Account", types.StructData{
+		"user_name": types.String("a"),
+		"KeepMe":    types.Bool(true),
+		"inner":     inner,
+		"next":      next,
+	})
+
+	opt := Opt{StructName: "Account", FieldNaming: SnakeCase}
+	for _, in := range []interface{}{v, &v} {
+		nv, err := MarshalOpt(nil, in, opt)
+		assert.NoError(err)
+		assert.True(expected.Equals(nv), types.EncodedValue(nv))
+	}
+
+	var out optOuter
+	assert.NoError(UnmarshalOpt(expected, &out, opt))
+	assert.Equal(v, out)
+
+	// The default naming still applies to the plain functions.
+	nv := MustMarshal(optInner{2})
+	assert.True(types.NewStruct("OptInner", types.StructData{"partID": types.Number(2)}).Equals(nv))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(inner, &optInner{}))
+
+	nv = MustMarshalOpt(nil, optInner{2}, Opt{FieldNaming: Verbatim})
+	assert.True(types.NewStruct("OptInner", types.StructData{"PartID": types.Number(2)}).Equals(nv))
+	var oi optInner
+	assert.NoError(UnmarshalOpt(nv, &oi, Opt{FieldNaming: Verbatim}))
+	assert.Equal(optInner{2}, oi)
+
+	// StructName only applies to the outermost struct.
+	nv = MustMarshalOpt(nil, []optInner{{3}}, Opt{StructName: "Part"})
+	assert.True(types.NewList(types.NewStruct("OptInner", types.StructData{"partID": types.Number(3)})).Equals(nv))
+}
+
+func TestMarshalOptNameCollision(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		URL string
+		Url string
+	}
+	_, err := MarshalOpt(nil, S{}, Opt{FieldNaming: SnakeCase})
+	assert.IsType(&InvalidTagError{}, err)
+	assert.IsType(&InvalidTagError{}, UnmarshalOpt(types.NewStruct("S", nil), &S{}, Opt{FieldNaming: SnakeCase}))
+
+	_, err = Marshal(S{})
+	assert.NoError(err)
+}
+
+func TestMarshalTypeOpt(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		UserName string
+		Inner    optInner
+	}
+	opt := Opt{StructName: "Account", FieldNaming: SnakeCase}
+	typ, err := MarshalTypeOpt(S{}, opt)
+	assert.NoError(err)
+	assert.True(types.TypeOf(MustMarshalOpt(nil, S{"a", optInner{1}}, opt)).Equals(typ), typ.Describe())
+
+	// Renaming a recursive struct leaves the nested occurrences with their
+	// own name.
+	typ, err = MarshalTypeOpt(optOuter{}, Opt{StructName: "Account"})
+	assert.NoError(err)
+	assert.Equal("Account", typ.Desc.(types.StructDesc).Name)
+	next, _ := typ.Desc.(types.StructDesc).Field("next")
+	assert.Equal("OptOuter", next.Desc.(types.StructDesc).Name)
+}
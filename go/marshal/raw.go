@@ -0,0 +1,89 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Raw is a Noms value that Marshal and Unmarshal pass through as it is, like
+// json.RawMessage, e.g. for a gateway that routes values by a few fields and
+// forwards the rest. A Raw is encoded as a Ref to its value, which is written
+// on its own, so all a Raw holds is the value's hash. Unmarshaling onto a Raw
+// keeps the Ref without reading the value it refers to, and marshaling the Raw
+// writes the same Ref back, so the value is neither read, decoded nor encoded
+// again. It's only read, from whichever ValueReader is given, if Value is
+// called.
+//
+// Marshaling a Raw doesn't copy the value it refers to, so the value must
+// already be in the database the result is written to, e.g. because it was
+// read from there, or pulled there.
+type Raw struct {
+	ref types.Ref
+}
+
+var rawType = reflect.TypeOf(Raw{})
+
+// ErrEmptyRaw is returned when marshaling a Raw that doesn't hold a value.
+var ErrEmptyRaw = errors.New("Cannot marshal an empty Raw")
+
+// NewRaw writes |v| to |vw| and returns a Raw that refers to it.
+func NewRaw(vw types.ValueWriter, v types.Value) Raw {
+	return Raw{vw.WriteValue(v)}
+}
+
+// RawFromRef returns a Raw that refers to the value |r| refers to.
+func RawFromRef(r types.Ref) Raw {
+	return Raw{r}
+}
+
+// IsEmpty returns true if r doesn't hold a value.
+func (r Raw) IsEmpty() bool {
+	return r.ref.TargetHash().IsEmpty()
+}
+
+// Hash returns the hash of the value r holds.
+func (r Raw) Hash() hash.Hash {
+	return r.ref.TargetHash()
+}
+
+// Ref returns the Ref that r is encoded as.
+func (r Raw) Ref() types.Ref {
+	return r.ref
+}
+
+// Value reads the value r holds from |vr|, and decodes it. It returns nil if
+// |vr| doesn't have the value.
+func (r Raw) Value(vr types.ValueReader) types.Value {
+	return r.ref.TargetValue(vr)
+}
+
+// MarshalNoms implements Marshaler.
+func (r Raw) MarshalNoms() (types.Value, error) {
+	if r.IsEmpty() {
+		return nil, ErrEmptyRaw
+	}
+	return r.ref, nil
+}
+
+// MarshalNomsType implements TypeMarshaler. A Raw can refer to any value.
+func (r Raw) MarshalNomsType() (*types.Type, error) {
+	return types.MakeRefType(types.ValueType), nil
+}
+
+// UnmarshalNoms implements Unmarshaler.
+func (r *Raw) UnmarshalNoms(v types.Value) error {
+	ref, ok := v.(types.Ref)
+	if !ok {
+		return fmt.Errorf("A Raw is encoded as a Ref, not a %s", types.KindToString[v.Kind()])
+	}
+	r.ref = ref
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type envelope struct {
+	To      string
+	Payload Raw
+	Extra   Raw `noms:",omitempty"`
+}
+
+func TestRawPassesThrough(t *testing.T) {
+	assert := assert.New(t)
+	cs := (&chunks.TestStorage{}).NewView()
+	vs := types.NewValueStore(cs)
+	defer vs.Close()
+
+	payload := types.NewStruct("Order", types.StructData{
+		"items": types.NewList(types.String("a"), types.String("b")),
+		"total": types.Number(42),
+	})
+	raw := NewRaw(vs, payload)
+	assert.Equal(payload.Hash(), raw.Hash())
+	assert.False(raw.IsEmpty())
+	vs.Flush()
+
+	v, err := MarshalVRW(vs, envelope{To: "orders", Payload: raw})
+	assert.NoError(err)
+	st := v.(types.Struct)
+	assert.True(raw.Ref().Equals(st.Get("payload")))
+	_, ok := st.MaybeGet("extra")
+	assert.False(ok)
+
+	typ, err := MarshalType(envelope{})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ), typ.Describe())
+
+	// Neither Unmarshal nor Marshal reads the payload, even from a ValueStore
+	// that hasn't cached it.
+	vs2 := types.NewValueStore(cs)
+	reads := cs.Reads
+	var env envelope
+	assert.NoError(Unmarshal(v, &env))
+	assert.Equal("orders", env.To)
+	assert.Equal(payload.Hash(), env.Payload.Hash())
+	v2, err := MarshalVRW(vs2, env)
+	assert.NoError(err)
+	assert.True(v.Equals(v2))
+	assert.Equal(reads, cs.Reads)
+
+	// Until it's asked for.
+	assert.True(payload.Equals(env.Payload.Value(vs2)))
+}
+
+func TestRawErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Marshal(Raw{})
+	assert.Equal(ErrEmptyRaw, err)
+
+	var r Raw
+	err = Unmarshal(types.Number(1), &r)
+	assert.Error(err)
+	assert.Contains(err.Error(), "A Raw is encoded as a Ref, not a Number")
+
+	// A Raw of a value that isn't in the store doesn't read as anything.
+	r = RawFromRef(types.NewRef(types.Number(1)))
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+	defer vs.Close()
+	assert.Nil(r.Value(vs))
+}
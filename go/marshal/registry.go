@@ -0,0 +1,181 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// TypeRegistry maps the names of Noms structs to the Go types they are
+// decoded into when unmarshaling onto an interface, which lets values of
+// several struct types be decoded from the same field, list or map.
+//
+// For example, given Go types Circle and Square that implement an interface
+// Shape, and a registry in which both are registered, the registry's
+// Unmarshal decodes a Noms struct named Circle onto a Shape field as a Circle,
+// and a List of Circles and Squares onto a []Shape or []interface{}.
+//
+// The registry's MarshalType gives a Shape field the type Circle | Square,
+// the union of the types of the registered structs that implement Shape. Its
+// Marshal registers the structs that it encodes from interfaces, so that a
+// registry that values are marshaled with across many calls learns the union
+// that their interface fields hold.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]reflect.Type
+}
+
+// NewTypeRegistry returns an empty TypeRegistry.
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: map[string]reflect.Type{}}
+}
+
+// Register registers the type of |v|, which must be a struct or a pointer to
+// one, for Noms structs of the name Marshal gives it: the name of the Go
+// struct with its first character changed to upper case. If |v| is a pointer,
+// values are decoded onto newly allocated structs.
+func (r *TypeRegistry) Register(v interface{}) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	r.RegisterName(strings.Title(t.Name()), v)
+}
+
+// RegisterName is like Register, but registers the type of |v| for Noms
+// structs named |name|. Registering a second type for a name replaces the
+// first.
+func (r *TypeRegistry) RegisterName(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+	if !isStructOrPointer(t) {
+		panic(&UnsupportedTypeError{t, "Only structs can be registered"})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[name] = t
+}
+
+func isStructOrPointer(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func (r *TypeRegistry) lookup(name string) reflect.Type {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.types[name]
+}
+
+// Unmarshal is like the package's Unmarshal, but when it decodes a Noms struct
+// onto an interface, it uses the Go type registered for the struct's name. The
+// registered type must implement the interface. Structs whose names aren't
+// registered can't be decoded onto interfaces, as with Unmarshal.
+func (r *TypeRegistry) Unmarshal(v types.Value, out interface{}) error {
+	return unmarshal(v, out, &decodeState{registry: r})
+}
+
+// Marshal is like the package's Marshal, but registers the Go type of each
+// struct, or pointer to one, that it encodes from an interface, for the name
+// of the Noms struct it's encoded as, unless the type is already registered.
+func (r *TypeRegistry) Marshal(v interface{}) (types.Value, error) {
+	return marshal(nil, v, Opt{}, r)
+}
+
+// MarshalType is like the package's MarshalType, but an interface, other than
+// one that Noms values implement, has the type of a union of the types of the
+// registered Go types that implement it, each a struct named as it's
+// registered. It's an error for no registered type to implement one. An empty
+// interface has the type Value.
+func (r *TypeRegistry) MarshalType(v interface{}) (*types.Type, error) {
+	return marshalType(v, Opt{}, r)
+}
+
+// observe registers |t|, the dynamic type of a value of an interface, which
+// was encoded as |nv|, unless it's registered already.
+func (r *TypeRegistry) observe(t reflect.Type, nv types.Value) {
+	s, ok := nv.(types.Struct)
+	if !ok || !isStructOrPointer(t) {
+		return
+	}
+	r.mu.RLock()
+	registered := false
+	for _, rt := range r.types {
+		if rt == t {
+			registered = true
+			break
+		}
+	}
+	r.mu.RUnlock()
+	if !registered {
+		r.mu.Lock()
+		r.types[s.Name()] = t
+		r.mu.Unlock()
+	}
+}
+
+// unionType returns the type of the interface |t|: the union of the types of
+// the registered types that implement it, or nil if there are none. An empty
+// interface can hold any value, not only registered structs, so its type is
+// Value.
+func (r *TypeRegistry) unionType(t reflect.Type, seenStructs map[string]reflect.Type, opt Opt) *types.Type {
+	if r == nil {
+		return nil
+	}
+	if t.NumMethod() == 0 {
+		return types.ValueType
+	}
+	r.mu.RLock()
+	names := []string{}
+	impls := map[string]reflect.Type{}
+	for name, rt := range r.types {
+		if rt.Implements(t) {
+			names = append(names, name)
+			impls[name] = rt
+		}
+	}
+	r.mu.RUnlock()
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+	members := make([]*types.Type, len(names))
+	for i, name := range names {
+		rt := impls[name]
+		st := rt
+		if st.Kind() == reflect.Ptr {
+			st = st.Elem()
+		}
+		if name == opt.structName(st) {
+			members[i] = encodeType(rt, seenStructs, r, nomsTags{}, opt)
+			continue
+		}
+		// Structs registered under names of their own are renamed, and are
+		// cycle targets by those names.
+		if _, ok := seenStructs[name]; ok {
+			members[i] = types.MakeCycleType(name)
+			continue
+		}
+		seenStructs[name] = rt
+		renamed := opt
+		renamed.StructName = name
+		members[i] = encodeType(rt, seenStructs, r, nomsTags{}, renamed)
+		delete(seenStructs, name)
+	}
+	for _, m := range members {
+		if m == nil {
+			return nil
+		}
+	}
+	return types.MakeUnionType(members...)
+}
@@ -0,0 +1,170 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type shape interface {
+	area() float64
+}
+
+type circle struct {
+	Radius float64
+}
+
+func (c circle) area() float64 {
+	return 3 * c.Radius * c.Radius
+}
+
+type square struct {
+	Side float64
+}
+
+func (s *square) area() float64 {
+	return s.Side * s.Side
+}
+
+func TestTypeRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewTypeRegistry()
+	r.Register(circle{})
+	r.RegisterName("Box", &square{})
+
+	c := MustMarshal(circle{2})
+	b := types.NewStruct("Box", types.StructData{"side": types.Number(3)})
+
+	var s shape
+	assert.NoError(r.Unmarshal(c, &s))
+	assert.Equal(circle{2}, s)
+	assert.NoError(r.Unmarshal(b, &s))
+	assert.Equal(&square{3}, s)
+
+	var shapes []shape
+	assert.NoError(r.Unmarshal(types.NewList(c, b, c), &shapes))
+	assert.Equal([]shape{circle{2}, &square{3}, circle{2}}, shapes)
+
+	var i interface{}
+	assert.NoError(r.Unmarshal(types.NewList(c, b), &i))
+	assert.Equal([]interface{}{circle{2}, &square{3}}, i)
+
+	type Drawing struct {
+		Name   string
+		Shapes map[string]shape
+	}
+	var dr Drawing
+	assert.NoError(r.Unmarshal(types.NewStruct("Drawing", types.StructData{
+		"name":   types.String("d"),
+		"shapes": types.NewMap(types.String("c"), c, types.String("b"), b),
+	}), &dr))
+	assert.Equal(Drawing{"d", map[string]shape{"c": circle{2}, "b": &square{3}}}, dr)
+
+	// Without the registry, structs can't be decoded onto interfaces.
+	assert.IsType(&UnsupportedTypeError{}, Unmarshal(c, &s))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(c, &i))
+}
+
+func TestTypeRegistryErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewTypeRegistry()
+	r.Register(square{})
+	r.Register(circle{})
+
+	var s shape
+	assert.IsType(&UnsupportedTypeError{}, r.Unmarshal(types.NewStruct("Triangle", types.StructData{}), &s))
+	// Only *square implements shape.
+	err := r.Unmarshal(types.NewStruct("Square", types.StructData{"side": types.Number(1)}), &s)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+
+	var i interface{}
+	assert.IsType(&UnmarshalTypeMismatchError{}, r.Unmarshal(types.NewList(MustMarshal(circle{1}), types.NewStruct("Triangle", types.StructData{})), &i))
+
+	assert.Panics(func() { r.Register(1) })
+}
+
+func TestTypeRegistryUnion(t *testing.T) {
+	assert := assert.New(t)
+
+	type Drawing struct {
+		Main   shape
+		Shapes []shape
+	}
+	circleType := types.MakeStructType("Circle", types.StructField{Name: "radius", Type: types.NumberType})
+	squareType := types.MakeStructType("Square", types.StructField{Name: "side", Type: types.NumberType})
+	drawingType := func(shapeType *types.Type) *types.Type {
+		return types.MakeStructType("Drawing",
+			types.StructField{Name: "main", Type: shapeType},
+			types.StructField{Name: "shapes", Type: types.MakeListType(shapeType)},
+		)
+	}
+
+	// The interface's type can't be known until some types are registered.
+	r := NewTypeRegistry()
+	_, err := r.MarshalType(Drawing{})
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	// Marshal registers the types it sees in interfaces, across calls.
+	d1 := Drawing{circle{1}, []shape{circle{2}}}
+	v1, err := r.Marshal(d1)
+	assert.NoError(err)
+	nt, err := r.MarshalType(Drawing{})
+	assert.NoError(err)
+	assert.True(drawingType(circleType).Equals(nt))
+
+	d2 := Drawing{&square{3}, []shape{circle{4}, &square{5}}}
+	v2, err := r.Marshal(d2)
+	assert.NoError(err)
+	nt, err = r.MarshalType(Drawing{})
+	assert.NoError(err)
+	union := types.MakeUnionType(circleType, squareType)
+	assert.True(drawingType(union).Equals(nt))
+	assert.True(types.IsValueSubtypeOf(v1, nt))
+	assert.True(types.IsValueSubtypeOf(v2, nt))
+
+	// The registry decodes the union back into the interface.
+	var out Drawing
+	assert.NoError(r.Unmarshal(v2, &out))
+	assert.Equal(d2, out)
+
+	// Types registered under other names are renamed in the union.
+	r = NewTypeRegistry()
+	r.Register(circle{})
+	r.RegisterName("Box", &square{})
+	nt, err = r.MarshalType(Drawing{})
+	assert.NoError(err)
+	boxType := types.MakeStructType("Box", types.StructField{Name: "side", Type: types.NumberType})
+	assert.True(drawingType(types.MakeUnionType(circleType, boxType)).Equals(nt))
+}
+
+func TestTypeRegistryEmptyInterface(t *testing.T) {
+	assert := assert.New(t)
+
+	type Frame struct {
+		Main  shape
+		Other shape
+		Any   interface{}
+	}
+	r := NewTypeRegistry()
+	r.Register(circle{})
+	nt, err := r.MarshalType(Frame{})
+	assert.NoError(err)
+	circleType := types.MakeStructType("Circle", types.StructField{Name: "radius", Type: types.NumberType})
+	// An interface{} can hold more than the registered structs.
+	assert.True(types.MakeStructType("Frame",
+		types.StructField{Name: "any", Type: types.ValueType},
+		types.StructField{Name: "main", Type: circleType},
+		types.StructField{Name: "other", Type: circleType},
+	).Equals(nt), nt.Describe())
+
+	v, err := r.Marshal(Frame{circle{1}, circle{2}, 3})
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, nt))
+}
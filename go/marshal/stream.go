@@ -0,0 +1,246 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Encoder marshals Go values one at a time into a Noms List or Map. The
+// collection is chunked and written to the Encoder's ValueReadWriter as it
+// grows, so that, unlike with Marshal, the Go values needn't all be held in
+// memory at once.
+//
+// The first call to Encode or EncodeEntry decides whether the Encoder builds a
+// List or a Map, and Close returns it. An Encoder must be closed once it's
+// been used.
+type Encoder struct {
+	vrw     types.ValueReadWriter
+	values  chan types.Value
+	listCh  <-chan types.List
+	mapCh   <-chan types.Map
+	started bool
+	closed  bool
+}
+
+var errEncoderClosed = errors.New("Encoder is closed")
+
+// NewEncoder returns an Encoder that writes to |vrw|.
+func NewEncoder(vrw types.ValueReadWriter) *Encoder {
+	return &Encoder{vrw: vrw, values: make(chan types.Value, 64)}
+}
+
+// Encode marshals |v|, using the same rules as MarshalVRW, and appends it to
+// the List being built.
+func (enc *Encoder) Encode(v interface{}) error {
+	if err := enc.start(false); err != nil {
+		return err
+	}
+	nv, err := MarshalVRW(enc.vrw, v)
+	if err != nil {
+		return err
+	}
+	enc.values <- nv
+	return nil
+}
+
+// EncodeEntry marshals |k| and |v|, using the same rules as MarshalVRW, and
+// sets them in the Map being built. Entries may be given in any order.
+func (enc *Encoder) EncodeEntry(k, v interface{}) error {
+	if err := enc.start(true); err != nil {
+		return err
+	}
+	nk, err := MarshalVRW(enc.vrw, k)
+	if err != nil {
+		return err
+	}
+	nv, err := MarshalVRW(enc.vrw, v)
+	if err != nil {
+		return err
+	}
+	enc.values <- nk
+	enc.values <- nv
+	return nil
+}
+
+func (enc *Encoder) start(isMap bool) error {
+	if enc.closed {
+		return errEncoderClosed
+	}
+	if !enc.started {
+		enc.started = true
+		if isMap {
+			enc.mapCh = types.NewStreamingMap(enc.vrw, enc.values)
+		} else {
+			enc.listCh = types.NewStreamingList(enc.vrw, enc.values)
+		}
+	}
+	if isMap != (enc.mapCh != nil) {
+		return errors.New("Encode and EncodeEntry can't both be used with one Encoder")
+	}
+	return nil
+}
+
+// Close finishes the collection being built and returns it. If nothing was
+// encoded, Close returns an empty List.
+func (enc *Encoder) Close() (types.Value, error) {
+	if enc.closed {
+		return nil, errEncoderClosed
+	}
+	enc.closed = true
+	close(enc.values)
+	switch {
+	case enc.mapCh != nil:
+		return <-enc.mapCh, nil
+	case enc.listCh != nil:
+		return <-enc.listCh, nil
+	}
+	return types.NewList(), nil
+}
+
+// Decoder unmarshals the elements of a Noms List or Set, or the entries of a
+// Noms Map, one at a time, so that they needn't all be held in memory at
+// once.
+type Decoder struct {
+	v     types.Value
+	next  func() (k, v types.Value)
+	isMap bool
+	k, e  types.Value
+}
+
+// NewDecoder returns a Decoder that reads from |v|, which must be a List, Set
+// or Map.
+func NewDecoder(v types.Value) *Decoder {
+	dec := &Decoder{v: v}
+	switch v := v.(type) {
+	case types.List:
+		it := v.Iterator()
+		dec.next = func() (types.Value, types.Value) { return nil, it.Next() }
+	case types.Set:
+		it := v.Iterator()
+		dec.next = func() (types.Value, types.Value) { return nil, it.Next() }
+	case types.Map:
+		it := v.Iterator()
+		dec.next = it.Next
+		dec.isMap = true
+	}
+	return dec
+}
+
+// More returns true if there are more elements or entries to decode.
+func (dec *Decoder) More() bool {
+	if dec.next == nil {
+		return false
+	}
+	if dec.e == nil {
+		dec.k, dec.e = dec.next()
+	}
+	return dec.e != nil
+}
+
+// Decode unmarshals the next element of the List or Set into |out|, using the
+// same rules as Unmarshal. It returns io.EOF once there are no more elements.
+func (dec *Decoder) Decode(out interface{}) error {
+	if err := dec.check(false); err != nil {
+		return err
+	}
+	if !dec.More() {
+		return io.EOF
+	}
+	e := dec.e
+	dec.e = nil
+	return Unmarshal(e, out)
+}
+
+// DecodeEntry unmarshals the key and value of the next entry of the Map into
+// |k| and |v|, using the same rules as Unmarshal. It returns io.EOF once there
+// are no more entries.
+func (dec *Decoder) DecodeEntry(k, v interface{}) error {
+	if err := dec.check(true); err != nil {
+		return err
+	}
+	if !dec.More() {
+		return io.EOF
+	}
+	key, e := dec.k, dec.e
+	dec.k, dec.e = nil, nil
+	if err := Unmarshal(key, k); err != nil {
+		return err
+	}
+	return Unmarshal(e, v)
+}
+
+func (dec *Decoder) check(isMap bool) error {
+	if dec.next == nil {
+		return fmt.Errorf("Cannot decode %s incrementally, it must be a List, Set or Map", types.TypeOf(dec.v).Describe())
+	}
+	if isMap && !dec.isMap {
+		return errors.New("DecodeEntry can only be used to decode a Map")
+	}
+	if !isMap && dec.isMap {
+		return errors.New("Decode can't be used to decode a Map, use DecodeEntry")
+	}
+	return nil
+}
+
+var boolType = reflect.TypeOf(false)
+
+// UnmarshalFunc unmarshals the elements of the List or Set |v|, or the entries
+// of the Map |v|, one at a time, and calls |fn| with each, so that large
+// collections can be consumed without building a Go slice or map of them.
+// |fn| is a function of one argument for a List or Set, or of a key and a
+// value for a Map, which each element or entry is decoded into using the same
+// rules as Unmarshal. It may return a bool, true to stop. For example:
+//
+//   err := UnmarshalFunc(m, func(id string, p Person) (stop bool) {
+//       ...
+//   })
+//
+// UnmarshalFunc returns the first error decoding an element or entry, after
+// which |fn| isn't called again. A function can send what it's given to a
+// channel to consume a collection from another goroutine.
+func UnmarshalFunc(v types.Value, fn interface{}) error {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fmt.Errorf("UnmarshalFunc requires a function, not %v", reflect.TypeOf(fn))
+	}
+	ft := fv.Type()
+	if n := ft.NumIn(); n < 1 || n > 2 || ft.IsVariadic() || ft.NumOut() > 1 || (ft.NumOut() == 1 && ft.Out(0) != boolType) {
+		return fmt.Errorf("UnmarshalFunc requires a func(T) or func(K, V), optionally returning a bool, not %s", ft)
+	}
+
+	dec := NewDecoder(v)
+	isMap := ft.NumIn() == 2
+	if err := dec.check(isMap); err != nil {
+		return err
+	}
+	args := make([]reflect.Value, ft.NumIn())
+	for dec.More() {
+		for i := range args {
+			args[i] = reflect.New(ft.In(i))
+		}
+		var err error
+		if isMap {
+			err = dec.DecodeEntry(args[0].Interface(), args[1].Interface())
+		} else {
+			err = dec.Decode(args[0].Interface())
+		}
+		if err != nil {
+			return err
+		}
+		for i := range args {
+			args[i] = args[i].Elem()
+		}
+		if res := fv.Call(args); len(res) == 1 && res[0].Bool() {
+			return nil
+		}
+	}
+	return nil
+}
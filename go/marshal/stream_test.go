@@ -0,0 +1,160 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"io"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+type streamRow struct {
+	ID   int
+	Name string
+}
+
+func TestEncoderList(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+
+	const n = 5000
+	enc := NewEncoder(vs)
+	rows := make([]streamRow, n)
+	for i := range rows {
+		rows[i] = streamRow{i, "row"}
+		assert.NoError(enc.Encode(rows[i]))
+	}
+	assert.Error(enc.EncodeEntry(1, 2))
+	l, err := enc.Close()
+	assert.NoError(err)
+	assert.True(MustMarshal(rows).Equals(l))
+
+	_, err = enc.Close()
+	assert.Error(err)
+	assert.Error(enc.Encode(1))
+
+	dec := NewDecoder(l)
+	for i := 0; dec.More(); i++ {
+		var r streamRow
+		assert.NoError(dec.Decode(&r))
+		assert.Equal(rows[i], r)
+	}
+	var r streamRow
+	assert.Equal(io.EOF, dec.Decode(&r))
+	assert.Error(dec.DecodeEntry(&r, &r))
+}
+
+func TestEncoderMap(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+
+	const n = 5000
+	enc := NewEncoder(vs)
+	m := map[int]streamRow{}
+	// Entries needn't be in order.
+	for i := n - 1; i >= 0; i-- {
+		m[i] = streamRow{i, "row"}
+		assert.NoError(enc.EncodeEntry(i, m[i]))
+	}
+	assert.Error(enc.Encode(1))
+	v, err := enc.Close()
+	assert.NoError(err)
+	assert.True(MustMarshal(m).Equals(v))
+
+	dec := NewDecoder(v)
+	count := 0
+	for ; dec.More(); count++ {
+		var k int
+		var r streamRow
+		assert.NoError(dec.DecodeEntry(&k, &r))
+		assert.Equal(count, k)
+		assert.Equal(m[k], r)
+	}
+	assert.Equal(n, count)
+	var k int
+	assert.Equal(io.EOF, dec.DecodeEntry(&k, &k))
+	assert.Error(dec.Decode(&k))
+}
+
+func TestEncoderErrors(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewValueStore((&chunks.TestStorage{}).NewView())
+
+	enc := NewEncoder(vs)
+	assert.IsType(&UnsupportedTypeError{}, enc.Encode(make(chan int)))
+	v, err := enc.Close()
+	assert.NoError(err)
+	assert.True(types.NewList().Equals(v))
+
+	dec := NewDecoder(types.NewSet(types.String("a")))
+	var s string
+	assert.NoError(dec.Decode(&s))
+	assert.Equal("a", s)
+	assert.False(dec.More())
+
+	dec = NewDecoder(types.NewList(types.String("a")))
+	var i int
+	assert.IsType(&UnmarshalTypeMismatchError{}, dec.Decode(&i))
+
+	dec = NewDecoder(types.Number(1))
+	assert.False(dec.More())
+	assert.Equal("Cannot decode Number incrementally, it must be a List, Set or Map", dec.Decode(&i).Error())
+}
+
+func TestUnmarshalFunc(t *testing.T) {
+	assert := assert.New(t)
+
+	l := MustMarshal([]streamRow{{1, "a"}, {2, "b"}, {3, "c"}})
+	rows := []streamRow{}
+	assert.NoError(UnmarshalFunc(l, func(r streamRow) {
+		rows = append(rows, r)
+	}))
+	assert.Equal([]streamRow{{1, "a"}, {2, "b"}, {3, "c"}}, rows)
+
+	// Returning true stops.
+	count := 0
+	assert.NoError(UnmarshalFunc(l, func(r streamRow) bool {
+		count++
+		return r.ID == 2
+	}))
+	assert.Equal(2, count)
+
+	m := MustMarshal(map[string]int{"a": 1, "b": 2})
+	got := map[string]int{}
+	assert.NoError(UnmarshalFunc(m, func(k string, v int) {
+		got[k] = v
+	}))
+	assert.Equal(map[string]int{"a": 1, "b": 2}, got)
+
+	// Elements can be handed to another goroutine.
+	ch := make(chan int)
+	go func() {
+		defer close(ch)
+		UnmarshalFunc(types.NewSet(types.Number(1), types.Number(2)), func(i int) { ch <- i })
+	}()
+	sum := 0
+	for i := range ch {
+		sum += i
+	}
+	assert.Equal(3, sum)
+
+	// The first error decoding an element is returned.
+	count = 0
+	err := UnmarshalFunc(types.NewList(types.Number(1), types.String("x"), types.Number(3)), func(i int) {
+		count++
+	})
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.Equal(1, count)
+
+	assert.Error(UnmarshalFunc(m, func(r streamRow) {}))
+	assert.Error(UnmarshalFunc(l, func(k, v int) {}))
+	assert.Error(UnmarshalFunc(types.Number(1), func(i int) {}))
+	assert.Error(UnmarshalFunc(l, 42))
+	assert.Error(UnmarshalFunc(l, func(i int) int { return i }))
+	assert.Error(UnmarshalFunc(l, func() {}))
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"encoding"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+var textMarshalerInterface = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var textUnmarshalerInterface = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isTextMarshaler returns true if values of |t| are encoded as a String with
+// their MarshalText method, which is the case for types that implement
+// encoding.TextMarshaler, either themselves or with a pointer receiver, but
+// none of the interfaces of this package. Pointers and interfaces are encoded
+// as the value they hold, as usual. Byte slices and arrays tagged with "blob"
+// or "list", such as a net.IP, are encoded as the tag asks.
+func isTextMarshaler(t reflect.Type, tags nomsTags) bool {
+	return t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && !tags.blob && !tags.list &&
+		reflect.PtrTo(t).Implements(textMarshalerInterface)
+}
+
+// isTextUnmarshaler returns true if values of |t| are encoded with MarshalText
+// and can be decoded with UnmarshalText.
+func isTextUnmarshaler(t reflect.Type, tags nomsTags) bool {
+	return isTextMarshaler(t, tags) && reflect.PtrTo(t).Implements(textUnmarshalerInterface)
+}
+
+func textEncoder(v reflect.Value, es *encodeState) types.Value {
+	m, ok := v.Interface().(encoding.TextMarshaler)
+	if !ok {
+		// MarshalText has a pointer receiver, as encoding/json allows. Unlike
+		// encoding/json, which then encodes unaddressable values without it,
+		// call it on a copy, so that all values of a type encode the same way.
+		if !v.CanAddr() {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p.Elem()
+		}
+		m = v.Addr().Interface().(encoding.TextMarshaler)
+	}
+	text, err := m.MarshalText()
+	if err != nil {
+		panic(&marshalNomsError{err})
+	}
+	return types.String(text)
+}
+
+func textDecoder(v types.Value, rv reflect.Value, ds *decodeState) {
+	s, ok := v.(types.String)
+	if !ok {
+		panic(&UnmarshalTypeMismatchError{v, rv.Type(), ", expected String", ""})
+	}
+	ptr := reflect.New(rv.Type())
+	if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+		panic(&unmarshalNomsError{err})
+	}
+	rv.Set(ptr.Elem())
+}
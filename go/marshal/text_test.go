@@ -0,0 +1,120 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// color is a struct, so without its text methods it'd be encoded as a struct.
+type color struct {
+	R, G, B uint8
+}
+
+func (c color) MarshalText() ([]byte, error) {
+	if c.R > 9 || c.G > 9 || c.B > 9 {
+		return nil, errors.New("color out of range")
+	}
+	return []byte{'0' + c.R, '0' + c.G, '0' + c.B}, nil
+}
+
+func (c *color) UnmarshalText(text []byte) error {
+	if len(text) != 3 {
+		return errors.New("invalid color " + string(text))
+	}
+	c.R, c.G, c.B = text[0]-'0', text[1]-'0', text[2]-'0'
+	return nil
+}
+
+// ptrColor only has text methods with pointer receivers.
+type ptrColor color
+
+func (c *ptrColor) MarshalText() ([]byte, error) {
+	return (*color)(c).MarshalText()
+}
+
+func (c *ptrColor) UnmarshalText(text []byte) error {
+	return (*color)(c).UnmarshalText(text)
+}
+
+func TestTextMarshalerPointerReceiver(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Color  ptrColor
+		Colors []ptrColor
+		Ptr    *ptrColor
+	}
+	s := S{ptrColor{1, 2, 3}, []ptrColor{{4, 5, 6}}, &ptrColor{7, 8, 9}}
+
+	// Fields are addressable through a pointer, but not in a struct value.
+	for _, in := range []interface{}{&s, s} {
+		v, err := Marshal(in)
+		assert.NoError(err)
+		st := v.(types.Struct)
+		assert.Equal(types.String("123"), st.Get("color"))
+		assert.True(types.NewList(types.String("456")).Equals(st.Get("colors")))
+		assert.Equal(types.String("789"), st.Get("ptr"))
+
+		typ, err := MarshalType(in)
+		assert.NoError(err)
+		assert.True(types.IsValueSubtypeOf(v, typ), typ.Describe())
+
+		var out S
+		assert.NoError(Unmarshal(v, &out))
+		assert.Equal(s, out)
+	}
+
+	v, err := Marshal(ptrColor{4, 5, 6})
+	assert.NoError(err)
+	assert.Equal(types.String("456"), v)
+}
+
+func TestTextMarshaler(t *testing.T) {
+	assert := assert.New(t)
+
+	type S struct {
+		Color   color
+		Colors  []color
+		IP      net.IP
+		IPBytes net.IP `noms:",blob"`
+		Ptr     *color
+	}
+	ip := net.ParseIP("10.0.0.1")
+	s := S{color{1, 2, 3}, []color{{4, 5, 6}}, ip, ip, &color{7, 8, 9}}
+
+	v, err := Marshal(s)
+	assert.NoError(err)
+	st := v.(types.Struct)
+	assert.Equal(types.String("123"), st.Get("color"))
+	assert.True(types.NewList(types.String("456")).Equals(st.Get("colors")))
+	assert.Equal(types.String("10.0.0.1"), st.Get("iP"))
+	assert.IsType(types.Blob{}, st.Get("iPBytes"))
+	assert.Equal(types.String("789"), st.Get("ptr"))
+
+	typ, err := MarshalType(s)
+	assert.NoError(err)
+	assert.True(types.IsValueSubtypeOf(v, typ), typ.Describe())
+
+	var out S
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(s, out)
+
+	_, err = Marshal(color{10, 0, 0})
+	assert.Equal("color out of range", err.Error())
+
+	var c color
+	err = Unmarshal(types.String("12"), &c)
+	assert.Equal("invalid color 12", err.Error())
+	err = Unmarshal(types.Number(12), &c)
+	assert.IsType(&UnmarshalTypeMismatchError{}, err)
+	assert.True(strings.HasSuffix(err.Error(), ", expected String"))
+}
@@ -0,0 +1,34 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import "sync/atomic"
+
+// Counter is a running count, either of events, e.g. cache hits, or of things
+// which come and go, e.g. open files. Unlike Histogram, it's updated
+// atomically, so concurrent updates are never lost.
+type Counter struct {
+	n int64
+}
+
+// Inc adds one to the count.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Dec subtracts one from the count.
+func (c *Counter) Dec() {
+	c.Add(-1)
+}
+
+// Add adds delta, which may be negative, to the count.
+func (c *Counter) Add(delta int64) {
+	atomic.AddInt64(&c.n, delta)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.n)
+}
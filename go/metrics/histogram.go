@@ -6,6 +6,7 @@ package metrics
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -58,6 +59,17 @@ func (h *Histogram) SampleTime(d time.Duration) {
 	h.Sample(uint64(d))
 }
 
+// SampleTimeSince is a convenience wrapper around SampleTime which records the
+// time elapsed since t. An elapsed time of zero, which coarse clocks can
+// report, is recorded as one nanosecond.
+func (h *Histogram) SampleTimeSince(t time.Time) {
+	dur := time.Since(t)
+	if dur <= 0 {
+		dur = time.Duration(1)
+	}
+	h.SampleTime(dur)
+}
+
 // SampleTime is a convenience wrapper around Sample which internally type
 // asserts the int to a uint64
 func (h *Histogram) SampleLen(l int) {
@@ -107,6 +119,14 @@ func (h Histogram) Delta(other Histogram) Histogram {
 	return nh
 }
 
+// BucketCounts returns the number of samples in each bucket. Bucket i holds
+// the samples in the range [2^i, 2^(i+1)).
+func (h Histogram) BucketCounts() []uint64 {
+	counts := make([]uint64, bucketCount)
+	copy(counts, h.buckets[:])
+	return counts
+}
+
 // Mean returns 0 if there are no samples, and h.Sum()/h.Samples otherwise.
 func (h Histogram) Mean() uint64 {
 	samples := h.Samples()
@@ -126,6 +146,30 @@ func (h Histogram) Samples() uint64 {
 	return s
 }
 
+// Percentile returns an approximation of the value below which the fraction p
+// (0 < p <= 1) of samples fall. Since samples only retain the precision of the
+// bucket they were recorded in, the result is the upper bound of the bucket
+// containing the p'th sample. Returns 0 if there are no samples.
+func (h Histogram) Percentile(p float64) uint64 {
+	samples := h.Samples()
+	if samples == 0 {
+		return 0
+	}
+
+	target := uint64(math.Ceil(p * float64(samples)))
+	if target == 0 {
+		target = 1
+	}
+	seen := uint64(0)
+	for i := 0; i < bucketCount; i++ {
+		seen += h.buckets[i]
+		if seen >= target {
+			return h.bucketVal(i+1) - 1
+		}
+	}
+	return h.bucketVal(bucketCount) - 1
+}
+
 func (h Histogram) String() string {
 	f := h.ToString
 	if f == nil {
@@ -52,6 +52,11 @@ func TestHistogramBasic(t *testing.T) {
 	assert.Equal(uint64(11), h.Samples())
 	assert.Equal(uint64(1563), h.Sum())
 	assert.Equal(uint64(142), h.Mean())
+
+	counts := h.BucketCounts()
+	assert.Len(counts, bucketCount)
+	assert.Equal([]uint64{2, 2, 3, 0}, counts[:4])
+	assert.Equal(uint64(4), counts[8])
 }
 
 func TestHistogramAdd(t *testing.T) {
@@ -92,6 +97,24 @@ func TestHistogramString(t *testing.T) {
 	assert.Equal("Mean: 805 MB, Sum: 3.2 GB, Samples: 4", bh.String())
 }
 
+func TestHistogramPercentile(t *testing.T) {
+	assert := assert.New(t)
+
+	h := Histogram{}
+	assert.Equal(uint64(0), h.Percentile(.99))
+
+	for i := 0; i < 98; i++ {
+		h.Sample(5) // bucket [4, 8)
+	}
+	h.Sample(100)  // bucket [64, 128)
+	h.Sample(5000) // bucket [4096, 8192)
+
+	assert.Equal(uint64(7), h.Percentile(.5))
+	assert.Equal(uint64(7), h.Percentile(.98))
+	assert.Equal(uint64(127), h.Percentile(.99))
+	assert.Equal(uint64(8191), h.Percentile(1))
+}
+
 func TestHistogramReport(t *testing.T) {
 	assert := assert.New(t)
 
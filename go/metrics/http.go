@@ -0,0 +1,42 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Handler returns an http.Handler which serves a plain-text dump of every
+// registered Histogram and Counter. If the request has a |report| query param, the ASCII
+// graph of each Histogram is included as well.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, withReport := req.URL.Query()["report"]
+		snap := Snapshot()
+		for _, name := range SnapshotNames(snap) {
+			h := snap[name]
+			f := h.ToString
+			if f == nil {
+				f = identToString
+			}
+			fmt.Fprintf(w, "%s: %s, P99: %s\n", name, h, f(h.Percentile(.99)))
+			if withReport && h.Samples() > 0 {
+				fmt.Fprintln(w, h.Report())
+			}
+		}
+		counts := CounterSnapshot()
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s: %d\n", name, counts[name])
+		}
+	})
+}
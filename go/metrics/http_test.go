@@ -0,0 +1,31 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	assert := assert.New(t)
+	defer Reset()
+
+	RegisterByteHistogram("test.HandlerBytes").Sample(1000)
+	RegisterCounter("test.HandlerCount").Add(3)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(200, w.Code)
+	assert.Contains(w.Body.String(), "test.HandlerBytes: Mean: 768 B, Sum: 768 B, Samples: 1, P99: 1.0 kB\n")
+	assert.Contains(w.Body.String(), "test.HandlerCount: 3\n")
+
+	w = httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/?report", nil))
+	assert.True(strings.Contains(w.Body.String(), "> 512 B: (1)"))
+}
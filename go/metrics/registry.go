@@ -0,0 +1,127 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// The registry holds named Histograms and Counters which instrumented code
+// paths throughout Noms (datas.Pull, types.ValueStore, NBS table IO, ...)
+// sample into. Sampling is off by default so that the instrumentation costs nothing more than an
+// atomic load unless someone is actively investigating performance.
+var (
+	enabled    uint32
+	registryMu sync.Mutex
+	registry   = map[string]*Histogram{}
+	counters   = map[string]*Counter{}
+)
+
+// SetEnabled turns global metrics collection on or off.
+func SetEnabled(on bool) {
+	v := uint32(0)
+	if on {
+		v = 1
+	}
+	atomic.StoreUint32(&enabled, v)
+}
+
+// Enabled returns true iff global metrics collection has been turned on.
+// Instrumented code should check this before taking any samples.
+func Enabled() bool {
+	return atomic.LoadUint32(&enabled) == 1
+}
+
+// RegisterTimeHistogram returns the Histogram registered under name, creating
+// a new time-valued Histogram if none exists yet.
+func RegisterTimeHistogram(name string) *Histogram {
+	return register(name, NewTimeHistogram)
+}
+
+// RegisterByteHistogram returns the Histogram registered under name, creating
+// a new byte-valued Histogram if none exists yet.
+func RegisterByteHistogram(name string) *Histogram {
+	return register(name, NewByteHistogram)
+}
+
+// RegisterHistogram returns the Histogram registered under name, creating a
+// new plain Histogram if none exists yet.
+func RegisterHistogram(name string) *Histogram {
+	return register(name, func() Histogram { return Histogram{} })
+}
+
+func register(name string, newHist func() Histogram) *Histogram {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if h, ok := registry[name]; ok {
+		return h
+	}
+	h := newHist()
+	registry[name] = &h
+	return &h
+}
+
+// RegisterCounter returns the Counter registered under name, creating a new
+// one if none exists yet.
+func RegisterCounter(name string) *Counter {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if c, ok := counters[name]; ok {
+		return c
+	}
+	c := &Counter{}
+	counters[name] = c
+	return c
+}
+
+// Snapshot returns a copy of every registered Histogram, keyed by name. Like
+// Histogram itself, Snapshot doesn't lock out concurrent samplers, so counts
+// may be slightly stale.
+func Snapshot() map[string]Histogram {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	snap := make(map[string]Histogram, len(registry))
+	for name, h := range registry {
+		snap[name] = *h
+	}
+	return snap
+}
+
+// CounterSnapshot returns the current value of every registered Counter,
+// keyed by name.
+func CounterSnapshot() map[string]int64 {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	snap := make(map[string]int64, len(counters))
+	for name, c := range counters {
+		snap[name] = c.Value()
+	}
+	return snap
+}
+
+// SnapshotNames returns the sorted names of all registered Histograms.
+func SnapshotNames(snap map[string]Histogram) []string {
+	names := make([]string, 0, len(snap))
+	for name := range snap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Reset clears the samples of every registered Histogram, and zeroes every
+// registered Counter.
+func Reset() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, h := range registry {
+		h.buckets = [bucketCount]uint64{}
+	}
+	for _, c := range counters {
+		atomic.StoreInt64(&c.n, 0)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestRegistryEnabled(t *testing.T) {
+	assert := assert.New(t)
+	defer SetEnabled(false)
+
+	assert.False(Enabled())
+	SetEnabled(true)
+	assert.True(Enabled())
+	SetEnabled(false)
+	assert.False(Enabled())
+}
+
+func TestRegistrySnapshot(t *testing.T) {
+	assert := assert.New(t)
+	defer Reset()
+
+	th := RegisterTimeHistogram("test.Latency")
+	assert.True(th == RegisterTimeHistogram("test.Latency"))
+	bh := RegisterByteHistogram("test.Bytes")
+
+	th.SampleTime(time.Millisecond)
+	th.SampleTimeSince(time.Now().Add(time.Hour)) // clamped, rather than panicking
+	bh.Sample(1024)
+
+	snap := Snapshot()
+	assert.Equal(uint64(2), snap["test.Latency"].Samples())
+	assert.Equal(uint64(1), snap["test.Bytes"].Samples())
+	assert.Equal("Mean: 1.5 kB, Sum: 1.5 kB, Samples: 1", snap["test.Bytes"].String())
+
+	names := SnapshotNames(snap)
+	assert.Contains(names, "test.Bytes")
+	assert.Contains(names, "test.Latency")
+
+	// Snapshots are copies, unaffected by later samples.
+	bh.Sample(1024)
+	assert.Equal(uint64(1), snap["test.Bytes"].Samples())
+
+	Reset()
+	assert.Equal(uint64(0), Snapshot()["test.Latency"].Samples())
+}
+
+func TestRegistryCounters(t *testing.T) {
+	assert := assert.New(t)
+	defer Reset()
+
+	c := RegisterCounter("test.Count")
+	assert.True(c == RegisterCounter("test.Count"))
+
+	c.Inc()
+	c.Inc()
+	c.Dec()
+	c.Add(5)
+	assert.Equal(int64(6), c.Value())
+	assert.Equal(int64(6), CounterSnapshot()["test.Count"])
+
+	Reset()
+	assert.Equal(int64(0), c.Value())
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package telemetry records the metrics a process collects as Noms values,
+// so that a Noms deployment can be monitored with Noms' own tools. It's
+// separate from package metrics because package types depends on that.
+package telemetry
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/metrics"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// DatasetID is the dataset that Recorders commit to by default.
+const DatasetID = "telemetry"
+
+// ToNoms returns |h| as a Noms struct:
+//
+//  struct Histogram {
+//    buckets: Map<Number, Number>,
+//    mean: Number,
+//    p99: Number,
+//    samples: Number,
+//    sum: Number,
+//  }
+//
+// buckets maps the lower bound of each bucket that has samples to the number
+// of samples in it; the bucket with lower bound n holds samples in [n, 2n).
+// The other fields are as reported by the Histogram's methods of the same
+// names, with p99 being Percentile(.99).
+func ToNoms(h metrics.Histogram) types.Struct {
+	kvs := []types.Value{}
+	for i, count := range h.BucketCounts() {
+		if count > 0 {
+			kvs = append(kvs, types.Number(uint64(1)<<uint(i)), types.Number(count))
+		}
+	}
+	return types.NewStruct("Histogram", types.StructData{
+		"buckets": types.NewMap(kvs...),
+		"mean":    types.Number(h.Mean()),
+		"p99":     types.Number(h.Percentile(.99)),
+		"samples": types.Number(h.Samples()),
+		"sum":     types.Number(h.Sum()),
+	})
+}
+
+// SnapshotToNoms returns a Map from the name of each Histogram in |snap| to
+// its ToNoms struct.
+func SnapshotToNoms(snap map[string]metrics.Histogram) types.Map {
+	kvs := make([]types.Value, 0, 2*len(snap))
+	for name, h := range snap {
+		kvs = append(kvs, types.String(name), ToNoms(h))
+	}
+	return types.NewMap(kvs...)
+}
+
+// Recorder commits snapshots of the process' registered Histograms to a
+// dataset. Each commit's value is a Map from Histogram names to their
+// ToNoms structs, and its meta has the date of the snapshot, so the history
+// of the dataset is a time series that `noms log` and `noms diff` can show.
+type Recorder struct {
+	db   datas.Database
+	id   string
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRecorder returns a Recorder that commits to the dataset |datasetID| of
+// |db|.
+func NewRecorder(db datas.Database, datasetID string) *Recorder {
+	return &Recorder{db: db, id: datasetID}
+}
+
+// Record commits a snapshot of the registered Histograms now. Commits made
+// concurrently by other processes are retried, since a snapshot doesn't
+// depend on the previous one.
+func (r *Recorder) Record() error {
+	now := time.Now()
+	v := SnapshotToNoms(metrics.Snapshot())
+	meta := types.NewStruct("Meta", types.StructData{
+		"date": types.String(now.UTC().Format(datas.CommitMetaDateFormat)),
+	})
+	for {
+		ds := r.db.GetDataset(r.id)
+		_, err := r.db.Commit(ds, v, datas.CommitOptions{Meta: meta})
+		if err != datas.ErrMergeNeeded {
+			return err
+		}
+	}
+}
+
+// Start calls Record every |interval| until Stop is called. Since metrics are
+// only collected while they're enabled, Start enables them. Errors are logged
+// rather than stopping the Recorder.
+func (r *Recorder) Start(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	metrics.SetEnabled(true)
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Record(); err != nil {
+					log.Printf("Failed to record telemetry to %s: %s", r.id, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(r.stop, r.done)
+}
+
+// Stop stops a Recorder that was started, waiting for any snapshot being
+// committed, and records a final one so that the samples taken since the last
+// snapshot aren't lost.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop == nil {
+		return nil
+	}
+	close(r.stop)
+	<-r.done
+	r.stop, r.done = nil, nil
+	return r.Record()
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/metrics"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestToNoms(t *testing.T) {
+	assert := assert.New(t)
+
+	h := metrics.Histogram{}
+	h.Sample(1)
+	h.Sample(5)
+	h.Sample(6)
+
+	s := ToNoms(h)
+	assert.Equal("Histogram", s.Name())
+	assert.True(types.NewMap(
+		types.Number(1), types.Number(1),
+		types.Number(4), types.Number(2),
+	).Equals(s.Get("buckets")))
+	assert.Equal(types.Number(3), s.Get("samples"))
+	assert.Equal(types.Number(h.Sum()), s.Get("sum"))
+	assert.Equal(types.Number(h.Mean()), s.Get("mean"))
+	assert.Equal(types.Number(7), s.Get("p99"))
+
+	s = ToNoms(metrics.Histogram{})
+	assert.True(types.NewMap().Equals(s.Get("buckets")))
+	assert.Equal(types.Number(0), s.Get("samples"))
+}
+
+func TestRecorder(t *testing.T) {
+	assert := assert.New(t)
+	defer metrics.SetEnabled(false)
+	defer metrics.Reset()
+
+	storage := &chunks.MemoryStorage{}
+	db := datas.NewDatabase(storage.NewView())
+	defer db.Close()
+
+	h := metrics.RegisterHistogram("telemetry.Test")
+	h.Sample(3)
+
+	r := NewRecorder(db, DatasetID)
+	assert.NoError(r.Record())
+	ds := db.GetDataset(DatasetID)
+	m := ds.HeadValue().(types.Map)
+	assert.True(ToNoms(*h).Equals(m.Get(types.String("telemetry.Test"))))
+	_, ok := ds.Head().Get(datas.MetaField).(types.Struct).MaybeGet("date")
+	assert.True(ok)
+
+	// Stopping records the samples taken since the last snapshot.
+	r.Start(time.Hour)
+	assert.True(metrics.Enabled())
+	h.Sample(3)
+	assert.NoError(r.Stop())
+	assert.NoError(r.Stop())
+
+	ds = db.GetDataset(DatasetID)
+	m = ds.HeadValue().(types.Map)
+	assert.Equal(types.Number(2), m.Get(types.String("telemetry.Test")).(types.Struct).Get("samples"))
+}
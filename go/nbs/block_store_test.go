@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/hash"
@@ -290,6 +291,82 @@ func (suite *BlockStoreSuite) TestCompactOnUpdateRoot() {
 	suite.Len(specs, 2)
 }
 
+func (suite *BlockStoreSuite) TestConfigureTableCacheAfterOpen() {
+	// SetupTest opened a store, which creates the shared table caches.
+	suite.Equal(ErrTableCacheConfigured, ConfigureTableCache(TableCacheOptions{MaxOpenFiles: 16}))
+}
+
+func (suite *BlockStoreSuite) TestRepair() {
+	inputs := [][]byte{[]byte("ab"), []byte("cd"), []byte("ef")}
+	chunx := make([]chunks.Chunk, len(inputs))
+	for i, data := range inputs {
+		chunx[i] = chunks.NewChunk(data)
+		suite.store.Put(chunx[i])
+	}
+	suite.True(suite.store.Commit(chunx[0].Hash(), suite.store.Root()))
+
+	repairs, err := suite.store.Repair()
+	suite.NoError(err)
+	suite.Empty(repairs)
+
+	specs := suite.store.tables.ToSpecs()
+	suite.Len(specs, 1)
+	path := filepath.Join(suite.dir, specs[0].name.String())
+	tableData, err := ioutil.ReadFile(path)
+	suite.NoError(err)
+	corruptChunk(tableData, inputs[1])
+	suite.NoError(ioutil.WriteFile(path, tableData, 0644))
+
+	repairs, err = suite.store.Repair()
+	suite.NoError(err)
+	suite.Equal([]TableRepair{{specs[0].name.String(), 2, hash.HashSlice{chunx[1].Hash()}}}, repairs)
+
+	store := NewLocalStore(suite.dir, testMemTableSize)
+	defer store.Close()
+	suite.Equal(chunx[0].Hash(), store.Root())
+	assertInputInStore(inputs[0], chunx[0].Hash(), store, suite.Assert())
+	assertInputInStore(inputs[2], chunx[2].Hash(), store, suite.Assert())
+	suite.False(store.Has(chunx[1].Hash()))
+}
+
+func (suite *BlockStoreSuite) TestScrubber() {
+	inputs := [][]byte{[]byte("ab"), []byte("cd"), []byte("ef")}
+	chunx := make([]chunks.Chunk, len(inputs))
+	for i, data := range inputs {
+		chunx[i] = chunks.NewChunk(data)
+		suite.store.Put(chunx[i])
+	}
+	suite.True(suite.store.Commit(chunx[0].Hash(), suite.store.Root()))
+
+	specs := suite.store.tables.ToSpecs()
+	suite.Len(specs, 1)
+	path := filepath.Join(suite.dir, specs[0].name.String())
+	tableData, err := ioutil.ReadFile(path)
+	suite.NoError(err)
+	corruptChunk(tableData, inputs[1])
+	suite.NoError(ioutil.WriteFile(path, tableData, 0644))
+
+	reports := make(chan ScrubReport, 1)
+	stop := suite.store.StartScrubber(ScrubberOptions{
+		Interval:  time.Millisecond,
+		BatchSize: 1,
+		Repair:    true,
+		OnDamage:  func(report ScrubReport) { reports <- report },
+	})
+	report := <-reports
+	stop()
+	stop()
+
+	suite.Equal(ScrubReport{
+		Table:   specs[0].name.String(),
+		Damaged: hash.HashSlice{chunx[1].Hash()},
+		Repairs: []TableRepair{{specs[0].name.String(), 2, hash.HashSlice{chunx[1].Hash()}}},
+	}, report)
+	assertInputInStore(inputs[0], chunx[0].Hash(), suite.store, suite.Assert())
+	assertInputInStore(inputs[2], chunx[2].Hash(), suite.store, suite.Assert())
+	suite.False(suite.store.Has(chunx[1].Hash()))
+}
+
 func assertInputInStore(input []byte, h hash.Hash, s chunks.ChunkStore, assert *assert.Assertions) {
 	c := s.Get(h)
 	assert.False(c.IsEmpty(), "Shouldn't get empty chunk for %s", h.String())
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// BulkWriter builds table files directly from a stream of chunks, for
+// importing many more chunks than Put and Commit handle well. Unlike Put, it
+// doesn't check whether the store already has a chunk, and it doesn't make
+// the tables it writes visible until Commit, which adds all of them to the
+// manifest in a single update. Chunks are written to tables in the order
+// they're added, so callers should add them in the order they'll be read;
+// duplicates of the chunk added just before are dropped, so a sorted stream
+// has no duplicates in its tables. NOT goroutine safe.
+type BulkWriter struct {
+	nbs       *NomsBlockStore
+	tableSize uint64
+
+	pending     []chunks.Chunk
+	pendingData uint64
+	last        hash.Hash
+
+	sources chunkSources
+}
+
+// NewBulkWriter returns a BulkWriter that adds tables to |nbs|, each holding
+// about |tableSize| bytes of uncompressed chunk data. If |tableSize| is 0,
+// the memTable size of |nbs| is used.
+func (nbs *NomsBlockStore) NewBulkWriter(tableSize uint64) *BulkWriter {
+	if tableSize == 0 {
+		tableSize = nbs.mtSize
+	}
+	return &BulkWriter{nbs: nbs, tableSize: tableSize}
+}
+
+// Put adds |c| to the table being built, writing the table out when it's
+// full. The chunk can't be read from the store until Commit.
+func (bw *BulkWriter) Put(c chunks.Chunk) {
+	d.PanicIfTrue(c.IsEmpty())
+	if len(bw.pending) > 0 && c.Hash() == bw.last {
+		return
+	}
+	if bw.pendingData+uint64(len(c.Data())) > bw.tableSize {
+		bw.flush()
+	}
+	bw.pending = append(bw.pending, c)
+	bw.pendingData += uint64(len(c.Data()))
+	bw.last = c.Hash()
+}
+
+// flush writes the pending chunks out as a table.
+func (bw *BulkWriter) flush() {
+	if len(bw.pending) == 0 {
+		return
+	}
+	buff := make([]byte, maxTableSize(uint64(len(bw.pending)), bw.pendingData))
+	tw := newTableWriter(buff, nil)
+	for _, c := range bw.pending {
+		tw.addChunk(addr(c.Hash()), c.Data())
+	}
+	tableSize, name := tw.finish()
+	count := uint32(len(bw.pending))
+	bw.nbs.stats.BytesPerPersist.Sample(tableSize)
+	bw.nbs.stats.ChunksPerPersist.Sample(uint64(count))
+
+	bw.sources = append(bw.sources, bw.nbs.tables.p.persistTable(name, buff[:tableSize], count))
+	bw.pending, bw.pendingData = nil, 0
+}
+
+// Commit writes out the table being built and then commits the store, as
+// NomsBlockStore.Commit does, adding every table written since the last
+// Commit to the manifest along with the new root |current|. If Commit returns
+// false, the tables stay with the store, and are added to the manifest by its
+// next successful Commit.
+func (bw *BulkWriter) Commit(current, last hash.Hash) bool {
+	bw.flush()
+	if len(bw.sources) > 0 {
+		func() {
+			bw.nbs.mu.Lock()
+			defer bw.nbs.mu.Unlock()
+			bw.nbs.tables = bw.nbs.tables.PrependSources(bw.sources)
+		}()
+		bw.sources = nil
+	}
+	return bw.nbs.Commit(current, last)
+}
@@ -0,0 +1,61 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestBulkWriter(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	store := NewLocalStore(dir, testMemTableSize)
+	defer store.Close()
+
+	existing := chunks.NewChunk([]byte("existing"))
+	store.Put(existing)
+	root := existing.Hash()
+	assert.True(store.Commit(root, hash.Hash{}))
+
+	const n = 100
+	input := make([]chunks.Chunk, n)
+	for i := range input {
+		input[i] = chunks.NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+	}
+
+	bw := store.NewBulkWriter(64)
+	for _, c := range input {
+		bw.Put(c)
+		bw.Put(c) // Consecutive duplicates are dropped.
+	}
+	assert.False(store.Has(input[0].Hash()))
+	assert.True(len(bw.sources) > 1)
+
+	// A failed Commit leaves the tables with the store.
+	newRoot := input[n-1].Hash()
+	assert.False(bw.Commit(newRoot, newRoot))
+	assert.True(bw.Commit(newRoot, root))
+	assert.Equal(newRoot, store.Root())
+	assert.Equal(uint32(n+1), store.Count())
+
+	reopened := NewLocalStore(dir, testMemTableSize)
+	defer reopened.Close()
+	assert.Equal(newRoot, reopened.Root())
+	assert.Equal(uint32(n+1), reopened.Count())
+	assert.True(reopened.Has(existing.Hash()))
+	for _, c := range input {
+		assert.Equal(c.Data(), reopened.Get(c.Hash()).Data())
+	}
+}
@@ -76,7 +76,7 @@ func (dm dynamoManifest) ParseIfExists(readHook func()) (exists bool, vers strin
 
 func validateManifest(item map[string]*dynamodb.AttributeValue) (valid, hasSpecs bool) {
 	if item[nbsVersAttr] != nil && item[nbsVersAttr].S != nil &&
-		StorageVersion == *item[nbsVersAttr].S &&
+		supportedStorageVersion(*item[nbsVersAttr].S) &&
 		item[versAttr] != nil && item[versAttr].S != nil &&
 		item[lockAttr] != nil && item[lockAttr].B != nil &&
 		item[rootAttr] != nil && item[rootAttr].B != nil {
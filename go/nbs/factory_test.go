@@ -10,10 +10,31 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/chunks/chunkstest"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
 )
 
+func TestLocalStoreFactoryConformance(t *testing.T) {
+	suite.Run(t, &localStoreFactorySuite{})
+}
+
+type localStoreFactorySuite struct {
+	chunkstest.Suite
+	dir string
+}
+
+func (suite *localStoreFactorySuite) SetupTest() {
+	suite.dir = makeTempDir(suite.Assert())
+	suite.Factory = NewLocalStoreFactory(suite.dir, 0, 8)
+}
+
+func (suite *localStoreFactorySuite) TearDownTest() {
+	suite.Factory.Shutter()
+	os.RemoveAll(suite.dir)
+}
+
 func TestLocalStoreFactory(t *testing.T) {
 	assert := assert.New(t)
 	dir := makeTempDir(assert)
@@ -10,6 +10,7 @@ import (
 	"sync"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/metrics"
 )
 
 func newFDCache(targetSize int) *fdCache {
@@ -55,8 +56,14 @@ func (fc *fdCache) RefFile(path string) (f *os.File, err error) {
 		return refFile()
 	}()
 	if f != nil {
+		if metrics.Enabled() {
+			tableFileCacheHits.Inc()
+		}
 		return f, nil
 	}
+	if metrics.Enabled() {
+		tableFileCacheMisses.Inc()
+	}
 
 	// Very much want this to be outside the lock, but the downside is that multiple callers may get here concurrently. That means we need to deal with the raciness below.
 	f, err = os.Open(path)
@@ -73,6 +80,7 @@ func (fc *fdCache) RefFile(path string) (f *os.File, err error) {
 	}
 	// I won the race!
 	fc.cache[path] = fdCacheEntry{f: f, refCount: 1}
+	openTableFiles.Inc()
 	return f, nil
 }
 
@@ -107,6 +115,7 @@ func (fc *fdCache) UnrefFile(path string) {
 		for _, p := range toDrop {
 			delete(fc.cache, p)
 		}
+		openTableFiles.Add(-int64(len(toDrop)))
 	}
 }
 
@@ -117,6 +126,7 @@ func (fc *fdCache) Drop() {
 	for _, ce := range fc.cache {
 		ce.f.Close()
 	}
+	openTableFiles.Add(-int64(len(fc.cache)))
 	fc.cache = map[string]fdCacheEntry{}
 }
 
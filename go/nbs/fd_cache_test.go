@@ -13,6 +13,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/attic-labs/testify/assert"
 )
 
@@ -110,4 +111,29 @@ func TestFDCache(t *testing.T) {
 
 		assert.EqualValues(paths[2:], fc.reportEntries())
 	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		assert := assert.New(t)
+		metrics.SetEnabled(true)
+		defer metrics.SetEnabled(false)
+		fc := newFDCache(1)
+		defer fc.Drop()
+
+		hits, misses, opens := tableFileCacheHits.Value(), tableFileCacheMisses.Value(), openTableFiles.Value()
+		refNoError(fc, paths[0], assert)
+		refNoError(fc, paths[0], assert)
+		refNoError(fc, paths[1], assert)
+		assert.EqualValues(1, tableFileCacheHits.Value()-hits)
+		assert.EqualValues(2, tableFileCacheMisses.Value()-misses)
+		assert.EqualValues(2, openTableFiles.Value()-opens)
+
+		// Evicting paths[0] closes it.
+		fc.UnrefFile(paths[0])
+		fc.UnrefFile(paths[0])
+		fc.UnrefFile(paths[1])
+		assert.EqualValues(1, openTableFiles.Value()-opens)
+
+		fc.Drop()
+		assert.EqualValues(0, openTableFiles.Value()-opens)
+	})
 }
@@ -79,7 +79,9 @@ func parseManifest(r io.Reader) (nomsVersion string, lock addr, root hash.Hash,
 	if len(slices) < 4 || len(slices)%2 == 1 {
 		d.Chk.Fail("Malformed manifest: " + string(manifest))
 	}
-	d.PanicIfFalse(StorageVersion == string(slices[0]))
+	if !supportedStorageVersion(slices[0]) {
+		d.Panic("Unsupported nbs storage version %s in manifest", slices[0])
+	}
 
 	return slices[1], ParseAddr([]byte(slices[2])), hash.Parse(slices[3]), parseSpecs(slices[4:])
 }
@@ -51,6 +51,34 @@ func TestFileManifestLoadIfExists(t *testing.T) {
 	}
 }
 
+func TestFileManifestStorageVersions(t *testing.T) {
+	assert := assert.New(t)
+	fm := makeFileManifestTempDir(t)
+	defer os.RemoveAll(fm.dir)
+
+	lock := computeAddr([]byte("locker"))
+	newRoot := hash.Of([]byte("new root"))
+
+	// Manifests of stores that only hold legacy tables are still readable.
+	err := clobberManifest(fm.dir, strings.Join([]string{legacyStorageVersion, constants.NomsVersion, lock.String(), newRoot.String()}, ":"))
+	assert.NoError(err)
+	exists, _, _, root, _ := fm.ParseIfExists(nil)
+	assert.True(exists)
+	assert.Equal(newRoot, root)
+
+	// Updating one writes the current StorageVersion.
+	newLock := computeAddr([]byte("new lock"))
+	fm.Update(lock, newLock, nil, newRoot, nil)
+	b, err := ioutil.ReadFile(filepath.Join(fm.dir, manifestFileName))
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(string(b), StorageVersion+":"))
+
+	// Unknown versions are rejected.
+	err = clobberManifest(fm.dir, strings.Join([]string{"3", constants.NomsVersion, lock.String(), newRoot.String()}, ":"))
+	assert.NoError(err)
+	assert.Panics(func() { fm.ParseIfExists(nil) })
+}
+
 func TestFileManifestLoadIfExistsHoldsLock(t *testing.T) {
 	assert := assert.New(t)
 	fm := makeFileManifestTempDir(t)
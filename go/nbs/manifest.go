@@ -62,6 +62,12 @@ type manifest interface {
 	)
 }
 
+// supportedStorageVersion returns whether a manifest written with storage
+// version |v| can be read.
+func supportedStorageVersion(v string) bool {
+	return v == StorageVersion || v == legacyStorageVersion
+}
+
 type tableSpec struct {
 	name       addr
 	chunkCount uint32
@@ -5,6 +5,7 @@
 package nbs
 
 import (
+	"bytes"
 	"sort"
 	"sync"
 
@@ -91,6 +92,14 @@ func (mt *memTable) getMany(reqs []getRecord, foundChunks chan *chunks.Chunk, wg
 	return
 }
 
+func (mt *memTable) addrsInRange(lo, hi addr, found hash.HashSet) {
+	for a := range mt.chunks {
+		if bytes.Compare(a[:], lo[:]) >= 0 && bytes.Compare(a[:], hi[:]) <= 0 {
+			found.Insert(hash.Hash(a))
+		}
+	}
+}
+
 func (mt *memTable) extract(chunks chan<- extractRecord) {
 	for _, hrec := range mt.order {
 		chunks <- extractRecord{a: *hrec.a, data: mt.chunks[*hrec.a]}
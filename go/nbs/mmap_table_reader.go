@@ -54,8 +54,11 @@ func newMmapTableReader(dir string, h addr, chunkCount uint32, indexCache *index
 		fi, err := f.Stat()
 		d.PanicIfError(err)
 		d.PanicIfTrue(fi.Size() < 0)
-		// index. Mmap won't take an offset that's not page-aligned, so find the nearest page boundary preceding the index.
+		// index. Mmap won't take an offset that's not page-aligned, so find the nearest page boundary preceding the index. Legacy tables have a shorter footer, so this may start a few bytes early -- or, for a tiny table, before the start of the file.
 		indexOffset := fi.Size() - int64(footerSize) - int64(indexSize(chunkCount))
+		if indexOffset < 0 {
+			indexOffset = 0
+		}
 		aligned := indexOffset / pageSize * pageSize // Thanks, integer arithmetic!
 		d.PanicIfTrue(fi.Size()-aligned > maxInt)
 		buff, err := unix.Mmap(int(f.Fd()), aligned, int(fi.Size()-aligned), unix.PROT_READ, unix.MAP_SHARED)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/metrics"
 )
 
 func newPersistingChunkSource(mt *memTable, haver chunkReader, p tablePersister, rl chan struct{}, stats *Stats) *persistingChunkSource {
@@ -32,6 +33,9 @@ func newPersistingChunkSource(mt *memTable, haver chunkReader, p tablePersister,
 
 		if cs.count() > 0 {
 			stats.PersistLatency.SampleTime(time.Since(t1))
+			if metrics.Enabled() {
+				tablePersistLatency.SampleTimeSince(t1)
+			}
 		}
 	}()
 	return ccs
@@ -108,6 +112,18 @@ func (ccs *persistingChunkSource) reader() io.Reader {
 	return ccs.cs.reader()
 }
 
+func (ccs *persistingChunkSource) salvage(mt *memTable) addrSlice {
+	ccs.wg.Wait()
+	d.Chk.True(ccs.cs != nil)
+	return ccs.cs.salvage(mt)
+}
+
+func (ccs *persistingChunkSource) scrub(from, count uint32) (addrSlice, error) {
+	ccs.wg.Wait()
+	d.Chk.True(ccs.cs != nil)
+	return ccs.cs.scrub(from, count)
+}
+
 func (ccs *persistingChunkSource) calcReads(reqs []getRecord, blockSize uint64) (reads int, remaining bool) {
 	ccs.wg.Wait()
 	d.Chk.True(ccs.cs != nil)
@@ -154,6 +170,14 @@ func (ecs emptyChunkSource) index() tableIndex {
 	return tableIndex{}
 }
 
+func (ecs emptyChunkSource) salvage(mt *memTable) addrSlice {
+	return nil
+}
+
+func (ecs emptyChunkSource) scrub(from, count uint32) (addrSlice, error) {
+	return nil, nil
+}
+
 func (ecs emptyChunkSource) reader() io.Reader {
 	return &bytes.Buffer{}
 }
@@ -0,0 +1,72 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/dustin/go-humanize"
+	flag "github.com/juju/gnuflag"
+)
+
+var (
+	dir     = flag.String("dir", "", "Repair the NBS store in the given directory")
+	table   = flag.String("table", "", "Repair an NBS store in AWS, using this table")
+	bucket  = flag.String("bucket", "", "Repair an NBS store in AWS, using this bucket")
+	dbName  = flag.String("db", "", "Repair an NBS store in AWS, using this db name")
+	verbose = flag.Bool("verbose", false, "List the hash of every chunk that could not be recovered")
+)
+
+const memTableSize = 128 * humanize.MiByte
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Replaces each damaged table in an NBS store with one holding only its intact chunks.")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse(true)
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		return
+	}
+
+	var store *nbs.NomsBlockStore
+	if *dir != "" {
+		store = nbs.NewLocalStore(*dir, memTableSize)
+	} else if *table != "" && *bucket != "" && *dbName != "" {
+		sess := session.Must(session.NewSession(aws.NewConfig().WithRegion("us-west-2")))
+		store = nbs.NewAWSStore(*table, *dbName, *bucket, s3.New(sess), dynamodb.New(sess), memTableSize)
+	} else {
+		log.Fatalf("Must set either --dir or ALL of --table, --bucket and --db\n")
+	}
+	defer store.Close()
+
+	repairs, err := store.Repair()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(repairs) == 0 {
+		fmt.Println("No damaged tables found")
+		return
+	}
+	for _, r := range repairs {
+		fmt.Printf("%s: salvaged %d chunks, lost %d\n", r.Table, r.Salvaged, len(r.Lost))
+		if *verbose {
+			for _, h := range r.Lost {
+				fmt.Printf("  %s\n", h)
+			}
+		}
+	}
+}
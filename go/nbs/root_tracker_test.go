@@ -236,6 +236,14 @@ func (ftp fakeTablePersister) Persist(mt *memTable, haver chunkReader, stats *St
 	return emptyChunkSource{}
 }
 
+func (ftp fakeTablePersister) persistTable(name addr, data []byte, chunkCount uint32) chunkSource {
+	if chunkCount > 0 {
+		ftp.sources[name] = newTableReader(parseTableIndex(data), bytes.NewReader(data), fileBlockSize)
+		return chunkSourceAdapter{ftp.sources[name], name}
+	}
+	return emptyChunkSource{}
+}
+
 func (ftp fakeTablePersister) CompactAll(sources chunkSources, stats *Stats) chunkSource {
 	name, data, chunkCount := compactSourcesToBuffer(sources)
 	if chunkCount > 0 {
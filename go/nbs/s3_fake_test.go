@@ -203,6 +203,9 @@ func parseRange(hdr string, total int) (start, end int) {
 		// negative range
 		fromEnd, err := strconv.Atoi(hdr[1:])
 		d.PanicIfError(err)
+		if fromEnd > total {
+			return 0, total
+		}
 		return total - fromEnd, total
 	}
 	ends := strings.Split(hdr, "-")
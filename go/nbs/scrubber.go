@@ -0,0 +1,154 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nbs
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+)
+
+const (
+	defaultScrubInterval  = time.Second
+	defaultScrubBatchSize = 256
+)
+
+// ScrubberOptions configures the scrubber that StartScrubber starts.
+type ScrubberOptions struct {
+	// Interval is how often the scrubber checks a batch of chunk records, if
+	// the store is idle. It defaults to a second.
+	Interval time.Duration
+	// BatchSize is the number of chunk records checked at a time. It defaults
+	// to 256.
+	BatchSize uint32
+	// Repair has the scrubber call Repair when it finds damaged records.
+	Repair bool
+	// OnDamage, if set, is called on the scrubber's goroutine with a report of
+	// each batch of damaged records it finds, or couldn't read.
+	OnDamage func(report ScrubReport)
+}
+
+// ScrubReport describes damaged chunk records that the scrubber found.
+type ScrubReport struct {
+	// Table is the name of the table that holds the records.
+	Table string
+	// Damaged holds the hashes of the chunks whose records are damaged.
+	Damaged hash.HashSlice
+	// ReadErr is the error reading the records, if some couldn't be read.
+	// They aren't counted as damaged, or repaired, since a failed read says
+	// nothing about what's stored, and are checked again on a later pass.
+	ReadErr error
+	// Repairs holds what Repair did, if the scrubber called it.
+	Repairs []TableRepair
+	// RepairErr is the error Repair returned, e.g. if there were pending
+	// writes. The damage will be found, and repaired, on a later pass.
+	RepairErr error
+}
+
+// StartScrubber starts a goroutine that slowly re-reads the chunk records of
+// the tables in the store's manifest and checks them against their
+// checksums, so that latent corruption, e.g. of a disk, is found before a
+// read needs the damaged chunks. It checks a batch of records every Interval,
+// but only if the store hasn't been read from or written to since the last
+// interval, so that it only uses time the store would be idle. Once it has
+// checked every table, it starts over. StartScrubber returns a function that
+// stops the scrubber, and waits until it has.
+func (nbs *NomsBlockStore) StartScrubber(opts ScrubberOptions) (stop func()) {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultScrubInterval
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultScrubBatchSize
+	}
+
+	s := &scrubber{nbs: nbs, opts: opts}
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		ops := atomic.LoadUint64(&nbs.ops)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if current := atomic.LoadUint64(&nbs.ops); current != ops {
+				ops = current
+				continue
+			}
+			s.step()
+		}
+	}()
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+// scrubber keeps the position of a scrub: the next record of the |idx|th
+// table in the manifest, which should be |table|. Tables come and go as the
+// store is written to, conjoined and repaired, so if that table is gone, the
+// scrub moves on to whichever is the |idx|th table now.
+type scrubber struct {
+	nbs   *NomsBlockStore
+	opts  ScrubberOptions
+	idx   int
+	table addr
+	next  uint32
+}
+
+// step checks the next batch of records, and moves on to the next table once
+// it has checked all of the current table's.
+func (s *scrubber) step() {
+	src := s.current()
+	if src == nil {
+		return
+	}
+
+	if damaged, err := src.scrub(s.next, s.opts.BatchSize); len(damaged) > 0 || err != nil {
+		report := ScrubReport{Table: src.hash().String(), ReadErr: err}
+		for _, a := range damaged {
+			report.Damaged = append(report.Damaged, hash.Hash(a))
+		}
+		if s.opts.Repair && len(damaged) > 0 {
+			report.Repairs, report.RepairErr = s.nbs.Repair()
+		}
+		if s.opts.OnDamage != nil {
+			s.opts.OnDamage(report)
+		}
+	}
+
+	s.next += s.opts.BatchSize
+	if s.next >= src.count() {
+		s.idx++
+		s.table = addr{}
+	}
+}
+
+// current returns the table being scrubbed, or nil if there are none.
+func (s *scrubber) current() chunkSource {
+	s.nbs.mu.RLock()
+	tables := s.nbs.tables.upstream
+	s.nbs.mu.RUnlock()
+	if len(tables) == 0 {
+		return nil
+	}
+
+	for i, src := range tables {
+		if src.hash() == s.table {
+			s.idx = i
+			return src
+		}
+	}
+	s.idx %= len(tables)
+	s.table, s.next = tables[s.idx].hash(), 0
+	return tables[s.idx]
+}
@@ -10,6 +10,27 @@ import (
 	"github.com/attic-labs/noms/go/metrics"
 )
 
+// These cover table IO across all NomsBlockStores in the process, and are
+// only sampled when metrics.Enabled().
+var (
+	tableReadLatency    = metrics.RegisterTimeHistogram("nbs.TableReadLatency")
+	bytesPerTableRead   = metrics.RegisterByteHistogram("nbs.BytesPerTableRead")
+	tablePersistLatency = metrics.RegisterTimeHistogram("nbs.TablePersistLatency")
+)
+
+// These cover the caches of open table files and parsed table indices. Hits
+// and misses are only counted when metrics.Enabled(), but openTableFiles is
+// always kept up to date, so that it's right whenever it's read. The size of
+// the index cache is sampled with the current value every time it changes.
+var (
+	openTableFiles       = metrics.RegisterCounter("nbs.OpenTableFiles")
+	tableFileCacheHits   = metrics.RegisterCounter("nbs.TableFileCacheHits")
+	tableFileCacheMisses = metrics.RegisterCounter("nbs.TableFileCacheMisses")
+	indexCacheBytes      = metrics.RegisterByteHistogram("nbs.IndexCacheBytes")
+	indexCacheHits       = metrics.RegisterCounter("nbs.IndexCacheHits")
+	indexCacheMisses     = metrics.RegisterCounter("nbs.IndexCacheMisses")
+)
+
 type Stats struct {
 	GetLatency   metrics.Histogram
 	ChunksPerGet metrics.Histogram
@@ -5,6 +5,7 @@
 package nbs
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -12,6 +13,7 @@ import (
 	"path"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -30,7 +32,13 @@ import (
 
 const (
 	// StorageVersion is the version of the on-disk Noms Chunks Store data format.
-	StorageVersion = "4"
+	// Version 5 stores write tables with checksummed, versioned footers, which
+	// version 4 readers can't parse, so they refuse to open them.
+	StorageVersion = "5"
+	// legacyStorageVersion stores hold only tables with legacy footers, which
+	// are still readable. Writing to one upgrades its manifest to
+	// StorageVersion.
+	legacyStorageVersion = "4"
 
 	defaultMemTableSize uint64 = (1 << 20) * 128 // 128MB
 	defaultAWSReadLimit        = 1024
@@ -45,12 +53,57 @@ var (
 	globalFDCache    *fdCache
 )
 
+// TableCacheOptions bound the resources used to read tables by every store
+// opened with NewLocalStore or NewAWSStore. Zero values select the defaults.
+type TableCacheOptions struct {
+	// MaxOpenFiles is the number of table files to keep open. It's a target
+	// rather than a hard cap: files in use are never closed, so the cache
+	// may briefly grow past it.
+	MaxOpenFiles int
+	// IndexCacheSize is the number of bytes of parsed table indices to keep
+	// in memory.
+	IndexCacheSize uint64
+}
+
+// ErrTableCacheConfigured is returned by ConfigureTableCache once the shared
+// table caches have been created.
+var ErrTableCacheConfigured = errors.New("nbs: table caches are already configured")
+
+// ConfigureTableCache sets the limits of the table caches shared by stores
+// opened with NewLocalStore or NewAWSStore. It must be called before the
+// first such store is opened, and at most once.
+func ConfigureTableCache(opts TableCacheOptions) error {
+	configured := false
+	cacheOnce.Do(func() {
+		makeGlobalCachesWithOptions(opts)
+		configured = true
+	})
+	if !configured {
+		return ErrTableCacheConfigured
+	}
+	return nil
+}
+
 func makeGlobalCaches() {
-	globalIndexCache = newIndexCache(defaultIndexCacheSize)
-	globalFDCache = newFDCache(defaultMaxTables)
+	makeGlobalCachesWithOptions(TableCacheOptions{})
+}
+
+func makeGlobalCachesWithOptions(opts TableCacheOptions) {
+	if opts.MaxOpenFiles == 0 {
+		opts.MaxOpenFiles = defaultMaxTables
+	}
+	if opts.IndexCacheSize == 0 {
+		opts.IndexCacheSize = defaultIndexCacheSize
+	}
+	globalIndexCache = newIndexCache(opts.IndexCacheSize)
+	globalFDCache = newFDCache(opts.MaxOpenFiles)
 }
 
 type NomsBlockStore struct {
+	// ops counts reads and writes, so that the scrubber can tell when the
+	// store is idle. It's first so that it's 64-bit aligned for atomic.
+	ops uint64
+
 	mm           manifest
 	manifestLock addr
 	nomsVersion  string
@@ -64,7 +117,8 @@ type NomsBlockStore struct {
 	maxTables int
 	putCount  uint64
 
-	stats *Stats
+	stats      *Stats
+	contention chunks.RootContentionSubscribers
 }
 
 type AWSStoreFactory struct {
@@ -196,6 +250,7 @@ func newNomsBlockStore(mm manifest, ts tableSet, memTableSize uint64, maxTables
 }
 
 func (nbs *NomsBlockStore) Put(c chunks.Chunk) {
+	atomic.AddUint64(&nbs.ops, 1)
 	t1 := time.Now()
 	a := addr(c.Hash())
 	d.PanicIfFalse(nbs.addChunk(a, c.Data()))
@@ -224,6 +279,7 @@ func (nbs *NomsBlockStore) addChunk(h addr, data []byte) bool {
 }
 
 func (nbs *NomsBlockStore) Get(h hash.Hash) chunks.Chunk {
+	atomic.AddUint64(&nbs.ops, 1)
 	t1 := time.Now()
 	defer func() {
 		nbs.stats.GetLatency.SampleTime(time.Since(t1))
@@ -250,6 +306,7 @@ func (nbs *NomsBlockStore) Get(h hash.Hash) chunks.Chunk {
 }
 
 func (nbs *NomsBlockStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	atomic.AddUint64(&nbs.ops, 1)
 	t1 := time.Now()
 	reqs := toGetRecords(hashes)
 
@@ -340,7 +397,27 @@ func (nbs *NomsBlockStore) Count() uint32 {
 	return count + tables.count()
 }
 
+// HashesWithPrefix returns the hashes of all chunks in this store, including
+// those which have not yet been committed, whose String() begins with prefix.
+func (nbs *NomsBlockStore) HashesWithPrefix(prefix string) hash.HashSet {
+	lo, hi, ok := hash.MaybeParsePrefix(prefix)
+	d.PanicIfFalse(ok)
+
+	found := hash.HashSet{}
+	tables := func() tableSet {
+		nbs.mu.RLock()
+		defer nbs.mu.RUnlock()
+		if nbs.mt != nil {
+			nbs.mt.addrsInRange(addr(lo), addr(hi), found)
+		}
+		return nbs.tables
+	}()
+	tables.addrsInRange(addr(lo), addr(hi), found)
+	return found
+}
+
 func (nbs *NomsBlockStore) Has(h hash.Hash) bool {
+	atomic.AddUint64(&nbs.ops, 1)
 	t1 := time.Now()
 	defer func() {
 		nbs.stats.HasLatency.SampleTime(time.Since(t1))
@@ -359,6 +436,7 @@ func (nbs *NomsBlockStore) Has(h hash.Hash) bool {
 }
 
 func (nbs *NomsBlockStore) HasMany(hashes hash.HashSet) hash.HashSet {
+	atomic.AddUint64(&nbs.ops, 1)
 	t1 := time.Now()
 
 	reqs := toHasRecords(hashes)
@@ -452,6 +530,7 @@ func (nbs *NomsBlockStore) Commit(current, last hash.Hash) bool {
 		if err := nbs.updateManifest(current, last); err == nil {
 			return true
 		} else if err == errOptimisticLockFailedRoot || err == errLastRootMismatch {
+			nbs.contention.Notify(chunks.RootContention{Last: last, Attempted: current, Winner: nbs.Root()})
 			return false
 		}
 		time.Sleep(b.Duration())
@@ -510,6 +589,74 @@ func (nbs *NomsBlockStore) updateManifest(current, last hash.Hash) error {
 	return nil
 }
 
+func (nbs *NomsBlockStore) SubscribeRootContention(f func(chunks.RootContention)) (unsubscribe func()) {
+	return nbs.contention.Subscribe(f)
+}
+
+// TableRepair describes a table that Repair found to be damaged, and replaced.
+type TableRepair struct {
+	// Table is the name of the damaged table.
+	Table string
+	// Salvaged is the number of chunks copied into the replacement table.
+	Salvaged uint32
+	// Lost holds the hashes of the chunks that could not be recovered.
+	Lost hash.HashSlice
+}
+
+var (
+	errRepairPendingWrites = errors.New("nbs: commit pending writes before repairing")
+	errRepairManifestMoved = errors.New("nbs: manifest changed during repair, try again")
+)
+
+// Repair reads every chunk record in the store's tables and replaces each
+// table holding damaged records with a new one that contains only the intact
+// chunks. Lost chunks are reported, not recreated, so the store may still be
+// missing data reachable from its root; pulling from a replica can fill them
+// back in. A table whose index is damaged can't be repaired, as the index is
+// the only record of which chunks the table holds.
+func (nbs *NomsBlockStore) Repair() ([]TableRepair, error) {
+	nbs.mu.Lock()
+	defer nbs.mu.Unlock()
+	if (nbs.mt != nil && nbs.mt.count() > 0) || len(nbs.tables.novel) > 0 || len(nbs.tables.compacted) > 0 {
+		return nil, errRepairPendingWrites
+	}
+
+	var repairs []TableRepair
+	repaired := tableSet{p: nbs.tables.p, rl: nbs.tables.rl}
+	for _, src := range nbs.tables.upstream {
+		mt := newMemTable(src.uncompressedLen())
+		lost := src.salvage(mt)
+		if len(lost) == 0 {
+			repaired.upstream = append(repaired.upstream, src)
+			continue
+		}
+
+		repair := TableRepair{Table: src.hash().String(), Salvaged: mt.count()}
+		for _, a := range lost {
+			repair.Lost = append(repair.Lost, hash.Hash(a))
+		}
+		repairs = append(repairs, repair)
+		if mt.count() > 0 {
+			repaired.upstream = append(repaired.upstream, repaired.p.Persist(mt, nil, nbs.stats))
+		}
+	}
+	if len(repairs) == 0 {
+		return nil, nil
+	}
+
+	specs := repaired.ToSpecs()
+	nl := generateLockHash(nbs.root, specs)
+	lock, actual, tableNames := nbs.mm.Update(nbs.manifestLock, nl, specs, nbs.root, nil)
+	if nl != lock {
+		nbs.manifestLock, nbs.root = lock, actual
+		nbs.tables = nbs.tables.Rebase(tableNames)
+		return nil, errRepairManifestMoved
+	}
+	nbs.tables = repaired
+	nbs.nomsVersion, nbs.manifestLock = constants.NomsVersion, lock
+	return repairs, nil
+}
+
 func (nbs *NomsBlockStore) Version() string {
 	return nbs.nomsVersion
 }
@@ -71,12 +71,22 @@ import (
      - Hash Suffix M must correspond to Chunk Record M for 0 <= M <= N
 
    Footer:
+   +----------------------+----------------------------------------+-------------------------+--------------------------+------------------+
+   | (Uint32) Chunk Count | (Uint64) Total Uncompressed Chunk Data | (Uint32) Index Checksum | (Uint32) Format Version  | (8) Magic Number |
+   +----------------------+----------------------------------------+-------------------------+--------------------------+------------------+
+
+     -Total Uncompressed Chunk Data is the sum of the uncompressed byte lengths of all contained chunk byte slices.
+     -Index Checksum is the CRC32 (Castagnoli) of the entire Index.
+     -Format Version is currently 2.
+     -Magic Number is the first 8 bytes of the SHA256 hash of "https://github.com/attic-labs/nbs/v2".
+
+   Legacy (version 1) Footer:
    +----------------------+----------------------------------------+------------------+
    | (Uint32) Chunk Count | (Uint64) Total Uncompressed Chunk Data | (8) Magic Number |
    +----------------------+----------------------------------------+------------------+
 
-     -Total Uncompressed Chunk Data is the sum of the uncompressed byte lengths of all contained chunk byte slices.
      -Magic Number is the first 8 bytes of the SHA256 hash of "https://github.com/attic-labs/nbs".
+     -Version 1 tables carry no Index Checksum. They are still readable, but all newly written tables use the version 2 Footer. Since the version 1 Footer is shorter, readers fetch footerSize bytes from the end of a table and let the Magic Number decide how to parse them.
 
     NOTE: Unsigned integer quanities, hashes and hash suffix are all encoded big-endian
 
@@ -100,6 +110,10 @@ import (
   - Calculate the Offset of your desired Chunk Record: Sum(Lengths[0]...Lengths[Ordinal-1])
   - Load Lengths[Ordinal] bytes from Table[Offset]
   - Check the first 4 bytes of the loaded data against the last 4 bytes of your desired Hash. They should match, and the rest of the data is your Chunk data.
+
+  Chunk Data is always stored snappy-compressed, but a chunk's address is the hash of its uncompressed data, so the same chunk has the same address in every Table and in every other ChunkStore, and dedupes across them.
+
+  Every Chunk Record carries the CRC32 of its compressed Chunk Data, so a damaged region of a Table only affects the Chunk Records that overlap it. NomsBlockStore.Repair() rewrites such a Table, keeping only its intact Chunk Records.
 */
 
 const (
@@ -110,11 +124,14 @@ const (
 	uint32Size         uint64 = 4
 	ordinalSize        uint64 = uint32Size
 	lengthSize         uint64 = uint32Size
-	magicNumber               = "\xff\xb5\xd8\xc2\x24\x63\xee\x50"
+	magicNumber               = "\x3a\x00\x3c\x99\xb5\x48\xd0\x1b"
+	legacyMagicNumber         = "\xff\xb5\xd8\xc2\x24\x63\xee\x50"
 	magicNumberSize    uint64 = uint64(len(magicNumber))
-	footerSize                = uint32Size + uint64Size + magicNumberSize
-	prefixTupleSize           = addrPrefixSize + ordinalSize
 	checksumSize       uint64 = uint32Size
+	formatVersion      uint32 = 2
+	footerSize                = uint32Size + uint64Size + checksumSize + uint32Size + magicNumberSize
+	legacyFooterSize          = uint32Size + uint64Size + magicNumberSize
+	prefixTupleSize           = addrPrefixSize + ordinalSize
 	maxChunkLengthSize uint64 = binary.MaxVarintLen64
 	maxChunkSize       uint64 = 0xffffffff // Snappy won't compress slices bigger than this
 )
@@ -216,6 +233,12 @@ type chunkSource interface {
 	// opens a Reader to the first byte of the chunkData segment of this table.
 	reader() io.Reader
 	index() tableIndex
+
+	// salvage copies every intact chunk into |mt| and returns the addresses of those that are damaged.
+	salvage(mt *memTable) (lost addrSlice)
+
+	// scrub checks the records of up to |count| chunks, starting with the |from|th, and returns the addresses of those that are damaged, and the error reading them, if some couldn't be read.
+	scrub(from, count uint32) (damaged addrSlice, err error)
 }
 
 type chunkSources []chunkSource
@@ -11,6 +11,7 @@ import (
 	"sort"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/attic-labs/noms/go/util/sizecache"
 )
 
@@ -31,6 +32,10 @@ type tablePersister interface {
 	// tablePersister is responsible for managing the lifetime of the returned
 	// chunkSource. TODO: Is that actually true? Or can we get rid of explicit 'close'
 	Open(name addr, chunkCount uint32) chunkSource
+
+	// persistTable makes the already-encoded table |data|, named |name| and
+	// holding |chunkCount| chunks, durable.
+	persistTable(name addr, data []byte, chunkCount uint32) chunkSource
 }
 
 type indexCache struct {
@@ -44,6 +49,13 @@ func newIndexCache(size uint64) *indexCache {
 
 func (sic indexCache) get(name addr) (tableIndex, bool) {
 	idx, found := sic.cache.Get(name)
+	if metrics.Enabled() {
+		if found {
+			indexCacheHits.Inc()
+		} else {
+			indexCacheMisses.Inc()
+		}
+	}
 	if found {
 		return idx.(tableIndex), true
 	}
@@ -54,6 +66,9 @@ func (sic indexCache) get(name addr) (tableIndex, bool) {
 func (sic indexCache) put(name addr, idx tableIndex) {
 	indexSize := uint64(idx.chunkCount) * (addrSize + ordinalSize + lengthSize + uint64Size)
 	sic.cache.Add(name, indexSize, idx)
+	if size := sic.cache.Size(); metrics.Enabled() && size > 0 {
+		indexCacheBytes.Sample(size)
+	}
 }
 
 type chunkSourcesByAscendingCount chunkSources
@@ -157,7 +172,8 @@ func planCompaction(sources chunkSources, stats *Stats) (plan compactionPlan) {
 		pfxPos += ordinalSize
 	}
 
-	writeFooter(plan.mergedIndex[uint64(len(plan.mergedIndex))-footerSize:], plan.chunkCount, totalUncompressedData)
+	indexLen := uint64(len(plan.mergedIndex)) - footerSize
+	writeFooter(plan.mergedIndex[indexLen:], plan.mergedIndex[:indexLen], plan.chunkCount, totalUncompressedData)
 
 	stats.BytesPerConjoin.Sample(uint64(plan.totalCompressedData) + uint64(len(plan.mergedIndex)))
 	return plan
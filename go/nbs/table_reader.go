@@ -7,6 +7,8 @@ package nbs
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 	"sort"
 	"sync"
@@ -15,6 +17,7 @@ import (
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/golang/snappy"
 )
 
@@ -33,13 +36,46 @@ type tableReader struct {
 	blockSize uint64
 }
 
+var (
+	errBadTableFooter   = errors.New("nbs table footer is unrecognized")
+	errBadTableIndex    = errors.New("nbs table index is corrupt")
+	errShortTableBuffer = errors.New("nbs table buffer is too short to hold its index")
+)
+
 // parses a valid nbs tableIndex from a byte stream. |buff| must end with an NBS index and footer, though it may contain an unspecified number of bytes before that data. |tableIndex| doesn't keep alive any references to |buff|.
 func parseTableIndex(buff []byte) tableIndex {
+	index, err := tryParseTableIndex(buff)
+	d.PanicIfError(err)
+	return index
+}
+
+// tryParseTableIndex is like parseTableIndex, but reports an unrecognized footer or, for tables which carry an index checksum, a damaged index as an error.
+func tryParseTableIndex(buff []byte) (tableIndex, error) {
 	pos := uint64(len(buff))
+	if pos < legacyFooterSize {
+		return tableIndex{}, errShortTableBuffer
+	}
 
 	// footer
 	pos -= magicNumberSize
-	d.Chk.True(string(buff[pos:]) == magicNumber)
+	verifyIndex := false
+	var indexChecksum uint32
+	switch string(buff[pos:]) {
+	case magicNumber:
+		if pos+magicNumberSize < footerSize {
+			return tableIndex{}, errShortTableBuffer
+		}
+		pos -= uint32Size
+		if v := binary.BigEndian.Uint32(buff[pos:]); v != formatVersion {
+			return tableIndex{}, fmt.Errorf("nbs table format version %d is unsupported", v)
+		}
+		pos -= checksumSize
+		indexChecksum = binary.BigEndian.Uint32(buff[pos:])
+		verifyIndex = true
+	case legacyMagicNumber:
+	default:
+		return tableIndex{}, errBadTableFooter
+	}
 
 	// total uncompressed chunk data
 	pos -= uint64Size
@@ -49,6 +85,13 @@ func parseTableIndex(buff []byte) tableIndex {
 	chunkCount := binary.BigEndian.Uint32(buff[pos:])
 
 	// index
+	if pos < indexSize(chunkCount) {
+		return tableIndex{}, errShortTableBuffer
+	}
+	if verifyIndex && crc(buff[pos-indexSize(chunkCount):pos]) != indexChecksum {
+		return tableIndex{}, errBadTableIndex
+	}
+
 	suffixesSize := uint64(chunkCount) * addrSuffixSize
 	pos -= suffixesSize
 	suffixes := make([]byte, suffixesSize)
@@ -67,7 +110,7 @@ func parseTableIndex(buff []byte) tableIndex {
 		prefixes, offsets,
 		lengths, ordinals,
 		suffixes,
-	}
+	}, nil
 }
 
 func computeOffsets(count uint32, buff []byte) (lengths []uint32, offsets []uint64) {
@@ -124,6 +167,20 @@ func (ti tableIndex) ordinalSuffixMatches(ordinal uint32, h addr) bool {
 	return bytes.Compare(h[addrPrefixSize:], ti.suffixes[li:li+addrSuffixSize]) == 0
 }
 
+// inserts into |found| the address of every chunk in this index which lies within [lo, hi].
+func (ti tableIndex) addrsInRange(lo, hi addr, found hash.HashSet) {
+	hiPrefix := hi.Prefix()
+	for idx := ti.prefixIdx(lo.Prefix()); idx < ti.chunkCount && ti.prefixes[idx] <= hiPrefix; idx++ {
+		var a addr
+		binary.BigEndian.PutUint64(a[:], ti.prefixes[idx])
+		li := uint64(ti.prefixIdxToOrdinal(idx)) * addrSuffixSize
+		copy(a[addrPrefixSize:], ti.suffixes[li:li+addrSuffixSize])
+		if bytes.Compare(a[:], lo[:]) >= 0 && bytes.Compare(a[:], hi[:]) <= 0 {
+			found.Insert(hash.Hash(a))
+		}
+	}
+}
+
 // returns the ordinal of |h| if present. returns |ti.chunkCount| if absent
 func (ti tableIndex) lookupOrdinal(h addr) uint32 {
 	prefix := h.Prefix()
@@ -220,11 +277,15 @@ func (tr tableReader) get(h addr, stats *Stats) (data []byte) {
 	stats.BytesPerRead.Sample(length)
 	stats.ChunksPerRead.SampleLen(1)
 	stats.ReadLatency.SampleTime(time.Since(t1))
+	if metrics.Enabled() {
+		tableReadLatency.SampleTimeSince(t1)
+		bytesPerTableRead.Sample(length)
+	}
 
 	d.Chk.NoError(err)
 	d.Chk.True(n == int(length))
-	data = tr.parseChunk(buff)
-	d.Chk.True(data != nil)
+	data, err = parseChunk(h, buff)
+	d.PanicIfError(err)
 
 	return
 }
@@ -258,6 +319,10 @@ func (tr tableReader) readAtOffsets(
 	stats.BytesPerRead.Sample(readLength)
 	stats.ChunksPerRead.SampleLen(len(offsets))
 	stats.ReadLatency.SampleTime(time.Since(t1))
+	if metrics.Enabled() {
+		tableReadLatency.SampleTimeSince(t1)
+		bytesPerTableRead.Sample(readLength)
+	}
 
 	d.Chk.NoError(err)
 	d.Chk.True(uint64(n) == readLength)
@@ -267,7 +332,11 @@ func (tr tableReader) readAtOffsets(
 		localStart := rec.offset - readStart
 		localEnd := localStart + uint64(tr.lengths[rec.ordinal])
 		d.Chk.True(localEnd <= readLength)
-		data := tr.parseChunk(buff[localStart:localEnd])
+		data, err := parseChunk(*rec.a, buff[localStart:localEnd])
+		if err != nil {
+			// Don't let one damaged record keep the rest of the batch from being delivered; the caller will see this chunk as missing.
+			continue
+		}
 		c := chunks.NewChunkWithHash(hash.Hash(*rec.a), data)
 		foundChunks <- &c
 	}
@@ -391,17 +460,32 @@ func canReadAhead(fRec offsetRec, fLength uint32, readStart, readEnd, blockSize
 	return fRec.offset + uint64(fLength), true
 }
 
-// Fetches the byte stream of data logically encoded within the table starting at |pos|.
-func (tr tableReader) parseChunk(buff []byte) []byte {
+// CorruptChunkError is returned when the record holding a chunk fails its checksum or can't be decompressed.
+type CorruptChunkError struct {
+	Hash hash.Hash
+}
+
+func (e CorruptChunkError) Error() string {
+	return fmt.Sprintf("nbs: record for chunk %s is corrupt", e.Hash)
+}
+
+// Decodes the chunk record |buff|, which should hold the chunk addressed by |h|.
+func parseChunk(h addr, buff []byte) ([]byte, error) {
+	if uint64(len(buff)) <= checksumSize {
+		return nil, CorruptChunkError{hash.Hash(h)}
+	}
 	dataLen := uint64(len(buff)) - checksumSize
 
 	chksum := binary.BigEndian.Uint32(buff[dataLen:])
-	d.Chk.True(chksum == crc(buff[:dataLen]))
+	if chksum != crc(buff[:dataLen]) {
+		return nil, CorruptChunkError{hash.Hash(h)}
+	}
 
 	data, err := snappy.Decode(nil, buff[:dataLen])
-	d.Chk.NoError(err)
-
-	return data
+	if err != nil || len(data) == 0 {
+		return nil, CorruptChunkError{hash.Hash(h)}
+	}
+	return data, nil
 }
 
 func (tr tableReader) calcReads(reqs []getRecord, blockSize uint64) (reads int, remaining bool) {
@@ -443,15 +527,21 @@ func (tr tableReader) calcReads(reqs []getRecord, blockSize uint64) (reads int,
 	return
 }
 
-func (tr tableReader) extract(chunks chan<- extractRecord) {
-	// Build reverse lookup table from ordinal -> chunk hash
-	hashes := make(addrSlice, len(tr.prefixes))
-	for idx, prefix := range tr.prefixes {
-		ordinal := tr.prefixIdxToOrdinal(uint32(idx))
+// returns the address of every chunk in this index, in ordinal order.
+func (ti tableIndex) addrsByOrdinal() addrSlice {
+	hashes := make(addrSlice, len(ti.prefixes))
+	for idx, prefix := range ti.prefixes {
+		ordinal := ti.prefixIdxToOrdinal(uint32(idx))
 		binary.BigEndian.PutUint64(hashes[ordinal][:], prefix)
 		li := uint64(ordinal) * addrSuffixSize
-		copy(hashes[ordinal][addrPrefixSize:], tr.suffixes[li:li+addrSuffixSize])
+		copy(hashes[ordinal][addrPrefixSize:], ti.suffixes[li:li+addrSuffixSize])
 	}
+	return hashes
+}
+
+func (tr tableReader) extract(chunks chan<- extractRecord) {
+	// Build reverse lookup table from ordinal -> chunk hash
+	hashes := tr.addrsByOrdinal()
 	chunkLen := tr.offsets[tr.chunkCount-1] + uint64(tr.lengths[tr.chunkCount-1])
 	buff := make([]byte, chunkLen)
 	n, err := tr.r.ReadAt(buff, int64(tr.offsets[0]))
@@ -460,7 +550,9 @@ func (tr tableReader) extract(chunks chan<- extractRecord) {
 
 	sendChunk := func(i uint32) {
 		localOffset := tr.offsets[i] - tr.offsets[0]
-		chunks <- extractRecord{a: hashes[i], data: tr.parseChunk(buff[localOffset : localOffset+uint64(tr.lengths[i])])}
+		data, err := parseChunk(hashes[i], buff[localOffset:localOffset+uint64(tr.lengths[i])])
+		d.PanicIfError(err)
+		chunks <- extractRecord{a: hashes[i], data: data}
 	}
 
 	for i := uint32(0); i < tr.chunkCount; i++ {
@@ -468,6 +560,66 @@ func (tr tableReader) extract(chunks chan<- extractRecord) {
 	}
 }
 
+// salvage adds to |mt| every chunk in this table whose record can be read and decoded, and whose contents match its address. It returns the addresses of the chunks that couldn't be recovered. Records are read one at a time, so a damaged region only costs the chunks that overlap it.
+func (tr tableReader) salvage(mt *memTable) (lost addrSlice) {
+	hashes := tr.addrsByOrdinal()
+	for i := uint32(0); i < tr.chunkCount; i++ {
+		buff := make([]byte, tr.lengths[i])
+		var data []byte
+		if n, err := tr.r.ReadAt(buff, int64(tr.offsets[i])); n == len(buff) && (err == nil || err == io.EOF) {
+			data, _ = parseChunk(hashes[i], buff)
+		}
+		if data == nil || computeAddr(data) != hashes[i] {
+			lost = append(lost, hashes[i])
+			continue
+		}
+		d.PanicIfFalse(mt.addChunk(hashes[i], data))
+	}
+	return
+}
+
+// scrub checks the records of up to |count| chunks in this table, starting with the |from|th in the order they're stored, against their checksums, and returns the addresses of those that are damaged. The records are read at once, so a table can be scrubbed a few records at a time. If some of the records can't be read, the error is returned, and only the records that were read are checked, since a failed read says nothing about what's stored.
+func (tr tableReader) scrub(from, count uint32) (damaged addrSlice, err error) {
+	if from >= tr.chunkCount {
+		return nil, nil
+	}
+	end := tr.chunkCount
+	if count < end-from {
+		end = from + count
+	}
+
+	start := tr.offsets[from]
+	buff := make([]byte, tr.offsets[end-1]+uint64(tr.lengths[end-1])-start)
+	n, err := tr.r.ReadAt(buff, int64(start))
+	if n == len(buff) {
+		err = nil
+	} else if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	var bad []uint32
+	for i := from; i < end; i++ {
+		localOffset := tr.offsets[i] - start
+		recordEnd := localOffset + uint64(tr.lengths[i])
+		if recordEnd > uint64(n) {
+			break
+		}
+		if _, perr := parseChunk(addr{}, buff[localOffset:recordEnd]); perr != nil {
+			bad = append(bad, i)
+		}
+	}
+	if len(bad) == 0 {
+		return nil, err
+	}
+
+	// Only look up the addresses of the damaged records, which is costly for a large table, if there are any.
+	hashes := tr.addrsByOrdinal()
+	for _, i := range bad {
+		damaged = append(damaged, hashes[i])
+	}
+	return damaged, err
+}
+
 func (tr tableReader) reader() io.Reader {
 	return &readerAdapter{tr.r, 0}
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 )
 
 const concurrentCompactions = 5
@@ -125,6 +126,17 @@ func (ts tableSet) count() uint32 {
 	return f(ts.novel) + f(ts.compacted) + f(ts.upstream)
 }
 
+func (ts tableSet) addrsInRange(lo, hi addr, found hash.HashSet) {
+	f := func(css chunkSources) {
+		for _, src := range css {
+			src.index().addrsInRange(lo, hi, found)
+		}
+	}
+	f(ts.novel)
+	f(ts.compacted)
+	f(ts.upstream)
+}
+
 func (ts tableSet) uncompressedLen() uint64 {
 	f := func(css chunkSources) (data uint64) {
 		for _, haver := range css {
@@ -159,6 +171,25 @@ func (ts tableSet) Prepend(mt *memTable, stats *Stats) tableSet {
 	return newTs
 }
 
+// PrependSources adds already-persisted |sources| to an existing tableSet,
+// returning a new tableSet with them added as novel tables.
+func (ts tableSet) PrependSources(sources chunkSources) tableSet {
+	newTs := tableSet{
+		novel:      make(chunkSources, len(ts.novel)+len(sources)),
+		compacted:  make(chunkSources, len(ts.compacted)),
+		compactees: make(chunkSources, len(ts.compactees)),
+		upstream:   make(chunkSources, len(ts.upstream)),
+		p:          ts.p,
+		rl:         ts.rl,
+	}
+	copy(newTs.novel, sources)
+	copy(newTs.novel[len(sources):], ts.novel)
+	copy(newTs.compacted, ts.compacted)
+	copy(newTs.compactees, ts.compactees)
+	copy(newTs.upstream, ts.upstream)
+	return newTs
+}
+
 // Compact returns a new tableSet that's smaller than |ts|. It chooses to
 // compact the N smallest (by number of chunks) tables which can be compacted
 // into a new table such that upon replacing the N input tables, the
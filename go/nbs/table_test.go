@@ -6,7 +6,9 @@ package nbs
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"sort"
 	"testing"
 
@@ -350,3 +352,174 @@ func TestEmpty(t *testing.T) {
 
 	d.PanicIfError(nil)
 }
+
+func TestTableIndexChecksum(t *testing.T) {
+	assert := assert.New(t)
+
+	tableData, _ := buildTable([][]byte{[]byte("hello2"), []byte("goodbye2")})
+	_, err := tryParseTableIndex(tableData)
+	assert.NoError(err)
+
+	// Flip a bit in the first prefix tuple.
+	indexStart := uint64(len(tableData)) - footerSize - indexSize(2)
+	tableData[indexStart] ^= 0x01
+	_, err = tryParseTableIndex(tableData)
+	assert.Equal(errBadTableIndex, err)
+	assert.Panics(func() { parseTableIndex(tableData) })
+
+	// Clobber the magic number.
+	tableData[len(tableData)-1] ^= 0x01
+	_, err = tryParseTableIndex(tableData)
+	assert.Equal(errBadTableFooter, err)
+}
+
+func toLegacyTable(tableData []byte) []byte {
+	footer := tableData[uint64(len(tableData))-footerSize:]
+	legacy := append([]byte{}, tableData[:uint64(len(tableData))-footerSize]...)
+	legacy = append(legacy, footer[:uint32Size+uint64Size]...)
+	return append(legacy, legacyMagicNumber...)
+}
+
+func TestLegacyTableFooter(t *testing.T) {
+	assert := assert.New(t)
+
+	chunks := [][]byte{
+		[]byte("hello2"),
+		[]byte("goodbye2"),
+		[]byte("badbye2"),
+	}
+	tableData, _ := buildTable(chunks)
+	legacyData := toLegacyTable(tableData)
+
+	// Readers fetch footerSize bytes from the end, so a legacy index may be preceded by some chunk data.
+	tr := newTableReader(parseTableIndex(legacyData), bytes.NewReader(legacyData), fileBlockSize)
+	assertChunksInReader(chunks, tr, assert)
+	for _, c := range chunks {
+		assert.Equal(string(c), string(tr.get(computeAddr(c), &Stats{})))
+	}
+}
+
+// corruptChunk flips a bit in the compressed data of the record holding |c|.
+func corruptChunk(tableData []byte, c []byte) {
+	index := parseTableIndex(tableData)
+	ordinal := index.lookupOrdinal(computeAddr(c))
+	tableData[index.offsets[ordinal]] ^= 0x01
+}
+
+func TestGetManySkipsCorruptChunk(t *testing.T) {
+	assert := assert.New(t)
+
+	inputs := [][]byte{
+		[]byte("hello2"),
+		[]byte("goodbye2"),
+		[]byte("badbye2"),
+	}
+	tableData, _ := buildTable(inputs)
+	corruptChunk(tableData, inputs[1])
+	tr := newTableReader(parseTableIndex(tableData), bytes.NewReader(tableData), fileBlockSize)
+
+	getBatch := make([]getRecord, len(inputs))
+	for i, c := range inputs {
+		a := computeAddr(c)
+		getBatch[i] = getRecord{&a, a.Prefix(), false}
+	}
+	sort.Sort(getRecordByPrefix(getBatch))
+
+	wg := &sync.WaitGroup{}
+	chunkChan := make(chan *chunks.Chunk, len(getBatch))
+	tr.getMany(getBatch, chunkChan, wg, &Stats{})
+	wg.Wait()
+	close(chunkChan)
+
+	found := hash.HashSet{}
+	for c := range chunkChan {
+		found.Insert(c.Hash())
+	}
+	assert.Equal(hash.NewHashSet(hash.Hash(computeAddr(inputs[0])), hash.Hash(computeAddr(inputs[2]))), found)
+
+	err := d.Try(func() { tr.get(computeAddr(inputs[1]), &Stats{}) }, CorruptChunkError{})
+	assert.Equal(CorruptChunkError{hash.Hash(computeAddr(inputs[1]))}, d.Unwrap(err))
+}
+
+func TestSalvage(t *testing.T) {
+	assert := assert.New(t)
+
+	chunks := [][]byte{
+		[]byte("hello2"),
+		[]byte("goodbye2"),
+		[]byte("badbye2"),
+	}
+	tableData, _ := buildTable(chunks)
+	tr := newTableReader(parseTableIndex(tableData), bytes.NewReader(tableData), fileBlockSize)
+
+	mt := newMemTable(tr.uncompressedLen())
+	assert.Empty(tr.salvage(mt))
+	assert.EqualValues(3, mt.count())
+
+	corruptChunk(tableData, chunks[2])
+	mt = newMemTable(tr.uncompressedLen())
+	assert.Equal(addrSlice{computeAddr(chunks[2])}, tr.salvage(mt))
+	assertChunksInReader(chunks[:2], mt, assert)
+	assertChunksNotInReader(chunks[2:], mt, assert)
+}
+
+func TestScrub(t *testing.T) {
+	assert := assert.New(t)
+
+	chunks := [][]byte{
+		[]byte("hello2"),
+		[]byte("goodbye2"),
+		[]byte("badbye2"),
+	}
+	tableData, _ := buildTable(chunks)
+	tr := newTableReader(parseTableIndex(tableData), bytes.NewReader(tableData), fileBlockSize)
+
+	scrub := func(tr tableReader, from, count uint32) addrSlice {
+		damaged, err := tr.scrub(from, count)
+		assert.NoError(err)
+		return damaged
+	}
+	assert.Empty(scrub(tr, 0, 3))
+
+	corruptChunk(tableData, chunks[2])
+	assert.Empty(scrub(tr, 0, 2))
+	assert.Equal(addrSlice{computeAddr(chunks[2])}, scrub(tr, 2, 1))
+	assert.Equal(addrSlice{computeAddr(chunks[2])}, scrub(tr, 1, 10))
+	assert.Empty(scrub(tr, 3, 1))
+
+	// Records that can't be read aren't damaged, as far as anyone knows.
+	index := parseTableIndex(tableData)
+	readErr := errors.New("read failed")
+	tr = newTableReader(index, failingReaderAt{bytes.NewReader(tableData), int64(index.offsets[1]) + 1, readErr}, fileBlockSize)
+	damaged, err := tr.scrub(0, 3)
+	assert.Equal(readErr, err)
+	assert.Empty(damaged)
+
+	// Those that were read before the error are still checked.
+	tr = newTableReader(index, failingReaderAt{bytes.NewReader(tableData), int64(index.offsets[2]) + 1, readErr}, fileBlockSize)
+	damaged, err = tr.scrub(1, 2)
+	assert.Equal(readErr, err)
+	assert.Empty(damaged)
+	tr = newTableReader(index, failingReaderAt{bytes.NewReader(tableData), int64(index.offsets[2]) + int64(index.lengths[2]), readErr}, fileBlockSize)
+	damaged, err = tr.scrub(0, 3)
+	assert.NoError(err)
+	assert.Equal(addrSlice{computeAddr(chunks[2])}, damaged)
+}
+
+// failingReaderAt reads from |r| up to |failAt|, and fails with |err| beyond it.
+type failingReaderAt struct {
+	r      io.ReaderAt
+	failAt int64
+	err    error
+}
+
+func (fr failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off+int64(len(p)) <= fr.failAt {
+		return fr.r.ReadAt(p, off)
+	}
+	if off >= fr.failAt {
+		return 0, fr.err
+	}
+	n, _ := fr.r.ReadAt(p[:fr.failAt-off], off)
+	return n, fr.err
+}
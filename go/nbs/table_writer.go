@@ -21,6 +21,7 @@ type tableWriter struct {
 	pos                   uint64
 	totalUncompressedData uint64
 	prefixes              prefixIndexSlice // TODO: This is in danger of exploding memory
+	indexStart            uint64
 	blockHash             hash.Hash
 
 	snapper snappyEncoder
@@ -128,6 +129,7 @@ func (hs prefixIndexSlice) Swap(i, j int)      { hs[i], hs[j] = hs[j], hs[i] }
 
 func (tw *tableWriter) writeIndex() {
 	sort.Sort(tw.prefixes)
+	tw.indexStart = tw.pos
 
 	pfxScratch := [addrPrefixSize]byte{}
 
@@ -161,10 +163,11 @@ func (tw *tableWriter) writeIndex() {
 }
 
 func (tw *tableWriter) writeFooter() {
-	tw.pos += writeFooter(tw.buff[tw.pos:], uint32(len(tw.prefixes)), tw.totalUncompressedData)
+	tw.pos += writeFooter(tw.buff[tw.pos:], tw.buff[tw.indexStart:tw.pos], uint32(len(tw.prefixes)), tw.totalUncompressedData)
 }
 
-func writeFooter(dst []byte, chunkCount uint32, uncData uint64) (consumed uint64) {
+// writeFooter writes a current-version footer describing |index| into |dst|.
+func writeFooter(dst, index []byte, chunkCount uint32, uncData uint64) (consumed uint64) {
 	// chunk count
 	binary.BigEndian.PutUint32(dst[consumed:], chunkCount)
 	consumed += uint32Size
@@ -173,6 +176,14 @@ func writeFooter(dst []byte, chunkCount uint32, uncData uint64) (consumed uint64
 	binary.BigEndian.PutUint64(dst[consumed:], uncData)
 	consumed += uint64Size
 
+	// index checksum
+	binary.BigEndian.PutUint32(dst[consumed:], crc(index))
+	consumed += checksumSize
+
+	// format version
+	binary.BigEndian.PutUint32(dst[consumed:], formatVersion)
+	consumed += uint32Size
+
 	// magic number
 	copy(dst[consumed:], magicNumber)
 	consumed += magicNumberSize
@@ -323,7 +323,7 @@ func (tc *TypeConverter) structToGQLObject(nomsType *types.Type) *graphql.Object
 					fieldType = graphql.NewNonNull(fieldType)
 				}
 
-				fields[name] = &graphql.Field{
+				fields[graphQLFieldName(name)] = &graphql.Field{
 					Type: fieldType,
 					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 						if field, ok := p.Source.(types.Struct).MaybeGet(name); ok {
@@ -385,7 +385,7 @@ func (tc *TypeConverter) structToGQLInputObject(nomsType *types.Type) (graphql.I
 				if !optional {
 					fieldType = graphql.NewNonNull(fieldType)
 				}
-				fields[name] = &graphql.InputObjectFieldConfig{
+				fields[graphQLFieldName(name)] = &graphql.InputObjectFieldConfig{
 					Type: fieldType,
 				}
 			})
@@ -676,6 +676,16 @@ func (tc *TypeConverter) mapEntryToGraphQLInputObject(keyType, valueType graphql
 	}))
 }
 
+// graphQLFieldName returns the name under which the Noms struct field |name|
+// is exposed in GraphQL. Field names which aren't valid GraphQL names, e.g.
+// ones containing spaces, are escaped using types.EscapeStructField.
+func graphQLFieldName(name string) string {
+	if types.IsValidStructFieldName(name) {
+		return name
+	}
+	return types.EscapeStructField(name)
+}
+
 // DefaultNameFunc returns the GraphQL type name for a Noms type.
 func DefaultNameFunc(nomsType *types.Type, isInputType bool) string {
 	if isInputType {
@@ -994,8 +1004,9 @@ func InputToNomsValue(arg interface{}, nomsType *types.Type) types.Value {
 		data := make(types.StructData, desc.Len())
 		m := arg.(map[string]interface{})
 		desc.IterFields(func(name string, t *types.Type, optional bool) {
-			if m[name] != nil || !optional {
-				data[name] = InputToNomsValue(m[name], t)
+			gqlName := graphQLFieldName(name)
+			if m[gqlName] != nil || !optional {
+				data[name] = InputToNomsValue(m[gqlName], t)
 			}
 		})
 		return types.NewStruct(desc.Name, data)
@@ -0,0 +1,434 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package codegen generates Go types that values of a Noms type can be
+// marshaled to and from with package marshal, so that code which reads and
+// writes Noms data needn't declare them by hand.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Options configure Generate.
+type Options struct {
+	// Package is the name of the package the code is generated for. The
+	// default is "main".
+	Package string
+	// Name is the name of the Go type generated for the Noms type itself,
+	// when it isn't a struct with a name of its own. The default is "Value".
+	Name string
+}
+
+// Generate returns Go source, formatted as by gofmt, that declares a Go type
+// for |t| and for each of the structs it holds, which Marshal and Unmarshal
+// convert to and from Noms values of those types:
+//
+//   - A Noms struct becomes a Go struct named after it, with an exported field
+//     for each of its fields, tagged with the Noms field's name. Optional
+//     fields are pointers, so that they're left out when nil. Structs without
+//     a name become anonymous Go structs, unless they're |t| itself or a
+//     member of a union.
+//   - Bool, Number and String become bool, float64 and string. Blob, Ref, Type
+//     and Value are kept as the Noms values types.Blob, types.Ref, *types.Type
+//     and types.Value.
+//   - A List becomes a slice. A Map becomes a Go map, and a Set a
+//     map[T]struct{} tagged "set", if their keys are of a type Go can compare.
+//     Otherwise, and for Sets that aren't held directly by a struct field,
+//     they're kept as a types.Map or a types.Set.
+//   - A union of structs becomes an interface that each of them implements,
+//     and a RegisterTypes function is generated that registers them with a
+//     marshal.TypeRegistry, whose Unmarshal can then decode them. A union of
+//     Bools, Numbers and Strings becomes an interface{}. Other unions are kept
+//     as a types.Value.
+//   - A cycle becomes a pointer to, or a collection of, the enclosing struct.
+//
+// Marshal names structs after their Go type, so those whose Noms names aren't
+// valid exported Go identifiers, or spell the same identifier as another's,
+// are named differently in Go; such structs need MarshalOpt's StructName, or
+// a TypeRegistry's RegisterName, to keep their Noms names.
+func Generate(t *types.Type, opts Options) (src []byte, err error) {
+	if opts.Package == "" {
+		opts.Package = "main"
+	}
+	if opts.Name == "" {
+		opts.Name = "Value"
+	}
+
+	g := &generator{
+		structs:  map[hash.Hash]*goStruct{},
+		unions:   map[string]*goUnion{},
+		used:     map[string]bool{"RegisterTypes": true},
+		imported: map[string]bool{},
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(codegenError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	if t.TargetKind() == types.StructKind {
+		g.structType(t, opts.Name)
+	} else {
+		g.reserve(opts.Name)
+		gt := g.goType(t, opts.Name)
+		// Describe spells structs over several lines, so the type is set off
+		// as a preformatted block of the comment.
+		desc := "//\t" + strings.Replace(t.Describe(), "\n", "\n//\t", -1)
+		g.decls = append(g.decls, fmt.Sprintf("// %s is the Go type of values of the Noms type\n//\n%s\ntype %s %s\n", opts.Name, desc, opts.Name, gt.expr))
+	}
+
+	return g.source(opts.Package)
+}
+
+type codegenError struct {
+	error
+}
+
+func fail(format string, args ...interface{}) {
+	panic(codegenError{fmt.Errorf(format, args...)})
+}
+
+// goType describes the Go type generated for a Noms type.
+type goType struct {
+	expr string
+	// comparable is set if Go map keys can be of the type.
+	comparable bool
+	// nilable is set for pointers and interfaces, which are left out of a
+	// Noms struct when nil.
+	nilable bool
+	iface   bool
+}
+
+type goStruct struct {
+	name     string
+	nomsName string
+	// comparable is known once the struct's fields have been generated.
+	done       bool
+	comparable bool
+}
+
+type goUnion struct {
+	name    string
+	members []string
+}
+
+type generator struct {
+	decls    []string
+	structs  map[hash.Hash]*goStruct
+	unions   map[string]*goUnion
+	order    []*goUnion
+	used     map[string]bool
+	imported map[string]bool
+	// enclosing holds the structs being generated, innermost last, which
+	// cycles refer to.
+	enclosing []*goStruct
+}
+
+var (
+	boolType   = goType{expr: "bool", comparable: true}
+	numberType = goType{expr: "float64", comparable: true}
+	stringType = goType{expr: "string", comparable: true}
+)
+
+func (g *generator) typesType(expr string, nilable bool) goType {
+	g.imported["github.com/attic-labs/noms/go/types"] = true
+	return goType{expr: expr, nilable: nilable, iface: expr == "types.Value"}
+}
+
+// goType returns the Go type for |t|. |hint| names the Go types generated
+// for structs without names, and for unions.
+func (g *generator) goType(t *types.Type, hint string) goType {
+	switch desc := t.Desc.(type) {
+	case types.StructDesc:
+		if desc.Name == "" {
+			// Anonymous Go structs marshal to Noms structs without a name.
+			body, comparable := g.structBody(t, &goStruct{name: hint})
+			return goType{expr: body, comparable: comparable}
+		}
+		s := g.structType(t, hint)
+		return goType{expr: s.name, comparable: s.done && s.comparable}
+	case types.CycleDesc:
+		return goType{expr: g.cycleTarget(string(desc)).name}
+	case types.CompoundDesc:
+		elems := desc.ElemTypes
+		switch t.TargetKind() {
+		case types.ListKind:
+			elem := g.goType(elems[0], hint+"Elem")
+			return goType{expr: "[]" + elem.expr}
+		case types.SetKind:
+			return g.typesType("types.Set", false)
+		case types.MapKind:
+			key := g.goType(elems[0], hint+"Key")
+			value := g.goType(elems[1], hint+"Value")
+			if !key.comparable {
+				return g.typesType("types.Map", false)
+			}
+			return goType{expr: "map[" + key.expr + "]" + value.expr}
+		case types.RefKind:
+			return g.typesType("types.Ref", false)
+		case types.UnionKind:
+			return g.unionType(elems, hint)
+		}
+	}
+
+	switch t.TargetKind() {
+	case types.BoolKind:
+		return boolType
+	case types.NumberKind:
+		return numberType
+	case types.StringKind:
+		return stringType
+	case types.BlobKind:
+		return g.typesType("types.Blob", false)
+	case types.TypeKind:
+		return g.typesType("*types.Type", true)
+	case types.ValueKind:
+		return g.typesType("types.Value", true)
+	}
+	fail("Unsupported Noms type %s", t.Describe())
+	panic("unreachable")
+}
+
+// unionType returns the Go type for a union of |elems|.
+func (g *generator) unionType(elems []*types.Type, hint string) goType {
+	if len(elems) == 0 {
+		return g.typesType("types.Value", true)
+	}
+
+	primitives, structs := true, true
+	for _, et := range elems {
+		switch et.TargetKind() {
+		case types.BoolKind, types.NumberKind, types.StringKind:
+			structs = false
+		case types.StructKind, types.CycleKind:
+			primitives = false
+		default:
+			return g.typesType("types.Value", true)
+		}
+	}
+	if primitives {
+		return goType{expr: "interface{}", comparable: true, nilable: true, iface: true}
+	}
+	if !structs {
+		return g.typesType("types.Value", true)
+	}
+
+	members := make([]string, len(elems))
+	for i, et := range elems {
+		// Members have methods, so they need names, even if their Noms
+		// structs have none.
+		if et.TargetKind() == types.StructKind {
+			members[i] = g.structType(et, fmt.Sprintf("%s%d", hint, i)).name
+		} else {
+			members[i] = g.goType(et, hint).expr
+		}
+	}
+	key := strings.Join(members, "|")
+	u, ok := g.unions[key]
+	if !ok {
+		u = &goUnion{g.reserve(hint), members}
+		g.unions[key] = u
+		g.order = append(g.order, u)
+	}
+	return goType{expr: u.name, nilable: true, iface: true}
+}
+
+// cycleTarget returns the innermost struct being generated that's named
+// |name|, which a cycle of that name refers to.
+func (g *generator) cycleTarget(name string) *goStruct {
+	for i := len(g.enclosing) - 1; i >= 0; i-- {
+		if g.enclosing[i].nomsName == name {
+			return g.enclosing[i]
+		}
+	}
+	fail("Unresolved cycle to struct %s", name)
+	panic("unreachable")
+}
+
+// structType returns the Go struct for the struct type |t|, declaring it the
+// first time it's needed.
+func (g *generator) structType(t *types.Type, hint string) *goStruct {
+	h := t.Hash()
+	if s, ok := g.structs[h]; ok {
+		return s
+	}
+
+	desc := t.Desc.(types.StructDesc)
+	name := identifier(desc.Name)
+	if name == "" {
+		name = hint
+	}
+	s := &goStruct{name: g.reserve(name), nomsName: desc.Name}
+	g.structs[h] = s
+
+	body, comparable := g.structBody(t, s)
+	s.done, s.comparable = true, comparable
+	comment := "named " + desc.Name
+	if desc.Name == "" {
+		comment = "without a name"
+	}
+	g.decls = append(g.decls, fmt.Sprintf("// %s is the Go type of Noms structs %s.\ntype %s %s\n", s.name, comment, s.name, body))
+	return s
+}
+
+// structBody returns the Go struct type, "struct { ... }", for the struct
+// type |t|, which |s| names, and whether Go can compare it.
+func (g *generator) structBody(t *types.Type, s *goStruct) (string, bool) {
+	g.enclosing = append(g.enclosing, s)
+	defer func() { g.enclosing = g.enclosing[:len(g.enclosing)-1] }()
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("struct {\n")
+	comparable := true
+	fieldNames := map[string]bool{}
+	t.Desc.(types.StructDesc).IterFields(func(name string, ft *types.Type, optional bool) {
+		if !types.IsValidStructFieldName(name) {
+			fail("Struct field name %q can't be marshaled", name)
+		}
+		fieldName := uniqueName(identifier(name), "Field", fieldNames)
+		gt, tags := g.fieldType(ft, optional, s.name+fieldName)
+		comparable = comparable && gt.comparable
+		fmt.Fprintf(buf, "%s %s `noms:\"%s\"`\n", fieldName, gt.expr, strings.Join(append([]string{name}, tags...), ","))
+	})
+	buf.WriteString("}")
+	return buf.String(), comparable
+}
+
+// fieldType returns the Go type of a struct field of type |t|, and the
+// options of its tag.
+func (g *generator) fieldType(t *types.Type, optional bool, hint string) (gt goType, tags []string) {
+	switch t.TargetKind() {
+	case types.SetKind:
+		elem := g.goType(t.Desc.(types.CompoundDesc).ElemTypes[0], hint+"Elem")
+		if elem.comparable {
+			gt = goType{expr: "map[" + elem.expr + "]struct{}"}
+		} else {
+			gt = goType{expr: "[]" + elem.expr}
+		}
+		tags = append(tags, "set")
+	case types.CycleKind:
+		// A struct can't hold itself, only a pointer to itself.
+		gt = g.goType(t, hint)
+		gt.expr, gt.nilable = "*"+gt.expr, true
+	default:
+		gt = g.goType(t, hint)
+	}
+
+	if optional {
+		if gt.iface {
+			tags = append(tags, "omitempty")
+		} else if !gt.nilable {
+			gt = goType{expr: "*" + gt.expr, nilable: true}
+		}
+	}
+	return gt, tags
+}
+
+// reserve returns |name|, or |name| with a number added if it's already the
+// name of a Go type, and reserves it.
+func (g *generator) reserve(name string) string {
+	return uniqueName(name, "Value", g.used)
+}
+
+func uniqueName(name, fallback string, used map[string]bool) string {
+	if name == "" {
+		name = fallback
+	}
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	used[unique] = true
+	return unique
+}
+
+// identifier returns an exported Go identifier for the Noms name |name|,
+// formed by upper casing the first character of each run of letters and
+// digits in it and dropping everything else.
+func identifier(name string) string {
+	buf := &bytes.Buffer{}
+	start := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			start = true
+			continue
+		}
+		if buf.Len() == 0 && unicode.IsDigit(r) {
+			buf.WriteByte('N')
+		}
+		if start {
+			r = unicode.ToUpper(r)
+		}
+		buf.WriteRune(r)
+		start = false
+	}
+	return buf.String()
+}
+
+func (g *generator) source(pkg string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by noms codegen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+
+	if len(g.order) > 0 {
+		g.imported["github.com/attic-labs/noms/go/marshal"] = true
+	}
+	if len(g.imported) > 0 {
+		imports := make([]string, 0, len(g.imported))
+		for path := range g.imported {
+			imports = append(imports, strconv.Quote(path))
+		}
+		sort.Strings(imports)
+		fmt.Fprintf(buf, "import (\n%s\n)\n\n", strings.Join(imports, "\n"))
+	}
+
+	for _, decl := range g.decls {
+		buf.WriteString(decl)
+		buf.WriteString("\n")
+	}
+
+	if len(g.order) > 0 {
+		registered := map[string]bool{}
+		register := &bytes.Buffer{}
+		for _, u := range g.order {
+			method := "is" + u.name
+			fmt.Fprintf(buf, "// %s is a union of %s.\ntype %s interface {\n\t%s()\n}\n\n", u.name, strings.Join(u.members, ", "), u.name, method)
+			for _, m := range u.members {
+				fmt.Fprintf(buf, "func (%s) %s() {}\n", m, method)
+				if !registered[m] {
+					registered[m] = true
+					fmt.Fprintf(register, "\tr.RegisterName(%q, %s{})\n", g.nomsName(m), m)
+				}
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("// RegisterTypes registers the structs that the union interfaces above hold\n// with |r|, so that r.Unmarshal can decode them.\n")
+		fmt.Fprintf(buf, "func RegisterTypes(r *marshal.TypeRegistry) {\n%s}\n", register.String())
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// nomsName returns the Noms name of the Go struct |name|.
+func (g *generator) nomsName(name string) string {
+	for _, s := range g.structs {
+		if s.name == name {
+			return s.nomsName
+		}
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package codegen
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/nomdl"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func generate(t *testing.T, typ string, opts Options) string {
+	nt, err := nomdl.ParseType(typ)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	src, err := Generate(nt, opts)
+	assert.NoError(t, err)
+	return string(src)
+}
+
+func TestGenerateStruct(t *testing.T) {
+	assert.Equal(t, "// Code generated by noms codegen. DO NOT EDIT.\n"+`
+package people
+
+import (
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Person is the Go type of Noms structs named Person.
+type Person struct {
+	Address struct {
+		City string `+"`"+`noms:"city"`+"`"+`
+	} `+"`"+`noms:"address"`+"`"+`
+	Age     *float64             `+"`"+`noms:"age"`+"`"+`
+	Emails  map[string]struct{}  `+"`"+`noms:"emails,set"`+"`"+`
+	Extra   types.Value          `+"`"+`noms:"extra,omitempty"`+"`"+`
+	Friends []Person             `+"`"+`noms:"friends"`+"`"+`
+	Photo   types.Blob           `+"`"+`noms:"photo"`+"`"+`
+	Scores  map[string]float64   `+"`"+`noms:"scores"`+"`"+`
+	Tags    map[float64]struct{} `+"`"+`noms:"tags,set"`+"`"+`
+}
+`, generate(t, `struct Person {
+		address: struct {city: String},
+		age?: Number,
+		emails: Set<String>,
+		extra?: Value,
+		friends: List<Cycle<Person>>,
+		photo: Blob,
+		scores: Map<String, Number>,
+		tags: Set<Number>,
+	}`, Options{Package: "people"}))
+}
+
+func TestGenerateUnion(t *testing.T) {
+	src := generate(t, `struct Shape {
+		fill: String | Number,
+		outline: struct Circle {r: Number} | struct Square {side: Number},
+	}`, Options{})
+	assert.Contains(t, src, "package main\n")
+	assert.Contains(t, src, "\tFill    interface{}  `noms:\"fill\"`\n")
+	assert.Contains(t, src, "\tOutline ShapeOutline `noms:\"outline\"`\n")
+	assert.Contains(t, src, "type ShapeOutline interface {\n\tisShapeOutline()\n}\n")
+	assert.Contains(t, src, "func (Circle) isShapeOutline() {}\n")
+	assert.Contains(t, src, "func (Square) isShapeOutline() {}\n")
+	assert.Contains(t, src, "func RegisterTypes(r *marshal.TypeRegistry) {\n\tr.RegisterName(\"Circle\", Circle{})\n\tr.RegisterName(\"Square\", Square{})\n}\n")
+}
+
+func TestGenerateNames(t *testing.T) {
+	src := generate(t, `Map<struct my_key {id: Number}, struct Value {id_num: Number, IdNum: String}>`, Options{Name: "Index"})
+	// Struct keys Go can compare keep Maps as Go maps.
+	assert.Contains(t, src, "type MyKey struct {\n\tId float64 `noms:\"id\"`\n}\n")
+	assert.Contains(t, src, "type Value struct {\n\tIdNum  string  `noms:\"IdNum\"`\n\tIdNum2 float64 `noms:\"id_num\"`\n}\n")
+	assert.Contains(t, src, "type Index map[MyKey]Value\n")
+
+	// Structs whose names spell the same Go identifier get different ones.
+	src = generate(t, `struct S {a: struct my_key {x: Number}, b: struct MyKey {x: String}}`, Options{})
+	assert.Contains(t, src, "type MyKey struct {\n\tX float64 `noms:\"x\"`\n}\n")
+	assert.Contains(t, src, "type MyKey2 struct {\n\tX string `noms:\"x\"`\n}\n")
+	assert.Contains(t, src, "\tA MyKey  `noms:\"a\"`\n\tB MyKey2 `noms:\"b\"`\n")
+}
+
+func TestGenerateInvalidFieldName(t *testing.T) {
+	_, err := Generate(types.MakeStructType("S", types.StructField{Name: "a-b", Type: types.NumberType}), Options{})
+	assert.Error(t, err)
+}
+
+// roundTripMain unmarshals a Shape into the generated Go types and marshals
+// it back, and fails unless the result is the Shape it started with.
+const roundTripMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/go/marshal"
+	"github.com/attic-labs/noms/go/types"
+)
+
+func main() {
+	child := types.NewStruct("Shape", types.StructData{
+		"children": types.NewList(),
+		"fill":     types.String("red"),
+		"name":     types.String("b"),
+		"outline":  types.NewStruct("Square", types.StructData{"side": types.Number(1)}),
+		"tags":     types.NewSet(),
+	})
+	v := types.NewStruct("Shape", types.StructData{
+		"children": types.NewList(child),
+		"fill":     types.Number(1),
+		"name":     types.String("a"),
+		"outline":  types.NewStruct("Circle", types.StructData{"r": types.Number(2)}),
+		"size":     types.Number(3),
+		"tags":     types.NewSet(types.String("x"), types.String("y")),
+	})
+
+	r := marshal.NewTypeRegistry()
+	RegisterTypes(r)
+	var s Shape
+	if err := r.Unmarshal(v, &s); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	back, err := r.Marshal(s)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !back.Equals(v) {
+		fmt.Println(types.EncodedValue(back))
+		os.Exit(1)
+	}
+}
+`
+
+func TestGeneratedRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test that builds the generated code in short mode.")
+	}
+	assert := assert.New(t)
+
+	src := generate(t, `struct Shape {
+		children: List<Cycle<Shape>>,
+		fill: String | Number,
+		name: String,
+		outline: struct Circle {r: Number} | struct Square {side: Number},
+		size?: Number,
+		tags: Set<String>,
+	}`, Options{})
+
+	dir, err := ioutil.TempDir("", "codegen")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	gen, main := filepath.Join(dir, "shape.go"), filepath.Join(dir, "main.go")
+	assert.NoError(ioutil.WriteFile(gen, []byte(src), 0644))
+	assert.NoError(ioutil.WriteFile(main, []byte(roundTripMain), 0644))
+
+	out, err := exec.Command("go", "run", gen, main).CombinedOutput()
+	assert.NoError(err, string(out))
+}
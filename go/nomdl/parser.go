@@ -28,7 +28,7 @@ type ParserOptions struct {
 func New(r io.Reader, options ParserOptions) *Parser {
 	s := scanner.Scanner{}
 	s.Filename = options.Filename
-	s.Mode = scanner.ScanIdents | scanner.ScanComments | scanner.SkipComments
+	s.Mode = scanner.ScanIdents | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
 	s.Init(r)
 	lex := lexer{scanner: &s}
 	return &Parser{&lex}
@@ -174,9 +174,7 @@ func (p *Parser) parseStructType() *types.Type {
 	fields := []types.StructField{}
 
 	for p.lex.peek() != '}' {
-		p.lex.eat(scanner.Ident)
-
-		fieldName := p.lex.tokenText()
+		fieldName := p.parseStructFieldName()
 		optional := p.lex.eatIf('?')
 		p.lex.eat(':')
 		typ := p.parseType()
@@ -196,6 +194,22 @@ func (p *Parser) parseStructType() *types.Type {
 	return types.MakeStructType(name, fields...)
 }
 
+// parseStructFieldName parses either a bare field name or, for names which
+// aren't valid identifiers, a quoted one as written by
+// types.QuoteStructFieldName.
+func (p *Parser) parseStructFieldName() string {
+	tok := p.lex.next()
+	if tok == scanner.String {
+		name, err := types.UnquoteStructFieldName(p.lex.tokenText())
+		if err != nil {
+			raiseSyntaxError(err.Error(), p.lex.pos())
+		}
+		return name
+	}
+	p.lex.check(scanner.Ident, tok)
+	return p.lex.tokenText()
+}
+
 func (p *Parser) parseSingleElemType(allowEmptyUnion bool) *types.Type {
 	p.lex.eat('<')
 	if allowEmptyUnion && p.lex.eatIf('>') {
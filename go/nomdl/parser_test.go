@@ -154,6 +154,19 @@ func TestStructTypes(t *testing.T) {
 		types.StructField{"y", types.StringType, false},
 	))
 
+	assertParseType(t, `struct S {
+	        "first name": String,
+	        "1st"?: Number,
+	        "qu\"ote": Bool,
+	}`, types.MakeStructType("S",
+		types.StructField{Name: "1st", Type: types.NumberType, Optional: true},
+		types.StructField{Name: "first name", Type: types.StringType},
+		types.StructField{Name: `qu"ote`, Type: types.BoolType},
+	))
+
+	assertParseError(t, `struct S { "a\qb": Bool }`, `Invalid field name "a\qb": Only ", \, \n, \r, \t and \u can be escaped, example:1:18`)
+	assertParseError(t, "struct S { \"\xff\": Bool }", "Invalid field name \"\xff\": Quoted string isn't valid UTF-8, example:1:15")
+
 	assertParseError(t, `struct S {
 	        x: Number
 	        y: String
@@ -178,6 +191,13 @@ func TestStructTypes(t *testing.T) {
 	assertParseError(t, `struct`, `Unexpected token EOF, expected "{", example:1:7`)
 }
 
+func TestQuotedStructFieldNamesRoundTrip(t *testing.T) {
+	for _, name := range []string{`a"b`, `a\b`, "a\nb", "\r\t", "\x00\x1f\x7f\u0085", "ümlaut", "💩", "first name"} {
+		typ := types.MakeStructType("S", types.StructField{Name: name, Type: types.BoolType})
+		assertParseType(t, typ.Describe(), typ)
+	}
+}
+
 func TestUnionTypes(t *testing.T) {
 	assertParseType(t, "Blob | Bool", types.MakeUnionType(types.BlobType, types.BoolType))
 	assertParseType(t, "Bool | Number | String", types.MakeUnionType(types.BoolType, types.NumberType, types.StringType))
@@ -8,13 +8,20 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
-var datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String() + ")")
+var (
+	datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String() + ")")
+	hashCapturePrefixRe    = regexp.MustCompile("^([0-9a-v]{1," + strconv.Itoa(hash.StringLen) + "})([.[]|$)")
+)
+
+// MinHashPrefixLen is the shortest abbreviated hash NewAbsolutePath accepts.
+const MinHashPrefixLen = 4
 
 // AbsolutePath describes the location of a Value within a Noms database.
 //
@@ -25,41 +32,50 @@ var datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String()
 // https://github.com/attic-labs/noms/blob/master/doc/spelling.md.
 type AbsolutePath struct {
 	// Dataset is the dataset this AbsolutePath is rooted at. Only one of
-	// Dataset and Hash should be set.
+	// Dataset, Hash and HashPrefix should be set.
 	Dataset string
-	// Hash is the hash this AbsolutePath is rooted at. Only one of Dataset and
-	// Hash should be set.
+	// Hash is the hash this AbsolutePath is rooted at. Only one of Dataset,
+	// Hash and HashPrefix should be set.
 	Hash hash.Hash
+	// HashPrefix is an abbreviated hash this AbsolutePath is rooted at, e.g.
+	// "a1b2c3" for "#a1b2c3". It is resolved to a Hash within a database by
+	// ResolveHashPrefix. Only one of Dataset, Hash and HashPrefix should be
+	// set.
+	HashPrefix string
 	// Path is the relative path from Dataset or Hash. This can be empty. In
 	// that case, the AbsolutePath describes the value at either Dataset or
 	// Hash.
 	Path types.Path
 }
 
-// NewAbsolutePath attempts to parse 'str' and return an AbsolutePath.
+// NewAbsolutePath attempts to parse 'str' and return an AbsolutePath. A
+// hash root may be abbreviated to its first MinHashPrefixLen or more
+// characters, in which case HashPrefix is set rather than Hash.
 func NewAbsolutePath(str string) (AbsolutePath, error) {
 	if len(str) == 0 {
 		return AbsolutePath{}, errors.New("Empty path")
 	}
 
 	var h hash.Hash
+	var hashPrefix string
 	var dataset string
 	var pathStr string
 
 	if str[0] == '#' {
 		tail := str[1:]
-		if len(tail) < hash.StringLen {
+		hashParts := hashCapturePrefixRe.FindStringSubmatch(tail)
+		if hashParts == nil || len(hashParts[1]) < MinHashPrefixLen {
 			return AbsolutePath{}, errors.New("Invalid hash: " + tail)
 		}
 
-		hashStr := tail[:hash.StringLen]
+		hashStr := hashParts[1]
 		if h2, ok := hash.MaybeParse(hashStr); ok {
 			h = h2
 		} else {
-			return AbsolutePath{}, errors.New("Invalid hash: " + hashStr)
+			hashPrefix = hashStr
 		}
 
-		pathStr = tail[hash.StringLen:]
+		pathStr = tail[len(hashStr):]
 	} else {
 		datasetParts := datasetCapturePrefixRe.FindStringSubmatch(str)
 		if datasetParts == nil {
@@ -71,7 +87,7 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 	}
 
 	if len(pathStr) == 0 {
-		return AbsolutePath{Hash: h, Dataset: dataset}, nil
+		return AbsolutePath{Hash: h, HashPrefix: hashPrefix, Dataset: dataset}, nil
 	}
 
 	path, err := types.ParsePath(pathStr)
@@ -79,11 +95,36 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 		return AbsolutePath{}, err
 	}
 
-	return AbsolutePath{Hash: h, Dataset: dataset, Path: path}, nil
+	return AbsolutePath{Hash: h, HashPrefix: hashPrefix, Dataset: dataset, Path: path}, nil
+}
+
+// ResolveHashPrefix returns a copy of 'p' whose HashPrefix, if it has one,
+// is replaced by the Hash of the single chunk in 'db' that it abbreviates. It
+// returns an error if the prefix matches no chunk or more than one, see
+// types.ValueStore.ResolveHashPrefix.
+func (p AbsolutePath) ResolveHashPrefix(db datas.Database) (AbsolutePath, error) {
+	if p.HashPrefix == "" {
+		return p, nil
+	}
+	h, err := db.ResolveHashPrefix(p.HashPrefix)
+	if err != nil {
+		return AbsolutePath{}, err
+	}
+	p.Hash, p.HashPrefix = h, ""
+	return p, nil
 }
 
-// Resolve returns the Value reachable by 'p' in 'db'.
+// Resolve returns the Value reachable by 'p' in 'db'. If 'p' has a
+// HashPrefix that cannot be resolved, Resolve returns nil; use
+// ResolveHashPrefix first to find out why.
 func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
+	if p.HashPrefix != "" {
+		var err error
+		if p, err = p.ResolveHashPrefix(db); err != nil {
+			return nil
+		}
+	}
+
 	if len(p.Dataset) > 0 {
 		var ok bool
 		ds := db.GetDataset(p.Dataset)
@@ -103,7 +144,7 @@ func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
 }
 
 func (p AbsolutePath) IsEmpty() bool {
-	return p.Dataset == "" && p.Hash.IsEmpty()
+	return p.Dataset == "" && p.Hash.IsEmpty() && p.HashPrefix == ""
 }
 
 func (p AbsolutePath) String() (str string) {
@@ -115,6 +156,8 @@ func (p AbsolutePath) String() (str string) {
 		str = p.Dataset
 	} else if !p.Hash.IsEmpty() {
 		str = "#" + p.Hash.String()
+	} else if p.HashPrefix != "" {
+		str = "#" + p.HashPrefix
 	} else {
 		panic("Unreachable")
 	}
@@ -133,6 +176,11 @@ func ReadAbsolutePaths(db datas.Database, paths ...string) ([]types.Value, error
 			return nil, fmt.Errorf("Invalid input path '%s'", ps)
 		}
 
+		p, err = p.ResolveHashPrefix(db)
+		if err != nil {
+			return nil, fmt.Errorf("Input path '%s' could not be resolved: %s", ps, err)
+		}
+
 		v := p.Resolve(db)
 		if v == nil {
 			return nil, fmt.Errorf("Input path '%s' does not exist in database", ps)
@@ -28,6 +28,8 @@ func TestAbsolutePathToAndFromString(t *testing.T) {
 	h := types.Number(42).Hash() // arbitrary hash
 	test(fmt.Sprintf("foo.bar[#%s]", h.String()))
 	test(fmt.Sprintf("#%s.bar[42]", h.String()))
+	test(fmt.Sprintf("#%s", h.String()[:MinHashPrefixLen]))
+	test(fmt.Sprintf("#%s[42]", h.String()[:10]))
 }
 
 func TestAbsolutePaths(t *testing.T) {
@@ -72,6 +74,8 @@ func TestAbsolutePaths(t *testing.T) {
 	resolvesTo(s1, "#"+s1.Hash().String())
 	resolvesTo(s0, "#"+list.Hash().String()+"[0]")
 	resolvesTo(s1, "#"+list.Hash().String()+"[1]")
+	resolvesTo(list, "#"+list.Hash().String()[:12])
+	resolvesTo(s1, "#"+list.Hash().String()[:12]+"[1]")
 
 	resolvesTo(nil, "foo")
 	resolvesTo(nil, "foo.parents")
@@ -79,6 +83,7 @@ func TestAbsolutePaths(t *testing.T) {
 	resolvesTo(nil, "foo.value[0]")
 	resolvesTo(nil, "#"+types.String("baz").Hash().String())
 	resolvesTo(nil, "#"+types.String("baz").Hash().String()+"[0]")
+	resolvesTo(nil, "#"+types.String("baz").Hash().String()[:12])
 }
 
 func TestReadAbsolutePaths(t *testing.T) {
@@ -109,6 +114,44 @@ func TestReadAbsolutePaths(t *testing.T) {
 	assert.Equal("Input path 'invalid.monkey' does not exist in database", err.Error())
 }
 
+func TestAbsolutePathHashPrefix(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := datas.NewDatabase(storage.NewView())
+
+	// Find two values whose hashes share a prefix.
+	byPrefix := map[string]types.Value{}
+	var a, b types.Value
+	for i := 0; a == nil; i++ {
+		v := types.Number(i)
+		db.WriteValue(v)
+		prefix := v.Hash().String()[:MinHashPrefixLen]
+		if other, ok := byPrefix[prefix]; ok {
+			a, b = other, v
+		}
+		byPrefix[prefix] = v
+	}
+
+	p, err := NewAbsolutePath("#" + a.Hash().String()[:hash.StringLen-1])
+	assert.NoError(err)
+	assert.Equal(a.Hash().String()[:hash.StringLen-1], p.HashPrefix)
+	p, err = p.ResolveHashPrefix(db)
+	assert.NoError(err)
+	assert.Equal(AbsolutePath{Hash: a.Hash()}, p)
+
+	prefix := a.Hash().String()[:MinHashPrefixLen]
+	p, err = NewAbsolutePath("#" + prefix)
+	assert.NoError(err)
+	_, err = p.ResolveHashPrefix(db)
+	assert.IsType(&types.AmbiguousHashPrefixError{}, err)
+	assert.Contains(err.Error(), b.Hash().String())
+	assert.Nil(p.Resolve(db))
+
+	vals, err := ReadAbsolutePaths(db, "#"+prefix)
+	assert.Nil(vals)
+	assert.Contains(err.Error(), "Input path '#"+prefix+"' could not be resolved: ")
+}
+
 func TestAbsolutePathParseErrors(t *testing.T) {
 	assert := assert.New(t)
 
@@ -124,6 +167,8 @@ func TestAbsolutePathParseErrors(t *testing.T) {
 	test(".foo.bar.baz", "Invalid dataset name: .foo.bar.baz")
 	test("#", "Invalid hash: ")
 	test("#abc", "Invalid hash: abc")
+	test("#abcdx", "Invalid hash: abcdx")
+	test("#"+strings.Repeat("a", hash.StringLen+1), "Invalid hash: "+strings.Repeat("a", hash.StringLen+1))
 	invHash := strings.Repeat("z", hash.StringLen)
 	test("#"+invHash, "Invalid hash: "+invHash)
 }
@@ -15,11 +15,12 @@ import (
 	flag "github.com/juju/gnuflag"
 )
 
-const CommitMetaDateFormat = "2006-01-02T15:04:05-0700"
+const CommitMetaDateFormat = datas.CommitMetaDateFormat
 
 var (
 	commitMetaDate            string
 	commitMetaMessage         string
+	commitMetaAuthor          string
 	commitMetaKeyValueStrings string
 	commitMetaKeyValuePaths   string
 )
@@ -28,6 +29,7 @@ var (
 func RegisterCommitMetaFlags(flags *flag.FlagSet) {
 	flags.StringVar(&commitMetaDate, "date", "", "alias for -meta 'date=<date>'. '<date>' must be iso8601-formatted. If '<date>' is empty, it defaults to the current date.")
 	flags.StringVar(&commitMetaMessage, "message", "", "alias for -meta 'message=<message>'")
+	flags.StringVar(&commitMetaAuthor, "author", "", "alias for -meta 'author=<author>'. By convention, '<author>' is formatted as 'Name <email>'.")
 	flags.StringVar(&commitMetaKeyValueStrings, "meta", "", "'<key>=<value>' - creates a metadata field called 'key' set to 'value'. Value should be human-readable encoded.")
 	flags.StringVar(&commitMetaKeyValuePaths, "meta-p", "", "'<key>=<path>' - creates a metadata field called 'key' set to the value at <path>")
 }
@@ -37,6 +39,7 @@ func RegisterCommitMetaFlags(flags *flag.FlagSet) {
 // Database is used only if commitMetaKeyValuePaths are provided on the command line and values need to be resolved.
 // Date should be ISO 8601 format (see CommitMetaDateFormat), if empty the current date is used.
 // The values passed as command line arguments (if any) are merged with the values provided as function arguments.
+// The author given by --author is used unless an author is given by -meta or in keyValueStrings.
 func CreateCommitMetaStruct(db datas.Database, date, message string, keyValueStrings map[string]string, keyValuePaths map[string]types.Value) (types.Struct, error) {
 	metaValues := types.StructData{}
 
@@ -106,6 +109,10 @@ func CreateCommitMetaStruct(db datas.Database, date, message string, keyValueStr
 	}
 	metaValues["date"] = types.String(date)
 
+	if _, ok := metaValues["author"]; !ok && commitMetaAuthor != "" {
+		metaValues["author"] = types.String(commitMetaAuthor)
+	}
+
 	if message != "" {
 		metaValues["message"] = types.String(message)
 	} else if commitMetaMessage != "" {
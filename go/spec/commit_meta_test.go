@@ -86,6 +86,31 @@ func TestCreateCommitMetaStructFromFlagsAndArgs(t *testing.T) {
 	assert.Equal(types.String("v4p4"), meta.Get("k4"))
 }
 
+func TestCreateCommitMetaStructAuthor(t *testing.T) {
+	assert := assert.New(t)
+	commitMetaDate = ""
+	commitMetaMessage = ""
+	commitMetaKeyValueStrings = ""
+	commitMetaKeyValuePaths = ""
+	commitMetaAuthor = "Ada <ada@example.com>"
+	defer func() { commitMetaAuthor = "" }()
+
+	meta, err := CreateCommitMetaStruct(nil, "", "", nil, nil)
+	assert.NoError(err)
+	assert.Equal(types.String(commitMetaAuthor), meta.Get("author"))
+
+	// An author given by -meta or by the caller wins over --author.
+	commitMetaKeyValueStrings = "author=Grace"
+	meta, err = CreateCommitMetaStruct(nil, "", "", nil, nil)
+	assert.NoError(err)
+	assert.Equal(types.String("Grace"), meta.Get("author"))
+
+	commitMetaKeyValueStrings = ""
+	meta, err = CreateCommitMetaStruct(nil, "", "", map[string]string{"author": "Hedy"}, nil)
+	assert.NoError(err)
+	assert.Equal(types.String("Hedy"), meta.Get("author"))
+}
+
 func TestCreateCommitMetaStructBadDate(t *testing.T) {
 	assert := assert.New(t)
 
@@ -12,7 +12,9 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
@@ -23,10 +25,24 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
+	humanize "github.com/dustin/go-humanize"
 )
 
 const Separator = "::"
 
+// Query parameters of http(s) specs which set SpecOptions.HTTP.
+const (
+	bwLimitParam      = "bwlimit"
+	maxRequestsParam  = "maxrequests"
+	verifyChunksParam = "verifychunks"
+	retriesParam      = "retries"
+	retryBackoffParam = "retrybackoff"
+	timeoutParam      = "timeout"
+)
+
+// Query parameter of aws specs which sets SpecOptions.MaxChunkSize.
+const maxChunkSizeParam = "maxchunksize"
+
 var datasetRe = regexp.MustCompile("^" + datas.DatasetRe.String() + "$")
 
 // SpecOptions customize Spec behavior.
@@ -34,15 +50,37 @@ type SpecOptions struct {
 	// Authorization token for requests. For example, if the database is HTTP
 	// this will used for an `Authorization: Bearer ${authorization}` header.
 	Authorization string
+
+	// HTTP configures connections to HTTP databases, e.g. to limit their
+	// bandwidth. Its fields can also be set with the "bwlimit" (e.g. "2MB",
+	// in bytes per second), "maxrequests", "verifychunks", "retries",
+	// "retrybackoff" and "timeout" (durations, e.g. "30s") query parameters
+	// of http(s) database specs, e.g. "https://example.com/db?bwlimit=2MB::ds".
+	// Fields set here take precedence.
+	HTTP datas.HTTPChunkStoreOptions
+
+	// SSH configures connections to ssh databases, e.g.
+	// "ssh://user@example.com/path/to/db", which run noms serve --stdio on
+	// the remote host over ssh. Its HTTP field is ignored in favour of the
+	// one above, which the query parameters of ssh specs also set.
+	SSH datas.SSHOptions
+
+	// MaxChunkSize, if set, is the size in bytes of the largest chunk that
+	// can be written to the database, as chunks.NewSizeLimitedChunkStore
+	// describes. It can also be set with the "maxchunksize" query parameter
+	// of aws database specs, e.g. "aws://table:bucket/db?maxchunksize=400KB".
+	// It's ignored for http(s) and ssh databases, which limit the chunks
+	// they store themselves.
+	MaxChunkSize uint64
 }
 
 // Spec locates a Noms database, dataset, or value globally.
 type Spec struct {
-	// Protocol is one of "mem", "ldb", "http", or "https".
+	// Protocol is one of "mem", "nbs", "http", "https", "aws" or "ssh".
 	Protocol string
 
 	// DatabaseName is the name of the Spec's database, which is the string after
-	// "protocol:". http/https specs include their leading "//" characters.
+	// "protocol:". http/https/ssh specs include their leading "//" characters.
 	DatabaseName string
 
 	// Options are the SpecOptions that the Spec was constructed with.
@@ -61,6 +99,17 @@ func newSpec(dbSpec string, opts SpecOptions) (Spec, error) {
 		return Spec{}, err
 	}
 
+	if protocol == "http" || protocol == "https" || protocol == "ssh" {
+		if _, err := parseHTTPParams(protocol+":"+dbName, &opts.HTTP); err != nil {
+			return Spec{}, err
+		}
+	}
+	if protocol == "aws" {
+		if err := parseMaxChunkSize(protocol+":"+dbName, &opts); err != nil {
+			return Spec{}, err
+		}
+	}
+
 	return Spec{
 		Protocol:     protocol,
 		DatabaseName: dbName,
@@ -139,6 +188,18 @@ func ForPathOpts(spec string, opts SpecOptions) (Spec, error) {
 	return sp, nil
 }
 
+// ForRemoteRef returns a Spec for the target of |r|, whose GetValue resolves
+// it. Close the Spec when done with the value.
+func ForRemoteRef(r datas.RemoteRef) (Spec, error) {
+	return ForRemoteRefOpts(r, SpecOptions{})
+}
+
+// ForRemoteRefOpts returns a Spec for the target of |r|, whose GetValue
+// resolves it. Close the Spec when done with the value.
+func ForRemoteRefOpts(r datas.RemoteRef, opts SpecOptions) (Spec, error) {
+	return ForPathOpts(r.Database+Separator+"#"+r.Target.String(), opts)
+}
+
 func (sp Spec) String() string {
 	s := sp.Protocol
 	if s != "mem" {
@@ -167,15 +228,15 @@ func (sp Spec) GetDatabase() datas.Database {
 // time. If there is no ChunkStore, for example remote databases, returns nil.
 func (sp Spec) NewChunkStore() chunks.ChunkStore {
 	switch sp.Protocol {
-	case "http", "https":
+	case "http", "https", "ssh":
 		return nil
 	case "aws":
-		return parseAWSSpec(sp.Href())
+		return sp.limitChunkSize(parseAWSSpec(sp.Href()))
 	case "nbs":
-		return nbs.NewLocalStore(sp.DatabaseName, 1<<28)
+		return sp.limitChunkSize(nbs.NewLocalStore(sp.DatabaseName, 1<<28))
 	case "mem":
 		storage := &chunks.MemoryStorage{}
-		return storage.NewView()
+		return sp.limitChunkSize(storage.NewView())
 	}
 	panic("unreachable")
 }
@@ -210,11 +271,11 @@ func (sp Spec) GetValue() (val types.Value) {
 
 // Href treats the Protocol and DatabaseName as a URL, and returns its href.
 // For example, the spec http://example.com/path::ds returns
-// "http://example.com/path". If the Protocol is not "http" or "http", returns
-// an empty string.
+// "http://example.com/path". If the Protocol is not "http", "https", "aws" or
+// "ssh", returns an empty string.
 func (sp Spec) Href() string {
 	switch proto := sp.Protocol; proto {
-	case "http", "https", "aws":
+	case "http", "https", "aws", "ssh":
 		return proto + ":" + sp.DatabaseName
 	default:
 		return ""
@@ -264,19 +325,117 @@ func (sp Spec) Close() error {
 func (sp Spec) createDatabase() datas.Database {
 	switch sp.Protocol {
 	case "http", "https":
-		return datas.NewDatabase(datas.NewHTTPChunkStore(sp.Href(), sp.Options.Authorization))
+		opts := sp.Options.HTTP
+		href, _ := parseHTTPParams(sp.Href(), &opts)
+		return datas.NewDatabase(datas.NewHTTPChunkStoreWithOptions(href, sp.Options.Authorization, opts))
+	case "ssh":
+		opts := sp.Options.SSH
+		opts.HTTP = sp.Options.HTTP
+		href, _ := parseHTTPParams(sp.Href(), &opts.HTTP)
+		return datas.NewDatabase(datas.NewSSHChunkStore(href, opts))
 	case "aws":
-		return datas.NewDatabase(parseAWSSpec(sp.Href()))
+		return datas.NewDatabase(sp.limitChunkSize(parseAWSSpec(sp.Href())))
 	case "nbs":
 		os.Mkdir(sp.DatabaseName, 0777)
-		return datas.NewDatabase(nbs.NewLocalStore(sp.DatabaseName, 1<<28))
+		return datas.NewDatabase(sp.limitChunkSize(nbs.NewLocalStore(sp.DatabaseName, 1<<28)))
 	case "mem":
 		storage := &chunks.MemoryStorage{}
-		return datas.NewDatabase(storage.NewView())
+		return datas.NewDatabase(sp.limitChunkSize(storage.NewView()))
 	}
 	panic("unreachable")
 }
 
+// limitChunkSize returns cs, wrapped so that chunks larger than
+// Options.MaxChunkSize can't be put, if it's set.
+func (sp Spec) limitChunkSize(cs chunks.ChunkStore) chunks.ChunkStore {
+	if sp.Options.MaxChunkSize == 0 {
+		return cs
+	}
+	return chunks.NewSizeLimitedChunkStore(cs, sp.Options.MaxChunkSize)
+}
+
+// parseMaxChunkSize sets opts.MaxChunkSize from the query parameter of href,
+// unless it's set already.
+func parseMaxChunkSize(href string, opts *SpecOptions) error {
+	u, err := url.Parse(href)
+	if err != nil {
+		return err
+	}
+	if mcs := u.Query().Get(maxChunkSizeParam); mcs != "" {
+		n, err := humanize.ParseBytes(mcs)
+		if err != nil || n == 0 {
+			return fmt.Errorf("Invalid %s in %s", maxChunkSizeParam, href)
+		}
+		if opts.MaxChunkSize == 0 {
+			opts.MaxChunkSize = n
+		}
+	}
+	return nil
+}
+
+// parseHTTPParams fills in the zero fields of opts from the query parameters
+// of href, and returns href without them.
+func parseHTTPParams(href string, opts *datas.HTTPChunkStoreOptions) (string, error) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	if bw := q.Get(bwLimitParam); bw != "" {
+		n, err := humanize.ParseBytes(bw)
+		if err != nil || n == 0 {
+			return "", fmt.Errorf("Invalid %s in %s", bwLimitParam, href)
+		}
+		if opts.BytesPerSecond == 0 {
+			opts.BytesPerSecond = n
+		}
+	}
+	if mr := q.Get(maxRequestsParam); mr != "" {
+		n, err := strconv.Atoi(mr)
+		if err != nil || n <= 0 {
+			return "", fmt.Errorf("Invalid %s in %s", maxRequestsParam, href)
+		}
+		if opts.MaxConcurrentRequests == 0 {
+			opts.MaxConcurrentRequests = n
+		}
+	}
+	if vc := q.Get(verifyChunksParam); vc != "" {
+		verify, err := strconv.ParseBool(vc)
+		if err != nil {
+			return "", fmt.Errorf("Invalid %s in %s", verifyChunksParam, href)
+		}
+		opts.SkipChunkVerification = opts.SkipChunkVerification || !verify
+	}
+	if r := q.Get(retriesParam); r != "" {
+		n, err := strconv.Atoi(r)
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("Invalid %s in %s", retriesParam, href)
+		}
+		if opts.Retries == 0 {
+			opts.Retries = n
+		}
+	}
+	for param, field := range map[string]*time.Duration{retryBackoffParam: &opts.RetryBackoff, timeoutParam: &opts.RequestTimeout} {
+		if ds := q.Get(param); ds != "" {
+			dur, err := time.ParseDuration(ds)
+			if err != nil || dur <= 0 {
+				return "", fmt.Errorf("Invalid %s in %s", param, href)
+			}
+			if *field == 0 {
+				*field = dur
+			}
+		}
+	}
+	q.Del(bwLimitParam)
+	q.Del(maxRequestsParam)
+	q.Del(verifyChunksParam)
+	q.Del(retriesParam)
+	q.Del(retryBackoffParam)
+	q.Del(timeoutParam)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 	if len(spec) == 0 {
 		err = fmt.Errorf("Empty spec")
@@ -301,7 +460,7 @@ func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 	case "nbs":
 		protocol, name = parts[0], parts[1]
 
-	case "http", "https", "aws":
+	case "http", "https", "aws", "ssh":
 		u, perr := url.Parse(spec)
 		if perr != nil {
 			err = perr
@@ -309,6 +468,10 @@ func parseDatabaseSpec(spec string) (protocol, name string, err error) {
 			err = fmt.Errorf("%s has empty host", spec)
 		} else if parts[0] == "aws" && u.Path == "" {
 			err = fmt.Errorf("%s does not specify a database ID", spec)
+		} else if parts[0] == "ssh" && (u.Path == "" || u.Path == "/") {
+			err = fmt.Errorf("%s does not specify a database path", spec)
+		} else if parts[0] == "ssh" && datas.CheckSSHURL(u) != nil {
+			err = datas.CheckSSHURL(u)
 		} else {
 			protocol, name = parts[0], parts[1]
 		}
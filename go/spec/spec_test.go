@@ -10,7 +10,9 @@ import (
 	"os"
 	"path"
 	"testing"
+	"time"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/types"
@@ -149,6 +151,32 @@ func TestNBSDatabaseSpec(t *testing.T) {
 	run("nbs:")
 }
 
+func TestForRemoteRef(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "spec_test")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	s := types.String("remote")
+	func() {
+		db := datas.NewDatabase(nbs.NewLocalStore(tmpDir, 8*(1<<20)))
+		defer db.Close()
+		_, err := db.CommitValue(db.GetDataset("ds"), db.WriteValue(s))
+		assert.NoError(err)
+	}()
+
+	sp, err := ForRemoteRef(datas.NewRemoteRef(tmpDir, s.Hash()))
+	assert.NoError(err)
+	defer sp.Close()
+	assert.Equal("nbs", sp.Protocol)
+	assert.Equal(tmpDir, sp.DatabaseName)
+	assert.True(s.Equals(sp.GetValue()))
+
+	_, err = ForRemoteRef(datas.NewRemoteRef("bogus:db", s.Hash()))
+	assert.Error(err)
+}
+
 // Skip LDB dataset and path tests: the database behaviour is tested in
 // TestLDBDatabaseSpec, TestMemDatasetSpec/TestMem*PathSpec cover general
 // dataset/path behaviour, and ForDataset/ForPath test LDB parsing.
@@ -182,6 +210,9 @@ func TestHref(t *testing.T) {
 	sp, _ = ForPath("aws://table:bucket/foo/bar/baz::myds.my.path")
 	assert.Equal("aws://table:bucket/foo/bar/baz", sp.Href())
 
+	sp, _ = ForDataset("ssh://me@host/foo/bar::myds")
+	assert.Equal("ssh://me@host/foo/bar", sp.Href())
+
 	sp, err := ForPath("mem::myds.my.path")
 	assert.NoError(err)
 	assert.Equal("", sp.Href())
@@ -207,6 +238,11 @@ func TestForDatabase(t *testing.T) {
 		"aws://t:b",
 		"aws://t",
 		"aws://t:",
+		"ssh://",
+		"ssh://host",
+		"ssh://host/",
+		"ssh://-oProxyCommand=touch$IFS/tmp/pwned/db",
+		"ssh://-oProxyCommand=id@h/db",
 	}
 
 	for _, spec := range badSpecs {
@@ -238,6 +274,7 @@ func TestForDatabase(t *testing.T) {
 		{"http://::ffff::1e::9a", "http", "//::ffff::1e::9a", ""},
 		{"aws://table:bucket/db", "aws", "//table:bucket/db", ""},
 		{"aws://table/db", "aws", "//table/db", ""},
+		{"ssh://me@host:2222/~/db", "ssh", "//me@host:2222/~/db", ""},
 	}
 
 	for _, tc := range testCases {
@@ -318,6 +355,7 @@ func TestForDataset(t *testing.T) {
 		{"http://::ffff::1e::9a::foo", "http", "//::ffff::1e::9a", "foo", ""},
 		{"aws://table:bucket/db::ds", "aws", "//table:bucket/db", "ds", ""},
 		{"aws://table/db::ds", "aws", "//table/db", "ds", ""},
+		{"ssh://host/data/db::ds", "ssh", "//host/data/db", "ds", ""},
 	}
 
 	for _, tc := range testCases {
@@ -506,3 +544,66 @@ func TestAcccessingInvalidSpec(t *testing.T) {
 	test("http:")
 	test("http:💩:")
 }
+
+func TestHTTPParams(t *testing.T) {
+	assert := assert.New(t)
+
+	str := "http://localhost:8000/db?access_token=x&bwlimit=2MB&maxrequests=3"
+	sp, err := ForDatabase(str)
+	assert.NoError(err)
+	assert.Equal(datas.HTTPChunkStoreOptions{BytesPerSecond: 2000000, MaxConcurrentRequests: 3}, sp.Options.HTTP)
+	assert.Equal(str, sp.String())
+
+	opts := datas.HTTPChunkStoreOptions{}
+	href, err := parseHTTPParams(sp.Href(), &opts)
+	assert.NoError(err)
+	assert.Equal("http://localhost:8000/db?access_token=x", href)
+
+	// Options given in code take precedence.
+	sp, err = ForDatasetOpts(str+"::ds", SpecOptions{HTTP: datas.HTTPChunkStoreOptions{BytesPerSecond: 5}})
+	assert.NoError(err)
+	assert.Equal(datas.HTTPChunkStoreOptions{BytesPerSecond: 5, MaxConcurrentRequests: 3}, sp.Options.HTTP)
+
+	sp, err = ForDatabase("https://host/db?verifychunks=false")
+	assert.NoError(err)
+	assert.True(sp.Options.HTTP.SkipChunkVerification)
+	sp, err = ForDatabase("https://host/db?verifychunks=1")
+	assert.NoError(err)
+	assert.False(sp.Options.HTTP.SkipChunkVerification)
+
+	sp, err = ForDatabase("https://host/db?retries=5&retrybackoff=500ms&timeout=1m")
+	assert.NoError(err)
+	assert.Equal(datas.HTTPChunkStoreOptions{Retries: 5, RetryBackoff: 500 * time.Millisecond, RequestTimeout: time.Minute}, sp.Options.HTTP)
+	href, err = parseHTTPParams(sp.Href(), &opts)
+	assert.NoError(err)
+	assert.Equal("https://host/db", href)
+
+	for _, bad := range []string{"https://host/db?retries=-1", "https://host/db?retrybackoff=1", "https://host/db?timeout=0s", "https://host/db?bwlimit=lots", "https://host/db?bwlimit=0", "https://host/db?maxrequests=-1", "https://host/db?maxrequests=x", "https://host/db?verifychunks=maybe"} {
+		_, err = ForDatabase(bad)
+		assert.Error(err, bad)
+	}
+}
+
+func TestMaxChunkSize(t *testing.T) {
+	assert := assert.New(t)
+
+	sp, err := ForDatabase("aws://table/db?maxchunksize=400KB")
+	assert.NoError(err)
+	assert.Equal(uint64(400000), sp.Options.MaxChunkSize)
+
+	// Options given in code take precedence.
+	sp, err = ForDatabaseOpts("aws://table/db?maxchunksize=400KB", SpecOptions{MaxChunkSize: 5})
+	assert.NoError(err)
+	assert.Equal(uint64(5), sp.Options.MaxChunkSize)
+
+	for _, bad := range []string{"aws://table/db?maxchunksize=lots", "aws://table/db?maxchunksize=0"} {
+		_, err = ForDatabase(bad)
+		assert.Error(err, bad)
+	}
+
+	sp, err = ForDatabaseOpts("mem", SpecOptions{MaxChunkSize: 4})
+	assert.NoError(err)
+	cs := sp.NewChunkStore()
+	assert.NotPanics(func() { cs.Put(chunks.NewChunk([]byte("abc"))) })
+	assert.Panics(func() { cs.Put(chunks.NewChunk([]byte("abcde"))) })
+}
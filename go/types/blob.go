@@ -293,6 +293,7 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 	}
 
 	mtChan := make(chan chan metaTuple, runtime.NumCPU())
+	inlineThreshold := sc.inlineThreshold
 
 	makeChunk := func() {
 		cp := make([]byte, offset)
@@ -303,14 +304,7 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 
 		go func(ch chan metaTuple, cp []byte) {
 			col, key, numLeaves := chunkBlobLeaf(vrw, cp)
-			var ref Ref
-			if vrw != nil {
-				ref = vrw.WriteValue(col)
-				col = nil
-			} else {
-				ref = NewRef(col)
-			}
-			ch <- newMetaTuple(ref, key, numLeaves, col)
+			ch <- newChunkMetaTuple(col, key, numLeaves, vrw, inlineThreshold)
 		}(ch, cp)
 
 		offset = 0
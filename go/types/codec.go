@@ -62,6 +62,7 @@ type nomsReader interface {
 	readNumber() Number
 	readBool() bool
 	readString() string
+	readStringBytes() []byte
 	readHash() hash.Hash
 }
 
@@ -119,9 +120,15 @@ func (b *binaryNomsReader) readBool() bool {
 }
 
 func (b *binaryNomsReader) readString() string {
+	return string(b.readStringBytes())
+}
+
+// readStringBytes returns the bytes of the next string without copying them.
+// The result aliases b.buff, so it must not be retained.
+func (b *binaryNomsReader) readStringBytes() []byte {
 	size := uint32(b.readCount())
 
-	v := string(b.buff[b.offset : b.offset+size])
+	v := b.buff[b.offset : b.offset+size]
 	b.offset += size
 	return v
 }
@@ -0,0 +1,100 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package codectest generates random Noms values and checks that they survive
+// encoding, decoding and a trip through a ChunkStore unchanged. ChunkStore
+// implementations, including those outside of this repository, can use it to
+// check their conformance.
+package codectest
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/types/testgen"
+)
+
+const (
+	// DefaultMaxDepth is the default bound on the nesting of generated
+	// values.
+	DefaultMaxDepth = testgen.DefaultMaxDepth
+	// DefaultMaxLen is the default bound on the number of elements in a
+	// generated collection. Occasionally a larger collection is generated so
+	// that chunked collections are covered as well.
+	DefaultMaxLen = testgen.DefaultMaxLen
+)
+
+// Generator produces pseudo-random Values. The same seed always produces the
+// same sequence of Values. See package testgen, which it comes from, for
+// generating Values of a given type.
+type Generator struct {
+	*testgen.Generator
+}
+
+// NewGenerator returns a Generator seeded with seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{testgen.NewGenerator(seed)}
+}
+
+// NewGeneratorFromBytes returns a Generator seeded with a hash of data. This
+// is how fuzzer supplied input is turned into Values.
+func NewGeneratorFromBytes(data []byte) *Generator {
+	return &Generator{testgen.NewGeneratorFromBytes(data)}
+}
+
+// CheckRoundTrip encodes and decodes v, then writes v to cs and reads it back
+// through a separate ValueStore. It returns an error describing the first
+// difference found, or nil if every copy of v is identical to v. cs must not
+// already contain a different value with the same hash, i.e. it must be a
+// correct ChunkStore.
+func CheckRoundTrip(v types.Value, cs chunks.ChunkStore) error {
+	h := v.Hash()
+	c := types.EncodeValue(v, nil)
+	if c.Hash() != h {
+		return fmt.Errorf("chunk hash %s differs from value hash %s", c.Hash(), h)
+	}
+	decoded := types.DecodeValue(c, nil)
+	if decoded.Hash() != h {
+		return fmt.Errorf("decoded value hash %s differs from %s", decoded.Hash(), h)
+	}
+	if !bytes.Equal(types.EncodeValue(decoded, nil).Data(), c.Data()) {
+		return fmt.Errorf("re-encoding decoded value %s produced different bytes", h)
+	}
+
+	vs := types.NewValueStore(cs)
+	r := vs.WriteValue(v)
+	if r.TargetHash() != h {
+		return fmt.Errorf("written ref %s differs from value hash %s", r.TargetHash(), h)
+	}
+	vs.Flush()
+
+	read := types.NewValueStore(cs).ReadValue(h)
+	if read == nil {
+		return fmt.Errorf("value %s not found after writing it", h)
+	}
+	if read.Hash() != h {
+		return fmt.Errorf("read value hash %s differs from %s", read.Hash(), h)
+	}
+	// The human readable encoding visits every chunk of v, so this also checks
+	// that all chunks of a chunked collection made it to the store.
+	if exp, act := types.EncodedValue(v), types.EncodedValue(read); exp != act {
+		return fmt.Errorf("read value %s differs from written value:\n%s\n%s", h, exp, act)
+	}
+	if !types.TypeOf(v).Equals(types.TypeOf(read)) {
+		return fmt.Errorf("read value %s has type %s, want %s", h, types.TypeOf(read).Describe(), types.TypeOf(v).Describe())
+	}
+	return nil
+}
+
+// Fuzz is an entry point for go-fuzz. It generates a Value from data and
+// panics if it does not round trip.
+func Fuzz(data []byte) int {
+	v := NewGeneratorFromBytes(data).Value()
+	if err := CheckRoundTrip(v, (&chunks.MemoryStorage{}).NewView()); err != nil {
+		panic(err)
+	}
+	return 1
+}
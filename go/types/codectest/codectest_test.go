@@ -0,0 +1,52 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package codectest
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	assert := assert.New(t)
+	g1, g2 := NewGenerator(42), NewGenerator(42)
+	for i := 0; i < 20; i++ {
+		assert.True(g1.Value().Equals(g2.Value()))
+	}
+	assert.True(NewGeneratorFromBytes([]byte("abc")).Value().Equals(NewGeneratorFromBytes([]byte("abc")).Value()))
+}
+
+func TestGeneratorMaxDepth(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGenerator(1)
+	g.MaxDepth = 0
+	for i := 0; i < 20; i++ {
+		v := g.Value()
+		assert.True(types.IsPrimitiveKind(v.Kind()) || v.Kind() == types.BlobKind)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	storage := &chunks.TestStorage{}
+	g := NewGenerator(0)
+	for i := 0; i < 50; i++ {
+		v := g.Value()
+		if err := CheckRoundTrip(v, storage.NewView()); err != nil {
+			t.Fatalf("value %d: %s", i, err)
+		}
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("noms"))
+	f.Add([]byte{0xff, 0x00, 0x7f})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Fuzz(data)
+	})
+}
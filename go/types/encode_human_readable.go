@@ -184,7 +184,7 @@ func (w *hrsWriter) writeStruct(v Struct, printStructName bool) {
 		w.newLine()
 	}
 	for i := 0; i < len(v.fieldNames); i++ {
-		w.write(v.fieldNames[i])
+		w.write(QuoteStructFieldName(v.fieldNames[i]))
 		w.write(": ")
 		w.Write(v.values[i])
 		w.write(",")
@@ -296,7 +296,7 @@ func (w *hrsWriter) writeStructType(t *Type, seenStructs map[*Type]struct{}) {
 		w.newLine()
 	}
 	desc.IterFields(func(name string, t *Type, optional bool) {
-		w.write(name)
+		w.write(QuoteStructFieldName(name))
 		if optional {
 			w.write("?")
 		}
@@ -148,6 +148,15 @@ func TestWriteHumanReadableStruct(t *testing.T) {
 	assertWriteTaggedHRSEqual(t, "struct S1 {\n  x: Number,\n  y: Number,\n}({\n  x: 1,\n  y: 2,\n})", str)
 }
 
+func TestWriteHumanReadableStructQuotedFieldNames(t *testing.T) {
+	str := NewStruct("S1", StructData{
+		"first name": Number(1),
+		"y":          Number(2),
+	})
+	assertWriteHRSEqual(t, "S1 {\n  \"first name\": 1,\n  y: 2,\n}", str)
+	assertWriteTaggedHRSEqual(t, "struct S1 {\n  \"first name\": Number,\n  y: Number,\n}({\n  \"first name\": 1,\n  y: 2,\n})", str)
+}
+
 func TestWriteHumanReadableListOfStruct(t *testing.T) {
 	str1 := NewStruct("S3", StructData{
 		"x": Number(1),
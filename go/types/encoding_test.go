@@ -39,6 +39,10 @@ func (r *nomsTestReader) readString() string {
 	return r.read().(string)
 }
 
+func (r *nomsTestReader) readStringBytes() []byte {
+	return []byte(r.readString())
+}
+
 func (r *nomsTestReader) readBool() bool {
 	return r.read().(bool)
 }
@@ -0,0 +1,101 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// maxAmbiguousCandidates is the number of candidates an
+// AmbiguousHashPrefixError lists in its message.
+const maxAmbiguousCandidates = 10
+
+// ErrHashPrefixUnsupported is returned by ResolveHashPrefix when the
+// underlying ChunkStore cannot enumerate hashes by prefix.
+var ErrHashPrefixUnsupported = errors.New("ChunkStore does not support resolving abbreviated hashes")
+
+// HashPrefixNotFoundError is returned by ResolveHashPrefix when no chunk's
+// hash begins with Prefix.
+type HashPrefixNotFoundError struct {
+	Prefix string
+}
+
+func (e *HashPrefixNotFoundError) Error() string {
+	return fmt.Sprintf("No chunk found with hash prefix %s", e.Prefix)
+}
+
+// AmbiguousHashPrefixError is returned by ResolveHashPrefix when the hashes
+// of more than one chunk begin with Prefix. Candidates holds all of them, in
+// order.
+type AmbiguousHashPrefixError struct {
+	Prefix     string
+	Candidates hash.HashSlice
+}
+
+func (e *AmbiguousHashPrefixError) Error() string {
+	n := len(e.Candidates)
+	if n > maxAmbiguousCandidates {
+		n = maxAmbiguousCandidates
+	}
+	strs := make([]string, n)
+	for i, h := range e.Candidates[:n] {
+		strs[i] = h.String()
+	}
+	if n < len(e.Candidates) {
+		strs = append(strs, "...")
+	}
+	return fmt.Sprintf("Hash prefix %s is ambiguous, it matches %d chunks: %s", e.Prefix, len(e.Candidates), strings.Join(strs, ", "))
+}
+
+// ResolveHashPrefix returns the hash of the single chunk in lvs, including
+// chunks which have been written but not yet flushed, whose hash begins with
+// prefix. A complete hash is returned as is, without checking that it is
+// present. Resolving abbreviated hashes requires a ChunkStore which
+// implements chunks.HashPrefixLister; if it doesn't, ErrHashPrefixUnsupported
+// is returned.
+func (lvs *ValueStore) ResolveHashPrefix(prefix string) (hash.Hash, error) {
+	if h, ok := hash.MaybeParse(prefix); ok {
+		return h, nil
+	}
+	if _, _, ok := hash.MaybeParsePrefix(prefix); !ok {
+		return hash.Hash{}, fmt.Errorf("Invalid hash prefix: %s", prefix)
+	}
+	lister, ok := lvs.cs.(chunks.HashPrefixLister)
+	if !ok {
+		return hash.Hash{}, ErrHashPrefixUnsupported
+	}
+
+	found := lister.HashesWithPrefix(prefix)
+	func() {
+		lvs.bufferMu.RLock()
+		defer lvs.bufferMu.RUnlock()
+		for h := range lvs.bufferedChunks {
+			if h.HasPrefix(prefix) {
+				found.Insert(h)
+			}
+		}
+	}()
+
+	switch len(found) {
+	case 0:
+		return hash.Hash{}, &HashPrefixNotFoundError{prefix}
+	case 1:
+		for h := range found {
+			return h, nil
+		}
+	}
+	candidates := make(hash.HashSlice, 0, len(found))
+	for h := range found {
+		candidates = append(candidates, h)
+	}
+	sort.Sort(candidates)
+	return hash.Hash{}, &AmbiguousHashPrefixError{prefix, candidates}
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestResolveHashPrefix(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	var refs []Ref
+	for i := 0; i < 100; i++ {
+		refs = append(refs, vs.WriteValue(Number(i)))
+	}
+	vs.persist()
+	// These are only buffered in vs.
+	for i := 100; i < 150; i++ {
+		refs = append(refs, vs.WriteValue(Number(i)))
+	}
+
+	for _, r := range refs {
+		h := r.TargetHash()
+		resolved, err := vs.ResolveHashPrefix(h.String()[:12])
+		assert.NoError(err)
+		assert.Equal(h, resolved)
+	}
+
+	// With 150 chunks, every single character prefix is ambiguous.
+	prefix := refs[0].TargetHash().String()[:1]
+	_, err := vs.ResolveHashPrefix(prefix)
+	if assert.IsType(&AmbiguousHashPrefixError{}, err) {
+		amb := err.(*AmbiguousHashPrefixError)
+		assert.Equal(prefix, amb.Prefix)
+		assert.True(len(amb.Candidates) > 1)
+		for i, h := range amb.Candidates {
+			assert.True(h.HasPrefix(prefix))
+			if i > 0 {
+				assert.True(amb.Candidates[i-1].Less(h))
+			}
+		}
+		assert.Contains(err.Error(), amb.Candidates[0].String())
+	}
+
+	_, err = vs.ResolveHashPrefix("vvvvvvvvvvvvvvvv")
+	assert.Equal(&HashPrefixNotFoundError{"vvvvvvvvvvvvvvvv"}, err)
+
+	_, err = vs.ResolveHashPrefix("xyz")
+	assert.Error(err)
+	_, err = vs.ResolveHashPrefix("")
+	assert.Error(err)
+}
+
+func TestResolveHashPrefixUnsupported(t *testing.T) {
+	assert := assert.New(t)
+
+	// Embedding the interface hides HashesWithPrefix.
+	cs := struct{ chunks.ChunkStore }{(&chunks.TestStorage{}).NewView()}
+	vs := NewValueStore(cs)
+	h := vs.WriteValue(Number(1)).TargetHash()
+
+	resolved, err := vs.ResolveHashPrefix(h.String())
+	assert.NoError(err)
+	assert.Equal(h, resolved)
+
+	_, err = vs.ResolveHashPrefix(h.String()[:8])
+	assert.Equal(ErrHashPrefixUnsupported, err)
+}
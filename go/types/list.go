@@ -187,6 +187,18 @@ func (l List) Concat(other List) List {
 	return newList(seq)
 }
 
+// Slice returns a new List of the items of this List from index start
+// (inclusive) through end (exclusive). Like Concat, it only needs to visit the
+// prolly tree chunks at either end of the slice, and shares those in between
+// with this List. This panics if start is greater than end, or end is out of
+// bounds.
+func (l List) Slice(start uint64, end uint64) List {
+	seq := slice(l.seq, start, end, func(cur *sequenceCursor, vr ValueReader) *sequenceChunker {
+		return l.newChunker(cur, vr)
+	})
+	return newList(seq)
+}
+
 // Remove returns a new list where the items at index start (inclusive) through end (exclusive) have
 // been removed. This panics if end is smaller than start.
 func (l List) Remove(start uint64, end uint64) List {
@@ -199,11 +211,13 @@ func (l List) RemoveAt(idx uint64) List {
 	return l.Splice(idx, 1)
 }
 
-type listIterFunc func(v Value, index uint64) (stop bool)
+// ListIterFunc is called by Iter with each element of a ListView and its
+// index, until it returns true.
+type ListIterFunc func(v Value, index uint64) (stop bool)
 
 // Iter iterates over the list and calls f for every element in the list. If f returns true then the
 // iteration stops.
-func (l List) Iter(f listIterFunc) {
+func (l List) Iter(f ListIterFunc) {
 	idx := uint64(0)
 	cur := newCursorAtIndex(l.seq, idx, false)
 	cur.iter(func(v interface{}) bool {
@@ -215,11 +229,13 @@ func (l List) Iter(f listIterFunc) {
 	})
 }
 
-type listIterAllFunc func(v Value, index uint64)
+// ListIterAllFunc is called by IterAll with each element of a ListView and
+// its index.
+type ListIterAllFunc func(v Value, index uint64)
 
 // IterAll iterates over the list and calls f for every element in the list. Unlike Iter there is no
 // way to stop the iteration and all elements are visited.
-func (l List) IterAll(f listIterAllFunc) {
+func (l List) IterAll(f ListIterAllFunc) {
 	// TODO: Consider removing this and have Iter behave like IterAll.
 	// https://github.com/attic-labs/noms/issues/2558
 	idx := uint64(0)
@@ -1102,6 +1102,51 @@ func TestListConcat(t *testing.T) {
 	run(7, 1e4, 1e4-1000, 1e4, 10)
 }
 
+func TestListSlice(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test in short mode.")
+	}
+
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	vs := newTestValueStore()
+	reload := func(vs *ValueStore, l List) List {
+		return vs.ReadValue(vs.WriteValue(l).TargetHash()).(List)
+	}
+
+	run := func(seed int64, size, by int) {
+		r := rand.New(rand.NewSource(seed))
+
+		listSlice := make(testList, size)
+		for i := range listSlice {
+			listSlice[i] = Number(r.Intn(size))
+		}
+
+		list := reload(vs, listSlice.toList())
+
+		for start := 0; start <= size; start += by {
+			for _, end := range []int{start, start + r.Intn(size-start+1), size} {
+				actual := list.Slice(uint64(start), uint64(end))
+				assert.True(listSlice[start:end].toList().Equals(actual),
+					"fail at %d-%d/%d (with expected length %d, actual %d)", start, end, size, end-start, actual.Len())
+			}
+		}
+	}
+
+	run(0, 10, 1)
+	run(1, 100, 1)
+	run(2, 1000, 7)
+	run(3, 1e4, 97)
+
+	l := NewList(generateNumbersAsValues(100)...)
+	assert.True(l.Equals(l.Slice(0, l.Len())))
+	assert.Panics(func() { l.Slice(2, 1) })
+	assert.Panics(func() { l.Slice(0, 101) })
+}
+
 func TestListConcatDifferentTypes(t *testing.T) {
 	assert := assert.New(t)
 
@@ -241,9 +241,11 @@ func (m Map) Get(key Value) Value {
 	return v
 }
 
-type mapIterCallback func(key, value Value) (stop bool)
+// MapIterCallback is called by Iter with each entry of a MapView, until it
+// returns true.
+type MapIterCallback func(key, value Value) (stop bool)
 
-func (m Map) Iter(cb mapIterCallback) {
+func (m Map) Iter(cb MapIterCallback) {
 	cur := newCursorAt(m.seq, emptyKey, false, false, false)
 	cur.iter(func(v interface{}) bool {
 		entry := v.(mapEntry)
@@ -279,9 +281,24 @@ func (m Map) IteratorFrom(key Value) MapIterator {
 	}
 }
 
-type mapIterAllCallback func(key, value Value)
+// ReverseIterator returns a MapIterator whose Next returns the entries of the
+// map from the largest key to the smallest, e.g. to page backwards through a
+// map keyed by time.
+func (m Map) ReverseIterator() MapIterator {
+	return &mapReverseIterator{newCursorAtEnd(m.seq)}
+}
+
+// ReverseIteratorFrom returns a MapIterator whose Next returns the entries of
+// the map from the largest key that is less than or equal to |key| to the
+// smallest.
+func (m Map) ReverseIteratorFrom(key Value) MapIterator {
+	return &mapReverseIterator{newCursorAtOrBefore(m.seq, key)}
+}
+
+// MapIterAllCallback is called by IterAll with each entry of a MapView.
+type MapIterAllCallback func(key, value Value)
 
-func (m Map) IterAll(cb mapIterAllCallback) {
+func (m Map) IterAll(cb MapIterAllCallback) {
 	cur := newCursorAt(m.seq, emptyKey, false, false, true)
 	cur.iter(func(v interface{}) bool {
 		entry := v.(mapEntry)
@@ -290,10 +307,51 @@ func (m Map) IterAll(cb mapIterAllCallback) {
 	})
 }
 
-func (m Map) IterFrom(start Value, cb mapIterCallback) {
+// IterReverse calls |cb| for the entries of the map from the largest key to
+// the smallest, until it returns true, e.g. to read the latest N entries of a
+// map keyed by time. Only the chunks that are reached are read.
+func (m Map) IterReverse(cb MapIterCallback) {
+	iterBackward(m.seq, func(v interface{}) bool {
+		entry := v.(mapEntry)
+		return cb(entry.key, entry.value)
+	})
+}
+
+// IterAllReverse calls |cb| for every entry of the map, from the largest key
+// to the smallest.
+func (m Map) IterAllReverse(cb MapIterAllCallback) {
+	iterBackward(m.seq, func(v interface{}) bool {
+		entry := v.(mapEntry)
+		cb(entry.key, entry.value)
+		return false
+	})
+}
+
+func (m Map) IterFrom(start Value, cb MapIterCallback) {
+	cur := newCursorAtValue(m.seq, start, false, false, false)
+	cur.iter(func(v interface{}) bool {
+		entry := v.(mapEntry)
+		return cb(entry.key, entry.value)
+	})
+}
+
+// IterRange calls |cb| for the entries of the map whose keys are at least
+// |start| and less than |end|, in order, until it returns true. It seeks
+// straight to start and stops at end, so only the chunks holding the range
+// are read. A nil start or end leaves the range open on that side. Keys are
+// compared in map order, so the range of keys that are Numbers, say, holds
+// only Numbers.
+func (m Map) IterRange(start, end Value, cb MapIterCallback) {
+	var endKey orderedKey
+	if end != nil {
+		endKey = newOrderedKey(end)
+	}
 	cur := newCursorAtValue(m.seq, start, false, false, false)
 	cur.iter(func(v interface{}) bool {
 		entry := v.(mapEntry)
+		if end != nil && !newOrderedKey(entry.key).Less(endKey) {
+			return true
+		}
 		return cb(entry.key, entry.value)
 	})
 }
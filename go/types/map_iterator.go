@@ -28,3 +28,21 @@ func (mi *mapIterator) Next() (k, v Value) {
 	}
 	return mi.currentKey, mi.currentValue
 }
+
+// mapReverseIterator iterates through a Noms Map from the largest key to the
+// smallest.
+type mapReverseIterator struct {
+	cursor *sequenceCursor
+}
+
+// Next returns the preceding entries from the Map, starting with the entry at
+// which the iterator was created. If there are no more entries, Next()
+// returns nils.
+func (mi *mapReverseIterator) Next() (k, v Value) {
+	if !mi.cursor.valid() {
+		return nil, nil
+	}
+	entry := mi.cursor.current().(mapEntry)
+	mi.cursor.retreat()
+	return entry.key, entry.value
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// MapPage is one page of a Map's entries, as returned by Map.IterPaged.
+type MapPage struct {
+	Keys, Values []Value
+	// Next is the token to pass to IterPaged for the following page. It's
+	// empty if there are no more entries.
+	Next string
+}
+
+// IterPaged returns up to |limit| entries of the map, in key order, beginning
+// just after the position recorded in |token|. An empty |token| starts at the
+// first entry. Tokens record a key rather than an index, so they remain
+// meaningful after the map is edited: the next page simply picks up with the
+// first key that sorts after the last one returned. An error is returned if
+// |token| is malformed, or if |limit| isn't positive.
+func (m Map) IterPaged(token string, limit int) (MapPage, error) {
+	if limit <= 0 {
+		return MapPage{}, fmt.Errorf("Invalid map page limit: %d", limit)
+	}
+
+	cur := newCursorAt(m.seq, emptyKey, false, false, false)
+	if token != "" {
+		key, err := parseMapPageToken(token)
+		if err != nil {
+			return MapPage{}, err
+		}
+		cur = newCursorAt(m.seq, key, false, false, false)
+		if cur.valid() && !key.Less(getCurrentKey(cur)) {
+			cur.advance()
+		}
+	}
+
+	page := MapPage{}
+	for ; cur.valid() && len(page.Keys) < limit; cur.advance() {
+		entry := cur.current().(mapEntry)
+		page.Keys = append(page.Keys, entry.key)
+		page.Values = append(page.Values, entry.value)
+	}
+	if cur.valid() {
+		page.Next = MapPageToken(page.Keys[len(page.Keys)-1])
+	}
+	return page, nil
+}
+
+// MapPageToken returns the token which makes IterPaged start with the first
+// entry whose key sorts after |key|. |key| need not be in the map.
+func MapPageToken(key Value) string {
+	switch key := key.(type) {
+	case Bool:
+		if key {
+			return "b1"
+		}
+		return "b0"
+	case Number:
+		return "n" + strconv.FormatFloat(float64(key), 'g', -1, 64)
+	case String:
+		return "s" + base64.RawURLEncoding.EncodeToString([]byte(key))
+	}
+	// All other keys are ordered by hash.
+	d.PanicIfTrue(isKindOrderedByValue(key.Kind()))
+	return "h" + key.Hash().String()
+}
+
+func parseMapPageToken(token string) (orderedKey, error) {
+	invalid := fmt.Errorf("Invalid map page token: %s", token)
+	payload := token[1:]
+	switch token[0] {
+	case 'b':
+		if payload != "0" && payload != "1" {
+			return orderedKey{}, invalid
+		}
+		return newOrderedKey(Bool(payload == "1")), nil
+	case 'n':
+		f, err := strconv.ParseFloat(payload, 64)
+		if err != nil {
+			return orderedKey{}, invalid
+		}
+		return newOrderedKey(Number(f)), nil
+	case 's':
+		s, err := base64.RawURLEncoding.DecodeString(payload)
+		if err != nil {
+			return orderedKey{}, invalid
+		}
+		return newOrderedKey(String(s)), nil
+	case 'h':
+		h, ok := hash.MaybeParse(payload)
+		if !ok || h.IsEmpty() {
+			return orderedKey{}, invalid
+		}
+		return orderedKeyFromHash(h), nil
+	}
+	return orderedKey{}, invalid
+}
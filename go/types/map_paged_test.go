@@ -0,0 +1,115 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func collectMapPages(assert *assert.Assertions, m Map, limit int) (keys []Value, pages int) {
+	token := ""
+	for {
+		page, err := m.IterPaged(token, limit)
+		assert.NoError(err)
+		assert.True(len(page.Keys) <= limit)
+		assert.Equal(len(page.Keys), len(page.Values))
+		for i, k := range page.Keys {
+			assert.True(m.Get(k).Equals(page.Values[i]))
+		}
+		keys = append(keys, page.Keys...)
+		pages++
+		if page.Next == "" {
+			return
+		}
+		token = page.Next
+	}
+}
+
+func TestMapIterPaged(t *testing.T) {
+	assert := assert.New(t)
+
+	kvs := []Value{}
+	for i := 0; i < 1000; i++ {
+		kvs = append(kvs, Number(i), String("v"))
+	}
+	m := NewMap(kvs...)
+
+	keys, pages := collectMapPages(assert, m, 300)
+	assert.Equal(4, pages)
+	assert.Len(keys, 1000)
+	for i, k := range keys {
+		assert.True(Number(i).Equals(k))
+	}
+
+	// When the last page is full, there's no trailing empty page.
+	_, pages = collectMapPages(assert, m, 250)
+	assert.Equal(4, pages)
+
+	page, err := NewMap().IterPaged("", 10)
+	assert.NoError(err)
+	assert.Empty(page.Keys)
+	assert.Empty(page.Next)
+}
+
+func TestMapIterPagedAfterEdit(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMap(Number(1), Bool(true), Number(2), Bool(true), Number(3), Bool(true), Number(4), Bool(true))
+	page, err := m.IterPaged("", 2)
+	assert.NoError(err)
+	assert.Equal([]Value{Number(1), Number(2)}, page.Keys)
+
+	// The token still works after the last key it returned is removed.
+	m = m.Remove(Number(2)).Set(Number(2.5), Bool(false))
+	page, err = m.IterPaged(page.Next, 2)
+	assert.NoError(err)
+	assert.Equal([]Value{Number(2.5), Number(3)}, page.Keys)
+	assert.Equal([]Value{Bool(false), Bool(true)}, page.Values)
+
+	// Tokens can also be made for keys that aren't in the map.
+	page, err = m.IterPaged(MapPageToken(Number(3.5)), 10)
+	assert.NoError(err)
+	assert.Equal([]Value{Number(4)}, page.Keys)
+	assert.Empty(page.Next)
+}
+
+func TestMapIterPagedMixedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMap(
+		Bool(false), Number(0),
+		Bool(true), Number(1),
+		Number(-1.5), Number(2),
+		String("a/b?c"), Number(3),
+		String(""), Number(4),
+		NewStruct("S", StructData{"x": Number(1)}), Number(5),
+		NewList(Number(1)), Number(6),
+		NewSet(String("s")), Number(7),
+	)
+
+	keys, pages := collectMapPages(assert, m, 1)
+	assert.Equal(int(m.Len()), pages)
+	expected := []Value{}
+	m.IterAll(func(k, v Value) {
+		expected = append(expected, k)
+	})
+	assert.Equal(expected, keys)
+}
+
+func TestMapIterPagedBadArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMap(Number(1), Number(1))
+	for _, token := range []string{"x", "b2", "nope", "s!!", "h", "h00000000000000000000000000000000", "hzz"} {
+		_, err := m.IterPaged(token, 1)
+		assert.Error(err, token)
+	}
+	for _, limit := range []int{0, -1} {
+		_, err := m.IterPaged("", limit)
+		assert.Error(err)
+	}
+}
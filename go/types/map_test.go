@@ -1243,6 +1243,97 @@ func TestMapRemoveLastWhenNotLoaded(t *testing.T) {
 	}
 }
 
+func TestMapIterReverse(t *testing.T) {
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	assert := assert.New(t)
+
+	doTest := func(tm testMap) {
+		m := tm.toMap()
+		sort.Sort(tm.entries)
+		idx := len(tm.entries)
+
+		m.IterAllReverse(func(k, v Value) {
+			idx--
+			assert.True(tm.entries[idx].key.Equals(k))
+			assert.True(tm.entries[idx].value.Equals(v))
+		})
+		assert.Equal(0, idx)
+
+		// The latest 10 entries.
+		idx = len(tm.entries)
+		m.IterReverse(func(k, v Value) bool {
+			idx--
+			assert.True(tm.entries[idx].key.Equals(k))
+			return idx == len(tm.entries)-10
+		})
+		assert.Equal(len(tm.entries)-10, idx)
+	}
+
+	doTest(getTestNativeOrderMap(16))
+	doTest(getTestRefValueOrderMap(2))
+	doTest(getTestRefToNativeOrderMap(2, newTestValueStore()))
+	doTest(getTestRefToValueOrderMap(2, newTestValueStore()))
+
+	NewMap().IterReverse(func(k, v Value) bool {
+		assert.Fail("empty map has no entries")
+		return false
+	})
+}
+
+func TestMapReverseIterator(t *testing.T) {
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	assert := assert.New(t)
+
+	doTest := func(tm testMap) {
+		m := tm.toMap()
+		sort.Sort(tm.entries)
+
+		it := m.ReverseIterator()
+		for i := len(tm.entries) - 1; i >= 0; i-- {
+			k, v := it.Next()
+			assert.True(tm.entries[i].key.Equals(k))
+			assert.True(tm.entries[i].value.Equals(v))
+		}
+		k, v := it.Next()
+		assert.Nil(k)
+		assert.Nil(v)
+
+		// Page backwards from the middle.
+		mid := len(tm.entries) / 2
+		it = m.ReverseIteratorFrom(tm.entries[mid].key)
+		for i := mid; i >= mid-10; i-- {
+			k, _ := it.Next()
+			assert.True(tm.entries[i].key.Equals(k))
+		}
+	}
+
+	doTest(getTestNativeOrderMap(16))
+	doTest(getTestRefValueOrderMap(2))
+	doTest(getTestRefToNativeOrderMap(2, newTestValueStore()))
+	doTest(getTestRefToValueOrderMap(2, newTestValueStore()))
+
+	next := func(it MapIterator) Value {
+		k, _ := it.Next()
+		return k
+	}
+	// Strings sort after Numbers, so every key of a large map of Numbers is
+	// less than one.
+	tm := getTestNativeOrderMap(16)
+	sort.Sort(tm.entries)
+	assert.True(tm.entries[len(tm.entries)-1].key.Equals(next(tm.toMap().ReverseIteratorFrom(String("a")))))
+
+	m := NewMap(Number(1), String("a"), Number(3), String("b"), Number(5), String("c"))
+	assert.Equal(Number(3), next(m.ReverseIteratorFrom(Number(4))))
+	assert.Equal(Number(5), next(m.ReverseIteratorFrom(Number(100))))
+	assert.Nil(next(m.ReverseIteratorFrom(Number(0))))
+	assert.Nil(next(NewMap().ReverseIterator()))
+	assert.Nil(next(NewMap().ReverseIteratorFrom(Number(1))))
+}
+
 func TestMapIterFrom(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1271,6 +1362,50 @@ func TestMapIterFrom(t *testing.T) {
 	assert.True(kvs[50:60].Equals(test(m1, Number(0), Number(8))))
 }
 
+func TestMapIterRange(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(m Map, start, end Value) ValueSlice {
+		res := ValueSlice{}
+		m.IterRange(start, end, func(k, v Value) bool {
+			res = append(res, k, v)
+			return false
+		})
+		return res
+	}
+
+	// The keys are the even numbers from -50 to 48.
+	kvs := generateNumbersAsValuesFromToBy(-50, 50, 1)
+	m1 := NewMap(kvs...)
+	assert.True(kvs.Equals(test(m1, nil, nil)))
+	assert.True(kvs.Equals(test(m1, Number(-1000), Number(1000))))
+	assert.True(kvs[2:].Equals(test(m1, Number(-49), nil)))
+	assert.True(kvs[:96].Equals(test(m1, nil, Number(46))))
+	assert.True(kvs[50:56].Equals(test(m1, Number(0), Number(5))))
+	assert.True(kvs[50:56].Equals(test(m1, Number(-0.5), Number(4.5))))
+	assert.True(kvs[0:0].Equals(test(m1, Number(4), Number(4))))
+	assert.True(kvs[0:0].Equals(test(m1, Number(4), Number(0))))
+	assert.True(kvs[0:0].Equals(test(m1, Number(100), nil)))
+
+	// Bools sort before Numbers, and Strings after them.
+	m2 := m1.Set(String("a"), Number(0)).Set(Bool(true), Number(0))
+	assert.True(kvs[96:].Equals(test(m2, Number(46), String(""))))
+
+	// Ranges that span chunks.
+	kvs = generateNumbersAsValuesFromToBy(0, 10000, 1)
+	m3 := NewMap(kvs...)
+	assert.True(len(m3.sequence().(metaSequence).tuples) > 1)
+	assert.True(kvs[1234:8766].Equals(test(m3, Number(1234), Number(8765))))
+
+	// Returning true stops the iteration.
+	n := 0
+	m3.IterRange(Number(10), nil, func(k, v Value) bool {
+		n++
+		return n == 3
+	})
+	assert.Equal(3, n)
+}
+
 func TestMapAt(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,98 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// MemoFunc computes a value derived from |input|. It must be deterministic:
+// the same input must always produce the same output.
+type MemoFunc func(input Value) Value
+
+// MemoCache remembers the results of expensive MemoFuncs, keyed by the
+// identity of the function and the hash of its input. Because Noms values are
+// content addressed, a cached result stays valid for as long as the input is
+// unchanged, no matter which process computed it or when.
+//
+// The cache itself is a Noms Map, so it can be persisted alongside the data it
+// describes: Persist writes it to the ValueReadWriter and returns a Ref which
+// the caller stores somewhere durable, e.g. as the head of a dataset. Passing
+// the target of that Ref to NewMemoCache in a later process picks up where
+// this one left off.
+//
+// Function identities are arbitrary strings chosen by the caller. Since a
+// cached result outlives the code that computed it, an identity should change
+// whenever the function's behavior does, e.g. "wordCount/v2".
+type MemoCache struct {
+	vrw ValueReadWriter
+	mu  sync.Mutex
+	m   Map
+}
+
+// NewMemoCache returns a MemoCache backed by |vrw|. If |root| is empty the
+// cache starts out empty, otherwise |root| must be the hash of a Ref returned
+// by an earlier call to Persist.
+func NewMemoCache(vrw ValueReadWriter, root hash.Hash) *MemoCache {
+	m := NewMap()
+	if !root.IsEmpty() {
+		v := vrw.ReadValue(root)
+		if v == nil {
+			d.Panic("Memo cache %s not found", root)
+		}
+		m = v.(Map)
+	}
+	return &MemoCache{vrw: vrw, m: m}
+}
+
+func memoKey(fn string, input Value) String {
+	// The hash has a fixed length, so no separator is needed to keep keys
+	// unambiguous. Keeping |fn| first groups each function's results.
+	return String(fn + input.Hash().String())
+}
+
+// Lookup returns the result of the function identified by |fn| for |input| if
+// it has been cached.
+func (mc *MemoCache) Lookup(fn string, input Value) (Value, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.m.MaybeGet(memoKey(fn, input))
+}
+
+// Get returns the result of the function identified by |fn| for |input|,
+// calling |compute| and caching what it returns if the result isn't already
+// known. |compute| is called without holding any locks, so concurrent callers
+// asking for the same uncached result may each compute it.
+func (mc *MemoCache) Get(fn string, input Value, compute MemoFunc) Value {
+	if v, ok := mc.Lookup(fn, input); ok {
+		return v
+	}
+	v := compute(input)
+	d.PanicIfTrue(v == nil)
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.m = mc.m.Set(memoKey(fn, input), v)
+	return v
+}
+
+// Len returns the number of cached results.
+func (mc *MemoCache) Len() uint64 {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.m.Len()
+}
+
+// Persist writes the cache to its ValueReadWriter and returns a Ref to it. As
+// with any other value, the caller must make the Ref reachable, e.g. by
+// committing it, for the cache to survive.
+func (mc *MemoCache) Persist() Ref {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.vrw.WriteValue(mc.m)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMemoCache(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	mc := NewMemoCache(vs, hash.Hash{})
+
+	calls := 0
+	sum := func(v Value) Value {
+		calls++
+		total := Number(0)
+		v.(List).IterAll(func(v Value, i uint64) {
+			total += v.(Number)
+		})
+		return total
+	}
+
+	l := NewList(Number(1), Number(2), Number(3))
+	assert.True(Number(6).Equals(mc.Get("sum", l, sum)))
+	assert.True(Number(6).Equals(mc.Get("sum", l, sum)))
+	assert.Equal(1, calls)
+
+	// Equal inputs share a result, different inputs and functions don't.
+	assert.True(Number(6).Equals(mc.Get("sum", NewList(Number(1), Number(2), Number(3)), sum)))
+	assert.Equal(1, calls)
+	assert.True(Number(10).Equals(mc.Get("sum", l.Append(Number(4)), sum)))
+	assert.Equal(2, calls)
+	mc.Get("sum/v2", l, sum)
+	assert.Equal(3, calls)
+	assert.Equal(uint64(3), mc.Len())
+
+	_, ok := mc.Lookup("sum", NewList())
+	assert.False(ok)
+	v, ok := mc.Lookup("sum", l)
+	assert.True(ok)
+	assert.True(Number(6).Equals(v))
+}
+
+func TestMemoCachePersist(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := &chunks.TestStorage{}
+	vs := NewValueStore(storage.NewView())
+	mc := NewMemoCache(vs, hash.Hash{})
+
+	input := generateNumbersAsValues(1000)
+	big := func(v Value) Value {
+		return NewList(input...)
+	}
+	l := NewList(input...)
+	mc.Get("big", String("in"), big)
+	r := mc.Persist()
+	vs.persist()
+
+	// Another process sees the cached result without computing it.
+	vs2 := NewValueStore(storage.NewView())
+	mc2 := NewMemoCache(vs2, r.TargetHash())
+	assert.Equal(uint64(1), mc2.Len())
+	v := mc2.Get("big", String("in"), func(v Value) Value {
+		assert.Fail("should have been cached")
+		return nil
+	})
+	assert.True(l.Equals(v))
+
+	assert.Panics(func() {
+		NewMemoCache(vs2, hash.Of([]byte("nope")))
+	})
+}
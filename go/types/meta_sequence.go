@@ -19,7 +19,13 @@ var emptyKey = orderedKey{}
 
 func newMetaTuple(ref Ref, key orderedKey, numLeaves uint64, child Collection) metaTuple {
 	d.PanicIfFalse(Ref{} != ref)
-	return metaTuple{ref, key, numLeaves, child}
+	return metaTuple{ref, key, numLeaves, child, false}
+}
+
+// newInlineMetaTuple returns a metaTuple whose child is encoded in place of the
+// Ref to it, rather than being written as a chunk of its own.
+func newInlineMetaTuple(child Collection, key orderedKey, numLeaves uint64) metaTuple {
+	return metaTuple{NewRef(child), key, numLeaves, child, true}
 }
 
 // metaTuple is a node in a Prolly Tree, consisting of data in the node (either tree leaves or other metaSequences), and a Value annotation for exploring the tree (e.g. the largest item if this an ordered sequence).
@@ -28,6 +34,7 @@ type metaTuple struct {
 	key       orderedKey
 	numLeaves uint64
 	child     Collection // may be nil
+	inline    bool       // if true, child isn't nil and isn't a chunk of its own
 }
 
 func (mt metaTuple) getChildSequence(vr ValueReader) sequence {
@@ -126,7 +133,11 @@ func (ms metaSequence) valueReader() ValueReader {
 
 func (ms metaSequence) WalkRefs(cb RefCallback) {
 	for _, tuple := range ms.tuples {
-		cb(tuple.ref)
+		if tuple.inline {
+			tuple.child.WalkRefs(cb)
+		} else {
+			cb(tuple.ref)
+		}
 	}
 }
 
@@ -230,14 +241,21 @@ func (ms metaSequence) getChildren(start, end uint64) (seqs []sequence) {
 
 	// Fetch committed child sequences in a single batch
 	valueChan := make(chan Value, len(hs))
+	var readPanic interface{}
 	go func() {
+		defer close(valueChan)
+		// Re-raised below so that a ValueReader that panics, e.g. a
+		// BudgetedValueReader, fails on the caller's goroutine.
+		defer func() { readPanic = recover() }()
 		ms.vr.ReadManyValues(hs, valueChan)
-		close(valueChan)
 	}()
 	children := make(map[hash.Hash]sequence, len(hs))
 	for value := range valueChan {
 		children[value.Hash()] = value.(Collection).sequence()
 	}
+	if readPanic != nil {
+		panic(readPanic)
+	}
 
 	for i := start; i < end; i++ {
 		mt := ms.tuples[i]
@@ -0,0 +1,141 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// ErrNumberOverflow is returned when the sum of the Numbers in a collection
+// is too large to be a Number.
+var ErrNumberOverflow = errors.New("Sum of Numbers overflows")
+
+// ErrNoNumbers is returned by MinMaxNumbers and MeanNumbers for an empty
+// collection.
+var ErrNoNumbers = errors.New("Collection has no Numbers")
+
+// NumberAggregate summarizes the Numbers in a collection.
+type NumberAggregate struct {
+	Count    uint64
+	Sum      float64
+	Min, Max float64
+}
+
+// Mean returns the mean of the Numbers, or NaN if there are none.
+func (a NumberAggregate) Mean() float64 {
+	if a.Count == 0 {
+		return math.NaN()
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// AggregateNumbers summarizes the values of |c|, which must be a List or Set
+// of Numbers, or a Map whose values are Numbers. Rather than iterating entry
+// by entry, it reads the children of each chunk of |c| in a batch and walks
+// their leaves. An error is returned if a value isn't a Number, or if the sum
+// overflows.
+func AggregateNumbers(c Collection) (NumberAggregate, error) {
+	switch k := c.Kind(); k {
+	case ListKind, SetKind, MapKind:
+	default:
+		return NumberAggregate{}, fmt.Errorf("Cannot aggregate the Numbers of a %s", KindToString[k])
+	}
+	agg := NumberAggregate{Min: math.Inf(1), Max: math.Inf(-1)}
+	if err := aggregateSequence(c.sequence(), &agg); err != nil {
+		return NumberAggregate{}, err
+	}
+	if agg.Count == 0 {
+		return NumberAggregate{}, nil
+	}
+	return agg, nil
+}
+
+// SumNumbers returns the sum of the values of |c|, as AggregateNumbers does.
+func SumNumbers(c Collection) (float64, error) {
+	agg, err := AggregateNumbers(c)
+	return agg.Sum, err
+}
+
+// MinMaxNumbers returns the smallest and largest values of |c|, as
+// AggregateNumbers does. ErrNoNumbers is returned if |c| is empty.
+func MinMaxNumbers(c Collection) (min, max float64, err error) {
+	agg, err := AggregateNumbers(c)
+	if err == nil && agg.Count == 0 {
+		err = ErrNoNumbers
+	}
+	return agg.Min, agg.Max, err
+}
+
+// MeanNumbers returns the mean of the values of |c|, as AggregateNumbers
+// does. ErrNoNumbers is returned if |c| is empty.
+func MeanNumbers(c Collection) (float64, error) {
+	agg, err := AggregateNumbers(c)
+	if err != nil {
+		return 0, err
+	}
+	if agg.Count == 0 {
+		return 0, ErrNoNumbers
+	}
+	return agg.Mean(), nil
+}
+
+func aggregateSequence(seq sequence, agg *NumberAggregate) error {
+	switch seq := seq.(type) {
+	case listLeafSequence:
+		return agg.addValues(seq.values)
+	case setLeafSequence:
+		return agg.addValues(seq.data)
+	case mapLeafSequence:
+		for _, entry := range seq.data {
+			if err := agg.add(entry.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case metaSequence:
+		for _, child := range seq.getChildren(0, uint64(seq.seqLen())) {
+			if err := aggregateSequence(child, agg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	d.Panic("unreachable")
+	return nil
+}
+
+func (agg *NumberAggregate) addValues(vs []Value) error {
+	for _, v := range vs {
+		if err := agg.add(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (agg *NumberAggregate) add(v Value) error {
+	n, ok := v.(Number)
+	if !ok {
+		return fmt.Errorf("Cannot aggregate a %s as a Number", KindToString[v.Kind()])
+	}
+	f := float64(n)
+	agg.Count++
+	agg.Sum += f
+	if math.IsInf(agg.Sum, 0) {
+		// Numbers are never infinite, so an infinite sum has overflowed.
+		return ErrNumberOverflow
+	}
+	if f < agg.Min {
+		agg.Min = f
+	}
+	if f > agg.Max {
+		agg.Max = f
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestAggregateNumbers(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	nums := ValueSlice{}
+	kvs := ValueSlice{}
+	for i := 0; i < 1000; i++ {
+		nums = append(nums, Number(i-100))
+		kvs = append(kvs, Number(i), Number(i-100))
+	}
+	// Read the collections back, so that their chunks are read from vs.
+	l := vs.ReadValue(vs.WriteValue(NewList(nums...)).TargetHash()).(Collection)
+	s := vs.ReadValue(vs.WriteValue(NewSet(nums...)).TargetHash()).(Collection)
+	m := vs.ReadValue(vs.WriteValue(NewMap(kvs...)).TargetHash()).(Collection)
+	assert.False(l.sequence().isLeaf())
+
+	for _, c := range []Collection{l, s, m} {
+		agg, err := AggregateNumbers(c)
+		assert.NoError(err)
+		assert.Equal(NumberAggregate{1000, 399500, -100, 899}, agg)
+		assert.Equal(399.5, agg.Mean())
+
+		sum, err := SumNumbers(c)
+		assert.NoError(err)
+		assert.Equal(float64(399500), sum)
+		min, max, err := MinMaxNumbers(c)
+		assert.NoError(err)
+		assert.Equal(float64(-100), min)
+		assert.Equal(float64(899), max)
+		mean, err := MeanNumbers(c)
+		assert.NoError(err)
+		assert.Equal(399.5, mean)
+	}
+
+	agg, err := AggregateNumbers(NewList())
+	assert.NoError(err)
+	assert.Equal(NumberAggregate{}, agg)
+	assert.True(math.IsNaN(agg.Mean()))
+	_, _, err = MinMaxNumbers(NewSet())
+	assert.Equal(ErrNoNumbers, err)
+	_, err = MeanNumbers(NewMap())
+	assert.Equal(ErrNoNumbers, err)
+
+	_, err = SumNumbers(NewList(Number(1), String("two")))
+	assert.Error(err)
+	_, err = SumNumbers(NewMap(Number(1), Bool(true)))
+	assert.Error(err)
+	_, err = SumNumbers(NewBlob())
+	assert.Error(err)
+	_, err = SumNumbers(NewList(Number(math.MaxFloat64), Number(math.MaxFloat64)))
+	assert.Equal(ErrNumberOverflow, err)
+}
@@ -0,0 +1,112 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+// OrderedCursor is a position in the ordered sequence of entries of a Map, or
+// of values of a Set. It moves in either direction, and can seek to any key,
+// so it can be used to build algorithms, like merges, joins and sampling,
+// that iterators can't express efficiently.
+//
+// The entries are stored in chunks, which are the leaves of a prolly tree. The
+// cursor reports its position within the current chunk, so that algorithms can
+// work a chunk at a time.
+type OrderedCursor struct {
+	seq orderedSequence
+	cur *sequenceCursor
+}
+
+// Cursor returns an OrderedCursor positioned at the first entry of |m|.
+func (m Map) Cursor() *OrderedCursor {
+	return newOrderedCursor(m.seq)
+}
+
+// Cursor returns an OrderedCursor positioned at the first value of |s|.
+func (s Set) Cursor() *OrderedCursor {
+	return newOrderedCursor(s.seq)
+}
+
+func newOrderedCursor(seq orderedSequence) *OrderedCursor {
+	return &OrderedCursor{seq, newCursorAt(seq, emptyKey, false, false, false)}
+}
+
+// Valid returns true if the cursor is positioned at an entry, and false if
+// it's moved past either end, or the collection is empty.
+func (oc *OrderedCursor) Valid() bool {
+	return oc.cur.valid()
+}
+
+// Key returns the key of the current entry of a Map, or the current value of
+// a Set. It panics if the cursor isn't Valid.
+func (oc *OrderedCursor) Key() Value {
+	switch item := oc.cur.current().(type) {
+	case mapEntry:
+		return item.key
+	case Value:
+		return item
+	}
+	panic("unreachable")
+}
+
+// Value returns the value of the current entry of a Map, or nil for a Set. It
+// panics if the cursor isn't Valid.
+func (oc *OrderedCursor) Value() Value {
+	if entry, ok := oc.cur.current().(mapEntry); ok {
+		return entry.value
+	}
+	return nil
+}
+
+// Advance moves the cursor to the next entry. It returns false, and leaves the
+// cursor invalid, if there is no next entry.
+func (oc *OrderedCursor) Advance() bool {
+	return oc.cur.advance()
+}
+
+// Retreat moves the cursor to the previous entry. It returns false, and leaves
+// the cursor invalid, if there is no previous entry.
+func (oc *OrderedCursor) Retreat() bool {
+	return oc.cur.retreat()
+}
+
+// SeekTo moves the cursor to the first entry whose key is greater than or
+// equal to |key|, in either direction. It returns false, and leaves the cursor
+// past the end, if there is no such entry.
+func (oc *OrderedCursor) SeekTo(key Value) bool {
+	oc.cur = newCursorAtValue(oc.seq, key, false, false, false)
+	return oc.cur.valid()
+}
+
+// SeekToLast moves the cursor to the last entry. It returns false if the
+// collection is empty.
+func (oc *OrderedCursor) SeekToLast() bool {
+	if oc.seq.seqLen() == 0 {
+		return false
+	}
+	oc.cur = newCursorAt(oc.seq, emptyKey, false, true, false)
+	return oc.cur.valid()
+}
+
+// Clone returns a cursor at the same position, which moves independently.
+func (oc *OrderedCursor) Clone() *OrderedCursor {
+	return &OrderedCursor{oc.seq, oc.cur.clone()}
+}
+
+// IndexInChunk returns the position of the current entry within the chunk
+// that holds it.
+func (oc *OrderedCursor) IndexInChunk() int {
+	return oc.cur.indexInChunk()
+}
+
+// ChunkLen returns the number of entries in the chunk that holds the current
+// entry.
+func (oc *OrderedCursor) ChunkLen() int {
+	return oc.cur.length()
+}
+
+// Depth returns the height of the prolly tree the entries are stored in,
+// which is 1 if they're all in a single chunk.
+func (oc *OrderedCursor) Depth() int {
+	return oc.cur.depth()
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestOrderedCursorMap(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 5000
+	kvs := make([]Value, 0, 2*n)
+	for i := 0; i < n; i++ {
+		kvs = append(kvs, Number(i), String("v"))
+	}
+	m := NewMap(kvs...)
+
+	oc := m.Cursor()
+	assert.True(oc.Depth() > 1)
+	chunks, i := 0, 0
+	for ; oc.Valid(); oc.Advance() {
+		assert.True(Number(i).Equals(oc.Key()))
+		assert.True(String("v").Equals(oc.Value()))
+		assert.True(oc.IndexInChunk() < oc.ChunkLen())
+		if oc.IndexInChunk() == 0 {
+			chunks++
+		}
+		i++
+	}
+	assert.Equal(n, i)
+	assert.True(chunks > 1)
+	assert.False(oc.Advance())
+
+	assert.True(oc.SeekToLast())
+	for i = n - 1; oc.Valid(); oc.Retreat() {
+		assert.True(Number(i).Equals(oc.Key()))
+		i--
+	}
+	assert.Equal(-1, i)
+
+	assert.True(oc.SeekTo(Number(2500.5)))
+	assert.True(Number(2501).Equals(oc.Key()))
+	cl := oc.Clone()
+	assert.True(oc.SeekTo(Number(10)))
+	assert.True(Number(10).Equals(oc.Key()))
+	assert.True(Number(2501).Equals(cl.Key()))
+	assert.False(oc.SeekTo(Number(n)))
+	assert.False(oc.Valid())
+
+	assert.False(NewMap().Cursor().Valid())
+	assert.False(NewMap().Cursor().SeekToLast())
+}
+
+func TestOrderedCursorSetJoin(t *testing.T) {
+	assert := assert.New(t)
+
+	// Find the values two sets have in common by leapfrogging cursors.
+	var evens, threes []Value
+	for i := 0; i < 3000; i++ {
+		if i%2 == 0 {
+			evens = append(evens, Number(i))
+		}
+		if i%3 == 0 {
+			threes = append(threes, Number(i))
+		}
+	}
+	a, b := NewSet(evens...).Cursor(), NewSet(threes...).Cursor()
+	assert.Nil(a.Value())
+
+	common := []Value{}
+	for a.Valid() && b.Valid() {
+		switch ka, kb := a.Key(), b.Key(); {
+		case ka.Equals(kb):
+			common = append(common, ka)
+			a.Advance()
+			b.Advance()
+		case ka.Less(kb):
+			a.SeekTo(kb)
+		default:
+			b.SeekTo(ka)
+		}
+	}
+	assert.Equal(500, len(common))
+	for i, v := range common {
+		assert.True(Number(i * 6).Equals(v))
+	}
+}
@@ -55,6 +55,36 @@ func newCursorAt(seq orderedSequence, key orderedKey, forInsertion bool, last bo
 	return cur
 }
 
+// iterBackward calls |cb| for the items of |seq|, from the last to the first,
+// until it returns true.
+func iterBackward(seq orderedSequence, cb cursorIterCallback) {
+	newCursorAtEnd(seq).iterBackward(cb)
+}
+
+// newCursorAtEnd returns a cursor at the last item of |seq|, which is invalid
+// if |seq| is empty.
+func newCursorAtEnd(seq orderedSequence) *sequenceCursor {
+	if seq.seqLen() == 0 {
+		return newSequenceCursor(nil, seq, 0, false)
+	}
+	return newCursorAt(seq, emptyKey, false, true, false)
+}
+
+// newCursorAtOrBefore returns a cursor at the last item of |seq| whose key is
+// less than or equal to |val|'s, which is invalid if there isn't one.
+func newCursorAtOrBefore(seq orderedSequence, val Value) *sequenceCursor {
+	if seq.seqLen() == 0 {
+		return newSequenceCursor(nil, seq, 0, false)
+	}
+	// Seeking for insertion puts the cursor in the last leaf, past its end,
+	// rather than past the end of a meta sequence, if every key is smaller.
+	cur := newCursorAtValue(seq, val, true, false, false)
+	if !cur.valid() || newOrderedKey(val).Less(getCurrentKey(cur)) {
+		cur.retreat()
+	}
+	return cur
+}
+
 func seekTo(cur *sequenceCursor, key orderedKey, lastPositionIfNotFound bool) bool {
 	seq := cur.seq.(orderedSequence)
 
@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
@@ -65,6 +66,18 @@ func constructPath(p Path, str string) (Path, error) {
 
 	switch op {
 	case '.':
+		if len(tail) > 0 && tail[0] == '"' {
+			name, rem, closed, err := parseQuotedString(tail)
+			if err != nil {
+				return Path{}, err
+			}
+			if !closed || !IsLegalStructFieldName(name) {
+				return Path{}, errors.New("Invalid field: " + tail)
+			}
+			p = append(p, FieldPath{name})
+			return constructPath(p, rem)
+		}
+
 		idx := fieldNameComponentRe.FindIndex([]byte(tail))
 		if idx == nil {
 			return Path{}, errors.New("Invalid field: " + tail)
@@ -193,7 +206,8 @@ func (p Path) IsEmpty() bool {
 
 // Gets Struct field values by name.
 type FieldPath struct {
-	// The name of the field, e.g. `.Name`.
+	// The name of the field, e.g. `.Name`. Names which aren't valid bare field
+	// names are quoted, e.g. `."first name"`.
 	Name string
 }
 
@@ -212,7 +226,7 @@ func (fp FieldPath) Resolve(v Value, vr ValueReader) Value {
 }
 
 func (fp FieldPath) String() string {
-	return fmt.Sprintf(".%s", fp.Name)
+	return fmt.Sprintf(".%s", QuoteStructFieldName(fp.Name))
 }
 
 // Indexes into Maps and Lists by key or index.
@@ -370,29 +384,12 @@ func ParsePathIndex(str string) (idx Value, h hash.Hash, rem string, err error)
 Switch:
 	switch str[0] {
 	case '"':
-		// String is complicated because ] might be quoted, and " or \ might be escaped.
-		stringBuf := bytes.Buffer{}
-		i := 1
-
-		for ; i < len(str); i++ {
-			c := str[i]
-			if c == '"' {
-				i++
-				break
-			}
-			if c == '\\' && i < len(str)-1 {
-				i++
-				c = str[i]
-				if c != '\\' && c != '"' {
-					err = errors.New(`Only " and \ can be escaped`)
-					break Switch
-				}
-			}
-			stringBuf.WriteByte(c)
+		var s string
+		s, rem, _, err = parseQuotedString(str)
+		if err != nil {
+			break Switch
 		}
-
-		idx = String(stringBuf.String())
-		rem = str[i:]
+		idx = String(s)
 
 	default:
 		idxStr := str
@@ -424,6 +421,61 @@ Switch:
 	return
 }
 
+// parseQuotedString parses the double-quoted string at the start of str,
+// returning its unescaped contents, the remainder of str following the closing
+// quote, and whether a closing quote was found at all. It accepts the escapes
+// that QuoteStructFieldName writes, and no others, and the contents must be
+// valid UTF-8.
+func parseQuotedString(str string) (s, rem string, closed bool, err error) {
+	d.PanicIfFalse(str[0] == '"')
+	// String is complicated because ] might be quoted, and " or \ might be escaped.
+	stringBuf := bytes.Buffer{}
+	i := 1
+
+	for ; i < len(str); i++ {
+		c := str[i]
+		if c == '"' {
+			closed = true
+			i++
+			break
+		}
+		if c == '\\' && i < len(str)-1 {
+			i++
+			switch c = str[i]; c {
+			case '\\', '"':
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'u':
+				var r uint64
+				if i+4 < len(str) {
+					r, err = strconv.ParseUint(str[i+1:i+5], 16, 16)
+				}
+				if i+4 >= len(str) || err != nil || !utf8.ValidRune(rune(r)) {
+					err = errors.New(`\u must be followed by 4 hex digits of a valid character`)
+					return
+				}
+				stringBuf.WriteRune(rune(r))
+				i += 4
+				continue
+			default:
+				err = errors.New(`Only ", \, \n, \r, \t and \u can be escaped`)
+				return
+			}
+		}
+		stringBuf.WriteByte(c)
+	}
+
+	if !utf8.Valid(stringBuf.Bytes()) {
+		err = errors.New("Quoted string isn't valid UTF-8")
+		return
+	}
+	return stringBuf.String(), str[i:], closed, nil
+}
+
 // TypeAnnotation is a PathPart annotation to resolve to the type of the value
 // it's resolved in.
 type TypeAnnotation struct {
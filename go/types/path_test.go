@@ -59,6 +59,18 @@ func TestPathStruct(t *testing.T) {
 	assertResolvesTo(assert, Number(203), v2, `.v1.baz`)
 	assertResolvesTo(assert, nil, v2, `.v1.notHere`)
 	assertResolvesTo(assert, nil, v2, `.notHere.v1`)
+
+	v3 := NewStruct("", StructData{
+		"first name": String("Ada"),
+		"1st":        v,
+		`qu"ote`:     Bool(true),
+	})
+
+	assertResolvesTo(assert, String("Ada"), v3, `."first name"`)
+	assertResolvesTo(assert, String("foo"), v3, `."1st".foo`)
+	assertResolvesTo(assert, Bool(true), v3, `."qu\"ote"`)
+	assertResolvesTo(assert, String("foo"), v3, `."1st"."foo"`)
+	assertResolvesTo(assert, nil, v3, `."last name"`)
 }
 
 func TestPathIndex(t *testing.T) {
@@ -247,6 +259,10 @@ func TestPathParseSuccess(t *testing.T) {
 	test(`["ಠ_ಠ"]`)
 	test(`["0"]["1"]["100"]`)
 	test(".foo[0].bar[4.5][false]")
+	test(`."first name"`)
+	test(`."1st".foo["bar"]`)
+	test(`."qu\\ote\""`)
+	test(`."ಠ_ಠ"`)
 	test(fmt.Sprintf(".foo[#%s]", h.String()))
 	test(fmt.Sprintf(".bar[#%s]@key", h.String()))
 }
@@ -273,6 +289,13 @@ func TestPathParseErrors(t *testing.T) {
 	test(". invalid.field", "Invalid field:  invalid.field")
 	test(".foo.", "Invalid field: ")
 	test(".foo.#invalid.field", "Invalid field: #invalid.field")
+	test(`."`, `Invalid field: "`)
+	test(`."foo`, `Invalid field: "foo`)
+	test(`.""`, `Invalid field: ""`)
+	test(`."\q"`, `Only ", \, \n, \r, \t and \u can be escaped`)
+	test(`."\u00"`, `\u must be followed by 4 hex digits of a valid character`)
+	test(`."\ud800"`, `\u must be followed by 4 hex digits of a valid character`)
+	test(".\"\xff\"", "Quoted string isn't valid UTF-8")
 	test(".foo!", "Invalid operator: !")
 	test(".foo!bar", "Invalid operator: !")
 	test(".foo#", "Invalid operator: #")
@@ -298,7 +321,7 @@ func TestPathParseErrors(t *testing.T) {
 	test(`.foo["]`, "[ is missing closing ]")
 	test(".foo[#]", "Invalid hash: ")
 	test(".foo[#invalid]", "Invalid hash: invalid")
-	test(`.foo["hello\nworld"]`, `Only " and \ can be escaped`)
+	test(`.foo["hello\qworld"]`, `Only ", \, \n, \r, \t and \u can be escaped`)
 	test(".foo[42]bar", "Invalid operator: b")
 	test("#foo", "Invalid operator: #")
 	test("!foo", "Invalid operator: !")
@@ -0,0 +1,262 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// Project returns the part of |v| that |t| describes. If |v| is a struct and
+// |t| is a struct type, the result is a struct of the same name that has only
+// the fields of |v| that |t| has, each projected onto the type |t| gives it.
+// Other values are returned as they are, so projection doesn't descend into
+// collections. The fields of |t| that |v| lacks are left out. Projection
+// selects fields; it doesn't check that their values are of the types that |t|
+// gives them.
+//
+// List.IterProjected and Map.IterProjected project the values of a collection
+// as they're decoded, which avoids decoding the fields that |t| leaves out.
+func Project(v Value, t *Type) Value {
+	s, ok := v.(Struct)
+	desc, isStruct := t.Desc.(StructDesc)
+	if !ok || !isStruct {
+		return v
+	}
+	fieldNames := make([]string, 0, len(desc.fields))
+	values := make([]Value, 0, len(desc.fields))
+	for i, name := range s.fieldNames {
+		if ft, _ := desc.Field(name); ft != nil {
+			fieldNames = append(fieldNames, name)
+			values = append(values, Project(s.values[i], ft))
+		}
+	}
+	return Struct{s.name, fieldNames, values, &hash.Hash{}}
+}
+
+// IterProjected calls |cb| with each value of |l|, in order, projected onto
+// |t| as by Project. The chunks of |l| that aren't in memory are decoded with
+// the projection, so the fields that |t| leaves out are skipped over rather
+// than decoded. When only a few fields of large structs are needed, this is
+// much cheaper than projecting the values Iter provides. |cb| returns true to
+// stop iterating.
+func (l List) IterProjected(t *Type, cb func(v Value, index uint64) (stop bool)) {
+	idx := uint64(0)
+	walkProjectedLeaves(l.seq, func(seq sequence) bool {
+		for _, v := range seq.(listLeafSequence).values {
+			if cb(Project(v, t), idx) {
+				return true
+			}
+			idx++
+		}
+		return false
+	}, func(dec *valueDecoder) bool {
+		count := dec.readCount()
+		for i := uint64(0); i < count; i++ {
+			if cb(dec.readProjectedValue(t), idx) {
+				return true
+			}
+			idx++
+		}
+		return false
+	})
+}
+
+// IterProjected calls |cb| with each entry of |m|, in order, with the value
+// projected onto |t| as by Project. Like List.IterProjected, it skips over the
+// fields that |t| leaves out rather than decoding them. Keys are decoded as
+// they are. |cb| returns true to stop iterating.
+func (m Map) IterProjected(t *Type, cb func(k, v Value) (stop bool)) {
+	walkProjectedLeaves(m.seq, func(seq sequence) bool {
+		for _, entry := range seq.(mapLeafSequence).data {
+			if cb(entry.key, Project(entry.value, t)) {
+				return true
+			}
+		}
+		return false
+	}, func(dec *valueDecoder) bool {
+		count := dec.readCount()
+		for i := uint64(0); i < count; i++ {
+			k := dec.readValue()
+			if cb(k, dec.readProjectedValue(t)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// chunkReader is implemented by ValueReaders that can provide the encoded
+// chunks of values, like ValueStore and the Databases that embed it.
+type chunkReader interface {
+	readChunk(h hash.Hash) chunks.Chunk
+}
+
+// walkProjectedLeaves visits the leaves of the prolly tree |seq|, in order. It
+// calls |inMemory| with each leaf sequence that's already decoded and
+// |encoded| with a decoder positioned at the items of each leaf chunk that
+// has to be read. Meta sequences are decoded as usual. Either callback returns
+// true to stop, in which case walkProjectedLeaves returns true.
+func walkProjectedLeaves(seq sequence, inMemory func(seq sequence) bool, encoded func(dec *valueDecoder) bool) bool {
+	ms, ok := seq.(metaSequence)
+	if !ok {
+		return inMemory(seq)
+	}
+	vr := ms.valueReader()
+	cr, ok := vr.(chunkReader)
+	for _, mt := range ms.tuples {
+		if mt.child != nil || !ok {
+			if walkProjectedLeaves(mt.getChildSequence(vr), inMemory, encoded) {
+				return true
+			}
+			continue
+		}
+
+		c := cr.readChunk(mt.ref.TargetHash())
+		d.PanicIfTrue(c.IsEmpty())
+		br := &binaryNomsReader{c.Data(), 0}
+		dec := newValueDecoder(br, vr)
+		k := dec.readKind()
+		d.PanicIfFalse(k == ms.Kind())
+		if dec.readBool() {
+			child := DecodeValue(c, vr).(Collection)
+			if walkProjectedLeaves(child.sequence(), inMemory, encoded) {
+				return true
+			}
+			continue
+		}
+		if encoded(dec) {
+			return true
+		}
+		d.PanicIfFalse(br.pos() == uint32(len(c.Data())))
+	}
+	return false
+}
+
+// readProjectedValue reads a value, projected onto |t| as by Project. The
+// fields of structs that |t| leaves out are skipped over.
+func (r *valueDecoder) readProjectedValue(t *Type) Value {
+	k := r.readKind()
+	desc, ok := t.Desc.(StructDesc)
+	if k != StructKind || !ok {
+		return r.readValueOfKind(k)
+	}
+
+	name := r.readString()
+	count := r.readCount()
+	allNames := make([]string, count)
+	for i := uint64(0); i < count; i++ {
+		allNames[i] = r.readString()
+	}
+
+	fieldNames := make([]string, 0, len(desc.fields))
+	values := make([]Value, 0, len(desc.fields))
+	for _, fieldName := range allNames {
+		ft, _ := desc.Field(fieldName)
+		if ft == nil {
+			r.skipValue()
+			continue
+		}
+		fieldNames = append(fieldNames, fieldName)
+		values = append(values, r.readProjectedValue(ft))
+	}
+	return Struct{name, fieldNames, values, &hash.Hash{}}
+}
+
+// skipValue moves past a value without decoding it.
+func (r *valueDecoder) skipValue() {
+	switch k := r.readKind(); k {
+	case BlobKind:
+		if r.readBool() {
+			r.skipMetaSequence()
+		} else {
+			r.readBytes()
+		}
+	case BoolKind:
+		r.readBool()
+	case NumberKind:
+		r.readNumber()
+	case StringKind:
+		r.readStringBytes()
+	case ListKind, SetKind:
+		if r.readBool() {
+			r.skipMetaSequence()
+			return
+		}
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.skipValue()
+		}
+	case MapKind:
+		if r.readBool() {
+			r.skipMetaSequence()
+			return
+		}
+		count := r.readCount()
+		for i := uint64(0); i < 2*count; i++ {
+			r.skipValue()
+		}
+	case RefKind:
+		r.readHash()
+		r.skipType()
+		r.readCount()
+	case StructKind:
+		r.readStringBytes()
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.readStringBytes()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.skipValue()
+		}
+	case TypeKind:
+		r.skipType()
+	default:
+		d.Chk.Fail(fmt.Sprintf("A value instance can never have type %s", k))
+	}
+}
+
+func (r *valueDecoder) skipMetaSequence() {
+	count := r.readCount()
+	for i := uint64(0); i < count; i++ {
+		r.skipValue() // the Ref to the child, or the inlined child
+		r.skipValue() // the key
+		r.readCount() // the number of leaves
+	}
+}
+
+func (r *valueDecoder) skipType() {
+	switch k := r.readKind(); k {
+	case ListKind, RefKind, SetKind:
+		r.skipType()
+	case MapKind:
+		r.skipType()
+		r.skipType()
+	case StructKind:
+		r.readStringBytes()
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.readStringBytes()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.skipType()
+		}
+		for i := uint64(0); i < count; i++ {
+			r.readBool()
+		}
+	case UnionKind:
+		count := r.readCount()
+		for i := uint64(0); i < count; i++ {
+			r.skipType()
+		}
+	case CycleKind:
+		r.readStringBytes()
+	default:
+		d.PanicIfFalse(IsPrimitiveKind(k))
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/testify/assert"
+)
+
+// wideStruct returns a struct with fields of every kind, so that projections
+// have to skip over each of them.
+func wideStruct(i int, big List) Struct {
+	data := StructData{
+		"blob":   NewBlob(bytes.NewBufferString(fmt.Sprintf("blob %d", i))),
+		"bool":   Bool(i%2 == 0),
+		"number": Number(i),
+		"string": String(fmt.Sprintf("s%d", i)),
+		"list":   NewList(Number(i), String("a")),
+		"set":    NewSet(Number(i)),
+		"map":    NewMap(String("k"), Number(i)),
+		"ref":    NewRef(Number(i)),
+		"type":   MakeListType(StringType),
+		"big":    big,
+		"inner": NewStruct("Inner", StructData{
+			"a": Number(i),
+			"b": String("b"),
+		}),
+	}
+	for j := 0; j < 30; j++ {
+		data[fmt.Sprintf("col%d", j)] = Number(i * j)
+	}
+	return NewStruct("Row", data)
+}
+
+var projectionType = MakeStructType("Row",
+	StructField{Name: "inner", Type: MakeStructType("Inner", StructField{Name: "b", Type: StringType})},
+	StructField{Name: "missing", Type: NumberType, Optional: true},
+	StructField{Name: "number", Type: NumberType},
+)
+
+func TestProject(t *testing.T) {
+	assert := assert.New(t)
+
+	s := wideStruct(3, NewList())
+	p := Project(s, projectionType)
+	assert.True(NewStruct("Row", StructData{
+		"inner":  NewStruct("Inner", StructData{"b": String("b")}),
+		"number": Number(3),
+	}).Equals(p))
+	assert.True(IsValueSubtypeOf(p, projectionType))
+
+	// Values that aren't structs, or aren't projected onto struct types, are
+	// left as they are.
+	assert.True(Number(1).Equals(Project(Number(1), projectionType)))
+	assert.True(s.Equals(Project(s, ValueType)))
+}
+
+func TestIterProjected(t *testing.T) {
+	assert := assert.New(t)
+
+	storage := &chunks.TestStorage{}
+	vs := NewValueStore(storage.NewView())
+	big := NewList(generateNumbersAsValues(2000)...)
+
+	const n = 600
+	rows := make([]Value, n)
+	kvs := make([]Value, 0, 2*n)
+	for i := range rows {
+		rows[i] = wideStruct(i, big)
+		kvs = append(kvs, Number(i), rows[i])
+	}
+	lh := vs.WriteValue(NewList(rows...)).TargetHash()
+	mh := vs.WriteValue(NewMap(kvs...)).TargetHash()
+	vs.persist()
+
+	// Read the collections back with a new ValueStore, so that their leaves
+	// have to be read from chunks.
+	vs = NewValueStore(storage.NewView())
+	l := vs.ReadValue(lh).(List)
+	m := vs.ReadValue(mh).(Map)
+	_, ok := l.seq.(metaSequence)
+	assert.True(ok)
+
+	for _, l := range []List{l, NewList(rows...)} {
+		count := uint64(0)
+		l.IterProjected(projectionType, func(v Value, idx uint64) bool {
+			assert.Equal(count, idx)
+			assert.True(Project(rows[idx], projectionType).Equals(v))
+			count++
+			return false
+		})
+		assert.Equal(uint64(n), count)
+	}
+
+	count := 0
+	m.IterProjected(projectionType, func(k, v Value) bool {
+		assert.True(Number(count).Equals(k))
+		assert.True(Project(rows[count], projectionType).Equals(v))
+		count++
+		return false
+	})
+	assert.Equal(n, count)
+
+	count = 0
+	l.IterProjected(projectionType, func(v Value, idx uint64) bool {
+		count++
+		return idx == 300
+	})
+	assert.Equal(301, count)
+}
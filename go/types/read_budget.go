@@ -0,0 +1,143 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// ReadBudget limits how much data a BudgetedValueReader may read. A zero
+// field means that there is no limit on that dimension.
+type ReadBudget struct {
+	// MaxBytes is the total size of the chunks that may be read.
+	MaxBytes uint64
+	// MaxChunks is the number of chunks, i.e. tree nodes, that may be read.
+	MaxChunks uint64
+}
+
+// ReadBudgetExceededError is the cause of the panic raised by a
+// BudgetedValueReader when reading a chunk would exceed its ReadBudget. It
+// can be recovered using d.Try(f, &ReadBudgetExceededError{}).
+type ReadBudgetExceededError struct {
+	Budget ReadBudget
+	// Bytes and Chunks are the totals that reading Hash would have brought
+	// the reader to.
+	Bytes, Chunks uint64
+	Hash          hash.Hash
+}
+
+func (e *ReadBudgetExceededError) Error() string {
+	return fmt.Sprintf("Reading chunk %s exceeds read budget (%d of %d bytes, %d of %d chunks)", e.Hash, e.Bytes, e.Budget.MaxBytes, e.Chunks, e.Budget.MaxChunks)
+}
+
+// BudgetedValueReader is a ValueReader that reads from a ValueStore but
+// panics with a *ReadBudgetExceededError once the data it has read would
+// exceed a ReadBudget. The budget is shared by all Values read through it, so
+// loading the chunks of a collection obtained from a BudgetedValueReader,
+// e.g. by iterating over it, is charged to the same budget. The reader does
+// not use the ValueStore's Value cache, so every chunk read is accounted for.
+type BudgetedValueReader struct {
+	vs     *ValueStore
+	budget ReadBudget
+
+	mu            sync.Mutex
+	bytes, chunks uint64
+}
+
+// NewBudgetedValueReader returns a BudgetedValueReader that reads from lvs
+// within budget.
+func (lvs *ValueStore) NewBudgetedValueReader(budget ReadBudget) *BudgetedValueReader {
+	return &BudgetedValueReader{vs: lvs, budget: budget}
+}
+
+// ReadValueWithBudget reads the value h from lvs and loads every chunk of it,
+// without following Refs or descending into Blobs, returning a
+// *ReadBudgetExceededError as soon as doing so would exceed budget. On
+// success further reads through the returned Value, e.g. of the chunks of
+// Values reached through its Refs, continue to be charged to budget.
+func (lvs *ValueStore) ReadValueWithBudget(h hash.Hash, budget ReadBudget) (v Value, err error) {
+	br := lvs.NewBudgetedValueReader(budget)
+	err = d.Try(func() {
+		v = br.ReadValue(h)
+		if v == nil {
+			return
+		}
+		WalkValues(v, br, func(v Value) bool {
+			_, isRef := v.(Ref)
+			return isRef
+		})
+	}, &ReadBudgetExceededError{})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Used returns the number of bytes and chunks read through br so far.
+func (br *BudgetedValueReader) Used() (bytes, chunks uint64) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.bytes, br.chunks
+}
+
+func (br *BudgetedValueReader) charge(c chunks.Chunk) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	bytes, chunks := br.bytes+uint64(len(c.Data())), br.chunks+1
+	if br.budget.MaxBytes > 0 && bytes > br.budget.MaxBytes || br.budget.MaxChunks > 0 && chunks > br.budget.MaxChunks {
+		panic(d.Wrap(&ReadBudgetExceededError{br.budget, bytes, chunks, c.Hash()}))
+	}
+	br.bytes, br.chunks = bytes, chunks
+}
+
+// ReadValue reads and decodes the value h, charging its chunk to the budget.
+// It returns nil if h is not present.
+func (br *BudgetedValueReader) ReadValue(h hash.Hash) Value {
+	br.vs.versOnce.Do(br.vs.expectVersion)
+	c := br.vs.getBufferedChunk(h)
+	if c.IsEmpty() {
+		c = br.vs.cs.Get(h)
+	}
+	if c.IsEmpty() {
+		return nil
+	}
+	br.charge(c)
+	return DecodeValue(c, br)
+}
+
+// ReadManyValues reads and decodes the values in hashes, charging each of
+// their chunks to the budget. Values that aren't present are ignored.
+func (br *BudgetedValueReader) ReadManyValues(hashes hash.HashSet, foundValues chan<- Value) {
+	br.vs.versOnce.Do(br.vs.expectVersion)
+	remaining := hash.HashSet{}
+	for h := range hashes {
+		if c := br.vs.getBufferedChunk(h); !c.IsEmpty() {
+			br.charge(c)
+			foundValues <- DecodeValue(c, br)
+			continue
+		}
+		remaining.Insert(h)
+	}
+	if len(remaining) == 0 {
+		return
+	}
+
+	foundChunks := make(chan *chunks.Chunk, 16)
+	go func() { br.vs.cs.GetMany(remaining, foundChunks); close(foundChunks) }()
+	defer func() {
+		// Drain the channel if charge panics so that GetMany can finish.
+		for range foundChunks {
+		}
+	}()
+	for c := range foundChunks {
+		br.charge(*c)
+		foundValues <- DecodeValue(*c, br)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestReadValueWithBudget(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	l := NewList(generateNumbersAsValues(10000)...)
+	assert.False(l.sequence().isLeaf())
+	s := NewStruct("S", StructData{"list": l, "ref": vs.WriteValue(NewList(generateNumbersAsValues(10000)...))})
+	h := vs.WriteValue(s).TargetHash()
+	vs.persist()
+
+	v, err := vs.ReadValueWithBudget(h, ReadBudget{})
+	assert.NoError(err)
+	assert.True(s.Equals(v))
+
+	_, err = vs.ReadValueWithBudget(h, ReadBudget{MaxChunks: 3})
+	assert.IsType(&ReadBudgetExceededError{}, err)
+	assert.Equal(uint64(4), err.(*ReadBudgetExceededError).Chunks)
+
+	_, err = vs.ReadValueWithBudget(h, ReadBudget{MaxBytes: 1024})
+	assert.IsType(&ReadBudgetExceededError{}, err)
+
+	// The Value behind "ref" isn't materialized, so a budget that covers s
+	// and its list is enough.
+	br := vs.NewBudgetedValueReader(ReadBudget{})
+	WalkValues(br.ReadValue(h), br, func(v Value) bool {
+		_, isRef := v.(Ref)
+		return isRef
+	})
+	bytes, chunks := br.Used()
+	v, err = vs.ReadValueWithBudget(h, ReadBudget{bytes, chunks})
+	assert.NoError(err)
+
+	assert.True(s.Equals(v))
+
+	v, err = vs.ReadValueWithBudget(hash.Parse("00000000000000000000000000000000"), ReadBudget{MaxChunks: 1})
+	assert.NoError(err)
+	assert.Nil(v)
+}
+
+func TestBudgetedValueReaderIteration(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	h := vs.WriteValue(NewList(generateNumbersAsValues(10000)...)).TargetHash()
+	vs.persist()
+
+	br := vs.NewBudgetedValueReader(ReadBudget{MaxChunks: 2})
+	l := br.ReadValue(h).(List)
+	err := d.Try(func() {
+		l.IterAll(func(v Value, i uint64) {})
+	}, &ReadBudgetExceededError{})
+	assert.IsType(&ReadBudgetExceededError{}, err)
+	_, chunks := br.Used()
+	assert.Equal(uint64(2), chunks)
+}
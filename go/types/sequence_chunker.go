@@ -19,6 +19,7 @@ type sequenceChunker struct {
 	hashValueBytes             hashValueBytesFn
 	rv                         *rollingValueHasher
 	done                       bool
+	inlineThreshold            int
 }
 
 // makeChunkFn takes a sequence of items to chunk, and returns the result of chunking those items, a tuple of a reference to that chunk which can itself be chunked + its underlying value.
@@ -35,6 +36,10 @@ func newSequenceChunker(cur *sequenceCursor, vr ValueReader, vw ValueWriter, mak
 
 	// |cur| will be nil if this is a new sequence, implying this is a new tree, or the tree has grown in height relative to its original chunked form.
 
+	inlineThreshold := inlineThresholdFor(vr)
+	if vw != nil {
+		inlineThreshold = inlineThresholdFor(vw)
+	}
 	sc := &sequenceChunker{
 		cur,
 		vr,
@@ -46,6 +51,7 @@ func newSequenceChunker(cur *sequenceCursor, vr ValueReader, vw ValueWriter, mak
 		hashValueBytes,
 		newRollingValueHasher(),
 		false,
+		inlineThreshold,
 	}
 
 	if cur != nil {
@@ -162,27 +168,53 @@ func (sc *sequenceChunker) createParent() {
 	sc.parent.isLeaf = false
 }
 
-func (sc *sequenceChunker) createSequence() (sequence, metaTuple) {
-	// If the sequence chunker has a ValueWriter, eagerly write sequences.
+// createSequence chunks the pending items. The root of a tree has no parent
+// to be inlined into, so it's written, if the chunker has a ValueWriter, no
+// matter how small it is.
+func (sc *sequenceChunker) createSequence(root bool) (sequence, metaTuple) {
 	col, key, numLeaves := sc.makeChunk(sc.current)
 	seq := col.sequence()
-	var ref Ref
-	if sc.vw != nil {
-		ref = sc.vw.WriteValue(col)
-		col = nil
-	} else {
-		ref = NewRef(col)
+	inlineThreshold := sc.inlineThreshold
+	if root {
+		inlineThreshold = 0
 	}
-	mt := newMetaTuple(ref, key, numLeaves, col)
+	mt := newChunkMetaTuple(col, key, numLeaves, sc.vw, inlineThreshold)
 
 	sc.current = []sequenceItem{}
 	return seq, mt
 }
 
+// newChunkMetaTuple returns the metaTuple that refers to the chunk |col|. If
+// the encoding of |col| is smaller than |inlineThreshold| bytes, it's inlined
+// into its parent. Otherwise, if |vw| isn't nil, |col| is eagerly written to it.
+func newChunkMetaTuple(col Collection, key orderedKey, numLeaves uint64, vw ValueWriter, inlineThreshold int) metaTuple {
+	if inlineThreshold > 0 && len(EncodeValue(col, nil).Data()) < inlineThreshold {
+		return newInlineMetaTuple(col, key, numLeaves)
+	}
+	if vw != nil {
+		return newMetaTuple(vw.WriteValue(col), key, numLeaves, nil)
+	}
+	return newMetaTuple(NewRef(col), key, numLeaves, col)
+}
+
+// inlineThresholdProvider is implemented by ValueReaders and ValueWriters,
+// e.g. ValueStore, that want the collections built on their behalf to inline
+// small chunks into their parents.
+type inlineThresholdProvider interface {
+	inlineThreshold() int
+}
+
+func inlineThresholdFor(v interface{}) int {
+	if itp, ok := v.(inlineThresholdProvider); ok {
+		return itp.inlineThreshold()
+	}
+	return 0
+}
+
 func (sc *sequenceChunker) handleChunkBoundary() {
 	d.Chk.NotEmpty(sc.current)
 
-	_, mt := sc.createSequence()
+	_, mt := sc.createSequence(false)
 	if sc.parent == nil {
 		sc.createParent()
 	}
@@ -227,7 +259,7 @@ func (sc *sequenceChunker) Done() sequence {
 
 	// (1) This is "leaf" chunker and thus produced tree of depth 1 which contains exactly one chunk (never hit a boundary), or (2) This in an internal node of the tree which contains multiple references to child nodes. In either case, this is the canonical root of the tree.
 	if sc.isLeaf || len(sc.current) > 1 {
-		seq, _ := sc.createSequence()
+		seq, _ := sc.createSequence(true)
 		return seq
 	}
 
@@ -24,13 +24,20 @@ func concat(fst, snd sequence, newSequenceChunker newSequenceChunkerFn) sequence
 		d.Panic("cannot concat sequences from different databases")
 	}
 	chunker := newSequenceChunker(newCursorAtIndex(fst, fst.numLeaves(), false), vr)
+	return finishChunkingAt(chunker, newCursorAtIndex(snd, 0, false))
+}
 
-	for cur, ch := newCursorAtIndex(snd, 0, false), chunker; cur != nil; cur = cur.parent {
-		// If fst is shallower than snd, its cur will have a parent whereas the
-		// chunker to snd won't. In that case, create a parent for fst.
-		// Note that if the inverse is true - snd is shallower than fst - this just
-		// means higher chunker levels will still have cursors from fst... which
-		// point to the end, so finalisation won't do anything. This is correct.
+// finishChunkingAt swaps the cursors of |chunker|, at every level, for those
+// of |cur|, so that it finalizes chunking up to the chunks that |cur| points
+// into, and returns the resulting sequence.
+func finishChunkingAt(chunker *sequenceChunker, cur *sequenceCursor) sequence {
+	for ch := chunker; cur != nil; cur = cur.parent {
+		// If the chunker is shallower than cur, it won't have a parent for each
+		// of cur's. In that case, create one.
+		// Note that if the inverse is true - cur is shallower than the chunker -
+		// this just means higher chunker levels will still have their own
+		// cursors... which point to the end, so finalisation won't do anything.
+		// This is correct.
 		if ch.parent == nil {
 			ch.createParent()
 		}
@@ -40,3 +47,24 @@ func concat(fst, snd sequence, newSequenceChunker newSequenceChunkerFn) sequence
 
 	return chunker.Done()
 }
+
+// slice returns a sequence of the items of |seq| from |start| (inclusive) to
+// |end| (exclusive). It uses the same trick as concat: chunking resumes at
+// end and is finalized at the end of seq, which drops the items after end,
+// and then starts afresh and is finalized at start, which keeps the items
+// after it. Only the chunks at either end of the slice are visited; those in
+// between are reused as they are.
+func slice(seq sequence, start, end uint64, newSequenceChunker newSequenceChunkerFn) sequence {
+	d.PanicIfFalse(start <= end)
+	d.PanicIfFalse(end <= seq.numLeaves())
+
+	vr := seq.valueReader()
+	if end < seq.numLeaves() {
+		chunker := newSequenceChunker(newCursorAtIndex(seq, end, false), vr)
+		seq = finishChunkingAt(chunker, newCursorAtIndex(seq, seq.numLeaves(), false))
+	}
+	if start > 0 {
+		seq = finishChunkingAt(newSequenceChunker(nil, vr), newCursorAtIndex(seq, start, false))
+	}
+	return seq
+}
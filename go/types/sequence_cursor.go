@@ -235,6 +235,14 @@ func (cur *sequenceCursor) iter(cb cursorIterCallback) {
 	}
 }
 
+// iterBackward iterates backward from the current position. Unlike iter, it
+// doesn't read ahead, so chunks are loaded one at a time as it reaches them.
+func (cur *sequenceCursor) iterBackward(cb cursorIterCallback) {
+	for cur.valid() && !cb(cur.getItem(cur.idx)) {
+		cur.retreat()
+	}
+}
+
 // newCursorAtIndex creates a new cursor over seq positioned at idx.
 //
 // Implemented by searching down the tree to the leaf sequence containing idx. Each
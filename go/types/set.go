@@ -221,6 +221,35 @@ func (s Set) IterAll(cb setIterAllCallback) {
 	})
 }
 
+// IterReverse calls |cb| for the values of the set from the largest to the
+// smallest, until it returns true. Only the chunks that are reached are read.
+func (s Set) IterReverse(cb setIterCallback) {
+	iterBackward(s.seq, func(v interface{}) bool {
+		return cb(v.(Value))
+	})
+}
+
+// IterAllReverse calls |cb| for every value of the set, from the largest to
+// the smallest.
+func (s Set) IterAllReverse(cb setIterAllCallback) {
+	iterBackward(s.seq, func(v interface{}) bool {
+		cb(v.(Value))
+		return false
+	})
+}
+
+// ReverseIterator returns a SetReverseIterator that starts at the largest
+// value of the set.
+func (s Set) ReverseIterator() SetReverseIterator {
+	return &setReverseIterator{newCursorAtEnd(s.seq)}
+}
+
+// ReverseIteratorFrom returns a SetReverseIterator that starts at the largest
+// value of the set that is less than or equal to |v|.
+func (s Set) ReverseIteratorFrom(v Value) SetReverseIterator {
+	return &setReverseIterator{newCursorAtOrBefore(s.seq, v)}
+}
+
 func (s Set) Iterator() SetIterator {
 	return s.IteratorAt(0)
 }
@@ -239,6 +268,134 @@ func (s Set) IteratorFrom(val Value) SetIterator {
 	}
 }
 
+// UnionMany returns a Set of the values that are in s or in any of |others|.
+// All the sets are merged in a single pass.
+func (s Set) UnionMany(others ...Set) Set {
+	iters := make([]SetIterator, 0, len(others)+1)
+	for _, set := range append([]Set{s}, others...) {
+		iters = append(iters, set.Iterator())
+	}
+	return newSetFromIterator(NewUnionManyIterator(iters...))
+}
+
+// IntersectMany returns a Set of the values that are in s and in all of
+// |others|. The sets are walked together, smallest first, skipping over the
+// parts of the larger ones that can't contain any of the result, so it's
+// fast to intersect many large sets when the result is small.
+func (s Set) IntersectMany(others ...Set) Set {
+	sets := append([]Set{s}, others...)
+	sort.Sort(setsByLen(sets))
+	if sets[0].Empty() {
+		return NewSet()
+	}
+	iters := make([]SetIterator, len(sets))
+	for i, set := range sets {
+		iters[i] = set.Iterator()
+	}
+	return newSetFromIterator(NewIntersectionManyIterator(iters...))
+}
+
+// Union returns a Set of the values that are in s or in |other|. The sets are
+// diffed first, which skips the subtrees they share, and then the values that
+// are only in one of them are inserted into the other, so it's fast to union
+// sets that are mostly the same, e.g. versions of a set that have diverged
+// a little. If they differ too much for that to pay off, they're merged as
+// UnionMany does.
+func (s Set) Union(other Set) Set {
+	onlyS, onlyOther, ok := s.valuesOnlyIn(other, func(onlyS, onlyOther ValueSlice) bool {
+		return !setEditsPayOff(s, onlyOther) && !setEditsPayOff(other, onlyS)
+	})
+	if !ok {
+		return s.UnionMany(other)
+	}
+	if !setEditsPayOff(s, onlyOther) || len(onlyS) < len(onlyOther) && setEditsPayOff(other, onlyS) {
+		return other.Insert(onlyS...)
+	}
+	return s.Insert(onlyOther...)
+}
+
+// Intersect returns a Set of the values that are in both s and |other|. Like
+// Union, it skips the subtrees the sets share, and removes the values that
+// are only in one of them from it, or merges them as IntersectMany does if
+// they differ too much.
+func (s Set) Intersect(other Set) Set {
+	onlyS, onlyOther, ok := s.valuesOnlyIn(other, func(onlyS, onlyOther ValueSlice) bool {
+		return !setEditsPayOff(s, onlyS) && !setEditsPayOff(other, onlyOther)
+	})
+	if !ok {
+		return s.IntersectMany(other)
+	}
+	if !setEditsPayOff(s, onlyS) || len(onlyOther) < len(onlyS) && setEditsPayOff(other, onlyOther) {
+		return other.Remove(onlyOther...)
+	}
+	return s.Remove(onlyS...)
+}
+
+// Difference returns a Set of the values that are in s but not in |other|.
+// Like Union, it skips the subtrees the sets share, so its cost depends on
+// how much they differ rather than on their size.
+func (s Set) Difference(other Set) Set {
+	onlyS, _, _ := s.valuesOnlyIn(other, nil)
+	return NewSet(onlyS...)
+}
+
+// valuesOnlyIn returns the values that are in s but not |other|, and those
+// that are in |other| but not s, by diffing them. If |tooMany| isn't nil, it's
+// called with the values found so far as each is found, and once it returns
+// true the diff is stopped, and valuesOnlyIn returns false.
+func (s Set) valuesOnlyIn(other Set, tooMany func(onlyS, onlyOther ValueSlice) bool) (onlyS, onlyOther ValueSlice, ok bool) {
+	changes := make(chan ValueChanged)
+	stop := make(chan struct{})
+	go func() {
+		s.Diff(other, changes, stop)
+		close(changes)
+	}()
+	for change := range changes {
+		switch change.ChangeType {
+		case DiffChangeAdded:
+			onlyS = append(onlyS, change.Key)
+		case DiffChangeRemoved:
+			onlyOther = append(onlyOther, change.Key)
+		default:
+			panic("unexpected change type")
+		}
+		if tooMany != nil && tooMany(onlyS, onlyOther) {
+			close(stop)
+			for range changes {
+			}
+			return onlyS, onlyOther, false
+		}
+	}
+	return onlyS, onlyOther, true
+}
+
+// setEditsPayOff returns true if it's cheaper to insert or remove |edits| one
+// at a time than to rebuild a Set the size of |base|. Each edit rewrites a
+// path through the tree, so edits only pay off while they're few.
+func setEditsPayOff(base Set, edits ValueSlice) bool {
+	return uint64(len(edits))*setEditCost <= base.Len()
+}
+
+// setEditCost is roughly the cost of an edit of a Set, in values appended by
+// a rebuild.
+const setEditCost = 16
+
+type setsByLen []Set
+
+func (s setsByLen) Len() int           { return len(s) }
+func (s setsByLen) Less(i, j int) bool { return s[i].Len() < s[j].Len() }
+func (s setsByLen) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// newSetFromIterator returns a Set of the values returned by |iter|, which
+// must be in noms-defined order without duplicates.
+func newSetFromIterator(iter SetIterator) Set {
+	ch := newEmptySetSequenceChunker(nil, nil)
+	for v := iter.Next(); v != nil; v = iter.Next() {
+		ch.Append(v)
+	}
+	return newSet(ch.Done().(orderedSequence))
+}
+
 func buildSetData(values ValueSlice) ValueSlice {
 	if len(values) == 0 {
 		return ValueSlice{}
@@ -5,6 +5,8 @@
 package types
 
 import (
+	"container/heap"
+
 	"github.com/attic-labs/noms/go/d"
 )
 
@@ -31,6 +33,27 @@ type SetIterator interface {
 	SkipTo(v Value) Value
 }
 
+// SetReverseIterator iterates through a set from the largest value to the
+// smallest.
+type SetReverseIterator interface {
+	// Next returns preceding values from a set. It returns nil, when no
+	// objects remain.
+	Next() Value
+}
+
+type setReverseIterator struct {
+	cursor *sequenceCursor
+}
+
+func (si *setReverseIterator) Next() Value {
+	if !si.cursor.valid() {
+		return nil
+	}
+	v := si.cursor.current().(Value)
+	si.cursor.retreat()
+	return v
+}
+
 type setIterator struct {
 	s            Set
 	cursor       *sequenceCursor
@@ -191,6 +214,136 @@ func (i *IntersectionIterator) SkipTo(v Value) Value {
 	return i.Next()
 }
 
+// UnionManyIterator returns the values returned by any of its child iterators, merging them all at
+// once rather than pairwise, so that each value costs Log(N) comparisons for N children. The values
+// from Next() are returned in noms-defined order with all duplicates removed.
+type UnionManyIterator struct {
+	states iterStateHeap
+}
+
+// NewUnionManyIterator creates a union iterator from any number of other SetIterators.
+func NewUnionManyIterator(iters ...SetIterator) SetIterator {
+	u := &UnionManyIterator{}
+	for _, iter := range iters {
+		d.PanicIfTrue(iter == nil)
+		if v := iter.Next(); v != nil {
+			u.states = append(u.states, &iterState{i: iter, v: v})
+		}
+	}
+	heap.Init(&u.states)
+	return u
+}
+
+func (u *UnionManyIterator) Next() Value {
+	if len(u.states) == 0 {
+		return nil
+	}
+	res := u.states[0].v
+	for len(u.states) > 0 && compareValue(u.states[0].v, res) == 0 {
+		if u.states[0].Next(); u.states[0].v == nil {
+			heap.Pop(&u.states)
+		} else {
+			heap.Fix(&u.states, 0)
+		}
+	}
+	return res
+}
+
+func (u *UnionManyIterator) SkipTo(v Value) Value {
+	d.PanicIfTrue(v == nil)
+	states := u.states[:0]
+	for _, st := range u.states {
+		if compareValue(st.v, v) < 0 {
+			st.SkipTo(v)
+		}
+		if st.v != nil {
+			states = append(states, st)
+		}
+	}
+	u.states = states
+	heap.Init(&u.states)
+	return u.Next()
+}
+
+// iterStateHeap is a min-heap of iterStates, ordered by their current values.
+type iterStateHeap []*iterState
+
+func (h iterStateHeap) Len() int            { return len(h) }
+func (h iterStateHeap) Less(i, j int) bool  { return compareValue(h[i].v, h[j].v) < 0 }
+func (h iterStateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *iterStateHeap) Push(x interface{}) { *h = append(*h, x.(*iterState)) }
+func (h *iterStateHeap) Pop() interface{} {
+	old := *h
+	st := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return st
+}
+
+// IntersectionManyIterator only returns values that are returned by all of its child iterators. Rather
+// than comparing them pairwise, each child is skipped straight to the largest value any of them is
+// at, so the cost is bounded by the child with the fewest values. The values from Next() are returned
+// in noms-defined order with all duplicates removed.
+type IntersectionManyIterator struct {
+	states []*iterState
+}
+
+// NewIntersectionManyIterator creates an intersection iterator from any number of other
+// SetIterators. It works best if the iterators are given in order of increasing size.
+func NewIntersectionManyIterator(iters ...SetIterator) SetIterator {
+	i := &IntersectionManyIterator{}
+	for _, iter := range iters {
+		d.PanicIfTrue(iter == nil)
+		i.states = append(i.states, &iterState{i: iter, v: iter.Next()})
+	}
+	return i
+}
+
+func (i *IntersectionManyIterator) Next() Value {
+	if len(i.states) == 0 {
+		return nil
+	}
+	for {
+		max := i.states[0].v
+		for _, st := range i.states[1:] {
+			if compareValue(st.v, max) > 0 {
+				max = st.v
+			}
+		}
+		if max == nil {
+			return nil
+		}
+		agreed := true
+		for _, st := range i.states {
+			if compareValue(st.v, max) < 0 {
+				st.SkipTo(max)
+			}
+			if st.v == nil {
+				return nil
+			}
+			if compareValue(st.v, max) != 0 {
+				agreed = false
+				break
+			}
+		}
+		if agreed {
+			for _, st := range i.states {
+				st.Next()
+			}
+			return max
+		}
+	}
+}
+
+func (i *IntersectionManyIterator) SkipTo(v Value) Value {
+	d.PanicIfTrue(v == nil)
+	for _, st := range i.states {
+		if compareValue(st.v, v) < 0 {
+			st.SkipTo(v)
+		}
+	}
+	return i.Next()
+}
+
 // considers nil max value, return -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
 func compareValue(v1, v2 Value) int {
 	if v1 == nil && v2 == nil {
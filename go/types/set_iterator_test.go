@@ -349,3 +349,100 @@ func iterize(iters []SetIterator, newIter newIterFunc, cntr *int) SetIterator {
 	}
 	return iterize(newIters, newIter, cntr)
 }
+
+func TestUnionManyIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	set1 := NewSet(generateNumbersAsValuesFromToBy(0, 10, 1)...)
+	set2 := NewSet(generateNumbersAsValuesFromToBy(5, 15, 1)...)
+	set3 := NewSet(generateNumbersAsValuesFromToBy(10, 20, 1)...)
+	set4 := NewSet(generateNumbersAsValuesFromToBy(15, 25, 1)...)
+
+	vs := iterToSlice(NewUnionManyIterator(set1.Iterator(), set2.Iterator(), set3.Iterator(), set4.Iterator(), NewSet().Iterator()))
+	expectedRes := generateNumbersAsValues(25)
+	assert.True(vs.Equals(expectedRes), "Expected: %v != actual: %v", expectedRes, vs)
+
+	ui := NewUnionManyIterator(set1.Iterator(), set4.Iterator(), set3.Iterator(), set2.Iterator())
+	assert.Panics(func() { ui.SkipTo(nil) })
+	assert.Equal(Number(0), ui.SkipTo(Number(-5)))
+	assert.Equal(Number(5), ui.SkipTo(Number(5)))
+	assert.Equal(Number(8), ui.SkipTo(Number(8)))
+	assert.Equal(Number(9), ui.SkipTo(Number(8)))
+	assert.Equal(Number(10), ui.SkipTo(Number(8)))
+	assert.Equal(Number(11), ui.SkipTo(Number(7)))
+	assert.Equal(Number(12), ui.Next())
+	assert.Equal(Number(15), ui.SkipTo(Number(15)))
+	assert.Equal(Number(24), ui.SkipTo(Number(24)))
+	assert.Nil(ui.SkipTo(Number(25)))
+	assert.Nil(ui.Next())
+
+	assert.Nil(NewUnionManyIterator().Next())
+}
+
+func TestIntersectionManyIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	byTwos := NewSet(generateNumbersAsValuesFromToBy(0, 200, 2)...)
+	byThrees := NewSet(generateNumbersAsValuesFromToBy(0, 200, 3)...)
+	byFives := NewSet(generateNumbersAsValuesFromToBy(0, 200, 5)...)
+
+	vs := iterToSlice(NewIntersectionManyIterator(byTwos.Iterator(), byThrees.Iterator(), byFives.Iterator()))
+	expectedRes := generateNumbersAsValuesFromToBy(0, 200, 30)
+	assert.True(vs.Equals(expectedRes), "Expected: %v != actual: %v", expectedRes, vs)
+
+	it := NewIntersectionManyIterator(byThrees.Iterator(), byFives.Iterator(), byTwos.Iterator())
+	assert.Panics(func() { it.SkipTo(nil) })
+	assert.Equal(Number(30), it.SkipTo(Number(5)))
+	assert.Equal(Number(60), it.SkipTo(Number(60)))
+	assert.Equal(Number(90), it.SkipTo(Number(5)))
+	assert.Equal(Number(120), it.Next())
+	assert.Equal(Number(150), it.SkipTo(Number(150)))
+	assert.Nil(it.SkipTo(Number(40000)))
+
+	assert.Nil(NewIntersectionManyIterator().Next())
+	assert.Nil(NewIntersectionManyIterator(byTwos.Iterator(), NewSet().Iterator()).Next())
+}
+
+// Unlike a binary tree of pairwise iterators, merging N sets at once costs Log(N) comparisons per
+// element for unions, and intersections only advance as far as the smallest set allows.
+func TestManyIteratorComplexity(t *testing.T) {
+	assert := assert.New(t)
+
+	numSets := 256
+	numElemsPerSet := 1000
+
+	counted := func(iters []SetIterator, cntr *int) []SetIterator {
+		for i, iter := range iters {
+			iters[i] = &countingSetIterator{iter, cntr}
+		}
+		return iters
+	}
+
+	calls := 0
+	vs := iterToSlice(NewUnionManyIterator(counted(createSetsWithDistinctNumbers(numSets, numElemsPerSet), &calls)...))
+	expected := generateNumbersAsValueSlice(numSets * numElemsPerSet)
+	assert.True(expected.Equals(vs), "expected: %v != actual: %v", expected, vs)
+	// Each child is advanced once per element it holds, plus once at the end.
+	assert.Equal(numSets*numElemsPerSet+numSets, calls)
+
+	calls = 0
+	vs = iterToSlice(NewIntersectionManyIterator(counted(createSetsWithDistinctNumbers(numSets, numElemsPerSet), &calls)...))
+	assert.Empty(vs)
+	// Skipping means that most elements are never visited.
+	assert.True(calls < numSets*numElemsPerSet/50, "callCount: %d", calls)
+}
+
+type countingSetIterator struct {
+	SetIterator
+	cntr *int
+}
+
+func (i *countingSetIterator) Next() Value {
+	*i.cntr++
+	return i.SetIterator.Next()
+}
+
+func (i *countingSetIterator) SkipTo(v Value) Value {
+	*i.cntr++
+	return i.SetIterator.SkipTo(v)
+}
@@ -669,6 +669,142 @@ func TestSetIterAll2(t *testing.T) {
 	doTest(getTestRefToValueOrderSet(2, newTestValueStore()))
 }
 
+func TestSetIterReverse(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	doTest := func(ts testSet) {
+		set := ts.toSet()
+		sort.Sort(ValueSlice(ts))
+		idx := len(ts)
+
+		set.IterAllReverse(func(v Value) {
+			idx--
+			assert.True(ts[idx].Equals(v))
+		})
+		assert.Equal(0, idx)
+
+		idx = len(ts)
+		set.IterReverse(func(v Value) bool {
+			idx--
+			assert.True(ts[idx].Equals(v))
+			return idx == len(ts)-64
+		})
+		assert.Equal(len(ts)-64, idx)
+	}
+
+	doTest(getTestNativeOrderSet(16))
+	doTest(getTestRefValueOrderSet(2))
+	doTest(getTestRefToNativeOrderSet(2, newTestValueStore()))
+	doTest(getTestRefToValueOrderSet(2, newTestValueStore()))
+
+	NewSet().IterAllReverse(func(v Value) {
+		assert.Fail("empty set has no values")
+	})
+}
+
+func TestSetReverseIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	doTest := func(ts testSet) {
+		set := ts.toSet()
+		sort.Sort(ValueSlice(ts))
+
+		it := set.ReverseIterator()
+		for i := len(ts) - 1; i >= 0; i-- {
+			assert.True(ts[i].Equals(it.Next()))
+		}
+		assert.Nil(it.Next())
+
+		mid := len(ts) / 2
+		it = set.ReverseIteratorFrom(ts[mid])
+		for i := mid; i >= mid-64; i-- {
+			assert.True(ts[i].Equals(it.Next()))
+		}
+	}
+
+	doTest(getTestNativeOrderSet(16))
+	doTest(getTestRefValueOrderSet(2))
+	doTest(getTestRefToNativeOrderSet(2, newTestValueStore()))
+	doTest(getTestRefToValueOrderSet(2, newTestValueStore()))
+
+	// Strings sort after Numbers, so every value of a large set of Numbers is
+	// less than one.
+	ts := getTestNativeOrderSet(16)
+	sort.Sort(ValueSlice(ts))
+	assert.True(ts[len(ts)-1].Equals(ts.toSet().ReverseIteratorFrom(String("a")).Next()))
+
+	s := NewSet(Number(1), Number(3), Number(5))
+	assert.Equal(Number(3), s.ReverseIteratorFrom(Number(4)).Next())
+	assert.Equal(Number(5), s.ReverseIteratorFrom(Number(100)).Next())
+	assert.Nil(s.ReverseIteratorFrom(Number(0)).Next())
+	assert.Nil(NewSet().ReverseIterator().Next())
+	assert.Nil(NewSet().ReverseIteratorFrom(Number(1)).Next())
+}
+
+func TestSetUnionIntersectDifference(t *testing.T) {
+	assert := assert.New(t)
+
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	test := func(a, b Set) {
+		intersect, difference := NewSet(), NewSet()
+		a.IterAll(func(v Value) {
+			if b.Has(v) {
+				intersect = intersect.Insert(v)
+			} else {
+				difference = difference.Insert(v)
+			}
+		})
+		union := a.UnionMany(b)
+
+		assert.True(union.Equals(a.Union(b)))
+		assert.True(union.Equals(b.Union(a)))
+		assert.True(intersect.Equals(a.Intersect(b)))
+		assert.True(intersect.Equals(b.Intersect(a)))
+		assert.True(difference.Equals(a.Difference(b)))
+	}
+
+	nums := generateNumbersAsValues(1000)
+	big := NewSet(nums...)
+	assert.False(big.sequence().isLeaf())
+
+	test(NewSet(), NewSet())
+	test(big, NewSet())
+	test(NewSet(), big)
+	test(big, big)
+	test(NewSet(Number(1), Number(2)), NewSet(Number(2), Number(3)))
+
+	// Sets that differ a little are edited.
+	changed := big.Remove(Number(10), Number(500)).Insert(Number(-1), Number(2000))
+	test(big, changed)
+	test(changed, big)
+	test(big, big.Remove(nums[300:310]...))
+
+	// Sets that differ a lot are merged.
+	shifted := NewSet(generateNumbersAsValuesFromToBy(500, 1500, 1)...)
+	test(big, shifted)
+	test(big, NewSet(generateNumbersAsValuesFromToBy(0, 1000, 3)...))
+
+	// The diff stops as soon as it's clear that edits won't pay off.
+	tooMany := func(onlyS, onlyOther ValueSlice) bool {
+		return !setEditsPayOff(big, onlyOther) && !setEditsPayOff(shifted, onlyS)
+	}
+	onlyS, onlyOther, ok := big.valuesOnlyIn(shifted, tooMany)
+	assert.False(ok)
+	assert.True(len(onlyS)+len(onlyOther) < 1000, "diffed %d values", len(onlyS)+len(onlyOther))
+	onlyS, onlyOther, ok = big.valuesOnlyIn(changed, tooMany)
+	assert.True(ok)
+	assert.Len(onlyS, 2)
+	assert.Len(onlyOther, 2)
+}
+
 func testSetOrder(assert *assert.Assertions, valueType *Type, value []Value, expectOrdering []Value) {
 	m := NewSet(value...)
 	i := 0
@@ -1031,3 +1167,25 @@ func TestSetWithNil(t *testing.T) {
 		NewSet(Number(42), nil)
 	})
 }
+
+func TestSetUnionIntersectMany(t *testing.T) {
+	assert := assert.New(t)
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	byTwos := NewSet(generateNumbersAsValuesFromToBy(0, 3000, 2)...)
+	byThrees := NewSet(generateNumbersAsValuesFromToBy(0, 3000, 3)...)
+	byFives := NewSet(generateNumbersAsValuesFromToBy(0, 3000, 5)...)
+
+	expected := NewSet(generateNumbersAsValuesFromToBy(0, 3000, 30)...)
+	assert.True(expected.Equals(byTwos.IntersectMany(byThrees, byFives)))
+	assert.True(expected.Equals(byFives.IntersectMany(byTwos, byThrees)))
+	assert.True(byTwos.Equals(byTwos.IntersectMany()))
+	assert.True(NewSet().Equals(byTwos.IntersectMany(byThrees, NewSet())))
+
+	union := byTwos.UnionMany(byThrees, byFives)
+	expected = byTwos.Insert(generateNumbersAsValuesFromToBy(0, 3000, 3)...).Insert(generateNumbersAsValuesFromToBy(0, 3000, 5)...)
+	assert.True(expected.Equals(union))
+	assert.True(union.Equals(byFives.UnionMany(NewSet(), byThrees, byTwos)))
+	assert.True(byTwos.Equals(byTwos.UnionMany()))
+}
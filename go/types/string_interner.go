@@ -0,0 +1,67 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "sync"
+
+const (
+	// Only strings this short are interned. Longer ones are unlikely to repeat
+	// and would bloat the table.
+	maxInternedStringLen = 64
+	// Once the table reaches this many entries it's cleared, rather than grown,
+	// which bounds the memory it holds on to.
+	maxInternedStrings = 1 << 16
+)
+
+// stringInterner dedupes the storage of short strings (String values, and
+// struct and field names) seen while decoding, so that decoding millions of
+// structs with identical field sets doesn't allocate a fresh copy of every
+// field name for each one. Each ValueStore owns one, shared by every value it
+// decodes. It is safe for concurrent use.
+type stringInterner struct {
+	mu   sync.RWMutex
+	strs map[string]string
+}
+
+func newStringInterner() *stringInterner {
+	return &stringInterner{strs: map[string]string{}}
+}
+
+// intern returns a string equal to b, reusing a previously returned one if
+// possible.
+func (si *stringInterner) intern(b []byte) string {
+	if len(b) > maxInternedStringLen {
+		return string(b)
+	}
+
+	si.mu.RLock()
+	s, ok := si.strs[string(b)] // The compiler elides the allocation here.
+	si.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = string(b)
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	if len(si.strs) >= maxInternedStrings {
+		si.strs = map[string]string{}
+	}
+	si.strs[s] = s
+	return s
+}
+
+// internerProvider is implemented by ValueReaders, e.g. ValueStore, which
+// want the values decoded on their behalf to share interned strings.
+type internerProvider interface {
+	interner() *stringInterner
+}
+
+func internerFor(vr ValueReader) *stringInterner {
+	if ip, ok := vr.(internerProvider); ok {
+		return ip.interner()
+	}
+	return nil
+}
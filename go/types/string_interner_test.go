@@ -0,0 +1,60 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestStringInterner(t *testing.T) {
+	assert := assert.New(t)
+
+	si := newStringInterner()
+	s1 := si.intern([]byte("name"))
+	s2 := si.intern([]byte("name"))
+	assert.Equal("name", s1)
+	assert.Equal(stringData(s1), stringData(s2))
+
+	long := strings.Repeat("x", maxInternedStringLen+1)
+	l1, l2 := si.intern([]byte(long)), si.intern([]byte(long))
+	assert.Equal(long, l1)
+	assert.NotEqual(stringData(l1), stringData(l2))
+}
+
+func TestStringInternerBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	si := newStringInterner()
+	for i := 0; i <= maxInternedStrings; i++ {
+		si.intern([]byte(strconv.Itoa(i)))
+	}
+	assert.True(len(si.strs) <= maxInternedStrings)
+}
+
+func TestValueStoreInternsDecodedStrings(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	r1 := vs.WriteValue(NewStruct("Person", StructData{"name": String("bob"), "age": Number(1)}))
+	r2 := vs.WriteValue(NewStruct("Person", StructData{"name": String("bob"), "age": Number(2)}))
+	vs.persist()
+
+	vs = NewValueStore(vs.ChunkStore())
+	s1 := vs.ReadValue(r1.TargetHash()).(Struct)
+	s2 := vs.ReadValue(r2.TargetHash()).(Struct)
+	assert.Equal(stringData(s1.name), stringData(s2.name))
+	assert.Equal(stringData(s1.fieldNames[0]), stringData(s2.fieldNames[0]))
+	assert.Equal(stringData(string(s1.Get("name").(String))), stringData(string(s2.Get("name").(String))))
+}
@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
@@ -164,6 +166,16 @@ func (s Struct) IterFields(cb func(name string, value Value)) {
 	}
 }
 
+// IterFieldTypes iterates over the fields, calling cb with the name, value and
+// type of every field in the struct. The type is TypeOf(value), which is the
+// type of the field in TypeOf(s) except where simplifying the struct's type
+// merges it with structs of the same name nested inside it.
+func (s Struct) IterFieldTypes(cb func(name string, value Value, t *Type)) {
+	for i := 0; i < len(s.fieldNames); i++ {
+		cb(s.fieldNames[i], s.values[i], TypeOf(s.values[i]))
+	}
+}
+
 func (s Struct) Kind() NomsKind {
 	return StructKind
 }
@@ -374,12 +386,73 @@ func EscapeStructField(input string) string {
 	return escapeField(input, encode)
 }
 
-// IsValidStructFieldName returns whether the name is valid as a field name in a struct.
-// Valid names must start with `a-zA-Z` and after that `a-zA-Z0-9_`.
+// IsValidStructFieldName returns whether the name is valid as a bare field
+// name in a struct, i.e. one that can be written without quoting in paths and
+// in the human readable encoding. Valid names must start with `a-zA-Z` and
+// after that `a-zA-Z0-9_`. Structs themselves accept any field name for which
+// IsLegalStructFieldName is true.
 func IsValidStructFieldName(name string) bool {
 	return fieldNameRe.MatchString(name)
 }
 
+// IsLegalStructFieldName returns whether the name may be used as a field name
+// in a struct, which is the case for any non-empty UTF-8 string. Names which
+// aren't also IsValidStructFieldName are quoted when written in paths and in
+// the human readable encoding, e.g. `."first name"`.
+func IsLegalStructFieldName(name string) bool {
+	return name != "" && utf8.ValidString(name)
+}
+
+// QuoteStructFieldName returns name as-is if it is IsValidStructFieldName,
+// and otherwise as a double-quoted string. In it, `"` and `\` are escaped by a
+// leading `\`, newline, carriage return and tab are written as `\n`, `\r` and
+// `\t`, and other control characters as `\u` and 4 hex digits. All other
+// characters are written as they are. These are the only escapes that paths
+// and nomdl accept in quoted names, and UnquoteStructFieldName undoes them.
+func QuoteStructFieldName(name string) string {
+	if IsValidStructFieldName(name) {
+		return name
+	}
+	buf := bytes.Buffer{}
+	buf.WriteByte('"')
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// UnquoteStructFieldName returns the name that QuoteStructFieldName quoted as
+// |quoted|, which must be a whole double-quoted string. An error is returned
+// if it uses other escapes, or the name isn't IsLegalStructFieldName.
+func UnquoteStructFieldName(quoted string) (string, error) {
+	if len(quoted) == 0 || quoted[0] != '"' {
+		return "", fmt.Errorf("Invalid field name %s", quoted)
+	}
+	name, rem, closed, err := parseQuotedString(quoted)
+	if err != nil {
+		return "", fmt.Errorf("Invalid field name %s: %s", quoted, err)
+	}
+	if !closed || rem != "" || !IsLegalStructFieldName(name) {
+		return "", fmt.Errorf("Invalid field name %s", quoted)
+	}
+	return name, nil
+}
+
 func verifyFields(fs structTypeFields) {
 	for i, f := range fs {
 		verifyFieldName(f.Name)
@@ -396,7 +469,9 @@ func verifyName(name, kind string) {
 }
 
 func verifyFieldName(name string) {
-	verifyName(name, " field")
+	if !IsLegalStructFieldName(name) {
+		d.Panic(`Invalid struct field name: "%s"`, name)
+	}
 }
 
 func verifyStructName(name string) {
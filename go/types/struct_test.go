@@ -267,14 +267,7 @@ func TestMakeStructTemplate(t *testing.T) {
 	}
 
 	assertInvalidFieldName("")
-	assertInvalidFieldName(" ")
-	assertInvalidFieldName(" a")
-	assertInvalidFieldName("a ")
-	assertInvalidFieldName("0")
-	assertInvalidFieldName("_")
-	assertInvalidFieldName("0a")
-	assertInvalidFieldName("_a")
-	assertInvalidFieldName("💩")
+	assertInvalidFieldName("\xff")
 
 	assertValidFieldName := func(n string) {
 		MakeStructTemplate("", []string{n})
@@ -285,6 +278,14 @@ func TestMakeStructTemplate(t *testing.T) {
 	assertValidFieldName("a0")
 	assertValidFieldName("a_")
 	assertValidFieldName("a0_")
+	assertValidFieldName(" ")
+	assertValidFieldName(" a")
+	assertValidFieldName("a ")
+	assertValidFieldName("0")
+	assertValidFieldName("_")
+	assertValidFieldName("0a")
+	assertValidFieldName("_a")
+	assertValidFieldName("💩")
 
 	assertInvalidFieldOrder := func(n []string) {
 		assert.Panics(func() {
@@ -311,6 +312,64 @@ func TestMakeStructTemplate(t *testing.T) {
 	}).Equals(str))
 }
 
+func TestStructArbitraryFieldNames(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewStruct("S", StructData{
+		"first name": String("Ada"),
+		"1st":        Number(1),
+		"ümlaut":     Bool(true),
+		"plain":      Number(2),
+	})
+	assert.Equal(String("Ada"), s.Get("first name"))
+	assert.Equal(Number(1), s.Get("1st"))
+	assert.Equal(Bool(true), s.Get("ümlaut"))
+
+	vs := newTestValueStore()
+	s2 := vs.ReadValue(vs.WriteValue(s).TargetHash()).(Struct)
+	assert.True(s.Equals(s2))
+	assert.True(TypeOf(s).Equals(TypeOf(s2)))
+}
+
+func TestQuoteStructFieldName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("a", QuoteStructFieldName("a"))
+	assert.Equal("a0_", QuoteStructFieldName("a0_"))
+	assert.Equal(`"first name"`, QuoteStructFieldName("first name"))
+	assert.Equal(`"0a"`, QuoteStructFieldName("0a"))
+	assert.Equal(`"💩"`, QuoteStructFieldName("💩"))
+	assert.Equal(`"a\"b\\c"`, QuoteStructFieldName(`a"b\c`))
+	assert.Equal(`"a\nb\r\tc\u0000\u007f\u0085"`, QuoteStructFieldName("a\nb\r\tc\x00\x7f\u0085"))
+}
+
+// quotedFieldNames need quoting, and between them use every escape.
+var quotedFieldNames = []string{`a"b`, `a\b`, "a\nb", "\r\t", "\x00\x1f\x7f\u0085", "ümlaut", "💩", "first name", `"\u00e9"`}
+
+func TestUnquoteStructFieldName(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range quotedFieldNames {
+		quoted := QuoteStructFieldName(name)
+		unquoted, err := UnquoteStructFieldName(quoted)
+		assert.NoError(err, quoted)
+		assert.Equal(name, unquoted)
+
+		p := Path{FieldPath{name}}
+		p2, err := ParsePath(p.String())
+		assert.NoError(err, p.String())
+		assert.True(p.Equals(p2), p.String())
+	}
+
+	unquoted, err := UnquoteStructFieldName(`"\u00e9"`)
+	assert.NoError(err)
+	assert.Equal("é", unquoted)
+	for _, bad := range []string{``, `a`, `""`, `"a`, `"a"b`, `"\x41"`, `"\a"`, "\"\xff\""} {
+		_, err := UnquoteStructFieldName(bad)
+		assert.Error(err, bad)
+	}
+}
+
 func TestStructWithNil(t *testing.T) {
 	assert.Panics(t, func() {
 		NewStruct("A", StructData{
@@ -324,3 +383,28 @@ func TestStructWithNil(t *testing.T) {
 		})
 	})
 }
+
+func TestStructIterFieldTypes(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewStruct("S", StructData{
+		"b": Bool(true),
+		"l": NewList(Number(1), String("x")),
+		"n": NewStruct("S", StructData{"b": Bool(false)}),
+	})
+	names := []string{}
+	s.IterFieldTypes(func(name string, v Value, typ *Type) {
+		names = append(names, name)
+		assert.True(s.Get(name).Equals(v))
+		assert.True(IsValueSubtypeOf(v, typ))
+		switch name {
+		case "b":
+			assert.True(BoolType.Equals(typ))
+		case "l":
+			assert.True(MakeListType(MakeUnionType(NumberType, StringType)).Equals(typ))
+		case "n":
+			assert.True(MakeStructType("S", StructField{"b", BoolType, false}).Equals(typ))
+		}
+	})
+	assert.Equal([]string{"b", "l", "n"}, names)
+}
@@ -0,0 +1,304 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package testgen generates pseudo-random Noms values, either of any type or
+// of a given type, for use in tests, fuzzing, load tests and simulations. A
+// Generator created with a given seed always produces the same sequence of
+// values.
+package testgen
+
+import (
+	"bytes"
+	"hash/fnv"
+	"math/rand"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	// DefaultMaxDepth is the default bound on the nesting of generated
+	// values.
+	DefaultMaxDepth = 4
+	// DefaultMaxLen is the default bound on the number of elements in a
+	// generated collection. Occasionally Value generates a larger collection
+	// so that chunked collections are covered as well.
+	DefaultMaxLen = 8
+
+	largeCollectionLen = 2000
+
+	// maxAttemptsPerElement bounds the attempts Collection makes at generating
+	// each distinct element of a Set or key of a Map.
+	maxAttemptsPerElement = 100
+)
+
+var (
+	structNames = []string{"", "A", "Foo", "Bar"}
+	fieldNames  = []string{"a", "b", "x", "field name", "日本"}
+)
+
+// Generator produces pseudo-random Values. The same seed always produces the
+// same sequence of Values.
+type Generator struct {
+	// MaxDepth bounds the nesting of generated values. Values of a given
+	// type may need to be nested more deeply than this, in which case it
+	// only bounds the nesting of values of the Value type and of cyclic types.
+	MaxDepth int
+	// MaxLen bounds the number of elements in generated collections, other
+	// than those made by Collection.
+	MaxLen int
+	r      *rand.Rand
+}
+
+// NewGenerator returns a Generator seeded with seed.
+func NewGenerator(seed int64) *Generator {
+	return &Generator{DefaultMaxDepth, DefaultMaxLen, rand.New(rand.NewSource(seed))}
+}
+
+// NewGeneratorFromBytes returns a Generator seeded with a hash of data. This
+// is how fuzzer supplied input is turned into Values.
+func NewGeneratorFromBytes(data []byte) *Generator {
+	h := fnv.New64a()
+	h.Write(data)
+	return NewGenerator(int64(h.Sum64()))
+}
+
+// Value returns the next random Value, of any type.
+func (g *Generator) Value() types.Value {
+	return g.value(g.MaxDepth)
+}
+
+// ValueOfType returns a random Value of type t, which means one for which
+// types.IsValueSubtypeOf(v, t) holds. Refs refer to values that aren't
+// written anywhere. It panics if t has no values, e.g. because it's the
+// empty union or a struct that can only contain itself.
+func (g *Generator) ValueOfType(t *types.Type) types.Value {
+	return g.valueOfType(t, g.MaxDepth, nil)
+}
+
+// Collection returns a random List, Set or Map of type t with exactly n
+// elements, whatever MaxLen is. It panics if t isn't a collection type, or
+// if the element type of a Set or key type of a Map doesn't have n distinct
+// values that can be found in reasonable time.
+func (g *Generator) Collection(t *types.Type, n int) types.Collection {
+	desc, ok := t.Desc.(types.CompoundDesc)
+	if !ok {
+		d.Panic("Not a collection type: %s", t.Describe())
+	}
+	elemType := desc.ElemTypes[0]
+	switch t.TargetKind() {
+	case types.ListKind:
+		vs := make([]types.Value, n)
+		for i := range vs {
+			vs[i] = g.valueOfType(elemType, g.MaxDepth-1, nil)
+		}
+		return types.NewList(vs...)
+	case types.SetKind:
+		return types.NewSet(g.distinct(elemType, n)...)
+	case types.MapKind:
+		keys := g.distinct(elemType, n)
+		kvs := make([]types.Value, 0, 2*n)
+		for _, k := range keys {
+			kvs = append(kvs, k, g.valueOfType(desc.ElemTypes[1], g.MaxDepth-1, nil))
+		}
+		return types.NewMap(kvs...)
+	}
+	d.Panic("Not a collection type: %s", t.Describe())
+	return nil
+}
+
+func (g *Generator) distinct(t *types.Type, n int) []types.Value {
+	seen := hash.HashSet{}
+	vs := make([]types.Value, 0, n)
+	for attempts := 0; len(vs) < n; attempts++ {
+		if attempts > maxAttemptsPerElement*(n+1) {
+			d.Panic("Couldn't generate %d distinct values of %s", n, t.Describe())
+		}
+		v := g.valueOfType(t, g.MaxDepth-1, nil)
+		if !seen.Has(v.Hash()) {
+			seen.Insert(v.Hash())
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+func (g *Generator) value(depth int) types.Value {
+	if depth <= 0 {
+		return g.primitive()
+	}
+	switch g.r.Intn(8) {
+	case 0, 1, 2:
+		return g.primitive()
+	case 3:
+		return types.NewList(g.values(depth - 1)...)
+	case 4:
+		return types.NewSet(g.values(depth - 1)...)
+	case 5:
+		vs := g.values(depth - 1)
+		kvs := make([]types.Value, 0, 2*len(vs))
+		for _, v := range vs {
+			kvs = append(kvs, v, g.value(depth-1))
+		}
+		return types.NewMap(kvs...)
+	case 6:
+		data := types.StructData{}
+		for _, name := range fieldNames {
+			if g.r.Intn(2) == 0 {
+				data[name] = g.value(depth - 1)
+			}
+		}
+		return types.NewStruct(structNames[g.r.Intn(len(structNames))], data)
+	default:
+		return types.TypeOf(g.value(depth - 1))
+	}
+}
+
+func (g *Generator) values(depth int) []types.Value {
+	n := g.r.Intn(g.MaxLen + 1)
+	if g.r.Intn(50) == 0 {
+		// Large enough to be chunked, but made of primitives to bound the size.
+		n, depth = largeCollectionLen, 0
+	}
+	vs := make([]types.Value, n)
+	for i := range vs {
+		vs[i] = g.value(depth)
+	}
+	return vs
+}
+
+func (g *Generator) primitive() types.Value {
+	switch g.r.Intn(5) {
+	case 0:
+		return g.bool()
+	case 1:
+		return g.integer()
+	case 2:
+		return g.float()
+	case 3:
+		return types.String(g.string())
+	default:
+		return g.blob()
+	}
+}
+
+func (g *Generator) bool() types.Bool {
+	return types.Bool(g.r.Intn(2) == 0)
+}
+
+func (g *Generator) integer() types.Number {
+	return types.Number(g.r.Int63n(1<<20) - 1<<19)
+}
+
+func (g *Generator) float() types.Number {
+	return types.Number(g.r.NormFloat64() * 1e6)
+}
+
+func (g *Generator) blob() types.Blob {
+	b := make([]byte, g.r.Intn(64))
+	g.r.Read(b)
+	return types.NewBlob(bytes.NewReader(b))
+}
+
+func (g *Generator) string() string {
+	rs := make([]rune, g.r.Intn(12))
+	for i := range rs {
+		if g.r.Intn(4) == 0 {
+			rs[i] = rune(0x80 + g.r.Intn(0x3000))
+		} else {
+			rs[i] = rune(' ' + g.r.Intn('~'-' '))
+		}
+	}
+	return string(rs)
+}
+
+// valueOfType generates a value of type t. structs holds the struct types
+// that enclose t, innermost last, which Cycle types refer to by name. Once
+// depth runs out, collections are empty, optional fields are left out and
+// unions prefer members that don't nest further, so that cyclic types end.
+func (g *Generator) valueOfType(t *types.Type, depth int, structs []*types.Type) types.Value {
+	switch desc := t.Desc.(type) {
+	case types.CycleDesc:
+		for i := len(structs) - 1; i >= 0; i-- {
+			if structs[i].Desc.(types.StructDesc).Name == string(desc) {
+				return g.valueOfType(structs[i], depth, structs[:i])
+			}
+		}
+		d.Panic("Unresolved cycle in type: %s", t.Describe())
+	case types.StructDesc:
+		structs = append(structs, t)
+		data := types.StructData{}
+		desc.IterFields(func(name string, ft *types.Type, optional bool) {
+			if optional && (depth <= 0 || g.r.Intn(2) == 0) {
+				return
+			}
+			data[name] = g.valueOfType(ft, depth-1, structs)
+		})
+		return types.NewStruct(desc.Name, data)
+	case types.CompoundDesc:
+		switch t.TargetKind() {
+		case types.ListKind, types.SetKind, types.MapKind:
+			n := 0
+			if depth > 0 {
+				n = g.r.Intn(g.MaxLen + 1)
+			}
+			elemType := desc.ElemTypes[0]
+			switch t.TargetKind() {
+			case types.ListKind:
+				vs := make([]types.Value, n)
+				for i := range vs {
+					vs[i] = g.valueOfType(elemType, depth-1, structs)
+				}
+				return types.NewList(vs...)
+			case types.SetKind:
+				vs := make([]types.Value, n)
+				for i := range vs {
+					vs[i] = g.valueOfType(elemType, depth-1, structs)
+				}
+				return types.NewSet(vs...)
+			default:
+				kvs := make([]types.Value, 0, 2*n)
+				for i := 0; i < n; i++ {
+					kvs = append(kvs, g.valueOfType(elemType, depth-1, structs), g.valueOfType(desc.ElemTypes[1], depth-1, structs))
+				}
+				return types.NewMap(kvs...)
+			}
+		case types.RefKind:
+			return types.NewRef(g.valueOfType(desc.ElemTypes[0], depth-1, structs))
+		case types.UnionKind:
+			if len(desc.ElemTypes) == 0 {
+				d.Panic("The empty union has no values")
+			}
+			if depth <= 0 {
+				for _, et := range desc.ElemTypes {
+					if types.IsPrimitiveKind(et.TargetKind()) {
+						return g.valueOfType(et, depth, structs)
+					}
+				}
+			}
+			return g.valueOfType(desc.ElemTypes[g.r.Intn(len(desc.ElemTypes))], depth, structs)
+		}
+	}
+
+	switch t.TargetKind() {
+	case types.BoolKind:
+		return g.bool()
+	case types.NumberKind:
+		if g.r.Intn(2) == 0 {
+			return g.integer()
+		}
+		return g.float()
+	case types.StringKind:
+		return types.String(g.string())
+	case types.BlobKind:
+		return g.blob()
+	case types.TypeKind:
+		return types.TypeOf(g.value(depth - 1))
+	case types.ValueKind:
+		return g.value(depth)
+	}
+	d.Panic("Unsupported type: %s", t.Describe())
+	return nil
+}
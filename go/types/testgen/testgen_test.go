@@ -0,0 +1,73 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package testgen
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// Struct Node {children: List<Cycle<Node>>, label?: String | Number}
+var nodeType = types.MakeStructType("Node",
+	types.StructField{Name: "children", Type: types.MakeListType(types.MakeCycleType("Node"))},
+	types.StructField{Name: "label", Type: types.MakeUnionType(types.StringType, types.NumberType), Optional: true},
+)
+
+func TestGeneratorIsDeterministic(t *testing.T) {
+	assert := assert.New(t)
+	g1, g2 := NewGenerator(42), NewGenerator(42)
+	for i := 0; i < 20; i++ {
+		assert.True(g1.Value().Equals(g2.Value()))
+		assert.True(g1.ValueOfType(nodeType).Equals(g2.ValueOfType(nodeType)))
+	}
+	assert.True(NewGeneratorFromBytes([]byte("abc")).Value().Equals(NewGeneratorFromBytes([]byte("abc")).Value()))
+}
+
+func TestValueOfType(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGenerator(0)
+	for _, typ := range []*types.Type{
+		types.BoolType,
+		types.NumberType,
+		types.StringType,
+		types.BlobType,
+		types.ValueType,
+		types.TypeType,
+		types.MakeListType(types.NumberType),
+		types.MakeSetType(types.StringType),
+		types.MakeMapType(types.StringType, types.MakeListType(types.BoolType)),
+		types.MakeRefType(types.MakeSetType(types.NumberType)),
+		types.MakeUnionType(types.BoolType, types.MakeListType(types.StringType)),
+		nodeType,
+		types.MakeListType(nodeType),
+	} {
+		for i := 0; i < 20; i++ {
+			v := g.ValueOfType(typ)
+			assert.True(types.IsValueSubtypeOf(v, typ), "%s is not a %s", types.EncodedValue(v), typ.Describe())
+		}
+	}
+
+	assert.Panics(func() { g.ValueOfType(types.MakeUnionType()) })
+}
+
+func TestCollection(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGenerator(0)
+	for _, typ := range []*types.Type{
+		types.MakeListType(types.BoolType),
+		types.MakeSetType(types.NumberType),
+		types.MakeMapType(types.StringType, nodeType),
+	} {
+		c := g.Collection(typ, 100)
+		assert.Equal(uint64(100), c.Len())
+		assert.True(types.IsValueSubtypeOf(c, typ))
+	}
+
+	assert.Equal(uint64(2), g.Collection(types.MakeSetType(types.BoolType), 2).Len())
+	assert.Panics(func() { g.Collection(types.MakeSetType(types.BoolType), 3) })
+	assert.Panics(func() { g.Collection(types.NumberType, 1) })
+}
@@ -93,14 +93,7 @@ func TestVerifyStructFieldName(t *testing.T) {
 		})
 	}
 	assertInvalid("")
-	assertInvalid(" ")
-	assertInvalid(" a")
-	assertInvalid("a ")
-	assertInvalid("0")
-	assertInvalid("_")
-	assertInvalid("0a")
-	assertInvalid("_a")
-	assertInvalid("💩")
+	assertInvalid("\xff")
 
 	assertValid := func(n string) {
 		MakeStructType("S", StructField{n, StringType, false})
@@ -110,6 +103,14 @@ func TestVerifyStructFieldName(t *testing.T) {
 	assertValid("a0")
 	assertValid("a_")
 	assertValid("a0_")
+	assertValid(" ")
+	assertValid(" a")
+	assertValid("a ")
+	assertValid("0")
+	assertValid("_")
+	assertValid("0a")
+	assertValid("_a")
+	assertValid("💩")
 }
 
 func TestVerifyStructName(t *testing.T) {
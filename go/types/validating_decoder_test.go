@@ -67,7 +67,7 @@ func TestValidatingBatchingSinkDecodeInvalidStructFieldName(t *testing.T) {
 	data := []interface{}{
 		uint8(TypeKind),
 		uint8(StructKind), "S", uint64(1), /* len */
-		"b ", uint8(NumberKind), false,
+		"", uint8(NumberKind), false,
 	}
 	assertPanicsOnInvalidChunk(t, data)
 }
@@ -15,15 +15,25 @@ type valueDecoder struct {
 	nomsReader
 	vr         ValueReader
 	validating bool
+	si         *stringInterner
 }
 
 // |tc| must be locked as long as the valueDecoder is being used
 func newValueDecoder(nr nomsReader, vr ValueReader) *valueDecoder {
-	return &valueDecoder{nr, vr, false}
+	return &valueDecoder{nr, vr, false, internerFor(vr)}
 }
 
 func newValueDecoderWithValidation(nr nomsReader, vr ValueReader) *valueDecoder {
-	return &valueDecoder{nr, vr, true}
+	return &valueDecoder{nr, vr, true, internerFor(vr)}
+}
+
+// readString shadows nomsReader.readString in order to intern the result, if
+// r.vr provided a stringInterner.
+func (r *valueDecoder) readString() string {
+	if r.si == nil {
+		return r.nomsReader.readString()
+	}
+	return r.si.intern(r.readStringBytes())
 }
 
 func (r *valueDecoder) readKind() NomsKind {
@@ -116,7 +126,8 @@ func (r *valueDecoder) readMetaSequence(k NomsKind) metaSequence {
 
 	data := []metaTuple{}
 	for i := uint64(0); i < count; i++ {
-		ref := r.readValue().(Ref)
+		// An inlined child is encoded in place of the Ref to it.
+		refOrChild := r.readValue()
 		v := r.readValue()
 		var key orderedKey
 		if r, ok := v.(Ref); ok {
@@ -126,14 +137,21 @@ func (r *valueDecoder) readMetaSequence(k NomsKind) metaSequence {
 			key = newOrderedKey(v)
 		}
 		numLeaves := r.readCount()
-		data = append(data, newMetaTuple(ref, key, numLeaves, nil))
+		if child, ok := refOrChild.(Collection); ok {
+			data = append(data, newInlineMetaTuple(child, key, numLeaves))
+		} else {
+			data = append(data, newMetaTuple(refOrChild.(Ref), key, numLeaves, nil))
+		}
 	}
 
 	return newMetaSequence(data, k, r.vr)
 }
 
 func (r *valueDecoder) readValue() Value {
-	k := r.readKind()
+	return r.readValueOfKind(r.readKind())
+}
+
+func (r *valueDecoder) readValueOfKind(k NomsKind) Value {
 	switch k {
 	case BlobKind:
 		isMeta := r.readBool()
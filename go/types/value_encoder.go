@@ -103,11 +103,16 @@ func (w *valueEncoder) maybeWriteMetaSequence(seq sequence) bool {
 	w.writeCount(uint64(count))
 	for i := 0; i < count; i++ {
 		tuple := ms.getItem(i).(metaTuple)
-		if tuple.child != nil && w.vw != nil {
-			// Write unwritten chunked sequences. Chunks are lazily written so that intermediate chunked structures like NewList().Append(x).Append(y) don't cause unnecessary churn.
-			w.vw.WriteValue(tuple.child)
+		if tuple.inline {
+			// Inlined children are encoded in place of the Ref to them.
+			w.writeValue(tuple.child)
+		} else {
+			if tuple.child != nil && w.vw != nil {
+				// Write unwritten chunked sequences. Chunks are lazily written so that intermediate chunked structures like NewList().Append(x).Append(y) don't cause unnecessary churn.
+				w.vw.WriteValue(tuple.child)
+			}
+			w.writeValue(tuple.ref)
 		}
-		w.writeValue(tuple.ref)
 		v := tuple.key.v
 		if !tuple.key.isOrderedByValue {
 			// See https://github.com/attic-labs/noms/issues/1688#issuecomment-227528987
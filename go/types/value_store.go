@@ -6,11 +6,13 @@ package types
 
 import (
 	"sync"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/metrics"
 	"github.com/attic-labs/noms/go/util/sizecache"
 )
 
@@ -51,6 +53,9 @@ type ValueStore struct {
 	bufferedChunkSize    uint64
 	withBufferedChildren map[hash.Hash]uint64 // chunk Hash -> ref height
 	valueCache           *sizecache.SizeCache
+	typeCache            *sizecache.SizeCache
+	strings              *stringInterner
+	inlineBytes          int
 
 	versOnce sync.Once
 }
@@ -58,6 +63,18 @@ type ValueStore struct {
 const (
 	defaultValueCacheSize = 1 << 25 // 32MB
 	defaultPendingPutMax  = 1 << 28 // 256MB
+
+	// typeCacheEntries is the number of Types that the typeCache keeps, for
+	// TypeOf and WriteValue. Each counts as one unit of its size.
+	typeCacheEntries = 1 << 14
+)
+
+// These are only sampled when metrics.Enabled().
+var (
+	readValueLatency   = metrics.RegisterTimeHistogram("types.ValueStore.ReadValueLatency")
+	bytesPerReadValue  = metrics.RegisterByteHistogram("types.ValueStore.BytesPerReadValue")
+	writeValueLatency  = metrics.RegisterTimeHistogram("types.ValueStore.WriteValueLatency")
+	bytesPerWriteValue = metrics.RegisterByteHistogram("types.ValueStore.BytesPerWriteValue")
 )
 
 // newTestValueStore creates a simple struct that satisfies ValueReadWriter
@@ -83,6 +100,8 @@ func newValueStoreWithCacheAndPending(cs chunks.ChunkStore, cacheSize, pendingMa
 		bufferedChunksMax:    pendingMax,
 		withBufferedChildren: map[hash.Hash]uint64{},
 		valueCache:           sizecache.New(cacheSize),
+		typeCache:            sizecache.New(typeCacheEntries),
+		strings:              newStringInterner(),
 
 		versOnce: sync.Once{},
 	}
@@ -99,6 +118,71 @@ func (lvs *ValueStore) ChunkStore() chunks.ChunkStore {
 	return lvs.cs
 }
 
+// interner returns the stringInterner shared by all the Values lvs decodes.
+func (lvs *ValueStore) interner() *stringInterner {
+	return lvs.strings
+}
+
+// SetInlineThreshold makes the collections that are built with lvs, or by
+// editing collections read from it, inline the chunks of their trees whose
+// encoding is smaller than |bytes| into their parents, rather than writing
+// them as chunks of their own. This saves chunks, and round trips to read
+// them, for collections of tiny values. Zero, the default, inlines nothing.
+// Call it before using lvs.
+//
+// Inlining changes the encoding, and so the hashes, of the collections it
+// applies to, while collections built without a ValueStore are never
+// inlined. Everything that writes to a database should use the same
+// threshold, and only versions of Noms that understand inlined chunks can
+// read collections that have them.
+func (lvs *ValueStore) SetInlineThreshold(bytes int) {
+	d.PanicIfTrue(bytes < 0)
+	lvs.inlineBytes = bytes
+}
+
+func (lvs *ValueStore) inlineThreshold() int {
+	return lvs.inlineBytes
+}
+
+// TypeOf is like the function TypeOf, but keeps the types it computes for
+// collections and structs, keyed by the hash of the value, so that asking
+// again for the type of the same value, as validation often does, doesn't
+// walk it again. WriteValue shares the cache, so the types of values that
+// were written are already known.
+func (lvs *ValueStore) TypeOf(v Value) *Type {
+	switch v.Kind() {
+	case ListKind, MapKind, SetKind, StructKind:
+		return lvs.typeOf(v, v.Hash())
+	}
+	return TypeOf(v)
+}
+
+// typeOf returns the type of |v|, whose hash is |h|, from the typeCache if
+// it's there, and otherwise computes it and keeps it if |v| is a collection or
+// struct.
+func (lvs *ValueStore) typeOf(v Value, h hash.Hash) *Type {
+	if t, ok := lvs.typeCache.Get(h); ok {
+		return t.(*Type)
+	}
+	t := TypeOf(v)
+	switch v.Kind() {
+	case ListKind, MapKind, SetKind, StructKind:
+		lvs.typeCache.Add(h, 1, t)
+	}
+	return t
+}
+
+// getBufferedChunk returns the chunk with hash h if it has been written to lvs
+// but not yet flushed, or EmptyChunk otherwise.
+func (lvs *ValueStore) getBufferedChunk(h hash.Hash) chunks.Chunk {
+	lvs.bufferMu.RLock()
+	defer lvs.bufferMu.RUnlock()
+	if pending, ok := lvs.bufferedChunks[h]; ok {
+		return pending
+	}
+	return chunks.EmptyChunk
+}
+
 // ReadValue reads and decodes a value from lvs. It is not considered an error
 // for the requested chunk to be empty; in this case, the function simply
 // returns nil.
@@ -111,15 +195,17 @@ func (lvs *ValueStore) ReadValue(h hash.Hash) Value {
 		return v.(Value)
 	}
 
-	chunk := func() chunks.Chunk {
-		lvs.bufferMu.RLock()
-		defer lvs.bufferMu.RUnlock()
-		if pending, ok := lvs.bufferedChunks[h]; ok {
-			return pending
-		}
-		return chunks.EmptyChunk
-	}()
+	chunk := lvs.getBufferedChunk(h)
 	if chunk.IsEmpty() {
+		if metrics.Enabled() {
+			t1 := time.Now()
+			defer func() {
+				readValueLatency.SampleTimeSince(t1)
+				if !chunk.IsEmpty() {
+					bytesPerReadValue.SampleLen(len(chunk.Data()))
+				}
+			}()
+		}
 		chunk = lvs.cs.Get(h)
 	}
 	if chunk.IsEmpty() {
@@ -131,6 +217,17 @@ func (lvs *ValueStore) ReadValue(h hash.Hash) Value {
 	return v
 }
 
+// readChunk returns the encoded chunk of the value with hash h, or EmptyChunk
+// if there's no such value, without decoding or caching it. It lets
+// projections decode just the parts of a value they need.
+func (lvs *ValueStore) readChunk(h hash.Hash) chunks.Chunk {
+	lvs.versOnce.Do(lvs.expectVersion)
+	if chunk := lvs.getBufferedChunk(h); !chunk.IsEmpty() {
+		return chunk
+	}
+	return lvs.cs.Get(h)
+}
+
 // ReadManyValues reads and decodes Values indicated by |hashes| from lvs. On
 // return, |foundValues| will have been fully sent all Values which have been
 // found. Any non-present Values will silently be ignored.
@@ -152,14 +249,7 @@ func (lvs *ValueStore) ReadManyValues(hashes hash.HashSet, foundValues chan<- Va
 			continue
 		}
 
-		chunk := func() chunks.Chunk {
-			lvs.bufferMu.RLock()
-			defer lvs.bufferMu.RUnlock()
-			if pending, ok := lvs.bufferedChunks[h]; ok {
-				return pending
-			}
-			return chunks.EmptyChunk
-		}()
+		chunk := lvs.getBufferedChunk(h)
 		if !chunk.IsEmpty() {
 			foundValues <- decode(h, &chunk, true)
 			continue
@@ -190,12 +280,19 @@ func (lvs *ValueStore) ReadManyValues(hashes hash.HashSet, foundValues chan<- Va
 func (lvs *ValueStore) WriteValue(v Value) Ref {
 	lvs.versOnce.Do(lvs.expectVersion)
 	d.PanicIfFalse(v != nil)
+	if metrics.Enabled() {
+		t1 := time.Now()
+		defer func() { writeValueLatency.SampleTimeSince(t1) }()
+	}
 	// Encoding v causes any child chunks, e.g. internal nodes if v is a meta sequence, to get written. That needs to happen before we try to validate v.
 	c := EncodeValue(v, lvs)
 	d.PanicIfTrue(c.IsEmpty())
+	if metrics.Enabled() {
+		bytesPerWriteValue.SampleLen(len(c.Data()))
+	}
 	h := c.Hash()
 	height := maxChunkHeight(v) + 1
-	r := constructRef(h, TypeOf(v), height)
+	r := constructRef(h, lvs.typeOf(v, h), height)
 	if v, ok := lvs.valueCache.Get(h); ok && v != nil {
 		return r
 	}
@@ -235,3 +235,96 @@ type badVersionStore struct {
 func (b *badVersionStore) Version() string {
 	return "BAD"
 }
+
+func TestValueStoreInlineThreshold(t *testing.T) {
+	assert := assert.New(t)
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	build := func(threshold int, edited int) (*chunks.TestStorage, Ref) {
+		storage := &chunks.TestStorage{}
+		vs := NewValueStore(storage.NewView())
+		vs.SetInlineThreshold(threshold)
+		values := make(chan Value)
+		lc := NewStreamingList(vs, values)
+		for i := 0; i < 1000; i++ {
+			if i == edited {
+				values <- String("edited")
+			} else {
+				values <- Number(i)
+			}
+		}
+		close(values)
+		r := vs.WriteValue(<-lc)
+		vs.persist()
+		return storage, r
+	}
+
+	// Every chunk that a chunk refers to must be in |storage|.
+	var assertComplete func(storage *chunks.TestStorage, r Ref)
+	assertComplete = func(storage *chunks.TestStorage, r Ref) {
+		c := storage.Get(r.TargetHash())
+		if assert.False(c.IsEmpty()) {
+			DecodeValue(c, nil).WalkRefs(func(r Ref) { assertComplete(storage, r) })
+		}
+	}
+
+	plain, plainRef := build(0, -1)
+	inlined, inlinedRef := build(1024, -1)
+	assert.True(inlined.Len() < plain.Len()/2, "%d chunks inlined, %d not", inlined.Len(), plain.Len())
+	assert.NotEqual(plainRef.TargetHash(), inlinedRef.TargetHash())
+	assertComplete(inlined, inlinedRef)
+
+	vs := NewValueStore(inlined.NewView())
+	vs.SetInlineThreshold(1024)
+	l := vs.ReadValue(inlinedRef.TargetHash()).(List)
+	assert.Equal(uint64(1000), l.Len())
+	i := 0
+	l.IterAll(func(v Value, idx uint64) {
+		assert.True(Number(i).Equals(v))
+		i++
+	})
+
+	// Edits of an inlined list are inlined the same way as building it afresh.
+	r := vs.WriteValue(l.Set(500, String("edited")))
+	vs.persist()
+	editedStorage, editedRef := build(1024, 500)
+	assert.Equal(editedRef.TargetHash(), r.TargetHash())
+	assertComplete(editedStorage, editedRef)
+	assertComplete(inlined, r)
+}
+
+func TestValueStoreTypeCache(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	s := NewStruct("S", StructData{
+		"list": NewList(Number(1), String("a")),
+		"set":  NewSet(Bool(true)),
+	})
+	typ := vs.TypeOf(s)
+	assert.True(TypeOf(s).Equals(typ))
+	// The type is computed once and kept, for the same value or an equal one.
+	assert.True(typ == vs.TypeOf(s))
+	assert.True(typ == vs.TypeOf(NewStruct("S", StructData{
+		"set":  NewSet(Bool(true)),
+		"list": NewList(Number(1), String("a")),
+	})))
+	assert.True(NumberType.Equals(vs.TypeOf(Number(1))))
+
+	l := NewList(s, Number(2))
+
+	// WriteValue computes the types of the values it writes for their Refs,
+	// and keeps them, so that writing the same value again reuses the type.
+	r := vs.WriteValue(l)
+	assert.True(MakeRefType(TypeOf(l)).Equals(TypeOf(r)))
+	cached, ok := vs.typeCache.Get(r.TargetHash())
+	assert.True(ok)
+	assert.True(cached.(*Type) == vs.WriteValue(l).TargetType())
+	assert.True(cached.(*Type) == vs.TypeOf(l))
+
+	// Primitives aren't kept.
+	r = vs.WriteValue(String("a"))
+	_, ok = vs.typeCache.Get(r.TargetHash())
+	assert.False(ok)
+}
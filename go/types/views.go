@@ -0,0 +1,153 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+// ListView is the read side of a List. List implements it, as do views like
+// MappedList that compute their elements on demand from another ListView, so
+// that a pipeline of transformations doesn't copy its data at every step.
+type ListView interface {
+	Len() uint64
+	Empty() bool
+	Get(idx uint64) Value
+	Iter(f ListIterFunc)
+	IterAll(f ListIterAllFunc)
+}
+
+// MapView is the read side of a Map. Map implements it, as do views like
+// FilteredMap that compute their entries on demand from another MapView.
+type MapView interface {
+	Len() uint64
+	Empty() bool
+	Has(key Value) bool
+	Get(key Value) Value
+	MaybeGet(key Value) (v Value, ok bool)
+	Iter(cb MapIterCallback)
+	IterAll(cb MapIterAllCallback)
+}
+
+// MappedList is a ListView whose elements are those of a base ListView,
+// transformed by a function each time they're read. It holds no elements of
+// its own; List builds a List that does.
+type MappedList struct {
+	base ListView
+	f    func(v Value, index uint64) Value
+}
+
+// NewMappedList returns a view of |base| in which each element |v|, at
+// |index|, is f(v, index). |f| is called each time an element is read, so it
+// should be cheap, or the view should be materialized with List.
+func NewMappedList(base ListView, f func(v Value, index uint64) Value) MappedList {
+	return MappedList{base, f}
+}
+
+func (ml MappedList) Len() uint64 {
+	return ml.base.Len()
+}
+
+func (ml MappedList) Empty() bool {
+	return ml.base.Empty()
+}
+
+func (ml MappedList) Get(idx uint64) Value {
+	return ml.f(ml.base.Get(idx), idx)
+}
+
+func (ml MappedList) Iter(f ListIterFunc) {
+	ml.base.Iter(func(v Value, index uint64) bool {
+		return f(ml.f(v, index), index)
+	})
+}
+
+func (ml MappedList) IterAll(f ListIterAllFunc) {
+	ml.base.IterAll(func(v Value, index uint64) {
+		f(ml.f(v, index), index)
+	})
+}
+
+// List computes the elements of |ml| and returns a List of them, for
+// committing. Chunks of the List are written to |vrw| as they're built, if
+// it isn't nil.
+func (ml MappedList) List(vrw ValueReadWriter) List {
+	ch := newEmptyListSequenceChunker(vrw, vrw)
+	ml.IterAll(func(v Value, index uint64) {
+		ch.Append(v)
+	})
+	return newList(ch.Done())
+}
+
+// FilteredMap is a MapView whose entries are those of a base MapView for
+// which a predicate holds. It holds no entries of its own; Map builds a Map
+// that does.
+type FilteredMap struct {
+	base MapView
+	pred func(k, v Value) bool
+}
+
+// NewFilteredMap returns a view of the entries of |base| for which |pred|
+// returns true. |pred| is called each time an entry is read, and Len and
+// Empty have to scan |base|, so a view that's read often should be
+// materialized with Map.
+func NewFilteredMap(base MapView, pred func(k, v Value) bool) FilteredMap {
+	return FilteredMap{base, pred}
+}
+
+func (fm FilteredMap) Len() (n uint64) {
+	fm.IterAll(func(k, v Value) {
+		n++
+	})
+	return
+}
+
+func (fm FilteredMap) Empty() bool {
+	empty := true
+	fm.Iter(func(k, v Value) bool {
+		empty = false
+		return true
+	})
+	return empty
+}
+
+func (fm FilteredMap) Has(key Value) bool {
+	_, ok := fm.MaybeGet(key)
+	return ok
+}
+
+func (fm FilteredMap) Get(key Value) Value {
+	v, _ := fm.MaybeGet(key)
+	return v
+}
+
+func (fm FilteredMap) MaybeGet(key Value) (v Value, ok bool) {
+	v, ok = fm.base.MaybeGet(key)
+	if !ok || !fm.pred(key, v) {
+		return nil, false
+	}
+	return v, true
+}
+
+func (fm FilteredMap) Iter(cb MapIterCallback) {
+	fm.base.Iter(func(k, v Value) bool {
+		return fm.pred(k, v) && cb(k, v)
+	})
+}
+
+func (fm FilteredMap) IterAll(cb MapIterAllCallback) {
+	fm.base.IterAll(func(k, v Value) {
+		if fm.pred(k, v) {
+			cb(k, v)
+		}
+	})
+}
+
+// Map computes the entries of |fm| and returns a Map of them, for
+// committing. Chunks of the Map are written to |vrw| as they're built, if it
+// isn't nil.
+func (fm FilteredMap) Map(vrw ValueReadWriter) Map {
+	ch := newEmptyMapSequenceChunker(vrw, vrw)
+	fm.IterAll(func(k, v Value) {
+		ch.Append(mapEntry{k, v})
+	})
+	return newMap(ch.Done().(orderedSequence))
+}
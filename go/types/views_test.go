@@ -0,0 +1,109 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMappedList(t *testing.T) {
+	assert := assert.New(t)
+
+	const n = 5000
+	base := NewList(generateNumbersAsValues(n)...)
+	calls := 0
+	double := NewMappedList(base, func(v Value, index uint64) Value {
+		calls++
+		return v.(Number) * 2
+	})
+	assert.Equal(0, calls)
+
+	// Views can be stacked.
+	var view ListView = NewMappedList(double, func(v Value, index uint64) Value {
+		return v.(Number) + Number(index)
+	})
+	assert.Equal(uint64(n), view.Len())
+	assert.False(view.Empty())
+	assert.True(Number(30).Equals(view.Get(10)))
+	assert.Equal(1, calls)
+
+	count := uint64(0)
+	view.Iter(func(v Value, index uint64) bool {
+		assert.True(Number(3 * index).Equals(v))
+		count++
+		return index == 99
+	})
+	assert.Equal(uint64(100), count)
+
+	storage := &chunks.TestStorage{}
+	vs := NewValueStore(storage.NewView())
+	l := view.(MappedList).List(vs)
+	assert.Equal(uint64(n), l.Len())
+	expected := make([]Value, n)
+	for i := range expected {
+		expected[i] = Number(3 * i)
+	}
+	assert.True(NewList(expected...).Equals(l))
+
+	// The chunks of the materialized List were written as it was built.
+	r := vs.WriteValue(l)
+	vs.persist()
+	assert.True(l.Equals(NewValueStore(storage.NewView()).ReadValue(r.TargetHash())))
+
+	assert.True(NewMappedList(NewList(), nil).List(nil).Empty())
+}
+
+func TestFilteredMap(t *testing.T) {
+	assert := assert.New(t)
+
+	kvs := []Value{}
+	for i := 0; i < 1000; i++ {
+		kvs = append(kvs, Number(i), String("v"))
+	}
+	base := NewMap(kvs...)
+	even := func(k, v Value) bool {
+		return int(k.(Number))%2 == 0
+	}
+	var view MapView = NewFilteredMap(base, even)
+
+	assert.Equal(uint64(500), view.Len())
+	assert.False(view.Empty())
+	assert.True(view.Has(Number(2)))
+	assert.False(view.Has(Number(3)))
+	assert.False(view.Has(Number(2000)))
+	assert.True(String("v").Equals(view.Get(Number(4))))
+	assert.Nil(view.Get(Number(5)))
+
+	// Views can be stacked.
+	view = NewFilteredMap(view, func(k, v Value) bool {
+		return k.(Number) < 10
+	})
+	keys := []Value{}
+	view.IterAll(func(k, v Value) {
+		keys = append(keys, k)
+	})
+	assert.Equal([]Value{Number(0), Number(2), Number(4), Number(6), Number(8)}, keys)
+
+	keys = nil
+	view.Iter(func(k, v Value) bool {
+		keys = append(keys, k)
+		return len(keys) == 2
+	})
+	assert.Equal([]Value{Number(0), Number(2)}, keys)
+
+	m := NewFilteredMap(base, even).Map(nil)
+	expected := []Value{}
+	for i := 0; i < 1000; i += 2 {
+		expected = append(expected, Number(i), String("v"))
+	}
+	assert.True(NewMap(expected...).Equals(m))
+
+	none := NewFilteredMap(base, func(k, v Value) bool { return false })
+	assert.True(none.Empty())
+	assert.True(none.Map(nil).Empty())
+}
@@ -0,0 +1,203 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "github.com/attic-labs/noms/go/d"
+
+// ValueDifference is a place where two values differ, as found by
+// WalkDifferences.
+type ValueDifference struct {
+	// Path is where the values differ, relative to the values being walked.
+	// It's empty if they differ as a whole.
+	Path Path
+	// ChangeType says whether Current was added, Last was removed, or Last
+	// was modified to become Current.
+	ChangeType DiffChangeType
+	// Last is the value at Path in the last value, or nil if it was added.
+	Last Value
+	// Current is the value at Path in the current value, or nil if it was
+	// removed.
+	Current Value
+	// Key is the key of the map entry, the set element, or the name of the
+	// struct field, as a String, that Path ends in, or nil if it ends in a
+	// list index. Paths end in the hash of keys that can't be path indexes, so
+	// Key is what says which it is.
+	Key Value
+}
+
+// DifferenceCallback is called by WalkDifferences for each place where the
+// values it walks differ, and returns true to stop the walk.
+type DifferenceCallback func(dif ValueDifference) (stop bool)
+
+// WalkDifferences walks |last| and |current| together, depth first, calling
+// |cb| for each place where they differ. Where both hold Lists, Maps, Sets or
+// Structs it descends into them rather than calling cb, so cb is called for
+// the values that were added or removed, and for the primitive values, Refs
+// and Blobs that were modified, or values that changed kind.
+//
+// The differences of collections are found with List.Diff, and with the
+// DiffHybrid of Maps and Sets, or their DiffLeftRight if |leftRight| is set,
+// so the parts of collections whose chunks are the same in both are skipped
+// without being read. Refs aren't followed.
+func WalkDifferences(last, current Value, leftRight bool, cb DifferenceCallback) {
+	d.PanicIfTrue(last == nil || current == nil)
+	if last.Equals(current) {
+		return
+	}
+	w := differenceWalker{leftRight, cb}
+	if !canWalkDifferences(last, current) {
+		cb(ValueDifference{nil, DiffChangeModified, last, current, nil})
+		return
+	}
+	w.walk(nil, last, current)
+}
+
+// canWalkDifferences returns true if WalkDifferences descends into |last|
+// and |current|, which differ.
+func canWalkDifferences(last, current Value) bool {
+	switch k := last.Kind(); k {
+	case ListKind, MapKind, SetKind, StructKind:
+		return k == current.Kind()
+	}
+	return false
+}
+
+type differenceWalker struct {
+	leftRight bool
+	cb        DifferenceCallback
+}
+
+// walk calls cb for the differences of |last| and |current|, which are of the
+// same kind, at |p|, and returns true if it stopped the walk.
+func (w differenceWalker) walk(p Path, last, current Value) bool {
+	switch last := last.(type) {
+	case List:
+		return w.walkLists(p, last, current.(List))
+	case Map:
+		current := current.(Map)
+		return w.walkOrdered(p, indexPathPart,
+			func(changes chan<- ValueChanged, stop <-chan struct{}) {
+				if w.leftRight {
+					current.DiffLeftRight(last, changes, stop)
+				} else {
+					current.DiffHybrid(last, changes, stop)
+				}
+			},
+			last.Get, current.Get)
+	case Set:
+		current := current.(Set)
+		self := func(k Value) Value { return k }
+		return w.walkOrdered(p, indexPathPart,
+			func(changes chan<- ValueChanged, stop <-chan struct{}) {
+				if w.leftRight {
+					current.DiffLeftRight(last, changes, stop)
+				} else {
+					current.DiffHybrid(last, changes, stop)
+				}
+			},
+			self, self)
+	case Struct:
+		current := current.(Struct)
+		return w.walkOrdered(p,
+			func(k Value) PathPart { return NewFieldPath(string(k.(String))) },
+			func(changes chan<- ValueChanged, stop <-chan struct{}) {
+				current.Diff(last, changes, stop)
+			},
+			func(k Value) Value { return last.Get(string(k.(String))) },
+			func(k Value) Value { return current.Get(string(k.(String))) })
+	}
+	panic("unreachable")
+}
+
+// visit calls cb for the difference of |last| and |current| at |p|, or
+// descends into them, and returns true if the walk was stopped.
+func (w differenceWalker) visit(p Path, change DiffChangeType, last, current, key Value) bool {
+	if change == DiffChangeModified && canWalkDifferences(last, current) {
+		return w.walk(p, last, current)
+	}
+	return w.cb(ValueDifference{p, change, last, current, key})
+}
+
+func (w differenceWalker) walkLists(p Path, last, current List) (stop bool) {
+	splices := make(chan Splice)
+	stopChan := make(chan struct{}, 1) // so that it doesn't block if Diff is done
+	go func() {
+		current.Diff(last, splices, stopChan)
+		close(splices)
+	}()
+
+	at := func(i uint64) Path {
+		return p.Append(NewIndexPath(Number(i)))
+	}
+	for splice := range splices {
+		if stop {
+			break
+		}
+		if splice.SpRemoved == splice.SpAdded {
+			// The elements were modified, rather than added or removed.
+			for i := uint64(0); i < splice.SpRemoved && !stop; i++ {
+				stop = w.visit(at(splice.SpAt+i), DiffChangeModified, last.Get(splice.SpAt+i), current.Get(splice.SpFrom+i), nil)
+			}
+			continue
+		}
+		for i := uint64(0); i < splice.SpRemoved && !stop; i++ {
+			stop = w.visit(at(splice.SpAt+i), DiffChangeRemoved, last.Get(splice.SpAt+i), nil, nil)
+		}
+		for i := uint64(0); i < splice.SpAdded && !stop; i++ {
+			stop = w.visit(at(splice.SpFrom+i), DiffChangeAdded, nil, current.Get(splice.SpFrom+i), nil)
+		}
+	}
+
+	if stop {
+		stopChan <- struct{}{}
+		for range splices {
+		}
+	}
+	return
+}
+
+// walkOrdered walks the changes that |diff| sends, of a Map, Set or Struct
+// whose entries are found by key with |lastGet| and |currentGet|, and that
+// are at |p| followed by the PathPart that |part| gives their key.
+func (w differenceWalker) walkOrdered(p Path, part func(k Value) PathPart, diff func(changes chan<- ValueChanged, stop <-chan struct{}), lastGet, currentGet func(k Value) Value) (stop bool) {
+	changes := make(chan ValueChanged)
+	stopChan := make(chan struct{}, 1) // so that it doesn't block if diff is done
+	go func() {
+		diff(changes, stopChan)
+		close(changes)
+	}()
+
+	for change := range changes {
+		if stop {
+			break
+		}
+		k := change.Key
+		p1 := p.Append(part(k))
+		switch change.ChangeType {
+		case DiffChangeAdded:
+			stop = w.visit(p1, DiffChangeAdded, nil, currentGet(k), k)
+		case DiffChangeRemoved:
+			stop = w.visit(p1, DiffChangeRemoved, lastGet(k), nil, k)
+		case DiffChangeModified:
+			stop = w.visit(p1, DiffChangeModified, lastGet(k), currentGet(k), k)
+		default:
+			panic("unknown change type")
+		}
+	}
+
+	if stop {
+		stopChan <- struct{}{}
+		for range changes {
+		}
+	}
+	return
+}
+
+func indexPathPart(k Value) PathPart {
+	if ValueCanBePathIndex(k) {
+		return NewIndexPath(k)
+	}
+	return NewHashIndexPath(k.Hash())
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestWalkDifferences(t *testing.T) {
+	assert := assert.New(t)
+
+	key := NewStruct("Key", StructData{"id": Number(1)})
+	last := NewStruct("S", StructData{
+		"name": String("last"),
+		"list": NewList(Number(1), Number(2), Number(3)),
+		"map":  NewMap(String("a"), Number(1), String("b"), NewStruct("", StructData{"x": Number(1)})),
+		"set":  NewSet(String("kept"), String("removed")),
+		"gone": Bool(true),
+		"kind": Number(1),
+	})
+	current := NewStruct("S", StructData{
+		"name": String("current"),
+		"list": NewList(Number(1), Number(5), Number(3), Number(4)),
+		"map":  NewMap(String("a"), Number(1), String("b"), NewStruct("", StructData{"x": Number(2)}), key, Number(3)),
+		"set":  NewSet(String("kept"), String("added")),
+		"kind": String("1"),
+	})
+
+	type dif struct {
+		path          string
+		change        DiffChangeType
+		last, current Value
+		key           Value
+	}
+	walk := func(leftRight bool) (difs []dif) {
+		WalkDifferences(last, current, leftRight, func(vd ValueDifference) bool {
+			difs = append(difs, dif{vd.Path.String(), vd.ChangeType, vd.Last, vd.Current, vd.Key})
+			return false
+		})
+		return
+	}
+	expected := []dif{
+		{".gone", DiffChangeRemoved, Bool(true), nil, String("gone")},
+		{".kind", DiffChangeModified, Number(1), String("1"), String("kind")},
+		{".list[1]", DiffChangeModified, Number(2), Number(5), nil},
+		{".list[3]", DiffChangeAdded, nil, Number(4), nil},
+		{`.map["b"].x`, DiffChangeModified, Number(1), Number(2), String("x")},
+		{".map[#" + key.Hash().String() + "]", DiffChangeAdded, nil, Number(3), key},
+		{".name", DiffChangeModified, String("last"), String("current"), String("name")},
+		{`.set["added"]`, DiffChangeAdded, nil, String("added"), String("added")},
+		{`.set["removed"]`, DiffChangeRemoved, String("removed"), nil, String("removed")},
+	}
+	for _, leftRight := range []bool{false, true} {
+		difs := walk(leftRight)
+		assert.Equal(len(expected), len(difs))
+		for i, e := range expected {
+			if i >= len(difs) {
+				break
+			}
+			d := difs[i]
+			assert.Equal(e.path, d.path)
+			assert.Equal(e.change, d.change, e.path)
+			for _, pair := range [][2]Value{{e.last, d.last}, {e.current, d.current}, {e.key, d.key}} {
+				if pair[0] == nil {
+					assert.Nil(pair[1], e.path)
+				} else {
+					assert.True(pair[0].Equals(pair[1]), e.path)
+				}
+			}
+		}
+	}
+
+	// Returning true stops the walk.
+	calls := 0
+	WalkDifferences(last, current, false, func(vd ValueDifference) bool {
+		calls++
+		return calls == 3
+	})
+	assert.Equal(3, calls)
+
+	// Values that can't be descended into differ as a whole.
+	var root []ValueDifference
+	WalkDifferences(Number(1), NewList(), false, func(vd ValueDifference) bool {
+		root = append(root, vd)
+		return false
+	})
+	assert.Equal([]ValueDifference{{nil, DiffChangeModified, Number(1), NewList(), nil}}, root)
+
+	WalkDifferences(last, last, false, func(vd ValueDifference) bool {
+		assert.Fail("equal values don't differ")
+		return false
+	})
+}
@@ -80,6 +80,11 @@ func RegisterOutputpagerFlags(flags *flag.FlagSet) {
 	flags.BoolVar(&noPager, "no-pager", false, "suppress paging functionality")
 }
 
+// Disable turns paging off, as --no-pager does.
+func Disable() {
+	noPager = true
+}
+
 func IsStdoutTty() bool {
 	return goisatty.IsTerminal(os.Stdout.Fd())
 }
@@ -104,3 +104,10 @@ func (c *SizeCache) Drop(key interface{}) {
 		delete(c.cache, key)
 	}
 }
+
+// Size returns the total size of the entries currently in the cache.
+func (c *SizeCache) Size() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.totalSize
+}
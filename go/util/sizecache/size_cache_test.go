@@ -44,6 +44,7 @@ func TestSizeCache(t *testing.T) {
 	c.Add(hashFromString("data-7"), defSize, "data-7")
 	assert.Equal(hashFromString("data-7"), c.lru.Back().Value)
 	assert.Equal(uint64(1000), c.totalSize)
+	assert.Equal(uint64(1000), c.Size())
 
 	c.Add(hashFromString("no-data"), 0, nil)
 	v, ok = c.Get(hashFromString("no-data"))
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package throttle provides a bandwidth limiter that can be shared by any
+// number of io.Readers.
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// Limiter limits the rate at which bytes pass through the Readers it wraps.
+// It is a token bucket which holds up to one second's worth of bytes, so
+// short bursts above the rate are allowed after a quiet period. A Limiter is
+// safe for concurrent use; concurrent Readers share its rate.
+type Limiter struct {
+	rate float64 // bytes per second
+
+	mu    sync.Mutex
+	avail float64 // may be negative, in which case callers wait it out
+	last  time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewLimiter returns a Limiter which lets bytesPerSecond bytes through per
+// second.
+func NewLimiter(bytesPerSecond uint64) *Limiter {
+	d.PanicIfFalse(bytesPerSecond > 0)
+	return &Limiter{
+		rate:  float64(bytesPerSecond),
+		avail: float64(bytesPerSecond),
+		last:  time.Now(),
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+// Wait blocks until n more bytes may pass through l.
+func (l *Limiter) Wait(n int) {
+	l.mu.Lock()
+	now := l.now()
+	l.avail += now.Sub(l.last).Seconds() * l.rate
+	if l.avail > l.rate {
+		l.avail = l.rate
+	}
+	l.last = now
+	l.avail -= float64(n)
+	var wait time.Duration
+	if l.avail < 0 {
+		wait = time.Duration(-l.avail / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if wait > 0 {
+		l.sleep(wait)
+	}
+}
+
+// Reader returns an io.Reader which reads from r no faster than l allows.
+func (l *Limiter) Reader(r io.Reader) io.Reader {
+	return &reader{r, l}
+}
+
+// ReadCloser is like Reader, but for an io.ReadCloser.
+func (l *Limiter) ReadCloser(rc io.ReadCloser) io.ReadCloser {
+	return &readCloser{reader{rc, l}, rc}
+}
+
+type reader struct {
+	inner io.Reader
+	l     *Limiter
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	n, err = r.inner.Read(p)
+	if n > 0 {
+		r.l.Wait(n)
+	}
+	return
+}
+
+type readCloser struct {
+	reader
+	io.Closer
+}
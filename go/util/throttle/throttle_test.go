@@ -0,0 +1,63 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package throttle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+// newTestLimiter returns a Limiter whose clock only moves when it sleeps.
+func newTestLimiter(bytesPerSecond uint64) (*Limiter, *time.Duration) {
+	l := NewLimiter(bytesPerSecond)
+	start := l.last
+	slept := time.Duration(0)
+	l.now = func() time.Time { return start.Add(slept) }
+	l.sleep = func(d time.Duration) { slept += d }
+	return l, &slept
+}
+
+func TestLimiterWait(t *testing.T) {
+	assert := assert.New(t)
+	l, slept := newTestLimiter(1000)
+
+	// The first second's worth is free.
+	l.Wait(1000)
+	assert.Equal(time.Duration(0), *slept)
+
+	l.Wait(500)
+	assert.Equal(500*time.Millisecond, *slept)
+	l.Wait(2000)
+	assert.Equal(2500*time.Millisecond, *slept)
+}
+
+func TestLimiterReader(t *testing.T) {
+	assert := assert.New(t)
+	l, slept := newTestLimiter(1 << 10)
+
+	data := make([]byte, 5<<10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	read, err := ioutil.ReadAll(l.Reader(bytes.NewReader(data)))
+	assert.NoError(err)
+	assert.Equal(data, read)
+	assert.Equal(4*time.Second, *slept)
+}
+
+func TestLimiterReadCloser(t *testing.T) {
+	assert := assert.New(t)
+	l, _ := newTestLimiter(1 << 10)
+
+	rc := l.ReadCloser(ioutil.NopCloser(bytes.NewReader([]byte("abc"))))
+	read, err := ioutil.ReadAll(rc)
+	assert.NoError(err)
+	assert.Equal("abc", string(read))
+	assert.NoError(rc.Close())
+}
@@ -34,7 +34,7 @@ func main() {
 		flag.PrintDefaults()
 	}
 
-	spec.RegisterCommitMetaFlags(flag.CommandLine)
+	config.RegisterCommitMetaFlags(flag.CommandLine)
 	verbose.RegisterVerboseFlags(flag.CommandLine)
 	flag.Parse(true)
 
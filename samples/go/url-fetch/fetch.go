@@ -34,7 +34,7 @@ func main() {
 	performCommit := flag.Bool("commit", true, "commit the data to head of the dataset (otherwise only write the data to the dataset)")
 	stdin := flag.Bool("stdin", false, "read blob from stdin")
 
-	spec.RegisterCommitMetaFlags(flag.CommandLine)
+	config.RegisterCommitMetaFlags(flag.CommandLine)
 	verbose.RegisterVerboseFlags(flag.CommandLine)
 
 	flag.Usage = func() {
@@ -55,7 +55,7 @@ func (a refIndexList) Less(i, j int) bool { return a[i].index < a[j].index }
 
 func main() {
 	err := d.Try(func() {
-		spec.RegisterCommitMetaFlags(flag.CommandLine)
+		config.RegisterCommitMetaFlags(flag.CommandLine)
 		verbose.RegisterVerboseFlags(flag.CommandLine)
 		profile.RegisterProfileFlags(flag.CommandLine)
 		flag.Usage = customUsage